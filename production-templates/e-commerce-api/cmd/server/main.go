@@ -17,6 +17,7 @@ import (
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"github.com/modsynth/e-commerce-api/internal/repository"
 	"github.com/modsynth/e-commerce-api/internal/service"
+	"github.com/modsynth/e-commerce-api/internal/websocket"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -42,21 +43,30 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	productRepo := repository.NewProductRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
 	cartRepo := repository.NewCartRepository(db)
+	guestCartRepo := repository.NewGuestCartRepository(db)
 	orderRepo := repository.NewOrderRepository(db)
 
+	// Initialize WebSocket hub
+	hub := websocket.NewHub()
+	go hub.Run()
+
 	// Initialize services
 	authService := service.NewAuthService(userRepo, cfg)
-	productService := service.NewProductService(productRepo)
-	cartService := service.NewCartService(cartRepo, productRepo)
-	orderService := service.NewOrderService(db, orderRepo, cartRepo, productRepo)
+	productService := service.NewProductService(productRepo, cfg)
+	categoryService := service.NewCategoryService(categoryRepo)
+	cartService := service.NewCartService(cartRepo, guestCartRepo, productRepo, cfg)
+	orderService := service.NewOrderService(db, orderRepo, cartRepo, productRepo, cartService, hub, service.LogLowStockNotifier{}, service.ManualPaymentGateway{}, cfg)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	productHandler := handlers.NewProductHandler(productService)
-	cartHandler := handlers.NewCartHandler(cartService)
+	categoryHandler := handlers.NewCategoryHandler(categoryService)
+	cartHandler := handlers.NewCartHandler(cartService, cfg.Server.Env == "production")
 	orderHandler := handlers.NewOrderHandler(orderService)
 	adminHandler := handlers.NewAdminHandler(orderService)
+	wsHandler := websocket.NewHandler(hub)
 
 	// Set gin mode
 	if cfg.Server.Env == "production" {
@@ -98,6 +108,8 @@ func main() {
 		{
 			products.GET("", productHandler.ListProducts)
 			products.GET("/:id", productHandler.GetProduct)
+			products.GET("/:id/related", productHandler.GetRelatedProducts)
+			products.GET("/:id/availability", productHandler.GetAvailability)
 
 			// Admin only
 			productsAdmin := products.Group("")
@@ -109,15 +121,40 @@ func main() {
 			}
 		}
 
+		// Category routes (public read, protected write)
+		categories := v1.Group("/categories")
+		{
+			categories.GET("/tree", categoryHandler.GetCategoryTree)
+
+			// Admin only
+			categoriesAdmin := categories.Group("")
+			categoriesAdmin.Use(middleware.AuthMiddleware(cfg), middleware.AdminMiddleware())
+			{
+				categoriesAdmin.POST("", categoryHandler.CreateCategory)
+				categoriesAdmin.PUT("/:id", categoryHandler.UpdateCategory)
+			}
+		}
+
 		// Cart routes (protected)
 		cart := v1.Group("/cart")
 		cart.Use(middleware.AuthMiddleware(cfg))
 		{
 			cart.GET("", cartHandler.GetCart)
+			cart.GET("/count", cartHandler.GetCartCount)
+			cart.GET("/validate", cartHandler.ValidateCart)
+			cart.POST("/refresh-prices", cartHandler.RefreshCartPrices)
 			cart.POST("/items", cartHandler.AddToCart)
 			cart.PUT("/items/:id", cartHandler.UpdateCartItem)
 			cart.DELETE("/items/:id", cartHandler.RemoveFromCart)
 			cart.DELETE("", cartHandler.ClearCart)
+			cart.POST("/merge", cartHandler.MergeGuestCart)
+		}
+
+		// Guest cart routes (public, keyed by the guest cart cookie)
+		guestCart := v1.Group("/guest-cart")
+		{
+			guestCart.GET("", cartHandler.GetGuestCart)
+			guestCart.POST("/items", cartHandler.AddToGuestCart)
 		}
 
 		// Orders routes (protected)
@@ -128,8 +165,12 @@ func main() {
 			orders.GET("", orderHandler.GetUserOrders)
 			orders.GET("/:id", orderHandler.GetOrder)
 			orders.PUT("/:id/cancel", orderHandler.CancelOrder)
+			orders.DELETE("/:id/items/:itemId", orderHandler.CancelOrderItem)
 		}
 
+		// Order events over WebSocket (protected)
+		v1.GET("/ws/orders", middleware.AuthMiddleware(cfg), wsHandler.HandleOrders)
+
 		// Payments routes (protected)
 		payments := v1.Group("/payments")
 		payments.Use(middleware.AuthMiddleware(cfg))
@@ -149,7 +190,12 @@ func main() {
 		{
 			admin.GET("/orders", adminHandler.GetAllOrders)
 			admin.PUT("/orders/:id", adminHandler.UpdateOrderStatus)
+			admin.POST("/orders/:id/refund", adminHandler.RefundOrder)
 			admin.GET("/stats", adminHandler.GetStats)
+			admin.POST("/products/import", productHandler.ImportProducts)
+			admin.POST("/products/bulk", productHandler.BulkCreateProducts)
+			admin.GET("/products/low-stock", productHandler.GetLowStock)
+			admin.GET("/products/:id/availability", productHandler.GetAvailabilityDetail)
 			admin.GET("/users", func(c *gin.Context) {
 				c.JSON(http.StatusNotImplemented, gin.H{"message": "User management coming soon"})
 			})
@@ -216,8 +262,11 @@ func migrateDB(db *gorm.DB) error {
 		&domain.ProductImage{},
 		&domain.Cart{},
 		&domain.CartItem{},
+		&domain.GuestCart{},
+		&domain.GuestCartItem{},
 		&domain.Order{},
 		&domain.OrderItem{},
+		&domain.OrderStatusHistory{},
 	)
 }
 
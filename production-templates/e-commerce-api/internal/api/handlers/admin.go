@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -25,7 +26,10 @@ func NewAdminHandler(orderService service.OrderService) *AdminHandler {
 // @Produce json
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
+// @Param cursor query string false "Continue after the next_cursor of a previous page instead of using page"
 // @Param status query string false "Filter by status"
+// @Param created_from query string false "Only orders placed on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Only orders placed on or before this date (YYYY-MM-DD)"
 // @Success 200 {array} domain.Order
 // @Router /api/v1/admin/orders [get]
 // @Security BearerAuth
@@ -38,15 +42,20 @@ func (h *AdminHandler) GetAllOrders(c *gin.Context) {
 
 	orders, total, err := h.orderService.GetAllOrders(&query)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) || errors.Is(err, domain.ErrInvalidDateRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":  orders,
-		"total": total,
-		"page":  query.Page,
-		"limit": query.Limit,
+		"data":        orders,
+		"total":       total,
+		"page":        query.Page,
+		"limit":       query.Limit,
+		"next_cursor": query.NextCursor(orders),
 	})
 }
 
@@ -82,6 +91,45 @@ func (h *AdminHandler) UpdateOrderStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "order status updated"})
 }
 
+// RefundOrder godoc
+// @Summary Refund an order's payment (Admin only)
+// @Description Refunds amount through the payment gateway, or everything not refunded yet when amount is omitted. A full refund marks the order refunded and returns its items to stock.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param request body domain.RefundOrderRequest false "Amount to refund"
+// @Success 200 {object} domain.Order
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/admin/orders/{id}/refund [post]
+// @Security BearerAuth
+func (h *AdminHandler) RefundOrder(c *gin.Context) {
+	adminID, _ := c.Get("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	// The body is optional; without one the whole remaining amount is refunded
+	var req domain.RefundOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := h.orderService.RefundOrder(uint(orderID), adminID.(uint), req.Amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
 // GetStats godoc
 // @Summary Get dashboard statistics (Admin only)
 // @Tags admin
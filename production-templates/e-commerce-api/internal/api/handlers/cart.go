@@ -9,13 +9,23 @@ import (
 	"github.com/modsynth/e-commerce-api/internal/service"
 )
 
+// guestCartCookie holds the token of an anonymous shopper's cart
+const (
+	guestCartCookie       = "guest_cart"
+	guestCartCookieMaxAge = 30 * 24 * 60 * 60
+)
+
 type CartHandler struct {
-	cartService service.CartService
+	cartService   service.CartService
+	secureCookies bool
 }
 
-func NewCartHandler(cartService service.CartService) *CartHandler {
+// NewCartHandler creates the cart handler. secureCookies restricts the guest
+// cart cookie to HTTPS.
+func NewCartHandler(cartService service.CartService, secureCookies bool) *CartHandler {
 	return &CartHandler{
-		cartService: cartService,
+		cartService:   cartService,
+		secureCookies: secureCookies,
 	}
 }
 
@@ -39,6 +49,26 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	c.JSON(http.StatusOK, cart)
 }
 
+// GetCartCount godoc
+// @Summary Get the number of items in the user's cart
+// @Tags cart
+// @Produce json
+// @Success 200 {object} domain.CartCount
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/cart/count [get]
+// @Security BearerAuth
+func (h *CartHandler) GetCartCount(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	count, err := h.cartService.GetCartCount(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, count)
+}
+
 // AddToCart godoc
 // @Summary Add item to cart
 // @Tags cart
@@ -142,3 +172,128 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// ValidateCart godoc
+// @Summary Check the user's cart before checkout
+// @Description Lists every item that is out of stock, changed price or is no longer for sale. Checkout fails with the same issues.
+// @Tags cart
+// @Produce json
+// @Success 200 {object} map[string]interface{} "valid and the list of domain.CartValidationIssue"
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/cart/validate [get]
+// @Security BearerAuth
+func (h *CartHandler) ValidateCart(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	issues, err := h.cartService.Validate(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":  len(issues) == 0,
+		"issues": issues,
+	})
+}
+
+// RefreshCartPrices godoc
+// @Summary Update cart item prices to the current product prices
+// @Description Returns the items whose price changed, with their old and new price.
+// @Tags cart
+// @Produce json
+// @Success 200 {object} map[string]interface{} "changes: list of domain.CartPriceChange"
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/cart/refresh-prices [post]
+// @Security BearerAuth
+func (h *CartHandler) RefreshCartPrices(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	changes, err := h.cartService.RefreshPrices(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+// GetGuestCart godoc
+// @Summary Get the cart of a shopper who is not logged in
+// @Tags cart
+// @Produce json
+// @Success 200 {object} domain.GuestCartWithSummary
+// @Router /api/v1/guest-cart [get]
+func (h *CartHandler) GetGuestCart(c *gin.Context) {
+	token, _ := c.Cookie(guestCartCookie)
+
+	cart, err := h.cartService.GetGuestCart(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// AddToGuestCart godoc
+// @Summary Add item to the cart of a shopper who is not logged in
+// @Description Starts a guest cart and sets its cookie when there is none yet
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param request body domain.AddToCartRequest true "Cart item"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/guest-cart/items [post]
+func (h *CartHandler) AddToGuestCart(c *gin.Context) {
+	var req domain.AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, _ := c.Cookie(guestCartCookie)
+	token, err := h.cartService.AddToGuestCart(token, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.setGuestCartCookie(c, token, guestCartCookieMaxAge)
+	c.JSON(http.StatusOK, gin.H{"message": "item added to cart"})
+}
+
+// MergeGuestCart godoc
+// @Summary Move the guest cart's items into the user's cart
+// @Description Call after logging in. Items that are no longer available or in stock are reported as adjustments.
+// @Tags cart
+// @Produce json
+// @Success 200 {object} domain.CartMergeResult
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/cart/merge [post]
+// @Security BearerAuth
+func (h *CartHandler) MergeGuestCart(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	token, err := c.Cookie(guestCartCookie)
+	if err != nil || token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no guest cart to merge"})
+		return
+	}
+
+	result, err := h.cartService.MergeGuestCart(userID.(uint), token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The guest cart is gone once merged
+	h.setGuestCartCookie(c, "", -1)
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *CartHandler) setGuestCartCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(guestCartCookie, token, maxAge, "/api/v1", "", h.secureCookies, true)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/service"
+)
+
+type CategoryHandler struct {
+	categoryService service.CategoryService
+}
+
+func NewCategoryHandler(categoryService service.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+	}
+}
+
+// GetCategoryTree godoc
+// @Summary Get the category tree
+// @Tags categories
+// @Produce json
+// @Success 200 {array} domain.Category
+// @Router /api/v1/categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.categoryService.GetCategoryTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// CreateCategory godoc
+// @Summary Create a category (Admin only)
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param request body domain.CreateCategoryRequest true "Category details"
+// @Success 201 {object} domain.Category
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/categories [post]
+// @Security BearerAuth
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req domain.CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.categoryService.CreateCategory(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category (Admin only)
+// @Description Setting parent_id to 0 moves the category to the top level. A category cannot be moved under its own descendants.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param request body domain.UpdateCategoryRequest true "Category details"
+// @Success 200 {object} domain.Category
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/categories/{id} [put]
+// @Security BearerAuth
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var req domain.UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -27,6 +28,7 @@ func NewOrderHandler(orderService service.OrderService) *OrderHandler {
 // @Param request body domain.CreateOrderRequest true "Order details"
 // @Success 201 {object} domain.Order
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} domain.CartValidationError "Cart items need attention; see GET /api/v1/cart/validate"
 // @Router /api/v1/orders [post]
 // @Security BearerAuth
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -40,6 +42,14 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	order, err := h.orderService.CreateOrder(userID.(uint), &req)
 	if err != nil {
+		var validationErr *domain.CartValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  err.Error(),
+				"issues": validationErr.Issues,
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -53,26 +63,39 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 // @Produce json
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
+// @Param cursor query string false "Continue after the next_cursor of a previous page instead of using page"
+// @Param status query string false "Filter by status"
+// @Param created_from query string false "Only orders placed on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Only orders placed on or before this date (YYYY-MM-DD)"
 // @Success 200 {array} domain.Order
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/orders [get]
 // @Security BearerAuth
 func (h *OrderHandler) GetUserOrders(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	var query domain.OrderListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	orders, total, err := h.orderService.GetUserOrders(userID.(uint), page, limit)
+	orders, total, err := h.orderService.GetUserOrders(userID.(uint), &query)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) || errors.Is(err, domain.ErrInvalidDateRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":  orders,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+		"data":        orders,
+		"total":       total,
+		"page":        query.Page,
+		"limit":       query.Limit,
+		"next_cursor": query.NextCursor(orders),
 	})
 }
 
@@ -127,3 +150,38 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "order cancelled successfully"})
 }
+
+// CancelOrderItem godoc
+// @Summary Cancel a single item of an order
+// @Description Removes the item, restores its stock and recomputes the order totals. Cancelling the last item cancels the order.
+// @Tags orders
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param itemId path int true "Order item ID"
+// @Success 200 {object} domain.Order
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/orders/{id}/items/{itemId} [delete]
+// @Security BearerAuth
+func (h *OrderHandler) CancelOrderItem(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order item ID"})
+		return
+	}
+
+	order, err := h.orderService.CancelItem(userID.(uint), uint(orderID), uint(itemID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
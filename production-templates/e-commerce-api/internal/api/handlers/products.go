@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -26,6 +28,8 @@ func NewProductHandler(productService service.ProductService) *ProductHandler {
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
 // @Param category_id query int false "Filter by category"
+// @Param cursor query string false "Continue after the next_cursor of a previous page instead of using page"
+// @Param include_subcategories query bool false "Include products from subcategories of category_id"
 // @Param search query string false "Search term"
 // @Success 200 {array} domain.Product
 // @Router /api/v1/products [get]
@@ -38,15 +42,20 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 
 	products, total, err := h.productService.ListProducts(&query)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":  products,
-		"total": total,
-		"page":  query.Page,
-		"limit": query.Limit,
+		"data":        products,
+		"total":       total,
+		"page":        query.Page,
+		"limit":       query.Limit,
+		"next_cursor": query.NextCursor(products),
 	})
 }
 
@@ -74,6 +83,83 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, product)
 }
 
+// GetRelatedProducts godoc
+// @Summary Get related products
+// @Description Active products from the same category, or featured products when the product has no category.
+// @Tags products
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param limit query int false "Maximum number of products (default 8, max 20)"
+// @Success 200 {array} domain.Product
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/products/{id}/related [get]
+func (h *ProductHandler) GetRelatedProducts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	products, err := h.productService.GetRelatedProducts(uint(id), limit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": products})
+}
+
+// GetAvailability godoc
+// @Summary Get product availability
+// @Tags products
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} domain.ProductAvailability
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/products/{id}/availability [get]
+func (h *ProductHandler) GetAvailability(c *gin.Context) {
+	h.respondAvailability(c, false)
+}
+
+// GetAvailabilityDetail godoc
+// @Summary Get product availability with on-hand and reserved stock (Admin only)
+// @Tags admin
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} domain.ProductAvailability
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/admin/products/{id}/availability [get]
+// @Security BearerAuth
+func (h *ProductHandler) GetAvailabilityDetail(c *gin.Context) {
+	h.respondAvailability(c, true)
+}
+
+func (h *ProductHandler) respondAvailability(c *gin.Context, detail bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	availability, err := h.productService.GetAvailability(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !detail {
+		availability = availability.Public()
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
 // CreateProduct godoc
 // @Summary Create a new product (Admin only)
 // @Tags products
@@ -156,3 +242,112 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// ImportProducts godoc
+// @Summary Import products from a CSV file (Admin only)
+// @Description Creates or updates products by SKU. Rows that fail validation are reported and skipped.
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV file with a header row"
+// @Success 200 {object} domain.ImportResult
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/admin/products/import [post]
+// @Security BearerAuth
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to open uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.productService.ImportCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// BulkCreateProducts godoc
+// @Summary Create many products at once (Admin only)
+// @Description Accepts a JSON array of products, or a CSV file uploaded as "file" (or sent as text/csv) whose header lists name,slug,price,sku,stock_quantity,description,category_id,compare_price,cost_price,barcode,weight,low_stock_threshold,track_inventory,allow_backorder,is_active,featured in that order; columns after price may be left out. Valid rows are created in one transaction; invalid rows and duplicate SKUs or slugs are reported per row and nothing existing is updated.
+// @Tags admin
+// @Accept json
+// @Accept multipart/form-data
+// @Produce json
+// @Param request body []domain.CreateProductRequest false "Products"
+// @Param file formData file false "CSV file with a header row"
+// @Success 200 {object} domain.ImportResult
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/admin/products/bulk [post]
+// @Security BearerAuth
+func (h *ProductHandler) BulkCreateProducts(c *gin.Context) {
+	var (
+		result *domain.ImportResult
+		err    error
+	)
+
+	switch c.ContentType() {
+	case "multipart/form-data":
+		fileHeader, ferr := c.FormFile("file")
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, ferr := fileHeader.Open()
+		if ferr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to open uploaded file"})
+			return
+		}
+		defer file.Close()
+		result, err = h.productService.BulkCreateCSV(file)
+	case "text/csv":
+		result, err = h.productService.BulkCreateCSV(c.Request.Body)
+	default:
+		// Decoded without binding validation so that invalid rows are
+		// reported per row instead of rejecting the whole batch
+		var reqs []domain.CreateProductRequest
+		if derr := json.NewDecoder(c.Request.Body).Decode(&reqs); derr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be a JSON array of products"})
+			return
+		}
+		result, err = h.productService.BulkCreate(reqs)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetLowStock godoc
+// @Summary List products that are low on stock (Admin only)
+// @Description Tracked products at or below their low_stock_threshold, or LOW_STOCK_THRESHOLD when they have none, lowest stock first.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.LowStockItem
+// @Router /api/v1/admin/products/low-stock [get]
+// @Security BearerAuth
+func (h *ProductHandler) GetLowStock(c *gin.Context) {
+	items, err := h.productService.GetLowStock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  items,
+		"total": len(items),
+	})
+}
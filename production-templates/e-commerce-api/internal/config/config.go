@@ -3,18 +3,23 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/modsynth/e-commerce-api/internal/jwtkeys"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Stripe   StripeConfig
-	S3       S3Config
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Stripe    StripeConfig
+	S3        S3Config
+	Order     OrderConfig
+	Inventory InventoryConfig
 }
 
 type ServerConfig struct {
@@ -38,10 +43,16 @@ type RedisConfig struct {
 	DB       int
 }
 
+// JWTConfig holds the token signing keys. New tokens are signed with Secret
+// and carry KeyID in their kid header. PreviousKeys maps the IDs of rotated
+// out keys to their secrets; their tokens are accepted until they are
+// removed from it.
 type JWTConfig struct {
-	Secret     string
-	AccessTTL  time.Duration
-	RefreshTTL time.Duration
+	Secret       string
+	KeyID        string
+	PreviousKeys map[string]string
+	AccessTTL    time.Duration
+	RefreshTTL   time.Duration
 }
 
 type StripeConfig struct {
@@ -56,6 +67,21 @@ type S3Config struct {
 	Bucket    string
 }
 
+// OrderConfig bounds the total of a single order. A MaxTotal of zero means
+// orders are not capped. RequireActiveProducts rejects checkouts of carts
+// holding products that were deactivated after being added.
+type OrderConfig struct {
+	MinTotal              float64
+	MaxTotal              float64
+	RequireActiveProducts bool
+}
+
+// InventoryConfig controls stock alerts. Tracked products whose stock falls to
+// LowStockThreshold or below are reported as low on stock.
+type InventoryConfig struct {
+	LowStockThreshold int
+}
+
 func Load() (*Config, error) {
 	// Load .env file if exists
 	_ = godotenv.Load()
@@ -80,9 +106,11 @@ func Load() (*Config, error) {
 			DB:       0,
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "your-secret-key-change-this"),
-			AccessTTL:  parseDuration(getEnv("JWT_ACCESS_TTL", "15m")),
-			RefreshTTL: parseDuration(getEnv("JWT_REFRESH_TTL", "168h")),
+			Secret:       getEnv("JWT_SECRET", "your-secret-key-change-this"),
+			KeyID:        getEnv("JWT_KEY_ID", jwtkeys.DefaultKeyID),
+			PreviousKeys: parseKeys(getEnv("JWT_PREVIOUS_KEYS", "")),
+			AccessTTL:    parseDuration(getEnv("JWT_ACCESS_TTL", "15m")),
+			RefreshTTL:   parseDuration(getEnv("JWT_REFRESH_TTL", "168h")),
 		},
 		Stripe: StripeConfig{
 			SecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
@@ -94,11 +122,24 @@ func Load() (*Config, error) {
 			SecretKey: getEnv("S3_SECRET_KEY", ""),
 			Bucket:    getEnv("S3_BUCKET", "ecommerce-images"),
 		},
+		Order: OrderConfig{
+			MinTotal: parseFloat(getEnv("ORDER_MIN_TOTAL", "0")),
+			MaxTotal: parseFloat(getEnv("ORDER_MAX_TOTAL", "0")),
+
+			RequireActiveProducts: getEnv("ORDER_REQUIRE_ACTIVE_PRODUCTS", "true") == "true",
+		},
+		Inventory: InventoryConfig{
+			LowStockThreshold: parseInt(getEnv("LOW_STOCK_THRESHOLD", "5")),
+		},
 	}
 
 	return config, nil
 }
 
+func (c *JWTConfig) KeySet() *jwtkeys.KeySet {
+	return jwtkeys.New(c.KeyID, c.Secret, c.PreviousKeys)
+}
+
 func (c *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -124,3 +165,33 @@ func parseDuration(s string) time.Duration {
 	}
 	return d
 }
+
+// parseKeys parses a comma-separated list of "id:secret" pairs, skipping
+// malformed entries
+func parseKeys(s string) map[string]string {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		keys[id] = secret
+	}
+	return keys
+}
+
+func parseInt(s string) int {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Cart struct {
 	ID        uint       `json:"id" gorm:"primaryKey"`
@@ -11,14 +15,15 @@ type Cart struct {
 }
 
 type CartItem struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	CartID    uint      `json:"cart_id" gorm:"not null"`
-	ProductID uint      `json:"product_id" gorm:"not null"`
-	Product   *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity  int       `json:"quantity" gorm:"not null;default:1"`
-	Price     float64   `json:"price" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CartID       uint      `json:"cart_id" gorm:"not null"`
+	ProductID    uint      `json:"product_id" gorm:"not null"`
+	Product      *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity     int       `json:"quantity" gorm:"not null;default:1"`
+	Price        float64   `json:"price" gorm:"not null"`
+	PriceChanged bool      `json:"price_changed" gorm:"-"` // Calculated field: Price differs from the product's current price
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type CartWithSummary struct {
@@ -27,6 +32,58 @@ type CartWithSummary struct {
 	ItemsCount int   `json:"items_count"`
 }
 
+// CartCount is the lightweight cart summary shown in cart badges
+type CartCount struct {
+	ItemsCount int     `json:"items_count"`
+	Subtotal   float64 `json:"subtotal"`
+}
+
+type CartIssueType string
+
+const (
+	CartIssueOutOfStock      CartIssueType = "out_of_stock"
+	CartIssuePriceChanged    CartIssueType = "price_changed"
+	CartIssueProductInactive CartIssueType = "product_inactive"
+	CartIssueProductNotFound CartIssueType = "product_not_found"
+)
+
+// CartValidationIssue is a problem with one cart item that has to be fixed
+// before checkout. Available is set for out-of-stock items, CurrentPrice for
+// items whose product price changed since they were added.
+type CartValidationIssue struct {
+	CartItemID   uint          `json:"cart_item_id"`
+	ProductID    uint          `json:"product_id"`
+	ProductName  string        `json:"product_name,omitempty"`
+	Type         CartIssueType `json:"type"`
+	Quantity     int           `json:"quantity"`
+	Available    int           `json:"available,omitempty"`
+	CartPrice    float64       `json:"cart_price"`
+	CurrentPrice float64       `json:"current_price,omitempty"`
+}
+
+// CartValidationError rejects a checkout whose cart has issues
+type CartValidationError struct {
+	Issues []CartValidationIssue `json:"issues"`
+}
+
+func (e *CartValidationError) Error() string {
+	problems := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		problems[i] = fmt.Sprintf("%s (%s)", issue.ProductName, issue.Type)
+	}
+	return fmt.Sprintf("cart items need attention: %s", strings.Join(problems, ", "))
+}
+
+// CartPriceChange is a cart item whose price was brought up to date with its
+// product
+type CartPriceChange struct {
+	CartItemID  uint    `json:"cart_item_id"`
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	OldPrice    float64 `json:"old_price"`
+	NewPrice    float64 `json:"new_price"`
+}
+
 type AddToCartRequest struct {
 	ProductID uint `json:"product_id" binding:"required"`
 	Quantity  int  `json:"quantity" binding:"required,gte=1"`
@@ -35,3 +92,44 @@ type AddToCartRequest struct {
 type UpdateCartItemRequest struct {
 	Quantity int `json:"quantity" binding:"required,gte=1"`
 }
+
+// GuestCart holds the items of a shopper who has not logged in. It is found by
+// an opaque token kept in a cookie and merged into the user's cart on login.
+type GuestCart struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	Token     string          `json:"-" gorm:"not null;uniqueIndex"`
+	Items     []GuestCartItem `json:"items,omitempty" gorm:"foreignKey:GuestCartID"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type GuestCartItem struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	GuestCartID uint      `json:"guest_cart_id" gorm:"not null"`
+	ProductID   uint      `json:"product_id" gorm:"not null"`
+	Product     *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity    int       `json:"quantity" gorm:"not null;default:1"`
+	Price       float64   `json:"price" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type GuestCartWithSummary struct {
+	*GuestCart
+	Subtotal   float64 `json:"subtotal"`
+	ItemsCount int     `json:"items_count"`
+}
+
+// CartMergeAdjustment reports a guest cart item that could not be moved into
+// the user's cart in full
+type CartMergeAdjustment struct {
+	ProductID uint   `json:"product_id"`
+	Requested int    `json:"requested"` // Quantity in the guest cart
+	Added     int    `json:"added"`
+	Reason    string `json:"reason"`
+}
+
+type CartMergeResult struct {
+	Cart        *CartWithSummary      `json:"cart"`
+	Adjustments []CartMergeAdjustment `json:"adjustments,omitempty"`
+}
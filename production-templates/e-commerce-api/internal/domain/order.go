@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type OrderStatus string
 type PaymentStatus string
@@ -15,36 +18,39 @@ const (
 )
 
 const (
-	PaymentStatusPending   PaymentStatus = "pending"
-	PaymentStatusSucceeded PaymentStatus = "succeeded"
-	PaymentStatusFailed    PaymentStatus = "failed"
-	PaymentStatusRefunded  PaymentStatus = "refunded"
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusSucceeded         PaymentStatus = "succeeded"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
 )
 
 type Order struct {
-	ID                     uint          `json:"id" gorm:"primaryKey"`
-	UserID                 uint          `json:"user_id" gorm:"not null"`
-	User                   *User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	OrderNumber            string        `json:"order_number" gorm:"uniqueIndex;not null"`
-	Status                 OrderStatus   `json:"status" gorm:"not null;default:'pending'"`
-	Subtotal               float64       `json:"subtotal" gorm:"not null"`
-	Tax                    float64       `json:"tax" gorm:"not null;default:0"`
-	Shipping               float64       `json:"shipping" gorm:"not null;default:0"`
-	Total                  float64       `json:"total" gorm:"not null"`
-	Currency               string        `json:"currency" gorm:"not null;default:'USD'"`
-	PaymentStatus          PaymentStatus `json:"payment_status" gorm:"not null;default:'pending'"`
-	PaymentMethod          string        `json:"payment_method"`
-	StripePaymentIntentID  string        `json:"stripe_payment_intent_id"`
-	ShippingAddressLine1   string        `json:"shipping_address_line1"`
-	ShippingAddressLine2   string        `json:"shipping_address_line2"`
-	ShippingCity           string        `json:"shipping_city"`
-	ShippingState          string        `json:"shipping_state"`
-	ShippingPostalCode     string        `json:"shipping_postal_code"`
-	ShippingCountry        string        `json:"shipping_country"`
-	Notes                  string        `json:"notes"`
-	Items                  []OrderItem   `json:"items,omitempty" gorm:"foreignKey:OrderID"`
-	CreatedAt              time.Time     `json:"created_at"`
-	UpdatedAt              time.Time     `json:"updated_at"`
+	ID                    uint                 `json:"id" gorm:"primaryKey"`
+	UserID                uint                 `json:"user_id" gorm:"not null"`
+	User                  *User                `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	OrderNumber           string               `json:"order_number" gorm:"uniqueIndex;not null"`
+	Status                OrderStatus          `json:"status" gorm:"not null;default:'pending'"`
+	Subtotal              float64              `json:"subtotal" gorm:"not null"`
+	Tax                   float64              `json:"tax" gorm:"not null;default:0"`
+	Shipping              float64              `json:"shipping" gorm:"not null;default:0"`
+	Total                 float64              `json:"total" gorm:"not null"`
+	Currency              string               `json:"currency" gorm:"not null;default:'USD'"`
+	PaymentStatus         PaymentStatus        `json:"payment_status" gorm:"not null;default:'pending'"`
+	RefundedAmount        float64              `json:"refunded_amount" gorm:"not null;default:0"`
+	PaymentMethod         string               `json:"payment_method"`
+	StripePaymentIntentID string               `json:"stripe_payment_intent_id"`
+	ShippingAddressLine1  string               `json:"shipping_address_line1"`
+	ShippingAddressLine2  string               `json:"shipping_address_line2"`
+	ShippingCity          string               `json:"shipping_city"`
+	ShippingState         string               `json:"shipping_state"`
+	ShippingPostalCode    string               `json:"shipping_postal_code"`
+	ShippingCountry       string               `json:"shipping_country"`
+	Notes                 string               `json:"notes"`
+	Items                 []OrderItem          `json:"items,omitempty" gorm:"foreignKey:OrderID"`
+	StatusHistory         []OrderStatusHistory `json:"status_history,omitempty" gorm:"foreignKey:OrderID"`
+	CreatedAt             time.Time            `json:"created_at"`
+	UpdatedAt             time.Time            `json:"updated_at"`
 }
 
 type OrderItem struct {
@@ -59,6 +65,24 @@ type OrderItem struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// OrderStatusHistory records a change to an order's status or payment
+// status. ChangedBy is the user who made it, or nil for changes made without
+// one, such as status updates through the admin API.
+type OrderStatusHistory struct {
+	ID            uint          `json:"id" gorm:"primaryKey"`
+	OrderID       uint          `json:"order_id" gorm:"not null;index"`
+	Status        OrderStatus   `json:"status" gorm:"not null"`
+	PaymentStatus PaymentStatus `json:"payment_status" gorm:"not null"`
+	Amount        *float64      `json:"amount,omitempty"` // Refunded amount, for refunds
+	ChangedBy     *uint         `json:"changed_by,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// RemainingRefundable is the part of the order total not refunded yet
+func (o *Order) RemainingRefundable() float64 {
+	return o.Total - o.RefundedAmount
+}
+
 type ShippingAddress struct {
 	Line1      string `json:"line1" binding:"required"`
 	Line2      string `json:"line2"`
@@ -74,15 +98,52 @@ type CreateOrderRequest struct {
 	Notes           string          `json:"notes"`
 }
 
+// OrderStatusEvent is pushed to the order's owner when its status changes
+type OrderStatusEvent struct {
+	OrderID        uint        `json:"order_id"`
+	OrderNumber    string      `json:"order_number"`
+	Status         OrderStatus `json:"status"`
+	PreviousStatus OrderStatus `json:"previous_status"`
+}
+
 type UpdateOrderStatusRequest struct {
 	Status OrderStatus `json:"status" binding:"required"`
 }
 
+// RefundOrderRequest refunds part of an order's payment, or everything not
+// refunded yet when Amount is nil
+type RefundOrderRequest struct {
+	Amount *float64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
 type OrderListQuery struct {
 	Page          int            `form:"page" binding:"omitempty,gte=1"`
 	Limit         int            `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Cursor        string         `form:"cursor"` // Replaces page when set; see Cursor
 	Status        *OrderStatus   `form:"status"`
 	PaymentStatus *PaymentStatus `form:"payment_status"`
 	UserID        *uint          `form:"user_id"`
 	OrderNumber   string         `form:"order_number"`
+	CreatedFrom   *time.Time     `form:"created_from" time_format:"2006-01-02"` // First day included
+	CreatedTo     *time.Time     `form:"created_to" time_format:"2006-01-02"`   // Last day included
+}
+
+var ErrInvalidDateRange = errors.New("created_from must not be after created_to")
+
+// Validate checks the filters that binding cannot
+func (q *OrderListQuery) Validate() error {
+	if q.CreatedFrom != nil && q.CreatedTo != nil && q.CreatedFrom.After(*q.CreatedTo) {
+		return ErrInvalidDateRange
+	}
+	return nil
+}
+
+// NextCursor returns the cursor for the page after orders, or "" when there is
+// none.
+func (q *OrderListQuery) NextCursor(orders []*Order) string {
+	if len(orders) == 0 {
+		return ""
+	}
+	last := orders[len(orders)-1]
+	return nextCursor(len(orders), q.Limit, last.CreatedAt, last.ID)
 }
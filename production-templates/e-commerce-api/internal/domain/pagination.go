@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the (created_at, id) sort key of the last row a client has seen.
+// Listing from a cursor instead of an offset keeps pages stable while new rows
+// are inserted.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+// Encode returns the cursor as an opaque, URL-safe string
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a string produced by Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var nanos int64
+	var id uint
+	if n, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil || n != 2 || id == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+// nextCursor returns the cursor after the last row of a full page, or "" when
+// the page is short and therefore the last one.
+func nextCursor(count, limit int, createdAt time.Time, id uint) string {
+	if count == 0 || count < limit {
+		return ""
+	}
+	return Cursor{CreatedAt: createdAt, ID: id}.Encode()
+}
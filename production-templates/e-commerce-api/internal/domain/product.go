@@ -3,34 +3,69 @@ package domain
 import "time"
 
 type Category struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name" gorm:"not null"`
-	Slug        string    `json:"slug" gorm:"uniqueIndex;not null"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	ParentID    *uint       `json:"parent_id" gorm:"index"`
+	Name        string      `json:"name" gorm:"not null"`
+	Slug        string      `json:"slug" gorm:"uniqueIndex;not null"`
+	Description string      `json:"description"`
+	Children    []*Category `json:"children,omitempty" gorm:"-"` // Filled in when building the tree
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+type CreateCategoryRequest struct {
+	ParentID    *uint  `json:"parent_id"`
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug" binding:"required"`
+	Description string `json:"description"`
+}
+
+// UpdateCategoryRequest changes a category. A ParentID of 0 moves the
+// category to the top level.
+type UpdateCategoryRequest struct {
+	ParentID    *uint  `json:"parent_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
 }
 
 type Product struct {
-	ID             uint            `json:"id" gorm:"primaryKey"`
-	CategoryID     *uint           `json:"category_id"`
-	Category       *Category       `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
-	Name           string          `json:"name" gorm:"not null"`
-	Slug           string          `json:"slug" gorm:"uniqueIndex;not null"`
-	Description    string          `json:"description"`
-	Price          float64         `json:"price" gorm:"not null"`
-	ComparePrice   *float64        `json:"compare_price,omitempty"`
-	CostPrice      *float64        `json:"cost_price,omitempty"`
-	SKU            string          `json:"sku" gorm:"uniqueIndex"`
-	Barcode        string          `json:"barcode"`
-	StockQuantity  int             `json:"stock_quantity" gorm:"not null;default:0"`
-	TrackInventory bool            `json:"track_inventory" gorm:"not null;default:true"`
-	Weight         *float64        `json:"weight,omitempty"`
-	IsActive       bool            `json:"is_active" gorm:"not null;default:true"`
-	Featured       bool            `json:"featured" gorm:"not null;default:false"`
-	Images         []ProductImage  `json:"images,omitempty" gorm:"foreignKey:ProductID"`
-	CreatedAt      time.Time       `json:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	CategoryID        *uint          `json:"category_id"`
+	Category          *Category      `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Name              string         `json:"name" gorm:"not null"`
+	Slug              string         `json:"slug" gorm:"uniqueIndex;not null"`
+	Description       string         `json:"description"`
+	Price             float64        `json:"price" gorm:"not null"`
+	ComparePrice      *float64       `json:"compare_price,omitempty"`
+	CostPrice         *float64       `json:"cost_price,omitempty"`
+	SKU               string         `json:"sku" gorm:"uniqueIndex"`
+	Barcode           string         `json:"barcode"`
+	StockQuantity     int            `json:"stock_quantity" gorm:"not null;default:0"`
+	LowStockThreshold *int           `json:"low_stock_threshold"` // Overrides the configured threshold when set
+	TrackInventory    bool           `json:"track_inventory" gorm:"not null;default:true"`
+	AllowBackorder    bool           `json:"allow_backorder" gorm:"not null;default:false"`
+	Weight            *float64       `json:"weight,omitempty"`
+	IsActive          bool           `json:"is_active" gorm:"not null;default:true"`
+	Featured          bool           `json:"featured" gorm:"not null;default:false"`
+	Images            []ProductImage `json:"images,omitempty" gorm:"foreignKey:ProductID"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// EffectiveLowStockThreshold returns the product's own low stock threshold,
+// or defaultThreshold when it has none
+func (p *Product) EffectiveLowStockThreshold(defaultThreshold int) int {
+	if p.LowStockThreshold != nil {
+		return *p.LowStockThreshold
+	}
+	return defaultThreshold
+}
+
+// CanFulfill reports whether quantity units can be sold right now. Products
+// that are not tracked or that accept backorders can always be sold.
+func (p *Product) CanFulfill(quantity int) bool {
+	return !p.TrackInventory || p.AllowBackorder || p.StockQuantity >= quantity
 }
 
 type ProductImage struct {
@@ -43,47 +78,124 @@ type ProductImage struct {
 }
 
 type CreateProductRequest struct {
-	CategoryID     *uint    `json:"category_id"`
-	Name           string   `json:"name" binding:"required"`
-	Slug           string   `json:"slug" binding:"required"`
-	Description    string   `json:"description"`
-	Price          float64  `json:"price" binding:"required,gt=0"`
-	ComparePrice   *float64 `json:"compare_price"`
-	CostPrice      *float64 `json:"cost_price"`
-	SKU            string   `json:"sku"`
-	Barcode        string   `json:"barcode"`
-	StockQuantity  int      `json:"stock_quantity" binding:"gte=0"`
-	TrackInventory bool     `json:"track_inventory"`
-	Weight         *float64 `json:"weight"`
-	IsActive       bool     `json:"is_active"`
-	Featured       bool     `json:"featured"`
+	CategoryID        *uint    `json:"category_id"`
+	Name              string   `json:"name" binding:"required"`
+	Slug              string   `json:"slug" binding:"required"`
+	Description       string   `json:"description"`
+	Price             float64  `json:"price" binding:"required,gt=0"`
+	ComparePrice      *float64 `json:"compare_price"`
+	CostPrice         *float64 `json:"cost_price"`
+	SKU               string   `json:"sku"`
+	Barcode           string   `json:"barcode"`
+	StockQuantity     int      `json:"stock_quantity" binding:"gte=0"`
+	LowStockThreshold *int     `json:"low_stock_threshold" binding:"omitempty,gte=0"`
+	TrackInventory    bool     `json:"track_inventory"`
+	AllowBackorder    bool     `json:"allow_backorder"`
+	Weight            *float64 `json:"weight"`
+	IsActive          bool     `json:"is_active"`
+	Featured          bool     `json:"featured"`
 }
 
 type UpdateProductRequest struct {
-	CategoryID     *uint    `json:"category_id"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Price          *float64 `json:"price" binding:"omitempty,gt=0"`
-	ComparePrice   *float64 `json:"compare_price"`
-	CostPrice      *float64 `json:"cost_price"`
-	SKU            string   `json:"sku"`
-	Barcode        string   `json:"barcode"`
-	StockQuantity  *int     `json:"stock_quantity" binding:"omitempty,gte=0"`
-	TrackInventory *bool    `json:"track_inventory"`
-	Weight         *float64 `json:"weight"`
-	IsActive       *bool    `json:"is_active"`
-	Featured       *bool    `json:"featured"`
+	CategoryID        *uint    `json:"category_id"`
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Price             *float64 `json:"price" binding:"omitempty,gt=0"`
+	ComparePrice      *float64 `json:"compare_price"`
+	CostPrice         *float64 `json:"cost_price"`
+	SKU               string   `json:"sku"`
+	Barcode           string   `json:"barcode"`
+	StockQuantity     *int     `json:"stock_quantity" binding:"omitempty,gte=0"`
+	LowStockThreshold *int     `json:"low_stock_threshold" binding:"omitempty,gte=0"`
+	TrackInventory    *bool    `json:"track_inventory"`
+	AllowBackorder    *bool    `json:"allow_backorder"`
+	Weight            *float64 `json:"weight"`
+	IsActive          *bool    `json:"is_active"`
+	Featured          *bool    `json:"featured"`
 }
 
 type ProductListQuery struct {
-	Page       int     `form:"page" binding:"omitempty,gte=1"`
-	Limit      int     `form:"limit" binding:"omitempty,gte=1,lte=100"`
-	CategoryID *uint   `form:"category_id"`
-	Search     string  `form:"search"`
-	MinPrice   *float64 `form:"min_price" binding:"omitempty,gte=0"`
-	MaxPrice   *float64 `form:"max_price" binding:"omitempty,gte=0"`
-	IsActive   *bool   `form:"is_active"`
-	Featured   *bool   `form:"featured"`
-	SortBy     string  `form:"sort_by"`
-	SortOrder  string  `form:"sort_order"`
+	Page                 int      `form:"page" binding:"omitempty,gte=1"`
+	Limit                int      `form:"limit" binding:"omitempty,gte=1,lte=100"`
+	Cursor               string   `form:"cursor"` // Replaces page when set; see Cursor
+	CategoryID           *uint    `form:"category_id"`
+	IncludeSubcategories bool     `form:"include_subcategories"`
+	Search               string   `form:"search"`
+	MinPrice             *float64 `form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice             *float64 `form:"max_price" binding:"omitempty,gte=0"`
+	IsActive             *bool    `form:"is_active"`
+	Featured             *bool    `form:"featured"`
+	SortBy               string   `form:"sort_by"`
+	SortOrder            string   `form:"sort_order"`
+}
+
+// CursorSortable reports whether results are ordered by created_at, the only
+// order cursors can continue.
+func (q *ProductListQuery) CursorSortable() bool {
+	return q.SortBy == "" || q.SortBy == "created_at"
+}
+
+// NextCursor returns the cursor for the page after products, or "" when there
+// is none.
+func (q *ProductListQuery) NextCursor(products []*Product) string {
+	if !q.CursorSortable() || len(products) == 0 {
+		return ""
+	}
+	last := products[len(products)-1]
+	return nextCursor(len(products), q.Limit, last.CreatedAt, last.ID)
+}
+
+// ProductAvailability describes how much of a product can be sold. OnHand and
+// Reserved are only filled in for admins; see Public.
+type ProductAvailability struct {
+	ProductID      uint `json:"product_id"`
+	TrackInventory bool `json:"track_inventory"`
+	AllowBackorder bool `json:"allow_backorder"`
+	Available      int  `json:"available"`
+	InStock        bool `json:"in_stock"`
+	OnHand         *int `json:"on_hand,omitempty"`
+	Reserved       *int `json:"reserved,omitempty"`
+}
+
+// Public returns a copy without the admin-only stock detail.
+func (a *ProductAvailability) Public() *ProductAvailability {
+	public := *a
+	public.OnHand = nil
+	public.Reserved = nil
+	return &public
+}
+
+// LowStockItem describes a tracked product at or below the low stock threshold
+type LowStockItem struct {
+	ProductID     uint   `json:"product_id"`
+	Name          string `json:"name"`
+	SKU           string `json:"sku"`
+	StockQuantity int    `json:"stock_quantity"`
+	Threshold     int    `json:"threshold"`
+}
+
+type ImportRowStatus string
+
+const (
+	ImportRowCreated ImportRowStatus = "created"
+	ImportRowUpdated ImportRowStatus = "updated"
+	ImportRowFailed  ImportRowStatus = "failed"
+)
+
+// ImportRowResult reports the outcome of a single CSV row. Row is the 1-based
+// line number in the uploaded file, so the header is row 1.
+type ImportRowResult struct {
+	Row       int             `json:"row"`
+	SKU       string          `json:"sku,omitempty"`
+	Status    ImportRowStatus `json:"status"`
+	ProductID uint            `json:"product_id,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
 }
@@ -15,6 +15,7 @@ type CartRepository interface {
 	RemoveItem(itemID uint) error
 	ClearCart(userID uint) error
 	GetCartWithItems(userID uint) (*domain.Cart, error)
+	CountItems(userID uint) (*domain.CartCount, error)
 }
 
 type cartRepository struct {
@@ -47,8 +48,10 @@ func (r *cartRepository) AddItem(item *domain.CartItem) error {
 	err := r.db.Where("cart_id = ? AND product_id = ?", item.CartID, item.ProductID).First(&existingItem).Error
 
 	if err == nil {
-		// Item exists, update quantity
+		// Item exists, update quantity and take the current price, so the
+		// whole line is charged at what the customer just saw
 		existingItem.Quantity += item.Quantity
+		existingItem.Price = item.Price
 		return r.db.Save(&existingItem).Error
 	}
 
@@ -93,3 +96,18 @@ func (r *cartRepository) GetCartWithItems(userID uint) (*domain.Cart, error) {
 	}
 	return &cart, nil
 }
+
+// CountItems sums the quantities and line totals of the user's cart in a
+// single aggregate query. A user without a cart gets zeros.
+func (r *cartRepository) CountItems(userID uint) (*domain.CartCount, error) {
+	var count domain.CartCount
+	err := r.db.Model(&domain.CartItem{}).
+		Select("COALESCE(SUM(cart_items.quantity), 0) AS items_count, COALESCE(SUM(cart_items.quantity * cart_items.price), 0) AS subtotal").
+		Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Where("carts.user_id = ?", userID).
+		Scan(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type CategoryRepository interface {
+	Create(category *domain.Category) error
+	FindByID(id uint) (*domain.Category, error)
+	Update(category *domain.Category) error
+	FindAll() ([]*domain.Category, error)
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) Create(category *domain.Category) error {
+	return r.db.Create(category).Error
+}
+
+func (r *categoryRepository) FindByID(id uint) (*domain.Category, error) {
+	var category domain.Category
+	err := r.db.First(&category, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("category not found")
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) Update(category *domain.Category) error {
+	return r.db.Save(category).Error
+}
+
+// FindAll returns every category, ordered by name, in a single query.
+func (r *categoryRepository) FindAll() ([]*domain.Category, error) {
+	var categories []*domain.Category
+	err := r.db.Order("name ASC, id ASC").Find(&categories).Error
+	return categories, err
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+// applyCursor restricts db to rows after cursor in (created_at, id) order,
+// descending unless asc is set. The id tie-break keeps rows that share a
+// timestamp from being skipped or repeated between pages.
+func applyCursor(db *gorm.DB, cursor string, asc bool) (*gorm.DB, error) {
+	c, err := domain.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if asc {
+		return db.Where("created_at > ? OR (created_at = ? AND id > ?)", c.CreatedAt, c.CreatedAt, c.ID), nil
+	}
+	return db.Where("created_at < ? OR (created_at = ? AND id < ?)", c.CreatedAt, c.CreatedAt, c.ID), nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type GuestCartRepository interface {
+	FindByToken(token string) (*domain.GuestCart, error)
+	Create(cart *domain.GuestCart) error
+	AddItem(item *domain.GuestCartItem) error
+	Delete(cartID uint) error
+}
+
+type guestCartRepository struct {
+	db *gorm.DB
+}
+
+func NewGuestCartRepository(db *gorm.DB) GuestCartRepository {
+	return &guestCartRepository{db: db}
+}
+
+func (r *guestCartRepository) FindByToken(token string) (*domain.GuestCart, error) {
+	var cart domain.GuestCart
+	err := r.db.Preload("Items.Product.Images").Where("token = ?", token).First(&cart).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("guest cart not found")
+		}
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *guestCartRepository) Create(cart *domain.GuestCart) error {
+	return r.db.Create(cart).Error
+}
+
+func (r *guestCartRepository) AddItem(item *domain.GuestCartItem) error {
+	// Adding a product already in the cart raises its quantity
+	var existingItem domain.GuestCartItem
+	err := r.db.Where("guest_cart_id = ? AND product_id = ?", item.GuestCartID, item.ProductID).First(&existingItem).Error
+
+	if err == nil {
+		existingItem.Quantity += item.Quantity
+		return r.db.Save(&existingItem).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.Create(item).Error
+	}
+
+	return err
+}
+
+// Delete removes the guest cart and its items
+func (r *guestCartRepository) Delete(cartID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("guest_cart_id = ?", cartID).Delete(&domain.GuestCartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.GuestCart{}, cartID).Error
+	})
+}
@@ -1,26 +1,38 @@
 package repository
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
 	Create(order *domain.Order) error
 	FindByID(id uint) (*domain.Order, error)
+	// FindByIDForUpdate is FindByID that also locks the order row until the
+	// surrounding transaction ends
+	FindByIDForUpdate(id uint) (*domain.Order, error)
 	FindByOrderNumber(orderNumber string) (*domain.Order, error)
-	FindByUserID(userID uint, page, limit int) ([]*domain.Order, int64, error)
 	Update(order *domain.Order) error
 	UpdateStatus(orderID uint, status domain.OrderStatus) error
+	RemoveItem(order *domain.Order, itemID uint) error
 	UpdatePaymentStatus(orderID uint, status domain.PaymentStatus) error
+	RecordRefund(order *domain.Order, previousRefunded float64) error
+	AddStatusHistory(entry *domain.OrderStatusHistory) error
 	List(query *domain.OrderListQuery) ([]*domain.Order, int64, error)
 	GenerateOrderNumber() (string, error)
 }
 
+// ErrOrderChanged is returned by RecordRefund when another refund was recorded
+// for the order in the meantime.
+var ErrOrderChanged = errors.New("order was changed concurrently")
+
 type orderRepository struct {
 	db *gorm.DB
 }
@@ -35,7 +47,7 @@ func (r *orderRepository) Create(order *domain.Order) error {
 
 func (r *orderRepository) FindByID(id uint) (*domain.Order, error) {
 	var order domain.Order
-	err := r.db.Preload("User").Preload("Items").First(&order, id).Error
+	err := r.db.Preload("User").Preload("Items").Preload("StatusHistory", orderStatusHistoryOrder).First(&order, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
@@ -45,9 +57,10 @@ func (r *orderRepository) FindByID(id uint) (*domain.Order, error) {
 	return &order, nil
 }
 
-func (r *orderRepository) FindByOrderNumber(orderNumber string) (*domain.Order, error) {
+func (r *orderRepository) FindByIDForUpdate(id uint) (*domain.Order, error) {
 	var order domain.Order
-	err := r.db.Preload("User").Preload("Items").Where("order_number = ?", orderNumber).First(&order).Error
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("User").Preload("Items").Preload("StatusHistory", orderStatusHistoryOrder).First(&order, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("order not found")
@@ -57,32 +70,16 @@ func (r *orderRepository) FindByOrderNumber(orderNumber string) (*domain.Order,
 	return &order, nil
 }
 
-func (r *orderRepository) FindByUserID(userID uint, page, limit int) ([]*domain.Order, int64, error) {
-	var orders []*domain.Order
-	var total int64
-
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 20
-	}
-
-	offset := (page - 1) * limit
-
-	db := r.db.Model(&domain.Order{}).Where("user_id = ?", userID)
-
-	if err := db.Count(&total).Error; err != nil {
-		return nil, 0, err
+func (r *orderRepository) FindByOrderNumber(orderNumber string) (*domain.Order, error) {
+	var order domain.Order
+	err := r.db.Preload("User").Preload("Items").Preload("StatusHistory", orderStatusHistoryOrder).Where("order_number = ?", orderNumber).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("order not found")
+		}
+		return nil, err
 	}
-
-	err := db.Preload("Items").
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limit).
-		Find(&orders).Error
-
-	return orders, total, err
+	return &order, nil
 }
 
 func (r *orderRepository) Update(order *domain.Order) error {
@@ -96,6 +93,29 @@ func (r *orderRepository) UpdateStatus(orderID uint, status domain.OrderStatus)
 		Error
 }
 
+// RemoveItem deletes an item from the order and saves the order's totals,
+// which the caller has already recomputed, in one transaction.
+func (r *orderRepository) RemoveItem(order *domain.Order, itemID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND order_id = ?", itemID, order.ID).Delete(&domain.OrderItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("order item not found")
+		}
+
+		return tx.Model(&domain.Order{}).
+			Where("id = ?", order.ID).
+			Updates(map[string]interface{}{
+				"subtotal": order.Subtotal,
+				"tax":      order.Tax,
+				"shipping": order.Shipping,
+				"total":    order.Total,
+			}).Error
+	})
+}
+
 func (r *orderRepository) UpdatePaymentStatus(orderID uint, status domain.PaymentStatus) error {
 	return r.db.Model(&domain.Order{}).
 		Where("id = ?", orderID).
@@ -103,6 +123,35 @@ func (r *orderRepository) UpdatePaymentStatus(orderID uint, status domain.Paymen
 		Error
 }
 
+// RecordRefund saves the order's refunded amount, payment status and status.
+// It only applies while the refunded amount is still previousRefunded, so
+// concurrent refunds cannot both be recorded.
+func (r *orderRepository) RecordRefund(order *domain.Order, previousRefunded float64) error {
+	result := r.db.Model(&domain.Order{}).
+		Where("id = ? AND refunded_amount = ?", order.ID, previousRefunded).
+		Updates(map[string]interface{}{
+			"refunded_amount": order.RefundedAmount,
+			"payment_status":  order.PaymentStatus,
+			"status":          order.Status,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderChanged
+	}
+	return nil
+}
+
+func (r *orderRepository) AddStatusHistory(entry *domain.OrderStatusHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// orderStatusHistoryOrder preloads an order's status history oldest first
+func orderStatusHistoryOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC, id ASC")
+}
+
 func (r *orderRepository) List(query *domain.OrderListQuery) ([]*domain.Order, int64, error) {
 	var orders []*domain.Order
 	var total int64
@@ -135,15 +184,33 @@ func (r *orderRepository) List(query *domain.OrderListQuery) ([]*domain.Order, i
 		db = db.Where("order_number = ?", query.OrderNumber)
 	}
 
+	// Both ends of the range are whole days
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+
+	if query.CreatedTo != nil {
+		db = db.Where("created_at < ?", query.CreatedTo.AddDate(0, 0, 1))
+	}
+
 	// Count total
 	if err := db.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// A cursor continues after the last row seen instead of skipping rows
+	if query.Cursor != "" {
+		var err error
+		if db, err = applyCursor(db, query.Cursor, false); err != nil {
+			return nil, 0, err
+		}
+		offset = 0
+	}
+
 	// Fetch results
 	err := db.Preload("User").
 		Preload("Items").
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Offset(offset).
 		Limit(query.Limit).
 		Find(&orders).Error
@@ -152,19 +219,32 @@ func (r *orderRepository) List(query *domain.OrderListQuery) ([]*domain.Order, i
 }
 
 func (r *orderRepository) GenerateOrderNumber() (string, error) {
-	// Format: ORD-YYYYMMDD-XXXXXX (e.g., ORD-20231114-000001)
+	// Format: ORD-YYYYMMDD-XXXXXX (e.g., ORD-20231114-482913)
+	//
+	// The suffix is random rather than a per-day sequence: counting existing
+	// orders races under concurrent checkouts and hands out duplicate numbers.
 	now := time.Now()
 	prefix := fmt.Sprintf("ORD-%s", now.Format("20060102"))
 
-	var count int64
-	err := r.db.Model(&domain.Order{}).
-		Where("order_number LIKE ?", prefix+"%").
-		Count(&count).Error
+	for attempt := 0; attempt < 5; attempt++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", err
+		}
 
-	if err != nil {
-		return "", err
+		orderNumber := fmt.Sprintf("%s-%06d", prefix, n.Int64())
+
+		var count int64
+		if err := r.db.Model(&domain.Order{}).
+			Where("order_number = ?", orderNumber).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return orderNumber, nil
+		}
 	}
 
-	orderNumber := fmt.Sprintf("%s-%06d", prefix, count+1)
-	return orderNumber, nil
+	return "", errors.New("failed to generate unique order number")
 }
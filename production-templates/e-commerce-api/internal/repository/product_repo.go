@@ -2,19 +2,43 @@ package repository
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"gorm.io/gorm"
 )
 
+// ErrInsufficientStock is returned by ReserveStock when the product does not
+// have enough stock left to satisfy the requested quantity.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+var ErrProductNotFound = errors.New("product not found")
+
+// categorySubtreeSQL selects the IDs of a category and all its descendants.
+// UNION rather than UNION ALL stops the recursion even if a cycle slipped in.
+const categorySubtreeSQL = `WITH RECURSIVE subtree(id) AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION
+	SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+) SELECT id FROM subtree`
+
 type ProductRepository interface {
 	Create(product *domain.Product) error
+	CreateBatch(products []*domain.Product) error
 	FindByID(id uint) (*domain.Product, error)
 	FindBySlug(slug string) (*domain.Product, error)
+	FindBySKU(sku string) (*domain.Product, error)
+	FindCategoryBySlug(slug string) (*domain.Category, error)
+	FindExistingCategoryIDs(ids []uint) ([]uint, error)
+	FindTakenSKUs(skus []string) ([]string, error)
+	FindTakenSlugs(slugs []string) ([]string, error)
 	Update(product *domain.Product) error
 	Delete(id uint) error
 	List(query *domain.ProductListQuery) ([]*domain.Product, int64, error)
-	DecrementStock(productID uint, quantity int) error
+	ListLowStock(defaultThreshold int) ([]*domain.Product, error)
+	FindRelated(productID uint, limit int) ([]*domain.Product, error)
+	GetReservedQuantity(productID uint) (int, error)
+	ReserveStock(productID uint, quantity int) error
 	IncrementStock(productID uint, quantity int) error
 }
 
@@ -30,12 +54,20 @@ func (r *productRepository) Create(product *domain.Product) error {
 	return r.db.Create(product).Error
 }
 
+// CreateBatch inserts all products in one transaction, so either every
+// product is created or none is.
+func (r *productRepository) CreateBatch(products []*domain.Product) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(products, 100).Error
+	})
+}
+
 func (r *productRepository) FindByID(id uint) (*domain.Product, error) {
 	var product domain.Product
 	err := r.db.Preload("Category").Preload("Images").First(&product, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("product not found")
+			return nil, ErrProductNotFound
 		}
 		return nil, err
 	}
@@ -54,6 +86,60 @@ func (r *productRepository) FindBySlug(slug string) (*domain.Product, error) {
 	return &product, nil
 }
 
+func (r *productRepository) FindBySKU(sku string) (*domain.Product, error) {
+	var product domain.Product
+	err := r.db.Where("sku = ?", sku).First(&product).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("product not found")
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *productRepository) FindCategoryBySlug(slug string) (*domain.Category, error) {
+	var category domain.Category
+	err := r.db.Where("slug = ?", slug).First(&category).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("category not found")
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// FindExistingCategoryIDs returns which of ids belong to a category.
+func (r *productRepository) FindExistingCategoryIDs(ids []uint) ([]uint, error) {
+	var existing []uint
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&domain.Category{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	return existing, err
+}
+
+// FindTakenSKUs returns which of skus are already used by a product.
+func (r *productRepository) FindTakenSKUs(skus []string) ([]string, error) {
+	var taken []string
+	if len(skus) == 0 {
+		return taken, nil
+	}
+	err := r.db.Model(&domain.Product{}).Where("sku IN ?", skus).Pluck("sku", &taken).Error
+	return taken, err
+}
+
+// FindTakenSlugs returns which of slugs are already used by a product.
+func (r *productRepository) FindTakenSlugs(slugs []string) ([]string, error) {
+	var taken []string
+	if len(slugs) == 0 {
+		return taken, nil
+	}
+	err := r.db.Model(&domain.Product{}).Where("slug IN ?", slugs).Pluck("slug", &taken).Error
+	return taken, err
+}
+
 func (r *productRepository) Update(product *domain.Product) error {
 	return r.db.Save(product).Error
 }
@@ -81,7 +167,11 @@ func (r *productRepository) List(query *domain.ProductListQuery) ([]*domain.Prod
 
 	// Apply filters
 	if query.CategoryID != nil {
-		db = db.Where("category_id = ?", *query.CategoryID)
+		if query.IncludeSubcategories {
+			db = db.Where("category_id IN (?)", r.db.Raw(categorySubtreeSQL, *query.CategoryID))
+		} else {
+			db = db.Where("category_id = ?", *query.CategoryID)
+		}
 	}
 
 	if query.Search != "" {
@@ -121,10 +211,28 @@ func (r *productRepository) List(query *domain.ProductListQuery) ([]*domain.Prod
 		sortOrder = "ASC"
 	}
 
+	order := sortBy + " " + sortOrder
+	if query.CursorSortable() {
+		order += ", id " + sortOrder
+	}
+
+	// A cursor continues after the last row seen instead of skipping rows
+	if query.Cursor != "" {
+		if !query.CursorSortable() {
+			return nil, 0, fmt.Errorf("%w: cursor pagination requires sorting by created_at", domain.ErrInvalidCursor)
+		}
+
+		var err error
+		if db, err = applyCursor(db, query.Cursor, sortOrder == "ASC"); err != nil {
+			return nil, 0, err
+		}
+		offset = 0
+	}
+
 	// Fetch results
 	err := db.Preload("Category").
 		Preload("Images").
-		Order(sortBy + " " + sortOrder).
+		Order(order).
 		Offset(offset).
 		Limit(query.Limit).
 		Find(&products).Error
@@ -132,11 +240,74 @@ func (r *productRepository) List(query *domain.ProductListQuery) ([]*domain.Prod
 	return products, total, err
 }
 
-func (r *productRepository) DecrementStock(productID uint, quantity int) error {
-	return r.db.Model(&domain.Product{}).
-		Where("id = ? AND stock_quantity >= ?", productID, quantity).
-		Update("stock_quantity", gorm.Expr("stock_quantity - ?", quantity)).
-		Error
+// ListLowStock returns tracked products at or below their low stock
+// threshold, lowest stock first. Products without a threshold of their own
+// use defaultThreshold.
+func (r *productRepository) ListLowStock(defaultThreshold int) ([]*domain.Product, error) {
+	var products []*domain.Product
+	err := r.db.Where("track_inventory = ? AND stock_quantity <= COALESCE(low_stock_threshold, ?)", true, defaultThreshold).
+		Order("stock_quantity ASC, id ASC").
+		Find(&products).Error
+	return products, err
+}
+
+// FindRelated returns up to limit active products from the same category as
+// productID, featured first and then newest. Products without a category get
+// featured products instead.
+func (r *productRepository) FindRelated(productID uint, limit int) ([]*domain.Product, error) {
+	var product domain.Product
+	if err := r.db.Select("id", "category_id").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("product not found")
+		}
+		return nil, err
+	}
+
+	db := r.db.Where("id <> ? AND is_active = ?", productID, true)
+	if product.CategoryID != nil {
+		db = db.Where("category_id = ?", *product.CategoryID)
+	} else {
+		db = db.Where("featured = ?", true)
+	}
+
+	var products []*domain.Product
+	err := db.Preload("Images").
+		Order("featured DESC, created_at DESC, id DESC").
+		Limit(limit).
+		Find(&products).Error
+
+	return products, err
+}
+
+// GetReservedQuantity sums the units of productID held by orders that have
+// taken stock but not shipped yet. Cancelling such an order returns its units
+// to stock.
+func (r *productRepository) GetReservedQuantity(productID uint) (int, error) {
+	var reserved int
+	err := r.db.Model(&domain.OrderItem{}).
+		Select("COALESCE(SUM(order_items.quantity), 0)").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND orders.status IN ?", productID,
+			[]domain.OrderStatus{domain.OrderStatusPending, domain.OrderStatusProcessing}).
+		Scan(&reserved).Error
+	return reserved, err
+}
+
+// ReserveStock atomically takes quantity units out of stock. The availability
+// check and the decrement happen in a single conditional UPDATE, so concurrent
+// checkouts can never drive stock below zero unless the product accepts
+// backorders.
+func (r *productRepository) ReserveStock(productID uint, quantity int) error {
+	result := r.db.Model(&domain.Product{}).
+		Where("id = ? AND (stock_quantity >= ? OR allow_backorder = ?)", productID, quantity, true).
+		Update("stock_quantity", gorm.Expr("stock_quantity - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
 }
 
 func (r *productRepository) IncrementStock(productID uint, quantity int) error {
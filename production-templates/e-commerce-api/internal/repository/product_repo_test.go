@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/modsynth/e-commerce-api/internal/domain"
+)
+
+func TestProductRepository_ReserveStock(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&domain.Category{}, &domain.Product{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	repo := NewProductRepository(db)
+
+	product := &domain.Product{Name: "Mug", Slug: "mug", SKU: "MUG-1", Price: 12, StockQuantity: 5}
+	if err := repo.Create(product); err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+
+	if err := repo.ReserveStock(product.ID, 3); err != nil {
+		t.Fatalf("ReserveStock() error = %v", err)
+	}
+
+	if err := repo.ReserveStock(product.ID, 3); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("ReserveStock() error = %v, want ErrInsufficientStock", err)
+	}
+
+	var reloaded domain.Product
+	db.First(&reloaded, product.ID)
+	if reloaded.StockQuantity != 2 {
+		t.Errorf("StockQuantity = %d, want 2", reloaded.StockQuantity)
+	}
+}
+
+func TestProductRepository_ListCursor(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&domain.Category{}, &domain.Product{}, &domain.ProductImage{}); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	repo := NewProductRepository(db)
+
+	// Pairs of products share a timestamp so the id tie-break is exercised
+	base := time.Now().Add(-time.Hour)
+	var want []uint
+	for i := 0; i < 5; i++ {
+		product := &domain.Product{
+			Name:      fmt.Sprintf("Product %d", i),
+			Slug:      fmt.Sprintf("product-%d", i),
+			SKU:       fmt.Sprintf("SKU-%d", i),
+			Price:     10,
+			CreatedAt: base.Add(time.Duration(i/2) * time.Minute),
+		}
+		if err := repo.Create(product); err != nil {
+			t.Fatalf("failed to create product: %v", err)
+		}
+		want = append([]uint{product.ID}, want...)
+	}
+
+	var got []uint
+	query := &domain.ProductListQuery{Limit: 2}
+	for page := 0; page < 5; page++ {
+		products, total, err := repo.List(query)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if page == 0 && total != 5 {
+			t.Errorf("List() total = %d, want 5", total)
+		}
+		for _, product := range products {
+			got = append(got, product.ID)
+		}
+
+		// A product added mid-listing is newer than the cursor and never shows up
+		if page == 0 {
+			late := &domain.Product{Name: "Late", Slug: "late", SKU: "LATE", Price: 10}
+			if err := repo.Create(late); err != nil {
+				t.Fatalf("failed to create product: %v", err)
+			}
+		}
+
+		next := query.NextCursor(products)
+		if next == "" {
+			break
+		}
+		query = &domain.ProductListQuery{Limit: 2, Cursor: next}
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("paged IDs = %v, want %v", got, want)
+	}
+
+	if _, _, err := repo.List(&domain.ProductListQuery{Cursor: "not-a-cursor"}); !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("List() with a bad cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/modsynth/e-commerce-api/internal/config"
 	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/jwtkeys"
 	"github.com/modsynth/e-commerce-api/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -21,12 +22,14 @@ type AuthService interface {
 type authService struct {
 	userRepo repository.UserRepository
 	config   *config.Config
+	keys     *jwtkeys.KeySet
 }
 
 func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) AuthService {
 	return &authService{
 		userRepo: userRepo,
 		config:   cfg,
+		keys:     cfg.JWT.KeySet(),
 	}
 }
 
@@ -157,8 +160,7 @@ func (s *authService) generateAccessToken(user *domain.User) (string, error) {
 		"iat":     time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	return s.keys.Sign(claims)
 }
 
 func (s *authService) generateRefreshToken(user *domain.User) (string, error) {
@@ -169,17 +171,11 @@ func (s *authService) generateRefreshToken(user *domain.User) (string, error) {
 		"iat":     time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	return s.keys.Sign(claims)
 }
 
 func (s *authService) validateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(s.config.JWT.Secret), nil
-	})
+	token, err := jwt.Parse(tokenString, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package service
 import (
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/modsynth/e-commerce-api/internal/config"
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"github.com/modsynth/e-commerce-api/internal/repository"
@@ -239,6 +240,12 @@ func TestAuthService_Login_InactiveUser(t *testing.T) {
 		t.Fatalf("failed to create test user: %v", err)
 	}
 
+	// is_active has a database default of true, so the zero value is not
+	// persisted on insert; set it explicitly.
+	if err := db.Model(inactiveUser).Update("is_active", false).Error; err != nil {
+		t.Fatalf("failed to deactivate test user: %v", err)
+	}
+
 	req := &domain.LoginRequest{
 		Email:    "inactive@example.com",
 		Password: password,
@@ -259,7 +266,7 @@ func TestAuthService_RefreshToken(t *testing.T) {
 	db := setupTestDB(t)
 	cfg := setupTestConfig()
 	userRepo := repository.NewUserRepository(db)
-	authService := NewAuthService(userRepo, cfg)
+	svc := NewAuthService(userRepo, cfg)
 
 	// Create test user
 	testUser := &domain.User{
@@ -276,7 +283,7 @@ func TestAuthService_RefreshToken(t *testing.T) {
 	}
 
 	// Generate valid refresh token
-	refreshToken, err := authService.(*authService).generateRefreshToken(testUser)
+	refreshToken, err := svc.(*authService).generateRefreshToken(testUser)
 	if err != nil {
 		t.Fatalf("failed to generate refresh token: %v", err)
 	}
@@ -300,7 +307,7 @@ func TestAuthService_RefreshToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			resp, err := authService.RefreshToken(tt.token)
+			resp, err := svc.RefreshToken(tt.token)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RefreshToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -324,6 +331,60 @@ func TestAuthService_RefreshToken(t *testing.T) {
 	}
 }
 
+func TestAuthService_RefreshToken_KeyRotation(t *testing.T) {
+	db := setupTestDB(t)
+	userRepo := repository.NewUserRepository(db)
+
+	testUser := &domain.User{
+		Email:        "rotation@example.com",
+		PasswordHash: "hashed_password",
+		FirstName:    "Rotation",
+		LastName:     "Test",
+		Role:         domain.RoleCustomer,
+		IsActive:     true,
+	}
+	if err := userRepo.Create(testUser); err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+
+	withKeys := func(keyID, secret string, previous map[string]string) AuthService {
+		cfg := setupTestConfig()
+		cfg.JWT.KeyID = keyID
+		cfg.JWT.Secret = secret
+		cfg.JWT.PreviousKeys = previous
+		return NewAuthService(userRepo, cfg)
+	}
+
+	oldToken, err := withKeys("v1", "old-secret", nil).(*authService).generateRefreshToken(testUser)
+	if err != nil {
+		t.Fatalf("failed to generate refresh token: %v", err)
+	}
+
+	t.Run("old key still listed", func(t *testing.T) {
+		svc := withKeys("v2", "new-secret", map[string]string{"v1": "old-secret"})
+		resp, err := svc.RefreshToken(oldToken)
+		if err != nil {
+			t.Fatalf("RefreshToken() error = %v, want token of a listed key accepted", err)
+		}
+
+		// New tokens are signed with the current key
+		token, _, err := jwt.NewParser().ParseUnverified(resp.AccessToken, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("failed to parse access token: %v", err)
+		}
+		if kid := token.Header["kid"]; kid != "v2" {
+			t.Errorf("access token kid = %v, want v2", kid)
+		}
+	})
+
+	t.Run("old key retired", func(t *testing.T) {
+		svc := withKeys("v2", "new-secret", nil)
+		if _, err := svc.RefreshToken(oldToken); err == nil {
+			t.Error("RefreshToken() succeeded with a token of a retired key, want error")
+		}
+	})
+}
+
 func TestAuthService_GetUserByID(t *testing.T) {
 	db := setupTestDB(t)
 	cfg := setupTestConfig()
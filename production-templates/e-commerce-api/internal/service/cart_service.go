@@ -1,29 +1,50 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"math"
 
+	"github.com/modsynth/e-commerce-api/internal/config"
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"github.com/modsynth/e-commerce-api/internal/repository"
 )
 
 type CartService interface {
 	GetCart(userID uint) (*domain.CartWithSummary, error)
+	GetCartCount(userID uint) (*domain.CartCount, error)
 	AddToCart(userID uint, req *domain.AddToCartRequest) error
 	UpdateCartItem(userID, itemID uint, req *domain.UpdateCartItemRequest) error
 	RemoveFromCart(userID, itemID uint) error
 	ClearCart(userID uint) error
+	Validate(userID uint) ([]domain.CartValidationIssue, error)
+	RefreshPrices(userID uint) ([]domain.CartPriceChange, error)
+
+	// Guest carts
+	GetGuestCart(token string) (*domain.GuestCartWithSummary, error)
+	AddToGuestCart(token string, req *domain.AddToCartRequest) (string, error)
+	MergeGuestCart(userID uint, guestToken string) (*domain.CartMergeResult, error)
 }
 
 type cartService struct {
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
+	cartRepo      repository.CartRepository
+	guestCartRepo repository.GuestCartRepository
+	productRepo   repository.ProductRepository
+	config        *config.Config
 }
 
-func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository) CartService {
+func NewCartService(
+	cartRepo repository.CartRepository,
+	guestCartRepo repository.GuestCartRepository,
+	productRepo repository.ProductRepository,
+	config *config.Config,
+) CartService {
 	return &cartService{
-		cartRepo:    cartRepo,
-		productRepo: productRepo,
+		cartRepo:      cartRepo,
+		guestCartRepo: guestCartRepo,
+		productRepo:   productRepo,
+		config:        config,
 	}
 }
 
@@ -37,9 +58,10 @@ func (s *cartService) GetCart(userID uint) (*domain.CartWithSummary, error) {
 	subtotal := 0.0
 	itemsCount := 0
 
-	for _, item := range cart.Items {
+	for i, item := range cart.Items {
 		subtotal += item.Price * float64(item.Quantity)
 		itemsCount += item.Quantity
+		cart.Items[i].PriceChanged = item.Product != nil && pricesDiffer(item.Price, item.Product.Price)
 	}
 
 	return &domain.CartWithSummary{
@@ -49,6 +71,10 @@ func (s *cartService) GetCart(userID uint) (*domain.CartWithSummary, error) {
 	}, nil
 }
 
+func (s *cartService) GetCartCount(userID uint) (*domain.CartCount, error) {
+	return s.cartRepo.CountItems(userID)
+}
+
 func (s *cartService) AddToCart(userID uint, req *domain.AddToCartRequest) error {
 	// Get or create cart
 	cart, err := s.cartRepo.GetCartWithItems(userID)
@@ -56,18 +82,9 @@ func (s *cartService) AddToCart(userID uint, req *domain.AddToCartRequest) error
 		return err
 	}
 
-	// Check if product exists and has enough stock
-	product, err := s.productRepo.FindByID(req.ProductID)
+	product, err := s.availableProduct(req)
 	if err != nil {
-		return errors.New("product not found")
-	}
-
-	if !product.IsActive {
-		return errors.New("product is not available")
-	}
-
-	if product.TrackInventory && product.StockQuantity < req.Quantity {
-		return errors.New("insufficient stock")
+		return err
 	}
 
 	// Add item to cart
@@ -107,12 +124,14 @@ func (s *cartService) UpdateCartItem(userID, itemID uint, req *domain.UpdateCart
 		return errors.New("product not found")
 	}
 
-	if product.TrackInventory && product.StockQuantity < req.Quantity {
+	if !product.CanFulfill(req.Quantity) {
 		return errors.New("insufficient stock")
 	}
 
-	// Update quantity
+	// Update quantity. Changing an item also accepts the product's current
+	// price, which clears a price_changed issue.
 	cartItem.Quantity = req.Quantity
+	cartItem.Price = product.Price
 
 	return s.cartRepo.UpdateItem(cartItem)
 }
@@ -143,3 +162,257 @@ func (s *cartService) RemoveFromCart(userID, itemID uint) error {
 func (s *cartService) ClearCart(userID uint) error {
 	return s.cartRepo.ClearCart(userID)
 }
+
+// Validate checks every item of the user's cart against its product as it is
+// now and returns the issues to fix before checkout, in cart order. An item
+// can have both a stock and a price issue. Deactivated products are only an
+// issue when active products are required.
+func (s *cartService) Validate(userID uint) ([]domain.CartValidationIssue, error) {
+	cart, err := s.cartRepo.GetCartWithItems(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]domain.CartValidationIssue, 0)
+	for _, item := range cart.Items {
+		issue := domain.CartValidationIssue{
+			CartItemID: item.ID,
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+			CartPrice:  item.Price,
+		}
+
+		product, err := s.productRepo.FindByID(item.ProductID)
+		if errors.Is(err, repository.ErrProductNotFound) {
+			issue.Type = domain.CartIssueProductNotFound
+			issues = append(issues, issue)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		issue.ProductName = product.Name
+
+		if !product.IsActive && s.config.Order.RequireActiveProducts {
+			issue.Type = domain.CartIssueProductInactive
+			issues = append(issues, issue)
+			continue
+		}
+
+		if !product.CanFulfill(item.Quantity) {
+			outOfStock := issue
+			outOfStock.Type = domain.CartIssueOutOfStock
+			outOfStock.Available = max(product.StockQuantity, 0)
+			issues = append(issues, outOfStock)
+		}
+
+		if pricesDiffer(item.Price, product.Price) {
+			priceChanged := issue
+			priceChanged.Type = domain.CartIssuePriceChanged
+			priceChanged.CurrentPrice = product.Price
+			issues = append(issues, priceChanged)
+		}
+	}
+
+	return issues, nil
+}
+
+// RefreshPrices brings the price of every cart item up to date with its
+// product and returns the items whose price changed, in cart order
+func (s *cartService) RefreshPrices(userID uint) ([]domain.CartPriceChange, error) {
+	cart, err := s.cartRepo.GetCartWithItems(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]domain.CartPriceChange, 0)
+	for i, item := range cart.Items {
+		// Items whose product is gone are left for Validate to report
+		if item.Product == nil || !pricesDiffer(item.Price, item.Product.Price) {
+			continue
+		}
+
+		changes = append(changes, domain.CartPriceChange{
+			CartItemID:  item.ID,
+			ProductID:   item.ProductID,
+			ProductName: item.Product.Name,
+			OldPrice:    item.Price,
+			NewPrice:    item.Product.Price,
+		})
+
+		cart.Items[i].Price = item.Product.Price
+		if err := s.cartRepo.UpdateItem(&cart.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// GetGuestCart returns the guest cart for token. A shopper without one yet
+// gets an empty cart.
+func (s *cartService) GetGuestCart(token string) (*domain.GuestCartWithSummary, error) {
+	cart := &domain.GuestCart{}
+	if token != "" {
+		if found, err := s.guestCartRepo.FindByToken(token); err == nil {
+			cart = found
+		}
+	}
+
+	subtotal := 0.0
+	itemsCount := 0
+
+	for _, item := range cart.Items {
+		subtotal += item.Price * float64(item.Quantity)
+		itemsCount += item.Quantity
+	}
+
+	return &domain.GuestCartWithSummary{
+		GuestCart:  cart,
+		Subtotal:   subtotal,
+		ItemsCount: itemsCount,
+	}, nil
+}
+
+// AddToGuestCart adds an item to the guest cart for token, starting a new cart
+// when the token is empty or unknown. It returns the token of the cart the
+// item went into.
+func (s *cartService) AddToGuestCart(token string, req *domain.AddToCartRequest) (string, error) {
+	product, err := s.availableProduct(req)
+	if err != nil {
+		return "", err
+	}
+
+	var cart *domain.GuestCart
+	if token != "" {
+		cart, _ = s.guestCartRepo.FindByToken(token)
+	}
+	if cart == nil {
+		token, err = newGuestCartToken()
+		if err != nil {
+			return "", err
+		}
+		cart = &domain.GuestCart{Token: token}
+		if err := s.guestCartRepo.Create(cart); err != nil {
+			return "", err
+		}
+	}
+
+	item := &domain.GuestCartItem{
+		GuestCartID: cart.ID,
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		Price:       product.Price,
+	}
+	if err := s.guestCartRepo.AddItem(item); err != nil {
+		return "", err
+	}
+
+	return cart.Token, nil
+}
+
+// MergeGuestCart moves the guest cart's items into the user's cart and deletes
+// the guest cart. Quantities of products already in the user's cart are
+// summed, and prices are taken from the product as it is now. Items whose
+// product is no longer for sale are dropped and quantities beyond the stock
+// left are cut; both are reported as adjustments.
+func (s *cartService) MergeGuestCart(userID uint, guestToken string) (*domain.CartMergeResult, error) {
+	guestCart, err := s.guestCartRepo.FindByToken(guestToken)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartRepo.GetCartWithItems(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inCart := make(map[uint]int, len(cart.Items))
+	for _, item := range cart.Items {
+		inCart[item.ProductID] = item.Quantity
+	}
+
+	var adjustments []domain.CartMergeAdjustment
+	for _, item := range guestCart.Items {
+		product, err := s.productRepo.FindByID(item.ProductID)
+		if err != nil || !product.IsActive {
+			adjustments = append(adjustments, domain.CartMergeAdjustment{
+				ProductID: item.ProductID,
+				Requested: item.Quantity,
+				Reason:    "product is not available",
+			})
+			continue
+		}
+
+		quantity := item.Quantity
+		if !product.CanFulfill(inCart[item.ProductID] + quantity) {
+			// Only tracked products without backorders get here
+			quantity = max(product.StockQuantity-inCart[item.ProductID], 0)
+			adjustments = append(adjustments, domain.CartMergeAdjustment{
+				ProductID: item.ProductID,
+				Requested: item.Quantity,
+				Added:     quantity,
+				Reason:    "insufficient stock",
+			})
+			if quantity == 0 {
+				continue
+			}
+		}
+
+		cartItem := &domain.CartItem{
+			CartID:    cart.ID,
+			ProductID: item.ProductID,
+			Quantity:  quantity,
+			Price:     product.Price,
+		}
+		if err := s.cartRepo.AddItem(cartItem); err != nil {
+			return nil, err
+		}
+		inCart[item.ProductID] += quantity
+	}
+
+	if err := s.guestCartRepo.Delete(guestCart.ID); err != nil {
+		return nil, err
+	}
+
+	merged, err := s.GetCart(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CartMergeResult{
+		Cart:        merged,
+		Adjustments: adjustments,
+	}, nil
+}
+
+// availableProduct checks that the requested product is for sale and in stock
+func (s *cartService) availableProduct(req *domain.AddToCartRequest) (*domain.Product, error) {
+	product, err := s.productRepo.FindByID(req.ProductID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	if !product.IsActive {
+		return nil, errors.New("product is not available")
+	}
+
+	if !product.CanFulfill(req.Quantity) {
+		return nil, errors.New("insufficient stock")
+	}
+
+	return product, nil
+}
+
+// pricesDiffer compares two prices in cents
+func pricesDiffer(a, b float64) bool {
+	return math.Round(a*100) != math.Round(b*100)
+}
+
+func newGuestCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/modsynth/e-commerce-api/internal/config"
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/repository"
+)
+
+func TestCartService_GetCartCount(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cartService := NewCartService(repository.NewCartRepository(db), repository.NewGuestCartRepository(db), repository.NewProductRepository(db), &config.Config{})
+
+	mug := createTestProduct(t, db, "mug", 12.5, 10)
+	pen := createTestProduct(t, db, "pen", 2.0, 10)
+	user := createTestCart(t, db, "badge@example.com", mug, 2)
+
+	expectCount := func(wantItems int, wantSubtotal float64) {
+		t.Helper()
+
+		count, err := cartService.GetCartCount(user.ID)
+		if err != nil {
+			t.Fatalf("GetCartCount() error = %v", err)
+		}
+		if count.ItemsCount != wantItems || count.Subtotal != wantSubtotal {
+			t.Errorf("GetCartCount() = %+v, want %d items, subtotal %.2f", count, wantItems, wantSubtotal)
+		}
+
+		cart, err := cartService.GetCart(user.ID)
+		if err != nil {
+			t.Fatalf("GetCart() error = %v", err)
+		}
+		if cart.ItemsCount != count.ItemsCount || cart.Subtotal != count.Subtotal {
+			t.Errorf("GetCartCount() = %+v, disagrees with GetCart() %d items, subtotal %.2f", count, cart.ItemsCount, cart.Subtotal)
+		}
+	}
+
+	expectCount(2, 25)
+
+	if err := cartService.AddToCart(user.ID, &domain.AddToCartRequest{ProductID: pen.ID, Quantity: 3}); err != nil {
+		t.Fatalf("AddToCart() error = %v", err)
+	}
+	expectCount(5, 31)
+
+	cart, _ := cartService.GetCart(user.ID)
+	for _, item := range cart.Items {
+		if item.ProductID == mug.ID {
+			if err := cartService.RemoveFromCart(user.ID, item.ID); err != nil {
+				t.Fatalf("RemoveFromCart() error = %v", err)
+			}
+		}
+	}
+	expectCount(3, 6)
+
+	// A user who never had a cart has nothing in it
+	if count, err := cartService.GetCartCount(user.ID + 100); err != nil || count.ItemsCount != 0 {
+		t.Errorf("GetCartCount() for a user without a cart = %+v, %v, want zero", count, err)
+	}
+}
+
+func TestCartService_MergeGuestCart(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cartService := NewCartService(repository.NewCartRepository(db), repository.NewGuestCartRepository(db), repository.NewProductRepository(db), &config.Config{})
+
+	mug := createTestProduct(t, db, "mug", 12.5, 10)
+	pen := createTestProduct(t, db, "pen", 2.0, 10)
+	lamp := createTestProduct(t, db, "lamp", 40.0, 3)
+	user := createTestCart(t, db, "merge@example.com", mug, 2)
+
+	// Guest items overlap with the user's mug and add a pen
+	token, err := cartService.AddToGuestCart("", &domain.AddToCartRequest{ProductID: mug.ID, Quantity: 3})
+	if err != nil {
+		t.Fatalf("AddToGuestCart() error = %v", err)
+	}
+	if token == "" {
+		t.Fatal("AddToGuestCart() should start a guest cart")
+	}
+	if again, err := cartService.AddToGuestCart(token, &domain.AddToCartRequest{ProductID: pen.ID, Quantity: 4}); err != nil || again != token {
+		t.Fatalf("AddToGuestCart() = %q, %v, want the same cart", again, err)
+	}
+
+	guest, err := cartService.GetGuestCart(token)
+	if err != nil {
+		t.Fatalf("GetGuestCart() error = %v", err)
+	}
+	if guest.ItemsCount != 7 || guest.Subtotal != 45.5 {
+		t.Errorf("GetGuestCart() = %d items, subtotal %.2f, want 7 items, 45.50", guest.ItemsCount, guest.Subtotal)
+	}
+
+	result, err := cartService.MergeGuestCart(user.ID, token)
+	if err != nil {
+		t.Fatalf("MergeGuestCart() error = %v", err)
+	}
+	if len(result.Adjustments) != 0 {
+		t.Errorf("Adjustments = %+v, want none", result.Adjustments)
+	}
+
+	quantities := make(map[uint]int)
+	for _, item := range result.Cart.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	if len(quantities) != 2 || quantities[mug.ID] != 5 || quantities[pen.ID] != 4 {
+		t.Errorf("merged quantities = %v, want 5 mugs and 4 pens", quantities)
+	}
+	if result.Cart.ItemsCount != 9 || result.Cart.Subtotal != 70.5 {
+		t.Errorf("merged cart = %d items, subtotal %.2f, want 9 items, 70.50", result.Cart.ItemsCount, result.Cart.Subtotal)
+	}
+
+	// The guest cart is gone and cannot be merged twice
+	if _, err := cartService.MergeGuestCart(user.ID, token); err == nil {
+		t.Error("MergeGuestCart() of an already merged cart should fail")
+	}
+	if guest, _ := cartService.GetGuestCart(token); guest.ItemsCount != 0 {
+		t.Errorf("guest cart still holds %d items after merging", guest.ItemsCount)
+	}
+
+	// Stock is checked again against the combined quantity
+	token, _ = cartService.AddToGuestCart("", &domain.AddToCartRequest{ProductID: mug.ID, Quantity: 8})
+	cartService.AddToGuestCart(token, &domain.AddToCartRequest{ProductID: lamp.ID, Quantity: 2})
+	db.Model(lamp).Update("is_active", false)
+
+	result, err = cartService.MergeGuestCart(user.ID, token)
+	if err != nil {
+		t.Fatalf("MergeGuestCart() error = %v", err)
+	}
+	if len(result.Adjustments) != 2 {
+		t.Fatalf("Adjustments = %+v, want the mug and the lamp", result.Adjustments)
+	}
+	for _, adjustment := range result.Adjustments {
+		switch adjustment.ProductID {
+		case mug.ID:
+			if adjustment.Requested != 8 || adjustment.Added != 5 {
+				t.Errorf("mug adjustment = %+v, want 5 of 8 added", adjustment)
+			}
+		case lamp.ID:
+			if adjustment.Added != 0 {
+				t.Errorf("lamp adjustment = %+v, want nothing added", adjustment)
+			}
+		}
+	}
+	for _, item := range result.Cart.Items {
+		if item.ProductID == mug.ID && item.Quantity != mug.StockQuantity {
+			t.Errorf("mug quantity = %d, want the %d in stock", item.Quantity, mug.StockQuantity)
+		}
+		if item.ProductID == lamp.ID {
+			t.Error("inactive lamp should not be merged")
+		}
+	}
+}
+
+func TestCartService_Validate(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cfg := &config.Config{Order: config.OrderConfig{RequireActiveProducts: true}}
+	cartService := NewCartService(repository.NewCartRepository(db), repository.NewGuestCartRepository(db), repository.NewProductRepository(db), cfg)
+	orderService := setupOrderService(db, cfg)
+
+	fine := createTestProduct(t, db, "fine", 10.0, 10)
+	scarce := createTestProduct(t, db, "scarce", 5.0, 10)
+	repriced := createTestProduct(t, db, "repriced", 8.0, 10)
+	retired := createTestProduct(t, db, "retired", 3.0, 10)
+	vanished := createTestProduct(t, db, "vanished", 4.0, 10)
+	user := createTestCart(t, db, "validate@example.com", fine, 1)
+	for _, product := range []*domain.Product{scarce, repriced, retired, vanished} {
+		if err := cartService.AddToCart(user.ID, &domain.AddToCartRequest{ProductID: product.ID, Quantity: 3}); err != nil {
+			t.Fatalf("AddToCart(%s) error = %v", product.Name, err)
+		}
+	}
+
+	if issues, err := cartService.Validate(user.ID); err != nil || len(issues) != 0 {
+		t.Fatalf("Validate() = %+v, %v, want no issues", issues, err)
+	}
+
+	// Everything that can go wrong after the items were added
+	db.Model(scarce).Update("stock_quantity", 2)
+	db.Model(repriced).Update("price", 9.5)
+	db.Model(retired).Update("is_active", false)
+	db.Delete(vanished)
+
+	issues, err := cartService.Validate(user.ID)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := map[uint]domain.CartIssueType{
+		scarce.ID:   domain.CartIssueOutOfStock,
+		repriced.ID: domain.CartIssuePriceChanged,
+		retired.ID:  domain.CartIssueProductInactive,
+		vanished.ID: domain.CartIssueProductNotFound,
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Validate() = %+v, want one issue per broken item", issues)
+	}
+	for _, issue := range issues {
+		if issue.Type != want[issue.ProductID] {
+			t.Errorf("product %d issue = %s, want %s", issue.ProductID, issue.Type, want[issue.ProductID])
+		}
+		switch issue.Type {
+		case domain.CartIssueOutOfStock:
+			if issue.Quantity != 3 || issue.Available != 2 {
+				t.Errorf("out of stock issue = %+v, want 3 requested, 2 available", issue)
+			}
+		case domain.CartIssuePriceChanged:
+			if issue.CartPrice != 8.0 || issue.CurrentPrice != 9.5 {
+				t.Errorf("price issue = %+v, want 8.00 in the cart, 9.50 now", issue)
+			}
+		}
+	}
+
+	// Checkout reports the same issues and reserves nothing
+	_, err = orderService.CreateOrder(user.ID, testOrderRequest())
+	var validationErr *domain.CartValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != len(issues) {
+		t.Fatalf("CreateOrder() error = %v, want the %d validation issues", err, len(issues))
+	}
+	var reloaded domain.Product
+	db.First(&reloaded, fine.ID)
+	if reloaded.StockQuantity != 10 {
+		t.Errorf("stock = %d after a rejected checkout, want 10", reloaded.StockQuantity)
+	}
+
+	// Fixing every item lets the order through
+	cart, _ := cartService.GetCart(user.ID)
+	for _, item := range cart.Items {
+		switch item.ProductID {
+		case scarce.ID, repriced.ID:
+			// Updating the quantity also accepts the current price
+			if err := cartService.UpdateCartItem(user.ID, item.ID, &domain.UpdateCartItemRequest{Quantity: 2}); err != nil {
+				t.Fatalf("UpdateCartItem() error = %v", err)
+			}
+		case retired.ID, vanished.ID:
+			if err := cartService.RemoveFromCart(user.ID, item.ID); err != nil {
+				t.Fatalf("RemoveFromCart() error = %v", err)
+			}
+		}
+	}
+	if issues, _ := cartService.Validate(user.ID); len(issues) != 0 {
+		t.Errorf("Validate() after fixing = %+v, want no issues", issues)
+	}
+	if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err != nil {
+		t.Errorf("CreateOrder() after fixing error = %v", err)
+	}
+}
+
+func TestCartService_RefreshPrices(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cartService := NewCartService(repository.NewCartRepository(db), repository.NewGuestCartRepository(db), repository.NewProductRepository(db), &config.Config{})
+
+	steady := createTestProduct(t, db, "steady", 10.0, 10)
+	pricier := createTestProduct(t, db, "pricier", 8.0, 10)
+	user := createTestCart(t, db, "refresh@example.com", steady, 1)
+	if err := cartService.AddToCart(user.ID, &domain.AddToCartRequest{ProductID: pricier.ID, Quantity: 2}); err != nil {
+		t.Fatalf("AddToCart() error = %v", err)
+	}
+
+	// The price goes up after the item was added
+	db.Model(pricier).Update("price", 9.5)
+
+	cart, err := cartService.GetCart(user.ID)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+	for _, item := range cart.Items {
+		if item.PriceChanged != (item.ProductID == pricier.ID) {
+			t.Errorf("product %d PriceChanged = %v, want it set only for the repriced product", item.ProductID, item.PriceChanged)
+		}
+	}
+	if cart.Subtotal != 26 {
+		t.Errorf("GetCart() subtotal = %.2f, want 26.00 at the prices in the cart", cart.Subtotal)
+	}
+
+	changes, err := cartService.RefreshPrices(user.ID)
+	if err != nil {
+		t.Fatalf("RefreshPrices() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].ProductID != pricier.ID || changes[0].OldPrice != 8.0 || changes[0].NewPrice != 9.5 {
+		t.Fatalf("RefreshPrices() = %+v, want pricier from 8.00 to 9.50", changes)
+	}
+
+	cart, _ = cartService.GetCart(user.ID)
+	for _, item := range cart.Items {
+		if item.PriceChanged {
+			t.Errorf("product %d PriceChanged after refreshing, want none", item.ProductID)
+		}
+	}
+	if cart.Subtotal != 29 {
+		t.Errorf("GetCart() subtotal after refreshing = %.2f, want 29.00", cart.Subtotal)
+	}
+
+	if changes, err := cartService.RefreshPrices(user.ID); err != nil || len(changes) != 0 {
+		t.Errorf("RefreshPrices() again = %+v, %v, want no changes", changes, err)
+	}
+}
+
+func TestCartService_AddToCart_TakesCurrentPrice(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cartService := NewCartService(repository.NewCartRepository(db), repository.NewGuestCartRepository(db), repository.NewProductRepository(db), &config.Config{})
+
+	product := createTestProduct(t, db, "repriced", 8.0, 10)
+	user := createTestCart(t, db, "readd@example.com", product, 1)
+
+	// Adding more after the price went up moves the whole line to it
+	db.Model(product).Update("price", 9.5)
+	if err := cartService.AddToCart(user.ID, &domain.AddToCartRequest{ProductID: product.ID, Quantity: 2}); err != nil {
+		t.Fatalf("AddToCart() error = %v", err)
+	}
+
+	cart, err := cartService.GetCart(user.ID)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 3 || cart.Items[0].Price != 9.5 || cart.Items[0].PriceChanged {
+		t.Fatalf("GetCart() items = %+v, want one line of 3 at 9.50", cart.Items)
+	}
+	if cart.Subtotal != 28.5 {
+		t.Errorf("GetCart() subtotal = %.2f, want 28.50", cart.Subtotal)
+	}
+}
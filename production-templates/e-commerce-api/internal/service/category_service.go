@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/repository"
+)
+
+type CategoryService interface {
+	CreateCategory(req *domain.CreateCategoryRequest) (*domain.Category, error)
+	UpdateCategory(id uint, req *domain.UpdateCategoryRequest) (*domain.Category, error)
+	GetCategoryTree() ([]*domain.Category, error)
+}
+
+type categoryService struct {
+	categoryRepo repository.CategoryRepository
+}
+
+func NewCategoryService(categoryRepo repository.CategoryRepository) CategoryService {
+	return &categoryService{
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (s *categoryService) CreateCategory(req *domain.CreateCategoryRequest) (*domain.Category, error) {
+	category := &domain.Category{
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+	}
+
+	if req.ParentID != nil {
+		if _, err := s.categoryRepo.FindByID(*req.ParentID); err != nil {
+			return nil, errors.New("parent category not found")
+		}
+		category.ParentID = req.ParentID
+	}
+
+	if err := s.categoryRepo.Create(category); err != nil {
+		return nil, errors.New("failed to create category")
+	}
+
+	return category, nil
+}
+
+func (s *categoryService) UpdateCategory(id uint, req *domain.UpdateCategoryRequest) (*domain.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		category.Name = req.Name
+	}
+	if req.Slug != "" {
+		category.Slug = req.Slug
+	}
+	if req.Description != "" {
+		category.Description = req.Description
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if err := s.checkParent(id, *req.ParentID); err != nil {
+				return nil, err
+			}
+			category.ParentID = req.ParentID
+		}
+	}
+
+	if err := s.categoryRepo.Update(category); err != nil {
+		return nil, errors.New("failed to update category")
+	}
+
+	return category, nil
+}
+
+// checkParent rejects a parent that would make the category its own ancestor.
+func (s *categoryService) checkParent(categoryID, parentID uint) error {
+	if parentID == categoryID {
+		return errors.New("a category cannot be its own parent")
+	}
+
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return errors.New("failed to load categories")
+	}
+
+	parents := make(map[uint]*uint, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+
+	if _, ok := parents[parentID]; !ok {
+		return errors.New("parent category not found")
+	}
+
+	// Walk up from the new parent; reaching the category means a cycle. The
+	// visited set guards against cycles already present in the data.
+	visited := make(map[uint]bool)
+	for current := &parentID; current != nil && !visited[*current]; current = parents[*current] {
+		if *current == categoryID {
+			return errors.New("invalid parent: a category cannot be moved under one of its descendants")
+		}
+		visited[*current] = true
+	}
+
+	return nil
+}
+
+// GetCategoryTree returns the top-level categories with their descendants
+// nested under Children.
+func (s *categoryService) GetCategoryTree() ([]*domain.Category, error) {
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, errors.New("failed to load categories")
+	}
+
+	byID := make(map[uint]*domain.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	roots := make([]*domain.Category, 0)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+
+	return roots, nil
+}
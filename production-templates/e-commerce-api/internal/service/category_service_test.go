@@ -0,0 +1,119 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/modsynth/e-commerce-api/internal/config"
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/repository"
+)
+
+func TestCategoryService_Tree(t *testing.T) {
+	db := setupOrderTestDB(t)
+	categoryService := NewCategoryService(repository.NewCategoryRepository(db))
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	create := func(name string, parent *domain.Category) *domain.Category {
+		req := &domain.CreateCategoryRequest{Name: name, Slug: name}
+		if parent != nil {
+			req.ParentID = &parent.ID
+		}
+		category, err := categoryService.CreateCategory(req)
+		if err != nil {
+			t.Fatalf("CreateCategory(%s) error = %v", name, err)
+		}
+		return category
+	}
+
+	clothing := create("clothing", nil)
+	shoes := create("shoes", clothing)
+	boots := create("boots", shoes)
+	books := create("books", nil)
+
+	tree, err := categoryService.GetCategoryTree()
+	if err != nil {
+		t.Fatalf("GetCategoryTree() error = %v", err)
+	}
+	if len(tree) != 2 || tree[0].ID != books.ID || tree[1].ID != clothing.ID {
+		t.Fatalf("GetCategoryTree() roots = %+v, want books and clothing", tree)
+	}
+	if len(tree[1].Children) != 1 || tree[1].Children[0].ID != shoes.ID ||
+		len(tree[1].Children[0].Children) != 1 || tree[1].Children[0].Children[0].ID != boots.ID {
+		t.Errorf("clothing subtree is not clothing > shoes > boots")
+	}
+
+	for _, c := range []struct {
+		name     string
+		category *domain.Category
+	}{{"coat", clothing}, {"sneaker", shoes}, {"wellington", boots}, {"novel", books}} {
+		product := createTestProduct(t, db, c.name, 10.0, 1)
+		db.Model(product).Update("category_id", c.category.ID)
+	}
+
+	tests := []struct {
+		name     string
+		category *domain.Category
+		include  bool
+		want     int64
+	}{
+		{name: "category only", category: clothing, include: false, want: 1},
+		{name: "with subcategories", category: clothing, include: true, want: 3},
+		{name: "leaf with subcategories", category: boots, include: true, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, total, err := productService.ListProducts(&domain.ProductListQuery{
+				CategoryID:           &tt.category.ID,
+				IncludeSubcategories: tt.include,
+			})
+			if err != nil {
+				t.Fatalf("ListProducts() error = %v", err)
+			}
+			if total != tt.want {
+				t.Errorf("ListProducts() total = %d, want %d", total, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryService_UpdateCategory_RejectsCycles(t *testing.T) {
+	db := setupOrderTestDB(t)
+	categoryService := NewCategoryService(repository.NewCategoryRepository(db))
+
+	root, _ := categoryService.CreateCategory(&domain.CreateCategoryRequest{Name: "root", Slug: "root"})
+	child, _ := categoryService.CreateCategory(&domain.CreateCategoryRequest{Name: "child", Slug: "child", ParentID: &root.ID})
+	grandchild, _ := categoryService.CreateCategory(&domain.CreateCategoryRequest{Name: "grandchild", Slug: "grandchild", ParentID: &child.ID})
+	other, _ := categoryService.CreateCategory(&domain.CreateCategoryRequest{Name: "other", Slug: "other"})
+
+	var missing uint = 9999
+	var topLevel uint
+
+	tests := []struct {
+		name     string
+		category *domain.Category
+		parentID *uint
+		wantErr  bool
+	}{
+		{name: "own parent", category: root, parentID: &root.ID, wantErr: true},
+		{name: "under own grandchild", category: root, parentID: &grandchild.ID, wantErr: true},
+		{name: "under own child", category: child, parentID: &grandchild.ID, wantErr: true},
+		{name: "unknown parent", category: other, parentID: &missing, wantErr: true},
+		{name: "under unrelated category", category: root, parentID: &other.ID, wantErr: false},
+		{name: "back to top level", category: root, parentID: &topLevel, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := categoryService.UpdateCategory(tt.category.ID, &domain.UpdateCategoryRequest{ParentID: tt.parentID})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateCategory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var reloaded domain.Category
+	db.First(&reloaded, root.ID)
+	if reloaded.ParentID != nil {
+		t.Errorf("root ParentID = %d, want nil", *reloaded.ParentID)
+	}
+}
@@ -2,9 +2,14 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"math"
 
+	"github.com/modsynth/e-commerce-api/internal/config"
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"github.com/modsynth/e-commerce-api/internal/repository"
+	"github.com/modsynth/e-commerce-api/internal/websocket"
 	"gorm.io/gorm"
 )
 
@@ -12,11 +17,44 @@ type OrderService interface {
 	CreateOrder(userID uint, req *domain.CreateOrderRequest) (*domain.Order, error)
 	GetOrderByID(userID, orderID uint) (*domain.Order, error)
 	GetOrderByOrderNumber(userID uint, orderNumber string) (*domain.Order, error)
-	GetUserOrders(userID uint, page, limit int) ([]*domain.Order, int64, error)
+	GetUserOrders(userID uint, query *domain.OrderListQuery) ([]*domain.Order, int64, error)
 	CancelOrder(userID, orderID uint) error
+	CancelItem(userID, orderID, orderItemID uint) (*domain.Order, error)
 	// Admin methods
 	GetAllOrders(query *domain.OrderListQuery) ([]*domain.Order, int64, error)
 	UpdateOrderStatus(orderID uint, status domain.OrderStatus) error
+	RefundOrder(orderID, adminID uint, amount *float64) (*domain.Order, error)
+}
+
+// LowStockNotifier is sent a LOW_STOCK event when a checkout brings a
+// product's stock down to its low stock threshold. It is called after the
+// order is committed, once per product and order.
+type LowStockNotifier interface {
+	NotifyLowStock(item domain.LowStockItem)
+}
+
+// LogLowStockNotifier writes low stock events to the log. It is the default
+// notifier.
+type LogLowStockNotifier struct{}
+
+func (LogLowStockNotifier) NotifyLowStock(item domain.LowStockItem) {
+	log.Printf("LOW_STOCK: product %d (%s, SKU %s) has %d left, threshold %d",
+		item.ProductID, item.Name, item.SKU, item.StockQuantity, item.Threshold)
+}
+
+// PaymentGateway moves money through the payment provider
+type PaymentGateway interface {
+	// Refund returns amount of the order's payment to the customer
+	Refund(order *domain.Order, amount float64) error
+}
+
+// ManualPaymentGateway is the default gateway while no payment provider is
+// integrated. It only logs refunds, which then have to be paid out by hand.
+type ManualPaymentGateway struct{}
+
+func (ManualPaymentGateway) Refund(order *domain.Order, amount float64) error {
+	log.Printf("REFUND: order %s needs %.2f %s refunded manually", order.OrderNumber, amount, order.Currency)
+	return nil
 }
 
 type orderService struct {
@@ -24,29 +62,58 @@ type orderService struct {
 	orderRepo   repository.OrderRepository
 	cartRepo    repository.CartRepository
 	productRepo repository.ProductRepository
+	cartService CartService
+	hub         *websocket.Hub
+	lowStock    LowStockNotifier
+	payments    PaymentGateway
+	config      *config.Config
 }
 
+// NewOrderService creates the order service. A nil lowStock notifier logs
+// low stock events and a nil payments gateway logs refunds for manual payout.
 func NewOrderService(
 	db *gorm.DB,
 	orderRepo repository.OrderRepository,
 	cartRepo repository.CartRepository,
 	productRepo repository.ProductRepository,
+	cartService CartService,
+	hub *websocket.Hub,
+	lowStock LowStockNotifier,
+	payments PaymentGateway,
+	config *config.Config,
 ) OrderService {
+	if lowStock == nil {
+		lowStock = LogLowStockNotifier{}
+	}
+	if payments == nil {
+		payments = ManualPaymentGateway{}
+	}
 	return &orderService{
 		db:          db,
 		orderRepo:   orderRepo,
 		cartRepo:    cartRepo,
 		productRepo: productRepo,
+		cartService: cartService,
+		hub:         hub,
+		lowStock:    lowStock,
+		payments:    payments,
+		config:      config,
 	}
 }
 
 func (s *orderService) CreateOrder(userID uint, req *domain.CreateOrderRequest) (*domain.Order, error) {
 	var order *domain.Order
+	var lowStock []domain.LowStockItem
 
-	// Use transaction
 	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Run the repositories on the transaction so a failed checkout
+		// rolls back every reservation along with the order
+		cartRepo := repository.NewCartRepository(tx)
+		orderRepo := repository.NewOrderRepository(tx)
+		productRepo := repository.NewProductRepository(tx)
+
 		// Get cart with items
-		cart, err := s.cartRepo.GetCartWithItems(userID)
+		cart, err := cartRepo.GetCartWithItems(userID)
 		if err != nil {
 			return err
 		}
@@ -55,50 +122,90 @@ func (s *orderService) CreateOrder(userID uint, req *domain.CreateOrderRequest)
 			return errors.New("cart is empty")
 		}
 
-		// Calculate totals and create order items
-		var orderItems []domain.OrderItem
-		subtotal := 0.0
+		// Report every problem with the cart at once, before any stock is
+		// reserved
+		issues, err := s.cartService.Validate(userID)
+		if err != nil {
+			return err
+		}
+		if len(issues) > 0 {
+			return &domain.CartValidationError{Issues: issues}
+		}
 
-		for _, cartItem := range cart.Items {
-			// Check stock availability
-			product, err := s.productRepo.FindByID(cartItem.ProductID)
+		products := make([]*domain.Product, len(cart.Items))
+		for i, cartItem := range cart.Items {
+			product, err := productRepo.FindByID(cartItem.ProductID)
 			if err != nil {
 				return errors.New("product not found: " + err.Error())
 			}
+			products[i] = product
+		}
 
-			if product.TrackInventory && product.StockQuantity < cartItem.Quantity {
-				return errors.New("insufficient stock for product: " + product.Name)
-			}
+		// Calculate totals and create order items
+		var orderItems []domain.OrderItem
+		subtotal := 0.0
+
+		for i, cartItem := range cart.Items {
+			product := products[i]
 
 			// Create order item
 			itemSubtotal := cartItem.Price * float64(cartItem.Quantity)
-			orderItem := domain.OrderItem{
+			orderItems = append(orderItems, domain.OrderItem{
 				ProductID:   cartItem.ProductID,
 				ProductName: product.Name,
 				ProductSKU:  product.SKU,
 				Quantity:    cartItem.Quantity,
 				Price:       cartItem.Price,
 				Subtotal:    itemSubtotal,
-			}
-
-			orderItems = append(orderItems, orderItem)
+			})
 			subtotal += itemSubtotal
+		}
 
-			// Decrement stock
-			if product.TrackInventory {
-				if err := s.productRepo.DecrementStock(cartItem.ProductID, cartItem.Quantity); err != nil {
-					return errors.New("failed to decrement stock")
+		// Check the total before touching stock, so a rejected order never
+		// reserves anything or reports low stock
+		tax, shipping, total := calculateTotals(subtotal)
+		if err := s.checkOrderTotal(total); err != nil {
+			return err
+		}
+
+		for i, cartItem := range cart.Items {
+			product := products[i]
+			if !product.TrackInventory {
+				continue
+			}
+
+			// Reserve stock. Validate only saw a snapshot; the reservation
+			// itself is what guards against concurrent orders.
+			if err := productRepo.ReserveStock(cartItem.ProductID, cartItem.Quantity); err != nil {
+				if errors.Is(err, repository.ErrInsufficientStock) {
+					return errors.New("insufficient stock for product: " + product.Name)
 				}
+				return errors.New("failed to reserve stock")
 			}
-		}
 
-		// Calculate tax and shipping (simplified)
-		tax := subtotal * 0.1 // 10% tax
-		shipping := 10.0      // Flat shipping rate
-		total := subtotal + tax + shipping
+			// Read the stock back on the transaction: the reservation holds
+			// the row, so this is what the reservation left
+			reserved, err := productRepo.FindByID(cartItem.ProductID)
+			if err != nil {
+				return errors.New("failed to reserve stock")
+			}
+			remaining := reserved.StockQuantity
+
+			// Only the order that crosses the threshold reports it
+			threshold := product.EffectiveLowStockThreshold(s.config.Inventory.LowStockThreshold)
+			if remaining <= threshold && remaining+cartItem.Quantity > threshold {
+				lowStock = append(lowStock, domain.LowStockItem{
+					ProductID:     product.ID,
+					Name:          product.Name,
+					SKU:           product.SKU,
+					StockQuantity: remaining,
+					Threshold:     threshold,
+				})
+			}
+		}
 
 		// Generate order number
-		orderNumber, err := s.orderRepo.GenerateOrderNumber()
+		orderNumber, err := orderRepo.GenerateOrderNumber()
 		if err != nil {
 			return errors.New("failed to generate order number")
 		}
@@ -125,12 +232,12 @@ func (s *orderService) CreateOrder(userID uint, req *domain.CreateOrderRequest)
 			Items:                orderItems,
 		}
 
-		if err := s.orderRepo.Create(order); err != nil {
+		if err := orderRepo.Create(order); err != nil {
 			return errors.New("failed to create order")
 		}
 
 		// Clear cart
-		if err := s.cartRepo.ClearCart(userID); err != nil {
+		if err := cartRepo.ClearCart(userID); err != nil {
 			return errors.New("failed to clear cart")
 		}
 
@@ -141,9 +248,36 @@ func (s *orderService) CreateOrder(userID uint, req *domain.CreateOrderRequest)
 		return nil, err
 	}
 
+	// Only report once the order is committed; failed orders roll back their
+	// reservations
+	for _, item := range lowStock {
+		s.lowStock.NotifyLowStock(item)
+	}
+
 	return order, nil
 }
 
+// calculateTotals derives tax, shipping and the order total from the item
+// subtotal (simplified: 10% tax and a flat shipping rate).
+func calculateTotals(subtotal float64) (tax, shipping, total float64) {
+	tax = subtotal * 0.1
+	shipping = 10.0
+	return tax, shipping, subtotal + tax + shipping
+}
+
+// checkOrderTotal enforces the configured order value bounds. An unset
+// maximum means orders are not capped.
+func (s *orderService) checkOrderTotal(total float64) error {
+	limits := s.config.Order
+	if total < limits.MinTotal {
+		return fmt.Errorf("order total %.2f is below the minimum of %.2f", total, limits.MinTotal)
+	}
+	if limits.MaxTotal > 0 && total > limits.MaxTotal {
+		return fmt.Errorf("order total %.2f exceeds the maximum of %.2f", total, limits.MaxTotal)
+	}
+	return nil
+}
+
 func (s *orderService) GetOrderByID(userID, orderID uint) (*domain.Order, error) {
 	order, err := s.orderRepo.FindByID(orderID)
 	if err != nil {
@@ -172,8 +306,15 @@ func (s *orderService) GetOrderByOrderNumber(userID uint, orderNumber string) (*
 	return order, nil
 }
 
-func (s *orderService) GetUserOrders(userID uint, page, limit int) ([]*domain.Order, int64, error) {
-	return s.orderRepo.FindByUserID(userID, page, limit)
+// GetUserOrders lists the user's own orders; any user_id in the query is
+// replaced by theirs.
+func (s *orderService) GetUserOrders(userID uint, query *domain.OrderListQuery) ([]*domain.Order, int64, error) {
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	query.UserID = &userID
+	return s.orderRepo.List(query)
 }
 
 func (s *orderService) CancelOrder(userID, orderID uint) error {
@@ -193,37 +334,281 @@ func (s *orderService) CancelOrder(userID, orderID uint) error {
 		return errors.New("order cannot be cancelled")
 	}
 
-	// Use transaction
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Restore stock
-		for _, item := range order.Items {
-			product, err := s.productRepo.FindByID(item.ProductID)
-			if err != nil {
-				continue // Product might be deleted
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Run the repositories on the transaction so stock is only restored
+		// if the order is cancelled
+		return cancelOrder(repository.NewOrderRepository(tx), repository.NewProductRepository(tx), order, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifyStatusChanged(order, domain.OrderStatusCancelled)
+	return nil
+}
+
+// cancelOrder returns the order's items to stock and marks it cancelled. The
+// repositories must share a transaction.
+func cancelOrder(orderRepo repository.OrderRepository, productRepo repository.ProductRepository, order *domain.Order, userID uint) error {
+	// Restore stock
+	for _, item := range order.Items {
+		product, err := productRepo.FindByID(item.ProductID)
+		if err != nil {
+			continue // Product might be deleted
+		}
+
+		if product.TrackInventory {
+			if err := productRepo.IncrementStock(item.ProductID, item.Quantity); err != nil {
+				return errors.New("failed to restore stock")
 			}
+		}
+	}
 
-			if product.TrackInventory {
-				if err := s.productRepo.IncrementStock(item.ProductID, item.Quantity); err != nil {
-					return errors.New("failed to restore stock")
-				}
+	// Update order status
+	if err := orderRepo.UpdateStatus(order.ID, domain.OrderStatusCancelled); err != nil {
+		return err
+	}
+
+	return orderRepo.AddStatusHistory(&domain.OrderStatusHistory{
+		OrderID:       order.ID,
+		Status:        domain.OrderStatusCancelled,
+		PaymentStatus: order.PaymentStatus,
+		ChangedBy:     &userID,
+	})
+}
+
+// CancelItem drops one item from a pending or processing order, restores its
+// stock and recomputes the order totals. Cancelling the last remaining item
+// cancels the whole order. Paid orders are refused, since lowering their total
+// would not return any money; they have to be refunded instead.
+func (s *orderService) CancelItem(userID, orderID, orderItemID uint) (*domain.Order, error) {
+	var cancelled *domain.Order
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Run the repositories on the transaction and lock the order, so the
+		// item, the totals and the stock change together and whether this is
+		// the last item is decided from the order as it is now
+		orderRepo := repository.NewOrderRepository(tx)
+		productRepo := repository.NewProductRepository(tx)
+
+		order, err := orderRepo.FindByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+
+		// Verify ownership
+		if order.UserID != userID {
+			return errors.New("order not found")
+		}
+
+		if order.Status != domain.OrderStatusPending && order.Status != domain.OrderStatusProcessing {
+			return errors.New("order cannot be cancelled")
+		}
+		if order.PaymentStatus == domain.PaymentStatusSucceeded || order.PaymentStatus == domain.PaymentStatusPartiallyRefunded {
+			return errors.New("items of a paid order cannot be cancelled, request a refund instead")
+		}
+
+		var item *domain.OrderItem
+		subtotal := 0.0
+		for i := range order.Items {
+			if order.Items[i].ID == orderItemID {
+				item = &order.Items[i]
+				continue
+			}
+			subtotal += order.Items[i].Subtotal
+		}
+		if item == nil {
+			return errors.New("order item not found")
+		}
+
+		if len(order.Items) == 1 {
+			if err := cancelOrder(orderRepo, productRepo, order, userID); err != nil {
+				return err
+			}
+			cancelled = order
+			return nil
+		}
+
+		order.Subtotal = subtotal
+		order.Tax, order.Shipping, order.Total = calculateTotals(subtotal)
+		if err := orderRepo.RemoveItem(order, orderItemID); err != nil {
+			return errors.New("failed to cancel order item")
+		}
+
+		if err := orderRepo.AddStatusHistory(&domain.OrderStatusHistory{
+			OrderID:       orderID,
+			Status:        order.Status,
+			PaymentStatus: order.PaymentStatus,
+			ChangedBy:     &userID,
+		}); err != nil {
+			return errors.New("failed to cancel order item")
+		}
+
+		// Restore stock
+		product, err := productRepo.FindByID(item.ProductID)
+		if err != nil {
+			return nil // Product might be deleted
+		}
+		if product.TrackInventory {
+			if err := productRepo.IncrementStock(item.ProductID, item.Quantity); err != nil {
+				return errors.New("failed to restore stock")
 			}
 		}
 
-		// Update order status
-		return s.orderRepo.UpdateStatus(orderID, domain.OrderStatusCancelled)
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cancelled != nil {
+		s.notifyStatusChanged(cancelled, domain.OrderStatusCancelled)
+	}
+	return s.orderRepo.FindByID(orderID)
 }
 
 func (s *orderService) GetAllOrders(query *domain.OrderListQuery) ([]*domain.Order, int64, error) {
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+
 	return s.orderRepo.List(query)
 }
 
 func (s *orderService) UpdateOrderStatus(orderID uint, status domain.OrderStatus) error {
 	// Verify order exists
-	_, err := s.orderRepo.FindByID(orderID)
+	order, err := s.orderRepo.FindByID(orderID)
 	if err != nil {
 		return err
 	}
 
-	return s.orderRepo.UpdateStatus(orderID, status)
+	if err := s.orderRepo.UpdateStatus(orderID, status); err != nil {
+		return err
+	}
+
+	if status != order.Status {
+		if err := s.orderRepo.AddStatusHistory(&domain.OrderStatusHistory{
+			OrderID:       orderID,
+			Status:        status,
+			PaymentStatus: order.PaymentStatus,
+		}); err != nil {
+			return err
+		}
+		s.notifyStatusChanged(order, status)
+	}
+	return nil
+}
+
+// RefundOrder refunds amount of the order's payment through the payment
+// gateway, or everything not refunded yet when amount is nil. Once the whole
+// payment is refunded the order becomes refunded and its items go back into
+// stock, unless cancelling the order already returned them. The gateway is
+// called last, so a refused refund rolls back everything else.
+func (s *orderService) RefundOrder(orderID, adminID uint, amount *float64) (*domain.Order, error) {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.PaymentStatus != domain.PaymentStatusSucceeded && order.PaymentStatus != domain.PaymentStatusPartiallyRefunded {
+		return nil, errors.New("order has no payment to refund")
+	}
+
+	// Work in cents so repeated partial refunds add up to the total exactly
+	remaining := toCents(order.RemainingRefundable())
+	refundCents := remaining
+	if amount != nil {
+		refundCents = toCents(*amount)
+		if refundCents <= 0 {
+			return nil, errors.New("refund amount must be greater than 0")
+		}
+		if refundCents > remaining {
+			return nil, fmt.Errorf("refund amount exceeds the %.2f left to refund", fromCents(remaining))
+		}
+	}
+	fullyRefunded := refundCents == remaining
+	refundAmount := fromCents(refundCents)
+
+	refunded := *order
+	refunded.RefundedAmount = fromCents(toCents(order.RefundedAmount) + refundCents)
+	refunded.PaymentStatus = domain.PaymentStatusPartiallyRefunded
+	if fullyRefunded {
+		refunded.PaymentStatus = domain.PaymentStatusRefunded
+		refunded.Status = domain.OrderStatusRefunded
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Run the repositories on the transaction so a failed refund leaves
+		// no trace
+		orderRepo := repository.NewOrderRepository(tx)
+		productRepo := repository.NewProductRepository(tx)
+
+		if err := orderRepo.RecordRefund(&refunded, order.RefundedAmount); err != nil {
+			if errors.Is(err, repository.ErrOrderChanged) {
+				return errors.New("order was refunded concurrently, try again")
+			}
+			return errors.New("failed to record refund")
+		}
+
+		if fullyRefunded && order.Status != domain.OrderStatusCancelled {
+			for _, item := range order.Items {
+				product, err := productRepo.FindByID(item.ProductID)
+				if err != nil {
+					continue // Product might be deleted
+				}
+
+				if product.TrackInventory {
+					if err := productRepo.IncrementStock(item.ProductID, item.Quantity); err != nil {
+						return errors.New("failed to restore stock")
+					}
+				}
+			}
+		}
+
+		if err := orderRepo.AddStatusHistory(&domain.OrderStatusHistory{
+			OrderID:       orderID,
+			Status:        refunded.Status,
+			PaymentStatus: refunded.PaymentStatus,
+			Amount:        &refundAmount,
+			ChangedBy:     &adminID,
+		}); err != nil {
+			return errors.New("failed to record refund")
+		}
+
+		if err := s.payments.Refund(order, refundAmount); err != nil {
+			return fmt.Errorf("refund failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if refunded.Status != order.Status {
+		s.notifyStatusChanged(order, refunded.Status)
+	}
+
+	return s.orderRepo.FindByID(orderID)
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// notifyStatusChanged pushes the new status to the order owner's open
+// WebSocket connections, if any.
+func (s *orderService) notifyStatusChanged(order *domain.Order, status domain.OrderStatus) {
+	if s.hub == nil {
+		return
+	}
+
+	s.hub.SendToUser(websocket.NewMessage(websocket.MessageTypeOrderStatusChanged, order.UserID, &domain.OrderStatusEvent{
+		OrderID:        order.ID,
+		OrderNumber:    order.OrderNumber,
+		Status:         status,
+		PreviousStatus: order.Status,
+	}))
 }
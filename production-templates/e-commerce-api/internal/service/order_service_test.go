@@ -0,0 +1,850 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modsynth/e-commerce-api/internal/config"
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupOrderTestDB opens a file-backed database so that concurrent checkouts
+// share one schema; an in-memory database is private to each connection.
+func setupOrderTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "orders.db") + "?_busy_timeout=10000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Category{},
+		&domain.Product{},
+		&domain.ProductImage{},
+		&domain.Cart{},
+		&domain.CartItem{},
+		&domain.GuestCart{},
+		&domain.GuestCartItem{},
+		&domain.Order{},
+		&domain.OrderItem{},
+		&domain.OrderStatusHistory{},
+	); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	return db
+}
+
+func setupOrderService(db *gorm.DB, cfg *config.Config) OrderService {
+	cartRepo := repository.NewCartRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	return NewOrderService(
+		db,
+		repository.NewOrderRepository(db),
+		cartRepo,
+		productRepo,
+		NewCartService(cartRepo, repository.NewGuestCartRepository(db), productRepo, cfg),
+		nil,
+		nil,
+		nil,
+		cfg,
+	)
+}
+
+func createTestProduct(t *testing.T, db *gorm.DB, name string, price float64, stock int) *domain.Product {
+	t.Helper()
+
+	product := &domain.Product{
+		Name:           name,
+		Slug:           name,
+		SKU:            name,
+		Price:          price,
+		StockQuantity:  stock,
+		TrackInventory: true,
+		IsActive:       true,
+	}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("failed to create product: %v", err)
+	}
+	return product
+}
+
+func createTestCart(t *testing.T, db *gorm.DB, email string, product *domain.Product, quantity int) *domain.User {
+	t.Helper()
+
+	user := &domain.User{
+		Email:        email,
+		PasswordHash: "hashed_password",
+		Role:         domain.RoleCustomer,
+		IsActive:     true,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	cart := &domain.Cart{UserID: user.ID}
+	if err := db.Create(cart).Error; err != nil {
+		t.Fatalf("failed to create cart: %v", err)
+	}
+
+	item := &domain.CartItem{
+		CartID:    cart.ID,
+		ProductID: product.ID,
+		Quantity:  quantity,
+		Price:     product.Price,
+	}
+	if err := db.Create(item).Error; err != nil {
+		t.Fatalf("failed to create cart item: %v", err)
+	}
+
+	return user
+}
+
+func testOrderRequest() *domain.CreateOrderRequest {
+	return &domain.CreateOrderRequest{
+		PaymentMethod: "card",
+		ShippingAddress: domain.ShippingAddress{
+			Line1:      "1 Test Street",
+			City:       "Seoul",
+			State:      "Seoul",
+			PostalCode: "04524",
+			Country:    "KR",
+		},
+	}
+}
+
+func TestOrderService_CreateOrder_ConcurrentStockReservation(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	const stock = 10
+	const buyers = 50
+
+	product := createTestProduct(t, db, "limited-edition", 25.0, stock)
+
+	users := make([]*domain.User, buyers)
+	for i := range users {
+		users[i] = createTestCart(t, db, fmt.Sprintf("buyer%d@example.com", i), product, 1)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	start := make(chan struct{})
+	for _, user := range users {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			<-start
+			if _, err := orderService.CreateOrder(userID, testOrderRequest()); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(user.ID)
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != stock {
+		t.Errorf("CreateOrder() succeeded %d times, want %d", succeeded, stock)
+	}
+
+	var remaining domain.Product
+	if err := db.First(&remaining, product.ID).Error; err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if remaining.StockQuantity != 0 {
+		t.Errorf("StockQuantity = %d, want 0", remaining.StockQuantity)
+	}
+
+	var orders int64
+	db.Model(&domain.Order{}).Count(&orders)
+	if orders != stock {
+		t.Errorf("orders created = %d, want %d", orders, stock)
+	}
+}
+
+func TestOrderService_CreateOrder_InsufficientStock(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	product := createTestProduct(t, db, "scarce", 10.0, 1)
+	user := createTestCart(t, db, "greedy@example.com", product, 2)
+
+	if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err == nil {
+		t.Fatal("CreateOrder() should fail when stock is insufficient")
+	}
+
+	var remaining domain.Product
+	if err := db.First(&remaining, product.ID).Error; err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if remaining.StockQuantity != 1 {
+		t.Errorf("StockQuantity = %d, want 1", remaining.StockQuantity)
+	}
+}
+
+func TestOrderService_CreateOrder_ClearCartFailureRollsBack(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	product := createTestProduct(t, db, "rollback", 10.0, 5)
+	user := createTestCart(t, db, "rollback@example.com", product, 2)
+
+	// Fail the last step of the checkout, after stock is reserved and the
+	// order is written
+	db.Callback().Delete().Before("gorm:delete").Register("test:fail_clear_cart", func(tx *gorm.DB) {
+		if tx.Statement.Table == "cart_items" {
+			tx.AddError(errors.New("cart is locked"))
+		}
+	})
+
+	if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err == nil {
+		t.Fatal("CreateOrder() should fail when the cart cannot be cleared")
+	}
+
+	var remaining domain.Product
+	if err := db.First(&remaining, product.ID).Error; err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if remaining.StockQuantity != 5 {
+		t.Errorf("StockQuantity = %d, want 5 after the rollback", remaining.StockQuantity)
+	}
+
+	var orders int64
+	db.Model(&domain.Order{}).Count(&orders)
+	if orders != 0 {
+		t.Errorf("orders created = %d, want 0", orders)
+	}
+}
+
+func TestOrderService_CreateOrder_TotalLimits(t *testing.T) {
+	// A single 50.00 item totals 65.00 with 10% tax and flat 10.00 shipping.
+	tests := []struct {
+		name    string
+		limits  config.OrderConfig
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:    "below minimum",
+			limits:  config.OrderConfig{MinTotal: 100},
+			wantErr: true,
+			errMsg:  "order total 65.00 is below the minimum of 100.00",
+		},
+		{
+			name:    "above maximum",
+			limits:  config.OrderConfig{MaxTotal: 60},
+			wantErr: true,
+			errMsg:  "order total 65.00 exceeds the maximum of 60.00",
+		},
+		{
+			name:    "within range",
+			limits:  config.OrderConfig{MinTotal: 20, MaxTotal: 100},
+			wantErr: false,
+		},
+		{
+			name:    "no maximum configured",
+			limits:  config.OrderConfig{MinTotal: 20},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupOrderTestDB(t)
+			orderService := setupOrderService(db, &config.Config{Order: tt.limits})
+
+			product := createTestProduct(t, db, "widget", 50.0, 5)
+			user := createTestCart(t, db, "limits@example.com", product, 1)
+
+			// A rejected order must not reserve stock even temporarily
+			reservations := 0
+			db.Callback().Update().Before("gorm:update").Register("test:count_reservations", func(tx *gorm.DB) {
+				if tx.Statement.Table == "products" {
+					reservations++
+				}
+			})
+
+			order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var remaining domain.Product
+			if err := db.First(&remaining, product.ID).Error; err != nil {
+				t.Fatalf("failed to reload product: %v", err)
+			}
+
+			if tt.wantErr {
+				if err.Error() != tt.errMsg {
+					t.Errorf("CreateOrder() error = %v, want %v", err.Error(), tt.errMsg)
+				}
+				if remaining.StockQuantity != 5 {
+					t.Errorf("StockQuantity = %d, want 5 after rejected order", remaining.StockQuantity)
+				}
+				if reservations != 0 {
+					t.Errorf("stock reserved %d times for a rejected order, want 0", reservations)
+				}
+				return
+			}
+
+			if order.Total != 65.0 {
+				t.Errorf("Total = %.2f, want 65.00", order.Total)
+			}
+			if remaining.StockQuantity != 4 {
+				t.Errorf("StockQuantity = %d, want 4", remaining.StockQuantity)
+			}
+		})
+	}
+}
+
+func TestOrderService_CancelItem(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	kept := createTestProduct(t, db, "kept", 30.0, 10)
+	dropped := createTestProduct(t, db, "dropped", 20.0, 10)
+	user := createTestCart(t, db, "partial@example.com", kept, 1)
+
+	var cart domain.Cart
+	db.Where("user_id = ?", user.ID).First(&cart)
+	if err := db.Create(&domain.CartItem{CartID: cart.ID, ProductID: dropped.ID, Quantity: 2, Price: dropped.Price}).Error; err != nil {
+		t.Fatalf("failed to create cart item: %v", err)
+	}
+
+	order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	var keptItem, droppedItem domain.OrderItem
+	for _, item := range order.Items {
+		if item.ProductID == dropped.ID {
+			droppedItem = item
+		} else {
+			keptItem = item
+		}
+	}
+
+	if _, err := orderService.CancelItem(user.ID+1, order.ID, droppedItem.ID); err == nil {
+		t.Error("CancelItem() by another user should fail")
+	}
+
+	updated, err := orderService.CancelItem(user.ID, order.ID, droppedItem.ID)
+	if err != nil {
+		t.Fatalf("CancelItem() error = %v", err)
+	}
+
+	// 30.00 subtotal + 3.00 tax + 10.00 shipping
+	if updated.Subtotal != 30.0 || updated.Tax != 3.0 || updated.Shipping != 10.0 || updated.Total != 43.0 {
+		t.Errorf("totals = %.2f/%.2f/%.2f/%.2f, want 30.00/3.00/10.00/43.00",
+			updated.Subtotal, updated.Tax, updated.Shipping, updated.Total)
+	}
+	if len(updated.Items) != 1 || updated.Items[0].ID != keptItem.ID {
+		t.Errorf("Items = %+v, want only item %d", updated.Items, keptItem.ID)
+	}
+	if updated.Status != domain.OrderStatusPending {
+		t.Errorf("Status = %s, want pending", updated.Status)
+	}
+	if n := len(updated.StatusHistory); n == 0 || updated.StatusHistory[n-1].ChangedBy == nil || *updated.StatusHistory[n-1].ChangedBy != user.ID {
+		t.Errorf("StatusHistory = %+v, want the cancellation recorded as changed by user %d", updated.StatusHistory, user.ID)
+	}
+
+	var reloaded domain.Product
+	db.First(&reloaded, dropped.ID)
+	if reloaded.StockQuantity != 10 {
+		t.Errorf("dropped product stock = %d, want 10", reloaded.StockQuantity)
+	}
+
+	if _, err := orderService.CancelItem(user.ID, order.ID, droppedItem.ID); err == nil {
+		t.Error("CancelItem() of an already cancelled item should fail")
+	}
+}
+
+func TestOrderService_CancelItem_StockFailureRollsBack(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	kept := createTestProduct(t, db, "kept", 30.0, 10)
+	dropped := createTestProduct(t, db, "dropped", 20.0, 10)
+	user := createTestCart(t, db, "rollback-item@example.com", kept, 1)
+
+	var cart domain.Cart
+	db.Where("user_id = ?", user.ID).First(&cart)
+	if err := db.Create(&domain.CartItem{CartID: cart.ID, ProductID: dropped.ID, Quantity: 2, Price: dropped.Price}).Error; err != nil {
+		t.Fatalf("failed to create cart item: %v", err)
+	}
+
+	order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	var droppedItem domain.OrderItem
+	for _, item := range order.Items {
+		if item.ProductID == dropped.ID {
+			droppedItem = item
+		}
+	}
+
+	// Restoring the stock is the last step of the cancellation
+	db.Callback().Update().Before("gorm:update").Register("test:fail_restock", func(tx *gorm.DB) {
+		if tx.Statement.Table == "products" {
+			tx.AddError(errors.New("products are locked"))
+		}
+	})
+
+	if _, err := orderService.CancelItem(user.ID, order.ID, droppedItem.ID); err == nil {
+		t.Fatal("CancelItem() should fail when stock cannot be restored")
+	}
+
+	var reloaded domain.Order
+	db.Preload("Items").Preload("StatusHistory").First(&reloaded, order.ID)
+	if len(reloaded.Items) != 2 || reloaded.Total != order.Total {
+		t.Errorf("order has %d items totalling %.2f, want the original 2 totalling %.2f", len(reloaded.Items), reloaded.Total, order.Total)
+	}
+	if len(reloaded.StatusHistory) != len(order.StatusHistory) {
+		t.Errorf("StatusHistory has %d entries, want %d", len(reloaded.StatusHistory), len(order.StatusHistory))
+	}
+}
+
+func TestOrderService_CancelItem_LastItemCancelsOrder(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	product := createTestProduct(t, db, "single", 50.0, 5)
+	user := createTestCart(t, db, "single@example.com", product, 2)
+
+	order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	updated, err := orderService.CancelItem(user.ID, order.ID, order.Items[0].ID)
+	if err != nil {
+		t.Fatalf("CancelItem() error = %v", err)
+	}
+	if updated.Status != domain.OrderStatusCancelled {
+		t.Errorf("Status = %s, want cancelled", updated.Status)
+	}
+
+	var reloaded domain.Product
+	db.First(&reloaded, product.ID)
+	if reloaded.StockQuantity != 5 {
+		t.Errorf("StockQuantity = %d, want 5", reloaded.StockQuantity)
+	}
+
+	if _, err := orderService.CancelItem(user.ID, order.ID, order.Items[0].ID); err == nil {
+		t.Error("CancelItem() on a cancelled order should fail")
+	}
+}
+
+func TestOrderService_CancelItem_PaidOrder(t *testing.T) {
+	db := setupOrderTestDB(t)
+	orderService := setupOrderService(db, &config.Config{})
+
+	kept := createTestProduct(t, db, "kept", 30.0, 10)
+	dropped := createTestProduct(t, db, "dropped", 20.0, 10)
+	user := createTestCart(t, db, "paid-item@example.com", kept, 1)
+
+	var cart domain.Cart
+	db.Where("user_id = ?", user.ID).First(&cart)
+	if err := db.Create(&domain.CartItem{CartID: cart.ID, ProductID: dropped.ID, Quantity: 2, Price: dropped.Price}).Error; err != nil {
+		t.Fatalf("failed to create cart item: %v", err)
+	}
+
+	order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	db.Model(order).Update("payment_status", domain.PaymentStatusSucceeded)
+
+	if _, err := orderService.CancelItem(user.ID, order.ID, order.Items[0].ID); err == nil {
+		t.Fatal("CancelItem() on a paid order should fail")
+	}
+
+	var reloaded domain.Order
+	db.Preload("Items").First(&reloaded, order.ID)
+	if len(reloaded.Items) != 2 || reloaded.Total != order.Total {
+		t.Errorf("order has %d items totalling %.2f, want the original 2 totalling %.2f", len(reloaded.Items), reloaded.Total, order.Total)
+	}
+}
+
+func TestOrderService_CreateOrder_InactiveProduct(t *testing.T) {
+	db := setupOrderTestDB(t)
+
+	active := createTestProduct(t, db, "active", 20.0, 5)
+	retired := createTestProduct(t, db, "retired", 30.0, 5)
+	user := createTestCart(t, db, "retired@example.com", active, 1)
+
+	var cart domain.Cart
+	db.Where("user_id = ?", user.ID).First(&cart)
+	retiredItem := &domain.CartItem{CartID: cart.ID, ProductID: retired.ID, Quantity: 1, Price: retired.Price}
+	if err := db.Create(retiredItem).Error; err != nil {
+		t.Fatalf("failed to create cart item: %v", err)
+	}
+
+	// Deactivated after it was added to the cart
+	db.Model(retired).Update("is_active", false)
+
+	orderService := setupOrderService(db, &config.Config{Order: config.OrderConfig{RequireActiveProducts: true}})
+	_, err := orderService.CreateOrder(user.ID, testOrderRequest())
+
+	var validationErr *domain.CartValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("CreateOrder() error = %v, want *domain.CartValidationError", err)
+	}
+	if len(validationErr.Issues) != 1 {
+		t.Fatalf("issues = %+v, want only the retired product", validationErr.Issues)
+	}
+	if issue := validationErr.Issues[0]; issue.Type != domain.CartIssueProductInactive || issue.CartItemID != retiredItem.ID || issue.ProductID != retired.ID || issue.ProductName != "retired" {
+		t.Errorf("issue = %+v, want product_inactive for cart item %d, product %d", issue, retiredItem.ID, retired.ID)
+	}
+
+	// Nothing was reserved, not even the active product listed first
+	for _, product := range []*domain.Product{active, retired} {
+		var reloaded domain.Product
+		db.First(&reloaded, product.ID)
+		if reloaded.StockQuantity != 5 {
+			t.Errorf("%s stock = %d, want 5", reloaded.Name, reloaded.StockQuantity)
+		}
+	}
+
+	// With enforcement disabled the order goes through
+	orderService = setupOrderService(db, &config.Config{})
+	if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err != nil {
+		t.Errorf("CreateOrder() without enforcement error = %v", err)
+	}
+}
+
+func TestOrderService_GetUserOrders_Filters(t *testing.T) {
+	db := setupOrderTestDB(t)
+	svc := setupOrderService(db, &config.Config{})
+
+	day := func(d int) time.Time { return time.Date(2024, time.March, d, 12, 0, 0, 0, time.UTC) }
+	date := func(d int) *time.Time {
+		midnight := time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC)
+		return &midnight
+	}
+	status := func(s domain.OrderStatus) *domain.OrderStatus { return &s }
+
+	var users []*domain.User
+	for _, email := range []string{"buyer@example.com", "other@example.com"} {
+		user := &domain.User{Email: email, PasswordHash: "hashed_password", Role: domain.RoleCustomer, IsActive: true}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		users = append(users, user)
+	}
+	buyer, other := users[0], users[1]
+
+	seeds := []struct {
+		user   *domain.User
+		status domain.OrderStatus
+		day    int
+	}{
+		{buyer, domain.OrderStatusPending, 1},
+		{buyer, domain.OrderStatusDelivered, 5},
+		{buyer, domain.OrderStatusDelivered, 10},
+		{buyer, domain.OrderStatusCancelled, 10},
+		{buyer, domain.OrderStatusDelivered, 20},
+		{other, domain.OrderStatusDelivered, 10},
+	}
+	for i, seed := range seeds {
+		order := &domain.Order{
+			UserID:      seed.user.ID,
+			OrderNumber: fmt.Sprintf("ORD-TEST-%d", i),
+			Status:      seed.status,
+			Subtotal:    10,
+			Total:       10,
+			CreatedAt:   day(seed.day),
+		}
+		if err := db.Create(order).Error; err != nil {
+			t.Fatalf("failed to create order: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     domain.OrderListQuery
+		wantTotal int64
+		wantDays  []int
+	}{
+		{name: "all of the user's orders", query: domain.OrderListQuery{}, wantTotal: 5, wantDays: []int{20, 10, 10, 5, 1}},
+		{name: "by status", query: domain.OrderListQuery{Status: status(domain.OrderStatusDelivered)}, wantTotal: 3, wantDays: []int{20, 10, 5}},
+		{name: "from a date", query: domain.OrderListQuery{CreatedFrom: date(10)}, wantTotal: 3, wantDays: []int{20, 10, 10}},
+		{name: "to a date includes that day", query: domain.OrderListQuery{CreatedTo: date(10)}, wantTotal: 4, wantDays: []int{10, 10, 5, 1}},
+		{
+			name:      "status and date range",
+			query:     domain.OrderListQuery{Status: status(domain.OrderStatusDelivered), CreatedFrom: date(5), CreatedTo: date(10)},
+			wantTotal: 2,
+			wantDays:  []int{10, 5},
+		},
+		{name: "paginated", query: domain.OrderListQuery{Status: status(domain.OrderStatusDelivered), Page: 2, Limit: 2}, wantTotal: 3, wantDays: []int{5}},
+		{name: "another user's ID is ignored", query: domain.OrderListQuery{UserID: &other.ID, CreatedFrom: date(10), CreatedTo: date(10)}, wantTotal: 2, wantDays: []int{10, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, total, err := svc.GetUserOrders(buyer.ID, &tt.query)
+			if err != nil {
+				t.Fatalf("GetUserOrders() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(orders) != len(tt.wantDays) {
+				t.Fatalf("got %d orders, want %d", len(orders), len(tt.wantDays))
+			}
+			for i, order := range orders {
+				if order.UserID != buyer.ID {
+					t.Errorf("order %s belongs to user %d", order.OrderNumber, order.UserID)
+				}
+				if order.CreatedAt.Day() != tt.wantDays[i] {
+					t.Errorf("order %d placed on day %d, want %d", i, order.CreatedAt.Day(), tt.wantDays[i])
+				}
+			}
+		})
+	}
+
+	_, _, err := svc.GetUserOrders(buyer.ID, &domain.OrderListQuery{CreatedFrom: date(10), CreatedTo: date(5)})
+	if !errors.Is(err, domain.ErrInvalidDateRange) {
+		t.Errorf("GetUserOrders() with an inverted range error = %v, want ErrInvalidDateRange", err)
+	}
+}
+
+// lowStockRecorder is a LowStockNotifier that remembers what it was told
+type lowStockRecorder struct {
+	items []domain.LowStockItem
+}
+
+func (r *lowStockRecorder) NotifyLowStock(item domain.LowStockItem) {
+	r.items = append(r.items, item)
+}
+
+func TestOrderService_CreateOrder_LowStockNotifiesOnce(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cfg := &config.Config{Inventory: config.InventoryConfig{LowStockThreshold: 5}}
+	cartRepo := repository.NewCartRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	notifier := &lowStockRecorder{}
+	orderService := NewOrderService(
+		db,
+		repository.NewOrderRepository(db),
+		cartRepo,
+		productRepo,
+		NewCartService(cartRepo, repository.NewGuestCartRepository(db), productRepo, cfg),
+		nil,
+		notifier,
+		nil,
+		cfg,
+	)
+
+	product := createTestProduct(t, db, "kettle", 30.0, 8)
+	custom := createTestProduct(t, db, "teapot", 20.0, 4)
+	db.Model(custom).Update("low_stock_threshold", 2)
+
+	buy := func(email string, product *domain.Product, quantity int) {
+		t.Helper()
+		user := createTestCart(t, db, email, product, quantity)
+		if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err != nil {
+			t.Fatalf("CreateOrder() error = %v", err)
+		}
+	}
+
+	buy("first@example.com", product, 2) // 6 left
+	if len(notifier.items) != 0 {
+		t.Fatalf("notified %+v above the threshold", notifier.items)
+	}
+	buy("second@example.com", product, 1) // 5 left, at the threshold
+	buy("third@example.com", product, 1)  // 4 left, already low
+
+	if len(notifier.items) != 1 {
+		t.Fatalf("notified %d times, want exactly once: %+v", len(notifier.items), notifier.items)
+	}
+	if item := notifier.items[0]; item.ProductID != product.ID || item.StockQuantity != 5 || item.Threshold != 5 {
+		t.Errorf("notified %+v, want kettle with 5 left at threshold 5", item)
+	}
+
+	// A product's own threshold replaces the configured one
+	buy("fourth@example.com", custom, 1) // 3 left
+	buy("fifth@example.com", custom, 1)  // 2 left
+	if len(notifier.items) != 2 || notifier.items[1].ProductID != custom.ID || notifier.items[1].Threshold != 2 {
+		t.Errorf("notified %+v, want teapot at its own threshold of 2", notifier.items)
+	}
+}
+
+// fakeGateway is a PaymentGateway that records refunds, or refuses them all
+// when err is set
+type fakeGateway struct {
+	refunds []float64
+	err     error
+}
+
+func (g *fakeGateway) Refund(order *domain.Order, amount float64) error {
+	if g.err != nil {
+		return g.err
+	}
+	g.refunds = append(g.refunds, amount)
+	return nil
+}
+
+// setupRefundTest places a paid order for two kettles and returns it with a
+// service refunding through gateway
+func setupRefundTest(t *testing.T, gateway PaymentGateway) (*gorm.DB, OrderService, *domain.Order, *domain.Product) {
+	t.Helper()
+
+	db := setupOrderTestDB(t)
+	cfg := &config.Config{}
+	cartRepo := repository.NewCartRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	orderService := NewOrderService(
+		db,
+		repository.NewOrderRepository(db),
+		cartRepo,
+		productRepo,
+		NewCartService(cartRepo, repository.NewGuestCartRepository(db), productRepo, cfg),
+		nil,
+		nil,
+		gateway,
+		cfg,
+	)
+
+	product := createTestProduct(t, db, "kettle", 30.0, 10)
+	user := createTestCart(t, db, "refund@example.com", product, 2)
+	order, err := orderService.CreateOrder(user.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	db.Model(order).Update("payment_status", domain.PaymentStatusSucceeded)
+
+	return db, orderService, order, product
+}
+
+func TestOrderService_RefundOrder_Full(t *testing.T) {
+	gateway := &fakeGateway{}
+	db, orderService, order, product := setupRefundTest(t, gateway)
+	const adminID = 99
+
+	refunded, err := orderService.RefundOrder(order.ID, adminID, nil)
+	if err != nil {
+		t.Fatalf("RefundOrder() error = %v", err)
+	}
+
+	// 60.00 subtotal + 6.00 tax + 10.00 shipping
+	if len(gateway.refunds) != 1 || gateway.refunds[0] != 76.0 {
+		t.Errorf("gateway refunds = %v, want [76]", gateway.refunds)
+	}
+	if refunded.Status != domain.OrderStatusRefunded || refunded.PaymentStatus != domain.PaymentStatusRefunded || refunded.RefundedAmount != 76.0 {
+		t.Errorf("order = %s/%s refunded %.2f, want refunded/refunded 76.00", refunded.Status, refunded.PaymentStatus, refunded.RefundedAmount)
+	}
+
+	var reloaded domain.Product
+	db.First(&reloaded, product.ID)
+	if reloaded.StockQuantity != 10 {
+		t.Errorf("stock = %d, want 10 restored", reloaded.StockQuantity)
+	}
+
+	if len(refunded.StatusHistory) != 1 {
+		t.Fatalf("status history = %+v, want one refund entry", refunded.StatusHistory)
+	}
+	entry := refunded.StatusHistory[0]
+	if entry.Status != domain.OrderStatusRefunded || entry.Amount == nil || *entry.Amount != 76.0 || entry.ChangedBy == nil || *entry.ChangedBy != adminID {
+		t.Errorf("history entry = %+v, want a 76.00 refund by admin %d", entry, adminID)
+	}
+
+	if _, err := orderService.RefundOrder(order.ID, adminID, nil); err == nil {
+		t.Error("RefundOrder() of a refunded order should fail")
+	}
+}
+
+func TestOrderService_RefundOrder_Partial(t *testing.T) {
+	gateway := &fakeGateway{}
+	db, orderService, order, product := setupRefundTest(t, gateway)
+
+	amount := 20.0
+	partial, err := orderService.RefundOrder(order.ID, 1, &amount)
+	if err != nil {
+		t.Fatalf("RefundOrder() error = %v", err)
+	}
+	if partial.Status != domain.OrderStatusPending || partial.PaymentStatus != domain.PaymentStatusPartiallyRefunded || partial.RefundedAmount != 20.0 {
+		t.Errorf("order = %s/%s refunded %.2f, want pending/partially_refunded 20.00", partial.Status, partial.PaymentStatus, partial.RefundedAmount)
+	}
+
+	// A partial refund keeps the goods with the customer
+	var reloaded domain.Product
+	db.First(&reloaded, product.ID)
+	if reloaded.StockQuantity != 8 {
+		t.Errorf("stock = %d, want 8", reloaded.StockQuantity)
+	}
+
+	tooMuch := 56.01
+	if _, err := orderService.RefundOrder(order.ID, 1, &tooMuch); err == nil || err.Error() != "refund amount exceeds the 56.00 left to refund" {
+		t.Errorf("RefundOrder() of more than is left error = %v", err)
+	}
+
+	rest := 56.0
+	full, err := orderService.RefundOrder(order.ID, 1, &rest)
+	if err != nil {
+		t.Fatalf("RefundOrder() error = %v", err)
+	}
+	if full.Status != domain.OrderStatusRefunded || full.PaymentStatus != domain.PaymentStatusRefunded || full.RefundedAmount != 76.0 {
+		t.Errorf("order = %s/%s refunded %.2f, want refunded/refunded 76.00", full.Status, full.PaymentStatus, full.RefundedAmount)
+	}
+	db.First(&reloaded, product.ID)
+	if reloaded.StockQuantity != 10 {
+		t.Errorf("stock = %d, want 10 once fully refunded", reloaded.StockQuantity)
+	}
+
+	if len(gateway.refunds) != 2 || gateway.refunds[0] != 20.0 || gateway.refunds[1] != 56.0 {
+		t.Errorf("gateway refunds = %v, want [20 56]", gateway.refunds)
+	}
+	if len(full.StatusHistory) != 2 {
+		t.Errorf("status history has %d entries, want 2", len(full.StatusHistory))
+	}
+}
+
+func TestOrderService_RefundOrder_GatewayFailure(t *testing.T) {
+	db, orderService, order, product := setupRefundTest(t, &fakeGateway{err: errors.New("card expired")})
+
+	if _, err := orderService.RefundOrder(order.ID, 1, nil); err == nil || err.Error() != "refund failed: card expired" {
+		t.Fatalf("RefundOrder() error = %v, want the gateway error", err)
+	}
+
+	// Nothing was changed
+	var reloaded domain.Order
+	db.Preload("StatusHistory").First(&reloaded, order.ID)
+	if reloaded.PaymentStatus != domain.PaymentStatusSucceeded || reloaded.RefundedAmount != 0 || len(reloaded.StatusHistory) != 0 {
+		t.Errorf("order = %s refunded %.2f with %d history entries, want it untouched",
+			reloaded.PaymentStatus, reloaded.RefundedAmount, len(reloaded.StatusHistory))
+	}
+	var reloadedProduct domain.Product
+	db.First(&reloadedProduct, product.ID)
+	if reloadedProduct.StockQuantity != 8 {
+		t.Errorf("stock = %d, want 8", reloadedProduct.StockQuantity)
+	}
+}
+
+func TestOrderService_RefundOrder_Unpaid(t *testing.T) {
+	db, orderService, order, _ := setupRefundTest(t, &fakeGateway{})
+	db.Model(order).Update("payment_status", domain.PaymentStatusPending)
+
+	if _, err := orderService.RefundOrder(order.ID, 1, nil); err == nil {
+		t.Error("RefundOrder() of an unpaid order should fail")
+	}
+}
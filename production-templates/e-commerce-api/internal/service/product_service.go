@@ -1,8 +1,14 @@
 package service
 
 import (
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
+	"github.com/modsynth/e-commerce-api/internal/config"
 	"github.com/modsynth/e-commerce-api/internal/domain"
 	"github.com/modsynth/e-commerce-api/internal/repository"
 )
@@ -15,35 +21,28 @@ type ProductService interface {
 	DeleteProduct(id uint) error
 	ListProducts(query *domain.ProductListQuery) ([]*domain.Product, int64, error)
 	CheckStock(productID uint, quantity int) (bool, error)
+	ImportCSV(r io.Reader) (*domain.ImportResult, error)
+	BulkCreate(reqs []domain.CreateProductRequest) (*domain.ImportResult, error)
+	BulkCreateCSV(r io.Reader) (*domain.ImportResult, error)
+	GetLowStock() ([]domain.LowStockItem, error)
+	GetRelatedProducts(productID uint, limit int) ([]*domain.Product, error)
+	GetAvailability(productID uint) (*domain.ProductAvailability, error)
 }
 
 type productService struct {
 	productRepo repository.ProductRepository
+	config      *config.Config
 }
 
-func NewProductService(productRepo repository.ProductRepository) ProductService {
+func NewProductService(productRepo repository.ProductRepository, config *config.Config) ProductService {
 	return &productService{
 		productRepo: productRepo,
+		config:      config,
 	}
 }
 
 func (s *productService) CreateProduct(req *domain.CreateProductRequest) (*domain.Product, error) {
-	product := &domain.Product{
-		CategoryID:     req.CategoryID,
-		Name:           req.Name,
-		Slug:           req.Slug,
-		Description:    req.Description,
-		Price:          req.Price,
-		ComparePrice:   req.ComparePrice,
-		CostPrice:      req.CostPrice,
-		SKU:            req.SKU,
-		Barcode:        req.Barcode,
-		StockQuantity:  req.StockQuantity,
-		TrackInventory: req.TrackInventory,
-		Weight:         req.Weight,
-		IsActive:       req.IsActive,
-		Featured:       req.Featured,
-	}
+	product := productFromRequest(req)
 
 	if err := s.productRepo.Create(product); err != nil {
 		return nil, errors.New("failed to create product")
@@ -105,9 +104,15 @@ func (s *productService) UpdateProduct(id uint, req *domain.UpdateProductRequest
 	if req.StockQuantity != nil {
 		product.StockQuantity = *req.StockQuantity
 	}
+	if req.LowStockThreshold != nil {
+		product.LowStockThreshold = req.LowStockThreshold
+	}
 	if req.TrackInventory != nil {
 		product.TrackInventory = *req.TrackInventory
 	}
+	if req.AllowBackorder != nil {
+		product.AllowBackorder = *req.AllowBackorder
+	}
 	if req.Weight != nil {
 		product.Weight = req.Weight
 	}
@@ -151,3 +156,622 @@ func (s *productService) CheckStock(productID uint, quantity int) (bool, error)
 
 	return product.StockQuantity >= quantity, nil
 }
+
+const (
+	defaultRelatedLimit = 8
+	maxRelatedLimit     = 20
+)
+
+func (s *productService) GetRelatedProducts(productID uint, limit int) ([]*domain.Product, error) {
+	if limit < 1 {
+		limit = defaultRelatedLimit
+	}
+	if limit > maxRelatedLimit {
+		limit = maxRelatedLimit
+	}
+
+	return s.productRepo.FindRelated(productID, limit)
+}
+
+// GetAvailability reports how many units can be sold. Stock is taken out of
+// StockQuantity when an order is placed, so StockQuantity is what checkout
+// sells from and units held by unshipped orders are still on hand.
+func (s *productService) GetAvailability(productID uint) (*domain.ProductAvailability, error) {
+	product, err := s.productRepo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := s.productRepo.GetReservedQuantity(productID)
+	if err != nil {
+		return nil, errors.New("failed to get reserved stock")
+	}
+
+	available := product.StockQuantity
+	if available < 0 {
+		// Backordered units are owed, not available
+		available = 0
+	}
+	onHand := reserved + product.StockQuantity
+	if onHand < 0 {
+		onHand = 0
+	}
+
+	return &domain.ProductAvailability{
+		ProductID:      product.ID,
+		TrackInventory: product.TrackInventory,
+		AllowBackorder: product.AllowBackorder,
+		Available:      available,
+		InStock:        product.CanFulfill(1),
+		OnHand:         &onHand,
+		Reserved:       &reserved,
+	}, nil
+}
+
+// GetLowStock returns tracked products at or below their low stock threshold,
+// lowest stock first. Products without a threshold of their own use the
+// configured one.
+func (s *productService) GetLowStock() ([]domain.LowStockItem, error) {
+	products, err := s.productRepo.ListLowStock(s.config.Inventory.LowStockThreshold)
+	if err != nil {
+		return nil, errors.New("failed to list low stock products")
+	}
+
+	items := make([]domain.LowStockItem, 0, len(products))
+	for _, product := range products {
+		items = append(items, domain.LowStockItem{
+			ProductID:     product.ID,
+			Name:          product.Name,
+			SKU:           product.SKU,
+			StockQuantity: product.StockQuantity,
+			Threshold:     product.EffectiveLowStockThreshold(s.config.Inventory.LowStockThreshold),
+		})
+	}
+
+	return items, nil
+}
+
+// requiredImportColumns must be present in the CSV header. All other known
+// columns are optional and left untouched on update when empty.
+var requiredImportColumns = []string{"name", "slug", "price", "sku"}
+
+// ImportCSV creates or updates products from a CSV file whose first row is a
+// header. Rows are matched to existing products by SKU. A row that fails
+// validation or cannot be saved is reported in the result and does not stop
+// the rest of the import; only an unreadable header aborts it.
+func (s *productService) ImportCSV(r io.Reader) (*domain.ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV file is empty")
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing required column %q", name)
+		}
+	}
+
+	result := &domain.ImportResult{Rows: []domain.ImportRowResult{}}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var row domain.ImportRowResult
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			row = domain.ImportRowResult{Row: parseErr.StartLine, Status: domain.ImportRowFailed, Error: parseErr.Err.Error()}
+		case err != nil:
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		default:
+			row = s.importRow(importRecord{columns: columns, values: record})
+			row.Row, _ = reader.FieldPos(0)
+		}
+
+		result.Total++
+		switch row.Status {
+		case domain.ImportRowCreated:
+			result.Created++
+		case domain.ImportRowUpdated:
+			result.Updated++
+		case domain.ImportRowFailed:
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, nil
+}
+
+func (s *productService) importRow(rec importRecord) domain.ImportRowResult {
+	sku := rec.get("sku")
+	row := domain.ImportRowResult{SKU: sku, Status: domain.ImportRowFailed}
+
+	if sku == "" {
+		row.Error = "sku is required"
+		return row
+	}
+
+	existing, err := s.productRepo.FindBySKU(sku)
+	product := existing
+	if err != nil {
+		product = &domain.Product{SKU: sku, TrackInventory: true, IsActive: true}
+	}
+
+	if err := s.applyImportRecord(product, rec, existing == nil); err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	if existing != nil {
+		if err := s.productRepo.Update(product); err != nil {
+			row.Error = "failed to update product"
+			return row
+		}
+		row.Status = domain.ImportRowUpdated
+	} else {
+		if err := s.productRepo.Create(product); err != nil {
+			row.Error = "failed to create product"
+			return row
+		}
+		row.Status = domain.ImportRowCreated
+	}
+
+	row.ProductID = product.ID
+	return row
+}
+
+// applyImportRecord copies the row's values onto product. For new products
+// name, slug and price are mandatory; for updates empty cells keep the
+// current value.
+func (s *productService) applyImportRecord(product *domain.Product, rec importRecord, isNew bool) error {
+	if v := rec.get("name"); v != "" {
+		product.Name = v
+	} else if isNew {
+		return errors.New("name is required")
+	}
+
+	if v := rec.get("slug"); v != "" {
+		product.Slug = v
+	} else if isNew {
+		return errors.New("slug is required")
+	}
+
+	if v := rec.get("price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price <= 0 {
+			return fmt.Errorf("invalid price %q", v)
+		}
+		product.Price = price
+	} else if isNew {
+		return errors.New("price is required")
+	}
+
+	if v := rec.get("description"); v != "" {
+		product.Description = v
+	}
+	if v := rec.get("barcode"); v != "" {
+		product.Barcode = v
+	}
+
+	for _, field := range []struct {
+		column string
+		target **float64
+	}{
+		{"compare_price", &product.ComparePrice},
+		{"cost_price", &product.CostPrice},
+		{"weight", &product.Weight},
+	} {
+		if v := rec.get(field.column); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return fmt.Errorf("invalid %s %q", field.column, v)
+			}
+			*field.target = &f
+		}
+	}
+
+	if v := rec.get("stock_quantity"); v != "" {
+		stock, err := strconv.Atoi(v)
+		if err != nil || stock < 0 {
+			return fmt.Errorf("invalid stock_quantity %q", v)
+		}
+		product.StockQuantity = stock
+	}
+
+	for _, field := range []struct {
+		column string
+		target *bool
+	}{
+		{"track_inventory", &product.TrackInventory},
+		{"allow_backorder", &product.AllowBackorder},
+		{"is_active", &product.IsActive},
+		{"featured", &product.Featured},
+	} {
+		if v := rec.get(field.column); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", field.column, v)
+			}
+			*field.target = b
+		}
+	}
+
+	if v := rec.get("category_slug"); v != "" {
+		category, err := s.productRepo.FindCategoryBySlug(v)
+		if err != nil {
+			return fmt.Errorf("unknown category %q", v)
+		}
+		product.CategoryID = &category.ID
+		product.Category = nil
+	}
+
+	return nil
+}
+
+type importRecord struct {
+	columns map[string]int
+	values  []string
+}
+
+// get returns the trimmed value of the named column, or "" when the column is
+// absent from the header or the row is short.
+func (r importRecord) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+// MaxBulkProducts caps how many products one bulk create may contain
+const MaxBulkProducts = 1000
+
+// BulkProductColumns is the column order of a bulk create CSV. The file starts
+// with a header row listing these columns in this order; trailing columns
+// after price may be left out.
+var BulkProductColumns = []string{
+	"name", "slug", "price", "sku", "stock_quantity", "description", "category_id",
+	"compare_price", "cost_price", "barcode", "weight", "low_stock_threshold",
+	"track_inventory", "allow_backorder", "is_active", "featured",
+}
+
+// bulkRow is one product of a bulk create. Row is its 1-based position in the
+// request, or its line number in a CSV upload.
+type bulkRow struct {
+	row int
+	req domain.CreateProductRequest
+	err error
+}
+
+// BulkCreate creates products from reqs. Every row is validated first,
+// including duplicate SKUs and slugs within the batch and against existing
+// products; the valid rows are then inserted in a single transaction and the
+// invalid ones reported in the result. Unlike ImportCSV it never updates an
+// existing product.
+func (s *productService) BulkCreate(reqs []domain.CreateProductRequest) (*domain.ImportResult, error) {
+	rows := make([]bulkRow, len(reqs))
+	for i, req := range reqs {
+		rows[i] = bulkRow{row: i + 1, req: req}
+	}
+	return s.bulkCreate(rows)
+}
+
+// BulkCreateCSV is BulkCreate for a CSV file laid out as BulkProductColumns.
+// Empty track_inventory and is_active cells default to true, as in ImportCSV.
+func (s *productService) BulkCreateCSV(r io.Reader) (*domain.ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV file is empty")
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if len(header) < 3 || len(header) > len(BulkProductColumns) {
+		return nil, fmt.Errorf("CSV header must list the columns %s in order", strings.Join(BulkProductColumns, ","))
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if want := BulkProductColumns[i]; strings.ToLower(strings.TrimSpace(name)) != want {
+			return nil, fmt.Errorf("CSV column %d must be %q, got %q", i+1, want, name)
+		}
+		columns[BulkProductColumns[i]] = i
+	}
+
+	var rows []bulkRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rows = append(rows, bulkRow{row: parseErr.StartLine, err: parseErr.Err})
+		case err != nil:
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		default:
+			line, _ := reader.FieldPos(0)
+			req, err := parseBulkRecord(importRecord{columns: columns, values: record})
+			rows = append(rows, bulkRow{row: line, req: req, err: err})
+		}
+	}
+
+	return s.bulkCreate(rows)
+}
+
+func (s *productService) bulkCreate(rows []bulkRow) (*domain.ImportResult, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no products to create")
+	}
+	if len(rows) > MaxBulkProducts {
+		return nil, fmt.Errorf("at most %d products can be created at once", MaxBulkProducts)
+	}
+
+	if err := s.checkBulkConflicts(rows); err != nil {
+		return nil, err
+	}
+
+	var products []*domain.Product
+	created := make(map[int]*domain.Product)
+	for i, row := range rows {
+		if row.err != nil {
+			continue
+		}
+		product := productFromRequest(&row.req)
+		products = append(products, product)
+		created[i] = product
+	}
+
+	if len(products) > 0 {
+		if err := s.productRepo.CreateBatch(products); err != nil {
+			return nil, errors.New("failed to create products")
+		}
+	}
+
+	result := &domain.ImportResult{Total: len(rows), Rows: make([]domain.ImportRowResult, 0, len(rows))}
+	for i, row := range rows {
+		rowResult := domain.ImportRowResult{Row: row.row, SKU: row.req.SKU}
+		if product, ok := created[i]; ok {
+			rowResult.Status = domain.ImportRowCreated
+			rowResult.ProductID = product.ID
+			result.Created++
+		} else {
+			rowResult.Status = domain.ImportRowFailed
+			rowResult.Error = row.err.Error()
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, rowResult)
+	}
+
+	return result, nil
+}
+
+// checkBulkConflicts validates every row that parsed and sets row.err on those
+// that are invalid, name a missing category, or reuse a SKU or slug from an
+// earlier row or an existing product.
+func (s *productService) checkBulkConflicts(rows []bulkRow) error {
+	var skus, slugs []string
+	var categoryIDs []uint
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = validateCreateProductRequest(&rows[i].req)
+		}
+		if rows[i].err != nil {
+			continue
+		}
+		if rows[i].req.SKU != "" {
+			skus = append(skus, rows[i].req.SKU)
+		}
+		slugs = append(slugs, rows[i].req.Slug)
+		if rows[i].req.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *rows[i].req.CategoryID)
+		}
+	}
+
+	takenSKUs, err := s.productRepo.FindTakenSKUs(skus)
+	if err != nil {
+		return errors.New("failed to check existing SKUs")
+	}
+	takenSlugs, err := s.productRepo.FindTakenSlugs(slugs)
+	if err != nil {
+		return errors.New("failed to check existing slugs")
+	}
+	existingCategories, err := s.productRepo.FindExistingCategoryIDs(categoryIDs)
+	if err != nil {
+		return errors.New("failed to check categories")
+	}
+
+	categories := make(map[uint]bool, len(existingCategories))
+	for _, id := range existingCategories {
+		categories[id] = true
+	}
+	skuRows := make(map[string]int)
+	for _, sku := range takenSKUs {
+		skuRows[sku] = 0
+	}
+	slugRows := make(map[string]int)
+	for _, slug := range takenSlugs {
+		slugRows[slug] = 0
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		if row.err != nil {
+			continue
+		}
+		req := &row.req
+
+		if req.CategoryID != nil && !categories[*req.CategoryID] {
+			row.err = fmt.Errorf("unknown category %d", *req.CategoryID)
+			continue
+		}
+		if req.SKU != "" {
+			if first, ok := skuRows[req.SKU]; ok {
+				row.err = duplicateError("sku", req.SKU, first)
+				continue
+			}
+		}
+		if first, ok := slugRows[req.Slug]; ok {
+			row.err = duplicateError("slug", req.Slug, first)
+			continue
+		}
+
+		if req.SKU != "" {
+			skuRows[req.SKU] = row.row
+		}
+		slugRows[req.Slug] = row.row
+	}
+
+	return nil
+}
+
+// duplicateError reports a value already used by row first, or by an existing
+// product when first is 0.
+func duplicateError(field, value string, first int) error {
+	if first == 0 {
+		return fmt.Errorf("%s %q is already used by another product", field, value)
+	}
+	return fmt.Errorf("duplicate %s %q, also in row %d", field, value, first)
+}
+
+// validateCreateProductRequest applies the checks the binding tags of
+// CreateProductRequest make for a single product.
+func validateCreateProductRequest(req *domain.CreateProductRequest) error {
+	switch {
+	case strings.TrimSpace(req.Name) == "":
+		return errors.New("name is required")
+	case strings.TrimSpace(req.Slug) == "":
+		return errors.New("slug is required")
+	case req.Price <= 0:
+		return errors.New("price must be greater than 0")
+	case req.StockQuantity < 0:
+		return errors.New("stock_quantity must not be negative")
+	case req.LowStockThreshold != nil && *req.LowStockThreshold < 0:
+		return errors.New("low_stock_threshold must not be negative")
+	}
+	return nil
+}
+
+func productFromRequest(req *domain.CreateProductRequest) *domain.Product {
+	return &domain.Product{
+		CategoryID:        req.CategoryID,
+		Name:              req.Name,
+		Slug:              req.Slug,
+		Description:       req.Description,
+		Price:             req.Price,
+		ComparePrice:      req.ComparePrice,
+		CostPrice:         req.CostPrice,
+		SKU:               req.SKU,
+		Barcode:           req.Barcode,
+		StockQuantity:     req.StockQuantity,
+		LowStockThreshold: req.LowStockThreshold,
+		TrackInventory:    req.TrackInventory,
+		AllowBackorder:    req.AllowBackorder,
+		Weight:            req.Weight,
+		IsActive:          req.IsActive,
+		Featured:          req.Featured,
+	}
+}
+
+// parseBulkRecord turns a bulk create CSV row into a request. Required values
+// are checked later by validateCreateProductRequest; only malformed numbers
+// and booleans fail here.
+func parseBulkRecord(rec importRecord) (domain.CreateProductRequest, error) {
+	req := domain.CreateProductRequest{
+		Name:           rec.get("name"),
+		Slug:           rec.get("slug"),
+		SKU:            rec.get("sku"),
+		Description:    rec.get("description"),
+		Barcode:        rec.get("barcode"),
+		TrackInventory: true,
+		IsActive:       true,
+	}
+
+	if v := rec.get("price"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return req, fmt.Errorf("invalid price %q", v)
+		}
+		req.Price = price
+	}
+
+	for _, field := range []struct {
+		column string
+		target **float64
+	}{
+		{"compare_price", &req.ComparePrice},
+		{"cost_price", &req.CostPrice},
+		{"weight", &req.Weight},
+	} {
+		if v := rec.get(field.column); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return req, fmt.Errorf("invalid %s %q", field.column, v)
+			}
+			*field.target = &f
+		}
+	}
+
+	if v := rec.get("stock_quantity"); v != "" {
+		stock, err := strconv.Atoi(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid stock_quantity %q", v)
+		}
+		req.StockQuantity = stock
+	}
+	if v := rec.get("low_stock_threshold"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid low_stock_threshold %q", v)
+		}
+		req.LowStockThreshold = &threshold
+	}
+	if v := rec.get("category_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return req, fmt.Errorf("invalid category_id %q", v)
+		}
+		categoryID := uint(id)
+		req.CategoryID = &categoryID
+	}
+
+	for _, field := range []struct {
+		column string
+		target *bool
+	}{
+		{"track_inventory", &req.TrackInventory},
+		{"allow_backorder", &req.AllowBackorder},
+		{"is_active", &req.IsActive},
+		{"featured", &req.Featured},
+	} {
+		if v := rec.get(field.column); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return req, fmt.Errorf("invalid %s %q", field.column, v)
+			}
+			*field.target = b
+		}
+	}
+
+	return req, nil
+}
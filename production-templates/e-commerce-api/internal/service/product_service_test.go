@@ -0,0 +1,369 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/modsynth/e-commerce-api/internal/config"
+	"github.com/modsynth/e-commerce-api/internal/domain"
+	"github.com/modsynth/e-commerce-api/internal/repository"
+)
+
+func TestProductService_ImportCSV(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	category := &domain.Category{Name: "Shoes", Slug: "shoes"}
+	if err := db.Create(category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	existing := createTestProduct(t, db, "old-runner", 80.0, 3)
+
+	csvData := strings.Join([]string{
+		"name,slug,price,sku,stock_quantity,category_slug",
+		"Trail Runner,trail-runner,120.50,TR-1,10,shoes",
+		"Old Runner v2,,95,old-runner,7,",
+		"No Price,no-price,,NP-1,1,",
+		"Bad Stock,bad-stock,10,BS-1,-4,",
+		"Lost,lost,10,LC-1,1,hats",
+		"Sandal,sandal,30,SD-1,,",
+	}, "\n")
+
+	result, err := productService.ImportCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+
+	if result.Total != 6 || result.Created != 2 || result.Updated != 1 || result.Failed != 3 {
+		t.Errorf("ImportCSV() totals = %+v, want total 6, created 2, updated 1, failed 3", result)
+	}
+
+	wantStatus := []struct {
+		row    int
+		status domain.ImportRowStatus
+		err    string
+	}{
+		{2, domain.ImportRowCreated, ""},
+		{3, domain.ImportRowUpdated, ""},
+		{4, domain.ImportRowFailed, "price is required"},
+		{5, domain.ImportRowFailed, `invalid stock_quantity "-4"`},
+		{6, domain.ImportRowFailed, `unknown category "hats"`},
+		{7, domain.ImportRowCreated, ""},
+	}
+	for i, want := range wantStatus {
+		got := result.Rows[i]
+		if got.Row != want.row || got.Status != want.status || got.Error != want.err {
+			t.Errorf("row %d = %+v, want row %d status %s error %q", i, got, want.row, want.status, want.err)
+		}
+	}
+
+	var created domain.Product
+	if err := db.Where("sku = ?", "TR-1").First(&created).Error; err != nil {
+		t.Fatalf("imported product not found: %v", err)
+	}
+	if created.CategoryID == nil || *created.CategoryID != category.ID || created.StockQuantity != 10 {
+		t.Errorf("created product = %+v, want category %d and stock 10", created, category.ID)
+	}
+
+	var updated domain.Product
+	if err := db.First(&updated, existing.ID).Error; err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if updated.Name != "Old Runner v2" || updated.Slug != "old-runner" || updated.Price != 95 || updated.StockQuantity != 7 {
+		t.Errorf("updated product = %+v, want name, price and stock replaced and slug kept", updated)
+	}
+}
+
+func TestProductService_ImportCSV_MissingColumn(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	_, err := productService.ImportCSV(strings.NewReader("name,slug,price\nA,a,1\n"))
+	if err == nil || err.Error() != `missing required column "sku"` {
+		t.Errorf("ImportCSV() error = %v, want missing sku column", err)
+	}
+}
+
+func TestProductService_BulkCreate(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	category := &domain.Category{Name: "Shoes", Slug: "shoes"}
+	if err := db.Create(category).Error; err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	existing := createTestProduct(t, db, "old-runner", 80.0, 3)
+	missingCategory := category.ID + 1
+	negative := -1
+
+	result, err := productService.BulkCreate([]domain.CreateProductRequest{
+		{Name: "Trail Runner", Slug: "trail-runner", Price: 120.5, SKU: "TR-1", StockQuantity: 10, CategoryID: &category.ID},
+		{Name: "No Price", Slug: "no-price", SKU: "NP-1"},
+		{Name: "Trail Runner Copy", Slug: "trail-runner-copy", Price: 99, SKU: "TR-1"},
+		{Name: "Old Runner", Slug: "old-runner-2", Price: 95, SKU: existing.SKU},
+		{Name: "Same Slug", Slug: "old-runner", Price: 95, SKU: "SS-1"},
+		{Name: "Lost", Slug: "lost", Price: 10, SKU: "LC-1", CategoryID: &missingCategory},
+		{Name: "Bad Threshold", Slug: "bad-threshold", Price: 10, SKU: "BT-1", LowStockThreshold: &negative},
+		{Name: "Sandal", Slug: "sandal", Price: 30},
+	})
+	if err != nil {
+		t.Fatalf("BulkCreate() error = %v", err)
+	}
+
+	if result.Total != 8 || result.Created != 2 || result.Failed != 6 {
+		t.Errorf("BulkCreate() totals = %+v, want total 8, created 2, failed 6", result)
+	}
+
+	wantStatus := []struct {
+		status domain.ImportRowStatus
+		err    string
+	}{
+		{domain.ImportRowCreated, ""},
+		{domain.ImportRowFailed, "price must be greater than 0"},
+		{domain.ImportRowFailed, `duplicate sku "TR-1", also in row 1`},
+		{domain.ImportRowFailed, `sku "old-runner" is already used by another product`},
+		{domain.ImportRowFailed, `slug "old-runner" is already used by another product`},
+		{domain.ImportRowFailed, fmt.Sprintf("unknown category %d", missingCategory)},
+		{domain.ImportRowFailed, "low_stock_threshold must not be negative"},
+		{domain.ImportRowCreated, ""},
+	}
+	for i, want := range wantStatus {
+		got := result.Rows[i]
+		if got.Row != i+1 || got.Status != want.status || got.Error != want.err {
+			t.Errorf("row %d = %+v, want status %s error %q", i+1, got, want.status, want.err)
+		}
+	}
+
+	var created domain.Product
+	if err := db.First(&created, result.Rows[0].ProductID).Error; err != nil {
+		t.Fatalf("created product not found: %v", err)
+	}
+	if created.SKU != "TR-1" || created.CategoryID == nil || *created.CategoryID != category.ID || created.StockQuantity != 10 {
+		t.Errorf("created product = %+v, want TR-1 in category %d with stock 10", created, category.ID)
+	}
+
+	var reloaded domain.Product
+	if err := db.First(&reloaded, existing.ID).Error; err != nil {
+		t.Fatalf("failed to reload product: %v", err)
+	}
+	if reloaded.Name != existing.Name || reloaded.Price != existing.Price {
+		t.Errorf("existing product = %+v, want it left untouched", reloaded)
+	}
+
+	if _, err := productService.BulkCreate(nil); err == nil {
+		t.Error("BulkCreate() of no products should fail")
+	}
+}
+
+func TestProductService_BulkCreateCSV(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	csvData := strings.Join([]string{
+		"name,slug,price,sku,stock_quantity",
+		"Trail Runner,trail-runner,120.50,TR-1,10",
+		"Bad Stock,bad-stock,10,BS-1,many",
+		"Twin,twin,10,TR-1,1",
+		"Sandal,sandal,30,SD-1",
+	}, "\n")
+
+	result, err := productService.BulkCreateCSV(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("BulkCreateCSV() error = %v", err)
+	}
+	if result.Created != 2 || result.Failed != 2 {
+		t.Errorf("BulkCreateCSV() totals = %+v, want created 2, failed 2", result)
+	}
+	wantErrors := []string{"", `invalid stock_quantity "many"`, `duplicate sku "TR-1", also in row 2`, ""}
+	for i, want := range wantErrors {
+		if got := result.Rows[i]; got.Row != i+2 || got.Error != want {
+			t.Errorf("row %d = %+v, want line %d error %q", i, got, i+2, want)
+		}
+	}
+
+	var sandal domain.Product
+	if err := db.Where("sku = ?", "SD-1").First(&sandal).Error; err != nil {
+		t.Fatalf("created product not found: %v", err)
+	}
+	if !sandal.TrackInventory || !sandal.IsActive {
+		t.Errorf("product = %+v, want inventory tracked and active by default", sandal)
+	}
+
+	_, err = productService.BulkCreateCSV(strings.NewReader("name,price,slug\nA,1,a\n"))
+	if err == nil || err.Error() != `CSV column 2 must be "slug", got "price"` {
+		t.Errorf("BulkCreateCSV() error = %v, want the column order rejected", err)
+	}
+}
+
+func TestProductService_GetLowStock(t *testing.T) {
+	db := setupOrderTestDB(t)
+	cfg := &config.Config{Inventory: config.InventoryConfig{LowStockThreshold: 5}}
+	productService := NewProductService(repository.NewProductRepository(db), cfg)
+
+	createTestProduct(t, db, "plenty", 10.0, 50)
+	createTestProduct(t, db, "at-threshold", 10.0, 5)
+	createTestProduct(t, db, "sold-out", 10.0, 0)
+	createTestProduct(t, db, "nearly-out", 10.0, 2)
+	untracked := createTestProduct(t, db, "untracked", 10.0, 0)
+	db.Model(untracked).Update("track_inventory", false)
+
+	// Products with their own threshold ignore the configured one
+	raised := createTestProduct(t, db, "raised", 10.0, 8)
+	db.Model(raised).Update("low_stock_threshold", 10)
+	lowered := createTestProduct(t, db, "lowered", 10.0, 3)
+	db.Model(lowered).Update("low_stock_threshold", 1)
+
+	items, err := productService.GetLowStock()
+	if err != nil {
+		t.Fatalf("GetLowStock() error = %v", err)
+	}
+
+	want := []string{"sold-out", "nearly-out", "at-threshold", "raised"}
+	wantThresholds := []int{5, 5, 5, 10}
+	if len(items) != len(want) {
+		t.Fatalf("GetLowStock() returned %d items, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.SKU != want[i] {
+			t.Errorf("items[%d].SKU = %s, want %s", i, item.SKU, want[i])
+		}
+		if item.Threshold != wantThresholds[i] {
+			t.Errorf("items[%d].Threshold = %d, want %d", i, item.Threshold, wantThresholds[i])
+		}
+	}
+}
+
+func TestProductService_GetRelatedProducts(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+
+	shoes := &domain.Category{Name: "Shoes", Slug: "shoes"}
+	hats := &domain.Category{Name: "Hats", Slug: "hats"}
+	db.Create(shoes)
+	db.Create(hats)
+
+	inCategory := func(name string, category *domain.Category, featured bool) *domain.Product {
+		product := createTestProduct(t, db, name, 10.0, 1)
+		db.Model(product).Updates(map[string]interface{}{"category_id": category.ID, "featured": featured})
+		return product
+	}
+
+	runner := inCategory("runner", shoes, false)
+	inCategory("boot", shoes, false)
+	inCategory("sandal", shoes, true)
+	inCategory("loafer", shoes, false)
+	hidden := inCategory("hidden", shoes, false)
+	db.Model(hidden).Update("is_active", false)
+	inCategory("cap", hats, true)
+	uncategorized := createTestProduct(t, db, "gift-card", 10.0, 1)
+
+	related, err := productService.GetRelatedProducts(runner.ID, 0)
+	if err != nil {
+		t.Fatalf("GetRelatedProducts() error = %v", err)
+	}
+	want := []string{"sandal", "loafer", "boot"}
+	if got := productSKUs(related); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GetRelatedProducts() = %v, want %v", got, want)
+	}
+
+	related, err = productService.GetRelatedProducts(runner.ID, 1)
+	if err != nil || len(related) != 1 {
+		t.Errorf("GetRelatedProducts() with limit 1 returned %d products, err %v", len(related), err)
+	}
+
+	// Without a category, featured products across the catalog are suggested
+	related, err = productService.GetRelatedProducts(uncategorized.ID, 0)
+	if err != nil {
+		t.Fatalf("GetRelatedProducts() error = %v", err)
+	}
+	want = []string{"cap", "sandal"}
+	if got := productSKUs(related); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GetRelatedProducts() fallback = %v, want %v", got, want)
+	}
+
+	if _, err := productService.GetRelatedProducts(9999, 0); err == nil {
+		t.Error("GetRelatedProducts() should fail for an unknown product")
+	}
+}
+
+func productSKUs(products []*domain.Product) []string {
+	skus := make([]string, len(products))
+	for i, product := range products {
+		skus[i] = product.SKU
+	}
+	return skus
+}
+
+func TestProductService_GetAvailability(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+	orderService := setupOrderService(db, &config.Config{})
+
+	product := createTestProduct(t, db, "lamp", 20.0, 10)
+	first := createTestCart(t, db, "first@example.com", product, 3)
+	second := createTestCart(t, db, "second@example.com", product, 2)
+
+	firstOrder, err := orderService.CreateOrder(first.ID, testOrderRequest())
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	if _, err := orderService.CreateOrder(second.ID, testOrderRequest()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	availability, err := productService.GetAvailability(product.ID)
+	if err != nil {
+		t.Fatalf("GetAvailability() error = %v", err)
+	}
+	if *availability.OnHand != 10 || *availability.Reserved != 5 || availability.Available != 5 {
+		t.Errorf("availability = on hand %d, reserved %d, available %d, want 10, 5, 5",
+			*availability.OnHand, *availability.Reserved, availability.Available)
+	}
+
+	// Shipped units leave the warehouse and are no longer reserved
+	if err := orderService.UpdateOrderStatus(firstOrder.ID, domain.OrderStatusShipped); err != nil {
+		t.Fatalf("UpdateOrderStatus() error = %v", err)
+	}
+	availability, _ = productService.GetAvailability(product.ID)
+	if *availability.OnHand != 7 || *availability.Reserved != 2 || availability.Available != 5 {
+		t.Errorf("after shipping = on hand %d, reserved %d, available %d, want 7, 2, 5",
+			*availability.OnHand, *availability.Reserved, availability.Available)
+	}
+
+	public := availability.Public()
+	if public.OnHand != nil || public.Reserved != nil || public.Available != 5 {
+		t.Errorf("Public() = %+v, want only available stock", public)
+	}
+}
+
+func TestProductService_GetAvailability_Backorder(t *testing.T) {
+	db := setupOrderTestDB(t)
+	productService := NewProductService(repository.NewProductRepository(db), &config.Config{})
+	orderService := setupOrderService(db, &config.Config{})
+
+	product := createTestProduct(t, db, "preorder", 20.0, 1)
+	db.Model(product).Update("allow_backorder", true)
+	user := createTestCart(t, db, "eager@example.com", product, 3)
+
+	if _, err := orderService.CreateOrder(user.ID, testOrderRequest()); err != nil {
+		t.Fatalf("CreateOrder() with backorder error = %v", err)
+	}
+
+	availability, err := productService.GetAvailability(product.ID)
+	if err != nil {
+		t.Fatalf("GetAvailability() error = %v", err)
+	}
+	if availability.Available != 0 || !availability.InStock || !availability.AllowBackorder {
+		t.Errorf("availability = %+v, want nothing available but still purchasable", availability)
+	}
+	if *availability.OnHand != 1 || *availability.Reserved != 3 {
+		t.Errorf("on hand %d, reserved %d, want 1, 3", *availability.OnHand, *availability.Reserved)
+	}
+
+	db.Model(product).Update("allow_backorder", false)
+	availability, _ = productService.GetAvailability(product.ID)
+	if availability.InStock {
+		t.Error("InStock should be false once backorders are disabled")
+	}
+}
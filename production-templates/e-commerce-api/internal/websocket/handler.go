@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Allow all origins in development
+	},
+}
+
+type Handler struct {
+	hub *Hub
+}
+
+func NewHandler(hub *Hub) *Handler {
+	return &Handler{hub: hub}
+}
+
+// HandleOrders godoc
+// @Summary Subscribe to order events
+// @Description Upgrades to a WebSocket that receives ORDER_STATUS_CHANGED events for the authenticated user's orders
+// @Tags orders
+// @Success 101
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/ws/orders [get]
+// @Security BearerAuth
+func (h *Handler) HandleOrders(c *gin.Context) {
+	// Set by the auth middleware
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade connection: %v", err)
+		return
+	}
+
+	client := NewClient(h.hub, conn, userID.(uint))
+	h.hub.Register(client)
+
+	go client.WritePump()
+	go client.ReadPump()
+}
@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+)
+
+const sendBufferSize = 64
+
+// Hub keeps the open connections of each user and delivers messages to the
+// connections of the user they are addressed to. Unlike the chat hub there
+// are no rooms: every event belongs to exactly one customer.
+type Hub struct {
+	// Registered clients organized by user ID
+	users map[uint]map[*Client]bool
+
+	// Outbound messages, delivered to Message.UserID
+	send chan *Message
+
+	// Register requests from clients
+	register chan *Client
+
+	// Unregister requests from clients
+	unregister chan *Client
+
+	// Mutex for thread-safe operations
+	mu sync.RWMutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		users:      make(map[uint]map[*Client]bool),
+		send:       make(chan *Message, 256),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+	}
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.registerClient(client)
+
+		case client := <-h.unregister:
+			h.unregisterClient(client)
+
+		case message := <-h.send:
+			h.deliver(message)
+		}
+	}
+}
+
+func (h *Hub) registerClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.users[client.UserID] == nil {
+		h.users[client.UserID] = make(map[*Client]bool)
+	}
+	h.users[client.UserID][client] = true
+}
+
+func (h *Hub) unregisterClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.users[client.UserID]
+	if !ok {
+		return
+	}
+	if _, exists := clients[client]; !exists {
+		return
+	}
+
+	delete(clients, client)
+	close(client.send)
+	if len(clients) == 0 {
+		delete(h.users, client.UserID)
+	}
+}
+
+func (h *Hub) deliver(message *Message) {
+	var overflowed []*Client
+
+	h.mu.RLock()
+	for client := range h.users[message.UserID] {
+		select {
+		case client.send <- message:
+		default:
+			// Client cannot keep up
+			overflowed = append(overflowed, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range overflowed {
+		log.Printf("Dropping slow order event client: UserID=%d", client.UserID)
+		h.unregisterClient(client)
+	}
+}
+
+// SendToUser delivers a message to every open connection of message.UserID
+func (h *Hub) SendToUser(message *Message) {
+	h.send <- message
+}
+
+// Register adds a client to the hub
+func (h *Hub) Register(client *Client) {
+	h.register <- client
+}
+
+// Unregister removes a client from the hub
+func (h *Hub) Unregister(client *Client) {
+	h.unregister <- client
+}
+
+// GetClientCount returns the total number of connected clients
+func (h *Hub) GetClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, clients := range h.users {
+		count += len(clients)
+	}
+	return count
+}
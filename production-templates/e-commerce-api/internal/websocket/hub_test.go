@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func expectMessage(t *testing.T, client *Client, want MessageType) {
+	t.Helper()
+
+	select {
+	case got := <-client.send:
+		if got.Type != want || got.UserID != client.UserID {
+			t.Errorf("message = %+v, want %s for user %d", got, want, client.UserID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s on user %d", want, client.UserID)
+	}
+}
+
+func expectNoMessage(t *testing.T, client *Client) {
+	t.Helper()
+
+	select {
+	case got := <-client.send:
+		t.Errorf("user %d received unexpected message %+v", client.UserID, got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_SendToUser(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	// The owner has two tabs open; another customer is also connected
+	phone := NewClient(hub, nil, 1)
+	laptop := NewClient(hub, nil, 1)
+	other := NewClient(hub, nil, 2)
+	for _, client := range []*Client{phone, laptop, other} {
+		hub.Register(client)
+	}
+
+	hub.SendToUser(NewMessage(MessageTypeOrderStatusChanged, 1, nil))
+
+	expectMessage(t, phone, MessageTypeOrderStatusChanged)
+	expectMessage(t, laptop, MessageTypeOrderStatusChanged)
+	expectNoMessage(t, other)
+
+	hub.Unregister(phone)
+	hub.SendToUser(NewMessage(MessageTypeOrderStatusChanged, 1, nil))
+
+	expectMessage(t, laptop, MessageTypeOrderStatusChanged)
+	if got := hub.GetClientCount(); got != 2 {
+		t.Errorf("GetClientCount() = %d, want 2", got)
+	}
+}
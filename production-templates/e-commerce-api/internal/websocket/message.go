@@ -0,0 +1,29 @@
+package websocket
+
+import "time"
+
+// MessageType represents the type of WebSocket message
+type MessageType string
+
+const (
+	// Order events
+	MessageTypeOrderStatusChanged MessageType = "ORDER_STATUS_CHANGED"
+)
+
+// Message is an event pushed to a user's connections
+type Message struct {
+	Type      MessageType `json:"type"`
+	UserID    uint        `json:"user_id"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// NewMessage creates a message addressed to a single user
+func NewMessage(msgType MessageType, userID uint, data interface{}) *Message {
+	return &Message{
+		Type:      msgType,
+		UserID:    userID,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+}
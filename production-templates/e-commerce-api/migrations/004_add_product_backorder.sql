@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE products ADD COLUMN IF NOT EXISTS allow_backorder BOOLEAN NOT NULL DEFAULT false;
+
+-- +migrate Down
+ALTER TABLE products DROP COLUMN IF EXISTS allow_backorder;
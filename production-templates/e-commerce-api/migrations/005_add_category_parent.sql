@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_categories_parent;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
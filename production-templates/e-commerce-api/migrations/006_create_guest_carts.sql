@@ -0,0 +1,25 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS guest_carts (
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(token)
+);
+
+CREATE TABLE IF NOT EXISTS guest_cart_items (
+    id SERIAL PRIMARY KEY,
+    guest_cart_id INTEGER NOT NULL REFERENCES guest_carts(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    price DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(guest_cart_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guest_cart_items_cart ON guest_cart_items(guest_cart_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS guest_cart_items;
+DROP TABLE IF EXISTS guest_carts;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE products ADD COLUMN IF NOT EXISTS low_stock_threshold INTEGER;
+
+-- +migrate Down
+ALTER TABLE products DROP COLUMN IF EXISTS low_stock_threshold;
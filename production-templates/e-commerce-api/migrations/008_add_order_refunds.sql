@@ -0,0 +1,18 @@
+-- +migrate Up
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS order_status_histories (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL,
+    payment_status VARCHAR(50) NOT NULL,
+    amount DECIMAL(10, 2),
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_status_histories_order_id ON order_status_histories(order_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS order_status_histories;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded_amount;
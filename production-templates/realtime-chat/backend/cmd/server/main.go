@@ -11,15 +11,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"realtime-chat/internal/config"
+	"realtime-chat/internal/contentfilter"
 	"realtime-chat/internal/domain"
 	"realtime-chat/internal/handler"
 	"realtime-chat/internal/middleware"
+	"realtime-chat/internal/ratelimit"
 	"realtime-chat/internal/repository"
+	"realtime-chat/internal/sanitize"
 	"realtime-chat/internal/service"
+	"realtime-chat/internal/storage"
 	"realtime-chat/internal/websocket"
 )
 
@@ -41,25 +46,78 @@ func main() {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
-	// Create WebSocket hub and start it
-	hub := websocket.NewHub()
-	go hub.Run()
+	// Create WebSocket hub
+	hubBackend, hubPresence, err := newHubBackend(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up WebSocket hub backend: %v", err)
+	}
+	hub := websocket.NewHub(websocket.HubConfig{
+		SendBufferSize: cfg.WebSocket.SendBufferSize,
+		OverflowPolicy: websocket.OverflowPolicy(cfg.WebSocket.OverflowPolicy),
+		TypingTimeout:  cfg.WebSocket.TypingTimeout,
+		Shards:         cfg.WebSocket.Shards,
+		Backend:        hubBackend,
+		Presence:       hubPresence,
+	})
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	roomRepo := repository.NewRoomRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
+	blockRepo := repository.NewBlockRepository(db)
+	scheduledRepo := repository.NewScheduledMessageRepository(db)
+
+	// Track presence from WebSocket connections, then start the hub
+	presenceService := service.NewPresenceService(userRepo, roomRepo, hub)
+	hub.SetStatusUpdater(presenceService.SetConnectionStatus)
+	go hub.Run()
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.Auth.JWTSecret, time.Duration(cfg.Auth.JWTExpiration)*time.Minute)
-	roomService := service.NewRoomService(roomRepo, userRepo, messageRepo, hub)
-	messageService := service.NewMessageService(messageRepo, roomRepo, userRepo, hub)
+	contentSanitizer := sanitize.NewSanitizer(sanitize.ParseMode(cfg.Content.SanitizeMode))
+	fileStorage := storage.NewLocalStorage(cfg.Upload.UploadDir, cfg.Upload.BaseURL)
+	authService := service.NewAuthService(userRepo, roomRepo, fileStorage, hub, cfg.Auth.JWTSecret, time.Duration(cfg.Auth.JWTExpiration)*time.Minute, cfg.Upload.MaxAvatarSize)
+	roomService := service.NewRoomService(roomRepo, userRepo, messageRepo, blockRepo, hub)
+	var messageLimiter *ratelimit.Limiter
+	if cfg.RateLimit.Messages > 0 {
+		messageLimiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore(), cfg.RateLimit.Messages, cfg.RateLimit.Window)
+	}
+	var contentFilter contentfilter.ContentFilter
+	if filterMode := contentfilter.ParseMode(cfg.Content.FilterMode); filterMode != contentfilter.ModeOff {
+		bannedWords := cfg.Content.BannedWords
+		if bannedWords == nil {
+			bannedWords = contentfilter.DefaultWords
+		}
+		contentFilter = contentfilter.NewWordFilter(bannedWords, filterMode)
+	}
+	messageService := service.NewMessageService(messageRepo, roomRepo, userRepo, blockRepo, contentSanitizer, contentFilter, messageLimiter, hub)
+	userService := service.NewUserService(userRepo, blockRepo)
+	scheduledService := service.NewScheduledMessageService(scheduledRepo, roomRepo, messageService)
+
+	// Archive inactive rooms in the background
+	if cfg.Archive.StaleAfter > 0 {
+		archiveService := service.NewArchiveService(roomRepo, hub, cfg.Archive.StaleAfter)
+		go archiveService.Run(cfg.Archive.Interval)
+	}
+
+	// Delete ephemeral messages once they expire
+	expiryService := service.NewExpiryService(messageRepo, hub)
+	go expiryService.Run(cfg.Expiry.Interval)
+
+	// Purge messages past their room's retention window
+	retentionWorker := service.NewRetentionWorker(roomRepo, messageRepo, hub)
+	go retentionWorker.Run(cfg.Retention.Interval)
+
+	// Send scheduled messages once they are due
+	go scheduledService.Run(cfg.Schedule.Interval)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
+	userHandler := handler.NewUserHandler(userService)
 	roomHandler := handler.NewRoomHandler(roomService)
 	messageHandler := handler.NewMessageHandler(messageService)
-	wsHandler := websocket.NewWebSocketHandler(hub)
+	scheduledHandler := handler.NewScheduledMessageHandler(scheduledService)
+	wsHandler := websocket.NewWebSocketHandler(hub, roomRepo)
 
 	// Set gin mode
 	if cfg.Server.Env == "production" {
@@ -84,6 +142,9 @@ func main() {
 		})
 	})
 
+	// Uploaded files
+	router.Static(cfg.Upload.BaseURL, cfg.Upload.UploadDir)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -102,29 +163,46 @@ func main() {
 			// Profile routes
 			protected.GET("/profile", authHandler.GetProfile)
 			protected.PUT("/profile", authHandler.UpdateProfile)
+			protected.POST("/profile/avatar", authHandler.UploadAvatar)
+			protected.PUT("/profile/status", presenceHandler.SetStatus)
 
 			// User search
 			protected.GET("/users/search", authHandler.SearchUsers)
+			protected.GET("/users/:id/presence", presenceHandler.GetPresence)
+
+			// Blocking
+			protected.POST("/users/:id/block", userHandler.Block)
+			protected.DELETE("/users/:id/block", userHandler.Unblock)
+			protected.GET("/blocks", userHandler.ListBlocked)
+			protected.GET("/blocked", userHandler.ListBlocked) // kept for existing clients
 
 			// Room routes
 			rooms := protected.Group("/rooms")
 			{
 				rooms.GET("", roomHandler.GetUserRooms)
+				rooms.GET("/search", roomHandler.SearchUserRooms)
 				rooms.POST("", roomHandler.Create)
 				rooms.GET("/:id", roomHandler.GetByID)
 				rooms.PUT("/:id", roomHandler.Update)
 				rooms.DELETE("/:id", roomHandler.Delete)
 				rooms.POST("/:id/archive", roomHandler.Archive)
 				rooms.POST("/:id/leave", roomHandler.LeaveRoom)
+				rooms.POST("/:id/convert", roomHandler.ConvertToGroup)
 
 				// Room participants
 				rooms.GET("/:id/participants", roomHandler.GetParticipants)
 				rooms.POST("/:id/participants", roomHandler.AddParticipant)
 				rooms.DELETE("/:id/participants/:userId", roomHandler.RemoveParticipant)
+				rooms.PUT("/:id/participants/:userId/role", roomHandler.UpdateParticipantRole)
+				rooms.POST("/:id/participants/:userId/mute", roomHandler.MuteParticipant)
+				rooms.DELETE("/:id/participants/:userId/mute", roomHandler.UnmuteParticipant)
 
 				// Unread count and mark as read
 				rooms.GET("/:id/unread", roomHandler.GetUnreadCount)
 				rooms.POST("/:id/read", roomHandler.MarkAsRead)
+
+				// Mute notifications for the current user
+				rooms.POST("/:id/mute", roomHandler.SetMute)
 			}
 
 			// Direct message
@@ -135,21 +213,46 @@ func main() {
 			{
 				messages.GET("", messageHandler.GetRoomMessages)
 				messages.POST("", messageHandler.Send)
+
+				// Scheduled messages
+				messages.POST("/schedule", scheduledHandler.Schedule)
+				messages.GET("/scheduled", scheduledHandler.List)
+				messages.DELETE("/scheduled/:id", scheduledHandler.Cancel)
 			}
 
+			// Shared images and files, newest first
+			protected.GET("/rooms/:roomId/media", messageHandler.GetRoomMedia)
+
 			protected.GET("/messages/:id", messageHandler.GetByID)
 			protected.PUT("/messages/:id", messageHandler.Update)
 			protected.DELETE("/messages/:id", messageHandler.Delete)
+			protected.GET("/messages/:id/thread", messageHandler.GetThread)
+
+			// Moderation (room admins and creator)
+			moderation := protected.Group("/rooms/:roomId/moderation")
+			{
+				moderation.POST("/bulk-delete", messageHandler.BulkDelete)
+				moderation.DELETE("/users/:userId/messages", messageHandler.DeleteAllFromUser)
+			}
 
 			// Message reactions
+			protected.GET("/messages/:id/reactions", messageHandler.GetReactions)
 			protected.POST("/messages/:id/reactions", messageHandler.AddReaction)
 			protected.DELETE("/messages/:id/reactions", messageHandler.RemoveReaction)
+			protected.GET("/rooms/:roomId/reactions/stats", messageHandler.GetReactionStats)
 
 			// Read receipts
 			protected.POST("/messages/:id/read", messageHandler.MarkAsRead)
+			protected.GET("/messages/:id/read-by", messageHandler.GetReadBy)
+			protected.GET("/rooms/:roomId/read-state", messageHandler.GetReadState)
+			protected.POST("/rooms/:roomId/read-all", messageHandler.MarkAllAsRead)
+
+			// Unread mentions
+			protected.GET("/mentions", messageHandler.GetUnreadMentions)
 
 			// Typing indicator
 			protected.POST("/rooms/:roomId/typing", messageHandler.SendTypingIndicator)
+			protected.GET("/rooms/:roomId/typing", messageHandler.GetTypingUsers)
 
 			// WebSocket endpoint (requires auth)
 			protected.GET("/ws/:roomId", wsHandler.HandleConnection)
@@ -194,6 +297,11 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Hijacked WebSocket connections are not closed by srv.Shutdown
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Printf("WebSocket hub forced to shutdown: %v", err)
+	}
+
 	log.Println("Server exited")
 }
 
@@ -215,6 +323,31 @@ func connectDB(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// newHubBackend returns the backend relaying WebSocket messages between
+// server instances and the counter sharing their users' connections
+func newHubBackend(cfg *config.Config) (websocket.HubBackend, websocket.PresenceCounter, error) {
+	switch cfg.WebSocket.Backend {
+	case "", "memory":
+		return websocket.NewMemoryBackend(), websocket.NewMemoryPresence(), nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address(),
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("failed to connect to redis: %w", err)
+		}
+		return websocket.NewRedisBackend(client, "chat:hub:"), websocket.NewRedisPresence(client, "chat:hub:"), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown hub backend %q: must be memory or redis", cfg.WebSocket.Backend)
+	}
+}
+
 func migrateDB(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&domain.User{},
@@ -223,5 +356,8 @@ func migrateDB(db *gorm.DB) error {
 		&domain.Message{},
 		&domain.MessageReaction{},
 		&domain.ReadReceipt{},
+		&domain.Mention{},
+		&domain.UserBlock{},
+		&domain.ScheduledMessage{},
 	)
 }
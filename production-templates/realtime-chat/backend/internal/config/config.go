@@ -3,17 +3,25 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Auth     AuthConfig
-	Upload   UploadConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Auth      AuthConfig
+	Upload    UploadConfig
+	WebSocket WebSocketConfig
+	Content   ContentConfig
+	Archive   ArchiveConfig
+	Expiry    ExpiryConfig
+	Retention RetentionConfig
+	Schedule  ScheduleConfig
+	RateLimit RateLimitConfig
 }
 
 type ServerConfig struct {
@@ -44,8 +52,46 @@ type AuthConfig struct {
 }
 
 type UploadConfig struct {
-	MaxFileSize int64
-	UploadDir   string
+	MaxFileSize   int64
+	MaxAvatarSize int64
+	UploadDir     string
+	BaseURL       string // path the upload directory is served from
+}
+
+type ContentConfig struct {
+	SanitizeMode string   // "escape" or "markdown"
+	FilterMode   string   // "mask", "reject" or "off"
+	BannedWords  []string // nil for the default word list
+}
+
+type ArchiveConfig struct {
+	StaleAfter time.Duration // 0 disables automatic archival
+	Interval   time.Duration
+}
+
+type ExpiryConfig struct {
+	Interval time.Duration // how often expired ephemeral messages are deleted
+}
+
+type RetentionConfig struct {
+	Interval time.Duration // how often rooms are purged of messages past their retention
+}
+
+type ScheduleConfig struct {
+	Interval time.Duration // how often due scheduled messages are sent
+}
+
+type RateLimitConfig struct {
+	Messages int           // messages a user may send to a room per Window, 0 disables the limit
+	Window   time.Duration
+}
+
+type WebSocketConfig struct {
+	SendBufferSize int           // messages queued per client
+	OverflowPolicy string        // "drop-client" or "drop-oldest"
+	TypingTimeout  time.Duration // typing indicators expire after this long
+	Shards         int           // room shards, 0 for one per CPU
+	Backend        string        // "memory" for a single instance, "redis" to relay across instances
 }
 
 func Load() (*Config, error) {
@@ -76,8 +122,39 @@ func Load() (*Config, error) {
 			RefreshTTL:    parseDuration(getEnv("JWT_REFRESH_TTL", "168h")), // default 7 days
 		},
 		Upload: UploadConfig{
-			MaxFileSize: parseInt64(getEnv("MAX_FILE_SIZE", "10485760")), // default 10MB
-			UploadDir:   getEnv("UPLOAD_DIR", "./uploads"),
+			MaxFileSize:   parseInt64(getEnv("MAX_FILE_SIZE", "10485760")),  // default 10MB
+			MaxAvatarSize: parseInt64(getEnv("MAX_AVATAR_SIZE", "2097152")), // default 2MB
+			UploadDir:     getEnv("UPLOAD_DIR", "./uploads"),
+			BaseURL:       getEnv("UPLOAD_BASE_URL", "/uploads"),
+		},
+		WebSocket: WebSocketConfig{
+			SendBufferSize: parsePositiveInt(getEnv("WS_SEND_BUFFER_SIZE", "256"), 256),
+			OverflowPolicy: getEnv("WS_OVERFLOW_POLICY", "drop-client"),
+			TypingTimeout:  parseOptionalDuration(getEnv("WS_TYPING_TIMEOUT", "5s")),
+			Shards:         parsePositiveInt(getEnv("WS_HUB_SHARDS", "0"), 0),
+			Backend:        getEnv("WS_HUB_BACKEND", "memory"),
+		},
+		Content: ContentConfig{
+			SanitizeMode: getEnv("CONTENT_SANITIZE_MODE", "escape"),
+			FilterMode:   getEnv("CONTENT_FILTER_MODE", "mask"),
+			BannedWords:  parseList(getEnv("CONTENT_BANNED_WORDS", "")),
+		},
+		Archive: ArchiveConfig{
+			StaleAfter: parseOptionalDuration(getEnv("AUTO_ARCHIVE_AFTER", "0")),
+			Interval:   parseDuration(getEnv("AUTO_ARCHIVE_INTERVAL", "1h")),
+		},
+		Expiry: ExpiryConfig{
+			Interval: parseDuration(getEnv("MESSAGE_EXPIRY_INTERVAL", "30s")),
+		},
+		Retention: RetentionConfig{
+			Interval: parseDuration(getEnv("MESSAGE_RETENTION_INTERVAL", "1h")),
+		},
+		Schedule: ScheduleConfig{
+			Interval: parseDuration(getEnv("SCHEDULED_MESSAGE_INTERVAL", "10s")),
+		},
+		RateLimit: RateLimitConfig{
+			Messages: parseNonNegativeInt(getEnv("MESSAGE_RATE_LIMIT", "20"), 20),
+			Window:   parseDuration(getEnv("MESSAGE_RATE_WINDOW", "10s")),
 		},
 	}
 
@@ -110,6 +187,26 @@ func parseDuration(s string) time.Duration {
 	return d
 }
 
+// parseOptionalDuration returns 0 when s is empty, zero or malformed.
+func parseOptionalDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// parseList splits a comma-separated list, returning nil when it is empty
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseInt(s string) int {
 	var i int
 	fmt.Sscanf(s, "%d", &i)
@@ -127,3 +224,21 @@ func parseInt64(s string) int64 {
 	}
 	return i
 }
+
+func parsePositiveInt(s string, defaultValue int) int {
+	var i int
+	fmt.Sscanf(s, "%d", &i)
+	if i <= 0 {
+		return defaultValue
+	}
+	return i
+}
+
+// parseNonNegativeInt returns defaultValue when s is malformed or negative.
+func parseNonNegativeInt(s string, defaultValue int) int {
+	var i int
+	if _, err := fmt.Sscanf(s, "%d", &i); err != nil || i < 0 {
+		return defaultValue
+	}
+	return i
+}
@@ -0,0 +1,104 @@
+package contentfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mode selects what happens to messages containing banned words
+type Mode string
+
+const (
+	// ModeOff leaves messages untouched
+	ModeOff Mode = "off"
+
+	// ModeMask replaces every letter of a banned word with an asterisk
+	ModeMask Mode = "mask"
+
+	// ModeReject refuses the whole message
+	ModeReject Mode = "reject"
+)
+
+// ParseMode converts a configuration value to a Mode, falling back to
+// ModeMask for anything unrecognized.
+func ParseMode(s string) Mode {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case ModeOff:
+		return ModeOff
+	case ModeReject:
+		return ModeReject
+	default:
+		return ModeMask
+	}
+}
+
+// DefaultWords are banned when no word list is configured
+var DefaultWords = []string{
+	"arsehole",
+	"asshole",
+	"bastard",
+	"bitch",
+	"bullshit",
+	"cunt",
+	"fuck",
+	"motherfucker",
+	"shit",
+	"wanker",
+}
+
+// ContentFilter checks user-written message content before it is stored
+type ContentFilter interface {
+	// Filter returns the content to store in place of content, or a
+	// *RejectedError when the message must not be stored at all
+	Filter(content string) (string, error)
+}
+
+// RejectedError refuses a message containing a banned word
+type RejectedError struct {
+	Word string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("message contains the banned word %q", e.Word)
+}
+
+// WordFilter matches banned words case-insensitively and only as whole
+// words, so "class" is not caught by "ass"
+type WordFilter struct {
+	mode    Mode
+	pattern *regexp.Regexp // nil when no words are banned
+}
+
+// NewWordFilter returns a filter banning words. Empty entries are ignored.
+func NewWordFilter(words []string, mode Mode) *WordFilter {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+
+	f := &WordFilter{mode: mode}
+	if len(quoted) > 0 {
+		f.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return f
+}
+
+func (f *WordFilter) Filter(content string) (string, error) {
+	if f.mode == ModeOff || f.pattern == nil {
+		return content, nil
+	}
+
+	if f.mode == ModeReject {
+		if word := f.pattern.FindString(content); word != "" {
+			return "", &RejectedError{Word: word}
+		}
+		return content, nil
+	}
+
+	return f.pattern.ReplaceAllStringFunc(content, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	}), nil
+}
@@ -0,0 +1,55 @@
+package contentfilter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWordFilter_Mask(t *testing.T) {
+	f := NewWordFilter([]string{"darn", "heck", " "}, ModeMask)
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "well darn it", want: "well **** it"},
+		{content: "DARN, what the Heck!", want: "****, what the ****!"},
+		{content: "darning socks in Heckington", want: "darning socks in Heckington"},
+		{content: "nothing to see", want: "nothing to see"},
+	}
+
+	for _, tt := range tests {
+		got, err := f.Filter(tt.content)
+		if err != nil {
+			t.Fatalf("Filter(%q) error = %v", tt.content, err)
+		}
+		if got != tt.want {
+			t.Errorf("Filter(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestWordFilter_Reject(t *testing.T) {
+	f := NewWordFilter([]string{"darn"}, ModeReject)
+
+	_, err := f.Filter("oh Darn")
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("Filter() error = %v, want a *RejectedError", err)
+	}
+	if rejected.Word != "Darn" {
+		t.Errorf("rejected word = %q, want %q", rejected.Word, "Darn")
+	}
+
+	if got, err := f.Filter("all clean"); err != nil || got != "all clean" {
+		t.Errorf("Filter(clean content) = %q, %v, want it unchanged", got, err)
+	}
+}
+
+func TestWordFilter_Off(t *testing.T) {
+	for _, f := range []*WordFilter{NewWordFilter(DefaultWords, ModeOff), NewWordFilter(nil, ModeReject)} {
+		if got, err := f.Filter("shit happens"); err != nil || got != "shit happens" {
+			t.Errorf("Filter() = %q, %v, want content left alone", got, err)
+		}
+	}
+}
@@ -28,12 +28,26 @@ type Message struct {
 	EditedAt        *time.Time        `json:"edited_at"`
 	IsDeleted       bool              `json:"is_deleted" gorm:"not null;default:false"`
 	DeletedAt       *time.Time        `json:"deleted_at"`
+	DeletedByID     *uint             `json:"deleted_by_id,omitempty"` // Visible to room moderators only
+	ExpiresAt       *time.Time        `json:"expires_at"`                // Ephemeral messages are deleted after this time
 	Reactions       []MessageReaction `json:"reactions,omitempty" gorm:"foreignKey:MessageID"`
+	ReactionCounts  []ReactionCount   `json:"reaction_counts" gorm:"-"` // Calculated field: reactions per emoji
 	ReadReceipts    []ReadReceipt     `json:"read_receipts,omitempty" gorm:"foreignKey:MessageID"`
+	Mentions        []Mention         `json:"mentions,omitempty" gorm:"foreignKey:MessageID"`
+	ReplyCount      int64             `json:"reply_count" gorm:"-"` // Calculated field: direct replies not deleted
 	CreatedAt       time.Time         `json:"created_at" gorm:"index"`
 	UpdatedAt       time.Time         `json:"updated_at"`
 }
 
+// HideModeration strips moderation details that only room admins and the
+// room creator may see, from the message and the message it replies to
+func (m *Message) HideModeration() {
+	m.DeletedByID = nil
+	if m.ReplyTo != nil {
+		m.ReplyTo.DeletedByID = nil
+	}
+}
+
 type MessageReaction struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	MessageID uint      `json:"message_id" gorm:"not null;uniqueIndex:idx_message_user_reaction"`
@@ -43,6 +57,39 @@ type MessageReaction struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// ReactionSummary groups a message's reactions with one emoji. ReactedByMe
+// tells whether the requesting user is among UserIDs.
+type ReactionSummary struct {
+	Emoji       string  `json:"emoji"`
+	Count       int     `json:"count"`
+	UserIDs     []uint  `json:"user_ids"`
+	Users       []*User `json:"users"`
+	ReactedByMe bool    `json:"reacted_by_me"`
+}
+
+// ReactionCount is how many users reacted to a message with one emoji, as
+// shown with the message. ReactedByMe tells whether the requesting user did.
+type ReactionCount struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+// ReactionStat is the number of times an emoji was used in a room
+type ReactionStat struct {
+	Emoji string `json:"emoji"`
+	Count int64  `json:"count"`
+}
+
+// RoomReactionStats ranks the emojis used in a room, most used first. Since
+// and Until echo the requested time window.
+type RoomReactionStats struct {
+	RoomID    uint            `json:"room_id"`
+	Since     *time.Time      `json:"since,omitempty"`
+	Until     *time.Time      `json:"until,omitempty"`
+	TopEmojis []*ReactionStat `json:"top_emojis"`
+}
+
 type ReadReceipt struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	MessageID uint      `json:"message_id" gorm:"not null;uniqueIndex:idx_message_user_read"`
@@ -51,20 +98,86 @@ type ReadReceipt struct {
 	ReadAt    time.Time `json:"read_at"`
 }
 
+// ParticipantReadState is how far a participant has read a room, for "seen"
+// indicators. LastReadMessageID is nil until they read a message.
+type ParticipantReadState struct {
+	UserID            uint      `json:"user_id"`
+	LastReadMessageID *uint     `json:"last_read_message_id"`
+	LastReadAt        time.Time `json:"last_read_at"`
+}
+
+// Mention records that a message mentioned a room participant by @username.
+// It counts as unread until the user reads the room past the message.
+type Mention struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	MessageID uint      `json:"message_id" gorm:"not null;uniqueIndex:idx_message_user_mention"`
+	Message   *Message  `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+	RoomID    uint      `json:"room_id" gorm:"not null;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_message_user_mention;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledMessagePending ScheduledMessageStatus = "pending"
+	ScheduledMessageSending ScheduledMessageStatus = "sending" // Claimed by a worker
+	ScheduledMessageSent    ScheduledMessageStatus = "sent"
+	ScheduledMessageFailed  ScheduledMessageStatus = "failed"
+)
+
+// ScheduledMessage is a message to be sent to a room at SendAt on behalf of
+// its sender. MessageID is the message it became once sent; Error tells why
+// sending failed.
+type ScheduledMessage struct {
+	ID        uint                   `json:"id" gorm:"primaryKey"`
+	RoomID    uint                   `json:"room_id" gorm:"not null;index"`
+	SenderID  uint                   `json:"sender_id" gorm:"not null"`
+	Sender    *User                  `json:"sender,omitempty" gorm:"foreignKey:SenderID"`
+	Type      MessageType            `json:"type" gorm:"not null;default:'text'"`
+	Content   string                 `json:"content" gorm:"not null"`
+	ReplyToID *uint                  `json:"reply_to_id"`
+	SendAt    time.Time              `json:"send_at" gorm:"not null;index:idx_scheduled_messages_due,priority:2"`
+	Status    ScheduledMessageStatus `json:"status" gorm:"not null;default:'pending';index:idx_scheduled_messages_due,priority:1"`
+	MessageID *uint                  `json:"message_id,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	SentAt    *time.Time             `json:"sent_at,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+type ScheduleMessageRequest struct {
+	Content   string      `json:"content" binding:"required"`
+	Type      MessageType `json:"type"`
+	ReplyToID *uint       `json:"reply_to_id"`
+	SendAt    time.Time   `json:"send_at" binding:"required"` // Must be in the future
+}
+
 type SendMessageRequest struct {
 	Content   string      `json:"content"`
 	Type      MessageType `json:"type"`
 	ReplyToID *uint       `json:"reply_to_id"`
+	ExpiresAt *time.Time  `json:"expires_at"` // Optional; must be in the future
 }
 
 type UpdateMessageRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
+type BulkDeleteMessagesRequest struct {
+	MessageIDs []uint `json:"message_ids" binding:"required,min=1,max=100"`
+}
+
 type AddReactionRequest struct {
 	Emoji string `json:"emoji" binding:"required"`
 }
 
+// TypingUser is a user currently typing in a room
+type TypingUser struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
 type TypingIndicator struct {
 	RoomID    uint      `json:"room_id"`
 	UserID    uint      `json:"user_id"`
@@ -11,18 +11,82 @@ const (
 )
 
 type Room struct {
-	ID           uint          `json:"id" gorm:"primaryKey"`
-	Name         string        `json:"name"`
-	Description  string        `json:"description"`
-	Type         RoomType      `json:"type" gorm:"not null;default:'group'"`
-	AvatarURL    string        `json:"avatar_url"`
-	CreatorID    uint          `json:"creator_id" gorm:"not null"`
-	Creator      *User         `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
-	Participants []Participant `json:"participants,omitempty" gorm:"foreignKey:RoomID"`
-	LastMessage  *Message      `json:"last_message,omitempty" gorm:"-"` // Not stored in DB, loaded separately
-	IsArchived   bool          `json:"is_archived" gorm:"not null;default:false"`
-	CreatedAt    time.Time     `json:"created_at"`
-	UpdatedAt    time.Time     `json:"updated_at"`
+	ID                uint              `json:"id" gorm:"primaryKey"`
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	Type              RoomType          `json:"type" gorm:"not null;default:'group'"`
+	AvatarURL         string            `json:"avatar_url"`
+	CreatorID         uint              `json:"creator_id" gorm:"not null"`
+	Creator           *User             `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
+	Participants      []Participant     `json:"participants,omitempty" gorm:"foreignKey:RoomID"`
+	LastMessage       *Message          `json:"last_message,omitempty" gorm:"-"` // Not stored in DB, loaded separately
+	IsArchived        bool              `json:"is_archived" gorm:"not null;default:false"`
+	NeverAutoArchive  bool              `json:"never_auto_archive" gorm:"not null;default:false"` // Exempt from archival of inactive rooms
+	HistoryVisibility HistoryVisibility `json:"history_visibility" gorm:"not null;default:'full'"`
+	RetentionDays     *int              `json:"retention_days"` // Messages older than this are purged; nil keeps them forever
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// HistoryVisibility decides which of a room's messages its participants can
+// read
+type HistoryVisibility string
+
+const (
+	HistoryVisibilityFull      HistoryVisibility = "full"       // The whole backlog
+	HistoryVisibilitySinceJoin HistoryVisibility = "since_join" // Only messages sent after the participant joined
+)
+
+// Valid reports whether v is a known visibility; empty counts as the default
+func (v HistoryVisibility) Valid() bool {
+	switch v {
+	case "", HistoryVisibilityFull, HistoryVisibilitySinceJoin:
+		return true
+	}
+	return false
+}
+
+// ParticipantRole decides what a participant may do in a room. Each role may
+// do everything the roles below it can:
+//   - owner: the room's creator; may also delete the room
+//   - admin: updates and archives the room and manages participants
+//   - moderator: deletes and moderates other participants' messages
+//   - member: reads and sends messages
+type ParticipantRole string
+
+const (
+	ParticipantRoleOwner     ParticipantRole = "owner"
+	ParticipantRoleAdmin     ParticipantRole = "admin"
+	ParticipantRoleModerator ParticipantRole = "moderator"
+	ParticipantRoleMember    ParticipantRole = "member"
+)
+
+func (r ParticipantRole) rank() int {
+	switch r {
+	case ParticipantRoleOwner:
+		return 4
+	case ParticipantRoleAdmin:
+		return 3
+	case ParticipantRoleModerator:
+		return 2
+	case ParticipantRoleMember:
+		return 1
+	}
+	return 0
+}
+
+func (r ParticipantRole) Valid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast reports whether r is the given role or ranks above it
+func (r ParticipantRole) AtLeast(role ParticipantRole) bool {
+	return r.Valid() && r.rank() >= role.rank()
+}
+
+// Outranks reports whether r ranks strictly above the given role
+func (r ParticipantRole) Outranks(role ParticipantRole) bool {
+	return r.rank() > role.rank()
 }
 
 type Participant struct {
@@ -30,28 +94,51 @@ type Participant struct {
 	RoomID       uint      `json:"room_id" gorm:"not null;uniqueIndex:idx_room_user"`
 	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_room_user"`
 	User         *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Role         string    `json:"role" gorm:"not null;default:'member'"` // admin, member
-	IsMuted      bool      `json:"is_muted" gorm:"not null;default:false"`
+	Role         ParticipantRole `json:"role" gorm:"not null;default:'member'"`
+	IsMuted      bool      `json:"is_muted" gorm:"not null;default:false"` // Silences notifications for this user only
+	IsSilenced   bool      `json:"is_silenced" gorm:"not null;default:false"` // Set by room admins; stops the user sending
 	LastReadAt   time.Time `json:"last_read_at"`
 	UnreadCount  int       `json:"unread_count" gorm:"-"` // Calculated field
+	MentionCount int       `json:"mention_count" gorm:"-"` // Calculated field
 	JoinedAt     time.Time `json:"joined_at"`
 	LeftAt       *time.Time `json:"left_at"`
 }
 
 type CreateRoomRequest struct {
-	Name        string   `json:"name" binding:"required"`
-	Description string   `json:"description"`
-	Type        RoomType `json:"type" binding:"required"`
-	UserIDs     []uint   `json:"user_ids"` // Initial participants
+	Name              string            `json:"name" binding:"required"`
+	Description       string            `json:"description"`
+	Type              RoomType          `json:"type" binding:"required"`
+	UserIDs           []uint            `json:"user_ids"`           // Initial participants
+	HistoryVisibility HistoryVisibility `json:"history_visibility"` // Defaults to full
 }
 
 type UpdateRoomRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	AvatarURL   string `json:"avatar_url"`
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	AvatarURL         string            `json:"avatar_url"`
+	NeverAutoArchive  *bool             `json:"never_auto_archive"`
+	HistoryVisibility HistoryVisibility `json:"history_visibility"`
+	RetentionDays     *int              `json:"retention_days"` // 0 keeps messages forever
+}
+
+// AutoArchiveNotice is broadcast when an inactive room is archived
+// automatically. AdminIDs lists the room's creator and admins, who are the
+// ones expected to act on it.
+type AutoArchiveNotice struct {
+	RoomID        uint      `json:"room_id"`
+	InactiveSince time.Time `json:"inactive_since"`
+	AdminIDs      []uint    `json:"admin_ids"`
+}
+
+type MuteRoomRequest struct {
+	Muted bool `json:"muted"`
 }
 
 type AddParticipantRequest struct {
 	UserID uint   `json:"user_id" binding:"required"`
-	Role   string `json:"role"` // admin or member
+	Role   ParticipantRole `json:"role"` // Defaults to member
+}
+
+type UpdateParticipantRoleRequest struct {
+	Role ParticipantRole `json:"role" binding:"required"`
 }
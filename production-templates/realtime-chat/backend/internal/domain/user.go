@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"io"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,6 +16,14 @@ const (
 	StatusOffline UserStatus = "offline"
 )
 
+func (s UserStatus) Valid() bool {
+	switch s {
+	case StatusOnline, StatusAway, StatusBusy, StatusOffline:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID           uint       `json:"id" gorm:"primaryKey"`
 	Email        string     `json:"email" gorm:"uniqueIndex;not null"`
@@ -22,6 +31,8 @@ type User struct {
 	Username     string     `json:"username" gorm:"uniqueIndex;not null"`
 	DisplayName  string     `json:"display_name"`
 	AvatarURL    string     `json:"avatar_url"`
+	AvatarKey    string     `json:"-"` // Storage key of an uploaded avatar
+	Bio          string     `json:"bio"`
 	Status       UserStatus `json:"status" gorm:"not null;default:'offline'"`
 	LastSeenAt   *time.Time `json:"last_seen_at"`
 	IsActive     bool       `json:"is_active" gorm:"not null;default:true"`
@@ -48,12 +59,40 @@ type AuthResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 }
 
+// UpdateProfileRequest changes the fields that are set. Avatars are uploaded
+// separately.
 type UpdateProfileRequest struct {
 	DisplayName string     `json:"display_name"`
-	AvatarURL   string     `json:"avatar_url"`
+	Bio         *string    `json:"bio"` // An empty bio clears it
 	Status      UserStatus `json:"status"`
 }
 
+// UserBlock records that BlockerID blocked BlockedID. Neither can open or
+// message a direct room with the other while it exists.
+type UserBlock struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	BlockerID uint      `json:"blocker_id" gorm:"not null;uniqueIndex:idx_blocker_blocked"`
+	BlockedID uint      `json:"blocked_id" gorm:"not null;uniqueIndex:idx_blocker_blocked;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Presence is what other users see of whether a user is around
+type Presence struct {
+	UserID     uint       `json:"user_id"`
+	Status     UserStatus `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+type UpdateStatusRequest struct {
+	Status UserStatus `json:"status" binding:"required"` // online, away or busy
+}
+
+// AvatarUpload is an image uploaded as a user's avatar
+type AvatarUpload struct {
+	Size    int64 // As declared by the client
+	Content io.Reader
+}
+
 type JWTClaims struct {
 	UserID   uint   `json:"user_id"`
 	Email    string `json:"email"`
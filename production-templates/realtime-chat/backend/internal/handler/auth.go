@@ -99,6 +99,34 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+func (h *AuthHandler) UploadAvatar(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "avatar file is required"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	user, err := h.authService.UploadAvatar(userID, &domain.AvatarUpload{
+		Size:    fileHeader.Size,
+		Content: file,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
 func (h *AuthHandler) SearchUsers(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
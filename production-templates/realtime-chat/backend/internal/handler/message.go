@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -35,6 +38,12 @@ func (h *MessageHandler) Send(c *gin.Context) {
 
 	message, err := h.messageService.Send(uint(roomID), userID, &req)
 	if err != nil {
+		var rateLimitErr *service.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -59,6 +68,39 @@ func (h *MessageHandler) GetByID(c *gin.Context) {
 	c.JSON(http.StatusOK, message)
 }
 
+func (h *MessageHandler) GetThread(c *gin.Context) {
+	userID := c.GetUint("userID")
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return
+	}
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var l int
+		if _, err := fmt.Sscanf(limitStr, "%d", &l); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		var o int
+		if _, err := fmt.Sscanf(offsetStr, "%d", &o); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	thread, err := h.messageService.GetThread(uint(messageID), userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
 func (h *MessageHandler) GetRoomMessages(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
@@ -83,7 +125,54 @@ func (h *MessageHandler) GetRoomMessages(c *gin.Context) {
 		}
 	}
 
-	messages, err := h.messageService.GetRoomMessages(uint(roomID), userID, limit, offset)
+	// Cursor pagination: fetch messages older than this message ID
+	var before uint
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		b, err := strconv.ParseUint(beforeStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before message ID"})
+			return
+		}
+		before = uint(b)
+	}
+
+	messages, err := h.messageService.GetRoomMessages(uint(roomID), userID, limit, offset, before)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *MessageHandler) GetRoomMedia(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var l int
+		if _, err := fmt.Sscanf(limitStr, "%d", &l); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	// Cursor pagination: fetch media older than this message ID
+	var before uint
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		b, err := strconv.ParseUint(beforeStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before message ID"})
+			return
+		}
+		before = uint(b)
+	}
+
+	messages, err := h.messageService.GetRoomMedia(uint(roomID), userID, limit, before)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -131,6 +220,51 @@ func (h *MessageHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "message deleted successfully"})
 }
 
+func (h *MessageHandler) BulkDelete(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	var req domain.BulkDeleteMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.messageService.BulkDelete(uint(roomID), userID, req.MessageIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": req.MessageIDs})
+}
+
+func (h *MessageHandler) DeleteAllFromUser(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	targetUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	deleted, err := h.messageService.DeleteAllFromUser(uint(roomID), userID, uint(targetUserID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
 func (h *MessageHandler) AddReaction(c *gin.Context) {
 	userID := c.GetUint("userID")
 	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -175,6 +309,97 @@ func (h *MessageHandler) RemoveReaction(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "reaction removed successfully"})
 }
 
+func (h *MessageHandler) GetReactions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return
+	}
+
+	summary, err := h.messageService.GetReactionSummary(uint(messageID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *MessageHandler) GetReactionStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var l int
+		if _, err := fmt.Sscanf(limitStr, "%d", &l); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	// Optional time window in RFC 3339, e.g. ?since=2024-05-01T00:00:00Z
+	window := make(map[string]*time.Time, 2)
+	for _, param := range []string{"since", "until"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid %s time, expected RFC 3339", param)})
+			return
+		}
+		window[param] = &t
+	}
+
+	stats, err := h.messageService.GetReactionStats(uint(roomID), userID, window["since"], window["until"], limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *MessageHandler) GetReadBy(c *gin.Context) {
+	userID := c.GetUint("userID")
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message ID"})
+		return
+	}
+
+	receipts, err := h.messageService.GetReadBy(uint(messageID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipts)
+}
+
+func (h *MessageHandler) GetReadState(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	states, err := h.messageService.GetReadState(uint(roomID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, states)
+}
+
 func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	userID := c.GetUint("userID")
 	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -191,6 +416,22 @@ func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "marked as read"})
 }
 
+func (h *MessageHandler) MarkAllAsRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	if err := h.messageService.MarkAllAsRead(uint(roomID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "marked all as read"})
+}
+
 func (h *MessageHandler) SendTypingIndicator(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
@@ -214,3 +455,32 @@ func (h *MessageHandler) SendTypingIndicator(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "typing indicator sent"})
 }
+
+func (h *MessageHandler) GetTypingUsers(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	typing, err := h.messageService.GetTypingUsers(uint(roomID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, typing)
+}
+
+func (h *MessageHandler) GetUnreadMentions(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	mentions, err := h.messageService.GetUnreadMentions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mentions)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/service"
+)
+
+type PresenceHandler struct {
+	presenceService service.PresenceService
+}
+
+func NewPresenceHandler(presenceService service.PresenceService) *PresenceHandler {
+	return &PresenceHandler{presenceService: presenceService}
+}
+
+func (h *PresenceHandler) GetPresence(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	presence, err := h.presenceService.GetPresence(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
+func (h *PresenceHandler) SetStatus(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req domain.UpdateStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	presence, err := h.presenceService.SetStatus(userID, req.Status)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
@@ -55,8 +55,9 @@ func (h *RoomHandler) GetByID(c *gin.Context) {
 
 func (h *RoomHandler) GetUserRooms(c *gin.Context) {
 	userID := c.GetUint("userID")
+	unreadOnly := c.Query("unread") == "true"
 
-	rooms, err := h.roomService.GetUserRooms(userID)
+	rooms, err := h.roomService.GetUserRooms(userID, unreadOnly)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -65,6 +66,23 @@ func (h *RoomHandler) GetUserRooms(c *gin.Context) {
 	c.JSON(http.StatusOK, rooms)
 }
 
+func (h *RoomHandler) SearchUserRooms(c *gin.Context) {
+	userID := c.GetUint("userID")
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter 'q' is required"})
+		return
+	}
+
+	rooms, err := h.roomService.SearchUserRooms(userID, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rooms)
+}
+
 func (h *RoomHandler) Update(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -120,6 +138,23 @@ func (h *RoomHandler) Archive(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "room archived successfully"})
 }
 
+func (h *RoomHandler) ConvertToGroup(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	room, err := h.roomService.ConvertToGroup(uint(roomID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
+
 func (h *RoomHandler) AddParticipant(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -164,6 +199,65 @@ func (h *RoomHandler) RemoveParticipant(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "participant removed successfully"})
 }
 
+func (h *RoomHandler) UpdateParticipantRole(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	participantUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req domain.UpdateParticipantRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	participant, err := h.roomService.UpdateParticipantRole(uint(roomID), uint(participantUserID), userID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, participant)
+}
+
+func (h *RoomHandler) MuteParticipant(c *gin.Context) {
+	h.setParticipantMuted(c, true)
+}
+
+func (h *RoomHandler) UnmuteParticipant(c *gin.Context) {
+	h.setParticipantMuted(c, false)
+}
+
+func (h *RoomHandler) setParticipantMuted(c *gin.Context, mute bool) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	participantUserID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.roomService.MuteParticipant(uint(roomID), uint(participantUserID), userID, mute); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": participantUserID, "muted": mute})
+}
+
 func (h *RoomHandler) LeaveRoom(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -234,6 +328,28 @@ func (h *RoomHandler) GetUnreadCount(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"unread_count": count})
 }
 
+func (h *RoomHandler) SetMute(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	var req domain.MuteRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.roomService.SetParticipantMute(uint(roomID), userID, req.Muted); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"muted": req.Muted})
+}
+
 func (h *RoomHandler) MarkAsRead(c *gin.Context) {
 	userID := c.GetUint("userID")
 	roomID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/service"
+)
+
+type ScheduledMessageHandler struct {
+	scheduledService service.ScheduledMessageService
+}
+
+func NewScheduledMessageHandler(scheduledService service.ScheduledMessageService) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{scheduledService: scheduledService}
+}
+
+func (h *ScheduledMessageHandler) Schedule(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	var req domain.ScheduleMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheduled, err := h.scheduledService.Schedule(uint(roomID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, scheduled)
+}
+
+func (h *ScheduledMessageHandler) List(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+
+	scheduled, err := h.scheduledService.List(uint(roomID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduled)
+}
+
+func (h *ScheduledMessageHandler) Cancel(c *gin.Context) {
+	userID := c.GetUint("userID")
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room ID"})
+		return
+	}
+	scheduledID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled message ID"})
+		return
+	}
+
+	if err := h.scheduledService.Cancel(uint(roomID), uint(scheduledID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "scheduled message cancelled"})
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets of a Limiter. Implementations must be safe
+// for concurrent use.
+type Store interface {
+	// Take removes a token from the bucket named key, which holds up to
+	// limit tokens and refills completely over window. When the bucket is
+	// empty it returns false and how long until the next token.
+	Take(key string, limit int, window time.Duration, now time.Time) (bool, time.Duration, error)
+}
+
+// Limiter allows up to limit events per window for each key, with a token
+// bucket so that a burst of limit events is let through at once
+type Limiter struct {
+	store  Store
+	limit  int
+	window time.Duration
+}
+
+func NewLimiter(store Store, limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		store:  store,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow takes one of key's tokens. When none is left it returns false and
+// how long until one is.
+func (l *Limiter) Allow(key string) (bool, time.Duration, error) {
+	ok, retryAfter, err := l.store.Take(key, l.limit, l.window, time.Now())
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	return ok, retryAfter, nil
+}
+
+// sweepInterval is how often MemoryStore forgets buckets that are full again
+const sweepInterval = time.Minute
+
+// MemoryStore keeps token buckets in memory. It is the default store, for
+// running a single server instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	window  time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit int, window time.Duration, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	rate := float64(limit) / float64(window) // tokens per nanosecond
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), updated: now, window: window}
+		s.buckets[key] = b
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(float64(limit), b.tokens+float64(elapsed)*rate)
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / rate), nil
+}
+
+// sweep drops the buckets that have refilled completely, which behave the
+// same as missing ones
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) >= b.window {
+			delete(s.buckets, key)
+		}
+	}
+}
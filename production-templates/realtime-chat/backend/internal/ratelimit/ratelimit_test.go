@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	window := 10 * time.Second
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := store.Take("alice", 3, window, now); !ok {
+			t.Fatalf("Take() #%d = false, want a burst of 3 allowed", i+1)
+		}
+	}
+
+	ok, retryAfter, err := store.Take("alice", 3, window, now)
+	if err != nil || ok {
+		t.Fatalf("Take() #4 = %v, %v, want it rejected", ok, err)
+	}
+	if want := window / 3; retryAfter != want {
+		t.Errorf("retryAfter = %v, want %v for one token", retryAfter, want)
+	}
+
+	// Keys have their own buckets
+	if ok, _, _ := store.Take("bob", 3, window, now); !ok {
+		t.Error("Take() for another key = false, want true")
+	}
+
+	// One token is back after a third of the window, and only one
+	later := now.Add(window/3 + time.Millisecond)
+	if ok, _, _ := store.Take("alice", 3, window, later); !ok {
+		t.Error("Take() after refilling one token = false, want true")
+	}
+	if ok, _, _ := store.Take("alice", 3, window, later); ok {
+		t.Error("Take() after using the refilled token = true, want false")
+	}
+
+	// Full buckets are forgotten
+	store.Take("carol", 3, window, later.Add(sweepInterval))
+	if len(store.buckets) != 1 {
+		t.Errorf("%d buckets kept after they refilled, want only carol's", len(store.buckets))
+	}
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"realtime-chat/internal/domain"
+)
+
+type BlockRepository interface {
+	// Create records the block; blocking someone twice is not an error
+	Create(block *domain.UserBlock) error
+	Delete(blockerID, blockedID uint) error
+	// ExistsBetween reports whether either user has blocked the other
+	ExistsBetween(userID, otherUserID uint) (bool, error)
+	// FindBlockedUsers returns the users the blocker has blocked, most
+	// recently blocked first
+	FindBlockedUsers(blockerID uint) ([]*domain.User, error)
+}
+
+type blockRepository struct {
+	db *gorm.DB
+}
+
+func NewBlockRepository(db *gorm.DB) BlockRepository {
+	return &blockRepository{db: db}
+}
+
+func (r *blockRepository) Create(block *domain.UserBlock) error {
+	// Check if block already exists
+	var existing domain.UserBlock
+	err := r.db.Where("blocker_id = ? AND blocked_id = ?", block.BlockerID, block.BlockedID).
+		First(&existing).Error
+
+	if err == nil {
+		// Already blocked
+		*block = existing
+		return nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to check existing block: %w", err)
+	}
+
+	if err := r.db.Create(block).Error; err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	return nil
+}
+
+func (r *blockRepository) Delete(blockerID, blockedID uint) error {
+	if err := r.db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&domain.UserBlock{}).Error; err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	return nil
+}
+
+func (r *blockRepository) ExistsBetween(userID, otherUserID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)",
+			userID, otherUserID, otherUserID, userID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check blocks: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *blockRepository) FindBlockedUsers(blockerID uint) ([]*domain.User, error) {
+	var users []*domain.User
+	err := r.db.Joins("JOIN user_blocks ON user_blocks.blocked_id = users.id").
+		Where("user_blocks.blocker_id = ?", blockerID).
+		Order("user_blocks.created_at DESC, user_blocks.id DESC").
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blocked users: %w", err)
+	}
+	return users, nil
+}
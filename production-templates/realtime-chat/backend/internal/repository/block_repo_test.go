@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+
+	"realtime-chat/internal/domain"
+)
+
+func TestBlockRepository(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewBlockRepository(db)
+
+	var users []*domain.User
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &domain.User{Email: name + "@example.com", Username: name, PasswordHash: "x"}
+		db.Create(user)
+		users = append(users, user)
+	}
+	alice, bob, carol := users[0], users[1], users[2]
+
+	for _, blocked := range []*domain.User{bob, carol, bob} {
+		if err := repo.Create(&domain.UserBlock{BlockerID: alice.ID, BlockedID: blocked.ID}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	// Blocking twice keeps a single block
+	var count int64
+	db.Model(&domain.UserBlock{}).Count(&count)
+	if count != 2 {
+		t.Errorf("%d blocks stored, want 2", count)
+	}
+
+	if exists, _ := repo.ExistsBetween(bob.ID, alice.ID); !exists {
+		t.Error("ExistsBetween() should see a block in either direction")
+	}
+	if exists, _ := repo.ExistsBetween(bob.ID, carol.ID); exists {
+		t.Error("ExistsBetween() reported a block between users who blocked no one")
+	}
+
+	blocked, err := repo.FindBlockedUsers(alice.ID)
+	if err != nil {
+		t.Fatalf("FindBlockedUsers() error = %v", err)
+	}
+	if len(blocked) != 2 || blocked[0].ID != carol.ID || blocked[1].ID != bob.ID {
+		t.Errorf("FindBlockedUsers() returned %d users, want carol then bob", len(blocked))
+	}
+
+	if err := repo.Delete(alice.ID, bob.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if exists, _ := repo.ExistsBetween(alice.ID, bob.ID); exists {
+		t.Error("ExistsBetween() should be false after unblocking")
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -12,20 +13,55 @@ import (
 type MessageRepository interface {
 	Create(message *domain.Message) error
 	FindByID(id uint) (*domain.Message, error)
-	FindByRoomID(roomID uint, limit, offset int) ([]*domain.Message, error)
+	// The room listings skip messages created before since; pass the zero
+	// time for the full history
+	FindByRoomID(roomID uint, since time.Time, limit, offset int) ([]*domain.Message, error)
+	FindByRoomIDBefore(roomID uint, since time.Time, beforeMessageID uint, limit int) ([]*domain.Message, error)
+	FindMediaByRoomID(roomID uint, since time.Time, beforeMessageID uint, limit int) ([]*domain.Message, error)
 	Update(message *domain.Message) error
-	SoftDelete(messageID uint) error
+	SoftDelete(messageID, deletedByID uint, deletedAt time.Time) error
+	BulkSoftDelete(roomID uint, messageIDs []uint, deletedByID uint, deletedAt time.Time) error
+	SoftDeleteBySender(roomID, senderID, deletedByID uint, deletedAt time.Time) ([]uint, error)
 	GetLastMessage(roomID uint) (*domain.Message, error)
+	ExpireMessages(now time.Time) ([]*domain.Message, error)
+	// DeleteOlderThan soft-deletes the room's messages sent before cutoff
+	// and returns their IDs
+	DeleteOlderThan(roomID uint, cutoff time.Time) ([]uint, error)
+
+	// Thread operations
+	// FindThread returns the root message and every message replying to it,
+	// directly or not, oldest first. A limit of 0 returns the whole thread.
+	FindThread(rootMessageID uint, limit, offset int) ([]*domain.Message, error)
+	// CountReplies maps each of the messages to its number of direct replies
+	// that are not deleted; messages without replies are left out
+	CountReplies(messageIDs []uint) (map[uint]int64, error)
 
 	// Reaction operations
 	AddReaction(reaction *domain.MessageReaction) error
 	RemoveReaction(messageID, userID uint, emoji string) error
 	GetReactions(messageID uint) ([]*domain.MessageReaction, error)
+	// CountReactions maps each of the messages to its reaction counts per
+	// emoji, most used first and otherwise in the order each emoji was first
+	// used. ReactedByMe is set for the emojis userID reacted with.
+	CountReactions(messageIDs []uint, userID uint) (map[uint][]domain.ReactionCount, error)
+	GetReactionStats(roomID uint, since, until *time.Time, limit int) ([]*domain.ReactionStat, error)
 
 	// Read receipt operations
 	MarkAsRead(messageID, userID uint) error
+	// MarkRoomAsRead adds read receipts, in one batch, for the room's messages
+	// the user had not read by readAt and moves their last read time to
+	// readAt. It returns how many messages were marked.
+	MarkRoomAsRead(roomID, userID uint, readAt time.Time) (int64, error)
+	// GetReadReceipts returns who read the message, most recent first
 	GetReadReceipts(messageID uint) ([]*domain.ReadReceipt, error)
 	GetLastReadMessage(roomID, userID uint) (*domain.Message, error)
+	// GetLastReadMessageIDs maps each user who read any of the room's
+	// messages to the newest of them
+	GetLastReadMessageIDs(roomID uint) (map[uint]uint, error)
+
+	// Mention operations
+	CreateMentions(mentions []*domain.Mention) error
+	FindUnreadMentions(userID uint) ([]*domain.Mention, error)
 }
 
 type messageRepository struct {
@@ -50,8 +86,8 @@ func (r *messageRepository) FindByID(id uint) (*domain.Message, error) {
 	err := r.db.
 		Preload("Sender").
 		Preload("ReplyTo.Sender").
-		Preload("Reactions.User").
 		Preload("ReadReceipts.User").
+		Scopes(notExpired(time.Now())).
 		First(&message, id).Error
 
 	if err != nil {
@@ -63,13 +99,13 @@ func (r *messageRepository) FindByID(id uint) (*domain.Message, error) {
 	return &message, nil
 }
 
-func (r *messageRepository) FindByRoomID(roomID uint, limit, offset int) ([]*domain.Message, error) {
+func (r *messageRepository) FindByRoomID(roomID uint, since time.Time, limit, offset int) ([]*domain.Message, error) {
 	var messages []*domain.Message
 	err := r.db.Where("room_id = ? AND is_deleted = ?", roomID, false).
+		Scopes(notExpired(time.Now()), sentSince(since)).
 		Preload("Sender").
 		Preload("ReplyTo.Sender").
-		Preload("Reactions.User").
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&messages).Error
@@ -86,6 +122,78 @@ func (r *messageRepository) FindByRoomID(roomID uint, limit, offset int) ([]*dom
 	return messages, nil
 }
 
+// FindByRoomIDBefore returns up to limit messages strictly older than
+// beforeMessageID, oldest first. Paging is keyed on (created_at, id) so new
+// messages arriving between page loads do not shift the window.
+func (r *messageRepository) FindByRoomIDBefore(roomID uint, since time.Time, beforeMessageID uint, limit int) ([]*domain.Message, error) {
+	var cursor domain.Message
+	err := r.db.Select("id", "created_at").
+		Where("room_id = ?", roomID).
+		First(&cursor, beforeMessageID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("message not found with id %d", beforeMessageID)
+		}
+		return nil, fmt.Errorf("failed to find cursor message: %w", err)
+	}
+
+	var messages []*domain.Message
+	err = r.db.Where("room_id = ? AND is_deleted = ?", roomID, false).
+		Scopes(notExpired(time.Now()), sentSince(since)).
+		Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID).
+		Preload("Sender").
+		Preload("ReplyTo.Sender").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&messages).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find messages for room: %w", err)
+	}
+
+	// Reverse order so oldest messages come first
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// FindMediaByRoomID returns up to limit image and file messages, newest first.
+// When beforeMessageID is set only messages older than it are returned, using
+// the same (created_at, id) keyset as FindByRoomIDBefore.
+func (r *messageRepository) FindMediaByRoomID(roomID uint, since time.Time, beforeMessageID uint, limit int) ([]*domain.Message, error) {
+	query := r.db.Where("room_id = ? AND is_deleted = ?", roomID, false).
+		Scopes(notExpired(time.Now()), sentSince(since)).
+		Where("type IN ?", []domain.MessageType{domain.MessageTypeImage, domain.MessageTypeFile})
+
+	if beforeMessageID > 0 {
+		var cursor domain.Message
+		err := r.db.Select("id", "created_at").
+			Where("room_id = ?", roomID).
+			First(&cursor, beforeMessageID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("message not found with id %d", beforeMessageID)
+			}
+			return nil, fmt.Errorf("failed to find cursor message: %w", err)
+		}
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	var messages []*domain.Message
+	err := query.
+		Preload("Sender").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&messages).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find media for room: %w", err)
+	}
+	return messages, nil
+}
+
 func (r *messageRepository) Update(message *domain.Message) error {
 	if err := r.db.Save(message).Error; err != nil {
 		return fmt.Errorf("failed to update message: %w", err)
@@ -93,22 +201,156 @@ func (r *messageRepository) Update(message *domain.Message) error {
 	return nil
 }
 
-func (r *messageRepository) SoftDelete(messageID uint) error {
-	if err := r.db.Model(&domain.Message{}).
-		Where("id = ?", messageID).
-		Updates(map[string]interface{}{
-			"is_deleted": true,
-			"deleted_at": gorm.Expr("NOW()"),
-			"content":    "[deleted]",
-		}).Error; err != nil {
+func (r *messageRepository) SoftDelete(messageID, deletedByID uint, deletedAt time.Time) error {
+	if err := softDelete(r.db, []uint{messageID}, &deletedByID, deletedAt); err != nil {
 		return fmt.Errorf("failed to soft delete message: %w", err)
 	}
 	return nil
 }
 
+// BulkSoftDelete soft-deletes the given messages of a room in one
+// transaction. If any of them is not in the room, none is deleted.
+func (r *messageRepository) BulkSoftDelete(roomID uint, messageIDs []uint, deletedByID uint, deletedAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var found int64
+		if err := tx.Model(&domain.Message{}).
+			Where("id IN ? AND room_id = ?", messageIDs, roomID).
+			Count(&found).Error; err != nil {
+			return fmt.Errorf("failed to find messages: %w", err)
+		}
+		if int(found) != len(uniqueIDs(messageIDs)) {
+			return errors.New("some messages do not belong to this room")
+		}
+
+		if err := softDelete(tx, messageIDs, &deletedByID, deletedAt); err != nil {
+			return fmt.Errorf("failed to soft delete messages: %w", err)
+		}
+		return nil
+	})
+}
+
+// SoftDeleteBySender soft-deletes every remaining message a user sent in a
+// room and returns their IDs
+func (r *messageRepository) SoftDeleteBySender(roomID, senderID, deletedByID uint, deletedAt time.Time) ([]uint, error) {
+	var messageIDs []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Message{}).
+			Where("room_id = ? AND sender_id = ? AND is_deleted = ?", roomID, senderID, false).
+			Pluck("id", &messageIDs).Error; err != nil {
+			return fmt.Errorf("failed to find messages: %w", err)
+		}
+		if len(messageIDs) == 0 {
+			return nil
+		}
+
+		if err := softDelete(tx, messageIDs, &deletedByID, deletedAt); err != nil {
+			return fmt.Errorf("failed to soft delete messages: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messageIDs, nil
+}
+
+// ExpireMessages soft-deletes every message whose expiry has passed and
+// returns them. Expired messages have no deleter.
+func (r *messageRepository) ExpireMessages(now time.Time) ([]*domain.Message, error) {
+	var expired []*domain.Message
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "room_id", "sender_id").
+			Where("is_deleted = ? AND expires_at <= ?", false, now).
+			Find(&expired).Error; err != nil {
+			return fmt.Errorf("failed to find expired messages: %w", err)
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		messageIDs := make([]uint, len(expired))
+		for i, message := range expired {
+			messageIDs[i] = message.ID
+		}
+		if err := softDelete(tx, messageIDs, nil, now); err != nil {
+			return fmt.Errorf("failed to soft delete expired messages: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// DeleteOlderThan soft-deletes the room's remaining messages sent before
+// cutoff and returns their IDs. Purged messages have no deleter.
+func (r *messageRepository) DeleteOlderThan(roomID uint, cutoff time.Time) ([]uint, error) {
+	var messageIDs []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Message{}).
+			Where("room_id = ? AND is_deleted = ? AND created_at < ?", roomID, false, cutoff).
+			Pluck("id", &messageIDs).Error; err != nil {
+			return fmt.Errorf("failed to find old messages: %w", err)
+		}
+		if len(messageIDs) == 0 {
+			return nil
+		}
+
+		if err := softDelete(tx, messageIDs, nil, time.Now()); err != nil {
+			return fmt.Errorf("failed to soft delete old messages: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messageIDs, nil
+}
+
+// softDelete replaces the messages' content with a placeholder and records
+// who deleted them and when
+func softDelete(db *gorm.DB, messageIDs []uint, deletedByID *uint, deletedAt time.Time) error {
+	return db.Model(&domain.Message{}).
+		Where("id IN ?", messageIDs).
+		Updates(map[string]interface{}{
+			"is_deleted":    true,
+			"deleted_at":    deletedAt,
+			"deleted_by_id": deletedByID,
+			"content":       "[deleted]",
+		}).Error
+}
+
+// notExpired hides ephemeral messages whose expiry has passed but which the
+// expiry job has not deleted yet
+func notExpired(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("expires_at IS NULL OR expires_at > ?", now)
+	}
+}
+
+// sentSince skips messages created before since, unless since is zero
+func sentSince(since time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if since.IsZero() {
+			return db
+		}
+		return db.Where("created_at >= ?", since)
+	}
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
+
 func (r *messageRepository) GetLastMessage(roomID uint) (*domain.Message, error) {
 	var message domain.Message
 	err := r.db.Where("room_id = ? AND is_deleted = ?", roomID, false).
+		Scopes(notExpired(time.Now())).
 		Preload("Sender").
 		Order("created_at DESC").
 		First(&message).Error
@@ -122,6 +364,70 @@ func (r *messageRepository) GetLastMessage(roomID uint) (*domain.Message, error)
 	return &message, nil
 }
 
+// Thread operations
+
+func (r *messageRepository) FindThread(rootMessageID uint, limit, offset int) ([]*domain.Message, error) {
+	now := time.Now()
+
+	// Replies to an expired message still belong to the thread, only the
+	// expired messages themselves are left out
+	var ids []uint
+	err := r.db.Raw(`
+		WITH RECURSIVE thread(id) AS (
+			SELECT id FROM messages WHERE id = ? AND (expires_at IS NULL OR expires_at > ?)
+			UNION
+			SELECT messages.id FROM messages JOIN thread ON messages.reply_to_id = thread.id
+		)
+		SELECT id FROM thread`, rootMessageID, now).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find thread: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("message not found with id %d", rootMessageID)
+	}
+
+	query := r.db.Where("id IN ?", ids).
+		Scopes(notExpired(now)).
+		Preload("Sender").
+		Order("created_at ASC, id ASC").
+		Offset(offset)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var messages []*domain.Message
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to find thread: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *messageRepository) CountReplies(messageIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ReplyToID uint
+		Count     int64
+	}
+	err := r.db.Model(&domain.Message{}).
+		Select("reply_to_id, COUNT(*) AS count").
+		Where("reply_to_id IN ? AND is_deleted = ?", messageIDs, false).
+		Group("reply_to_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count replies: %w", err)
+	}
+
+	for _, row := range rows {
+		counts[row.ReplyToID] = row.Count
+	}
+	return counts, nil
+}
+
 // Reaction operations
 
 func (r *messageRepository) AddReaction(reaction *domain.MessageReaction) error {
@@ -162,6 +468,7 @@ func (r *messageRepository) GetReactions(messageID uint) ([]*domain.MessageReact
 	var reactions []*domain.MessageReaction
 	err := r.db.Where("message_id = ?", messageID).
 		Preload("User").
+		Order("created_at ASC, id ASC").
 		Find(&reactions).Error
 
 	if err != nil {
@@ -170,6 +477,66 @@ func (r *messageRepository) GetReactions(messageID uint) ([]*domain.MessageReact
 	return reactions, nil
 }
 
+func (r *messageRepository) CountReactions(messageIDs []uint, userID uint) (map[uint][]domain.ReactionCount, error) {
+	counts := make(map[uint][]domain.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		MessageID   uint
+		Emoji       string
+		Count       int64
+		ReactedByMe int
+	}
+	err := r.db.Model(&domain.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, MAX(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("message_id, count DESC, MIN(id)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+
+	for _, row := range rows {
+		counts[row.MessageID] = append(counts[row.MessageID], domain.ReactionCount{
+			Emoji:       row.Emoji,
+			Count:       row.Count,
+			ReactedByMe: row.ReactedByMe == 1,
+		})
+	}
+	return counts, nil
+}
+
+// GetReactionStats counts the reactions on a room's non-deleted messages per
+// emoji, most used first. since and until bound the reaction time, each is
+// optional; until is exclusive.
+func (r *messageRepository) GetReactionStats(roomID uint, since, until *time.Time, limit int) ([]*domain.ReactionStat, error) {
+	query := r.db.Model(&domain.MessageReaction{}).
+		Select("message_reactions.emoji AS emoji, COUNT(*) AS count").
+		Joins("JOIN messages ON messages.id = message_reactions.message_id").
+		Where("messages.room_id = ? AND messages.is_deleted = ?", roomID, false)
+	if since != nil {
+		query = query.Where("message_reactions.created_at >= ?", *since)
+	}
+	if until != nil {
+		query = query.Where("message_reactions.created_at < ?", *until)
+	}
+
+	var stats []*domain.ReactionStat
+	err := query.
+		Group("message_reactions.emoji").
+		Order("count DESC, emoji ASC").
+		Limit(limit).
+		Scan(&stats).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction stats: %w", err)
+	}
+	return stats, nil
+}
+
 // Read receipt operations
 
 func (r *messageRepository) MarkAsRead(messageID, userID uint) error {
@@ -191,6 +558,7 @@ func (r *messageRepository) MarkAsRead(messageID, userID uint) error {
 	receipt := &domain.ReadReceipt{
 		MessageID: messageID,
 		UserID:    userID,
+		ReadAt:    time.Now(),
 	}
 
 	if err := r.db.Create(receipt).Error; err != nil {
@@ -200,11 +568,56 @@ func (r *messageRepository) MarkAsRead(messageID, userID uint) error {
 	return nil
 }
 
+func (r *messageRepository) MarkRoomAsRead(roomID, userID uint, readAt time.Time) (int64, error) {
+	var marked int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var participant domain.Participant
+		if err := tx.Where("room_id = ? AND user_id = ? AND left_at IS NULL", roomID, userID).
+			First(&participant).Error; err != nil {
+			return fmt.Errorf("failed to find participant: %w", err)
+		}
+
+		// Unread the same way GetUnreadCount counts it, minus messages that
+		// already have a receipt from this user
+		var messageIDs []uint
+		if err := tx.Model(&domain.Message{}).
+			Where("room_id = ? AND sender_id != ? AND created_at > ? AND created_at <= ?",
+				roomID, userID, participant.LastReadAt, readAt).
+			Where("NOT EXISTS (SELECT 1 FROM read_receipts WHERE read_receipts.message_id = messages.id AND read_receipts.user_id = ?)", userID).
+			Pluck("id", &messageIDs).Error; err != nil {
+			return fmt.Errorf("failed to find unread messages: %w", err)
+		}
+
+		if len(messageIDs) > 0 {
+			receipts := make([]*domain.ReadReceipt, len(messageIDs))
+			for i, messageID := range messageIDs {
+				receipts[i] = &domain.ReadReceipt{MessageID: messageID, UserID: userID, ReadAt: readAt}
+			}
+			if err := tx.Create(&receipts).Error; err != nil {
+				return fmt.Errorf("failed to create read receipts: %w", err)
+			}
+		}
+
+		if err := tx.Model(&domain.Participant{}).
+			Where("id = ?", participant.ID).
+			Update("last_read_at", readAt).Error; err != nil {
+			return fmt.Errorf("failed to update last read: %w", err)
+		}
+
+		marked = int64(len(messageIDs))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return marked, nil
+}
+
 func (r *messageRepository) GetReadReceipts(messageID uint) ([]*domain.ReadReceipt, error) {
 	var receipts []*domain.ReadReceipt
 	err := r.db.Where("message_id = ?", messageID).
 		Preload("User").
-		Order("read_at DESC").
+		Order("read_at DESC, id DESC").
 		Find(&receipts).Error
 
 	if err != nil {
@@ -229,3 +642,54 @@ func (r *messageRepository) GetLastReadMessage(roomID, userID uint) (*domain.Mes
 	}
 	return &message, nil
 }
+
+func (r *messageRepository) GetLastReadMessageIDs(roomID uint) (map[uint]uint, error) {
+	var rows []struct {
+		UserID    uint
+		MessageID uint
+	}
+	err := r.db.Model(&domain.ReadReceipt{}).
+		Select("read_receipts.user_id, MAX(read_receipts.message_id) AS message_id").
+		Joins("JOIN messages ON messages.id = read_receipts.message_id").
+		Where("messages.room_id = ?", roomID).
+		Group("read_receipts.user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last read messages: %w", err)
+	}
+
+	lastRead := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		lastRead[row.UserID] = row.MessageID
+	}
+	return lastRead, nil
+}
+
+// Mention operations
+
+func (r *messageRepository) CreateMentions(mentions []*domain.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	if err := r.db.Create(mentions).Error; err != nil {
+		return fmt.Errorf("failed to create mentions: %w", err)
+	}
+	return nil
+}
+
+// FindUnreadMentions returns the user's mentions in rooms they still belong
+// to that are newer than their last read position, newest first.
+func (r *messageRepository) FindUnreadMentions(userID uint) ([]*domain.Mention, error) {
+	var mentions []*domain.Mention
+	err := r.db.
+		Joins("JOIN participants ON participants.room_id = mentions.room_id AND participants.user_id = mentions.user_id").
+		Where("mentions.user_id = ? AND participants.left_at IS NULL AND mentions.created_at > participants.last_read_at", userID).
+		Preload("Message.Sender").
+		Order("mentions.created_at DESC, mentions.id DESC").
+		Find(&mentions).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unread mentions: %w", err)
+	}
+	return mentions, nil
+}
@@ -0,0 +1,627 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"realtime-chat/internal/domain"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Room{},
+		&domain.Participant{},
+		&domain.Message{},
+		&domain.MessageReaction{},
+		&domain.ReadReceipt{},
+		&domain.Mention{},
+		&domain.UserBlock{},
+		&domain.ScheduledMessage{},
+	); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	return db
+}
+
+// seedMessages creates count messages in a room. Every third message shares
+// its predecessor's timestamp so that ordering has to fall back to the ID.
+func seedMessages(t *testing.T, db *gorm.DB, roomID, senderID uint, count int) []*domain.Message {
+	t.Helper()
+
+	base := time.Now().Add(-time.Hour)
+	messages := make([]*domain.Message, count)
+	for i := range messages {
+		createdAt := base.Add(time.Duration(i) * time.Second)
+		if i%3 == 2 {
+			createdAt = messages[i-1].CreatedAt
+		}
+		messages[i] = &domain.Message{
+			RoomID:    roomID,
+			SenderID:  senderID,
+			Type:      domain.MessageTypeText,
+			Content:   "message",
+			CreatedAt: createdAt,
+		}
+		if err := db.Create(messages[i]).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+	return messages
+}
+
+func TestMessageRepository_FindByRoomIDBefore(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	sender := &domain.User{Email: "a@example.com", Username: "alice", PasswordHash: "x"}
+	if err := db.Create(sender).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	seeded := seedMessages(t, db, 1, sender.ID, 50)
+	seedMessages(t, db, 2, sender.ID, 5) // another room must not leak in
+
+	// Start from the newest page, then walk backwards with the cursor
+	page, err := repo.FindByRoomID(1, time.Time{}, 15, 0)
+	if err != nil {
+		t.Fatalf("FindByRoomID() error = %v", err)
+	}
+
+	var collected []*domain.Message
+	for len(page) > 0 {
+		collected = append(page, collected...)
+		page, err = repo.FindByRoomIDBefore(1, time.Time{}, page[0].ID, 15)
+		if err != nil {
+			t.Fatalf("FindByRoomIDBefore() error = %v", err)
+		}
+	}
+
+	if len(collected) != len(seeded) {
+		t.Fatalf("collected %d messages, want %d", len(collected), len(seeded))
+	}
+	for i, message := range collected {
+		if message.ID != seeded[i].ID {
+			t.Fatalf("collected[%d].ID = %d, want %d", i, message.ID, seeded[i].ID)
+		}
+	}
+}
+
+func TestMessageRepository_FindByRoomIDBefore_UnknownCursor(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	seedMessages(t, db, 1, 1, 3)
+	other := seedMessages(t, db, 2, 1, 1)
+
+	if _, err := repo.FindByRoomIDBefore(1, time.Time{}, 9999, 10); err == nil {
+		t.Error("FindByRoomIDBefore() should fail for a missing cursor")
+	}
+	if _, err := repo.FindByRoomIDBefore(1, time.Time{}, other[0].ID, 10); err == nil {
+		t.Error("FindByRoomIDBefore() should fail for a cursor from another room")
+	}
+}
+
+func TestMessageRepository_UnreadMentions(t *testing.T) {
+	db := setupTestDB(t)
+	messageRepo := NewMessageRepository(db)
+	roomRepo := NewRoomRepository(db)
+
+	alice := &domain.User{Email: "a@example.com", Username: "alice", PasswordHash: "x"}
+	bob := &domain.User{Email: "b@example.com", Username: "bob", PasswordHash: "x"}
+	db.Create(alice)
+	db.Create(bob)
+
+	room := &domain.Room{Name: "general", CreatorID: bob.ID}
+	db.Create(room)
+	lastRead := time.Now().Add(-time.Hour).Add(2 * time.Second)
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: alice.ID, LastReadAt: lastRead, JoinedAt: lastRead})
+
+	// The first three messages are at or before alice's last read position
+	messages := seedMessages(t, db, room.ID, bob.ID, 5)
+	var mentions []*domain.Mention
+	for _, message := range messages {
+		mentions = append(mentions, &domain.Mention{MessageID: message.ID, RoomID: room.ID, UserID: alice.ID, CreatedAt: message.CreatedAt})
+	}
+	if err := messageRepo.CreateMentions(mentions); err != nil {
+		t.Fatalf("CreateMentions() error = %v", err)
+	}
+
+	unread, err := messageRepo.FindUnreadMentions(alice.ID)
+	if err != nil {
+		t.Fatalf("FindUnreadMentions() error = %v", err)
+	}
+	if len(unread) != 2 || unread[0].MessageID != messages[4].ID || unread[0].Message == nil {
+		t.Errorf("FindUnreadMentions() = %d mentions, want the 2 newest with their messages", len(unread))
+	}
+
+	counts, err := roomRepo.GetUnreadMentionCounts(alice.ID)
+	if err != nil || counts[room.ID] != 2 {
+		t.Errorf("GetUnreadMentionCounts() = %v, %v, want 2 in room %d", counts, err, room.ID)
+	}
+
+	// Muting the room only silences notifications, the mentions still count
+	db.Model(&domain.Participant{}).Where("user_id = ?", alice.ID).Update("is_muted", true)
+	if counts, err := roomRepo.GetUnreadMentionCounts(alice.ID); err != nil || counts[room.ID] != 2 {
+		t.Errorf("GetUnreadMentionCounts() in a muted room = %v, %v, want 2 in room %d", counts, err, room.ID)
+	}
+	if unread, _ := messageRepo.FindUnreadMentions(alice.ID); len(unread) != 2 {
+		t.Errorf("FindUnreadMentions() in a muted room = %d mentions, want 2", len(unread))
+	}
+
+	db.Model(&domain.Participant{}).Where("user_id = ?", alice.ID).Update("last_read_at", time.Now())
+	if unread, _ := messageRepo.FindUnreadMentions(alice.ID); len(unread) != 0 {
+		t.Errorf("FindUnreadMentions() after reading = %d mentions, want 0", len(unread))
+	}
+}
+
+func TestMessageRepository_BulkSoftDelete(t *testing.T) {
+	db := setupTestDB(t)
+	messageRepo := NewMessageRepository(db)
+
+	admin := &domain.User{Email: "admin@example.com", Username: "admin", PasswordHash: "x"}
+	spammer := &domain.User{Email: "spam@example.com", Username: "spammer", PasswordHash: "x"}
+	db.Create(admin)
+	db.Create(spammer)
+
+	room := &domain.Room{Name: "general", CreatorID: admin.ID}
+	other := &domain.Room{Name: "random", CreatorID: admin.ID}
+	db.Create(room)
+	db.Create(other)
+
+	spam := seedMessages(t, db, room.ID, spammer.ID, 3)
+	kept := seedMessages(t, db, room.ID, admin.ID, 1)
+	elsewhere := seedMessages(t, db, other.ID, spammer.ID, 1)
+
+	countDeleted := func() int64 {
+		var count int64
+		db.Model(&domain.Message{}).Where("is_deleted = ?", true).Count(&count)
+		return count
+	}
+
+	// A message from another room rejects the whole batch
+	err := messageRepo.BulkSoftDelete(room.ID, []uint{spam[0].ID, elsewhere[0].ID}, admin.ID, time.Now())
+	if err == nil {
+		t.Fatal("BulkSoftDelete() with a message from another room should fail")
+	}
+	if n := countDeleted(); n != 0 {
+		t.Errorf("deleted messages after rejected batch = %d, want 0", n)
+	}
+
+	if err := messageRepo.BulkSoftDelete(room.ID, []uint{spam[0].ID, spam[1].ID}, admin.ID, time.Now()); err != nil {
+		t.Fatalf("BulkSoftDelete() error = %v", err)
+	}
+	var deleted domain.Message
+	db.First(&deleted, spam[0].ID)
+	if !deleted.IsDeleted || deleted.Content != "[deleted]" || deleted.DeletedByID == nil || *deleted.DeletedByID != admin.ID {
+		t.Errorf("deleted message = %+v, want placeholder deleted by %d", deleted, admin.ID)
+	}
+
+	// Purging the spammer only touches their remaining messages in the room
+	purged, err := messageRepo.SoftDeleteBySender(room.ID, spammer.ID, admin.ID, time.Now())
+	if err != nil {
+		t.Fatalf("SoftDeleteBySender() error = %v", err)
+	}
+	if len(purged) != 1 || purged[0] != spam[2].ID {
+		t.Errorf("SoftDeleteBySender() = %v, want [%d]", purged, spam[2].ID)
+	}
+	if n := countDeleted(); n != 3 {
+		t.Errorf("deleted messages = %d, want 3", n)
+	}
+
+	var untouched domain.Message
+	db.First(&untouched, kept[0].ID)
+	if untouched.IsDeleted {
+		t.Error("another sender's message was deleted")
+	}
+}
+
+func TestMessageRepository_FindMediaByRoomID(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	base := time.Now().Add(-time.Hour)
+	kinds := []domain.MessageType{
+		domain.MessageTypeImage,
+		domain.MessageTypeText,
+		domain.MessageTypeFile,
+		domain.MessageTypeSystem,
+		domain.MessageTypeImage,
+		domain.MessageTypeFile,
+	}
+	messages := make([]*domain.Message, len(kinds))
+	for i, kind := range kinds {
+		messages[i] = &domain.Message{
+			RoomID:    1,
+			SenderID:  1,
+			Type:      kind,
+			FileURL:   "https://cdn.example.com/file",
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}
+		if err := db.Create(messages[i]).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+	}
+	// Another room's media must not leak in
+	db.Create(&domain.Message{RoomID: 2, SenderID: 1, Type: domain.MessageTypeImage})
+
+	if err := repo.SoftDelete(messages[4].ID, 1, time.Now()); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+
+	media, err := repo.FindMediaByRoomID(1, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatalf("FindMediaByRoomID() error = %v", err)
+	}
+	want := []uint{messages[5].ID, messages[2].ID, messages[0].ID}
+	if len(media) != len(want) {
+		t.Fatalf("FindMediaByRoomID() returned %d messages, want %d", len(media), len(want))
+	}
+	for i, message := range media {
+		if message.ID != want[i] {
+			t.Errorf("media[%d].ID = %d, want %d", i, message.ID, want[i])
+		}
+	}
+
+	// Page on from the first result
+	page, err := repo.FindMediaByRoomID(1, time.Time{}, media[0].ID, 1)
+	if err != nil {
+		t.Fatalf("FindMediaByRoomID() error = %v", err)
+	}
+	if len(page) != 1 || page[0].ID != messages[2].ID {
+		t.Errorf("next page = %+v, want message %d", page, messages[2].ID)
+	}
+}
+
+func TestMessageRepository_ExpiredMessages(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	seeded := seedMessages(t, db, 1, 1, 3)
+	db.Model(seeded[0]).Update("expires_at", past)
+	db.Model(seeded[1]).Update("expires_at", future)
+
+	// Expired messages are hidden even before the expiry job deletes them
+	messages, err := repo.FindByRoomID(1, time.Time{}, 10, 0)
+	if err != nil {
+		t.Fatalf("FindByRoomID() error = %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != seeded[1].ID || messages[1].ID != seeded[2].ID {
+		t.Errorf("FindByRoomID() returned %d messages, want messages %d and %d", len(messages), seeded[1].ID, seeded[2].ID)
+	}
+	if _, err := repo.FindByID(seeded[0].ID); err == nil {
+		t.Error("FindByID() of an expired message should fail")
+	}
+	if _, err := repo.FindByID(seeded[1].ID); err != nil {
+		t.Errorf("FindByID() of a message yet to expire error = %v", err)
+	}
+
+	// nor in threads, as the root or as a reply
+	db.Model(seeded[0]).Update("reply_to_id", seeded[2].ID)
+	if _, err := repo.FindThread(seeded[0].ID, 0, 0); err == nil {
+		t.Error("FindThread() of an expired message should fail")
+	}
+	if thread, err := repo.FindThread(seeded[2].ID, 0, 0); err != nil || len(thread) != 1 {
+		t.Errorf("FindThread() = %d messages, %v, want only the root", len(thread), err)
+	}
+
+	expired, err := repo.ExpireMessages(time.Now())
+	if err != nil {
+		t.Fatalf("ExpireMessages() error = %v", err)
+	}
+	if len(expired) != 1 || expired[0].ID != seeded[0].ID || expired[0].RoomID != 1 {
+		t.Fatalf("ExpireMessages() = %+v, want message %d", expired, seeded[0].ID)
+	}
+
+	var reloaded domain.Message
+	db.First(&reloaded, seeded[0].ID)
+	if !reloaded.IsDeleted || reloaded.Content != "[deleted]" || reloaded.DeletedByID != nil {
+		t.Errorf("expired message = %+v, want soft-deleted without a deleter", reloaded)
+	}
+
+	if again, _ := repo.ExpireMessages(time.Now()); len(again) != 0 {
+		t.Errorf("second ExpireMessages() = %d messages, want 0", len(again))
+	}
+}
+
+func TestMessageRepository_DeleteOlderThan(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	old := seedMessages(t, db, 1, 1, 3) // an hour ago
+	recent := &domain.Message{RoomID: 1, SenderID: 1, Type: domain.MessageTypeText, Content: "recent"}
+	db.Create(recent)
+	elsewhere := seedMessages(t, db, 2, 1, 1)[0]
+	if err := repo.SoftDelete(old[0].ID, 1, time.Now()); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+
+	purged, err := repo.DeleteOlderThan(1, time.Now().Add(-30*time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteOlderThan() error = %v", err)
+	}
+
+	// Already deleted messages are not purged again
+	if fmt.Sprint(purged) != fmt.Sprint([]uint{old[1].ID, old[2].ID}) {
+		t.Errorf("DeleteOlderThan() = %v, want [%d %d]", purged, old[1].ID, old[2].ID)
+	}
+
+	var reloaded domain.Message
+	db.First(&reloaded, old[1].ID)
+	if !reloaded.IsDeleted || reloaded.Content != "[deleted]" || reloaded.DeletedByID != nil {
+		t.Errorf("purged message = %+v, want soft-deleted without a deleter", reloaded)
+	}
+	for _, kept := range []*domain.Message{recent, elsewhere} {
+		var message domain.Message
+		db.First(&message, kept.ID)
+		if message.IsDeleted {
+			t.Errorf("message %d was purged, want it kept", kept.ID)
+		}
+	}
+}
+
+func TestMessageRepository_CountReactions(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	messages := seedMessages(t, db, 1, 1, 3)
+	for _, r := range []struct {
+		messageID uint
+		userID    uint
+		emoji     string
+	}{
+		{messages[0].ID, 1, "🎉"},
+		{messages[0].ID, 2, "👍"},
+		{messages[0].ID, 3, "👍"},
+		{messages[0].ID, 2, "🎉"},
+		{messages[0].ID, 1, "👀"},
+		{messages[1].ID, 2, "❤️"},
+	} {
+		if err := db.Create(&domain.MessageReaction{MessageID: r.messageID, UserID: r.userID, Emoji: r.emoji}).Error; err != nil {
+			t.Fatalf("failed to create reaction: %v", err)
+		}
+	}
+
+	counts, err := repo.CountReactions([]uint{messages[0].ID, messages[1].ID, messages[2].ID}, 1)
+	if err != nil {
+		t.Fatalf("CountReactions() error = %v", err)
+	}
+
+	format := func(counts []domain.ReactionCount) string {
+		got := make([]string, len(counts))
+		for i, count := range counts {
+			got[i] = fmt.Sprintf("%s:%d:%v", count.Emoji, count.Count, count.ReactedByMe)
+		}
+		return strings.Join(got, " ")
+	}
+	// Ties keep the order each emoji was first used in
+	if got, want := format(counts[messages[0].ID]), "🎉:2:true 👍:2:false 👀:1:true"; got != want {
+		t.Errorf("first message counts = %s, want %s", got, want)
+	}
+	if got, want := format(counts[messages[1].ID]), "❤️:1:false"; got != want {
+		t.Errorf("second message counts = %s, want %s", got, want)
+	}
+	if got := counts[messages[2].ID]; len(got) != 0 {
+		t.Errorf("message without reactions has counts %v", got)
+	}
+}
+
+func TestMessageRepository_GetReactionStats(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	messages := seedMessages(t, db, 1, 1, 4)
+	other := seedMessages(t, db, 2, 1, 1)[0]
+	if err := repo.SoftDelete(messages[3].ID, 1, now); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+
+	react := func(messageID, userID uint, emoji string, at time.Time) {
+		t.Helper()
+		reaction := &domain.MessageReaction{MessageID: messageID, UserID: userID, Emoji: emoji, CreatedAt: at}
+		if err := db.Create(reaction).Error; err != nil {
+			t.Fatalf("failed to create reaction: %v", err)
+		}
+	}
+	react(messages[0].ID, 1, "👍", recent)
+	react(messages[0].ID, 2, "👍", recent)
+	react(messages[1].ID, 1, "👍", old)
+	react(messages[2].ID, 3, "👍", recent)
+	react(messages[0].ID, 3, "🎉", old)
+	react(messages[1].ID, 2, "🎉", old)
+	react(messages[1].ID, 3, "🎉", old)
+	react(messages[1].ID, 4, "❤️", recent)
+	react(messages[2].ID, 1, "❤️", recent)
+	// Reactions on deleted messages and in other rooms don't count
+	for userID := uint(1); userID <= 5; userID++ {
+		react(messages[3].ID, userID, "😂", recent)
+		react(other.ID, userID, "😂", recent)
+	}
+
+	dayAgo := now.Add(-24 * time.Hour)
+	tests := []struct {
+		name  string
+		since *time.Time
+		until *time.Time
+		limit int
+		want  string
+	}{
+		{name: "all time", limit: 10, want: "👍:4 🎉:3 ❤️:2"},
+		{name: "top two", limit: 2, want: "👍:4 🎉:3"},
+		{name: "since", since: &dayAgo, limit: 10, want: "👍:3 ❤️:2"},
+		{name: "until", until: &dayAgo, limit: 10, want: "🎉:3 👍:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.GetReactionStats(1, tt.since, tt.until, tt.limit)
+			if err != nil {
+				t.Fatalf("GetReactionStats() error = %v", err)
+			}
+			got := make([]string, len(stats))
+			for i, stat := range stats {
+				got[i] = fmt.Sprintf("%s:%d", stat.Emoji, stat.Count)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("GetReactionStats() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageRepository_ReadReceipts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	messages := seedMessages(t, db, 1, 1, 3)
+	other := seedMessages(t, db, 2, 1, 1)[0]
+
+	now := time.Now()
+	read := func(messageID, userID uint, at time.Time) {
+		t.Helper()
+		if err := db.Create(&domain.ReadReceipt{MessageID: messageID, UserID: userID, ReadAt: at}).Error; err != nil {
+			t.Fatalf("failed to create read receipt: %v", err)
+		}
+	}
+	read(messages[0].ID, 2, now.Add(-3*time.Minute))
+	read(messages[0].ID, 4, now.Add(-time.Minute))
+	read(messages[0].ID, 3, now.Add(-2*time.Minute))
+	read(messages[1].ID, 2, now)
+	read(other.ID, 3, now) // another room
+
+	receipts, err := repo.GetReadReceipts(messages[0].ID)
+	if err != nil {
+		t.Fatalf("GetReadReceipts() error = %v", err)
+	}
+	got := make([]uint, len(receipts))
+	for i, receipt := range receipts {
+		got[i] = receipt.UserID
+	}
+	if fmt.Sprint(got) != "[4 3 2]" {
+		t.Errorf("GetReadReceipts() readers = %v, want [4 3 2], most recent first", got)
+	}
+
+	// Marking as read records when, and only once
+	if err := repo.MarkAsRead(messages[2].ID, 5); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+	if err := repo.MarkAsRead(messages[2].ID, 5); err != nil {
+		t.Fatalf("MarkAsRead() again error = %v", err)
+	}
+	receipts, _ = repo.GetReadReceipts(messages[2].ID)
+	if len(receipts) != 1 || time.Since(receipts[0].ReadAt) > time.Minute {
+		t.Errorf("GetReadReceipts() after MarkAsRead = %+v, want one receipt read just now", receipts)
+	}
+
+	lastRead, err := repo.GetLastReadMessageIDs(1)
+	if err != nil {
+		t.Fatalf("GetLastReadMessageIDs() error = %v", err)
+	}
+	want := map[uint]uint{2: messages[1].ID, 3: messages[0].ID, 4: messages[0].ID, 5: messages[2].ID}
+	if fmt.Sprint(lastRead) != fmt.Sprint(want) {
+		t.Errorf("GetLastReadMessageIDs() = %v, want %v", lastRead, want)
+	}
+}
+
+func TestMessageRepository_FindThread(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewMessageRepository(db)
+
+	base := time.Now().Add(-time.Hour)
+	create := func(minute int, replyTo *domain.Message) *domain.Message {
+		t.Helper()
+		message := &domain.Message{RoomID: 1, SenderID: 1, Type: domain.MessageTypeText, Content: "message",
+			CreatedAt: base.Add(time.Duration(minute) * time.Minute)}
+		if replyTo != nil {
+			message.ReplyToID = &replyTo.ID
+		}
+		if err := db.Create(message).Error; err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		return message
+	}
+
+	// root
+	// ├── a
+	// │   ├── a1
+	// │   └── a2 (deleted)
+	// └── b
+	//     └── b1
+	root := create(0, nil)
+	a := create(1, root)
+	b := create(2, root)
+	a1 := create(3, a)
+	unrelated := create(4, nil)
+	b1 := create(5, b)
+	a2 := create(6, a)
+	create(7, unrelated)
+	if err := repo.SoftDelete(a2.ID, 1, time.Now()); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+
+	thread, err := repo.FindThread(root.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("FindThread() error = %v", err)
+	}
+	got := make([]uint, len(thread))
+	for i, message := range thread {
+		got[i] = message.ID
+	}
+	want := []uint{root.ID, a.ID, b.ID, a1.ID, b1.ID, a2.ID}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("FindThread() = %v, want %v in creation order", got, want)
+	}
+
+	// Pages follow the same order
+	page, err := repo.FindThread(root.ID, 2, 3)
+	if err != nil {
+		t.Fatalf("FindThread() page error = %v", err)
+	}
+	if len(page) != 2 || page[0].ID != a1.ID || page[1].ID != b1.ID {
+		t.Errorf("FindThread(limit 2, offset 3) returned %d messages, want a1 and b1", len(page))
+	}
+
+	// A reply is the root of its own subthread
+	thread, err = repo.FindThread(a.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("FindThread() error = %v", err)
+	}
+	if len(thread) != 3 || thread[0].ID != a.ID {
+		t.Errorf("FindThread(a) returned %d messages starting with %d, want a and its 2 replies", len(thread), thread[0].ID)
+	}
+
+	if _, err := repo.FindThread(9999, 0, 0); err == nil {
+		t.Error("FindThread() of a missing message should fail")
+	}
+
+	counts, err := repo.CountReplies([]uint{root.ID, a.ID, b.ID, a1.ID})
+	if err != nil {
+		t.Fatalf("CountReplies() error = %v", err)
+	}
+	wantCounts := map[uint]int64{root.ID: 2, a.ID: 1, b.ID: 1}
+	if fmt.Sprint(counts) != fmt.Sprint(wantCounts) {
+		t.Errorf("CountReplies() = %v, want %v", counts, wantCounts)
+	}
+}
@@ -3,6 +3,8 @@ package repository
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -13,6 +15,10 @@ type RoomRepository interface {
 	Create(room *domain.Room) error
 	FindByID(id uint) (*domain.Room, error)
 	FindByUserID(userID uint) ([]*domain.Room, error)
+	// SearchUserRooms returns the user's rooms whose name matches query.
+	// Unnamed direct rooms match on the other participant's username or
+	// display name.
+	SearchUserRooms(userID uint, query string) ([]*domain.Room, error)
 	FindDirectRoom(user1ID, user2ID uint) (*domain.Room, error)
 	Update(room *domain.Room) error
 	Delete(id uint) error
@@ -23,7 +29,17 @@ type RoomRepository interface {
 	FindParticipant(roomID, userID uint) (*domain.Participant, error)
 	GetParticipants(roomID uint) ([]*domain.Participant, error)
 	UpdateLastRead(roomID, userID uint) error
+	UpdateParticipantMute(roomID, userID uint, muted bool) error
+	UpdateParticipantSilenced(roomID, userID uint, silenced bool) error
+	UpdateParticipantRole(roomID, userID uint, role domain.ParticipantRole) error
 	GetUnreadCount(roomID, userID uint) (int64, error)
+	GetUnreadCounts(userID uint) (map[uint]int64, error)
+	GetRoomUnreadCounts(roomID uint) (map[uint]int64, error)
+	GetUnreadMentionCounts(userID uint) (map[uint]int64, error)
+
+	// Auto-archival
+	FindStaleRooms(cutoff time.Time) ([]*domain.Room, error)
+	FindRoomsWithRetention() ([]*domain.Room, error)
 }
 
 type roomRepository struct {
@@ -72,6 +88,35 @@ func (r *roomRepository) FindByUserID(userID uint) ([]*domain.Room, error) {
 	return rooms, nil
 }
 
+// SearchUserRooms returns the user's rooms whose name contains query, ignoring
+// case, and their unnamed direct rooms whose other participant's username or
+// display name does. Wildcards in query match literally.
+func (r *roomRepository) SearchUserRooms(userID uint, query string) ([]*domain.Room, error) {
+	var rooms []*domain.Room
+	searchPattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+
+	otherParticipant := r.db.Table("participants AS others").
+		Select("1").
+		Joins("JOIN users ON users.id = others.user_id").
+		Where("others.room_id = rooms.id AND others.user_id <> ? AND others.left_at IS NULL", userID).
+		Where(`LOWER(users.username) LIKE ? ESCAPE '\' OR LOWER(users.display_name) LIKE ? ESCAPE '\'`, searchPattern, searchPattern)
+
+	err := r.db.
+		Joins("JOIN participants ON rooms.id = participants.room_id").
+		Where("participants.user_id = ? AND participants.left_at IS NULL", userID).
+		Where(r.db.Where(`LOWER(rooms.name) LIKE ? ESCAPE '\'`, searchPattern).
+			Or("rooms.type = ? AND COALESCE(rooms.name, '') = '' AND EXISTS (?)", domain.RoomTypeDirect, otherParticipant)).
+		Preload("Creator").
+		Preload("Participants.User").
+		Order("rooms.updated_at DESC").
+		Find(&rooms).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rooms for user: %w", err)
+	}
+	return rooms, nil
+}
+
 func (r *roomRepository) FindDirectRoom(user1ID, user2ID uint) (*domain.Room, error) {
 	var room domain.Room
 
@@ -167,6 +212,33 @@ func (r *roomRepository) UpdateLastRead(roomID, userID uint) error {
 	return nil
 }
 
+func (r *roomRepository) UpdateParticipantMute(roomID, userID uint, muted bool) error {
+	if err := r.db.Model(&domain.Participant{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Update("is_muted", muted).Error; err != nil {
+		return fmt.Errorf("failed to update mute: %w", err)
+	}
+	return nil
+}
+
+func (r *roomRepository) UpdateParticipantSilenced(roomID, userID uint, silenced bool) error {
+	if err := r.db.Model(&domain.Participant{}).
+		Where("room_id = ? AND user_id = ? AND left_at IS NULL", roomID, userID).
+		Update("is_silenced", silenced).Error; err != nil {
+		return fmt.Errorf("failed to update silenced: %w", err)
+	}
+	return nil
+}
+
+func (r *roomRepository) UpdateParticipantRole(roomID, userID uint, role domain.ParticipantRole) error {
+	if err := r.db.Model(&domain.Participant{}).
+		Where("room_id = ? AND user_id = ? AND left_at IS NULL", roomID, userID).
+		Update("role", role).Error; err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
 func (r *roomRepository) GetUnreadCount(roomID, userID uint) (int64, error) {
 	var participant domain.Participant
 	err := r.db.Where("room_id = ? AND user_id = ?", roomID, userID).
@@ -191,3 +263,121 @@ func (r *roomRepository) GetUnreadCount(roomID, userID uint) (int64, error) {
 
 	return count, nil
 }
+
+// GetUnreadCounts returns the unread message count of every room the user
+// currently participates in, keyed by room ID, using a single query. Rooms
+// with nothing unread are omitted; muted rooms are counted like any other.
+func (r *roomRepository) GetUnreadCounts(userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		RoomID uint
+		Count  int64
+	}
+
+	err := r.db.Model(&domain.Message{}).
+		Select("messages.room_id, COUNT(*) AS count").
+		Joins("JOIN participants ON participants.room_id = messages.room_id AND participants.user_id = ? AND participants.left_at IS NULL", userID).
+		Where("messages.created_at > participants.last_read_at AND messages.sender_id != ?", userID).
+		Group("messages.room_id").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.RoomID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetRoomUnreadCounts returns the unread message count of every current
+// participant of the room, keyed by user ID, using a single query.
+// Participants with nothing unread are omitted, and so are participants who
+// muted the room, since this feeds their unread notifications.
+func (r *roomRepository) GetRoomUnreadCounts(roomID uint) (map[uint]int64, error) {
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+
+	err := r.db.Model(&domain.Participant{}).
+		Select("participants.user_id, COUNT(*) AS count").
+		Joins("JOIN messages ON messages.room_id = participants.room_id AND messages.created_at > participants.last_read_at AND messages.sender_id != participants.user_id").
+		Where("participants.room_id = ? AND participants.left_at IS NULL AND participants.is_muted = ?", roomID, false).
+		Group("participants.user_id").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetUnreadMentionCounts returns the number of unread mentions of the user
+// per room, keyed by room ID. Rooms without unread mentions are omitted.
+func (r *roomRepository) GetUnreadMentionCounts(userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		RoomID uint
+		Count  int64
+	}
+
+	err := r.db.Model(&domain.Mention{}).
+		Select("mentions.room_id, COUNT(*) AS count").
+		Joins("JOIN participants ON participants.room_id = mentions.room_id AND participants.user_id = mentions.user_id AND participants.left_at IS NULL").
+		Where("mentions.user_id = ? AND mentions.created_at > participants.last_read_at", userID).
+		Group("mentions.room_id").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread mentions: %w", err)
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.RoomID] = row.Count
+	}
+	return counts, nil
+}
+
+// FindStaleRooms returns unarchived rooms that were created before cutoff and
+// have had no messages since. Rooms flagged NeverAutoArchive are skipped.
+func (r *roomRepository) FindStaleRooms(cutoff time.Time) ([]*domain.Room, error) {
+	var rooms []*domain.Room
+
+	err := r.db.
+		Where("is_archived = ? AND never_auto_archive = ? AND created_at < ?", false, false, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM messages WHERE messages.room_id = rooms.id AND messages.created_at >= ?)", cutoff).
+		Order("id").
+		Find(&rooms).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stale rooms: %w", err)
+	}
+	return rooms, nil
+}
+
+// FindRoomsWithRetention returns the rooms that purge old messages
+func (r *roomRepository) FindRoomsWithRetention() ([]*domain.Room, error) {
+	var rooms []*domain.Room
+
+	err := r.db.
+		Where("retention_days IS NOT NULL").
+		Order("id").
+		Find(&rooms).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rooms with retention: %w", err)
+	}
+	return rooms, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
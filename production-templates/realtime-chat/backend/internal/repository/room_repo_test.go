@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+)
+
+func TestRoomRepository_GetUnreadCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+
+	alice := &domain.User{Email: "a@example.com", Username: "alice", PasswordHash: "x"}
+	bob := &domain.User{Email: "b@example.com", Username: "bob", PasswordHash: "x"}
+	db.Create(alice)
+	db.Create(bob)
+
+	now := time.Now()
+	read := &domain.Room{Name: "read", CreatorID: bob.ID}
+	unread := &domain.Room{Name: "unread", CreatorID: bob.ID}
+	left := &domain.Room{Name: "left", CreatorID: bob.ID}
+	muted := &domain.Room{Name: "muted", CreatorID: bob.ID}
+	for _, room := range []*domain.Room{read, unread, left, muted} {
+		db.Create(room)
+		seedMessages(t, db, room.ID, bob.ID, 4)
+	}
+	seedMessages(t, db, unread.ID, alice.ID, 2) // own messages are never unread
+
+	db.Create(&domain.Participant{RoomID: read.ID, UserID: alice.ID, LastReadAt: now, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: unread.ID, UserID: alice.ID, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: left.ID, UserID: alice.ID, JoinedAt: now, LeftAt: &now})
+	db.Create(&domain.Participant{RoomID: muted.ID, UserID: alice.ID, JoinedAt: now, IsMuted: true})
+
+	counts, err := repo.GetUnreadCounts(alice.ID)
+	if err != nil {
+		t.Fatalf("GetUnreadCounts() error = %v", err)
+	}
+
+	// A muted room still lists what is unread in it
+	if len(counts) != 2 || counts[unread.ID] != 4 || counts[muted.ID] != 4 {
+		t.Errorf("GetUnreadCounts() = %v, want rooms %d and %d with 4", counts, unread.ID, muted.ID)
+	}
+
+	single, err := repo.GetUnreadCount(unread.ID, alice.ID)
+	if err != nil || single != counts[unread.ID] {
+		t.Errorf("GetUnreadCount() = %d, %v, want it to match the batched count %d", single, err, counts[unread.ID])
+	}
+}
+
+func TestRoomRepository_GetRoomUnreadCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+
+	var users []*domain.User
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		user := &domain.User{Email: name + "@example.com", Username: name, PasswordHash: "x"}
+		db.Create(user)
+		users = append(users, user)
+	}
+	alice, bob, carol, dave, erin := users[0], users[1], users[2], users[3], users[4]
+
+	room := &domain.Room{Name: "general", CreatorID: bob.ID}
+	other := &domain.Room{Name: "other", CreatorID: bob.ID}
+	db.Create(room)
+	db.Create(other)
+	seedMessages(t, db, room.ID, bob.ID, 3)
+	seedMessages(t, db, room.ID, alice.ID, 2)
+	seedMessages(t, db, other.ID, bob.ID, 4)
+
+	now := time.Now()
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: alice.ID, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: bob.ID, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: carol.ID, LastReadAt: now, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: dave.ID, JoinedAt: now, LeftAt: &now})
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: erin.ID, JoinedAt: now, IsMuted: true})
+	db.Create(&domain.Participant{RoomID: other.ID, UserID: carol.ID, JoinedAt: now})
+
+	counts, err := repo.GetRoomUnreadCounts(room.ID)
+	if err != nil {
+		t.Fatalf("GetRoomUnreadCounts() error = %v", err)
+	}
+
+	// Own messages are never unread; carol read everything, dave left and
+	// erin muted the room
+	if len(counts) != 2 || counts[alice.ID] != 3 || counts[bob.ID] != 2 {
+		t.Errorf("GetRoomUnreadCounts() = %v, want alice 3 and bob 2", counts)
+	}
+}
+
+func TestRoomRepository_UnreadCountAfterMarkRoomAsRead(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+	messageRepo := NewMessageRepository(db)
+
+	alice := &domain.User{Email: "a@example.com", Username: "alice", PasswordHash: "x"}
+	bob := &domain.User{Email: "b@example.com", Username: "bob", PasswordHash: "x"}
+	db.Create(alice)
+	db.Create(bob)
+
+	room := &domain.Room{Name: "general", CreatorID: bob.ID}
+	db.Create(room)
+	messages := seedMessages(t, db, room.ID, bob.ID, 5)
+	seedMessages(t, db, room.ID, alice.ID, 2)
+	db.Create(&domain.Participant{RoomID: room.ID, UserID: alice.ID, JoinedAt: time.Now()})
+
+	// One message was already read on its own
+	if err := messageRepo.MarkAsRead(messages[1].ID, alice.ID); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+
+	if count, _ := repo.GetUnreadCount(room.ID, alice.ID); count != 5 {
+		t.Fatalf("GetUnreadCount() before = %d, want 5", count)
+	}
+
+	marked, err := messageRepo.MarkRoomAsRead(room.ID, alice.ID, time.Now())
+	if err != nil {
+		t.Fatalf("MarkRoomAsRead() error = %v", err)
+	}
+	if marked != 4 {
+		t.Errorf("MarkRoomAsRead() marked %d messages, want the 4 without a receipt", marked)
+	}
+
+	var receipts int64
+	db.Model(&domain.ReadReceipt{}).Where("user_id = ?", alice.ID).Count(&receipts)
+	if receipts != 5 {
+		t.Errorf("alice has %d read receipts, want one per message from bob", receipts)
+	}
+
+	count, err := repo.GetUnreadCount(room.ID, alice.ID)
+	if err != nil || count != 0 {
+		t.Errorf("GetUnreadCount() after MarkRoomAsRead = %d, %v, want 0", count, err)
+	}
+
+	// Nothing is left to mark
+	if marked, err := messageRepo.MarkRoomAsRead(room.ID, alice.ID, time.Now()); err != nil || marked != 0 {
+		t.Errorf("MarkRoomAsRead() again = %d, %v, want 0", marked, err)
+	}
+
+	if _, err := messageRepo.MarkRoomAsRead(room.ID, bob.ID, time.Now()); err == nil {
+		t.Error("MarkRoomAsRead() by a non-participant should fail")
+	}
+}
+
+func TestRoomRepository_SearchUserRooms(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+
+	alice := &domain.User{Email: "a@example.com", Username: "alice", PasswordHash: "x"}
+	bob := &domain.User{Email: "b@example.com", Username: "bob", DisplayName: "Bobby Tables", PasswordHash: "x"}
+	db.Create(alice)
+	db.Create(bob)
+
+	general := &domain.Room{Name: "General Chat", Type: domain.RoomTypeGroup, CreatorID: alice.ID}
+	percent := &domain.Room{Name: "100% pure", Type: domain.RoomTypeGroup, CreatorID: alice.ID}
+	underscore := &domain.Room{Name: "under_score", Type: domain.RoomTypeGroup, CreatorID: alice.ID}
+	direct := &domain.Room{Type: domain.RoomTypeDirect, CreatorID: alice.ID}
+	left := &domain.Room{Name: "general (old)", Type: domain.RoomTypeGroup, CreatorID: alice.ID}
+	other := &domain.Room{Name: "general for bob", Type: domain.RoomTypeGroup, CreatorID: bob.ID}
+	for _, room := range []*domain.Room{general, percent, underscore, direct, left, other} {
+		db.Create(room)
+	}
+
+	now := time.Now()
+	for _, room := range []*domain.Room{general, percent, underscore, direct} {
+		db.Create(&domain.Participant{RoomID: room.ID, UserID: alice.ID, JoinedAt: now})
+	}
+	db.Create(&domain.Participant{RoomID: direct.ID, UserID: bob.ID, JoinedAt: now})
+	db.Create(&domain.Participant{RoomID: left.ID, UserID: alice.ID, JoinedAt: now, LeftAt: &now})
+	db.Create(&domain.Participant{RoomID: other.ID, UserID: bob.ID, JoinedAt: now})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{name: "case-insensitive name", query: "GENERAL", want: []uint{general.ID}},
+		{name: "percent is literal", query: "%", want: []uint{percent.ID}},
+		{name: "underscore is literal", query: "_", want: []uint{underscore.ID}},
+		{name: "backslash is literal", query: `\`, want: nil},
+		{name: "direct room by username", query: "bob", want: []uint{direct.ID}},
+		{name: "direct room by display name", query: "tables", want: []uint{direct.ID}},
+		{name: "own username does not match", query: "alice", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rooms, err := repo.SearchUserRooms(alice.ID, tt.query)
+			if err != nil {
+				t.Fatalf("SearchUserRooms() error = %v", err)
+			}
+
+			var got []uint
+			for _, room := range rooms {
+				got = append(got, room.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("SearchUserRooms(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoomRepository_FindStaleRooms(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+
+	owner := &domain.User{Email: "o@example.com", Username: "owner", PasswordHash: "x"}
+	db.Create(owner)
+
+	now := time.Now()
+	cutoff := now.Add(-30 * time.Minute)
+	longAgo := now.Add(-48 * time.Hour)
+
+	silent := &domain.Room{Name: "silent", CreatorID: owner.ID, CreatedAt: longAgo}
+	quiet := &domain.Room{Name: "quiet", CreatorID: owner.ID, CreatedAt: longAgo}
+	active := &domain.Room{Name: "active", CreatorID: owner.ID, CreatedAt: longAgo}
+	exempt := &domain.Room{Name: "exempt", CreatorID: owner.ID, CreatedAt: longAgo, NeverAutoArchive: true}
+	archived := &domain.Room{Name: "archived", CreatorID: owner.ID, CreatedAt: longAgo, IsArchived: true}
+	fresh := &domain.Room{Name: "fresh", CreatorID: owner.ID, CreatedAt: now}
+	for _, room := range []*domain.Room{silent, quiet, active, exempt, archived, fresh} {
+		db.Create(room)
+	}
+
+	seedMessages(t, db, quiet.ID, owner.ID, 3) // last message an hour ago
+	seedMessages(t, db, active.ID, owner.ID, 3)
+	db.Create(&domain.Message{RoomID: active.ID, SenderID: owner.ID, Content: "still here", Type: domain.MessageTypeText})
+
+	rooms, err := repo.FindStaleRooms(cutoff)
+	if err != nil {
+		t.Fatalf("FindStaleRooms() error = %v", err)
+	}
+
+	got := make([]uint, len(rooms))
+	for i, room := range rooms {
+		got[i] = room.ID
+	}
+	if len(got) != 2 || got[0] != silent.ID || got[1] != quiet.ID {
+		t.Errorf("FindStaleRooms() = %v, want [%d %d]", got, silent.ID, quiet.ID)
+	}
+}
+
+func TestRoomRepository_FindRoomsWithRetention(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRoomRepository(db)
+
+	week := 7
+	kept := &domain.Room{Name: "kept", CreatorID: 1}
+	purged := &domain.Room{Name: "purged", CreatorID: 1, RetentionDays: &week}
+	for _, room := range []*domain.Room{kept, purged} {
+		db.Create(room)
+	}
+
+	rooms, err := repo.FindRoomsWithRetention()
+	if err != nil {
+		t.Fatalf("FindRoomsWithRetention() error = %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].ID != purged.ID || *rooms[0].RetentionDays != week {
+		t.Errorf("FindRoomsWithRetention() returned %d rooms, want only %q", len(rooms), purged.Name)
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"realtime-chat/internal/domain"
+)
+
+type ScheduledMessageRepository interface {
+	Create(message *domain.ScheduledMessage) error
+	FindByID(id uint) (*domain.ScheduledMessage, error)
+	// FindPending returns the room's messages still waiting to be sent,
+	// soonest first. A senderID of 0 returns everyone's.
+	FindPending(roomID, senderID uint) ([]*domain.ScheduledMessage, error)
+	// FindDue returns up to limit pending messages whose send time is at or
+	// before now, soonest first
+	FindDue(now time.Time, limit int) ([]*domain.ScheduledMessage, error)
+	// UpdateStatus moves the message from one status to another and reports
+	// whether it was still in the from status, so only one worker can claim
+	// a message
+	UpdateStatus(id uint, from, to domain.ScheduledMessageStatus) (bool, error)
+	MarkSent(id, messageID uint, sentAt time.Time) error
+	MarkFailed(id uint, reason string) error
+	// DeletePending deletes the message unless it is being or was already
+	// sent, and reports whether it did
+	DeletePending(id uint) (bool, error)
+}
+
+type scheduledMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepository(db *gorm.DB) ScheduledMessageRepository {
+	return &scheduledMessageRepository{db: db}
+}
+
+func (r *scheduledMessageRepository) Create(message *domain.ScheduledMessage) error {
+	if err := r.db.Create(message).Error; err != nil {
+		return fmt.Errorf("failed to schedule message: %w", err)
+	}
+	return nil
+}
+
+func (r *scheduledMessageRepository) FindByID(id uint) (*domain.ScheduledMessage, error) {
+	var message domain.ScheduledMessage
+	err := r.db.Preload("Sender").First(&message, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("scheduled message not found with id %d", id)
+		}
+		return nil, fmt.Errorf("failed to find scheduled message: %w", err)
+	}
+	return &message, nil
+}
+
+func (r *scheduledMessageRepository) FindPending(roomID, senderID uint) ([]*domain.ScheduledMessage, error) {
+	db := r.db.Where("room_id = ? AND status = ?", roomID, domain.ScheduledMessagePending)
+	if senderID != 0 {
+		db = db.Where("sender_id = ?", senderID)
+	}
+
+	var messages []*domain.ScheduledMessage
+	err := db.Preload("Sender").
+		Order("send_at ASC, id ASC").
+		Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scheduled messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *scheduledMessageRepository) FindDue(now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	var messages []*domain.ScheduledMessage
+	err := r.db.Where("status = ? AND send_at <= ?", domain.ScheduledMessagePending, now).
+		Order("send_at ASC, id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due scheduled messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *scheduledMessageRepository) UpdateStatus(id uint, from, to domain.ScheduledMessageStatus) (bool, error) {
+	result := r.db.Model(&domain.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update scheduled message: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *scheduledMessageRepository) MarkSent(id, messageID uint, sentAt time.Time) error {
+	if err := r.db.Model(&domain.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     domain.ScheduledMessageSent,
+			"message_id": messageID,
+			"sent_at":    sentAt,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to mark scheduled message sent: %w", err)
+	}
+	return nil
+}
+
+func (r *scheduledMessageRepository) MarkFailed(id uint, reason string) error {
+	if err := r.db.Model(&domain.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status": domain.ScheduledMessageFailed,
+			"error":  reason,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to mark scheduled message failed: %w", err)
+	}
+	return nil
+}
+
+func (r *scheduledMessageRepository) DeletePending(id uint) (bool, error) {
+	result := r.db.Where("id = ? AND status = ?", id, domain.ScheduledMessagePending).
+		Delete(&domain.ScheduledMessage{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to cancel scheduled message: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
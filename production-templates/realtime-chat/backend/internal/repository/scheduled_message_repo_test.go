@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+)
+
+func TestScheduledMessageRepository(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewScheduledMessageRepository(db)
+
+	sender := &domain.User{Email: "alice@example.com", Username: "alice", PasswordHash: "x"}
+	db.Create(sender)
+
+	now := time.Now()
+	var scheduled []*domain.ScheduledMessage
+	for _, sendAt := range []time.Time{now.Add(time.Minute), now.Add(-time.Minute), now.Add(-time.Hour)} {
+		message := &domain.ScheduledMessage{
+			RoomID:   1,
+			SenderID: sender.ID,
+			Type:     domain.MessageTypeText,
+			Content:  "later",
+			SendAt:   sendAt,
+			Status:   domain.ScheduledMessagePending,
+		}
+		if err := repo.Create(message); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		scheduled = append(scheduled, message)
+	}
+
+	due, err := repo.FindDue(now, 10)
+	if err != nil {
+		t.Fatalf("FindDue() error = %v", err)
+	}
+	if len(due) != 2 || due[0].ID != scheduled[2].ID || due[1].ID != scheduled[1].ID {
+		t.Fatalf("FindDue() = %d messages, want the two past ones, oldest first", len(due))
+	}
+
+	// Only one worker can claim a message
+	if claimed, err := repo.UpdateStatus(due[0].ID, domain.ScheduledMessagePending, domain.ScheduledMessageSending); err != nil || !claimed {
+		t.Fatalf("UpdateStatus() = %v, %v, want the message claimed", claimed, err)
+	}
+	if claimed, _ := repo.UpdateStatus(due[0].ID, domain.ScheduledMessagePending, domain.ScheduledMessageSending); claimed {
+		t.Error("UpdateStatus() claimed a message twice")
+	}
+	if due, _ := repo.FindDue(now, 10); len(due) != 1 {
+		t.Errorf("FindDue() = %d messages after a claim, want 1", len(due))
+	}
+
+	// Claimed messages can no longer be cancelled
+	if deleted, _ := repo.DeletePending(scheduled[2].ID); deleted {
+		t.Error("DeletePending() deleted a message that is being sent")
+	}
+	if deleted, err := repo.DeletePending(scheduled[0].ID); err != nil || !deleted {
+		t.Errorf("DeletePending() = %v, %v, want the pending message deleted", deleted, err)
+	}
+
+	if pending, _ := repo.FindPending(1, sender.ID); len(pending) != 1 || pending[0].ID != scheduled[1].ID {
+		t.Errorf("FindPending() = %d messages, want only the one still pending", len(pending))
+	}
+}
@@ -0,0 +1,112 @@
+package sanitize
+
+import "testing"
+
+func TestSanitizer_Escape(t *testing.T) {
+	s := NewSanitizer(ModeEscape)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "script tag",
+			content: `<script>alert("xss")</script>`,
+			want:    `&lt;script&gt;alert(&#34;xss&#34;)&lt;/script&gt;`,
+		},
+		{
+			name:    "event handler attribute",
+			content: `<img src=x onerror='alert(1)'>`,
+			want:    `&lt;img src=x onerror=&#39;alert(1)&#39;&gt;`,
+		},
+		{
+			name:    "plain text",
+			content: "hello, world",
+			want:    "hello, world",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Sanitize(tt.content); got != tt.want {
+				t.Errorf("Sanitize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizer_Markdown(t *testing.T) {
+	s := NewSanitizer(ModeMarkdown)
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "script tag",
+			content: `<script>alert("xss")</script>`,
+			want:    `&lt;script&gt;alert("xss")&lt;/script&gt;`,
+		},
+		{
+			name:    "emphasis and code survive",
+			content: "**bold** _italic_ `code` ~~gone~~",
+			want:    "**bold** _italic_ `code` ~~gone~~",
+		},
+		{
+			name:    "blockquote survives",
+			content: "> quoted\n>> nested <b>x</b>\nplain > text",
+			want:    "> quoted\n>> nested &lt;b&gt;x&lt;/b&gt;\nplain &gt; text",
+		},
+		{
+			name:    "safe link survives",
+			content: `[docs](https://example.com/a?b=1&c=2 "Title")`,
+			want:    `[docs](https://example.com/a?b=1&amp;c=2 "Title")`,
+		},
+		{
+			name:    "javascript link",
+			content: "[click](javascript:alert(1))",
+			want:    "[click](#))",
+		},
+		{
+			name:    "obfuscated javascript link",
+			content: "[click]( JaVa\tScRiPt:alert`1`)",
+			want:    "[click](#)",
+		},
+		{
+			name:    "entity encoded scheme",
+			content: "[click](&#106;avascript:alert`1`)",
+			want:    "[click](#)",
+		},
+		{
+			name:    "data image",
+			content: "![x](data:text/html;base64,PHNjcmlwdD4=)",
+			want:    "![x](#)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Sanitize(tt.content); got != tt.want {
+				t.Errorf("Sanitize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := map[string]Mode{
+		"markdown":  ModeMarkdown,
+		" Markdown": ModeMarkdown,
+		"escape":    ModeEscape,
+		"":          ModeEscape,
+		"bogus":     ModeEscape,
+	}
+
+	for in, want := range tests {
+		if got := ParseMode(in); got != want {
+			t.Errorf("ParseMode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
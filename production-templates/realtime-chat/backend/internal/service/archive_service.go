@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+	"realtime-chat/internal/websocket"
+)
+
+type ArchiveService interface {
+	// ArchiveStale archives every room without messages for the configured
+	// period and returns the IDs of the rooms it archived.
+	ArchiveStale(now time.Time) ([]uint, error)
+	// Run calls ArchiveStale every interval. It never returns.
+	Run(interval time.Duration)
+}
+
+type archiveService struct {
+	roomRepo   repository.RoomRepository
+	hub        *websocket.Hub
+	staleAfter time.Duration
+}
+
+func NewArchiveService(
+	roomRepo repository.RoomRepository,
+	hub *websocket.Hub,
+	staleAfter time.Duration,
+) ArchiveService {
+	return &archiveService{
+		roomRepo:   roomRepo,
+		hub:        hub,
+		staleAfter: staleAfter,
+	}
+}
+
+func (s *archiveService) ArchiveStale(now time.Time) ([]uint, error) {
+	cutoff := now.Add(-s.staleAfter)
+
+	rooms, err := s.roomRepo.FindStaleRooms(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		room.IsArchived = true
+		if err := s.roomRepo.Update(room); err != nil {
+			return archived, fmt.Errorf("failed to archive room %d: %w", room.ID, err)
+		}
+		archived = append(archived, room.ID)
+
+		s.notifyArchived(room, cutoff)
+	}
+
+	return archived, nil
+}
+
+func (s *archiveService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		archived, err := s.ArchiveStale(now)
+		if err != nil {
+			log.Printf("Failed to archive stale rooms: %v", err)
+		}
+		if len(archived) > 0 {
+			log.Printf("Archived %d inactive rooms: %v", len(archived), archived)
+		}
+	}
+}
+
+// Helper methods
+
+func (s *archiveService) notifyArchived(room *domain.Room, inactiveSince time.Time) {
+	if s.hub == nil {
+		return
+	}
+
+	notice := &domain.AutoArchiveNotice{
+		RoomID:        room.ID,
+		InactiveSince: inactiveSince,
+		AdminIDs:      []uint{room.CreatorID},
+	}
+
+	participants, err := s.roomRepo.GetParticipants(room.ID)
+	if err != nil {
+		log.Printf("Failed to load participants for room %d: %v", room.ID, err)
+	}
+	for _, participant := range participants {
+		if participant.Role.AtLeast(domain.ParticipantRoleAdmin) && participant.UserID != room.CreatorID {
+			notice.AdminIDs = append(notice.AdminIDs, participant.UserID)
+		}
+	}
+
+	s.hub.Broadcast(websocket.NewMessage(websocket.MessageTypeRoomArchived, room.ID, 0, notice))
+}
@@ -1,37 +1,76 @@
 package service
 
 import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"time"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 
 	"realtime-chat/internal/domain"
 	"realtime-chat/internal/repository"
+	"realtime-chat/internal/storage"
+	"realtime-chat/internal/websocket"
 )
 
+const (
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+)
+
+// avatarTypes maps the image types accepted as avatars to their extension
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
 type AuthService interface {
 	Register(req *domain.RegisterRequest) (*domain.AuthResponse, error)
 	Login(req *domain.LoginRequest) (*domain.AuthResponse, error)
 	RefreshToken(refreshToken string) (*domain.AuthResponse, error)
 	GetUserByID(userID uint) (*domain.User, error)
 	UpdateProfile(userID uint, req *domain.UpdateProfileRequest) (*domain.User, error)
+	UploadAvatar(userID uint, file *domain.AvatarUpload) (*domain.User, error)
 	SearchUsers(query string, limit int) ([]*domain.User, error)
 }
 
 type authService struct {
 	userRepo      repository.UserRepository
+	roomRepo      repository.RoomRepository
+	storage       storage.Storage
+	hub           *websocket.Hub
 	jwtSecret     string
 	jwtExpiration time.Duration
+	maxAvatarSize int64 // 0 means no limit
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string, jwtExpiration time.Duration) AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	roomRepo repository.RoomRepository,
+	fileStorage storage.Storage,
+	hub *websocket.Hub,
+	jwtSecret string,
+	jwtExpiration time.Duration,
+	maxAvatarSize int64,
+) AuthService {
 	return &authService{
 		userRepo:      userRepo,
+		roomRepo:      roomRepo,
+		storage:       fileStorage,
+		hub:           hub,
 		jwtSecret:     jwtSecret,
 		jwtExpiration: jwtExpiration,
+		maxAvatarSize: maxAvatarSize,
 	}
 }
 
@@ -208,12 +247,21 @@ func (s *authService) UpdateProfile(userID uint, req *domain.UpdateProfileReques
 
 	// Update fields if provided
 	if req.DisplayName != "" {
+		if utf8.RuneCountInString(req.DisplayName) > maxDisplayNameLength {
+			return nil, fmt.Errorf("display name cannot be longer than %d characters", maxDisplayNameLength)
+		}
 		user.DisplayName = req.DisplayName
 	}
-	if req.AvatarURL != "" {
-		user.AvatarURL = req.AvatarURL
+	if req.Bio != nil {
+		if utf8.RuneCountInString(*req.Bio) > maxBioLength {
+			return nil, fmt.Errorf("bio cannot be longer than %d characters", maxBioLength)
+		}
+		user.Bio = *req.Bio
 	}
 	if req.Status != "" {
+		if !req.Status.Valid() {
+			return nil, fmt.Errorf("invalid status %q", req.Status)
+		}
 		user.Status = req.Status
 	}
 
@@ -221,6 +269,60 @@ func (s *authService) UpdateProfile(userID uint, req *domain.UpdateProfileReques
 		return nil, fmt.Errorf("failed to update profile: %w", err)
 	}
 
+	s.broadcastProfileUpdated(user)
+
+	return user, nil
+}
+
+// UploadAvatar streams an image to storage and makes it the user's avatar,
+// replacing any avatar uploaded before. Files larger than the configured
+// maximum are rejected, whatever size the client declared.
+func (s *authService) UploadAvatar(userID uint, file *domain.AvatarUpload) (*domain.User, error) {
+	if s.storage == nil {
+		return nil, errors.New("avatar storage is not configured")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	if s.maxAvatarSize > 0 && file.Size > s.maxAvatarSize {
+		return nil, fmt.Errorf("avatar exceeds the maximum size of %d bytes", s.maxAvatarSize)
+	}
+
+	// Trust the content rather than the declared type or file name
+	content := bufio.NewReader(file.Content)
+	head, _ := content.Peek(512)
+	ext, ok := avatarTypes[http.DetectContentType(head)]
+	if !ok {
+		return nil, errors.New("avatar must be a PNG, JPEG, GIF or WebP image")
+	}
+
+	key, err := avatarKey(userID, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	avatarURL, err := s.storage.Save(key, &limitedReader{r: content, limit: s.maxAvatarSize})
+	if err != nil {
+		if errors.Is(err, errAvatarTooLarge) {
+			return nil, fmt.Errorf("avatar exceeds the maximum size of %d bytes", s.maxAvatarSize)
+		}
+		return nil, fmt.Errorf("failed to store avatar: %w", err)
+	}
+
+	previousKey := user.AvatarKey
+	user.AvatarURL = avatarURL
+	user.AvatarKey = key
+	if err := s.userRepo.Update(user); err != nil {
+		s.deleteStoredFile(key)
+		return nil, fmt.Errorf("failed to update avatar: %w", err)
+	}
+	s.deleteStoredFile(previousKey)
+
+	s.broadcastProfileUpdated(user)
+
 	return user, nil
 }
 
@@ -234,6 +336,64 @@ func (s *authService) SearchUsers(query string, limit int) ([]*domain.User, erro
 
 // Helper methods
 
+// broadcastProfileUpdated tells the user's rooms to refresh their profile
+func (s *authService) broadcastProfileUpdated(user *domain.User) {
+	if s.hub == nil || s.roomRepo == nil {
+		return
+	}
+
+	rooms, err := s.roomRepo.FindByUserID(user.ID)
+	if err != nil {
+		log.Printf("Failed to load rooms for user %d: %v", user.ID, err)
+		return
+	}
+
+	for _, room := range rooms {
+		s.hub.Broadcast(websocket.NewMessage(websocket.MessageTypeUserProfileUpdated, room.ID, user.ID, user))
+	}
+}
+
+// deleteStoredFile removes a file from storage. A leftover file is only
+// logged.
+func (s *authService) deleteStoredFile(key string) {
+	if key == "" {
+		return
+	}
+	if err := s.storage.Delete(key); err != nil {
+		log.Printf("Failed to delete stored file %s: %v", key, err)
+	}
+}
+
+// errAvatarTooLarge aborts an upload once it exceeds the maximum size
+var errAvatarTooLarge = errors.New("avatar too large")
+
+// limitedReader fails with errAvatarTooLarge once more than limit bytes were
+// read through it. A limit of 0 means no limit.
+type limitedReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.limit > 0 && l.n > l.limit {
+		return n, errAvatarTooLarge
+	}
+	return n, err
+}
+
+// avatarKey returns a unique storage key for a user's avatar. A new key per
+// upload keeps cached copies of the previous avatar from being served.
+func avatarKey(userID uint, ext string) (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate avatar key: %w", err)
+	}
+	return fmt.Sprintf("avatars/%d/%s%s", userID, hex.EncodeToString(token), ext), nil
+}
+
 func (s *authService) generateAccessToken(user *domain.User) (string, error) {
 	claims := &domain.JWTClaims{
 		UserID:    user.ID,
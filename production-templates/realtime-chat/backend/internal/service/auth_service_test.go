@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/websocket"
+)
+
+// pngHeader is enough of a PNG for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestAuthService_UpdateProfile(t *testing.T) {
+	bio := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		req     *domain.UpdateProfileRequest
+		wantErr bool
+	}{
+		{name: "display name too long", req: &domain.UpdateProfileRequest{DisplayName: strings.Repeat("a", maxDisplayNameLength+1)}, wantErr: true},
+		{name: "bio too long", req: &domain.UpdateProfileRequest{Bio: bio(strings.Repeat("é", maxBioLength+1))}, wantErr: true},
+		{name: "unknown status", req: &domain.UpdateProfileRequest{Status: "sleeping"}, wantErr: true},
+		{name: "display name and bio", req: &domain.UpdateProfileRequest{DisplayName: "Ada", Bio: bio(strings.Repeat("é", maxBioLength))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUsers(1)[0]
+			user.DisplayName = "before"
+			svc := NewAuthService(newFakeUserRepo(user), newFakeRoomRepo(), nil, nil, "secret", time.Minute, 0)
+
+			updated, err := svc.UpdateProfile(user.ID, tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if user.DisplayName != "before" || user.Bio != "" || user.Status != domain.StatusOffline {
+					t.Errorf("user = %+v, want it unchanged after a failed update", user)
+				}
+				return
+			}
+			if updated.DisplayName != tt.req.DisplayName || updated.Bio != *tt.req.Bio {
+				t.Errorf("UpdateProfile() = %+v, want the new display name and bio", updated)
+			}
+		})
+	}
+}
+
+func TestAuthService_UploadAvatar(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		content []byte
+		wantErr string
+	}{
+		{name: "not an image", content: []byte("just some text"), wantErr: "must be a PNG"},
+		{name: "declared too large", size: 1 << 20, content: pngHeader, wantErr: "maximum size"},
+		{name: "larger than declared", size: 10, content: append(append([]byte{}, pngHeader...), make([]byte, 2048)...), wantErr: "maximum size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUsers(1)[0]
+			files := newMemoryStorage()
+			svc := NewAuthService(newFakeUserRepo(user), newFakeRoomRepo(), files, nil, "secret", time.Minute, 1024)
+
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.content))
+			}
+			_, err := svc.UploadAvatar(user.ID, &domain.AvatarUpload{Size: size, Content: bytes.NewReader(tt.content)})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("UploadAvatar() error = %v, want %q", err, tt.wantErr)
+			}
+			if user.AvatarURL != "" {
+				t.Errorf("AvatarURL = %q, want it unchanged", user.AvatarURL)
+			}
+		})
+	}
+}
+
+func TestAuthService_UploadAvatarBroadcasts(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	users := testUsers(2)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	files := newMemoryStorage()
+	svc := NewAuthService(newFakeUserRepo(users...), roomRepo, files, hub, "secret", time.Minute, 1024)
+
+	first, err := svc.UploadAvatar(users[0].ID, &domain.AvatarUpload{Size: int64(len(pngHeader)), Content: bytes.NewReader(pngHeader)})
+	if err != nil {
+		t.Fatalf("UploadAvatar() error = %v", err)
+	}
+	firstKey := first.AvatarKey
+	if !strings.HasSuffix(first.AvatarURL, firstKey) || !strings.HasSuffix(firstKey, ".png") {
+		t.Errorf("AvatarURL = %q, key = %q, want the stored PNG", first.AvatarURL, firstKey)
+	}
+
+	// Room members are told about the new avatar, which replaces the old one
+	conn := connectTestClient(t, hub, 1, users[1].ID)
+	updated, err := svc.UploadAvatar(users[0].ID, &domain.AvatarUpload{Size: int64(len(pngHeader)), Content: bytes.NewReader(pngHeader)})
+	if err != nil {
+		t.Fatalf("UploadAvatar() error = %v", err)
+	}
+	if _, ok := files.files[firstKey]; ok {
+		t.Error("previous avatar should be deleted from storage")
+	}
+	if len(files.files) != 1 {
+		t.Errorf("storage holds %d files, want 1", len(files.files))
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data domain.User           `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("failed to read broadcast: %v", err)
+	}
+	if event.Type != websocket.MessageTypeUserProfileUpdated || event.Data.AvatarURL != updated.AvatarURL {
+		t.Errorf("broadcast = %s with avatar %q, want %s with %q", event.Type, event.Data.AvatarURL, websocket.MessageTypeUserProfileUpdated, updated.AvatarURL)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"realtime-chat/internal/repository"
+	"realtime-chat/internal/websocket"
+)
+
+type ExpiryService interface {
+	// ExpireDue deletes every ephemeral message whose expiry has passed and
+	// returns the IDs of the messages it deleted.
+	ExpireDue(now time.Time) ([]uint, error)
+	// Run calls ExpireDue every interval. It never returns.
+	Run(interval time.Duration)
+}
+
+type expiryService struct {
+	messageRepo repository.MessageRepository
+	hub         *websocket.Hub
+}
+
+func NewExpiryService(messageRepo repository.MessageRepository, hub *websocket.Hub) ExpiryService {
+	return &expiryService{
+		messageRepo: messageRepo,
+		hub:         hub,
+	}
+}
+
+func (s *expiryService) ExpireDue(now time.Time) ([]uint, error) {
+	expired, err := s.messageRepo.ExpireMessages(now)
+	if err != nil {
+		return nil, err
+	}
+
+	messageIDs := make([]uint, 0, len(expired))
+	for _, message := range expired {
+		messageIDs = append(messageIDs, message.ID)
+
+		// Same event as a manual delete so clients need no special handling
+		if s.hub != nil {
+			s.hub.Broadcast(websocket.NewMessage(websocket.MessageTypeMessageDeleted, message.RoomID, 0, map[string]interface{}{
+				"message_id": message.ID,
+				"room_id":    message.RoomID,
+			}))
+		}
+	}
+
+	return messageIDs, nil
+}
+
+func (s *expiryService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		expired, err := s.ExpireDue(now)
+		if err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+		}
+		if len(expired) > 0 {
+			log.Printf("Deleted %d expired messages", len(expired))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/websocket"
+)
+
+// connectTestClient joins roomID as userID over a real WebSocket connection
+// and waits until the hub has registered it.
+func connectTestClient(t *testing.T, hub *websocket.Hub, roomID, userID uint) *gorillaws.Conn {
+	t.Helper()
+
+	roomRepo := newFakeRoomRepo()
+	roomRepo.AddParticipant(&domain.Participant{RoomID: roomID, UserID: userID})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws/:roomId", func(c *gin.Context) {
+		c.Set("userID", userID)
+	}, websocket.NewWebSocketHandler(hub, roomRepo).HandleConnection)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/" + strconv.FormatUint(uint64(roomID), 10)
+	conn, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(time.Second)
+	for hub.GetClientCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the client to register")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return conn
+}
+
+func TestExpiryService_ExpireDue(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	now := time.Now()
+	past := now.Add(-time.Second)
+	future := now.Add(time.Hour)
+
+	messageRepo := &fakeMessageRepo{}
+	expiring := &domain.Message{RoomID: 1, SenderID: 1, Content: "code 123456", ExpiresAt: &past}
+	lasting := &domain.Message{RoomID: 1, SenderID: 1, Content: "see you", ExpiresAt: &future}
+	permanent := &domain.Message{RoomID: 1, SenderID: 1, Content: "hello"}
+	for _, message := range []*domain.Message{expiring, lasting, permanent} {
+		messageRepo.Create(message)
+	}
+
+	conn := connectTestClient(t, hub, 1, 2)
+	svc := NewExpiryService(messageRepo, hub)
+
+	expired, err := svc.ExpireDue(now)
+	if err != nil {
+		t.Fatalf("ExpireDue() error = %v", err)
+	}
+	if len(expired) != 1 || expired[0] != expiring.ID {
+		t.Fatalf("ExpireDue() = %v, want [%d]", expired, expiring.ID)
+	}
+	if !expiring.IsDeleted || expiring.Content != "[deleted]" {
+		t.Error("expired message should be soft-deleted")
+	}
+	if lasting.IsDeleted || permanent.IsDeleted {
+		t.Error("unexpired messages should be kept")
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data struct {
+			MessageID uint `json:"message_id"`
+		} `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("failed to read broadcast: %v", err)
+	}
+	if event.Type != websocket.MessageTypeMessageDeleted || event.Data.MessageID != expiring.ID {
+		t.Errorf("broadcast = %+v, want %s for message %d", event, websocket.MessageTypeMessageDeleted, expiring.ID)
+	}
+
+	// A second run finds nothing left to expire
+	if again, err := svc.ExpireDue(now); err != nil || len(again) != 0 {
+		t.Errorf("second ExpireDue() = %v, %v, want nothing expired", again, err)
+	}
+}
@@ -0,0 +1,686 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+)
+
+// fakeRoomRepo is an in-memory RoomRepository. Methods a test does not need
+// fall through to the embedded nil interface and panic if called.
+type fakeRoomRepo struct {
+	repository.RoomRepository
+	rooms        map[uint]*domain.Room
+	participants []*domain.Participant
+	unread       map[uint]int64
+	nextID       uint
+}
+
+func newFakeRoomRepo() *fakeRoomRepo {
+	return &fakeRoomRepo{
+		rooms:  make(map[uint]*domain.Room),
+		unread: make(map[uint]int64),
+	}
+}
+
+func (r *fakeRoomRepo) Create(room *domain.Room) error {
+	r.nextID++
+	room.ID = r.nextID
+	room.CreatedAt = time.Now()
+	room.UpdatedAt = room.CreatedAt
+	r.rooms[room.ID] = room
+	return nil
+}
+
+func (r *fakeRoomRepo) FindByID(id uint) (*domain.Room, error) {
+	room, ok := r.rooms[id]
+	if !ok {
+		return nil, fmt.Errorf("room not found with id %d", id)
+	}
+	copied := *room
+	copied.Participants = nil
+	for _, p := range r.participants {
+		if p.RoomID == id && p.LeftAt == nil {
+			copied.Participants = append(copied.Participants, *p)
+		}
+	}
+	return &copied, nil
+}
+
+func (r *fakeRoomRepo) FindByUserID(userID uint) ([]*domain.Room, error) {
+	var rooms []*domain.Room
+	for _, p := range r.participants {
+		if p.UserID == userID && p.LeftAt == nil {
+			room, _ := r.FindByID(p.RoomID)
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+// SearchUserRooms matches room names only; matching direct rooms on the
+// other participant's name is left to the database.
+func (r *fakeRoomRepo) SearchUserRooms(userID uint, query string) ([]*domain.Room, error) {
+	rooms, _ := r.FindByUserID(userID)
+	var matched []*domain.Room
+	for _, room := range rooms {
+		if strings.Contains(strings.ToLower(room.Name), strings.ToLower(query)) {
+			matched = append(matched, room)
+		}
+	}
+	return matched, nil
+}
+
+// GetUnreadCounts reports the counts set in unread, which the fake treats as
+// the unread counts of the user being queried.
+func (r *fakeRoomRepo) GetUnreadCounts(userID uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	for roomID, count := range r.unread {
+		if count > 0 {
+			counts[roomID] = count
+		}
+	}
+	return counts, nil
+}
+
+// GetRoomUnreadCounts reports the room's count set in unread for each of its
+// current participants who have not muted the room.
+func (r *fakeRoomRepo) GetRoomUnreadCounts(roomID uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	if r.unread[roomID] == 0 {
+		return counts, nil
+	}
+	for _, p := range r.participants {
+		if p.RoomID == roomID && p.LeftAt == nil && !p.IsMuted {
+			counts[p.UserID] = r.unread[roomID]
+		}
+	}
+	return counts, nil
+}
+
+// GetUnreadMentionCounts reports no mentions; mention counting is covered by
+// the repository tests.
+func (r *fakeRoomRepo) GetUnreadMentionCounts(userID uint) (map[uint]int64, error) {
+	return map[uint]int64{}, nil
+}
+
+func (r *fakeRoomRepo) FindDirectRoom(user1ID, user2ID uint) (*domain.Room, error) {
+	for _, room := range r.rooms {
+		if room.Type != domain.RoomTypeDirect {
+			continue
+		}
+		_, err1 := r.FindParticipant(room.ID, user1ID)
+		_, err2 := r.FindParticipant(room.ID, user2ID)
+		if err1 == nil && err2 == nil {
+			return r.FindByID(room.ID)
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRoomRepo) Update(room *domain.Room) error {
+	copied := *room
+	r.rooms[room.ID] = &copied
+	return nil
+}
+
+func (r *fakeRoomRepo) AddParticipant(participant *domain.Participant) error {
+	participant.ID = uint(len(r.participants) + 1)
+	r.participants = append(r.participants, participant)
+	return nil
+}
+
+func (r *fakeRoomRepo) RemoveParticipant(roomID, userID uint) error {
+	now := time.Now()
+	for _, p := range r.participants {
+		if p.RoomID == roomID && p.UserID == userID && p.LeftAt == nil {
+			p.LeftAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeRoomRepo) FindParticipant(roomID, userID uint) (*domain.Participant, error) {
+	for _, p := range r.participants {
+		if p.RoomID == roomID && p.UserID == userID && p.LeftAt == nil {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("participant not found")
+}
+
+func (r *fakeRoomRepo) UpdateParticipantMute(roomID, userID uint, muted bool) error {
+	participant, err := r.FindParticipant(roomID, userID)
+	if err != nil {
+		return err
+	}
+	participant.IsMuted = muted
+	return nil
+}
+
+func (r *fakeRoomRepo) UpdateParticipantSilenced(roomID, userID uint, silenced bool) error {
+	participant, err := r.FindParticipant(roomID, userID)
+	if err != nil {
+		return err
+	}
+	participant.IsSilenced = silenced
+	return nil
+}
+
+func (r *fakeRoomRepo) UpdateParticipantRole(roomID, userID uint, role domain.ParticipantRole) error {
+	participant, err := r.FindParticipant(roomID, userID)
+	if err != nil {
+		return err
+	}
+	participant.Role = role
+	return nil
+}
+
+func (r *fakeRoomRepo) FindRoomsWithRetention() ([]*domain.Room, error) {
+	var rooms []*domain.Room
+	for id := uint(1); id <= r.nextID; id++ {
+		if room, ok := r.rooms[id]; ok && room.RetentionDays != nil {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (r *fakeRoomRepo) GetParticipants(roomID uint) ([]*domain.Participant, error) {
+	var participants []*domain.Participant
+	for _, p := range r.participants {
+		if p.RoomID == roomID && p.LeftAt == nil {
+			participants = append(participants, p)
+		}
+	}
+	return participants, nil
+}
+
+// fakeMessageRepo is an in-memory MessageRepository.
+type fakeMessageRepo struct {
+	repository.MessageRepository
+	messages  []*domain.Message
+	mentions  []*domain.Mention
+	reactions []*domain.MessageReaction
+	receipts  []*domain.ReadReceipt
+}
+
+func (r *fakeMessageRepo) Create(message *domain.Message) error {
+	message.ID = uint(len(r.messages) + 1)
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeMessageRepo) FindByID(id uint) (*domain.Message, error) {
+	for _, m := range r.messages {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("message not found with id %d", id)
+}
+
+// Update is a no-op: messages are stored by pointer, so edits are already in
+// place
+func (r *fakeMessageRepo) Update(message *domain.Message) error {
+	return nil
+}
+
+// FindByRoomID returns the room's live messages from since on, oldest first
+func (r *fakeMessageRepo) FindByRoomID(roomID uint, since time.Time, limit, offset int) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, m := range r.messages {
+		if m.RoomID == roomID && !m.IsDeleted && !m.CreatedAt.Before(since) {
+			messages = append(messages, m)
+		}
+	}
+	if offset >= len(messages) {
+		return nil, nil
+	}
+	messages = messages[offset:]
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+func (r *fakeMessageRepo) SoftDelete(messageID, deletedByID uint, deletedAt time.Time) error {
+	message, err := r.FindByID(messageID)
+	if err != nil {
+		return err
+	}
+	message.IsDeleted = true
+	message.DeletedAt = &deletedAt
+	message.DeletedByID = &deletedByID
+	message.Content = "[deleted]"
+	return nil
+}
+
+// BulkSoftDelete and SoftDeleteBySender accept anything; their transactional
+// behaviour is covered by the repository tests.
+func (r *fakeMessageRepo) BulkSoftDelete(roomID uint, messageIDs []uint, deletedByID uint, deletedAt time.Time) error {
+	return nil
+}
+
+func (r *fakeMessageRepo) SoftDeleteBySender(roomID, senderID, deletedByID uint, deletedAt time.Time) ([]uint, error) {
+	return nil, nil
+}
+
+func (r *fakeMessageRepo) ExpireMessages(now time.Time) ([]*domain.Message, error) {
+	var expired []*domain.Message
+	for _, m := range r.messages {
+		if !m.IsDeleted && m.ExpiresAt != nil && !m.ExpiresAt.After(now) {
+			m.IsDeleted = true
+			m.DeletedAt = &now
+			m.Content = "[deleted]"
+			expired = append(expired, m)
+		}
+	}
+	return expired, nil
+}
+
+func (r *fakeMessageRepo) DeleteOlderThan(roomID uint, cutoff time.Time) ([]uint, error) {
+	now := time.Now()
+	var messageIDs []uint
+	for _, m := range r.messages {
+		if m.RoomID == roomID && !m.IsDeleted && m.CreatedAt.Before(cutoff) {
+			m.IsDeleted = true
+			m.DeletedAt = &now
+			m.Content = "[deleted]"
+			messageIDs = append(messageIDs, m.ID)
+		}
+	}
+	return messageIDs, nil
+}
+
+func (r *fakeMessageRepo) CreateMentions(mentions []*domain.Mention) error {
+	for _, mention := range mentions {
+		mention.ID = uint(len(r.mentions) + 1)
+		r.mentions = append(r.mentions, mention)
+	}
+	return nil
+}
+
+// AddReaction ignores a user reacting twice with the same emoji, like the
+// unique index does
+func (r *fakeMessageRepo) AddReaction(reaction *domain.MessageReaction) error {
+	for _, existing := range r.reactions {
+		if existing.MessageID == reaction.MessageID && existing.UserID == reaction.UserID && existing.Emoji == reaction.Emoji {
+			return nil
+		}
+	}
+	reaction.ID = uint(len(r.reactions) + 1)
+	reaction.CreatedAt = time.Now()
+	r.reactions = append(r.reactions, reaction)
+	return nil
+}
+
+func (r *fakeMessageRepo) GetReactions(messageID uint) ([]*domain.MessageReaction, error) {
+	var reactions []*domain.MessageReaction
+	for _, reaction := range r.reactions {
+		if reaction.MessageID == messageID {
+			reactions = append(reactions, reaction)
+		}
+	}
+	return reactions, nil
+}
+
+func (r *fakeMessageRepo) CountReactions(messageIDs []uint, userID uint) (map[uint][]domain.ReactionCount, error) {
+	counts := make(map[uint][]domain.ReactionCount)
+	for _, id := range messageIDs {
+		reactions, _ := r.GetReactions(id)
+		byEmoji := make(map[string]int)
+		for _, reaction := range reactions {
+			i, ok := byEmoji[reaction.Emoji]
+			if !ok {
+				i = len(counts[id])
+				byEmoji[reaction.Emoji] = i
+				counts[id] = append(counts[id], domain.ReactionCount{Emoji: reaction.Emoji})
+			}
+			counts[id][i].Count++
+			if reaction.UserID == userID {
+				counts[id][i].ReactedByMe = true
+			}
+		}
+		sort.SliceStable(counts[id], func(i, j int) bool {
+			return counts[id][i].Count > counts[id][j].Count
+		})
+	}
+	return counts, nil
+}
+
+func (r *fakeMessageRepo) GetReadReceipts(messageID uint) ([]*domain.ReadReceipt, error) {
+	var receipts []*domain.ReadReceipt
+	for _, receipt := range r.receipts {
+		if receipt.MessageID == messageID {
+			receipts = append(receipts, receipt)
+		}
+	}
+	sort.SliceStable(receipts, func(i, j int) bool {
+		return receipts[i].ReadAt.After(receipts[j].ReadAt)
+	})
+	return receipts, nil
+}
+
+// MarkRoomAsRead adds receipts for the room's messages from others that the
+// user has none for yet. Unlike the real repository it ignores last_read_at.
+func (r *fakeMessageRepo) MarkRoomAsRead(roomID, userID uint, readAt time.Time) (int64, error) {
+	read := make(map[uint]bool)
+	for _, receipt := range r.receipts {
+		if receipt.UserID == userID {
+			read[receipt.MessageID] = true
+		}
+	}
+	var marked int64
+	for _, m := range r.messages {
+		if m.RoomID != roomID || m.SenderID == userID || read[m.ID] || m.CreatedAt.After(readAt) {
+			continue
+		}
+		r.receipts = append(r.receipts, &domain.ReadReceipt{MessageID: m.ID, UserID: userID, ReadAt: readAt})
+		marked++
+	}
+	return marked, nil
+}
+
+func (r *fakeMessageRepo) GetLastReadMessageIDs(roomID uint) (map[uint]uint, error) {
+	lastRead := make(map[uint]uint)
+	for _, receipt := range r.receipts {
+		message, err := r.FindByID(receipt.MessageID)
+		if err != nil || message.RoomID != roomID {
+			continue
+		}
+		if receipt.MessageID > lastRead[receipt.UserID] {
+			lastRead[receipt.UserID] = receipt.MessageID
+		}
+	}
+	return lastRead, nil
+}
+
+// FindThread returns the root and its replies, directly or not, in the order
+// they were created
+func (r *fakeMessageRepo) FindThread(rootMessageID uint, limit, offset int) ([]*domain.Message, error) {
+	inThread := map[uint]bool{rootMessageID: true}
+	var thread []*domain.Message
+	for _, m := range r.messages {
+		if m.ID == rootMessageID || (m.ReplyToID != nil && inThread[*m.ReplyToID]) {
+			inThread[m.ID] = true
+			thread = append(thread, m)
+		}
+	}
+	if len(thread) == 0 {
+		return nil, fmt.Errorf("message not found with id %d", rootMessageID)
+	}
+	thread = thread[min(offset, len(thread)):]
+	if limit > 0 {
+		thread = thread[:min(limit, len(thread))]
+	}
+	return thread, nil
+}
+
+func (r *fakeMessageRepo) CountReplies(messageIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	for _, id := range messageIDs {
+		for _, m := range r.messages {
+			if m.ReplyToID != nil && *m.ReplyToID == id && !m.IsDeleted {
+				counts[id]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *fakeMessageRepo) GetLastMessage(roomID uint) (*domain.Message, error) {
+	var last *domain.Message
+	for _, m := range r.messages {
+		if m.RoomID == roomID && (last == nil || m.CreatedAt.After(last.CreatedAt)) {
+			last = m
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no messages in room %d", roomID)
+	}
+	return last, nil
+}
+
+// fakeUserRepo is an in-memory UserRepository.
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[uint]*domain.User
+}
+
+func newFakeUserRepo(users ...*domain.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: make(map[uint]*domain.User)}
+	for _, u := range users {
+		r.users[u.ID] = u
+	}
+	return r
+}
+
+func (r *fakeUserRepo) FindByID(id uint) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found with id %d", id)
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepo) Update(user *domain.User) error {
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateStatus(userID uint, status domain.UserStatus) error {
+	user, err := r.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	user.Status = status
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateLastSeen(userID uint) error {
+	user, err := r.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	user.LastSeenAt = &now
+	return nil
+}
+
+func (r *fakeUserRepo) FindByUsername(username string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found with username %s", username)
+}
+
+func testUsers(n int) []*domain.User {
+	users := make([]*domain.User, n)
+	for i := range users {
+		id := uint(i + 1)
+		users[i] = &domain.User{
+			ID:       id,
+			Email:    fmt.Sprintf("user%d@example.com", id),
+			Username: fmt.Sprintf("user%d", id),
+			Status:   domain.StatusOffline,
+		}
+	}
+	return users
+}
+
+// memoryStorage is an in-memory storage.Storage
+type memoryStorage struct {
+	files map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: make(map[string][]byte)}
+}
+
+func (m *memoryStorage) Save(key string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	m.files[key] = data
+	return "https://files.example.com/" + key, nil
+}
+
+func (m *memoryStorage) Delete(key string) error {
+	delete(m.files, key)
+	return nil
+}
+
+// fakeBlockRepo is an in-memory BlockRepository
+type fakeBlockRepo struct {
+	blocks []*domain.UserBlock
+}
+
+func newFakeBlockRepo() *fakeBlockRepo {
+	return &fakeBlockRepo{}
+}
+
+func (r *fakeBlockRepo) Create(block *domain.UserBlock) error {
+	for _, existing := range r.blocks {
+		if existing.BlockerID == block.BlockerID && existing.BlockedID == block.BlockedID {
+			return nil
+		}
+	}
+	block.ID = uint(len(r.blocks) + 1)
+	block.CreatedAt = time.Now()
+	r.blocks = append(r.blocks, block)
+	return nil
+}
+
+func (r *fakeBlockRepo) Delete(blockerID, blockedID uint) error {
+	for i, block := range r.blocks {
+		if block.BlockerID == blockerID && block.BlockedID == blockedID {
+			r.blocks = append(r.blocks[:i], r.blocks[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *fakeBlockRepo) ExistsBetween(userID, otherUserID uint) (bool, error) {
+	for _, block := range r.blocks {
+		if (block.BlockerID == userID && block.BlockedID == otherUserID) ||
+			(block.BlockerID == otherUserID && block.BlockedID == userID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindBlockedUsers returns users carrying only their ID, most recently
+// blocked first
+func (r *fakeBlockRepo) FindBlockedUsers(blockerID uint) ([]*domain.User, error) {
+	var blocked []*domain.User
+	for i := len(r.blocks) - 1; i >= 0; i-- {
+		if r.blocks[i].BlockerID == blockerID {
+			blocked = append(blocked, &domain.User{ID: r.blocks[i].BlockedID})
+		}
+	}
+	return blocked, nil
+}
+
+// fakeScheduledRepo is an in-memory ScheduledMessageRepository.
+type fakeScheduledRepo struct {
+	messages []*domain.ScheduledMessage
+	lastID   uint
+}
+
+func (r *fakeScheduledRepo) Create(message *domain.ScheduledMessage) error {
+	r.lastID++
+	message.ID = r.lastID
+	message.CreatedAt = time.Now()
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeScheduledRepo) FindByID(id uint) (*domain.ScheduledMessage, error) {
+	for _, m := range r.messages {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("scheduled message not found with id %d", id)
+}
+
+func (r *fakeScheduledRepo) FindPending(roomID, senderID uint) ([]*domain.ScheduledMessage, error) {
+	var pending []*domain.ScheduledMessage
+	for _, m := range r.messages {
+		if m.RoomID == roomID && m.Status == domain.ScheduledMessagePending && (senderID == 0 || m.SenderID == senderID) {
+			pending = append(pending, m)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].SendAt.Before(pending[j].SendAt)
+	})
+	return pending, nil
+}
+
+func (r *fakeScheduledRepo) FindDue(now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	var due []*domain.ScheduledMessage
+	for _, m := range r.messages {
+		if m.Status == domain.ScheduledMessagePending && !m.SendAt.After(now) {
+			due = append(due, m)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].SendAt.Before(due[j].SendAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (r *fakeScheduledRepo) UpdateStatus(id uint, from, to domain.ScheduledMessageStatus) (bool, error) {
+	m, err := r.FindByID(id)
+	if err != nil || m.Status != from {
+		return false, nil
+	}
+	m.Status = to
+	return true, nil
+}
+
+func (r *fakeScheduledRepo) MarkSent(id, messageID uint, sentAt time.Time) error {
+	m, err := r.FindByID(id)
+	if err != nil {
+		return err
+	}
+	m.Status = domain.ScheduledMessageSent
+	m.MessageID = &messageID
+	m.SentAt = &sentAt
+	return nil
+}
+
+func (r *fakeScheduledRepo) MarkFailed(id uint, reason string) error {
+	m, err := r.FindByID(id)
+	if err != nil {
+		return err
+	}
+	m.Status = domain.ScheduledMessageFailed
+	m.Error = reason
+	return nil
+}
+
+func (r *fakeScheduledRepo) DeletePending(id uint) (bool, error) {
+	for i, m := range r.messages {
+		if m.ID == id && m.Status == domain.ScheduledMessagePending {
+			r.messages = append(r.messages[:i], r.messages[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
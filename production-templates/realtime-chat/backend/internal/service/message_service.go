@@ -3,76 +3,175 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"realtime-chat/internal/contentfilter"
 	"realtime-chat/internal/domain"
+	"realtime-chat/internal/ratelimit"
 	"realtime-chat/internal/repository"
+	"realtime-chat/internal/sanitize"
 	"realtime-chat/internal/websocket"
 )
 
 type MessageService interface {
 	Send(roomID, senderID uint, req *domain.SendMessageRequest) (*domain.Message, error)
 	GetByID(messageID, userID uint) (*domain.Message, error)
-	GetRoomMessages(roomID, userID uint, limit, offset int) ([]*domain.Message, error)
+	GetRoomMessages(roomID, userID uint, limit, offset int, beforeID uint) ([]*domain.Message, error)
+	GetRoomMedia(roomID, userID uint, limit int, beforeID uint) ([]*domain.Message, error)
+	GetThread(messageID, userID uint, limit, offset int) ([]*domain.Message, error)
 	Update(messageID, userID uint, req *domain.UpdateMessageRequest) (*domain.Message, error)
 	Delete(messageID, userID uint) error
 
 	// Reactions
 	AddReaction(messageID, userID uint, req *domain.AddReactionRequest) error
 	RemoveReaction(messageID, userID uint, emoji string) error
+	GetReactionSummary(messageID, userID uint) ([]*domain.ReactionSummary, error)
+	GetReactionStats(roomID, userID uint, since, until *time.Time, limit int) (*domain.RoomReactionStats, error)
 
 	// Read receipts
 	MarkAsRead(messageID, userID uint) error
+	MarkAllAsRead(roomID, userID uint) error
+	GetReadBy(messageID, userID uint) ([]*domain.ReadReceipt, error)
+	GetReadState(roomID, userID uint) ([]*domain.ParticipantReadState, error)
 
 	// Typing indicator
 	SendTypingIndicator(roomID, userID uint, isTyping bool) error
+	GetTypingUsers(roomID, userID uint) ([]*domain.TypingUser, error)
+
+	// Mentions
+	GetUnreadMentions(userID uint) ([]*domain.Mention, error)
+
+	// Moderation
+	BulkDelete(roomID, adminID uint, messageIDs []uint) error
+	DeleteAllFromUser(roomID, adminID, targetUserID uint) ([]uint, error)
 }
 
 type messageService struct {
 	messageRepo repository.MessageRepository
 	roomRepo    repository.RoomRepository
 	userRepo    repository.UserRepository
+	blockRepo   repository.BlockRepository
+	sanitizer   *sanitize.Sanitizer
+	filter      contentfilter.ContentFilter // nil when content is not filtered
+	limiter     *ratelimit.Limiter          // nil when sending is not rate limited
 	hub         *websocket.Hub
 }
 
+// RateLimitError rejects a message sent faster than the rate limit allows
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many messages, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
 func NewMessageService(
 	messageRepo repository.MessageRepository,
 	roomRepo repository.RoomRepository,
 	userRepo repository.UserRepository,
+	blockRepo repository.BlockRepository,
+	sanitizer *sanitize.Sanitizer,
+	filter contentfilter.ContentFilter,
+	limiter *ratelimit.Limiter,
 	hub *websocket.Hub,
 ) MessageService {
 	return &messageService{
 		messageRepo: messageRepo,
 		roomRepo:    roomRepo,
 		userRepo:    userRepo,
+		blockRepo:   blockRepo,
+		sanitizer:   sanitizer,
+		filter:      filter,
+		limiter:     limiter,
 		hub:         hub,
 	}
 }
 
 func (s *messageService) Send(roomID, senderID uint, req *domain.SendMessageRequest) (*domain.Message, error) {
-	// Verify sender is participant
-	participant, err := s.roomRepo.FindParticipant(roomID, senderID)
+	// System messages are written by the server, never by clients
+	if req.Type == domain.MessageTypeSystem {
+		return nil, errors.New("system messages cannot be sent by users")
+	}
+	return s.send(roomID, senderID, req, false)
+}
+
+// sendSystem posts a server-generated system message on behalf of senderID.
+// It is not rate limited.
+func (s *messageService) sendSystem(roomID, senderID uint, content string) (*domain.Message, error) {
+	return s.send(roomID, senderID, &domain.SendMessageRequest{Content: content, Type: domain.MessageTypeSystem}, true)
+}
+
+func (s *messageService) send(roomID, senderID uint, req *domain.SendMessageRequest, system bool) (*domain.Message, error) {
+	// Verify sender is participant. Muting a room only silences it for the
+	// muting user, so muted participants can still send; only being muted by
+	// a room admin stops them.
+	sender, err := s.roomRepo.FindParticipant(roomID, senderID)
 	if err != nil {
 		return nil, errors.New("access denied: user is not a participant")
 	}
-
-	// Check if muted
-	if participant.IsMuted {
-		return nil, errors.New("you are muted in this room")
+	if sender.IsSilenced {
+		return nil, errors.New("you have been muted in this room")
 	}
 
 	// Validate message content
 	if req.Content == "" && req.Type == domain.MessageTypeText {
 		return nil, errors.New("message content is required")
 	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	// Nothing can be sent in a direct room once either side blocked the other
+	room, err := s.roomRepo.FindByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.Type == domain.RoomTypeDirect {
+		for _, participant := range room.Participants {
+			if participant.UserID == senderID {
+				continue
+			}
+			if err := checkNotBlocked(s.blockRepo, senderID, participant.UserID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Banned words are masked or refuse the message before it counts
+	// against the rate limit
+	content := req.Content
+	if !system {
+		content, err = s.filterContent(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Each user has their own allowance in every room. System messages are
+	// never limited.
+	if s.limiter != nil && !system {
+		ok, retryAfter, err := s.limiter.Allow(fmt.Sprintf("%d:%d", senderID, roomID))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
 
 	// Create message
 	message := &domain.Message{
 		RoomID:    roomID,
 		SenderID:  senderID,
 		Type:      req.Type,
-		Content:   req.Content,
+		Content:   s.sanitizer.Sanitize(content),
 		ReplyToID: req.ReplyToID,
+		ExpiresAt: req.ExpiresAt,
 	}
 
 	if err := s.messageRepo.Create(message); err != nil {
@@ -85,8 +184,16 @@ func (s *messageService) Send(roomID, senderID uint, req *domain.SendMessageRequ
 		return nil, fmt.Errorf("failed to reload message: %w", err)
 	}
 
+	// Notify mentioned participants, whether or not they muted the room
+	message.Mentions = s.recordMentions(message, content)
+
 	// Broadcast new message event
 	s.broadcastMessageEvent(roomID, senderID, websocket.MessageTypeNewMessage, message)
+	s.notifyUnreadCounts(roomID, senderID)
+
+	if message.ReplyToID != nil {
+		s.notifyThread(message)
+	}
 
 	return message, nil
 }
@@ -98,24 +205,128 @@ func (s *messageService) GetByID(messageID, userID uint) (*domain.Message, error
 	}
 
 	// Verify user has access to this message's room
-	if _, err := s.roomRepo.FindParticipant(message.RoomID, userID); err != nil {
+	participant, err := s.roomRepo.FindParticipant(message.RoomID, userID)
+	if err != nil {
 		return nil, errors.New("access denied: user is not a participant")
 	}
 
+	since, err := s.historyStart(message.RoomID, participant)
+	if err != nil {
+		return nil, err
+	}
+	if message.CreatedAt.Before(since) {
+		return nil, errors.New("access denied: message was sent before the user joined")
+	}
+
+	if !s.isModerator(message.RoomID, userID) {
+		message.HideModeration()
+	}
+
+	if err := s.attachCounts([]*domain.Message{message}, userID); err != nil {
+		return nil, err
+	}
+
 	return message, nil
 }
 
-func (s *messageService) GetRoomMessages(roomID, userID uint, limit, offset int) ([]*domain.Message, error) {
+// GetRoomMessages pages through a room's history. When beforeID is set it
+// takes precedence over offset and returns messages older than that ID.
+func (s *messageService) GetRoomMessages(roomID, userID uint, limit, offset int, beforeID uint) ([]*domain.Message, error) {
 	// Verify user is participant
-	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+	participant, err := s.roomRepo.FindParticipant(roomID, userID)
+	if err != nil {
 		return nil, errors.New("access denied: user is not a participant")
 	}
 
-	messages, err := s.messageRepo.FindByRoomID(roomID, limit, offset)
+	since, err := s.historyStart(roomID, participant)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*domain.Message
+	if beforeID > 0 {
+		messages, err = s.messageRepo.FindByRoomIDBefore(roomID, since, beforeID, limit)
+	} else {
+		messages, err = s.messageRepo.FindByRoomID(roomID, since, limit, offset)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get room messages: %w", err)
 	}
 
+	if !s.isModerator(roomID, userID) {
+		for _, message := range messages {
+			message.HideModeration()
+		}
+	}
+
+	if err := s.attachCounts(messages, userID); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetThread pages through the thread the message belongs to: the message it
+// ultimately replies to and every reply below that, oldest first. The thread
+// starts no earlier than the history the user may read.
+func (s *messageService) GetThread(messageID, userID uint, limit, offset int) ([]*domain.Message, error) {
+	message, err := s.messageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	// Verify user is participant
+	participant, err := s.roomRepo.FindParticipant(message.RoomID, userID)
+	if err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	since, err := s.historyStart(message.RoomID, participant)
+	if err != nil {
+		return nil, err
+	}
+	if message.CreatedAt.Before(since) {
+		return nil, errors.New("access denied: message was sent before the user joined")
+	}
+
+	root := s.threadRoot(message, since)
+	thread, err := s.messageRepo.FindThread(root.ID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread: %w", err)
+	}
+
+	if !s.isModerator(message.RoomID, userID) {
+		for _, m := range thread {
+			m.HideModeration()
+		}
+	}
+
+	if err := s.attachCounts(thread, userID); err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+// GetRoomMedia lists the room's image and file messages, newest first. Pass
+// the ID of the last message of a page as beforeID to load the next one.
+func (s *messageService) GetRoomMedia(roomID, userID uint, limit int, beforeID uint) ([]*domain.Message, error) {
+	// Verify user is participant
+	participant, err := s.roomRepo.FindParticipant(roomID, userID)
+	if err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	since, err := s.historyStart(roomID, participant)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.messageRepo.FindMediaByRoomID(roomID, since, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room media: %w", err)
+	}
+
 	return messages, nil
 }
 
@@ -136,7 +347,11 @@ func (s *messageService) Update(messageID, userID uint, req *domain.UpdateMessag
 	}
 
 	// Update content
-	message.Content = req.Content
+	content, err := s.filterContent(req.Content)
+	if err != nil {
+		return nil, err
+	}
+	message.Content = s.sanitizer.Sanitize(content)
 	message.IsEdited = true
 	now := time.Now()
 	message.EditedAt = &now
@@ -165,23 +380,16 @@ func (s *messageService) Delete(messageID, userID uint) error {
 
 	// Check if user is sender or room admin
 	if message.SenderID != userID {
-		participant, err := s.roomRepo.FindParticipant(message.RoomID, userID)
-		if err != nil {
+		if _, err := s.roomRepo.FindParticipant(message.RoomID, userID); err != nil {
 			return errors.New("access denied")
 		}
 
-		if participant.Role != "admin" {
-			room, err := s.roomRepo.FindByID(message.RoomID)
-			if err != nil {
-				return fmt.Errorf("failed to get room: %w", err)
-			}
-			if room.CreatorID != userID {
-				return errors.New("only sender, admin, or creator can delete message")
-			}
+		if !s.isModerator(message.RoomID, userID) {
+			return errors.New("only sender, admin, or creator can delete message")
 		}
 	}
 
-	if err := s.messageRepo.SoftDelete(messageID); err != nil {
+	if err := s.messageRepo.SoftDelete(messageID, userID, time.Now()); err != nil {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
 
@@ -194,6 +402,47 @@ func (s *messageService) Delete(messageID, userID uint) error {
 	return nil
 }
 
+// BulkDelete soft-deletes several messages of a room at once. Either all of
+// them are deleted or, if any is not in the room, none is.
+func (s *messageService) BulkDelete(roomID, adminID uint, messageIDs []uint) error {
+	if !s.isModerator(roomID, adminID) {
+		return errors.New("only room moderators or higher can moderate messages")
+	}
+
+	if err := s.messageRepo.BulkSoftDelete(roomID, messageIDs, adminID, time.Now()); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	s.broadcastMessageEvent(roomID, adminID, websocket.MessageTypeMessagesBulkDeleted, map[string]interface{}{
+		"message_ids": messageIDs,
+		"room_id":     roomID,
+	})
+
+	return nil
+}
+
+// DeleteAllFromUser soft-deletes every message a user sent in a room and
+// returns the IDs of the deleted messages
+func (s *messageService) DeleteAllFromUser(roomID, adminID, targetUserID uint) ([]uint, error) {
+	if !s.isModerator(roomID, adminID) {
+		return nil, errors.New("only room moderators or higher can moderate messages")
+	}
+
+	messageIDs, err := s.messageRepo.SoftDeleteBySender(roomID, targetUserID, adminID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	if len(messageIDs) > 0 {
+		s.broadcastMessageEvent(roomID, adminID, websocket.MessageTypeMessagesBulkDeleted, map[string]interface{}{
+			"message_ids": messageIDs,
+			"room_id":     roomID,
+		})
+	}
+
+	return messageIDs, nil
+}
+
 func (s *messageService) AddReaction(messageID, userID uint, req *domain.AddReactionRequest) error {
 	message, err := s.messageRepo.FindByID(messageID)
 	if err != nil {
@@ -251,6 +500,72 @@ func (s *messageService) RemoveReaction(messageID, userID uint, emoji string) er
 	return nil
 }
 
+// GetReactionSummary groups the message's reactions by emoji, most used
+// first and otherwise in the order each emoji was first used
+func (s *messageService) GetReactionSummary(messageID, userID uint) ([]*domain.ReactionSummary, error) {
+	// Applies the same access rules as reading the message
+	if _, err := s.GetByID(messageID, userID); err != nil {
+		return nil, err
+	}
+
+	reactions, err := s.messageRepo.GetReactions(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %w", err)
+	}
+
+	summary := make([]*domain.ReactionSummary, 0)
+	byEmoji := make(map[string]*domain.ReactionSummary)
+	for _, reaction := range reactions {
+		group, ok := byEmoji[reaction.Emoji]
+		if !ok {
+			group = &domain.ReactionSummary{Emoji: reaction.Emoji, UserIDs: []uint{}, Users: []*domain.User{}}
+			byEmoji[reaction.Emoji] = group
+			summary = append(summary, group)
+		}
+		group.Count++
+		group.UserIDs = append(group.UserIDs, reaction.UserID)
+		if reaction.User != nil {
+			group.Users = append(group.Users, reaction.User)
+		}
+		if reaction.UserID == userID {
+			group.ReactedByMe = true
+		}
+	}
+
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].Count > summary[j].Count
+	})
+	return summary, nil
+}
+
+// GetReactionStats ranks the emojis used in reactions to the room's messages,
+// optionally limited to reactions added within [since, until)
+func (s *messageService) GetReactionStats(roomID, userID uint, since, until *time.Time, limit int) (*domain.RoomReactionStats, error) {
+	// Verify user is participant
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	if since != nil && until != nil && !since.Before(*until) {
+		return nil, errors.New("since must be before until")
+	}
+
+	stats, err := s.messageRepo.GetReactionStats(roomID, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction stats: %w", err)
+	}
+	if stats == nil {
+		stats = []*domain.ReactionStat{}
+	}
+
+	return &domain.RoomReactionStats{
+		RoomID:    roomID,
+		Since:     since,
+		Until:     until,
+		TopEmojis: stats,
+	}, nil
+}
+
 func (s *messageService) MarkAsRead(messageID, userID uint) error {
 	message, err := s.messageRepo.FindByID(messageID)
 	if err != nil {
@@ -280,6 +595,78 @@ func (s *messageService) MarkAsRead(messageID, userID uint) error {
 	return nil
 }
 
+// MarkAllAsRead marks every unread message in the room as read by the user,
+// so the room's unread count drops to zero
+func (s *messageService) MarkAllAsRead(roomID, userID uint) error {
+	// Verify user is participant
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return errors.New("access denied: user is not a participant")
+	}
+
+	readAt := time.Now()
+	marked, err := s.messageRepo.MarkRoomAsRead(roomID, userID, readAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark room as read: %w", err)
+	}
+
+	s.broadcastMessageEvent(roomID, userID, websocket.MessageTypeRoomRead, map[string]interface{}{
+		"room_id": roomID,
+		"user_id": userID,
+		"read_at": readAt,
+		"marked":  marked,
+	})
+
+	return nil
+}
+
+// GetReadBy lists who has read the message and when, most recent first
+func (s *messageService) GetReadBy(messageID, userID uint) ([]*domain.ReadReceipt, error) {
+	// Applies the same access rules as reading the message
+	if _, err := s.GetByID(messageID, userID); err != nil {
+		return nil, err
+	}
+
+	receipts, err := s.messageRepo.GetReadReceipts(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read receipts: %w", err)
+	}
+	if receipts == nil {
+		receipts = []*domain.ReadReceipt{}
+	}
+	return receipts, nil
+}
+
+// GetReadState reports the last message each current participant of the room
+// has read
+func (s *messageService) GetReadState(roomID, userID uint) ([]*domain.ParticipantReadState, error) {
+	// Verify user is participant
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	participants, err := s.roomRepo.GetParticipants(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get participants: %w", err)
+	}
+	lastRead, err := s.messageRepo.GetLastReadMessageIDs(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*domain.ParticipantReadState, 0, len(participants))
+	for _, participant := range participants {
+		state := &domain.ParticipantReadState{
+			UserID:     participant.UserID,
+			LastReadAt: participant.LastReadAt,
+		}
+		if messageID, ok := lastRead[participant.UserID]; ok {
+			state.LastReadMessageID = &messageID
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
 func (s *messageService) SendTypingIndicator(roomID, userID uint, isTyping bool) error {
 	// Verify user is participant
 	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
@@ -292,22 +679,278 @@ func (s *messageService) SendTypingIndicator(roomID, userID uint, isTyping bool)
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
-	// Broadcast typing indicator
-	indicator := &domain.TypingIndicator{
-		RoomID:    roomID,
-		UserID:    userID,
-		Username:  user.Username,
-		IsTyping:  isTyping,
-		Timestamp: time.Now(),
+	if s.hub == nil {
+		return nil
 	}
 
-	s.broadcastMessageEvent(roomID, userID, websocket.MessageTypeTyping, indicator)
+	// Broadcast typing indicator; the hub emits the stop itself if the
+	// client never sends one
+	indicator := func(typing bool) *websocket.Message {
+		return websocket.NewMessage(websocket.MessageTypeTyping, roomID, userID, &domain.TypingIndicator{
+			RoomID:    roomID,
+			UserID:    userID,
+			Username:  user.Username,
+			IsTyping:  typing,
+			Timestamp: time.Now(),
+		})
+	}
+
+	if isTyping {
+		s.hub.StartTyping(indicator(true), indicator(false))
+	} else {
+		s.hub.StopTyping(indicator(false))
+	}
 
 	return nil
 }
 
+// GetTypingUsers lists who is typing in the room, by username. Indicators
+// expire after the hub's typing timeout, or as soon as the user disconnects.
+func (s *messageService) GetTypingUsers(roomID, userID uint) ([]*domain.TypingUser, error) {
+	// Verify user is participant
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	typing := make([]*domain.TypingUser, 0)
+	if s.hub == nil {
+		return typing, nil
+	}
+
+	for _, typingUserID := range s.hub.GetTypingUsers(roomID) {
+		user, err := s.userRepo.FindByID(typingUserID)
+		if err != nil {
+			continue
+		}
+		typing = append(typing, &domain.TypingUser{UserID: user.ID, Username: user.Username})
+	}
+
+	sort.Slice(typing, func(i, j int) bool {
+		return typing[i].Username < typing[j].Username
+	})
+	return typing, nil
+}
+
+func (s *messageService) GetUnreadMentions(userID uint) ([]*domain.Mention, error) {
+	mentions, err := s.messageRepo.FindUnreadMentions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentions: %w", err)
+	}
+	for _, mention := range mentions {
+		if mention.Message != nil {
+			mention.Message.HideModeration()
+		}
+	}
+	return mentions, nil
+}
+
 // Helper methods
 
+// historyStart returns the oldest point of a room's history the participant
+// may read: when they joined if the room only shows history since joining,
+// otherwise the zero time
+func (s *messageService) historyStart(roomID uint, participant *domain.Participant) (time.Time, error) {
+	room, err := s.roomRepo.FindByID(roomID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.HistoryVisibility == domain.HistoryVisibilitySinceJoin {
+		return participant.JoinedAt, nil
+	}
+	return time.Time{}, nil
+}
+
+// attachCounts fills in the reply count and the reaction counts of each
+// message, as seen by userID
+func (s *messageService) attachCounts(messages []*domain.Message, userID uint) error {
+	ids := make([]uint, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	replies, err := s.messageRepo.CountReplies(ids)
+	if err != nil {
+		return fmt.Errorf("failed to count replies: %w", err)
+	}
+	reactions, err := s.messageRepo.CountReactions(ids, userID)
+	if err != nil {
+		return fmt.Errorf("failed to count reactions: %w", err)
+	}
+	for _, message := range messages {
+		message.ReplyCount = replies[message.ID]
+		message.ReactionCounts = reactions[message.ID]
+		if message.ReactionCounts == nil {
+			message.ReactionCounts = []domain.ReactionCount{}
+		}
+	}
+	return nil
+}
+
+// isModerator reports whether the user may moderate the room's messages,
+// which takes the moderator role or higher
+func (s *messageService) isModerator(roomID, userID uint) bool {
+	_, role, err := participantRole(s.roomRepo, roomID, userID)
+	return err == nil && role.AtLeast(domain.ParticipantRoleModerator)
+}
+
+// mentionPattern matches @username at the start of the content or after a
+// character that cannot be part of a username, so emails are not mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@-])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// parseMentions returns the distinct usernames mentioned in content, in order
+// of first appearance. Trailing punctuation such as "@bob." is not part of the
+// username.
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// filterContent masks banned words in user-written content, or returns a
+// *contentfilter.RejectedError when the filter refuses it
+func (s *messageService) filterContent(content string) (string, error) {
+	if s.filter == nil {
+		return content, nil
+	}
+	return s.filter.Filter(content)
+}
+
+// recordMentions persists a mention for every current participant mentioned
+// in content, other than the sender, and notifies them. Unknown usernames and
+// non-participants are ignored. Failures are logged rather than returned
+// because the message itself has already been sent.
+func (s *messageService) recordMentions(message *domain.Message, content string) []domain.Mention {
+	var mentions []*domain.Mention
+	for _, username := range parseMentions(content) {
+		user, err := s.userRepo.FindByUsername(username)
+		if err != nil || user.ID == message.SenderID {
+			continue
+		}
+		// Mentions reach participants even in rooms they muted
+		if _, err := s.roomRepo.FindParticipant(message.RoomID, user.ID); err != nil {
+			continue
+		}
+		mentions = append(mentions, &domain.Mention{
+			MessageID: message.ID,
+			RoomID:    message.RoomID,
+			UserID:    user.ID,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	if err := s.messageRepo.CreateMentions(mentions); err != nil {
+		log.Printf("Failed to record mentions for message %d: %v", message.ID, err)
+		return nil
+	}
+
+	result := make([]domain.Mention, len(mentions))
+	userIDs := make([]uint, len(mentions))
+	for i, mention := range mentions {
+		result[i] = *mention
+		userIDs[i] = mention.UserID
+	}
+
+	if s.hub != nil {
+		s.hub.SendToUsers(websocket.NewMessage(websocket.MessageTypeUserMentioned, message.RoomID, message.SenderID, message), userIDs)
+	}
+
+	return result
+}
+
+// threadRoot follows the message's replies up to the message that started the
+// thread, stopping at the room's edge and at history sent before since
+func (s *messageService) threadRoot(message *domain.Message, since time.Time) *domain.Message {
+	root := message
+	for root.ReplyToID != nil {
+		parent, err := s.messageRepo.FindByID(*root.ReplyToID)
+		if err != nil || parent.RoomID != message.RoomID || parent.CreatedAt.Before(since) {
+			break
+		}
+		root = parent
+	}
+	return root
+}
+
+// notifyThread sends a THREAD_REPLY event to everyone else who has posted in
+// the reply's thread and has not muted the room
+func (s *messageService) notifyThread(reply *domain.Message) {
+	if s.hub == nil {
+		return
+	}
+
+	root := s.threadRoot(reply, time.Time{})
+	thread, err := s.messageRepo.FindThread(root.ID, 0, 0)
+	if err != nil {
+		log.Printf("Failed to load thread %d for message %d: %v", root.ID, reply.ID, err)
+		return
+	}
+
+	seen := map[uint]bool{reply.SenderID: true}
+	var userIDs []uint
+	for _, m := range thread {
+		if seen[m.SenderID] {
+			continue
+		}
+		seen[m.SenderID] = true
+
+		participant, err := s.roomRepo.FindParticipant(reply.RoomID, m.SenderID)
+		if err != nil || participant.IsMuted {
+			continue
+		}
+		userIDs = append(userIDs, m.SenderID)
+	}
+
+	if len(userIDs) == 0 {
+		return
+	}
+	s.hub.SendToUsers(websocket.NewMessage(websocket.MessageTypeThreadReply, reply.RoomID, reply.SenderID, map[string]interface{}{
+		"root_message_id": root.ID,
+		"message":         reply,
+	}), userIDs)
+}
+
+// notifyUnreadCounts sends every other participant their new unread count for
+// the room. It goes to the users rather than the room, so room lists update
+// for participants connected to other rooms too, and participants with the
+// same count share one message. Participants who muted the room get no update;
+// GetRoomUnreadCounts leaves them out.
+func (s *messageService) notifyUnreadCounts(roomID, senderID uint) {
+	if s.hub == nil {
+		return
+	}
+
+	counts, err := s.roomRepo.GetRoomUnreadCounts(roomID)
+	if err != nil {
+		log.Printf("Failed to count unread messages in room %d: %v", roomID, err)
+		return
+	}
+
+	byCount := make(map[int64][]uint)
+	for userID, count := range counts {
+		if userID != senderID {
+			byCount[count] = append(byCount[count], userID)
+		}
+	}
+
+	for count, userIDs := range byCount {
+		s.hub.SendToUsers(websocket.NewMessage(websocket.MessageTypeUnreadUpdated, roomID, senderID, map[string]interface{}{
+			"room_id":      roomID,
+			"unread_count": count,
+		}), userIDs)
+	}
+}
+
 func (s *messageService) broadcastMessageEvent(roomID, userID uint, eventType websocket.MessageType, data interface{}) {
 	if s.hub != nil {
 		message := websocket.NewMessage(eventType, roomID, userID, data)
@@ -0,0 +1,893 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"realtime-chat/internal/contentfilter"
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/ratelimit"
+	"realtime-chat/internal/sanitize"
+	"realtime-chat/internal/websocket"
+)
+
+func TestMessageService_SendMentions(t *testing.T) {
+	users := testUsers(4) // user1 sends; user2 and user3 are participants; user4 is not
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users[:3] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []uint
+	}{
+		{name: "multiple mentions", content: "@user2 and @user3, see above", want: []uint{2, 3}},
+		{name: "repeated mention", content: "@user2 @user2 ping", want: []uint{2}},
+		{name: "trailing punctuation", content: "thanks @user3.", want: []uint{3}},
+		{name: "non-participant ignored", content: "@user4 is not here", want: nil},
+		{name: "unknown username ignored", content: "hi @nobody", want: nil},
+		{name: "self mention ignored", content: "note to @user1", want: nil},
+		{name: "email is not a mention", content: "mail user2@example.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageRepo := &fakeMessageRepo{}
+			svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+			message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: tt.content, Type: domain.MessageTypeText})
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if len(messageRepo.mentions) != len(tt.want) || len(message.Mentions) != len(tt.want) {
+				t.Fatalf("mentions stored = %d, returned = %d, want %d", len(messageRepo.mentions), len(message.Mentions), len(tt.want))
+			}
+			for i, mention := range messageRepo.mentions {
+				if mention.UserID != tt.want[i] || mention.MessageID != message.ID || mention.RoomID != 1 {
+					t.Errorf("mention %d = %+v, want user %d on message %d", i, mention, tt.want[i], message.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageService_SendMuted(t *testing.T) {
+	users := testUsers(3)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	roomService := NewRoomService(roomRepo, newFakeUserRepo(users...), &fakeMessageRepo{}, newFakeBlockRepo(), nil)
+
+	// user1 and user3 mute the room; user2 does not
+	for _, user := range []*domain.User{users[0], users[2]} {
+		if err := roomService.SetParticipantMute(1, user.ID, true); err != nil {
+			t.Fatalf("SetParticipantMute() error = %v", err)
+		}
+	}
+
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "@user2 @user3 ping", Type: domain.MessageTypeText})
+	if err != nil {
+		t.Fatalf("Send() from a muted participant error = %v", err)
+	}
+
+	// Mentions override mute
+	if len(message.Mentions) != 2 || message.Mentions[0].UserID != users[1].ID || message.Mentions[1].UserID != users[2].ID {
+		t.Errorf("Mentions = %+v, want users %d and %d", message.Mentions, users[1].ID, users[2].ID)
+	}
+
+	if err := roomService.SetParticipantMute(2, users[0].ID, true); err == nil {
+		t.Error("SetParticipantMute() in a room the user is not in should fail")
+	}
+}
+
+func TestMessageService_SendRateLimited(t *testing.T) {
+	users := testUsers(2)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	roomRepo.Create(&domain.Room{Name: "random", Type: domain.RoomTypeGroup})
+	for _, roomID := range []uint{1, 2} {
+		for _, user := range users {
+			roomRepo.AddParticipant(&domain.Participant{RoomID: roomID, UserID: user.ID})
+		}
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), 3, time.Minute)
+	svc := NewMessageService(&fakeMessageRepo{}, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, limiter, nil)
+
+	send := func(roomID, senderID uint, messageType domain.MessageType) error {
+		_, err := svc.Send(roomID, senderID, &domain.SendMessageRequest{Content: "spam", Type: messageType})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := send(1, users[0].ID, domain.MessageTypeText); err != nil {
+			t.Fatalf("Send() #%d error = %v", i+1, err)
+		}
+	}
+
+	err := send(1, users[0].ID, domain.MessageTypeText)
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("Send() #4 within the window error = %v, want a RateLimitError", err)
+	}
+	if rateLimitErr.RetryAfter <= 0 || rateLimitErr.RetryAfter > 20*time.Second {
+		t.Errorf("RetryAfter = %v, want about a third of the window", rateLimitErr.RetryAfter)
+	}
+
+	// The limit is per user and per room, and system messages are exempt
+	if err := send(1, users[1].ID, domain.MessageTypeText); err != nil {
+		t.Errorf("Send() by another user error = %v", err)
+	}
+	if err := send(2, users[0].ID, domain.MessageTypeText); err != nil {
+		t.Errorf("Send() to another room error = %v", err)
+	}
+	if _, err := svc.(*messageService).sendSystem(1, users[0].ID, "user2 joined"); err != nil {
+		t.Errorf("sendSystem() error = %v", err)
+	}
+
+	// Clients cannot claim the system type to get around the limit
+	if err := send(2, users[1].ID, domain.MessageTypeSystem); err == nil {
+		t.Error("Send() of a client system message should fail")
+	}
+}
+
+func TestMessageService_ContentFilter(t *testing.T) {
+	users := testUsers(2)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	words := []string{"darn"}
+
+	t.Run("mask", func(t *testing.T) {
+		messageRepo := &fakeMessageRepo{}
+		filter := contentfilter.NewWordFilter(words, contentfilter.ModeMask)
+		svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), filter, nil, nil)
+
+		message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "darn <it>", Type: domain.MessageTypeText})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if want := "**** &lt;it&gt;"; message.Content != want {
+			t.Errorf("Send() content = %q, want %q", message.Content, want)
+		}
+
+		message, err = svc.Update(message.ID, users[0].ID, &domain.UpdateMessageRequest{Content: "fine, DARN"})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if want := "fine, ****"; message.Content != want {
+			t.Errorf("Update() content = %q, want %q", message.Content, want)
+		}
+
+		// System messages are not written by users and are left alone
+		message, err = svc.(*messageService).sendSystem(1, users[0].ID, "darn")
+		if err != nil {
+			t.Fatalf("sendSystem() error = %v", err)
+		}
+		if message.Content != "darn" {
+			t.Errorf("system message content = %q, want it unfiltered", message.Content)
+		}
+
+		// Clients claiming the system type are refused rather than exempted
+		if _, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "darn", Type: domain.MessageTypeSystem}); err == nil {
+			t.Error("Send() of a client system message should fail")
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		messageRepo := &fakeMessageRepo{}
+		filter := contentfilter.NewWordFilter(words, contentfilter.ModeReject)
+		svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), filter, nil, nil)
+
+		var rejected *contentfilter.RejectedError
+		_, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "oh darn", Type: domain.MessageTypeText})
+		if !errors.As(err, &rejected) || rejected.Word != "darn" {
+			t.Fatalf("Send() error = %v, want the banned word rejected", err)
+		}
+		if len(messageRepo.messages) != 0 {
+			t.Fatalf("%d messages stored, want none", len(messageRepo.messages))
+		}
+
+		message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "all good", Type: domain.MessageTypeText})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		if _, err := svc.Update(message.ID, users[0].ID, &domain.UpdateMessageRequest{Content: "darn"}); !errors.As(err, &rejected) {
+			t.Errorf("Update() error = %v, want the banned word rejected", err)
+		}
+		if message.Content != "all good" {
+			t.Errorf("content after rejected edit = %q, want it unchanged", message.Content)
+		}
+
+		if _, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "oh darn", Type: domain.MessageTypeSystem}); err == nil {
+			t.Error("Send() of a client system message should fail")
+		}
+		if len(messageRepo.messages) != 1 {
+			t.Errorf("%d messages stored, want only the clean one", len(messageRepo.messages))
+		}
+	})
+}
+
+func TestMessageService_DeleteRecordsDeleter(t *testing.T) {
+	users := testUsers(3) // user1 created the room, user2 is an admin, user3 a member
+	creator, admin, member := users[0], users[1], users[2]
+
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, CreatorID: creator.ID})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: creator.ID, Role: "admin"})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: admin.ID, Role: "admin"})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: member.ID, Role: "member"})
+
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	send := func(sender *domain.User) *domain.Message {
+		t.Helper()
+		message, err := svc.Send(1, sender.ID, &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return message
+	}
+
+	tests := []struct {
+		name    string
+		sender  *domain.User
+		deleter *domain.User
+	}{
+		{name: "deleted by sender", sender: member, deleter: member},
+		{name: "deleted by admin", sender: member, deleter: admin},
+		{name: "deleted by creator", sender: admin, deleter: creator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := send(tt.sender)
+
+			if err := svc.Delete(message.ID, tt.deleter.ID); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			deleted, err := svc.GetByID(message.ID, admin.ID)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if deleted.DeletedByID == nil || *deleted.DeletedByID != tt.deleter.ID {
+				t.Errorf("DeletedByID = %v, want %d", deleted.DeletedByID, tt.deleter.ID)
+			}
+			if !deleted.IsDeleted || deleted.DeletedAt == nil || deleted.Content != "[deleted]" {
+				t.Errorf("message = %+v, want a soft-deleted placeholder", deleted)
+			}
+
+			// Members do not see who deleted it
+			seen, err := svc.GetByID(message.ID, member.ID)
+			if err != nil {
+				t.Fatalf("GetByID() error = %v", err)
+			}
+			if seen.DeletedByID != nil {
+				t.Errorf("member sees DeletedByID = %d, want it hidden", *seen.DeletedByID)
+			}
+		})
+	}
+
+	if err := svc.Delete(send(admin).ID, member.ID); err == nil {
+		t.Error("Delete() of someone else's message by a member should fail")
+	}
+}
+
+func TestMessageService_ModerationRequiresAdmin(t *testing.T) {
+	users := testUsers(3) // user1 created the room, user2 is an admin, user3 a member
+	creator, admin, member := users[0], users[1], users[2]
+
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, CreatorID: creator.ID})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: creator.ID, Role: "member"})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: admin.ID, Role: "admin"})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: member.ID, Role: "member"})
+
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	tests := []struct {
+		name    string
+		userID  uint
+		wantErr bool
+	}{
+		{name: "member", userID: member.ID, wantErr: true},
+		{name: "admin", userID: admin.ID, wantErr: false},
+		{name: "creator", userID: creator.ID, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.BulkDelete(1, tt.userID, []uint{1, 2})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BulkDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			_, err = svc.DeleteAllFromUser(1, tt.userID, member.ID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeleteAllFromUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageService_ModeratorRole(t *testing.T) {
+	users := testUsers(3) // user1 created the room, user2 is a moderator, user3 a member
+	creator, moderator, member := users[0], users[1], users[2]
+
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, CreatorID: creator.ID, HistoryVisibility: domain.HistoryVisibilityFull})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: creator.ID, Role: domain.ParticipantRoleOwner})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: moderator.ID, Role: domain.ParticipantRoleModerator})
+	roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: member.ID, Role: domain.ParticipantRoleMember})
+
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	send := func(sender *domain.User) *domain.Message {
+		t.Helper()
+		message, err := svc.Send(1, sender.ID, &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return message
+	}
+
+	// Moderators delete and moderate other participants' messages
+	if err := svc.Delete(send(member).ID, moderator.ID); err != nil {
+		t.Errorf("Delete() by a moderator error = %v", err)
+	}
+	if err := svc.BulkDelete(1, moderator.ID, []uint{send(member).ID}); err != nil {
+		t.Errorf("BulkDelete() by a moderator error = %v", err)
+	}
+	if _, err := svc.DeleteAllFromUser(1, moderator.ID, member.ID); err != nil {
+		t.Errorf("DeleteAllFromUser() by a moderator error = %v", err)
+	}
+
+	// Members do not
+	if err := svc.Delete(send(moderator).ID, member.ID); err == nil {
+		t.Error("Delete() of a moderator's message by a member should fail")
+	}
+	if err := svc.BulkDelete(1, member.ID, []uint{send(moderator).ID}); err == nil {
+		t.Error("BulkDelete() by a member should fail")
+	}
+}
+
+func TestMessageService_HistoryVisibility(t *testing.T) {
+	users := testUsers(2) // user1 was there from the start; user2 joins later
+	joinedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		visibility domain.HistoryVisibility
+		wantSeen   int
+	}{
+		{name: "full history", visibility: domain.HistoryVisibilityFull, wantSeen: 3},
+		{name: "since join", visibility: domain.HistoryVisibilitySinceJoin, wantSeen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomRepo := newFakeRoomRepo()
+			roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: tt.visibility})
+			roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: users[0].ID, JoinedAt: joinedAt.Add(-time.Hour)})
+			roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: users[1].ID, JoinedAt: joinedAt})
+
+			messageRepo := &fakeMessageRepo{}
+			for i, sentAt := range []time.Time{joinedAt.Add(-time.Minute * 30), joinedAt.Add(-time.Minute), joinedAt.Add(time.Minute)} {
+				messageRepo.messages = append(messageRepo.messages, &domain.Message{
+					ID: uint(i + 1), RoomID: 1, SenderID: users[0].ID, Type: domain.MessageTypeText, CreatedAt: sentAt,
+				})
+			}
+			svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+			messages, err := svc.GetRoomMessages(1, users[1].ID, 50, 0, 0)
+			if err != nil {
+				t.Fatalf("GetRoomMessages() error = %v", err)
+			}
+			if len(messages) != tt.wantSeen {
+				t.Errorf("late joiner sees %d messages, want %d", len(messages), tt.wantSeen)
+			}
+			for _, message := range messages {
+				if tt.visibility == domain.HistoryVisibilitySinceJoin && message.CreatedAt.Before(joinedAt) {
+					t.Errorf("late joiner sees message %d sent before joining", message.ID)
+				}
+			}
+
+			_, err = svc.GetByID(1, users[1].ID)
+			if wantDenied := tt.visibility == domain.HistoryVisibilitySinceJoin; (err != nil) != wantDenied {
+				t.Errorf("GetByID() of a pre-join message error = %v, want denied %v", err, wantDenied)
+			}
+
+			// Earlier participants keep the whole backlog either way
+			if messages, err := svc.GetRoomMessages(1, users[0].ID, 50, 0, 0); err != nil || len(messages) != 3 {
+				t.Errorf("original participant sees %d messages (error %v), want 3", len(messages), err)
+			}
+		})
+	}
+
+	roomService := NewRoomService(newFakeRoomRepo(), newFakeUserRepo(users...), &fakeMessageRepo{}, newFakeBlockRepo(), nil)
+	_, err := roomService.Create(users[0].ID, &domain.CreateRoomRequest{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: "never"})
+	if err == nil {
+		t.Error("Create() with an unknown history visibility should fail")
+	}
+	room, err := roomService.Create(users[0].ID, &domain.CreateRoomRequest{Name: "general", Type: domain.RoomTypeGroup})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if room.HistoryVisibility != domain.HistoryVisibilityFull {
+		t.Errorf("default HistoryVisibility = %q, want %q", room.HistoryVisibility, domain.HistoryVisibilityFull)
+	}
+}
+
+func TestMessageService_GetReactionSummary(t *testing.T) {
+	users := testUsers(4) // user4 is not a participant
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: domain.HistoryVisibilityFull})
+	for _, user := range users[:3] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "ship it?", Type: domain.MessageTypeText})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	for _, r := range []struct {
+		userID uint
+		emoji  string
+	}{
+		{userID: 1, emoji: "🎉"},
+		{userID: 2, emoji: "👍"},
+		{userID: 3, emoji: "👍"},
+		{userID: 2, emoji: "🎉"},
+		{userID: 1, emoji: "👀"},
+		{userID: 3, emoji: "👍"}, // repeated, counted once
+	} {
+		if err := svc.AddReaction(message.ID, r.userID, &domain.AddReactionRequest{Emoji: r.emoji}); err != nil {
+			t.Fatalf("AddReaction(%d, %s) error = %v", r.userID, r.emoji, err)
+		}
+	}
+
+	summary, err := svc.GetReactionSummary(message.ID, users[2].ID)
+	if err != nil {
+		t.Fatalf("GetReactionSummary() error = %v", err)
+	}
+
+	// Ties keep the order each emoji was first used in
+	want := []domain.ReactionSummary{
+		{Emoji: "🎉", Count: 2, UserIDs: []uint{1, 2}, ReactedByMe: false},
+		{Emoji: "👍", Count: 2, UserIDs: []uint{2, 3}, ReactedByMe: true},
+		{Emoji: "👀", Count: 1, UserIDs: []uint{1}, ReactedByMe: false},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("GetReactionSummary() returned %d emojis, want %d", len(summary), len(want))
+	}
+	for i, got := range summary {
+		if got.Emoji != want[i].Emoji || got.Count != want[i].Count || got.ReactedByMe != want[i].ReactedByMe ||
+			fmt.Sprint(got.UserIDs) != fmt.Sprint(want[i].UserIDs) {
+			t.Errorf("summary[%d] = %+v, want %+v", i, *got, want[i])
+		}
+	}
+
+	// Each user sees their own reactions flagged
+	summary, err = svc.GetReactionSummary(message.ID, users[0].ID)
+	if err != nil {
+		t.Fatalf("GetReactionSummary() error = %v", err)
+	}
+	for _, got := range summary {
+		if wantMine := got.Emoji != "👍"; got.ReactedByMe != wantMine {
+			t.Errorf("%s ReactedByMe = %v for user1, want %v", got.Emoji, got.ReactedByMe, wantMine)
+		}
+	}
+
+	if _, err := svc.GetReactionSummary(message.ID, users[3].ID); err == nil {
+		t.Error("GetReactionSummary() by a non-participant should fail")
+	}
+
+	// Messages carry the counts without the per-user breakdown
+	fetched, err := svc.GetByID(message.ID, users[2].ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	listed, err := svc.GetRoomMessages(1, users[2].ID, 50, 0, 0)
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("GetRoomMessages() = %d messages, %v", len(listed), err)
+	}
+	for _, got := range [][]domain.ReactionCount{fetched.ReactionCounts, listed[0].ReactionCounts} {
+		if len(got) != len(want) {
+			t.Fatalf("ReactionCounts = %+v, want %d emojis", got, len(want))
+		}
+		for i, count := range got {
+			if count.Emoji != want[i].Emoji || count.Count != int64(want[i].Count) || count.ReactedByMe != want[i].ReactedByMe {
+				t.Errorf("ReactionCounts[%d] = %+v, want %+v", i, count, want[i])
+			}
+		}
+	}
+}
+
+func TestMessageService_ReadReceipts(t *testing.T) {
+	users := testUsers(4) // user4 is not a participant
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: domain.HistoryVisibilityFull})
+	for _, user := range users[:3] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	var messages []*domain.Message
+	for _, content := range []string{"first", "second"} {
+		message, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: content, Type: domain.MessageTypeText})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		messages = append(messages, message)
+	}
+
+	now := time.Now()
+	messageRepo.receipts = []*domain.ReadReceipt{
+		{MessageID: messages[0].ID, UserID: users[2].ID, ReadAt: now.Add(-2 * time.Minute)},
+		{MessageID: messages[0].ID, UserID: users[1].ID, ReadAt: now.Add(-time.Minute)},
+		{MessageID: messages[1].ID, UserID: users[1].ID, ReadAt: now},
+	}
+
+	readBy, err := svc.GetReadBy(messages[0].ID, users[0].ID)
+	if err != nil {
+		t.Fatalf("GetReadBy() error = %v", err)
+	}
+	if len(readBy) != 2 || readBy[0].UserID != users[1].ID || readBy[1].UserID != users[2].ID {
+		t.Errorf("GetReadBy() = %+v, want user2 then user3, most recent first", readBy)
+	}
+
+	states, err := svc.GetReadState(1, users[2].ID)
+	if err != nil {
+		t.Fatalf("GetReadState() error = %v", err)
+	}
+	want := map[uint]*uint{users[0].ID: nil, users[1].ID: &messages[1].ID, users[2].ID: &messages[0].ID}
+	if len(states) != len(want) {
+		t.Fatalf("GetReadState() returned %d participants, want %d", len(states), len(want))
+	}
+	for _, state := range states {
+		wantID, ok := want[state.UserID]
+		if !ok {
+			t.Errorf("GetReadState() includes user %d, who is not a participant", state.UserID)
+			continue
+		}
+		if (wantID == nil) != (state.LastReadMessageID == nil) || (wantID != nil && *wantID != *state.LastReadMessageID) {
+			t.Errorf("user %d LastReadMessageID = %v, want %v", state.UserID, state.LastReadMessageID, wantID)
+		}
+	}
+
+	// Only participants can see who read what
+	if _, err := svc.GetReadBy(messages[0].ID, users[3].ID); err == nil {
+		t.Error("GetReadBy() by a non-participant should fail")
+	}
+	if _, err := svc.GetReadState(1, users[3].ID); err == nil {
+		t.Error("GetReadState() by a non-participant should fail")
+	}
+}
+
+func TestMessageService_MarkAllAsRead(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	users := testUsers(3) // user3 is not a participant
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users[:2] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, hub)
+
+	for _, sender := range []*domain.User{users[0], users[0], users[1]} {
+		if _, err := svc.Send(1, sender.ID, &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	conn := connectTestClient(t, hub, 1, users[0].ID)
+
+	if err := svc.MarkAllAsRead(1, users[1].ID); err != nil {
+		t.Fatalf("MarkAllAsRead() error = %v", err)
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data struct {
+			UserID uint  `json:"user_id"`
+			Marked int64 `json:"marked"`
+		} `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for event.Type != websocket.MessageTypeRoomRead {
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("failed to read ROOM_READ: %v", err)
+		}
+	}
+	if event.Data.UserID != users[1].ID || event.Data.Marked != 2 {
+		t.Errorf("ROOM_READ data = %+v, want user %d with 2 messages marked", event.Data, users[1].ID)
+	}
+	if len(messageRepo.receipts) != 2 {
+		t.Errorf("MarkAllAsRead() created %d receipts, want 2 for user1's messages only", len(messageRepo.receipts))
+	}
+
+	if err := svc.MarkAllAsRead(1, users[2].ID); err == nil {
+		t.Error("MarkAllAsRead() by a non-participant should fail")
+	}
+}
+
+func TestMessageService_GetThread(t *testing.T) {
+	users := testUsers(3) // user3 is not a participant
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: domain.HistoryVisibilityFull})
+	for _, user := range users[:2] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	send := func(content string, replyTo *domain.Message) *domain.Message {
+		t.Helper()
+		req := &domain.SendMessageRequest{Content: content, Type: domain.MessageTypeText}
+		if replyTo != nil {
+			req.ReplyToID = &replyTo.ID
+		}
+		message, err := svc.Send(1, users[0].ID, req)
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		return message
+	}
+
+	root := send("root", nil)
+	a := send("a", root)
+	send("unrelated", nil)
+	send("b", root)
+	a1 := send("a1", a)
+
+	// Asking for any message of the thread returns all of it
+	for _, start := range []*domain.Message{root, a1} {
+		thread, err := svc.GetThread(start.ID, users[1].ID, 50, 0)
+		if err != nil {
+			t.Fatalf("GetThread(%q) error = %v", start.Content, err)
+		}
+
+		got := make([]string, len(thread))
+		replies := make(map[string]int64)
+		for i, message := range thread {
+			got[i] = message.Content
+			replies[message.Content] = message.ReplyCount
+		}
+		if fmt.Sprint(got) != "[root a b a1]" {
+			t.Errorf("GetThread(%q) = %v, want [root a b a1]", start.Content, got)
+		}
+		if replies["root"] != 2 || replies["a"] != 1 || replies["b"] != 0 || replies["a1"] != 0 {
+			t.Errorf("reply counts = %v, want root:2 a:1 b:0 a1:0", replies)
+		}
+	}
+
+	page, err := svc.GetThread(a1.ID, users[1].ID, 2, 1)
+	if err != nil {
+		t.Fatalf("GetThread() page error = %v", err)
+	}
+	if len(page) != 2 || page[0].Content != "a" || page[1].Content != "b" {
+		t.Errorf("GetThread(limit 2, offset 1) returned %d messages, want a and b", len(page))
+	}
+
+	message, err := svc.GetByID(root.ID, users[1].ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if message.ReplyCount != 2 {
+		t.Errorf("GetByID() ReplyCount = %d, want 2", message.ReplyCount)
+	}
+
+	if _, err := svc.GetThread(root.ID, users[2].ID, 50, 0); err == nil {
+		t.Error("GetThread() by a non-participant should fail")
+	}
+}
+
+func TestMessageService_ThreadReplyEvent(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	users := testUsers(3)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, HistoryVisibility: domain.HistoryVisibilityFull})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	messageRepo := &fakeMessageRepo{}
+	svc := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, hub)
+
+	// user2 started the thread and is connected to another room, so only
+	// targeted events reach them
+	root, err := svc.Send(1, users[1].ID, &domain.SendMessageRequest{Content: "root", Type: domain.MessageTypeText})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	first, err := svc.Send(1, users[2].ID, &domain.SendMessageRequest{Content: "first", Type: domain.MessageTypeText, ReplyToID: &root.ID})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	conn := connectTestClient(t, hub, 2, users[1].ID)
+
+	reply, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "nested", Type: domain.MessageTypeText, ReplyToID: &first.ID})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data struct {
+			RootMessageID uint            `json:"root_message_id"`
+			Message       *domain.Message `json:"message"`
+		} `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for event.Type != websocket.MessageTypeThreadReply {
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("failed to read THREAD_REPLY: %v", err)
+		}
+	}
+	if event.Data.RootMessageID != root.ID {
+		t.Errorf("root_message_id = %d, want %d", event.Data.RootMessageID, root.ID)
+	}
+	if event.Data.Message == nil || event.Data.Message.ID != reply.ID {
+		t.Errorf("message = %+v, want the reply %d", event.Data.Message, reply.ID)
+	}
+}
+
+func TestMessageService_UnreadUpdatedEvent(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	users := testUsers(3) // user3 muted the room
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID, IsMuted: user == users[2]})
+	}
+	roomRepo.unread[1] = 3
+	svc := NewMessageService(&fakeMessageRepo{}, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, hub)
+
+	// Both users are connected to another room, so only targeted events
+	// reach them
+	recipient := connectTestClient(t, hub, 2, users[1].ID)
+	sender := connectTestClient(t, hub, 3, users[0].ID)
+	muted := connectTestClient(t, hub, 4, users[2].ID)
+	deadline := time.Now().Add(time.Second)
+	for hub.GetClientCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the clients to register")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data struct {
+			RoomID      uint  `json:"room_id"`
+			UnreadCount int64 `json:"unread_count"`
+		} `json:"data"`
+	}
+	recipient.SetReadDeadline(time.Now().Add(time.Second))
+	if err := recipient.ReadJSON(&event); err != nil {
+		t.Fatalf("failed to read UNREAD_UPDATED: %v", err)
+	}
+	if event.Type != websocket.MessageTypeUnreadUpdated || event.Data.RoomID != 1 || event.Data.UnreadCount != 3 {
+		t.Errorf("event = %+v, want UNREAD_UPDATED for room 1 with 3 unread", event)
+	}
+
+	// The sender's own count did not change, and muted rooms show no badge
+	sender.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := sender.ReadJSON(&event); err == nil {
+		t.Errorf("sender received %s, want nothing", event.Type)
+	}
+	muted.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := muted.ReadJSON(&event); err == nil {
+		t.Errorf("muted participant received %s, want nothing", event.Type)
+	}
+}
+
+func TestMessageService_UnreadUpdatedBatched(t *testing.T) {
+	var mu sync.Mutex
+	var unread []*websocket.Envelope
+	backend := websocket.NewMemoryBackend()
+	backend.Subscribe(func(envelope *websocket.Envelope) {
+		if envelope.Message.Type == websocket.MessageTypeUnreadUpdated {
+			mu.Lock()
+			unread = append(unread, envelope)
+			mu.Unlock()
+		}
+	})
+	hub := websocket.NewHub(websocket.HubConfig{Backend: backend})
+	go hub.Run()
+
+	users := testUsers(4)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	roomRepo.unread[1] = 3
+	svc := NewMessageService(&fakeMessageRepo{}, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, hub)
+
+	if _, err := svc.Send(1, users[0].ID, &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// Everyone else has the same count, so one message reaches them all
+	mu.Lock()
+	defer mu.Unlock()
+	if len(unread) != 1 || len(unread[0].Recipients) != 3 {
+		t.Fatalf("published %d UNREAD_UPDATED messages, want 1 for the 3 other participants", len(unread))
+	}
+	for _, userID := range unread[0].Recipients {
+		if userID == users[0].ID {
+			t.Error("UNREAD_UPDATED was sent to the sender")
+		}
+	}
+}
+
+func TestMessageService_GetTypingUsers(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{TypingTimeout: 50 * time.Millisecond})
+	go hub.Run()
+
+	users := testUsers(4) // user4 is not a participant
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users[:3] {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	svc := NewMessageService(&fakeMessageRepo{}, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, hub)
+
+	for _, user := range []*domain.User{users[2], users[1]} {
+		if err := svc.SendTypingIndicator(1, user.ID, true); err != nil {
+			t.Fatalf("SendTypingIndicator() error = %v", err)
+		}
+	}
+
+	typing, err := svc.GetTypingUsers(1, users[0].ID)
+	if err != nil {
+		t.Fatalf("GetTypingUsers() error = %v", err)
+	}
+	if len(typing) != 2 || typing[0].Username != users[1].Username || typing[1].Username != users[2].Username {
+		t.Errorf("GetTypingUsers() = %+v, want %s and %s", typing, users[1].Username, users[2].Username)
+	}
+
+	if _, err := svc.GetTypingUsers(1, users[3].ID); err == nil {
+		t.Error("GetTypingUsers() by a non-participant should fail")
+	}
+
+	// Indicators nobody stops expire
+	if err := svc.SendTypingIndicator(1, users[1].ID, false); err != nil {
+		t.Fatalf("SendTypingIndicator() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if typing, _ := svc.GetTypingUsers(1, users[0].ID); len(typing) != 0 {
+		t.Errorf("GetTypingUsers() after the timeout = %+v, want none", typing)
+	}
+}
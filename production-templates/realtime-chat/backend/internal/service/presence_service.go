@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+	"realtime-chat/internal/websocket"
+)
+
+type PresenceService interface {
+	// SetConnectionStatus is installed as the hub's StatusUpdater. It persists
+	// the user's online/offline status and notifies the rooms they belong to.
+	SetConnectionStatus(userID uint, online bool)
+	// SetStatus sets the user's status by hand, e.g. to away or busy.
+	// Offline is only set once all of the user's connections drop.
+	SetStatus(userID uint, status domain.UserStatus) (*domain.Presence, error)
+	GetPresence(userID uint) (*domain.Presence, error)
+}
+
+type presenceService struct {
+	userRepo repository.UserRepository
+	roomRepo repository.RoomRepository
+	hub      *websocket.Hub
+}
+
+func NewPresenceService(
+	userRepo repository.UserRepository,
+	roomRepo repository.RoomRepository,
+	hub *websocket.Hub,
+) PresenceService {
+	return &presenceService{
+		userRepo: userRepo,
+		roomRepo: roomRepo,
+		hub:      hub,
+	}
+}
+
+func (s *presenceService) SetConnectionStatus(userID uint, online bool) {
+	status := domain.StatusOnline
+	if !online {
+		status = domain.StatusOffline
+	}
+
+	if err := s.userRepo.UpdateStatus(userID, status); err != nil {
+		log.Printf("Failed to update status for user %d: %v", userID, err)
+	}
+
+	if !online {
+		if err := s.userRepo.UpdateLastSeen(userID); err != nil {
+			log.Printf("Failed to update last seen for user %d: %v", userID, err)
+		}
+	}
+
+	// The hub also reports users offline while shutting down, when its
+	// shards no longer drain their broadcast channels, so hand the fan-out to
+	// another goroutine rather than feeding those channels from here.
+	go s.broadcastPresenceChanged(userID)
+}
+
+func (s *presenceService) SetStatus(userID uint, status domain.UserStatus) (*domain.Presence, error) {
+	switch status {
+	case domain.StatusOnline, domain.StatusAway, domain.StatusBusy:
+	default:
+		return nil, fmt.Errorf("invalid status %q: must be online, away or busy", status)
+	}
+
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := s.userRepo.UpdateStatus(userID, status); err != nil {
+		return nil, err
+	}
+
+	return s.broadcastPresenceChanged(userID), nil
+}
+
+func (s *presenceService) GetPresence(userID uint) (*domain.Presence, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return presenceOf(user), nil
+}
+
+// Helper methods
+
+// broadcastPresenceChanged tells the rooms the user belongs to about their
+// current presence and returns it. USER_STATUS_CHANGED carries just the
+// status, PRESENCE_CHANGED the full presence.
+func (s *presenceService) broadcastPresenceChanged(userID uint) *domain.Presence {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", userID, err)
+		return nil
+	}
+	presence := presenceOf(user)
+
+	if s.hub == nil {
+		return presence
+	}
+
+	rooms, err := s.roomRepo.FindByUserID(userID)
+	if err != nil {
+		log.Printf("Failed to load rooms for user %d: %v", userID, err)
+		return presence
+	}
+
+	for _, room := range rooms {
+		s.hub.Broadcast(websocket.NewMessage(websocket.MessageTypeUserStatusChanged, room.ID, userID, map[string]interface{}{
+			"user_id": userID,
+			"status":  presence.Status,
+		}))
+		s.hub.Broadcast(websocket.NewMessage(websocket.MessageTypePresenceChanged, room.ID, userID, presence))
+	}
+	return presence
+}
+
+func presenceOf(user *domain.User) *domain.Presence {
+	return &domain.Presence{
+		UserID:     user.ID,
+		Status:     user.Status,
+		LastSeenAt: user.LastSeenAt,
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/websocket"
+)
+
+func TestPresenceService_ConnectionStatus(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	users := testUsers(2)
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID})
+	}
+	svc := NewPresenceService(newFakeUserRepo(users...), roomRepo, hub)
+	conn := connectTestClient(t, hub, 1, users[1].ID)
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data domain.Presence       `json:"data"`
+	}
+	// The write pump batches queued messages into one frame, one per line
+	var frame *json.Decoder
+	readEvent := func() {
+		t.Helper()
+		if frame == nil || !frame.More() {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("failed to read broadcast: %v", err)
+			}
+			frame = json.NewDecoder(bytes.NewReader(data))
+		}
+		event.Data = domain.Presence{}
+		if err := frame.Decode(&event); err != nil {
+			t.Fatalf("failed to decode broadcast: %v", err)
+		}
+	}
+
+	// USER_STATUS_CHANGED comes first for older clients, then the full
+	// PRESENCE_CHANGED
+	expectPresence := func(want domain.UserStatus) {
+		t.Helper()
+		for _, wantType := range []websocket.MessageType{websocket.MessageTypeUserStatusChanged, websocket.MessageTypePresenceChanged} {
+			readEvent()
+			if event.Type != wantType || event.Data.UserID != users[0].ID || event.Data.Status != want {
+				t.Errorf("broadcast = %s %+v, want %s for user %d as %s", event.Type, event.Data, wantType, users[0].ID, want)
+			}
+		}
+	}
+
+	svc.SetConnectionStatus(users[0].ID, true)
+	expectPresence(domain.StatusOnline)
+
+	// Dropping the last connection takes the user offline and records when
+	svc.SetConnectionStatus(users[0].ID, false)
+	expectPresence(domain.StatusOffline)
+	if event.Data.LastSeenAt == nil {
+		t.Error("LastSeenAt should be set once the user goes offline")
+	}
+
+	presence, err := svc.GetPresence(users[0].ID)
+	if err != nil {
+		t.Fatalf("GetPresence() error = %v", err)
+	}
+	if presence.Status != domain.StatusOffline || presence.LastSeenAt == nil {
+		t.Errorf("GetPresence() = %+v, want offline with a last seen time", presence)
+	}
+}
+
+func TestPresenceService_SetStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  domain.UserStatus
+		wantErr bool
+	}{
+		{name: "away", status: domain.StatusAway},
+		{name: "busy", status: domain.StatusBusy},
+		{name: "back online", status: domain.StatusOnline},
+		{name: "offline is set by the hub only", status: domain.StatusOffline, wantErr: true},
+		{name: "unknown", status: "sleeping", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUsers(1)[0]
+			svc := NewPresenceService(newFakeUserRepo(user), newFakeRoomRepo(), nil)
+
+			presence, err := svc.SetStatus(user.ID, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if user.Status != domain.StatusOffline {
+					t.Errorf("Status = %s, want it unchanged", user.Status)
+				}
+				return
+			}
+			if presence.Status != tt.status || user.Status != tt.status {
+				t.Errorf("SetStatus() = %+v, stored %s, want %s", presence, user.Status, tt.status)
+			}
+		})
+	}
+
+	svc := NewPresenceService(newFakeUserRepo(), newFakeRoomRepo(), nil)
+	if _, err := svc.GetPresence(99); err == nil {
+		t.Error("GetPresence() of an unknown user should fail")
+	}
+}
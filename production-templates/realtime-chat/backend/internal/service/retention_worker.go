@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"realtime-chat/internal/repository"
+	"realtime-chat/internal/websocket"
+)
+
+type RetentionWorker interface {
+	// PurgeDue deletes the messages of every room with a retention
+	// window that are older than it, and returns how many it deleted per
+	// room. Rooms without retention are skipped.
+	PurgeDue(now time.Time) (map[uint]int, error)
+	// Run calls PurgeDue every interval. It never returns.
+	Run(interval time.Duration)
+}
+
+type retentionWorker struct {
+	roomRepo    repository.RoomRepository
+	messageRepo repository.MessageRepository
+	hub         *websocket.Hub
+}
+
+func NewRetentionWorker(
+	roomRepo repository.RoomRepository,
+	messageRepo repository.MessageRepository,
+	hub *websocket.Hub,
+) RetentionWorker {
+	return &retentionWorker{
+		roomRepo:    roomRepo,
+		messageRepo: messageRepo,
+		hub:         hub,
+	}
+}
+
+func (w *retentionWorker) PurgeDue(now time.Time) (map[uint]int, error) {
+	rooms, err := w.roomRepo.FindRoomsWithRetention()
+	if err != nil {
+		return nil, err
+	}
+
+	purged := make(map[uint]int)
+	for _, room := range rooms {
+		if room.RetentionDays == nil || *room.RetentionDays <= 0 {
+			continue
+		}
+
+		cutoff := now.AddDate(0, 0, -*room.RetentionDays)
+		messageIDs, err := w.messageRepo.DeleteOlderThan(room.ID, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge room %d: %w", room.ID, err)
+		}
+		if len(messageIDs) == 0 {
+			continue
+		}
+		purged[room.ID] = len(messageIDs)
+
+		// Same event as a moderator's bulk delete
+		if w.hub != nil {
+			w.hub.Broadcast(websocket.NewMessage(websocket.MessageTypeMessagesBulkDeleted, room.ID, 0, map[string]interface{}{
+				"message_ids": messageIDs,
+				"room_id":     room.ID,
+			}))
+		}
+	}
+
+	return purged, nil
+}
+
+func (w *retentionWorker) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		purged, err := w.PurgeDue(now)
+		if err != nil {
+			log.Printf("Failed to purge messages past retention: %v", err)
+		}
+		if len(purged) > 0 {
+			log.Printf("Purged messages past retention: %v", purged)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+)
+
+func TestRetentionWorker_PurgeDue(t *testing.T) {
+	now := time.Now()
+	week := 7
+
+	roomRepo := newFakeRoomRepo()
+	purged := &domain.Room{Name: "purged", RetentionDays: &week}
+	forever := &domain.Room{Name: "forever"}
+	roomRepo.Create(purged)
+	roomRepo.Create(forever)
+
+	messageRepo := &fakeMessageRepo{}
+	seed := func(room *domain.Room, age time.Duration) *domain.Message {
+		message := &domain.Message{RoomID: room.ID, SenderID: 1, Content: "hello"}
+		messageRepo.Create(message)
+		message.CreatedAt = now.Add(-age)
+		return message
+	}
+	old := seed(purged, 8*24*time.Hour)
+	recent := seed(purged, 6*24*time.Hour)
+	ancient := seed(forever, 365*24*time.Hour)
+
+	worker := NewRetentionWorker(roomRepo, messageRepo, nil)
+
+	counts, err := worker.PurgeDue(now)
+	if err != nil {
+		t.Fatalf("PurgeDue() error = %v", err)
+	}
+	if len(counts) != 1 || counts[purged.ID] != 1 {
+		t.Errorf("PurgeDue() = %v, want one message purged from room %d", counts, purged.ID)
+	}
+	if !old.IsDeleted || old.Content != "[deleted]" {
+		t.Error("message past the retention window should be soft-deleted")
+	}
+	if recent.IsDeleted {
+		t.Error("message within the retention window should be kept")
+	}
+	if ancient.IsDeleted {
+		t.Error("messages in rooms without retention should be kept")
+	}
+
+	// Nothing is left to purge on the next run
+	if again, err := worker.PurgeDue(now); err != nil || len(again) != 0 {
+		t.Errorf("second PurgeDue() = %v, %v, want nothing purged", again, err)
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"realtime-chat/internal/domain"
@@ -13,16 +15,21 @@ import (
 type RoomService interface {
 	Create(creatorID uint, req *domain.CreateRoomRequest) (*domain.Room, error)
 	GetByID(roomID, userID uint) (*domain.Room, error)
-	GetUserRooms(userID uint) ([]*domain.Room, error)
+	GetUserRooms(userID uint, unreadOnly bool) ([]*domain.Room, error)
+	SearchUserRooms(userID uint, query string) ([]*domain.Room, error)
 	Update(roomID, userID uint, req *domain.UpdateRoomRequest) (*domain.Room, error)
 	Delete(roomID, userID uint) error
 	Archive(roomID, userID uint) error
+	ConvertToGroup(roomID, userID uint) (*domain.Room, error)
 
 	// Participant management
 	AddParticipant(roomID, requestUserID uint, req *domain.AddParticipantRequest) error
 	RemoveParticipant(roomID, participantUserID, requestUserID uint) error
 	LeaveRoom(roomID, userID uint) error
 	GetParticipants(roomID, userID uint) ([]*domain.Participant, error)
+	SetParticipantMute(roomID, userID uint, muted bool) error
+	UpdateParticipantRole(roomID, targetUserID, requesterID uint, role domain.ParticipantRole) (*domain.Participant, error)
+	MuteParticipant(roomID, targetUserID, requesterID uint, mute bool) error
 
 	// Direct message
 	GetOrCreateDirectRoom(user1ID, user2ID uint) (*domain.Room, error)
@@ -36,6 +43,7 @@ type roomService struct {
 	roomRepo    repository.RoomRepository
 	userRepo    repository.UserRepository
 	messageRepo repository.MessageRepository
+	blockRepo   repository.BlockRepository
 	hub         *websocket.Hub
 }
 
@@ -43,12 +51,14 @@ func NewRoomService(
 	roomRepo repository.RoomRepository,
 	userRepo repository.UserRepository,
 	messageRepo repository.MessageRepository,
+	blockRepo repository.BlockRepository,
 	hub *websocket.Hub,
 ) RoomService {
 	return &roomService{
 		roomRepo:    roomRepo,
 		userRepo:    userRepo,
 		messageRepo: messageRepo,
+		blockRepo:   blockRepo,
 		hub:         hub,
 	}
 }
@@ -69,6 +79,9 @@ func (s *roomService) Create(creatorID uint, req *domain.CreateRoomRequest) (*do
 		if len(req.UserIDs) != 1 {
 			return nil, errors.New("direct room must have exactly one other participant")
 		}
+		if err := checkNotBlocked(s.blockRepo, creatorID, req.UserIDs[0]); err != nil {
+			return nil, err
+		}
 
 		// Check if direct room already exists
 		existingRoom, err := s.roomRepo.FindDirectRoom(creatorID, req.UserIDs[0])
@@ -80,23 +93,32 @@ func (s *roomService) Create(creatorID uint, req *domain.CreateRoomRequest) (*do
 		}
 	}
 
+	if !req.HistoryVisibility.Valid() {
+		return nil, fmt.Errorf("invalid history visibility %q", req.HistoryVisibility)
+	}
+	visibility := req.HistoryVisibility
+	if visibility == "" {
+		visibility = domain.HistoryVisibilityFull
+	}
+
 	// Create room
 	room := &domain.Room{
-		Name:        req.Name,
-		Description: req.Description,
-		Type:        req.Type,
-		CreatorID:   creatorID,
+		Name:              req.Name,
+		Description:       req.Description,
+		Type:              req.Type,
+		CreatorID:         creatorID,
+		HistoryVisibility: visibility,
 	}
 
 	if err := s.roomRepo.Create(room); err != nil {
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
 
-	// Add creator as admin participant
+	// Add creator as the owner
 	creatorParticipant := &domain.Participant{
 		RoomID:   room.ID,
 		UserID:   creatorID,
-		Role:     "admin",
+		Role:     domain.ParticipantRoleOwner,
 		JoinedAt: time.Now(),
 	}
 	if err := s.roomRepo.AddParticipant(creatorParticipant); err != nil {
@@ -108,7 +130,7 @@ func (s *roomService) Create(creatorID uint, req *domain.CreateRoomRequest) (*do
 		participant := &domain.Participant{
 			RoomID:   room.ID,
 			UserID:   userID,
-			Role:     "member",
+			Role:     domain.ParticipantRoleMember,
 			JoinedAt: time.Now(),
 		}
 		if err := s.roomRepo.AddParticipant(participant); err != nil {
@@ -150,43 +172,87 @@ func (s *roomService) GetByID(roomID, userID uint) (*domain.Room, error) {
 	return room, nil
 }
 
-func (s *roomService) GetUserRooms(userID uint) ([]*domain.Room, error) {
+// GetUserRooms lists the user's rooms with their last message and the user's
+// unread and mention counts. With unreadOnly set, only rooms with unread messages are
+// returned, most recent activity first.
+func (s *roomService) GetUserRooms(userID uint, unreadOnly bool) ([]*domain.Room, error) {
 	rooms, err := s.roomRepo.FindByUserID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user rooms: %w", err)
 	}
 
-	// Load last message and unread count for each room
-	for i := range rooms {
-		lastMessage, _ := s.messageRepo.GetLastMessage(rooms[i].ID)
-		rooms[i].LastMessage = lastMessage
+	return s.withUserState(rooms, userID, unreadOnly)
+}
+
+// SearchUserRooms lists the user's rooms whose name, or for direct rooms the
+// other participant's name, matches query, enriched like GetUserRooms.
+func (s *roomService) SearchUserRooms(userID uint, query string) ([]*domain.Room, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("search query is required")
+	}
 
-		unreadCount, _ := s.roomRepo.GetUnreadCount(rooms[i].ID, userID)
-		for j := range rooms[i].Participants {
-			if rooms[i].Participants[j].UserID == userID {
-				rooms[i].Participants[j].UnreadCount = int(unreadCount)
-			}
-		}
+	rooms, err := s.roomRepo.SearchUserRooms(userID, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search user rooms: %w", err)
 	}
 
-	return rooms, nil
+	return s.withUserState(rooms, userID, false)
 }
 
-func (s *roomService) Update(roomID, userID uint, req *domain.UpdateRoomRequest) (*domain.Room, error) {
-	// Check if user is admin or creator
-	participant, err := s.roomRepo.FindParticipant(roomID, userID)
+// withUserState sets each room's last message and the user's unread and
+// mention counts, dropping rooms without unread messages if unreadOnly is set.
+func (s *roomService) withUserState(rooms []*domain.Room, userID uint, unreadOnly bool) ([]*domain.Room, error) {
+	unreadCounts, err := s.roomRepo.GetUnreadCounts(userID)
 	if err != nil {
-		return nil, errors.New("access denied: user is not a participant")
+		return nil, fmt.Errorf("failed to get unread counts: %w", err)
 	}
 
-	if participant.Role != "admin" {
-		room, err := s.roomRepo.FindByID(roomID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get room: %w", err)
+	mentionCounts, err := s.roomRepo.GetUnreadMentionCounts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mention counts: %w", err)
+	}
+
+	result := make([]*domain.Room, 0, len(rooms))
+	for _, room := range rooms {
+		unreadCount := unreadCounts[room.ID]
+		if unreadOnly && unreadCount == 0 {
+			continue
 		}
-		if room.CreatorID != userID {
-			return nil, errors.New("only admin or creator can update room")
+
+		lastMessage, _ := s.messageRepo.GetLastMessage(room.ID)
+		room.LastMessage = lastMessage
+
+		for j := range room.Participants {
+			if room.Participants[j].UserID == userID {
+				room.Participants[j].UnreadCount = int(unreadCount)
+				room.Participants[j].MentionCount = int(mentionCounts[room.ID])
+			}
 		}
+		result = append(result, room)
+	}
+
+	if unreadOnly {
+		sort.SliceStable(result, func(i, j int) bool {
+			return lastActivity(result[i]).After(lastActivity(result[j]))
+		})
+	}
+
+	return result, nil
+}
+
+// lastActivity is the time of the room's last message, or its last update if
+// it has no messages.
+func lastActivity(room *domain.Room) time.Time {
+	if room.LastMessage != nil {
+		return room.LastMessage.CreatedAt
+	}
+	return room.UpdatedAt
+}
+
+func (s *roomService) Update(roomID, userID uint, req *domain.UpdateRoomRequest) (*domain.Room, error) {
+	if _, err := s.requireRole(roomID, userID, domain.ParticipantRoleAdmin, "update room"); err != nil {
+		return nil, err
 	}
 
 	room, err := s.roomRepo.FindByID(roomID)
@@ -204,6 +270,25 @@ func (s *roomService) Update(roomID, userID uint, req *domain.UpdateRoomRequest)
 	if req.AvatarURL != "" {
 		room.AvatarURL = req.AvatarURL
 	}
+	if req.NeverAutoArchive != nil {
+		room.NeverAutoArchive = *req.NeverAutoArchive
+	}
+	if req.HistoryVisibility != "" {
+		if !req.HistoryVisibility.Valid() {
+			return nil, fmt.Errorf("invalid history visibility %q", req.HistoryVisibility)
+		}
+		room.HistoryVisibility = req.HistoryVisibility
+	}
+	if req.RetentionDays != nil {
+		switch days := *req.RetentionDays; {
+		case days < 0:
+			return nil, errors.New("retention_days cannot be negative")
+		case days == 0:
+			room.RetentionDays = nil
+		default:
+			room.RetentionDays = &days
+		}
+	}
 
 	if err := s.roomRepo.Update(room); err != nil {
 		return nil, fmt.Errorf("failed to update room: %w", err)
@@ -234,44 +319,72 @@ func (s *roomService) Delete(roomID, userID uint) error {
 }
 
 func (s *roomService) Archive(roomID, userID uint) error {
+	if _, err := s.requireRole(roomID, userID, domain.ParticipantRoleAdmin, "archive room"); err != nil {
+		return err
+	}
+
 	room, err := s.roomRepo.FindByID(roomID)
 	if err != nil {
 		return fmt.Errorf("failed to get room: %w", err)
 	}
 
-	// Only creator or admin can archive room
-	participant, err := s.roomRepo.FindParticipant(roomID, userID)
+	room.IsArchived = true
+	if err := s.roomRepo.Update(room); err != nil {
+		return fmt.Errorf("failed to archive room: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertToGroup turns a direct room into a group room so more participants
+// can be added. Existing participants and message history are kept as-is.
+func (s *roomService) ConvertToGroup(roomID, userID uint) (*domain.Room, error) {
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	room, err := s.roomRepo.FindByID(roomID)
 	if err != nil {
-		return errors.New("access denied: user is not a participant")
+		return nil, fmt.Errorf("failed to get room: %w", err)
 	}
 
-	if room.CreatorID != userID && participant.Role != "admin" {
-		return errors.New("only creator or admin can archive room")
+	if room.Type != domain.RoomTypeDirect {
+		return nil, errors.New("only direct rooms can be converted to group")
 	}
 
-	room.IsArchived = true
+	room.Type = domain.RoomTypeGroup
 	if err := s.roomRepo.Update(room); err != nil {
-		return fmt.Errorf("failed to archive room: %w", err)
+		return nil, fmt.Errorf("failed to convert room: %w", err)
 	}
 
-	return nil
+	// Broadcast room converted event
+	s.broadcastRoomEvent(roomID, userID, websocket.MessageTypeRoomConverted, room)
+
+	return room, nil
 }
 
 func (s *roomService) AddParticipant(roomID, requestUserID uint, req *domain.AddParticipantRequest) error {
-	// Check if requester is admin or creator
-	participant, err := s.roomRepo.FindParticipant(roomID, requestUserID)
+	requesterRole, err := s.requireRole(roomID, requestUserID, domain.ParticipantRoleAdmin, "add participants")
 	if err != nil {
-		return errors.New("access denied: user is not a participant")
+		return err
 	}
 
-	if participant.Role != "admin" {
-		room, err := s.roomRepo.FindByID(roomID)
-		if err != nil {
-			return fmt.Errorf("failed to get room: %w", err)
-		}
-		if room.CreatorID != requestUserID {
-			return errors.New("only admin or creator can add participants")
-		}
+	// Participants can only be given a role below the requester's own
+	role := req.Role
+	if role == "" {
+		role = domain.ParticipantRoleMember
+	}
+	if err := checkAssignableRole(requesterRole, role); err != nil {
+		return err
+	}
+
+	room, err := s.roomRepo.FindByID(roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room: %w", err)
+	}
+
+	if room.Type == domain.RoomTypeDirect {
+		return errors.New("cannot add participants to a direct room; convert it to a group first")
 	}
 
 	// Verify user to add exists
@@ -287,11 +400,6 @@ func (s *roomService) AddParticipant(roomID, requestUserID uint, req *domain.Add
 	}
 
 	// Add participant
-	role := req.Role
-	if role == "" {
-		role = "member"
-	}
-
 	newParticipant := &domain.Participant{
 		RoomID:   roomID,
 		UserID:   req.UserID,
@@ -313,20 +421,22 @@ func (s *roomService) AddParticipant(roomID, requestUserID uint, req *domain.Add
 }
 
 func (s *roomService) RemoveParticipant(roomID, participantUserID, requestUserID uint) error {
-	// Check if requester is admin or creator
-	participant, err := s.roomRepo.FindParticipant(roomID, requestUserID)
-	if err != nil {
-		return errors.New("access denied: user is not a participant")
-	}
+	// Anyone may leave; removing others takes an admin who outranks them
+	if requestUserID != participantUserID {
+		requesterRole, err := s.requireRole(roomID, requestUserID, domain.ParticipantRoleAdmin, "remove participants")
+		if err != nil {
+			return err
+		}
 
-	if participant.Role != "admin" {
-		room, err := s.roomRepo.FindByID(roomID)
+		_, targetRole, err := participantRole(s.roomRepo, roomID, participantUserID)
 		if err != nil {
-			return fmt.Errorf("failed to get room: %w", err)
+			return err
 		}
-		if room.CreatorID != requestUserID && requestUserID != participantUserID {
-			return errors.New("only admin, creator, or the participant themselves can remove")
+		if !requesterRole.Outranks(targetRole) {
+			return fmt.Errorf("cannot remove a participant with role %s", targetRole)
 		}
+	} else if _, err := s.roomRepo.FindParticipant(roomID, requestUserID); err != nil {
+		return errors.New("access denied: user is not a participant")
 	}
 
 	if err := s.roomRepo.RemoveParticipant(roomID, participantUserID); err != nil {
@@ -346,6 +456,90 @@ func (s *roomService) LeaveRoom(roomID, userID uint) error {
 	return s.RemoveParticipant(roomID, userID, userID)
 }
 
+// UpdateParticipantRole changes another participant's role. Only the room
+// creator (the owner) may change roles, and the creator's own role cannot be
+// changed; ownership cannot be handed over this way.
+func (s *roomService) UpdateParticipantRole(roomID, targetUserID, requesterID uint, role domain.ParticipantRole) (*domain.Participant, error) {
+	if !role.Valid() {
+		return nil, errInvalidRole(role)
+	}
+
+	requesterRole, err := s.requireRole(roomID, requesterID, domain.ParticipantRoleOwner, "change roles")
+	if err != nil {
+		return nil, err
+	}
+	if targetUserID == requesterID {
+		return nil, errors.New("cannot change your own role")
+	}
+
+	room, err := s.roomRepo.FindByID(roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	if targetUserID == room.CreatorID {
+		return nil, errors.New("cannot change the role of the room creator")
+	}
+
+	target, targetRole, err := participantRole(s.roomRepo, roomID, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if !requesterRole.Outranks(targetRole) {
+		return nil, fmt.Errorf("cannot change the role of a participant with role %s", targetRole)
+	}
+	if err := checkAssignableRole(requesterRole, role); err != nil {
+		return nil, err
+	}
+
+	if err := s.roomRepo.UpdateParticipantRole(roomID, targetUserID, role); err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+	target.Role = role
+
+	// Broadcast role changed event
+	s.broadcastRoomEvent(roomID, requesterID, websocket.MessageTypeParticipantRoleChanged, map[string]interface{}{
+		"room_id": roomID,
+		"user_id": targetUserID,
+		"role":    role,
+	})
+
+	return target, nil
+}
+
+// MuteParticipant stops another participant sending messages in the room, or
+// lets them send again. Unlike SetParticipantMute it is a moderation action:
+// the requester must be an admin who outranks the participant.
+func (s *roomService) MuteParticipant(roomID, targetUserID, requesterID uint, mute bool) error {
+	requesterRole, err := s.requireRole(roomID, requesterID, domain.ParticipantRoleAdmin, "mute participants")
+	if err != nil {
+		return err
+	}
+	if targetUserID == requesterID {
+		return errors.New("cannot mute yourself")
+	}
+
+	_, targetRole, err := participantRole(s.roomRepo, roomID, targetUserID)
+	if err != nil {
+		return err
+	}
+	if !requesterRole.Outranks(targetRole) {
+		return fmt.Errorf("cannot mute a participant with role %s", targetRole)
+	}
+
+	if err := s.roomRepo.UpdateParticipantSilenced(roomID, targetUserID, mute); err != nil {
+		return fmt.Errorf("failed to mute participant: %w", err)
+	}
+
+	// Broadcast participant muted event
+	s.broadcastRoomEvent(roomID, requesterID, websocket.MessageTypeParticipantMuted, map[string]interface{}{
+		"room_id": roomID,
+		"user_id": targetUserID,
+		"muted":   mute,
+	})
+
+	return nil
+}
+
 func (s *roomService) GetParticipants(roomID, userID uint) ([]*domain.Participant, error) {
 	// Check if user is participant
 	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
@@ -360,7 +554,25 @@ func (s *roomService) GetParticipants(roomID, userID uint) ([]*domain.Participan
 	return participants, nil
 }
 
+// SetParticipantMute mutes or unmutes a room for the user. A muted user still
+// receives the room's messages over WebSocket and can still send, but gets no
+// unread or mention badges for the room. Mentions still notify them.
+func (s *roomService) SetParticipantMute(roomID, userID uint, muted bool) error {
+	if _, err := s.roomRepo.FindParticipant(roomID, userID); err != nil {
+		return errors.New("access denied: user is not a participant")
+	}
+
+	if err := s.roomRepo.UpdateParticipantMute(roomID, userID, muted); err != nil {
+		return fmt.Errorf("failed to set mute: %w", err)
+	}
+	return nil
+}
+
 func (s *roomService) GetOrCreateDirectRoom(user1ID, user2ID uint) (*domain.Room, error) {
+	if err := checkNotBlocked(s.blockRepo, user1ID, user2ID); err != nil {
+		return nil, err
+	}
+
 	// Check if direct room already exists
 	room, err := s.roomRepo.FindDirectRoom(user1ID, user2ID)
 	if err != nil {
@@ -397,6 +609,54 @@ func (s *roomService) MarkAsRead(roomID, userID uint) error {
 
 // Helper methods
 
+// participantRole returns a current participant and their role in the room.
+// The creator is always the owner, whatever their participant record says.
+func participantRole(roomRepo repository.RoomRepository, roomID, userID uint) (*domain.Participant, domain.ParticipantRole, error) {
+	participant, err := roomRepo.FindParticipant(roomID, userID)
+	if err != nil {
+		return nil, "", errors.New("user is not a participant")
+	}
+
+	room, err := roomRepo.FindByID(roomID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get room: %w", err)
+	}
+	if room.CreatorID == userID {
+		return participant, domain.ParticipantRoleOwner, nil
+	}
+	return participant, participant.Role, nil
+}
+
+// requireRole returns the user's role in the room if it is at least the given
+// one
+func (s *roomService) requireRole(roomID, userID uint, role domain.ParticipantRole, action string) (domain.ParticipantRole, error) {
+	_, userRole, err := participantRole(s.roomRepo, roomID, userID)
+	if err != nil {
+		return "", errors.New("access denied: user is not a participant")
+	}
+	if !userRole.AtLeast(role) {
+		return "", fmt.Errorf("only %s or higher can %s", role, action)
+	}
+	return userRole, nil
+}
+
+// checkAssignableRole reports whether a participant with the requester's role
+// may hand out the given role, which must rank below their own
+func checkAssignableRole(requesterRole, role domain.ParticipantRole) error {
+	if !role.Valid() {
+		return errInvalidRole(role)
+	}
+	if !requesterRole.Outranks(role) {
+		return fmt.Errorf("cannot assign role %s", role)
+	}
+	return nil
+}
+
+func errInvalidRole(role domain.ParticipantRole) error {
+	return fmt.Errorf("invalid role %q: must be one of %s, %s or %s", role,
+		domain.ParticipantRoleAdmin, domain.ParticipantRoleModerator, domain.ParticipantRoleMember)
+}
+
 func (s *roomService) broadcastRoomEvent(roomID, userID uint, eventType websocket.MessageType, data interface{}) {
 	if s.hub != nil {
 		message := websocket.NewMessage(eventType, roomID, userID, data)
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/sanitize"
+	"realtime-chat/internal/websocket"
+)
+
+func TestRoomService_ConvertToGroup(t *testing.T) {
+	roomRepo := newFakeRoomRepo()
+	svc := NewRoomService(roomRepo, newFakeUserRepo(testUsers(3)...), nil, newFakeBlockRepo(), nil)
+
+	room, err := svc.Create(1, &domain.CreateRoomRequest{
+		Type:    domain.RoomTypeDirect,
+		UserIDs: []uint{2},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := svc.AddParticipant(room.ID, 1, &domain.AddParticipantRequest{UserID: 3}); err == nil {
+		t.Fatal("AddParticipant() should fail on a direct room")
+	}
+
+	if _, err := svc.ConvertToGroup(room.ID, 3); err == nil {
+		t.Error("ConvertToGroup() should fail for a non-participant")
+	}
+
+	converted, err := svc.ConvertToGroup(room.ID, 2)
+	if err != nil {
+		t.Fatalf("ConvertToGroup() error = %v", err)
+	}
+	if converted.Type != domain.RoomTypeGroup {
+		t.Errorf("Type = %v, want %v", converted.Type, domain.RoomTypeGroup)
+	}
+
+	if err := svc.AddParticipant(room.ID, 1, &domain.AddParticipantRequest{UserID: 3}); err != nil {
+		t.Fatalf("AddParticipant() after conversion error = %v", err)
+	}
+
+	participants, err := svc.GetParticipants(room.ID, 3)
+	if err != nil {
+		t.Fatalf("GetParticipants() error = %v", err)
+	}
+	if len(participants) != 3 {
+		t.Errorf("participants = %d, want 3", len(participants))
+	}
+
+	if _, err := svc.ConvertToGroup(room.ID, 1); err == nil {
+		t.Error("ConvertToGroup() should reject a room that is already a group")
+	}
+}
+
+func TestRoomService_GetUserRooms_UnreadOnly(t *testing.T) {
+	roomRepo := newFakeRoomRepo()
+	messageRepo := &fakeMessageRepo{}
+	svc := NewRoomService(roomRepo, newFakeUserRepo(testUsers(2)...), messageRepo, newFakeBlockRepo(), nil)
+
+	var rooms []*domain.Room
+	for _, name := range []string{"older", "read", "newer"} {
+		room, err := svc.Create(1, &domain.CreateRoomRequest{Name: name, Type: domain.RoomTypeGroup, UserIDs: []uint{2}})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		rooms = append(rooms, room)
+	}
+	older, read, newer := rooms[0], rooms[1], rooms[2]
+
+	now := time.Now()
+	messageRepo.messages = []*domain.Message{
+		{RoomID: older.ID, SenderID: 2, CreatedAt: now.Add(-time.Hour)},
+		{RoomID: read.ID, SenderID: 2, CreatedAt: now},
+		{RoomID: newer.ID, SenderID: 2, CreatedAt: now.Add(-time.Minute)},
+	}
+	roomRepo.unread[older.ID] = 2
+	roomRepo.unread[newer.ID] = 5
+
+	got, err := svc.GetUserRooms(1, true)
+	if err != nil {
+		t.Fatalf("GetUserRooms() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != newer.ID || got[1].ID != older.ID {
+		t.Fatalf("GetUserRooms(unread) = %v, want rooms [%d %d]", roomIDs(got), newer.ID, older.ID)
+	}
+	for _, p := range got[0].Participants {
+		if p.UserID == 1 && p.UnreadCount != 5 {
+			t.Errorf("UnreadCount = %d, want 5", p.UnreadCount)
+		}
+	}
+
+	all, err := svc.GetUserRooms(1, false)
+	if err != nil {
+		t.Fatalf("GetUserRooms() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("GetUserRooms() returned %d rooms, want 3", len(all))
+	}
+}
+
+func TestRoomService_SearchUserRooms(t *testing.T) {
+	roomRepo := newFakeRoomRepo()
+	messageRepo := &fakeMessageRepo{}
+	svc := NewRoomService(roomRepo, newFakeUserRepo(testUsers(3)...), messageRepo, newFakeBlockRepo(), nil)
+
+	for _, name := range []string{"Design Review", "random", "design-ops"} {
+		if _, err := svc.Create(1, &domain.CreateRoomRequest{Name: name, Type: domain.RoomTypeGroup, UserIDs: []uint{2}}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	// user3 is not in this one
+	if _, err := svc.Create(3, &domain.CreateRoomRequest{Name: "design private", Type: domain.RoomTypeGroup}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	messageRepo.messages = []*domain.Message{{RoomID: 1, SenderID: 1, Content: "agenda", CreatedAt: time.Now()}}
+	roomRepo.unread[1] = 3
+
+	got, err := svc.SearchUserRooms(2, "  design ")
+	if err != nil {
+		t.Fatalf("SearchUserRooms() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Fatalf("SearchUserRooms() = %v, want rooms [1 3]", roomIDs(got))
+	}
+	if got[0].LastMessage == nil || got[0].LastMessage.Content != "agenda" {
+		t.Errorf("LastMessage = %v, want the room's last message", got[0].LastMessage)
+	}
+	for _, p := range got[0].Participants {
+		if p.UserID == 2 && p.UnreadCount != 3 {
+			t.Errorf("UnreadCount = %d, want 3", p.UnreadCount)
+		}
+	}
+
+	if _, err := svc.SearchUserRooms(2, "   "); err == nil {
+		t.Error("SearchUserRooms() with a blank query should fail")
+	}
+}
+
+func roomIDs(rooms []*domain.Room) []uint {
+	ids := make([]uint, len(rooms))
+	for i, room := range rooms {
+		ids[i] = room.ID
+	}
+	return ids
+}
+
+// newRoleTestRoom returns a group room created by user1, the owner, with
+// user2 as an admin, user3 as a moderator and users 4 and 5 as members.
+// User 6 is not in the room.
+func newRoleTestRoom(t *testing.T) (RoomService, *fakeRoomRepo) {
+	t.Helper()
+
+	roomRepo := newFakeRoomRepo()
+	svc := NewRoomService(roomRepo, newFakeUserRepo(testUsers(6)...), nil, newFakeBlockRepo(), nil)
+
+	room, err := svc.Create(1, &domain.CreateRoomRequest{
+		Name:    "general",
+		Type:    domain.RoomTypeGroup,
+		UserIDs: []uint{2, 3, 4, 5},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	roomRepo.UpdateParticipantRole(room.ID, 2, domain.ParticipantRoleAdmin)
+	roomRepo.UpdateParticipantRole(room.ID, 3, domain.ParticipantRoleModerator)
+	return svc, roomRepo
+}
+
+func TestRoomService_RolePermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  uint
+		allowed bool
+	}{
+		{name: "owner", userID: 1, allowed: true},
+		{name: "admin", userID: 2, allowed: true},
+		{name: "moderator", userID: 3, allowed: false},
+		{name: "member", userID: 4, allowed: false},
+	}
+
+	actions := []struct {
+		name      string
+		ownerOnly bool
+		run       func(svc RoomService, userID uint) error
+	}{
+		{name: "Update", run: func(svc RoomService, userID uint) error {
+			_, err := svc.Update(1, userID, &domain.UpdateRoomRequest{Name: "renamed"})
+			return err
+		}},
+		{name: "Archive", run: func(svc RoomService, userID uint) error {
+			return svc.Archive(1, userID)
+		}},
+		{name: "AddParticipant", run: func(svc RoomService, userID uint) error {
+			return svc.AddParticipant(1, userID, &domain.AddParticipantRequest{UserID: 6})
+		}},
+		{name: "RemoveParticipant", run: func(svc RoomService, userID uint) error {
+			return svc.RemoveParticipant(1, 5, userID)
+		}},
+		{name: "UpdateParticipantRole", ownerOnly: true, run: func(svc RoomService, userID uint) error {
+			_, err := svc.UpdateParticipantRole(1, 5, userID, domain.ParticipantRoleModerator)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, action := range actions {
+			t.Run(tt.name+"/"+action.name, func(t *testing.T) {
+				svc, _ := newRoleTestRoom(t)
+
+				allowed := tt.allowed
+				if action.ownerOnly {
+					allowed = tt.userID == 1
+				}
+				err := action.run(svc, tt.userID)
+				if (err == nil) != allowed {
+					t.Errorf("%s() error = %v, allowed %v", action.name, err, allowed)
+				}
+			})
+		}
+	}
+}
+
+func TestRoomService_UpdateParticipantRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		requesterID uint
+		targetID    uint
+		role        domain.ParticipantRole
+		wantErr     bool
+	}{
+		{name: "owner promotes a member to admin", requesterID: 1, targetID: 4, role: domain.ParticipantRoleAdmin},
+		{name: "owner demotes an admin", requesterID: 1, targetID: 2, role: domain.ParticipantRoleMember},
+		{name: "owner demotes a moderator", requesterID: 1, targetID: 3, role: domain.ParticipantRoleMember},
+		{name: "admin cannot promote a member", requesterID: 2, targetID: 4, role: domain.ParticipantRoleModerator, wantErr: true},
+		{name: "admin cannot demote a moderator", requesterID: 2, targetID: 3, role: domain.ParticipantRoleMember, wantErr: true},
+		{name: "admin cannot grant admin", requesterID: 2, targetID: 4, role: domain.ParticipantRoleAdmin, wantErr: true},
+		{name: "admin cannot demote the creator", requesterID: 2, targetID: 1, role: domain.ParticipantRoleMember, wantErr: true},
+		{name: "creator's own role cannot be changed", requesterID: 1, targetID: 1, role: domain.ParticipantRoleAdmin, wantErr: true},
+		{name: "ownership cannot be granted", requesterID: 1, targetID: 2, role: domain.ParticipantRoleOwner, wantErr: true},
+		{name: "own role cannot be changed", requesterID: 2, targetID: 2, role: domain.ParticipantRoleMember, wantErr: true},
+		{name: "unknown role", requesterID: 1, targetID: 4, role: "superuser", wantErr: true},
+		{name: "target must be a participant", requesterID: 1, targetID: 6, role: domain.ParticipantRoleMember, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, roomRepo := newRoleTestRoom(t)
+
+			participant, err := svc.UpdateParticipantRole(1, tt.targetID, tt.requesterID, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateParticipantRole() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if participant.Role != tt.role {
+				t.Errorf("returned Role = %s, want %s", participant.Role, tt.role)
+			}
+			stored, _ := roomRepo.FindParticipant(1, tt.targetID)
+			if stored.Role != tt.role {
+				t.Errorf("stored Role = %s, want %s", stored.Role, tt.role)
+			}
+		})
+	}
+}
+
+func TestRoomService_UpdateParticipantRole_InvalidRole(t *testing.T) {
+	svc, _ := newRoleTestRoom(t)
+
+	_, err := svc.UpdateParticipantRole(1, 4, 1, "superuser")
+	if err == nil || !strings.Contains(err.Error(), "must be one of admin, moderator or member") {
+		t.Errorf("UpdateParticipantRole() error = %v, want one listing the allowed roles", err)
+	}
+}
+
+func TestRoomService_UpdateParticipantRole_Broadcast(t *testing.T) {
+	hub := websocket.NewHub(websocket.HubConfig{})
+	go hub.Run()
+
+	roomRepo := newFakeRoomRepo()
+	svc := NewRoomService(roomRepo, newFakeUserRepo(testUsers(3)...), nil, newFakeBlockRepo(), hub)
+	room, err := svc.Create(1, &domain.CreateRoomRequest{Name: "general", Type: domain.RoomTypeGroup, UserIDs: []uint{2, 3}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	conn := connectTestClient(t, hub, room.ID, 3)
+	if _, err := svc.UpdateParticipantRole(room.ID, 2, 1, domain.ParticipantRoleModerator); err != nil {
+		t.Fatalf("UpdateParticipantRole() error = %v", err)
+	}
+
+	var event struct {
+		Type websocket.MessageType `json:"type"`
+		Data struct {
+			UserID uint                   `json:"user_id"`
+			Role   domain.ParticipantRole `json:"role"`
+		} `json:"data"`
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("failed to read broadcast: %v", err)
+	}
+	if event.Type != websocket.MessageTypeParticipantRoleChanged || event.Data.UserID != 2 || event.Data.Role != domain.ParticipantRoleModerator {
+		t.Errorf("broadcast = %s for user %d as %s, want %s for user 2 as moderator", event.Type, event.Data.UserID, event.Data.Role, websocket.MessageTypeParticipantRoleChanged)
+	}
+}
+
+func TestRoomService_ParticipantManagementByRank(t *testing.T) {
+	svc, roomRepo := newRoleTestRoom(t)
+
+	if err := svc.AddParticipant(1, 2, &domain.AddParticipantRequest{UserID: 6, Role: domain.ParticipantRoleAdmin}); err == nil {
+		t.Error("AddParticipant() by an admin should not grant admin")
+	}
+	if err := svc.AddParticipant(1, 1, &domain.AddParticipantRequest{UserID: 6, Role: domain.ParticipantRoleAdmin}); err != nil {
+		t.Fatalf("AddParticipant() by the owner error = %v", err)
+	}
+	if added, _ := roomRepo.FindParticipant(1, 6); added == nil || added.Role != domain.ParticipantRoleAdmin {
+		t.Errorf("added participant = %+v, want an admin", added)
+	}
+
+	// Admins cannot remove their peers or the owner
+	if err := svc.RemoveParticipant(1, 6, 2); err == nil {
+		t.Error("RemoveParticipant() of an admin by an admin should fail")
+	}
+	if err := svc.RemoveParticipant(1, 1, 2); err == nil {
+		t.Error("RemoveParticipant() of the owner by an admin should fail")
+	}
+	if err := svc.RemoveParticipant(1, 3, 2); err != nil {
+		t.Errorf("RemoveParticipant() of a moderator by an admin error = %v", err)
+	}
+
+	// Anyone may leave
+	if err := svc.LeaveRoom(1, 4); err != nil {
+		t.Errorf("LeaveRoom() by a member error = %v", err)
+	}
+}
+
+func TestRoomService_MuteParticipant(t *testing.T) {
+	tests := []struct {
+		name        string
+		requesterID uint
+		targetID    uint
+		wantErr     bool
+	}{
+		{name: "owner mutes an admin", requesterID: 1, targetID: 2},
+		{name: "admin mutes a member", requesterID: 2, targetID: 4},
+		{name: "moderator cannot mute", requesterID: 3, targetID: 4, wantErr: true},
+		{name: "admin cannot mute the owner", requesterID: 2, targetID: 1, wantErr: true},
+		{name: "cannot mute yourself", requesterID: 1, targetID: 1, wantErr: true},
+		{name: "target must be a participant", requesterID: 1, targetID: 6, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, roomRepo := newRoleTestRoom(t)
+
+			err := svc.MuteParticipant(1, tt.targetID, tt.requesterID, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MuteParticipant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if stored, _ := roomRepo.FindParticipant(1, tt.targetID); !stored.IsSilenced {
+				t.Error("participant should be muted")
+			}
+		})
+	}
+}
+
+func TestRoomService_MutedParticipantCannotSend(t *testing.T) {
+	svc, roomRepo := newRoleTestRoom(t)
+	messageSvc := NewMessageService(&fakeMessageRepo{}, roomRepo, newFakeUserRepo(testUsers(6)...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+	req := &domain.SendMessageRequest{Content: "hello", Type: domain.MessageTypeText}
+
+	if err := svc.MuteParticipant(1, 4, 2, true); err != nil {
+		t.Fatalf("MuteParticipant() error = %v", err)
+	}
+	if _, err := messageSvc.Send(1, 4, req); err == nil {
+		t.Error("Send() by a muted participant should fail")
+	}
+	// Muting the room's notifications is unrelated
+	if participant, _ := roomRepo.FindParticipant(1, 4); participant.IsMuted {
+		t.Error("IsMuted should be left alone")
+	}
+
+	if err := svc.MuteParticipant(1, 4, 2, false); err != nil {
+		t.Fatalf("MuteParticipant() unmute error = %v", err)
+	}
+	if _, err := messageSvc.Send(1, 4, req); err != nil {
+		t.Errorf("Send() after unmuting error = %v", err)
+	}
+}
+
+func TestRoomService_UpdateRetention(t *testing.T) {
+	svc, _ := newRoleTestRoom(t)
+	days := func(n int) *int { return &n }
+
+	if _, err := svc.Update(1, 1, &domain.UpdateRoomRequest{RetentionDays: days(-1)}); err == nil {
+		t.Error("Update() should reject a negative retention")
+	}
+
+	room, err := svc.Update(1, 1, &domain.UpdateRoomRequest{RetentionDays: days(30)})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if room.RetentionDays == nil || *room.RetentionDays != 30 {
+		t.Errorf("RetentionDays = %v, want 30", room.RetentionDays)
+	}
+
+	// Leaving it out keeps the retention; 0 removes it
+	if room, _ = svc.Update(1, 1, &domain.UpdateRoomRequest{Name: "renamed"}); room.RetentionDays == nil {
+		t.Error("Update() without retention_days should keep the retention")
+	}
+	if room, _ = svc.Update(1, 1, &domain.UpdateRoomRequest{RetentionDays: days(0)}); room.RetentionDays != nil {
+		t.Errorf("RetentionDays = %d, want nil after setting 0", *room.RetentionDays)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+)
+
+// scheduledBatchSize caps how many due messages one SendDue call sends
+const scheduledBatchSize = 100
+
+type ScheduledMessageService interface {
+	Schedule(roomID, userID uint, req *domain.ScheduleMessageRequest) (*domain.ScheduledMessage, error)
+	// List returns the room's messages waiting to be sent that the user may
+	// see: their own, or everyone's for room admins
+	List(roomID, userID uint) ([]*domain.ScheduledMessage, error)
+	// Cancel deletes a message before it is sent. Only the user who
+	// scheduled it and room admins may cancel it.
+	Cancel(roomID, scheduledID, userID uint) error
+	// SendDue sends every scheduled message whose time has come through
+	// MessageService.Send and returns the IDs of the messages it sent.
+	SendDue(now time.Time) ([]uint, error)
+	// Run calls SendDue every interval. It never returns.
+	Run(interval time.Duration)
+}
+
+type scheduledMessageService struct {
+	scheduledRepo  repository.ScheduledMessageRepository
+	roomRepo       repository.RoomRepository
+	messageService MessageService
+}
+
+func NewScheduledMessageService(
+	scheduledRepo repository.ScheduledMessageRepository,
+	roomRepo repository.RoomRepository,
+	messageService MessageService,
+) ScheduledMessageService {
+	return &scheduledMessageService{
+		scheduledRepo:  scheduledRepo,
+		roomRepo:       roomRepo,
+		messageService: messageService,
+	}
+}
+
+func (s *scheduledMessageService) Schedule(roomID, userID uint, req *domain.ScheduleMessageRequest) (*domain.ScheduledMessage, error) {
+	// Verify user is participant. Whether they may still send is checked
+	// again when the message goes out.
+	participant, err := s.roomRepo.FindParticipant(roomID, userID)
+	if err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+	if participant.IsSilenced {
+		return nil, errors.New("you have been muted in this room")
+	}
+
+	if !req.SendAt.After(time.Now()) {
+		return nil, errors.New("send_at must be in the future")
+	}
+
+	messageType := req.Type
+	if messageType == "" {
+		messageType = domain.MessageTypeText
+	}
+	if messageType == domain.MessageTypeSystem {
+		return nil, errors.New("system messages cannot be sent by users")
+	}
+
+	scheduled := &domain.ScheduledMessage{
+		RoomID:    roomID,
+		SenderID:  userID,
+		Type:      messageType,
+		Content:   req.Content,
+		ReplyToID: req.ReplyToID,
+		SendAt:    req.SendAt,
+		Status:    domain.ScheduledMessagePending,
+	}
+	if err := s.scheduledRepo.Create(scheduled); err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+func (s *scheduledMessageService) List(roomID, userID uint) ([]*domain.ScheduledMessage, error) {
+	_, role, err := participantRole(s.roomRepo, roomID, userID)
+	if err != nil {
+		return nil, errors.New("access denied: user is not a participant")
+	}
+
+	senderID := userID
+	if role.AtLeast(domain.ParticipantRoleAdmin) {
+		senderID = 0
+	}
+
+	scheduled, err := s.scheduledRepo.FindPending(roomID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled == nil {
+		scheduled = []*domain.ScheduledMessage{}
+	}
+	return scheduled, nil
+}
+
+func (s *scheduledMessageService) Cancel(roomID, scheduledID, userID uint) error {
+	scheduled, err := s.scheduledRepo.FindByID(scheduledID)
+	if err != nil || scheduled.RoomID != roomID {
+		return errors.New("scheduled message not found")
+	}
+
+	if scheduled.SenderID != userID {
+		_, role, err := participantRole(s.roomRepo, roomID, userID)
+		if err != nil || !role.AtLeast(domain.ParticipantRoleAdmin) {
+			return errors.New("only the sender or a room admin can cancel a scheduled message")
+		}
+	}
+
+	deleted, err := s.scheduledRepo.DeletePending(scheduledID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("scheduled message was already sent")
+	}
+	return nil
+}
+
+func (s *scheduledMessageService) SendDue(now time.Time) ([]uint, error) {
+	due, err := s.scheduledRepo.FindDue(now, scheduledBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	sent := make([]uint, 0, len(due))
+	for _, scheduled := range due {
+		// Another instance may have picked the message up already
+		claimed, err := s.scheduledRepo.UpdateStatus(scheduled.ID, domain.ScheduledMessagePending, domain.ScheduledMessageSending)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		message, err := s.messageService.Send(scheduled.RoomID, scheduled.SenderID, &domain.SendMessageRequest{
+			Content:   scheduled.Content,
+			Type:      scheduled.Type,
+			ReplyToID: scheduled.ReplyToID,
+		})
+		if err != nil {
+			// Rate limited messages are retried on the next run
+			var rateLimitErr *RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				if _, err := s.scheduledRepo.UpdateStatus(scheduled.ID, domain.ScheduledMessageSending, domain.ScheduledMessagePending); err != nil {
+					return sent, err
+				}
+				continue
+			}
+			if err := s.scheduledRepo.MarkFailed(scheduled.ID, err.Error()); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err := s.scheduledRepo.MarkSent(scheduled.ID, message.ID, now); err != nil {
+			return sent, fmt.Errorf("message %d was sent but: %w", message.ID, err)
+		}
+		sent = append(sent, message.ID)
+	}
+
+	return sent, nil
+}
+
+func (s *scheduledMessageService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		sent, err := s.SendDue(now)
+		if err != nil {
+			log.Printf("Failed to send scheduled messages: %v", err)
+		}
+		if len(sent) > 0 {
+			log.Printf("Sent %d scheduled messages", len(sent))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/sanitize"
+)
+
+func setupScheduledMessageService(users []*domain.User) (ScheduledMessageService, *fakeRoomRepo, *fakeMessageRepo, *fakeScheduledRepo) {
+	roomRepo := newFakeRoomRepo()
+	roomRepo.Create(&domain.Room{Name: "general", Type: domain.RoomTypeGroup, CreatorID: users[0].ID})
+	for _, user := range users {
+		roomRepo.AddParticipant(&domain.Participant{RoomID: 1, UserID: user.ID, Role: domain.ParticipantRoleMember})
+	}
+	messageRepo := &fakeMessageRepo{}
+	scheduledRepo := &fakeScheduledRepo{}
+	messageService := NewMessageService(messageRepo, roomRepo, newFakeUserRepo(users...), newFakeBlockRepo(), sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+	return NewScheduledMessageService(scheduledRepo, roomRepo, messageService), roomRepo, messageRepo, scheduledRepo
+}
+
+func TestScheduledMessageService_SendDue(t *testing.T) {
+	users := testUsers(2)
+	svc, roomRepo, messageRepo, scheduledRepo := setupScheduledMessageService(users)
+
+	now := time.Now()
+	soon, err := svc.Schedule(1, users[1].ID, &domain.ScheduleMessageRequest{Content: "happy birthday!", SendAt: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	later, err := svc.Schedule(1, users[0].ID, &domain.ScheduleMessageRequest{Content: "reminder", SendAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if _, err := svc.Schedule(1, users[0].ID, &domain.ScheduleMessageRequest{Content: "too late", SendAt: now.Add(-time.Minute)}); err == nil {
+		t.Error("Schedule() in the past should fail")
+	}
+
+	// Nothing is due yet
+	if sent, err := svc.SendDue(now); err != nil || len(sent) != 0 {
+		t.Fatalf("SendDue() before the send time = %v, %v, want nothing sent", sent, err)
+	}
+
+	sent, err := svc.SendDue(now.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if len(sent) != 1 || len(messageRepo.messages) != 1 {
+		t.Fatalf("SendDue() = %v, want only the first message sent", sent)
+	}
+	message := messageRepo.messages[0]
+	if message.SenderID != users[1].ID || message.Content != "happy birthday!" || message.Type != domain.MessageTypeText {
+		t.Errorf("sent message = %+v, want user2's scheduled text", message)
+	}
+	if soon.Status != domain.ScheduledMessageSent || soon.MessageID == nil || *soon.MessageID != message.ID {
+		t.Errorf("scheduled message = %+v, want it marked sent as message %d", soon, message.ID)
+	}
+
+	// A sender who can no longer post fails instead of being sent
+	roomRepo.RemoveParticipant(1, users[0].ID)
+	if sent, err := svc.SendDue(now.Add(2 * time.Hour)); err != nil || len(sent) != 0 {
+		t.Fatalf("SendDue() for a sender who left = %v, %v, want nothing sent", sent, err)
+	}
+	if later.Status != domain.ScheduledMessageFailed || later.Error == "" {
+		t.Errorf("scheduled message = %+v, want it failed with the reason", later)
+	}
+
+	if pending, _ := scheduledRepo.FindPending(1, 0); len(pending) != 0 {
+		t.Errorf("%d messages still pending, want none", len(pending))
+	}
+}
+
+func TestScheduledMessageService_Cancel(t *testing.T) {
+	users := testUsers(4) // user1 owns the room, user2 is an admin
+	svc, roomRepo, _, _ := setupScheduledMessageService(users)
+	roomRepo.UpdateParticipantRole(1, users[1].ID, domain.ParticipantRoleAdmin)
+
+	sendAt := time.Now().Add(time.Hour)
+	schedule := func(userID uint) *domain.ScheduledMessage {
+		t.Helper()
+		scheduled, err := svc.Schedule(1, userID, &domain.ScheduleMessageRequest{Content: "later", SendAt: sendAt})
+		if err != nil {
+			t.Fatalf("Schedule() error = %v", err)
+		}
+		return scheduled
+	}
+	own := schedule(users[2].ID)
+	byAdmin := schedule(users[2].ID)
+	schedule(users[3].ID)
+
+	// Members only see their own; admins see everyone's
+	if list, err := svc.List(1, users[2].ID); err != nil || len(list) != 2 {
+		t.Errorf("List() by a member = %d messages, %v, want their 2", len(list), err)
+	}
+	if list, err := svc.List(1, users[1].ID); err != nil || len(list) != 3 {
+		t.Errorf("List() by an admin = %d messages, %v, want all 3", len(list), err)
+	}
+
+	if err := svc.Cancel(1, own.ID, users[3].ID); err == nil {
+		t.Error("Cancel() by another member should fail")
+	}
+	if err := svc.Cancel(1, own.ID, users[2].ID); err != nil {
+		t.Errorf("Cancel() by the sender error = %v", err)
+	}
+	if err := svc.Cancel(1, byAdmin.ID, users[1].ID); err != nil {
+		t.Errorf("Cancel() by a room admin error = %v", err)
+	}
+	if err := svc.Cancel(2, byAdmin.ID, users[1].ID); err == nil {
+		t.Error("Cancel() through another room should fail")
+	}
+
+	// Sent messages can no longer be cancelled
+	sent := schedule(users[2].ID)
+	if _, err := svc.SendDue(sendAt); err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if err := svc.Cancel(1, sent.ID, users[2].ID); err == nil {
+		t.Error("Cancel() of a sent message should fail")
+	}
+}
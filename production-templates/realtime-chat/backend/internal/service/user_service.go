@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/repository"
+)
+
+type UserService interface {
+	// Block stops the two users from opening or messaging a direct room
+	// with each other. Blocking someone twice is not an error.
+	Block(blockerID, blockedID uint) error
+	Unblock(blockerID, blockedID uint) error
+	ListBlocked(userID uint) ([]*domain.User, error)
+}
+
+type userService struct {
+	userRepo  repository.UserRepository
+	blockRepo repository.BlockRepository
+}
+
+func NewUserService(userRepo repository.UserRepository, blockRepo repository.BlockRepository) UserService {
+	return &userService{
+		userRepo:  userRepo,
+		blockRepo: blockRepo,
+	}
+}
+
+func (s *userService) Block(blockerID, blockedID uint) error {
+	if blockerID == blockedID {
+		return errors.New("cannot block yourself")
+	}
+
+	if _, err := s.userRepo.FindByID(blockedID); err != nil {
+		return fmt.Errorf("user to block not found: %w", err)
+	}
+
+	return s.blockRepo.Create(&domain.UserBlock{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+}
+
+func (s *userService) Unblock(blockerID, blockedID uint) error {
+	return s.blockRepo.Delete(blockerID, blockedID)
+}
+
+func (s *userService) ListBlocked(userID uint) ([]*domain.User, error) {
+	return s.blockRepo.FindBlockedUsers(userID)
+}
+
+// checkNotBlocked fails if either user has blocked the other
+func checkNotBlocked(blockRepo repository.BlockRepository, userID, otherUserID uint) error {
+	blocked, err := blockRepo.ExistsBetween(userID, otherUserID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errors.New("cannot message this user: one of you has blocked the other")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"testing"
+
+	"realtime-chat/internal/domain"
+	"realtime-chat/internal/sanitize"
+)
+
+func TestUserService_BlockPreventsDirectMessages(t *testing.T) {
+	users := testUsers(3) // user1 blocks user2; user3 is unaffected
+	userRepo := newFakeUserRepo(users...)
+	roomRepo := newFakeRoomRepo()
+	blockRepo := newFakeBlockRepo()
+	userSvc := NewUserService(userRepo, blockRepo)
+	roomSvc := NewRoomService(roomRepo, userRepo, &fakeMessageRepo{}, blockRepo, nil)
+	messageSvc := NewMessageService(&fakeMessageRepo{}, roomRepo, userRepo, blockRepo, sanitize.NewSanitizer(sanitize.ModeEscape), nil, nil, nil)
+
+	// A direct room and a group room that exist before the block
+	dm, err := roomSvc.GetOrCreateDirectRoom(users[0].ID, users[1].ID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDirectRoom() error = %v", err)
+	}
+	group, err := roomSvc.Create(users[0].ID, &domain.CreateRoomRequest{Name: "general", Type: domain.RoomTypeGroup, UserIDs: []uint{users[1].ID}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := userSvc.Block(users[0].ID, users[1].ID); err != nil {
+		t.Fatalf("Block() error = %v", err)
+	}
+	if err := userSvc.Block(users[0].ID, users[1].ID); err != nil {
+		t.Errorf("Block() twice error = %v", err)
+	}
+
+	// The block applies in both directions
+	if _, err := roomSvc.GetOrCreateDirectRoom(users[1].ID, users[0].ID); err == nil {
+		t.Error("GetOrCreateDirectRoom() by the blocked user should fail")
+	}
+	if _, err := roomSvc.GetOrCreateDirectRoom(users[0].ID, users[1].ID); err == nil {
+		t.Error("GetOrCreateDirectRoom() by the blocker should fail")
+	}
+	if _, err := roomSvc.Create(users[1].ID, &domain.CreateRoomRequest{Type: domain.RoomTypeDirect, UserIDs: []uint{users[0].ID}}); err == nil {
+		t.Error("Create() of a direct room with a blocker should fail")
+	}
+	for _, sender := range users[:2] {
+		if _, err := messageSvc.Send(dm.ID, sender.ID, &domain.SendMessageRequest{Content: "hi", Type: domain.MessageTypeText}); err == nil {
+			t.Errorf("Send() by user %d in a blocked direct room should fail", sender.ID)
+		}
+	}
+
+	// Other rooms and users are unaffected
+	if _, err := messageSvc.Send(group.ID, users[1].ID, &domain.SendMessageRequest{Content: "hi", Type: domain.MessageTypeText}); err != nil {
+		t.Errorf("Send() in a group room error = %v", err)
+	}
+	if _, err := roomSvc.GetOrCreateDirectRoom(users[2].ID, users[1].ID); err != nil {
+		t.Errorf("GetOrCreateDirectRoom() between unblocked users error = %v", err)
+	}
+
+	blocked, err := userSvc.ListBlocked(users[0].ID)
+	if err != nil {
+		t.Fatalf("ListBlocked() error = %v", err)
+	}
+	if len(blocked) != 1 || blocked[0].ID != users[1].ID {
+		t.Errorf("ListBlocked() returned %d users, want user %d", len(blocked), users[1].ID)
+	}
+
+	if err := userSvc.Unblock(users[0].ID, users[1].ID); err != nil {
+		t.Fatalf("Unblock() error = %v", err)
+	}
+	if _, err := messageSvc.Send(dm.ID, users[1].ID, &domain.SendMessageRequest{Content: "hi again", Type: domain.MessageTypeText}); err != nil {
+		t.Errorf("Send() after unblocking error = %v", err)
+	}
+}
+
+func TestUserService_BlockValidation(t *testing.T) {
+	users := testUsers(1)
+	svc := NewUserService(newFakeUserRepo(users...), newFakeBlockRepo())
+
+	if err := svc.Block(users[0].ID, users[0].ID); err == nil {
+		t.Error("Block() of yourself should fail")
+	}
+	if err := svc.Block(users[0].ID, 99); err == nil {
+		t.Error("Block() of an unknown user should fail")
+	}
+}
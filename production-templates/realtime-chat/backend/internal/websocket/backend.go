@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+)
+
+// Envelope carries a message from the hub that sent it to the hubs of every
+// server instance sharing a backend
+type Envelope struct {
+	// Instance ID of the sending hub, which has already delivered the
+	// message to its own clients
+	Origin  string   `json:"origin"`
+	Message *Message `json:"message"`
+
+	// Set for messages sent with SendToUsers
+	Recipients []uint `json:"recipients,omitempty"`
+}
+
+// HubBackend relays messages between the hubs of server instances so clients
+// connected to any of them receive every broadcast
+type HubBackend interface {
+	// Publish hands the envelope to every subscriber, including the
+	// publishing hub's own
+	Publish(ctx context.Context, envelope *Envelope) error
+	// Subscribe calls handle for each published envelope until the returned
+	// function is called. The subscription is active once Subscribe returns.
+	Subscribe(handle func(*Envelope)) (unsubscribe func(), err error)
+}
+
+// MemoryBackend relays messages between hubs in the same process. It is the
+// default backend, for running a single server instance.
+type MemoryBackend struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(*Envelope)
+	nextID      int
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		subscribers: make(map[int]func(*Envelope)),
+	}
+}
+
+func (b *MemoryBackend) Publish(ctx context.Context, envelope *Envelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handle := range b.subscribers {
+		handle(envelope)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Subscribe(handle func(*Envelope)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handle
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}, nil
+}
+
+// PresenceCounter counts each user's open connections across every server
+// instance, so a user only goes offline once their last connection anywhere
+// drops
+type PresenceCounter interface {
+	// Add changes the user's connection count by delta and returns the new
+	// count
+	Add(ctx context.Context, userID uint, delta int) (int, error)
+}
+
+// MemoryPresence counts connections within one process. It is the default,
+// for running a single server instance.
+type MemoryPresence struct {
+	mu     sync.Mutex
+	counts map[uint]int
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{
+		counts: make(map[uint]int),
+	}
+}
+
+func (p *MemoryPresence) Add(ctx context.Context, userID uint, delta int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := p.counts[userID] + delta
+	if count <= 0 {
+		delete(p.counts, userID)
+		return 0, nil
+	}
+	p.counts[userID] = count
+	return count, nil
+}
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend records published envelopes and lets tests relay envelopes
+// as if another instance had published them
+type fakeBackend struct {
+	mu         sync.Mutex
+	published  []*Envelope
+	handle     func(*Envelope)
+	subscribed chan struct{}
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{subscribed: make(chan struct{})}
+}
+
+func (b *fakeBackend) Publish(ctx context.Context, envelope *Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, envelope)
+	return nil
+}
+
+func (b *fakeBackend) Subscribe(handle func(*Envelope)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handle = handle
+	close(b.subscribed)
+	return func() {}, nil
+}
+
+func (b *fakeBackend) relay(t *testing.T, envelope *Envelope) {
+	t.Helper()
+
+	select {
+	case <-b.subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("hub did not subscribe to the backend")
+	}
+	b.mu.Lock()
+	handle := b.handle
+	b.mu.Unlock()
+	handle(envelope)
+}
+
+// waitSubscribers waits until n hubs have subscribed to the backend
+func waitSubscribers(t *testing.T, backend *MemoryBackend, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		backend.mu.RLock()
+		count := len(backend.subscribers)
+		backend.mu.RUnlock()
+		if count >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d hub(s) subscribed to the backend, want %d", count, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// expectMessage waits for the next message queued for the client
+func expectMessage(t *testing.T, client *Client) *Message {
+	t.Helper()
+
+	select {
+	case msg := <-client.send:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("user %d in room %d received nothing", client.UserID, client.RoomID)
+		return nil
+	}
+}
+
+func expectNoMessage(t *testing.T, client *Client) {
+	t.Helper()
+
+	select {
+	case msg := <-client.send:
+		t.Errorf("user %d in room %d received unexpected %s message", client.UserID, client.RoomID, msg.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_BackendPublishesBroadcasts(t *testing.T) {
+	backend := newFakeBackend()
+	hub := NewHub(HubConfig{Backend: backend})
+	go hub.Run()
+
+	client := NewClient(hub, nil, 1, 7)
+	hub.Register(client)
+
+	hub.Broadcast(NewMessage(MessageTypeNewMessage, 1, 8, "hello"))
+	hub.SendToUsers(NewMessage(MessageTypeUserMentioned, 1, 8, "hi"), []uint{7})
+
+	// Local clients get each message straight away
+	expectMessage(t, client)
+	expectMessage(t, client)
+
+	backend.mu.Lock()
+	published := backend.published
+	backend.mu.Unlock()
+	if len(published) != 2 {
+		t.Fatalf("published %d envelopes, want 2", len(published))
+	}
+	for _, envelope := range published {
+		if envelope.Origin != hub.instanceID {
+			t.Errorf("Origin = %q, want the hub's instance ID %q", envelope.Origin, hub.instanceID)
+		}
+	}
+	if got := published[1].Recipients; len(got) != 1 || got[0] != 7 {
+		t.Errorf("Recipients = %v, want [7]", got)
+	}
+}
+
+func TestHub_BackendFanOut(t *testing.T) {
+	backend := newFakeBackend()
+	hub := NewHub(HubConfig{Backend: backend})
+	go hub.Run()
+
+	inRoom := NewClient(hub, nil, 1, 7)
+	otherRoom := NewClient(hub, nil, 2, 8)
+	hub.Register(inRoom)
+	hub.Register(otherRoom)
+
+	// Another instance's room message reaches the local clients in the room
+	backend.relay(t, &Envelope{Origin: "other", Message: NewMessage(MessageTypeNewMessage, 1, 9, "remote")})
+	if msg := expectMessage(t, inRoom); msg.Data != "remote" {
+		t.Errorf("received %v, want the relayed message", msg.Data)
+	}
+	expectNoMessage(t, otherRoom)
+
+	// Its targeted messages reach the recipients in any room
+	backend.relay(t, &Envelope{Origin: "other", Message: NewMessage(MessageTypeUserMentioned, 1, 9, "ping"), Recipients: []uint{8}})
+	expectMessage(t, otherRoom)
+	expectNoMessage(t, inRoom)
+
+	// The hub's own messages were delivered when sent and are not repeated
+	backend.relay(t, &Envelope{Origin: hub.instanceID, Message: NewMessage(MessageTypeNewMessage, 1, 9, "echo")})
+	expectNoMessage(t, inRoom)
+}
+
+func TestHub_MemoryBackendAcrossHubs(t *testing.T) {
+	backend := NewMemoryBackend()
+	first := NewHub(HubConfig{Backend: backend})
+	second := NewHub(HubConfig{Backend: backend})
+	go first.Run()
+	go second.Run()
+	waitSubscribers(t, backend, 2)
+
+	local := NewClient(first, nil, 1, 7)
+	remote := NewClient(second, nil, 1, 8)
+	first.Register(local)
+	second.Register(remote)
+
+	first.Broadcast(NewMessage(MessageTypeNewMessage, 1, 7, "hello"))
+	expectMessage(t, local)
+	expectMessage(t, remote)
+
+	// Each client gets the message exactly once
+	expectNoMessage(t, local)
+	expectNoMessage(t, remote)
+
+	second.SendToUsers(NewMessage(MessageTypeUserMentioned, 1, 8, "hi"), []uint{7})
+	expectMessage(t, local)
+	expectNoMessage(t, remote)
+
+	// A stopped hub unsubscribes, the other keeps relaying
+	second.quitOnce.Do(func() { close(second.quit) })
+	<-second.stopped
+	backend.mu.RLock()
+	remaining := len(backend.subscribers)
+	backend.mu.RUnlock()
+	if remaining != 1 {
+		t.Errorf("%d subscriber(s) after a hub stopped, want 1", remaining)
+	}
+
+	first.Broadcast(NewMessage(MessageTypeNewMessage, 1, 7, "still here"))
+	expectMessage(t, local)
+}
@@ -27,17 +27,23 @@ type Client struct {
 	hub    *Hub
 	conn   *websocket.Conn
 	send   chan *Message
+	done   chan struct{} // Closed when WritePump returns
 	RoomID uint
 	UserID uint
+
+	// Negotiated event schema version, see protocol.go
+	Protocol string
 }
 
 func NewClient(hub *Hub, conn *websocket.Conn, roomID, userID uint) *Client {
 	return &Client{
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan *Message, 256),
-		RoomID: roomID,
-		UserID: userID,
+		hub:      hub,
+		conn:     conn,
+		send:     make(chan *Message, hub.sendBufferSize),
+		done:     make(chan struct{}),
+		RoomID:   roomID,
+		UserID:   userID,
+		Protocol: DefaultProtocol,
 	}
 }
 
@@ -93,6 +99,7 @@ func (c *Client) WritePump() {
 		if err := c.conn.Close(); err != nil {
 			log.Printf("Error closing connection: %v", err)
 		}
+		close(c.done)
 	}()
 
 	for {
@@ -105,7 +112,11 @@ func (c *Client) WritePump() {
 
 			if !ok {
 				// Hub closed the channel
-				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+				closeMessage := []byte{}
+				if c.hub.shuttingDown() {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				}
+				if err := c.conn.WriteMessage(websocket.CloseMessage, closeMessage); err != nil {
 					log.Printf("Error writing close message: %v", err)
 				}
 				return
@@ -116,7 +127,7 @@ func (c *Client) WritePump() {
 				return
 			}
 
-			data, err := json.Marshal(message)
+			data, err := encodeMessage(c.Protocol, message)
 			if err != nil {
 				log.Printf("Error marshaling message: %v", err)
 				continue
@@ -136,7 +147,7 @@ func (c *Client) WritePump() {
 				}
 
 				msg := <-c.send
-				data, err := json.Marshal(msg)
+				data, err := encodeMessage(c.Protocol, msg)
 				if err != nil {
 					log.Printf("Error marshaling queued message: %v", err)
 					continue
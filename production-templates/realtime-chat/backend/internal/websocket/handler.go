@@ -4,9 +4,12 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+
+	"realtime-chat/internal/domain"
 )
 
 var upgrader = websocket.Upgrader{
@@ -17,13 +20,20 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// ParticipantFinder looks up a user's active membership of a room
+type ParticipantFinder interface {
+	FindParticipant(roomID, userID uint) (*domain.Participant, error)
+}
+
 type WebSocketHandler struct {
-	hub *Hub
+	hub          *Hub
+	participants ParticipantFinder
 }
 
-func NewWebSocketHandler(hub *Hub) *WebSocketHandler {
+func NewWebSocketHandler(hub *Hub, participants ParticipantFinder) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub: hub,
+		hub:          hub,
+		participants: participants,
 	}
 }
 
@@ -43,13 +53,33 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	requested := websocket.Subprotocols(c.Request)
+
+	// Only participants may subscribe to a room's events
+	if _, err := h.participants.FindParticipant(uint(roomID), userID.(uint)); err != nil {
+		rejectConnection(c, requested, websocket.ClosePolicyViolation, "not a participant of this room")
+		return
+	}
+
+	protocol, ok := negotiateProtocol(requested)
+	if !ok {
+		rejectConnection(c, requested, CloseUnsupportedProtocol, unsupportedProtocolReason(requested))
+		return
+	}
+
+	var responseHeader http.Header
+	if len(requested) > 0 {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {protocol}}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
 
 	client := NewClient(h.hub, conn, uint(roomID), userID.(uint))
+	client.Protocol = protocol
 	h.hub.Register(client)
 
 	// Start goroutines for reading and writing
@@ -57,6 +87,29 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	go client.ReadPump()
 }
 
+// rejectConnection completes the handshake only to close the connection with
+// the given code and reason, which browsers don't expose for a failed
+// handshake. Browsers also fail a handshake whose subprotocol they didn't
+// request, so the client's first choice is echoed back.
+func rejectConnection(c *gin.Context, requested []string, code int, reason string) {
+	var responseHeader http.Header
+	if len(requested) > 0 {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {requested[0]}}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, responseHeader)
+	if err != nil {
+		log.Printf("Failed to upgrade connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	closeMessage := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait)); err != nil {
+		log.Printf("Error writing close message: %v", err)
+	}
+}
+
 // GetOnlineUsers returns online users in a room
 func (h *WebSocketHandler) GetOnlineUsers(c *gin.Context) {
 	roomIDStr := c.Param("roomId")
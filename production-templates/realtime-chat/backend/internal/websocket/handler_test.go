@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"realtime-chat/internal/domain"
+)
+
+// participants maps room IDs to the users taking part in them
+type participants map[uint][]uint
+
+func (p participants) FindParticipant(roomID, userID uint) (*domain.Participant, error) {
+	for _, id := range p[roomID] {
+		if id == userID {
+			return &domain.Participant{RoomID: roomID, UserID: userID}, nil
+		}
+	}
+	return nil, errors.New("participant not found")
+}
+
+// startRoomServer serves HandleConnection for userID on /ws/:roomId
+func startRoomServer(t *testing.T, hub *Hub, userID uint) string {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewWebSocketHandler(hub, participants{1: {7, 8}})
+	router.GET("/ws/:roomId", func(c *gin.Context) {
+		c.Set("userID", userID)
+		handler.HandleConnection(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/"
+}
+
+func TestHandleConnection_Participant(t *testing.T) {
+	hub := NewHub(HubConfig{})
+	go hub.Run()
+	url := startRoomServer(t, hub, 8)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"1", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	if client := registeredClient(t, hub, 1); client.UserID != 8 {
+		t.Errorf("registered user = %d, want 8", client.UserID)
+	}
+}
+
+func TestHandleConnection_RejectsNonParticipant(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		roomID string
+	}{
+		{name: "outsider", userID: 9, roomID: "1"},
+		{name: "participant of another room", userID: 7, roomID: "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(HubConfig{})
+			go hub.Run()
+			url := startRoomServer(t, hub, tt.userID)
+
+			conn, _, err := websocket.DefaultDialer.Dial(url+tt.roomID, nil)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _, err = conn.ReadMessage()
+
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+			}
+			if closeErr.Code != websocket.ClosePolicyViolation {
+				t.Errorf("close code = %d, want %d", closeErr.Code, websocket.ClosePolicyViolation)
+			}
+			if count := hub.GetClientCount(); count != 0 {
+				t.Errorf("GetClientCount() = %d, want 0 after a rejected handshake", count)
+			}
+		})
+	}
+}
@@ -1,95 +1,469 @@
 package websocket
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"runtime"
 	"sync"
+	"time"
 )
 
-// Hub maintains active WebSocket connections and broadcasts messages
-type Hub struct {
+// OverflowPolicy decides what happens when a client's send buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropClient disconnects clients that cannot keep up
+	OverflowDropClient OverflowPolicy = "drop-client"
+
+	// OverflowDropOldest discards the oldest queued message to make room
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+)
+
+const (
+	defaultSendBufferSize = 256
+	defaultTypingTimeout  = 5 * time.Second
+
+	// How long Broadcast waits for the backend to accept a message
+	publishTimeout = 2 * time.Second
+
+	// How long a presence count update may take
+	presenceTimeout = 2 * time.Second
+)
+
+// HubConfig tunes per-client buffering, typing expiry, sharding and how
+// messages and presence reach other server instances. Zero values fall back
+// to a 256-message buffer, the drop-client policy, a 5s typing timeout, one
+// shard per CPU and an in-memory backend and presence counter.
+type HubConfig struct {
+	SendBufferSize int
+	OverflowPolicy OverflowPolicy
+	TypingTimeout  time.Duration
+	Shards         int
+	Backend        HubBackend
+	Presence       PresenceCounter
+}
+
+// typingKey identifies one user typing in one room
+type typingKey struct {
+	roomID uint
+	userID uint
+}
+
+// typingState is a pending auto-stop and the stop message it sends
+type typingState struct {
+	timer *time.Timer
+	stop  *Message
+}
+
+// StatusUpdater is notified when a user's first connection registers
+// (online = true) and when their last connection unregisters (online = false),
+// counting the connections of every instance sharing the presence counter.
+type StatusUpdater func(userID uint, online bool)
+
+// connChange is a change to a user's connection count on this instance that
+// has yet to be applied to the presence counter
+type connChange struct {
+	userID uint
+	delta  int
+}
+
+// shard owns a subset of the rooms, picked by room ID, and delivers their
+// messages on its own goroutine so busy rooms don't hold up the others
+type shard struct {
 	// Registered clients organized by room ID
 	rooms map[uint]map[*Client]bool
 
-	// Inbound messages from clients
+	// Set once the hub has stopped; later registrations are refused
+	closed bool
+
+	// Messages waiting to be delivered to the shard's rooms
 	broadcast chan *Message
 
-	// Register requests from clients
-	register chan *Client
+	// Guards rooms and closed. Deliveries hold the read lock, so clients are
+	// only added and removed between them.
+	mu sync.RWMutex
+}
 
-	// Unregister requests from clients
-	unregister chan *Client
+// Hub maintains active WebSocket connections and broadcasts messages
+type Hub struct {
+	// Rooms are spread across shards by room ID
+	shards []*shard
 
-	// Mutex for thread-safe operations
-	mu sync.RWMutex
+	// Number of open connections per user on this instance, across all rooms
+	userConns map[uint]int
+
+	// Connection changes waiting for the presence goroutine, in the order
+	// they happened, and a signal that there are some
+	connChanges []connChange
+	connReady   chan struct{}
+
+	// Guards userConns and connChanges. Taken inside a shard's lock when
+	// both are needed.
+	usersMu sync.Mutex
+
+	// Counts connections across instances to find online/offline transitions
+	presence PresenceCounter
+
+	// Called on online/offline transitions; may be nil
+	statusUpdater StatusUpdater
+
+	// Capacity of each client's send channel
+	sendBufferSize int
+
+	// What to do when a client's send channel is full
+	overflowPolicy OverflowPolicy
+
+	// How long a typing-start lasts without a follow-up
+	typingTimeout time.Duration
+
+	// Relays messages to and from the hubs of other server instances
+	backend HubBackend
+
+	// Identifies this hub's own messages when the backend relays them back
+	instanceID string
+
+	// Pending auto-stop timers of users currently typing
+	typing   map[typingKey]*typingState
+	typingMu sync.Mutex
+
+	// quit asks Run to stop; stopped is closed once it has, after which
+	// closed lists the clients it disconnected
+	quit     chan struct{}
+	quitOnce sync.Once
+	stopped  chan struct{}
+	closed   []*Client
 }
 
-func NewHub() *Hub {
+func NewHub(config HubConfig) *Hub {
+	if config.SendBufferSize <= 0 {
+		config.SendBufferSize = defaultSendBufferSize
+	}
+
+	switch config.OverflowPolicy {
+	case OverflowDropClient, OverflowDropOldest:
+	case "":
+		config.OverflowPolicy = OverflowDropClient
+	default:
+		log.Printf("Unknown overflow policy %q, using %q", config.OverflowPolicy, OverflowDropClient)
+		config.OverflowPolicy = OverflowDropClient
+	}
+
+	if config.TypingTimeout <= 0 {
+		config.TypingTimeout = defaultTypingTimeout
+	}
+
+	if config.Shards <= 0 {
+		config.Shards = runtime.GOMAXPROCS(0)
+	}
+
+	if config.Backend == nil {
+		config.Backend = NewMemoryBackend()
+	}
+
+	if config.Presence == nil {
+		config.Presence = NewMemoryPresence()
+	}
+
+	shards := make([]*shard, config.Shards)
+	for i := range shards {
+		shards[i] = &shard{
+			rooms:     make(map[uint]map[*Client]bool),
+			broadcast: make(chan *Message, 256),
+		}
+	}
+
 	return &Hub{
-		rooms:      make(map[uint]map[*Client]bool),
-		broadcast:  make(chan *Message, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		shards:         shards,
+		userConns:      make(map[uint]int),
+		connReady:      make(chan struct{}, 1),
+		presence:       config.Presence,
+		sendBufferSize: config.SendBufferSize,
+		overflowPolicy: config.OverflowPolicy,
+		typingTimeout:  config.TypingTimeout,
+		backend:        config.Backend,
+		instanceID:     newInstanceID(),
+		typing:         make(map[typingKey]*typingState),
+		quit:           make(chan struct{}),
+		stopped:        make(chan struct{}),
+	}
+}
+
+// newInstanceID returns a random ID for a hub
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
 	}
+	return hex.EncodeToString(b)
 }
 
+// Run delivers messages on one goroutine per shard, and reports presence
+// transitions on another, until Shutdown is called. Messages from other
+// instances are delivered as long as it runs.
 func (h *Hub) Run() {
+	unsubscribe, err := h.backend.Subscribe(h.receive)
+	if err != nil {
+		log.Printf("Failed to subscribe to hub backend, only local clients will receive messages: %v", err)
+		unsubscribe = func() {}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.runPresence()
+	}()
+
+	for _, s := range h.shards {
+		wg.Add(1)
+		go func(s *shard) {
+			defer wg.Done()
+			h.runShard(s)
+		}(s)
+	}
+	wg.Wait()
+	unsubscribe()
+
+	h.closed = h.closeAll()
+	close(h.stopped)
+}
+
+func (h *Hub) runShard(s *shard) {
 	for {
 		select {
-		case client := <-h.register:
-			h.registerClient(client)
+		case message := <-s.broadcast:
+			h.deliver(s, message)
 
-		case client := <-h.unregister:
-			h.unregisterClient(client)
+		case <-h.quit:
+			return
+		}
+	}
+}
+
+// runPresence applies connection changes to the presence counter as they are
+// queued, outside the hub's locks
+func (h *Hub) runPresence() {
+	for {
+		select {
+		case <-h.connReady:
+			h.applyConnChanges()
 
-		case message := <-h.broadcast:
-			h.broadcastMessage(message)
+		case <-h.quit:
+			return
 		}
 	}
 }
 
-func (h *Hub) registerClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// queueConnChange records a change to the user's connection count for the
+// presence goroutine. The caller must hold usersMu.
+func (h *Hub) queueConnChange(userID uint, delta int) {
+	h.connChanges = append(h.connChanges, connChange{userID: userID, delta: delta})
+	select {
+	case h.connReady <- struct{}{}:
+	default:
+	}
+}
+
+// applyConnChanges applies the queued connection changes to the presence
+// counter in order and reports the users that came online or went offline
+func (h *Hub) applyConnChanges() {
+	h.usersMu.Lock()
+	changes := h.connChanges
+	h.connChanges = nil
+	h.usersMu.Unlock()
+
+	for _, change := range changes {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+		count, err := h.presence.Add(ctx, change.userID, change.delta)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to update presence for user %d: %v", change.userID, err)
+			continue
+		}
 
-	if h.rooms[client.RoomID] == nil {
-		h.rooms[client.RoomID] = make(map[*Client]bool)
+		if h.statusUpdater == nil {
+			continue
+		}
+		if change.delta > 0 && count == change.delta {
+			h.statusUpdater(change.userID, true)
+		} else if change.delta < 0 && count == 0 {
+			h.statusUpdater(change.userID, false)
+		}
 	}
+}
 
-	h.rooms[client.RoomID][client] = true
-	log.Printf("Client registered: UserID=%d, RoomID=%d, Total in room=%d",
-		client.UserID, client.RoomID, len(h.rooms[client.RoomID]))
+// shardFor returns the shard owning the room
+func (h *Hub) shardFor(roomID uint) *shard {
+	return h.shards[roomID%uint(len(h.shards))]
 }
 
-func (h *Hub) unregisterClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// Shutdown stops Run and disconnects every client with a going-away close
+// frame, then waits for their write pumps to finish. It returns ctx.Err() if
+// ctx expires first. Run must have been started.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.quitOnce.Do(func() { close(h.quit) })
 
-	if clients, ok := h.rooms[client.RoomID]; ok {
-		if _, exists := clients[client]; exists {
-			delete(clients, client)
-			close(client.send)
+	select {
+	case <-h.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 
-			// Remove room if no clients left
-			if len(clients) == 0 {
-				delete(h.rooms, client.RoomID)
-			}
+	for _, client := range h.closed {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// closeAll closes the send channel of every registered client, which makes
+// its write pump send a close frame, cancels pending typing timers and
+// removes this instance's connections from the presence counter, reporting
+// users offline whose last connection that was. It returns the closed
+// clients.
+func (h *Hub) closeAll() []*Client {
+	h.typingMu.Lock()
+	for key, state := range h.typing {
+		state.timer.Stop()
+		delete(h.typing, key)
+	}
+	h.typingMu.Unlock()
 
-			log.Printf("Client unregistered: UserID=%d, RoomID=%d, Remaining in room=%d",
-				client.UserID, client.RoomID, len(clients))
+	var clients []*Client
+	for _, s := range h.shards {
+		s.mu.Lock()
+		s.closed = true
+		for roomID, roomClients := range s.rooms {
+			for client := range roomClients {
+				close(client.send)
+				clients = append(clients, client)
+			}
+			delete(s.rooms, roomID)
 		}
+		s.mu.Unlock()
+	}
+
+	h.usersMu.Lock()
+	for userID, conns := range h.userConns {
+		delete(h.userConns, userID)
+		h.queueConnChange(userID, -conns)
+	}
+	h.usersMu.Unlock()
+	h.applyConnChanges()
+
+	log.Printf("Hub stopped, disconnected %d client(s)", len(clients))
+	return clients
+}
+
+// shuttingDown reports whether Shutdown has been called
+func (h *Hub) shuttingDown() bool {
+	select {
+	case <-h.quit:
+		return true
+	default:
+		return false
 	}
 }
 
-func (h *Hub) broadcastMessage(message *Message) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// SetStatusUpdater installs the callback invoked on presence transitions.
+// It must be called before Run.
+func (h *Hub) SetStatusUpdater(updater StatusUpdater) {
+	h.statusUpdater = updater
+}
+
+// registerClient adds the client to its room's shard and reports whether it
+// was added. Clients of a stopped hub are refused.
+func (h *Hub) registerClient(client *Client) bool {
+	s := h.shardFor(client.RoomID)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	if s.rooms[client.RoomID] == nil {
+		s.rooms[client.RoomID] = make(map[*Client]bool)
+	}
+	s.rooms[client.RoomID][client] = true
+	inRoom := len(s.rooms[client.RoomID])
+
+	// Counted under the shard lock, so closeAll either sees this
+	// connection or refuses it, never a connection it then cannot remove
+	h.usersMu.Lock()
+	h.userConns[client.UserID]++
+	h.queueConnChange(client.UserID, 1)
+	h.usersMu.Unlock()
+	s.mu.Unlock()
 
-	clients, ok := h.rooms[message.RoomID]
+	log.Printf("Client registered: UserID=%d, RoomID=%d, Total in room=%d",
+		client.UserID, client.RoomID, inRoom)
+	return true
+}
+
+func (h *Hub) unregisterClient(client *Client) {
+	s := h.shardFor(client.RoomID)
+	s.mu.Lock()
+	clients, ok := s.rooms[client.RoomID]
+	if ok {
+		_, ok = clients[client]
+	}
 	if !ok {
+		s.mu.Unlock()
 		return
 	}
 
-	for client := range clients {
+	delete(clients, client)
+	close(client.send)
+
+	// Remove room if no clients left
+	if len(clients) == 0 {
+		delete(s.rooms, client.RoomID)
+	}
+	remaining := len(clients)
+	stillInRoom := false
+	for other := range clients {
+		if other.UserID == client.UserID {
+			stillInRoom = true
+			break
+		}
+	}
+
+	// Counted under the shard lock like in registerClient
+	h.usersMu.Lock()
+	h.userConns[client.UserID]--
+	if h.userConns[client.UserID] <= 0 {
+		delete(h.userConns, client.UserID)
+	}
+	h.queueConnChange(client.UserID, -1)
+	h.usersMu.Unlock()
+	s.mu.Unlock()
+
+	log.Printf("Client unregistered: UserID=%d, RoomID=%d, Remaining in room=%d",
+		client.UserID, client.RoomID, remaining)
+
+	// A user who left mid-typing stops typing now rather than at the timeout.
+	// A shard dropping a slow client gets here on its own goroutine, and
+	// would deadlock waiting for room in its own full broadcast channel, so
+	// the stop is sent from another goroutine.
+	if !stillInRoom {
+		if stop := h.clearTyping(typingKey{roomID: client.RoomID, userID: client.UserID}); stop != nil {
+			stop.Timestamp = time.Now()
+			go h.Broadcast(stop)
+		}
+	}
+}
+
+// deliver hands a message to its recipients within the shard
+func (h *Hub) deliver(s *shard, message *Message) {
+	var overflowed []*Client
+
+	s.mu.RLock()
+	for client := range s.recipients(message) {
 		// Don't send typing indicators back to the sender
 		if message.Type == MessageTypeTyping && client.UserID == message.UserID {
 			continue
@@ -97,36 +471,226 @@ func (h *Hub) broadcastMessage(message *Message) {
 
 		select {
 		case client.send <- message:
+			continue
 		default:
-			// Client's send channel is full, close it
-			close(client.send)
-			delete(clients, client)
 		}
+
+		// Client's send channel is full
+		if h.overflowPolicy == OverflowDropOldest {
+			h.sendDropOldest(client, message)
+		} else {
+			overflowed = append(overflowed, client)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, client := range overflowed {
+		h.unregisterClient(client)
 	}
 }
 
-// Broadcast sends a message to all clients in a room
+// recipients returns the shard's clients a message goes to: everyone in its
+// room, or every connection of its targeted users. The caller must hold s.mu.
+func (s *shard) recipients(message *Message) map[*Client]bool {
+	if message.recipients == nil {
+		return s.rooms[message.RoomID]
+	}
+
+	clients := make(map[*Client]bool)
+	for _, roomClients := range s.rooms {
+		for client := range roomClients {
+			if message.recipients[client.UserID] {
+				clients[client] = true
+			}
+		}
+	}
+	return clients
+}
+
+// sendDropOldest discards queued messages until message fits. The shard's
+// goroutine is the only sender on client.send and channels are only closed
+// under the shard's write lock, so the loop ends as soon as one slot frees
+// up; a concurrent WritePump receive only frees it sooner.
+func (h *Hub) sendDropOldest(client *Client, message *Message) {
+	dropped := 0
+	for {
+		select {
+		case client.send <- message:
+			if dropped > 0 {
+				log.Printf("Dropped %d queued message(s) for slow client: UserID=%d, RoomID=%d",
+					dropped, client.UserID, client.RoomID)
+			}
+			return
+		default:
+		}
+
+		select {
+		case <-client.send:
+			dropped++
+		default:
+		}
+	}
+}
+
+// Broadcast sends a message to all clients in a room, on this and every
+// other server instance. Messages sent after the hub has stopped only reach
+// the other instances.
 func (h *Hub) Broadcast(message *Message) {
-	h.broadcast <- message
+	h.publish(&Envelope{Origin: h.instanceID, Message: message})
+	h.dispatch(message)
+}
+
+// SendToUsers delivers a message to every connection of the given users,
+// whichever room and server instance they are connected to
+func (h *Hub) SendToUsers(message *Message, userIDs []uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	message.recipients = make(map[uint]bool, len(userIDs))
+	for _, userID := range userIDs {
+		message.recipients[userID] = true
+	}
+	h.publish(&Envelope{Origin: h.instanceID, Message: message, Recipients: userIDs})
+	h.dispatch(message)
 }
 
-// Register adds a client to the hub
+// publish hands a message to the backend for the other instances
+func (h *Hub) publish(envelope *Envelope) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := h.backend.Publish(ctx, envelope); err != nil {
+		log.Printf("Failed to publish %s message for room %d: %v", envelope.Message.Type, envelope.Message.RoomID, err)
+	}
+}
+
+// receive delivers a message relayed by the backend to local clients. The
+// hub's own messages were delivered when they were sent and are skipped.
+func (h *Hub) receive(envelope *Envelope) {
+	if envelope.Origin == h.instanceID {
+		return
+	}
+
+	// The backend may hand the same envelope to other hubs
+	message := *envelope.Message
+	if len(envelope.Recipients) > 0 {
+		message.recipients = make(map[uint]bool, len(envelope.Recipients))
+		for _, userID := range envelope.Recipients {
+			message.recipients[userID] = true
+		}
+	}
+	h.dispatch(&message)
+}
+
+// dispatch queues a message on the shard of its room, or on every shard when
+// it targets users. Messages are dropped once the hub has stopped.
+func (h *Hub) dispatch(message *Message) {
+	if message.recipients == nil {
+		select {
+		case h.shardFor(message.RoomID).broadcast <- message:
+		case <-h.stopped:
+		}
+		return
+	}
+
+	for _, s := range h.shards {
+		select {
+		case s.broadcast <- message:
+		case <-h.stopped:
+			return
+		}
+	}
+}
+
+// StartTyping broadcasts start and arms a timer that broadcasts stop unless
+// the same user starts or stops typing in the room again before the typing
+// timeout, so clients never see a typing indicator stuck on.
+func (h *Hub) StartTyping(start, stop *Message) {
+	key := typingKey{roomID: start.RoomID, userID: start.UserID}
+
+	h.typingMu.Lock()
+	if state, ok := h.typing[key]; ok {
+		state.timer.Stop()
+	}
+	state := &typingState{stop: stop}
+	state.timer = time.AfterFunc(h.typingTimeout, func() {
+		h.typingMu.Lock()
+		expired := h.typing[key] == state
+		if expired {
+			delete(h.typing, key)
+		}
+		h.typingMu.Unlock()
+
+		if expired {
+			stop.Timestamp = time.Now()
+			h.Broadcast(stop)
+		}
+	})
+	h.typing[key] = state
+	h.typingMu.Unlock()
+
+	h.Broadcast(start)
+}
+
+// StopTyping cancels the pending auto-stop and broadcasts stop
+func (h *Hub) StopTyping(stop *Message) {
+	h.clearTyping(typingKey{roomID: stop.RoomID, userID: stop.UserID})
+	h.Broadcast(stop)
+}
+
+// clearTyping cancels the pending auto-stop for key and returns the stop
+// message it would have sent, or nil if the user was not typing
+func (h *Hub) clearTyping(key typingKey) *Message {
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	state, ok := h.typing[key]
+	if !ok {
+		return nil
+	}
+	state.timer.Stop()
+	delete(h.typing, key)
+	return state.stop
+}
+
+// GetTypingUsers returns the IDs of users currently typing in a room
+func (h *Hub) GetTypingUsers(roomID uint) []uint {
+	h.typingMu.Lock()
+	defer h.typingMu.Unlock()
+
+	result := make([]uint, 0)
+	for key := range h.typing {
+		if key.roomID == roomID {
+			result = append(result, key.userID)
+		}
+	}
+
+	return result
+}
+
+// Register adds a client to the hub. Once the hub has stopped the client is
+// disconnected instead.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	if !h.registerClient(client) {
+		close(client.send)
+	}
 }
 
-// Unregister removes a client from the hub
+// Unregister removes a client from the hub. Unregistering a client twice is
+// harmless.
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	h.unregisterClient(client)
 }
 
 // GetOnlineUsers returns a list of online user IDs in a room
 func (h *Hub) GetOnlineUsers(roomID uint) []uint {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	s := h.shardFor(roomID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	userIDs := make(map[uint]bool)
-	if clients, ok := h.rooms[roomID]; ok {
+	if clients, ok := s.rooms[roomID]; ok {
 		for client := range clients {
 			userIDs[client.UserID] = true
 		}
@@ -142,19 +706,24 @@ func (h *Hub) GetOnlineUsers(roomID uint) []uint {
 
 // GetRoomCount returns the number of active rooms
 func (h *Hub) GetRoomCount() int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return len(h.rooms)
+	count := 0
+	for _, s := range h.shards {
+		s.mu.RLock()
+		count += len(s.rooms)
+		s.mu.RUnlock()
+	}
+	return count
 }
 
 // GetClientCount returns the total number of connected clients
 func (h *Hub) GetClientCount() int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
 	count := 0
-	for _, clients := range h.rooms {
-		count += len(clients)
+	for _, s := range h.shards {
+		s.mu.RLock()
+		for _, clients := range s.rooms {
+			count += len(clients)
+		}
+		s.mu.RUnlock()
 	}
 	return count
 }
@@ -0,0 +1,577 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type statusChange struct {
+	userID uint
+	online bool
+}
+
+// startTestHub runs a hub whose status transitions are recorded on the
+// returned channel.
+func startTestHub(t *testing.T) (*Hub, chan statusChange) {
+	t.Helper()
+
+	changes := make(chan statusChange, 16)
+	hub := NewHub(HubConfig{})
+	hub.SetStatusUpdater(func(userID uint, online bool) {
+		changes <- statusChange{userID: userID, online: online}
+	})
+	go hub.Run()
+
+	return hub, changes
+}
+
+func expectStatus(t *testing.T, changes chan statusChange, want statusChange) {
+	t.Helper()
+
+	select {
+	case got := <-changes:
+		if got != want {
+			t.Errorf("status change = %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for status change %+v", want)
+	}
+}
+
+func expectNoStatus(t *testing.T, changes chan statusChange) {
+	t.Helper()
+
+	select {
+	case got := <-changes:
+		t.Errorf("unexpected status change %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_StatusUpdaterTransitions(t *testing.T) {
+	hub, changes := startTestHub(t)
+
+	first := NewClient(hub, nil, 1, 7)
+	second := NewClient(hub, nil, 2, 7)
+
+	hub.Register(first)
+	expectStatus(t, changes, statusChange{userID: 7, online: true})
+
+	// A second connection for the same user is not a transition
+	hub.Register(second)
+	expectNoStatus(t, changes)
+
+	hub.Unregister(first)
+	expectNoStatus(t, changes)
+
+	hub.Unregister(second)
+	expectStatus(t, changes, statusChange{userID: 7, online: false})
+
+	if _, ok := <-first.send; ok {
+		t.Error("send channel should be closed after unregister")
+	}
+}
+
+func TestHub_StatusUpdaterPerUser(t *testing.T) {
+	hub, changes := startTestHub(t)
+
+	alice := NewClient(hub, nil, 1, 1)
+	bob := NewClient(hub, nil, 1, 2)
+
+	hub.Register(alice)
+	expectStatus(t, changes, statusChange{userID: 1, online: true})
+
+	hub.Register(bob)
+	expectStatus(t, changes, statusChange{userID: 2, online: true})
+
+	hub.Unregister(alice)
+	expectStatus(t, changes, statusChange{userID: 1, online: false})
+
+	// Unregistering a client twice must not report another transition
+	hub.Unregister(alice)
+	expectNoStatus(t, changes)
+
+	if got := hub.GetOnlineUsers(1); len(got) != 1 || got[0] != 2 {
+		t.Errorf("GetOnlineUsers() = %v, want [2]", got)
+	}
+}
+
+func TestHub_StatusUpdaterAcrossInstances(t *testing.T) {
+	// Two instances share a presence counter, as they would through Redis
+	presence := NewMemoryPresence()
+	changes := make(chan statusChange, 16)
+	record := func(userID uint, online bool) {
+		changes <- statusChange{userID: userID, online: online}
+	}
+	hubs := make([]*Hub, 2)
+	for i := range hubs {
+		hubs[i] = NewHub(HubConfig{Presence: presence})
+		hubs[i].SetStatusUpdater(record)
+		go hubs[i].Run()
+	}
+
+	first := NewClient(hubs[0], nil, 1, 7)
+	second := NewClient(hubs[1], nil, 2, 7)
+
+	hubs[0].Register(first)
+	expectStatus(t, changes, statusChange{userID: 7, online: true})
+	hubs[1].Register(second)
+	expectNoStatus(t, changes)
+
+	// Still connected to the other instance
+	hubs[0].Unregister(first)
+	expectNoStatus(t, changes)
+
+	hubs[1].Unregister(second)
+	expectStatus(t, changes, statusChange{userID: 7, online: false})
+}
+
+func TestHub_StatusUpdaterRunsOutsideLock(t *testing.T) {
+	release := make(chan struct{})
+	called := make(chan uint, 4)
+	hub := NewHub(HubConfig{})
+	hub.SetStatusUpdater(func(userID uint, online bool) {
+		called <- userID
+		<-release
+	})
+	go hub.Run()
+	defer close(release)
+
+	hub.Register(NewClient(hub, nil, 1, 1))
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("status updater was not called")
+	}
+
+	// The updater is stuck, e.g. on a slow database, but other users can
+	// still connect and disconnect
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bob := NewClient(hub, nil, 1, 2)
+		hub.Register(bob)
+		hub.Unregister(bob)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Register() blocked behind the status updater")
+	}
+}
+
+// drain delivers the messages queued on a hub that is not running, standing
+// in for the shard goroutines of Run
+func drain(hub *Hub) {
+	for _, s := range hub.shards {
+		for len(s.broadcast) > 0 {
+			hub.deliver(s, <-s.broadcast)
+		}
+	}
+}
+
+// saturate registers a client on a hub that is not running and broadcasts
+// count messages to its room without draining the send channel.
+func saturate(t *testing.T, policy OverflowPolicy, count int) (*Hub, *Client) {
+	t.Helper()
+
+	hub := NewHub(HubConfig{SendBufferSize: 3, OverflowPolicy: policy})
+	client := NewClient(hub, nil, 1, 1)
+	hub.registerClient(client)
+
+	for i := 1; i <= count; i++ {
+		hub.Broadcast(NewMessage(MessageTypeNewMessage, 1, 2, i))
+	}
+	drain(hub)
+
+	return hub, client
+}
+
+func TestHub_OverflowDropClient(t *testing.T) {
+	hub, client := saturate(t, OverflowDropClient, 5)
+
+	if got := hub.GetClientCount(); got != 0 {
+		t.Errorf("GetClientCount() = %d, want 0 after overflow", got)
+	}
+
+	// The buffered messages are still delivered before the close
+	for want := 1; want <= 3; want++ {
+		msg, ok := <-client.send
+		if !ok || msg.Data != want {
+			t.Fatalf("received %v (open=%v), want message %d", msg, ok, want)
+		}
+	}
+	if _, ok := <-client.send; ok {
+		t.Error("send channel should be closed after overflow")
+	}
+}
+
+func TestHub_OverflowDropClientSaturatedShard(t *testing.T) {
+	hub := NewHub(HubConfig{SendBufferSize: 1, Shards: 1})
+	s := hub.shards[0]
+
+	// The client is typing and has a full send buffer
+	client := NewClient(hub, nil, 1, 1)
+	hub.registerClient(client)
+	hub.StartTyping(
+		&Message{Type: MessageTypeTyping, RoomID: 1, UserID: 1, Data: true},
+		&Message{Type: MessageTypeTyping, RoomID: 1, UserID: 1, Data: false},
+	)
+	client.send <- NewMessage(MessageTypeNewMessage, 1, 2, "queued")
+
+	// and the shard's own broadcast channel is full too
+	for len(s.broadcast) < cap(s.broadcast) {
+		s.broadcast <- NewMessage(MessageTypeNewMessage, 2, 2, "backlog")
+	}
+
+	// Dropping the client on the shard's goroutine sends a typing stop,
+	// which must not wait for the shard to make room
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.deliver(s, NewMessage(MessageTypeNewMessage, 1, 2, "overflow"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deliver() deadlocked on the shard's own broadcast channel")
+	}
+
+	if count := hub.GetClientCount(); count != 0 {
+		t.Errorf("GetClientCount() = %d, want the slow client dropped", count)
+	}
+
+	go hub.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
+
+func TestHub_OverflowDropOldest(t *testing.T) {
+	hub, client := saturate(t, OverflowDropOldest, 10)
+
+	if got := hub.GetClientCount(); got != 1 {
+		t.Fatalf("GetClientCount() = %d, want 1", got)
+	}
+	if got := len(client.send); got != 3 {
+		t.Fatalf("queued messages = %d, want 3", got)
+	}
+
+	for want := 8; want <= 10; want++ {
+		if msg := <-client.send; msg.Data != want {
+			t.Errorf("received message %v, want %d", msg.Data, want)
+		}
+	}
+}
+
+func TestHub_OverflowDropOldestConcurrentReader(t *testing.T) {
+	hub := NewHub(HubConfig{SendBufferSize: 1, OverflowPolicy: OverflowDropOldest})
+	client := NewClient(hub, nil, 1, 1)
+	hub.registerClient(client)
+
+	done := make(chan int)
+	go func() {
+		last := 0
+		for msg := range client.send {
+			n := msg.Data.(int)
+			if n <= last {
+				t.Errorf("message %d received after %d", n, last)
+			}
+			last = n
+		}
+		done <- last
+	}()
+
+	const total = 1000
+	for i := 1; i <= total; i++ {
+		hub.Broadcast(NewMessage(MessageTypeNewMessage, 1, 2, i))
+		drain(hub)
+	}
+	hub.unregisterClient(client)
+
+	select {
+	case last := <-done:
+		if last != total {
+			t.Errorf("last message = %d, want %d", last, total)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reader did not finish")
+	}
+}
+
+func expectTyping(t *testing.T, client *Client, want bool) {
+	t.Helper()
+
+	select {
+	case msg := <-client.send:
+		if msg.Type != MessageTypeTyping || msg.Data != want {
+			t.Errorf("received %s %v, want typing %v", msg.Type, msg.Data, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for typing %v", want)
+	}
+}
+
+func TestHub_TypingAutoStop(t *testing.T) {
+	hub := NewHub(HubConfig{TypingTimeout: 50 * time.Millisecond})
+	go hub.Run()
+
+	listener := NewClient(hub, nil, 1, 2)
+	hub.Register(listener)
+
+	hub.StartTyping(NewMessage(MessageTypeTyping, 1, 1, true), NewMessage(MessageTypeTyping, 1, 1, false))
+	expectTyping(t, listener, true)
+
+	if got := hub.GetTypingUsers(1); len(got) != 1 || got[0] != 1 {
+		t.Errorf("GetTypingUsers() = %v, want [1]", got)
+	}
+	if got := hub.GetTypingUsers(2); len(got) != 0 {
+		t.Errorf("GetTypingUsers() for another room = %v, want none", got)
+	}
+
+	// No stop is sent, so the hub emits one once the timeout passes
+	expectTyping(t, listener, false)
+
+	if got := hub.GetTypingUsers(1); len(got) != 0 {
+		t.Errorf("GetTypingUsers() after expiry = %v, want none", got)
+	}
+}
+
+func TestHub_TypingExplicitStop(t *testing.T) {
+	hub := NewHub(HubConfig{TypingTimeout: 50 * time.Millisecond})
+	go hub.Run()
+
+	listener := NewClient(hub, nil, 1, 2)
+	hub.Register(listener)
+
+	hub.StartTyping(NewMessage(MessageTypeTyping, 1, 1, true), NewMessage(MessageTypeTyping, 1, 1, false))
+	expectTyping(t, listener, true)
+
+	hub.StopTyping(NewMessage(MessageTypeTyping, 1, 1, false))
+	expectTyping(t, listener, false)
+
+	// The cancelled timer must not emit a second stop
+	select {
+	case msg := <-listener.send:
+		t.Errorf("unexpected message after stop: %s %v", msg.Type, msg.Data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHub_TypingStopsOnDisconnect(t *testing.T) {
+	hub := NewHub(HubConfig{TypingTimeout: time.Minute})
+	go hub.Run()
+
+	listener := NewClient(hub, nil, 1, 2)
+	typist := NewClient(hub, nil, 1, 1)
+	otherTab := NewClient(hub, nil, 1, 1)
+	for _, client := range []*Client{listener, typist, otherTab} {
+		hub.Register(client)
+	}
+
+	hub.StartTyping(NewMessage(MessageTypeTyping, 1, 1, true), NewMessage(MessageTypeTyping, 1, 1, false))
+	expectTyping(t, listener, true)
+
+	// The user is still in the room on another connection
+	hub.Unregister(typist)
+	if got := hub.GetTypingUsers(1); len(got) != 1 {
+		t.Errorf("GetTypingUsers() = %v, want the user still typing", got)
+	}
+
+	// Their last connection going away stops the indicator long before the
+	// timeout
+	hub.Unregister(otherTab)
+	expectTyping(t, listener, false)
+	if got := hub.GetTypingUsers(1); len(got) != 0 {
+		t.Errorf("GetTypingUsers() after disconnecting = %v, want none", got)
+	}
+}
+
+func TestHub_Shutdown(t *testing.T) {
+	hub, changes := startTestHub(t)
+
+	var conns []*websocket.Conn
+	for _, userID := range []uint{7, 8} {
+		conn, _, err := websocket.DefaultDialer.Dial(startRoomServer(t, hub, userID)+"1", nil)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+		expectStatus(t, changes, statusChange{userID: userID, online: true})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if len(hub.closed) != len(conns) {
+		t.Fatalf("Shutdown() disconnected %d clients, want %d", len(hub.closed), len(conns))
+	}
+	for _, client := range hub.closed {
+		if _, ok := <-client.send; ok {
+			t.Error("client send channel is still open")
+		}
+	}
+	if count := hub.GetClientCount(); count != 0 {
+		t.Errorf("GetClientCount() = %d, want 0", count)
+	}
+
+	offline := map[uint]bool{}
+	for range conns {
+		select {
+		case change := <-changes:
+			if change.online {
+				t.Errorf("status change = %+v, want offline", change)
+			}
+			offline[change.userID] = true
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for users to go offline")
+		}
+	}
+	if !offline[7] || !offline[8] {
+		t.Errorf("users reported offline = %v, want 7 and 8", offline)
+	}
+
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+		}
+		if closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseGoingAway)
+		}
+	}
+
+	// A stopped hub neither blocks senders nor accepts new clients
+	hub.Broadcast(&Message{Type: MessageTypeTyping, RoomID: 1})
+	late := NewClient(hub, nil, 1, 9)
+	hub.Register(late)
+	if _, ok := <-late.send; ok {
+		t.Error("client registered after Shutdown was not disconnected")
+	}
+	expectNoStatus(t, changes)
+}
+
+func TestHub_ShardedRoomRouting(t *testing.T) {
+	hub := NewHub(HubConfig{Shards: 4})
+	go hub.Run()
+
+	// Rooms 1 and 5 share a shard, rooms 1 and 2 don't
+	clients := map[uint]*Client{}
+	for _, roomID := range []uint{1, 2, 5} {
+		clients[roomID] = NewClient(hub, nil, roomID, roomID)
+		hub.Register(clients[roomID])
+	}
+
+	if got := hub.GetRoomCount(); got != 3 {
+		t.Errorf("GetRoomCount() = %d, want 3", got)
+	}
+	if got := hub.GetClientCount(); got != 3 {
+		t.Errorf("GetClientCount() = %d, want 3", got)
+	}
+
+	for _, roomID := range []uint{1, 2, 5} {
+		hub.Broadcast(NewMessage(MessageTypeNewMessage, roomID, 99, roomID))
+	}
+
+	for roomID, client := range clients {
+		select {
+		case msg := <-client.send:
+			if msg.RoomID != roomID {
+				t.Errorf("client in room %d received a message for room %d", roomID, msg.RoomID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("client in room %d received nothing", roomID)
+		}
+		select {
+		case msg := <-client.send:
+			t.Errorf("client in room %d received an extra message for room %d", roomID, msg.RoomID)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	// Targeted messages reach their users whichever shard holds them
+	hub.SendToUsers(NewMessage(MessageTypeNewMessage, 0, 99, "ping"), []uint{2, 5})
+	for roomID, want := range map[uint]bool{1: false, 2: true, 5: true} {
+		select {
+		case <-clients[roomID].send:
+			if !want {
+				t.Errorf("user %d received a message meant for others", roomID)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if want {
+				t.Errorf("user %d did not receive the targeted message", roomID)
+			}
+		}
+	}
+
+	hub.Unregister(clients[1])
+	if got := hub.GetRoomCount(); got != 2 {
+		t.Errorf("GetRoomCount() = %d after unregister, want 2", got)
+	}
+}
+
+// BenchmarkHub_Broadcast measures delivery across many busy rooms with a
+// single shard, which behaves like the former single hub goroutine, and with
+// the default of one shard per CPU.
+func BenchmarkHub_Broadcast(b *testing.B) {
+	const rooms = 64
+	const clientsPerRoom = 8
+
+	for _, bench := range []struct {
+		name   string
+		shards int
+	}{
+		{name: "single", shards: 1},
+		{name: "sharded", shards: 0},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			hub := NewHub(HubConfig{Shards: bench.shards, SendBufferSize: 1024, OverflowPolicy: OverflowDropOldest})
+			go hub.Run()
+
+			// Each reader drains its client until the final message of its
+			// room, which drop-oldest never discards
+			var received sync.WaitGroup
+			for roomID := uint(1); roomID <= rooms; roomID++ {
+				for i := 0; i < clientsPerRoom; i++ {
+					client := NewClient(hub, nil, roomID, uint(i)+1)
+					hub.Register(client)
+					received.Add(1)
+					go func() {
+						defer received.Done()
+						for msg := range client.send {
+							if msg.Data == "done" {
+								return
+							}
+						}
+					}()
+				}
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				roomID := uint(0)
+				for pb.Next() {
+					roomID++
+					hub.Broadcast(NewMessage(MessageTypeNewMessage, roomID%rooms+1, 0, "hello"))
+				}
+			})
+			for roomID := uint(1); roomID <= rooms; roomID++ {
+				hub.Broadcast(NewMessage(MessageTypeNewMessage, roomID, 0, "done"))
+			}
+			received.Wait()
+		})
+	}
+}
@@ -10,6 +10,8 @@ const (
 	MessageTypeNewMessage    MessageType = "NEW_MESSAGE"
 	MessageTypeMessageEdited MessageType = "MESSAGE_EDITED"
 	MessageTypeMessageDeleted MessageType = "MESSAGE_DELETED"
+	MessageTypeMessagesBulkDeleted MessageType = "MESSAGES_BULK_DELETED"
+	MessageTypeThreadReply MessageType = "THREAD_REPLY" // Sent to the thread's participants
 
 	// Reaction events
 	MessageTypeReactionAdded   MessageType = "REACTION_ADDED"
@@ -19,15 +21,26 @@ const (
 	MessageTypeTyping MessageType = "TYPING"
 
 	// Read receipts
-	MessageTypeMessageRead MessageType = "MESSAGE_READ"
+	MessageTypeMessageRead   MessageType = "MESSAGE_READ"
+	MessageTypeRoomRead      MessageType = "ROOM_READ"      // A user read everything in the room
+	MessageTypeUnreadUpdated MessageType = "UNREAD_UPDATED" // Sent to each participant with their own unread count
+
+	// Mentions, sent only to the mentioned users
+	MessageTypeUserMentioned MessageType = "USER_MENTIONED"
 
 	// Room events
 	MessageTypeUserJoined MessageType = "USER_JOINED"
 	MessageTypeUserLeft   MessageType = "USER_LEFT"
+	MessageTypeParticipantRoleChanged MessageType = "PARTICIPANT_ROLE_CHANGED"
+	MessageTypeParticipantMuted MessageType = "PARTICIPANT_MUTED"
 	MessageTypeRoomUpdated MessageType = "ROOM_UPDATED"
+	MessageTypeRoomConverted MessageType = "ROOM_CONVERTED"
+	MessageTypeRoomArchived  MessageType = "ROOM_ARCHIVED"
 
 	// User status
 	MessageTypeUserStatusChanged MessageType = "USER_STATUS_CHANGED"
+	MessageTypePresenceChanged MessageType = "PRESENCE_CHANGED" // Sent after USER_STATUS_CHANGED with the last seen time
+	MessageTypeUserProfileUpdated MessageType = "USER_PROFILE_UPDATED"
 
 	// System messages
 	MessageTypePing MessageType = "PING"
@@ -42,6 +55,9 @@ type Message struct {
 	UserID    uint        `json:"user_id"`
 	Data      interface{} `json:"data,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
+
+	// When set, only these users receive the message, on any connection
+	recipients map[uint]bool
 }
 
 // NewMessage creates a new WebSocket message
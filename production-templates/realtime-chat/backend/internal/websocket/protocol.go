@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Event schema versions, negotiated as WebSocket subprotocols. Clients list
+// the versions they understand in Sec-WebSocket-Protocol, most preferred
+// first, and the server picks the first one it supports.
+const (
+	ProtocolV1 = "chat.v1"
+)
+
+// DefaultProtocol is assumed for clients that don't request a subprotocol,
+// so clients predating negotiation keep working
+const DefaultProtocol = ProtocolV1
+
+// supportedProtocols lists the versions this server can speak
+var supportedProtocols = []string{ProtocolV1}
+
+// CloseUnsupportedProtocol is the close code sent to clients that only
+// requested versions this server doesn't support
+const CloseUnsupportedProtocol = 4001
+
+// negotiateProtocol picks the first of the client's requested versions that
+// the server supports. A client that requested none gets DefaultProtocol.
+func negotiateProtocol(requested []string) (string, bool) {
+	if len(requested) == 0 {
+		return DefaultProtocol, true
+	}
+
+	for _, protocol := range requested {
+		for _, supported := range supportedProtocols {
+			if protocol == supported {
+				return protocol, true
+			}
+		}
+	}
+	return "", false
+}
+
+// maxCloseReason is the longest reason that fits in a close frame
+const maxCloseReason = 123
+
+// unsupportedProtocolReason explains a rejected handshake in the close frame
+func unsupportedProtocolReason(requested []string) string {
+	reason := fmt.Sprintf("unsupported protocol version %s, supported: %s",
+		strings.Join(requested, ", "), strings.Join(supportedProtocols, ", "))
+	if len(reason) > maxCloseReason {
+		reason = fmt.Sprintf("unsupported protocol version, supported: %s", strings.Join(supportedProtocols, ", "))
+	}
+	return reason
+}
+
+// encodeMessage renders a message in the shape of the given protocol version.
+// Add a case here when a new version changes the event shape.
+func encodeMessage(protocol string, message *Message) ([]byte, error) {
+	switch protocol {
+	case ProtocolV1:
+		return json.Marshal(message)
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", protocol)
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// startProtocolServer serves HandleConnection for user 7 on /ws/:roomId
+func startProtocolServer(t *testing.T, hub *Hub) string {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewWebSocketHandler(hub, participants{1: {7}})
+	router.GET("/ws/:roomId", func(c *gin.Context) {
+		c.Set("userID", uint(7))
+		handler.HandleConnection(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/1"
+}
+
+// registeredClient waits for the hub to register a client in the room
+func registeredClient(t *testing.T, hub *Hub, roomID uint) *Client {
+	t.Helper()
+
+	s := hub.shardFor(roomID)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		for client := range s.rooms[roomID] {
+			s.mu.RUnlock()
+			return client
+		}
+		s.mu.RUnlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the client to register")
+	return nil
+}
+
+func TestHandleConnection_NegotiatesProtocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		wantReply string
+	}{
+		{name: "supported version", requested: []string{"chat.v9", ProtocolV1}, wantReply: ProtocolV1},
+		{name: "no version requested", requested: nil, wantReply: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(HubConfig{})
+			go hub.Run()
+			url := startProtocolServer(t, hub)
+
+			dialer := websocket.Dialer{Subprotocols: tt.requested}
+			conn, _, err := dialer.Dial(url, nil)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			if got := conn.Subprotocol(); got != tt.wantReply {
+				t.Errorf("negotiated subprotocol = %q, want %q", got, tt.wantReply)
+			}
+			if client := registeredClient(t, hub, 1); client.Protocol != ProtocolV1 {
+				t.Errorf("Client.Protocol = %q, want %q", client.Protocol, ProtocolV1)
+			}
+		})
+	}
+}
+
+func TestHandleConnection_RejectsUnsupportedProtocol(t *testing.T) {
+	hub := NewHub(HubConfig{})
+	go hub.Run()
+	url := startProtocolServer(t, hub)
+
+	dialer := websocket.Dialer{Subprotocols: []string{"chat.v0"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+	}
+	if closeErr.Code != CloseUnsupportedProtocol {
+		t.Errorf("close code = %d, want %d", closeErr.Code, CloseUnsupportedProtocol)
+	}
+	if !strings.Contains(closeErr.Text, "chat.v0") || !strings.Contains(closeErr.Text, ProtocolV1) {
+		t.Errorf("close reason = %q, want it to name the requested and supported versions", closeErr.Text)
+	}
+
+	if count := hub.GetClientCount(); count != 0 {
+		t.Errorf("GetClientCount() = %d, want 0 after a rejected handshake", count)
+	}
+}
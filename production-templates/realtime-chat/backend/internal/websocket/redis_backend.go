@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend relays messages between server instances over Redis pub/sub.
+// Room messages are published on "<prefix>room:<id>" and targeted messages on
+// "<prefix>users"; every instance subscribes to all of them.
+type RedisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisBackend(client *redis.Client, prefix string) *RedisBackend {
+	return &RedisBackend{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (b *RedisBackend) Publish(ctx context.Context, envelope *Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return b.client.Publish(ctx, b.channel(envelope), data).Err()
+}
+
+func (b *RedisBackend) Subscribe(handle func(*Envelope)) (func(), error) {
+	ctx := context.Background()
+	pubsub := b.client.PSubscribe(ctx, b.prefix+"*")
+
+	// Wait for the confirmation so nothing published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// The channel is closed once pubsub is; go-redis resubscribes by
+		// itself after a dropped connection
+		for msg := range pubsub.Channel() {
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil || envelope.Message == nil {
+				log.Printf("Ignoring malformed hub message on %s: %v", msg.Channel, err)
+				continue
+			}
+			handle(&envelope)
+		}
+	}()
+
+	return func() {
+		pubsub.Close()
+		<-done
+	}, nil
+}
+
+// channel returns the channel an envelope is published on
+func (b *RedisBackend) channel(envelope *Envelope) string {
+	if len(envelope.Recipients) > 0 {
+		return b.prefix + "users"
+	}
+	return fmt.Sprintf("%sroom:%d", b.prefix, envelope.Message.RoomID)
+}
+
+// RedisPresence counts connections in Redis under "<prefix>conns:<id>", so
+// every instance sharing the prefix sees the same counts. Connections of an
+// instance that crashed are not subtracted.
+type RedisPresence struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisPresence(client *redis.Client, prefix string) *RedisPresence {
+	return &RedisPresence{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (p *RedisPresence) Add(ctx context.Context, userID uint, delta int) (int, error) {
+	count, err := p.client.IncrBy(ctx, fmt.Sprintf("%sconns:%d", p.prefix, userID), int64(delta)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count connections: %w", err)
+	}
+	if count < 0 {
+		return 0, nil
+	}
+	return int(count), nil
+}
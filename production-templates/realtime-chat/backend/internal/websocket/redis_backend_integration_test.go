@@ -0,0 +1,127 @@
+//go:build integration
+
+package websocket
+
+// Run against a local Redis with:
+//
+//	REDIS_ADDR=localhost:6379 go test -tags integration ./internal/websocket
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Fatalf("failed to connect to redis at %s: %v", addr, err)
+	}
+	return client
+}
+
+// readyBackend signals once the hub has subscribed
+type readyBackend struct {
+	HubBackend
+	ready chan struct{}
+}
+
+func (b *readyBackend) Subscribe(handle func(*Envelope)) (func(), error) {
+	unsubscribe, err := b.HubBackend.Subscribe(handle)
+	close(b.ready)
+	return unsubscribe, err
+}
+
+// startRedisHub runs a hub as one server instance would and waits for its
+// subscription
+func startRedisHub(t *testing.T, prefix string) *Hub {
+	t.Helper()
+
+	backend := &readyBackend{
+		HubBackend: NewRedisBackend(newTestRedisClient(t), prefix),
+		ready:      make(chan struct{}),
+	}
+	hub := NewHub(HubConfig{Backend: backend})
+	go hub.Run()
+
+	select {
+	case <-backend.ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("hub did not subscribe to redis")
+	}
+	return hub
+}
+
+func TestRedisBackend_AcrossInstances(t *testing.T) {
+	// A fresh prefix keeps concurrent runs apart
+	prefix := fmt.Sprintf("chat:hub:test:%d:", time.Now().UnixNano())
+	first := startRedisHub(t, prefix)
+	second := startRedisHub(t, prefix)
+
+	local := NewClient(first, nil, 1, 7)
+	remote := NewClient(second, nil, 1, 8)
+	elsewhere := NewClient(second, nil, 2, 9)
+	first.Register(local)
+	second.Register(remote)
+	second.Register(elsewhere)
+
+	first.Broadcast(NewMessage(MessageTypeNewMessage, 1, 7, "hello"))
+	if msg := expectMessage(t, remote); msg.Type != MessageTypeNewMessage || msg.Data != "hello" {
+		t.Errorf("remote client received %s %v, want the broadcast", msg.Type, msg.Data)
+	}
+	expectMessage(t, local)
+
+	// Exactly once, and only in the room
+	expectNoMessage(t, local)
+	expectNoMessage(t, remote)
+	expectNoMessage(t, elsewhere)
+
+	first.SendToUsers(NewMessage(MessageTypeUserMentioned, 1, 7, "hi"), []uint{9})
+	expectMessage(t, elsewhere)
+	expectNoMessage(t, remote)
+}
+
+func TestRedisPresence_Add(t *testing.T) {
+	client := newTestRedisClient(t)
+	prefix := fmt.Sprintf("chat:hub:test:%d:", time.Now().UnixNano())
+	t.Cleanup(func() { client.Del(context.Background(), prefix+"conns:7") })
+
+	// Two instances counting the same user
+	first := NewRedisPresence(client, prefix)
+	second := NewRedisPresence(client, prefix)
+	ctx := context.Background()
+
+	steps := []struct {
+		presence *RedisPresence
+		delta    int
+		want     int
+	}{
+		{first, 1, 1},
+		{second, 2, 3},
+		{first, -1, 2},
+		{second, -2, 0},
+	}
+	for i, step := range steps {
+		count, err := step.presence.Add(ctx, 7, step.delta)
+		if err != nil {
+			t.Fatalf("step %d: Add() error = %v", i, err)
+		}
+		if count != step.want {
+			t.Errorf("step %d: Add(%d) = %d, want %d", i, step.delta, count, step.want)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+-- Rooms exempt from automatic archival
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS never_auto_archive BOOLEAN NOT NULL DEFAULT false;
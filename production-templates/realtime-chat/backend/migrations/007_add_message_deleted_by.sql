@@ -0,0 +1,2 @@
+-- Who soft-deleted a message: the sender or a room moderator
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
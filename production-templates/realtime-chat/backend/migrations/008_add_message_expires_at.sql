@@ -0,0 +1,4 @@
+-- Ephemeral messages are soft-deleted once expires_at has passed
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at) WHERE expires_at IS NOT NULL AND is_deleted = false;
@@ -0,0 +1,3 @@
+-- Whether participants see the whole backlog ('full') or only messages sent
+-- after they joined ('since_join')
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS history_visibility VARCHAR(20) NOT NULL DEFAULT 'full';
@@ -0,0 +1,6 @@
+-- Room creators were stored as 'admin'; they now hold the 'owner' role
+UPDATE participants
+SET role = 'owner'
+FROM rooms
+WHERE participants.room_id = rooms.id
+  AND participants.user_id = rooms.creator_id;
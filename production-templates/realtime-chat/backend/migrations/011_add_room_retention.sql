@@ -0,0 +1,2 @@
+-- Messages older than this many days are purged; NULL keeps them forever
+ALTER TABLE rooms ADD COLUMN IF NOT EXISTS retention_days INTEGER;
@@ -0,0 +1,4 @@
+-- Profile bio, and the storage key of an uploaded avatar so it can be
+-- replaced
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Set when a room admin mutes a participant, who then cannot send messages
+ALTER TABLE participants ADD COLUMN IF NOT EXISTS is_silenced BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,19 @@
+-- Messages to be sent to a room at a later time
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL DEFAULT 'text',
+    content TEXT NOT NULL,
+    reply_to_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    send_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scheduled_messages_room_id ON scheduled_messages(room_id);
+CREATE INDEX idx_scheduled_messages_due ON scheduled_messages(status, send_at);
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,9 +18,12 @@ import (
 	"task-management-app/internal/config"
 	"task-management-app/internal/domain"
 	"task-management-app/internal/handler"
+	"task-management-app/internal/mailer"
 	"task-management-app/internal/middleware"
 	"task-management-app/internal/repository"
+	"task-management-app/internal/sanitize"
 	"task-management-app/internal/service"
+	"task-management-app/internal/storage"
 	"task-management-app/internal/websocket"
 )
 
@@ -49,20 +53,65 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	projectRepo := repository.NewProjectRepository(db)
 	boardRepo := repository.NewBoardRepository(db)
+	labelRepo := repository.NewLabelRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
+	activityRepo := repository.NewActivityRepository(db)
+	templateRepo := repository.NewProjectTemplateRepository(db)
+	invitationRepo := repository.NewInvitationRepository(db)
+
+	// Store the built-in project templates
+	if err := templateRepo.EnsureBuiltIns(domain.BuiltInProjectTemplates()); err != nil {
+		log.Printf("Failed to store built-in project templates: %v", err)
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.Auth.JWTSecret, time.Duration(cfg.Auth.JWTExpiration)*time.Minute)
-	projectService := service.NewProjectService(projectRepo, userRepo)
+	contentSanitizer := sanitize.NewSanitizer(sanitize.ParseMode(cfg.Content.SanitizeMode))
+	jwtKeys := cfg.Auth.KeySet()
+	authService := service.NewAuthService(userRepo, jwtKeys, time.Duration(cfg.Auth.JWTExpiration)*time.Minute)
+	var invitationMailer mailer.Mailer = mailer.LogMailer{}
+	if cfg.SMTP.User != "" {
+		invitationMailer = mailer.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.User, cfg.SMTP.Password, cfg.SMTP.From)
+	}
+	projectService := service.NewProjectService(projectRepo, userRepo, labelRepo, templateRepo, invitationRepo, invitationMailer, service.ProjectOptions{
+		InvitationTTL:       cfg.Invite.TTL,
+		InvitationAcceptURL: cfg.Invite.AcceptURL,
+	})
 	boardService := service.NewBoardService(boardRepo, projectRepo, hub)
-	taskService := service.NewTaskService(taskRepo, boardRepo, projectRepo, hub)
+	labelService := service.NewLabelService(labelRepo, projectRepo, hub)
+	fileStorage := storage.NewLocalStorage(cfg.Storage.UploadDir, cfg.Storage.BaseURL)
+	taskService := service.NewTaskService(taskRepo, boardRepo, projectRepo, activityRepo, contentSanitizer, fileStorage, hub, service.TaskOptions{
+		RejectPastDueDates: cfg.Task.RejectPastDueDates,
+		MaxAttachmentSize:  cfg.Storage.MaxAttachmentSize,
+	})
+
+	// Fix projects left with zero or several owners by older role updates
+	if repaired, err := projectService.RepairOwnership(); err != nil {
+		log.Printf("Failed to repair project ownership: %v", err)
+	} else if len(repaired) > 0 {
+		log.Printf("Repaired ownership of projects: %v", repaired)
+	}
+
+	// Archive inactive projects in the background
+	if cfg.Archive.StaleAfter > 0 {
+		archiveService := service.NewArchiveService(projectRepo, hub, cfg.Archive.StaleAfter)
+		go archiveService.Run(cfg.Archive.Interval)
+	}
+
+	// Announce tasks falling due soon and reaching their due date
+	if cfg.Task.ReminderInterval > 0 {
+		reminderScheduler := service.NewReminderScheduler(taskRepo, hub, cfg.Task.DueSoonWindow)
+		go reminderScheduler.Run(cfg.Task.ReminderInterval)
+	}
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
 	projectHandler := handler.NewProjectHandler(projectService)
 	boardHandler := handler.NewBoardHandler(boardService)
+	labelHandler := handler.NewLabelHandler(labelService)
 	taskHandler := handler.NewTaskHandler(taskService)
-	wsHandler := websocket.NewWebSocketHandler(hub)
+	wsHandler := websocket.NewWebSocketHandler(hub, projectService, func(token string) (*domain.JWTClaims, error) {
+		return middleware.ParseAccessToken(token, jwtKeys)
+	})
 
 	// Set gin mode
 	if cfg.Server.Env == "production" {
@@ -73,7 +122,9 @@ func main() {
 	router := gin.New()
 
 	// Global middleware
-	router.Use(middleware.LoggerMiddleware())
+	requestLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.LoggerMiddleware(requestLogger))
 	router.Use(middleware.CORSMiddleware())
 	router.Use(gin.Recovery())
 
@@ -85,6 +136,9 @@ func main() {
 		})
 	})
 
+	// Uploaded attachments
+	router.Static(cfg.Storage.BaseURL, cfg.Storage.UploadDir)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -96,32 +150,51 @@ func main() {
 			auth.POST("/refresh", authHandler.RefreshToken)
 		}
 
-		// WebSocket endpoint (requires auth)
-		v1.GET("/ws/:projectId", middleware.AuthMiddleware(cfg.Auth.JWTSecret), wsHandler.HandleConnection)
+		// WebSocket endpoint (authenticates itself, accepting ?token= from browsers)
+		v1.GET("/ws/:projectId", wsHandler.HandleConnection)
 
 		// Protected routes (require authentication)
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.Auth.JWTSecret))
+		protected.Use(middleware.AuthMiddleware(jwtKeys))
 		{
 			// Profile routes
 			protected.GET("/profile", authHandler.GetProfile)
 
+			// Project invitations
+			protected.POST("/invitations/:token/accept", projectHandler.AcceptInvitation)
+			protected.POST("/invites/:token/accept", projectHandler.AcceptInvite)
+
+			// Project templates
+			protected.GET("/templates", projectHandler.ListTemplates)
+
 			// Project routes
 			projects := protected.Group("/projects")
 			{
 				projects.GET("", projectHandler.List)
 				projects.POST("", projectHandler.Create)
+				projects.POST("/from-template", projectHandler.CreateFromTemplate)
 				projects.GET("/:id", projectHandler.GetByID)
 				projects.PUT("/:id", projectHandler.Update)
 				projects.DELETE("/:id", projectHandler.Delete)
 				projects.POST("/:id/archive", projectHandler.Archive)
 				projects.POST("/:id/unarchive", projectHandler.Unarchive)
+				projects.POST("/:id/save-as-template", projectHandler.SaveAsTemplate)
 
 				// Project members
 				projects.GET("/:id/members", projectHandler.GetMembers)
 				projects.POST("/:id/members", projectHandler.AddMember)
 				projects.DELETE("/:id/members/:memberID", projectHandler.RemoveMember)
 				projects.PUT("/:id/members/:memberID/role", projectHandler.UpdateMemberRole)
+				projects.POST("/:id/transfer", projectHandler.TransferOwnership)
+				projects.POST("/:id/invitations", projectHandler.InviteMember)
+				projects.POST("/:id/invites", projectHandler.CreateInvite)
+
+				// Project tasks by due date
+				projects.GET("/:id/tasks/overdue", taskHandler.GetOverdue)
+				projects.GET("/:id/tasks/upcoming", taskHandler.GetUpcoming)
+
+				// Project task statistics
+				projects.GET("/:id/stats", taskHandler.GetProjectStats)
 
 				// Project online users (WebSocket)
 				projects.GET("/:projectId/online-users", wsHandler.GetOnlineUsers)
@@ -132,9 +205,21 @@ func main() {
 			{
 				boards.POST("/projects/:projectID/boards", boardHandler.Create)
 				boards.GET("/projects/:projectID/boards", boardHandler.ListByProject)
+				boards.POST("/projects/:projectID/boards/reorder", boardHandler.Reorder)
 				boards.GET("/boards/:id", boardHandler.GetByID)
 				boards.PUT("/boards/:id", boardHandler.Update)
 				boards.DELETE("/boards/:id", boardHandler.Delete)
+				boards.GET("/boards/:id/export", taskHandler.ExportBoard)
+			}
+
+			// Label routes
+			labels := protected.Group("")
+			{
+				labels.POST("/projects/:projectID/labels", labelHandler.Create)
+				labels.GET("/projects/:projectID/labels", labelHandler.ListByProject)
+				labels.GET("/projects/:projectID/labels/usage", labelHandler.GetUsage)
+				labels.PUT("/labels/:id", labelHandler.Update)
+				labels.DELETE("/labels/:id", labelHandler.Delete)
 			}
 
 			// Task routes
@@ -142,23 +227,45 @@ func main() {
 			{
 				tasks.POST("/boards/:boardID/tasks", taskHandler.Create)
 				tasks.GET("/boards/:boardID/tasks", taskHandler.ListByBoard)
+				tasks.POST("/boards/:boardID/reorder", taskHandler.Reorder)
+				tasks.GET("/projects/:projectID/tasks/search", taskHandler.Search)
 				tasks.GET("/tasks/:id", taskHandler.GetByID)
 				tasks.PUT("/tasks/:id", taskHandler.Update)
 				tasks.DELETE("/tasks/:id", taskHandler.Delete)
+				tasks.POST("/tasks/:id/assign-me", taskHandler.AssignToMe)
+				tasks.POST("/tasks/:id/unassign", taskHandler.Unassign)
 				tasks.POST("/tasks/:id/move", taskHandler.Move)
+				tasks.POST("/tasks/:id/transfer", taskHandler.Transfer)
+				tasks.GET("/tasks/:id/activity", taskHandler.GetActivity)
 
 				// Task comments
 				tasks.POST("/tasks/:id/comments", taskHandler.AddComment)
+				tasks.PUT("/tasks/:id/comments/:commentID", taskHandler.UpdateComment)
 				tasks.DELETE("/tasks/:id/comments/:commentID", taskHandler.DeleteComment)
 
+				// Time tracking
+				tasks.POST("/tasks/:id/time", taskHandler.LogTime)
+				tasks.GET("/tasks/:id/time", taskHandler.GetTimeLogs)
+
 				// Task checklist
 				tasks.POST("/tasks/:id/checklist", taskHandler.AddChecklistItem)
 				tasks.PUT("/tasks/:id/checklist/:itemID", taskHandler.UpdateChecklistItem)
 				tasks.DELETE("/tasks/:id/checklist/:itemID", taskHandler.DeleteChecklistItem)
+				tasks.PUT("/tasks/:id/checklist/reorder", taskHandler.ReorderChecklist)
+				tasks.POST("/tasks/:id/checklist/:itemID/convert", taskHandler.ConvertChecklistItem)
+
+				// Task attachments
+				tasks.POST("/tasks/:id/attachments", taskHandler.AddAttachment)
+				tasks.GET("/tasks/:id/attachments", taskHandler.GetAttachments)
+				tasks.DELETE("/attachments/:id", taskHandler.DeleteAttachment)
 
 				// Task labels
 				tasks.POST("/tasks/:id/labels", taskHandler.AssignLabels)
 			}
+
+			// Personal task list and activity timeline
+			protected.GET("/me/tasks", taskHandler.ListMyTasks)
+			protected.GET("/me/activity", taskHandler.GetMyActivity)
 		}
 	}
 
@@ -196,6 +303,11 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Hijacked WebSocket connections are not closed by srv.Shutdown
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Printf("WebSocket hub forced to shutdown: %v", err)
+	}
+
 	log.Println("Server exited")
 }
 
@@ -226,7 +338,13 @@ func migrateDB(db *gorm.DB) error {
 		&domain.Task{},
 		&domain.Label{},
 		&domain.Comment{},
+		&domain.CommentMention{},
+		&domain.TimeLog{},
 		&domain.Attachment{},
 		&domain.ChecklistItem{},
+		&domain.TaskActivity{},
+		&domain.ProjectTemplate{},
+		&domain.ProjectInvitation{},
+		&domain.ProjectInvite{},
 	)
 }
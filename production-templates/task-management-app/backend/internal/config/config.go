@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"task-management-app/internal/jwtkeys"
 )
 
 type Config struct {
@@ -14,6 +17,11 @@ type Config struct {
 	Redis    RedisConfig
 	Auth     AuthConfig
 	SMTP     SMTPConfig
+	Invite   InviteConfig
+	Content  ContentConfig
+	Archive  ArchiveConfig
+	Task     TaskConfig
+	Storage  StorageConfig
 }
 
 type ServerConfig struct {
@@ -38,9 +46,11 @@ type RedisConfig struct {
 }
 
 type AuthConfig struct {
-	JWTSecret     string
-	JWTExpiration int // in minutes
-	RefreshTTL    time.Duration
+	JWTSecret       string
+	JWTKeyID        string            // Sent as the kid of tokens signed with JWTSecret
+	JWTPreviousKeys map[string]string // Retired key IDs and secrets whose tokens are still accepted
+	JWTExpiration   int               // in minutes
+	RefreshTTL      time.Duration
 }
 
 type SMTPConfig struct {
@@ -51,6 +61,32 @@ type SMTPConfig struct {
 	From     string
 }
 
+type InviteConfig struct {
+	TTL       time.Duration // How long an invitation can be accepted
+	AcceptURL string        // Link sent to invitees; the token is appended
+}
+
+type ContentConfig struct {
+	SanitizeMode string // "escape" or "markdown"
+}
+
+type TaskConfig struct {
+	RejectPastDueDates bool          // Refuse due dates before today on create/update
+	ReminderInterval   time.Duration // How often due tasks are checked for reminders; 0 disables
+	DueSoonWindow      time.Duration // How long before the due date assignees are reminded; 0 disables
+}
+
+type StorageConfig struct {
+	UploadDir         string // Directory attachments are stored in
+	BaseURL           string // URL path the upload directory is served from
+	MaxAttachmentSize int64  // In bytes
+}
+
+type ArchiveConfig struct {
+	StaleAfter time.Duration // 0 disables automatic archival
+	Interval   time.Duration
+}
+
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -74,9 +110,11 @@ func Load() (*Config, error) {
 			DB:       0,
 		},
 		Auth: AuthConfig{
-			JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-			JWTExpiration: parseInt(getEnv("JWT_EXPIRATION", "15")),  // default 15 minutes
-			RefreshTTL:    parseDuration(getEnv("JWT_REFRESH_TTL", "168h")), // default 7 days
+			JWTSecret:       getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
+			JWTKeyID:        getEnv("JWT_KEY_ID", jwtkeys.DefaultKeyID),
+			JWTPreviousKeys: parseKeys(getEnv("JWT_PREVIOUS_KEYS", "")),
+			JWTExpiration:   parseInt(getEnv("JWT_EXPIRATION", "15")),         // default 15 minutes
+			RefreshTTL:      parseDuration(getEnv("JWT_REFRESH_TTL", "168h")), // default 7 days
 		},
 		SMTP: SMTPConfig{
 			Host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -85,11 +123,38 @@ func Load() (*Config, error) {
 			Password: getEnv("SMTP_PASSWORD", ""),
 			From:     getEnv("SMTP_FROM", "noreply@taskapp.com"),
 		},
+		Invite: InviteConfig{
+			TTL:       parseDuration(getEnv("INVITATION_TTL", "168h")), // default 7 days
+			AcceptURL: getEnv("INVITATION_ACCEPT_URL", "http://localhost:3000/invitations/"),
+		},
+		Content: ContentConfig{
+			SanitizeMode: getEnv("CONTENT_SANITIZE_MODE", "escape"),
+		},
+		Archive: ArchiveConfig{
+			StaleAfter: parseOptionalDuration(getEnv("AUTO_ARCHIVE_AFTER", "0")),
+			Interval:   parseDuration(getEnv("AUTO_ARCHIVE_INTERVAL", "1h")),
+		},
+		Task: TaskConfig{
+			RejectPastDueDates: getEnv("TASK_REJECT_PAST_DUE_DATES", "false") == "true",
+			ReminderInterval:   parseOptionalDuration(getEnv("TASK_REMINDER_INTERVAL", "1m")),
+			DueSoonWindow:      parseOptionalDuration(getEnv("TASK_DUE_SOON_WINDOW", "24h")),
+		},
+		Storage: StorageConfig{
+			UploadDir:         getEnv("UPLOAD_DIR", "./uploads"),
+			BaseURL:           getEnv("UPLOAD_BASE_URL", "/uploads"),
+			MaxAttachmentSize: parseSize(getEnv("ATTACHMENT_MAX_SIZE", "10485760")), // default 10 MiB
+		},
 	}
 
 	return config, nil
 }
 
+// KeySet returns the keys access and refresh tokens are signed and verified
+// with
+func (c *AuthConfig) KeySet() *jwtkeys.KeySet {
+	return jwtkeys.New(c.JWTKeyID, c.JWTSecret, c.JWTPreviousKeys)
+}
+
 func (c *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -116,6 +181,39 @@ func parseDuration(s string) time.Duration {
 	return d
 }
 
+// parseOptionalDuration returns 0 when s is empty, zero or malformed.
+func parseOptionalDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// parseSize parses a byte count, falling back to 10 MiB when s is malformed
+// or not positive.
+func parseSize(s string) int64 {
+	var n int64
+	if _, err := fmt.Sscanf(s, "%d", &n); err != nil || n <= 0 {
+		return 10 << 20
+	}
+	return n
+}
+
+// parseKeys parses a comma-separated list of "id:secret" pairs. Malformed
+// entries are skipped.
+func parseKeys(s string) map[string]string {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		keys[id] = secret
+	}
+	return keys
+}
+
 func parseInt(s string) int {
 	var i int
 	fmt.Sscanf(s, "%d", &i)
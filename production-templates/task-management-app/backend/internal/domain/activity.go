@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type ActivityAction string
+
+const (
+	ActivityTaskCreated     ActivityAction = "task_created"
+	ActivityTaskUpdated     ActivityAction = "task_updated"
+	ActivityTaskAssigned    ActivityAction = "task_assigned"
+	ActivityTaskMoved       ActivityAction = "task_moved"
+	ActivityTaskTransferred ActivityAction = "task_transferred"
+	ActivityCommentAdded    ActivityAction = "comment_added"
+)
+
+// TaskActivity is a persisted record of a user's action on a task. Unlike
+// WebSocket events it survives, so it can be replayed as a timeline.
+type TaskActivity struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	ProjectID uint            `json:"project_id" gorm:"not null;index"`
+	Project   *Project        `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	TaskID    uint            `json:"task_id" gorm:"not null;index"`
+	Task      *Task           `json:"task,omitempty" gorm:"foreignKey:TaskID"`
+	UserID    uint            `json:"user_id" gorm:"not null;index"`
+	User      *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Action    ActivityAction  `json:"action" gorm:"not null"`
+	Changes   ActivityChanges `json:"changes,omitempty" gorm:"type:jsonb"`
+	CreatedAt time.Time       `json:"created_at" gorm:"index"`
+}
+
+// FieldChange is the value of a task field before and after an action
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// ActivityChanges maps the task fields an action changed to their old and new
+// values. It is stored as a JSON object.
+type ActivityChanges map[string]FieldChange
+
+func (c ActivityChanges) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *ActivityChanges) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ActivityChanges", value)
+	}
+	return json.Unmarshal(data, c)
+}
@@ -3,13 +3,28 @@ package domain
 import "time"
 
 type Board struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	ProjectID uint      `json:"project_id" gorm:"not null"`
-	Name      string    `json:"name" gorm:"not null"`
-	Position  int       `json:"position" gorm:"not null;default:0"`
-	Tasks     []Task    `json:"tasks,omitempty" gorm:"foreignKey:BoardID"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	ProjectID       uint      `json:"project_id" gorm:"not null"`
+	Project         *Project  `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	Name            string    `json:"name" gorm:"not null"`
+	Position        int       `json:"position" gorm:"not null;default:0"`
+	WIPLimit        *int      `json:"wip_limit"`                  // Max active (non-completed) tasks; nil = unlimited
+	ActiveTaskCount int       `json:"active_task_count" gorm:"-"` // Tasks counting towards WIPLimit
+	CreatedByID     *uint     `json:"created_by_id"`              // nil for boards created before creators were recorded
+	Tasks           []Task    `json:"tasks,omitempty" gorm:"foreignKey:BoardID"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ComputeActiveTaskCount fills ActiveTaskCount from the loaded Tasks, counting
+// those that count towards the WIP limit
+func (b *Board) ComputeActiveTaskCount() {
+	b.ActiveTaskCount = 0
+	for _, task := range b.Tasks {
+		if !task.IsCompleted {
+			b.ActiveTaskCount++
+		}
+	}
 }
 
 type CreateBoardRequest struct {
@@ -20,4 +35,10 @@ type CreateBoardRequest struct {
 type UpdateBoardRequest struct {
 	Name     string `json:"name"`
 	Position *int   `json:"position"`
+	WIPLimit *int   `json:"wip_limit" binding:"omitempty,gte=0"` // 0 removes the limit
+}
+
+// ReorderBoardsRequest lists every board of a project in its new order
+type ReorderBoardsRequest struct {
+	BoardIDs []uint `json:"board_ids" binding:"required"`
 }
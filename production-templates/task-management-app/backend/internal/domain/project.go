@@ -12,18 +12,22 @@ const (
 )
 
 type Project struct {
-	ID          uint             `json:"id" gorm:"primaryKey"`
-	Name        string           `json:"name" gorm:"not null"`
-	Description string           `json:"description"`
-	Icon        string           `json:"icon"`
-	Color       string           `json:"color"`
-	OwnerID     uint             `json:"owner_id" gorm:"not null"`
-	Owner       *User            `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
-	Members     []ProjectMember  `json:"members,omitempty" gorm:"foreignKey:ProjectID"`
-	Boards      []Board          `json:"boards,omitempty" gorm:"foreignKey:ProjectID"`
-	IsArchived  bool             `json:"is_archived" gorm:"not null;default:false"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
+	ID               uint            `json:"id" gorm:"primaryKey"`
+	Name             string          `json:"name" gorm:"not null"`
+	Key              string          `json:"key" gorm:"not null;default:''"` // Prefix of task display IDs, e.g. PROJ in PROJ-42
+	Description      string          `json:"description"`
+	Icon             string          `json:"icon"`
+	Color            string          `json:"color"`
+	OwnerID          uint            `json:"owner_id" gorm:"not null"`
+	Owner            *User           `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Members          []ProjectMember `json:"members,omitempty" gorm:"foreignKey:ProjectID"`
+	Boards           []Board         `json:"boards,omitempty" gorm:"foreignKey:ProjectID"`
+	Labels           []Label         `json:"labels,omitempty" gorm:"foreignKey:ProjectID"` // Not loaded; set to create labels along with the project
+	TaskCounter      int             `json:"-" gorm:"not null;default:0"`                  // Last task number handed out
+	IsArchived       bool            `json:"is_archived" gorm:"not null;default:false"`
+	NeverAutoArchive bool            `json:"never_auto_archive" gorm:"not null;default:false"` // Exempt from archival of inactive projects
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
 }
 
 type ProjectMember struct {
@@ -38,16 +42,70 @@ type ProjectMember struct {
 
 type CreateProjectRequest struct {
 	Name        string `json:"name" binding:"required"`
+	Key         string `json:"key"` // Derived from the name when empty
 	Description string `json:"description"`
 	Icon        string `json:"icon"`
 	Color       string `json:"color"`
 }
 
 type UpdateProjectRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Icon        string `json:"icon"`
-	Color       string `json:"color"`
+	Name             string `json:"name"`
+	Key              string `json:"key"` // Only affects tasks created afterwards
+	Description      string `json:"description"`
+	Icon             string `json:"icon"`
+	Color            string `json:"color"`
+	NeverAutoArchive *bool  `json:"never_auto_archive"`
+}
+
+// AutoArchiveNotice is broadcast when an inactive project is archived
+// automatically. AdminIDs lists the project's owner and admins.
+type AutoArchiveNotice struct {
+	ProjectID     uint      `json:"project_id"`
+	InactiveSince time.Time `json:"inactive_since"`
+	AdminIDs      []uint    `json:"admin_ids"`
+}
+
+// ProjectInvitation invites someone to a project by email, whether or not
+// they have an account yet. Whoever signs in with that email can accept it
+// with the token until it expires.
+type ProjectInvitation struct {
+	ID           uint        `json:"id" gorm:"primaryKey"`
+	ProjectID    uint        `json:"project_id" gorm:"not null;index"`
+	Project      *Project    `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+	Email        string      `json:"email" gorm:"not null"`
+	Role         ProjectRole `json:"role" gorm:"not null;default:'member'"`
+	Token        string      `json:"-" gorm:"uniqueIndex;not null"` // Only sent to the invitee
+	InvitedByID  uint        `json:"invited_by_id" gorm:"not null"`
+	ExpiresAt    time.Time   `json:"expires_at" gorm:"not null"`
+	AcceptedAt   *time.Time  `json:"accepted_at"`
+	AcceptedByID *uint       `json:"accepted_by_id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// ProjectInvite is a shareable invite link. Unlike a ProjectInvitation it
+// isn't tied to an email address: the first user to accept it before it
+// expires joins the project, after which it can't be used again.
+type ProjectInvite struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	ProjectID   uint        `json:"project_id" gorm:"not null;index"`
+	Role        ProjectRole `json:"role" gorm:"not null;default:'member'"`
+	Token       string      `json:"token" gorm:"uniqueIndex;not null"`
+	CreatedByID uint        `json:"created_by_id" gorm:"not null"`
+	ExpiresAt   time.Time   `json:"expires_at" gorm:"not null"`
+	UsedAt      *time.Time  `json:"used_at"`
+	UsedByID    *uint       `json:"used_by_id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+type CreateInviteRequest struct {
+	Role ProjectRole `json:"role" binding:"required"`
+}
+
+type InviteMemberRequest struct {
+	Email string      `json:"email" binding:"required,email"`
+	Role  ProjectRole `json:"role" binding:"required"`
 }
 
 type AddMemberRequest struct {
@@ -58,3 +116,7 @@ type AddMemberRequest struct {
 type UpdateMemberRoleRequest struct {
 	Role ProjectRole `json:"role" binding:"required"`
 }
+
+type TransferOwnershipRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
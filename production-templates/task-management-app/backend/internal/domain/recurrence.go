@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type RecurrenceFrequency string
+
+const (
+	RecurrenceDaily   RecurrenceFrequency = "daily"
+	RecurrenceWeekly  RecurrenceFrequency = "weekly"
+	RecurrenceMonthly RecurrenceFrequency = "monthly"
+)
+
+// maxRecurrenceInterval bounds Interval so a typo can't schedule a task
+// centuries ahead
+const maxRecurrenceInterval = 365
+
+// RecurrenceRule makes a task regenerate when it is completed: the next
+// occurrence is due Interval days, weeks or months after the completed one.
+// It is stored as a JSON object.
+type RecurrenceRule struct {
+	Frequency RecurrenceFrequency `json:"frequency"`
+	Interval  int                 `json:"interval"` // Defaults to 1
+}
+
+// Normalize validates the rule and fills in the default interval
+func (r *RecurrenceRule) Normalize() error {
+	switch r.Frequency {
+	case RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+	default:
+		return fmt.Errorf("invalid recurrence frequency %q: must be daily, weekly or monthly", r.Frequency)
+	}
+
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+	if r.Interval < 1 || r.Interval > maxRecurrenceInterval {
+		return fmt.Errorf("recurrence interval must be between 1 and %d", maxRecurrenceInterval)
+	}
+	return nil
+}
+
+// Next returns the occurrence after t. Monthly rules keep the day of the
+// month, falling back to the month's last day when it is shorter.
+func (r RecurrenceRule) Next(t time.Time) time.Time {
+	switch r.Frequency {
+	case RecurrenceDaily:
+		return t.AddDate(0, 0, r.Interval)
+	case RecurrenceWeekly:
+		return t.AddDate(0, 0, 7*r.Interval)
+	default:
+		next := t.AddDate(0, r.Interval, 0)
+		if next.Day() != t.Day() {
+			// Overflowed into the following month, step back to the end of
+			// the intended one
+			next = next.AddDate(0, 0, -next.Day())
+		}
+		return next
+	}
+}
+
+func (r RecurrenceRule) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *RecurrenceRule) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RecurrenceRule", value)
+	}
+	return json.Unmarshal(data, r)
+}
@@ -0,0 +1,23 @@
+package domain
+
+// ProjectStats summarizes the tasks of a project
+type ProjectStats struct {
+	ProjectID              uint                   `json:"project_id"`
+	Total                  int64                  `json:"total"`
+	ByStatus               map[string]int64       `json:"by_status"`   // "open" and "completed"
+	ByPriority             map[TaskPriority]int64 `json:"by_priority"` // Every priority, including unused ones
+	ByAssignee             []*AssigneeTaskCount   `json:"by_assignee"` // Most tasks first
+	Overdue                int64                  `json:"overdue"`
+	CompletedLast7Days     int64                  `json:"completed_last_7_days"`
+	AverageCompletionHours *float64               `json:"average_completion_hours"` // From creation to completion; nil until a task is completed
+	EstimatedMinutes       int64                  `json:"estimated_minutes"`        // Sum of the tasks' estimates
+	LoggedMinutes          int64                  `json:"logged_minutes"`           // Time logged against the tasks
+}
+
+// AssigneeTaskCount counts the tasks assigned to one user. AssigneeID is nil
+// for unassigned tasks.
+type AssigneeTaskCount struct {
+	AssigneeID *uint  `json:"assignee_id"`
+	Username   string `json:"username,omitempty"`
+	Count      int64  `json:"count"`
+}
@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
 
 type TaskPriority string
 
@@ -12,59 +17,147 @@ const (
 )
 
 type Task struct {
-	ID          uint            `json:"id" gorm:"primaryKey"`
-	BoardID     uint            `json:"board_id" gorm:"not null"`
-	Board       *Board          `json:"board,omitempty" gorm:"foreignKey:BoardID"`
-	Title       string          `json:"title" gorm:"not null"`
-	Description string          `json:"description"`
-	Position    int             `json:"position" gorm:"not null;default:0"`
-	Priority    TaskPriority    `json:"priority" gorm:"not null;default:'medium'"`
-	DueDate     *time.Time      `json:"due_date"`
-	CreatorID   uint            `json:"creator_id" gorm:"not null"`
-	Creator     *User           `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
-	AssigneeID  *uint           `json:"assignee_id"`
-	Assignee    *User           `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
-	Labels      []Label         `json:"labels,omitempty" gorm:"many2many:task_labels"`
-	Comments    []Comment       `json:"comments,omitempty" gorm:"foreignKey:TaskID"`
-	Attachments []Attachment    `json:"attachments,omitempty" gorm:"foreignKey:TaskID"`
-	Checklist   []ChecklistItem `json:"checklist,omitempty" gorm:"foreignKey:TaskID"`
-	IsCompleted bool            `json:"is_completed" gorm:"not null;default:false"`
-	CompletedAt *time.Time      `json:"completed_at"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	ID                 uint             `json:"id" gorm:"primaryKey"`
+	BoardID            uint             `json:"board_id" gorm:"not null"`
+	Board              *Board           `json:"board,omitempty" gorm:"foreignKey:BoardID"`
+	Number             int              `json:"number" gorm:"not null;default:0"` // Sequence within the project
+	DisplayID          string           `json:"display_id" gorm:"not null;default:''"`
+	Title              string           `json:"title" gorm:"not null"`
+	Description        string           `json:"description"`
+	Position           int              `json:"position" gorm:"not null;default:0"`
+	Priority           TaskPriority     `json:"priority" gorm:"not null;default:'medium'"`
+	DueDate            *time.Time       `json:"due_date"`
+	DueNotifiedAt      *time.Time       `json:"-"` // When the TASK_DUE reminder went out; reset when the due date changes
+	ReminderSentAt     *time.Time       `json:"-"` // When the TASK_DUE_SOON reminder went out; reset when the due date changes
+	CreatorID          uint             `json:"creator_id" gorm:"not null"`
+	Creator            *User            `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
+	AssigneeID         *uint            `json:"assignee_id"`
+	Assignee           *User            `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	ParentTaskID       *uint            `json:"parent_task_id"`
+	Subtasks           []Task           `json:"subtasks,omitempty" gorm:"foreignKey:ParentTaskID"`
+	SubtaskProgress    *SubtaskProgress `json:"subtask_progress,omitempty" gorm:"-"`
+	AutoCompleteParent bool             `json:"auto_complete_parent" gorm:"not null;default:false"` // Complete this task with its last open subtask
+	Recurrence         *RecurrenceRule  `json:"recurrence,omitempty" gorm:"type:jsonb"`             // Completing the task creates its next occurrence
+	EstimatedMinutes   *int             `json:"estimated_minutes"`                                  // nil when the task has no estimate
+	LoggedMinutes      int              `json:"logged_minutes" gorm:"not null;default:0"`           // Sum of the task's time logs
+	Labels             []Label          `json:"labels,omitempty" gorm:"many2many:task_labels"`
+	Comments           []Comment        `json:"comments,omitempty" gorm:"foreignKey:TaskID"`
+	Attachments        []Attachment     `json:"attachments,omitempty" gorm:"foreignKey:TaskID"`
+	Checklist          []ChecklistItem  `json:"checklist,omitempty" gorm:"foreignKey:TaskID"`
+	IsCompleted        bool             `json:"is_completed" gorm:"not null;default:false"`
+	CompletedAt        *time.Time       `json:"completed_at"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// SubtaskProgress counts a task's completed subtasks
+type SubtaskProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// ComputeSubtaskProgress fills SubtaskProgress from the loaded Subtasks. It is
+// left nil for tasks without subtasks.
+func (t *Task) ComputeSubtaskProgress() {
+	if len(t.Subtasks) == 0 {
+		t.SubtaskProgress = nil
+		return
+	}
+
+	progress := &SubtaskProgress{Total: len(t.Subtasks)}
+	for _, subtask := range t.Subtasks {
+		if subtask.IsCompleted {
+			progress.Completed++
+		}
+	}
+	t.SubtaskProgress = progress
+}
+
+// TaskDisplayID formats the human-friendly identifier of a task, e.g. PROJ-42
+func TaskDisplayID(projectKey string, number int) string {
+	return fmt.Sprintf("%s-%d", projectKey, number)
 }
 
 type Label struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	ProjectID uint      `json:"project_id" gorm:"not null"`
-	Name      string    `json:"name" gorm:"not null"`
-	Color     string    `json:"color" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ProjectID   uint      `json:"project_id" gorm:"not null"`
+	Name        string    `json:"name" gorm:"not null"`
+	Color       string    `json:"color" gorm:"not null"`
+	CreatedByID *uint     `json:"created_by_id"` // nil for labels created before creators were recorded
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LabelUsage is a label with the number of tasks carrying it
+type LabelUsage struct {
+	Label     `gorm:"embedded"`
+	TaskCount int64 `json:"task_count"`
 }
 
 type Comment struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	TaskID    uint       `json:"task_id" gorm:"not null"`
+	UserID    uint       `json:"user_id" gorm:"not null"`
+	User      *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Content   string     `json:"content" gorm:"not null"`
+	IsEdited  bool       `json:"is_edited" gorm:"not null;default:false"`
+	EditedAt  *time.Time `json:"edited_at"` // When the content was last edited
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	MentionedUserIDs []uint `json:"mentioned_user_ids,omitempty" gorm:"-"` // Set when the comment is added
+}
+
+// CommentMention records that a comment mentioned a project member by
+// @username
+type CommentMention struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
+	CommentID uint      `json:"comment_id" gorm:"not null;uniqueIndex:idx_comment_user_mention"`
 	TaskID    uint      `json:"task_id" gorm:"not null"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Content   string    `json:"content" gorm:"not null"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_comment_user_mention;index"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type Attachment struct {
+// TimeLog records time a user spent on a task
+type TimeLog struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	TaskID    uint      `json:"task_id" gorm:"not null"`
+	TaskID    uint      `json:"task_id" gorm:"not null;index"`
 	UserID    uint      `json:"user_id" gorm:"not null"`
 	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Filename  string    `json:"filename" gorm:"not null"`
-	FileURL   string    `json:"file_url" gorm:"not null"`
-	FileSize  int64     `json:"file_size" gorm:"not null"`
-	MimeType  string    `json:"mime_type" gorm:"not null"`
+	Minutes   int       `json:"minutes" gorm:"not null"`
+	Note      string    `json:"note"`
+	LoggedAt  time.Time `json:"logged_at" gorm:"not null"` // When the work was done
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// TaskTimeLogs lists a task's time logs with their total
+type TaskTimeLogs struct {
+	Entries      []*TimeLog `json:"entries"`
+	TotalMinutes int        `json:"total_minutes"`
+}
+
+type Attachment struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TaskID     uint      `json:"task_id" gorm:"not null"`
+	UserID     uint      `json:"user_id" gorm:"not null"`
+	User       *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Filename   string    `json:"filename" gorm:"not null"`
+	FileURL    string    `json:"file_url" gorm:"not null"`
+	StorageKey string    `json:"-" gorm:"not null;default:''"` // Where the file lives in the storage backend
+	FileSize   int64     `json:"file_size" gorm:"not null"`
+	MimeType   string    `json:"mime_type" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AttachmentUpload is a file being attached to a task. Size and MimeType are
+// as declared by the client; an empty MimeType is detected from the content.
+type AttachmentUpload struct {
+	Filename string
+	Size     int64
+	MimeType string
+	Content  io.Reader
+}
+
 type ChecklistItem struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	TaskID      uint      `json:"task_id" gorm:"not null"`
@@ -76,21 +169,95 @@ type ChecklistItem struct {
 }
 
 type CreateTaskRequest struct {
-	Title       string       `json:"title" binding:"required"`
-	Description string       `json:"description"`
-	Priority    TaskPriority `json:"priority"`
-	DueDate     *time.Time   `json:"due_date"`
-	AssigneeID  *uint        `json:"assignee_id"`
-	LabelIDs    []uint       `json:"label_ids"`
+	Title              string          `json:"title" binding:"required"`
+	Description        string          `json:"description"`
+	Priority           TaskPriority    `json:"priority"`
+	DueDate            *time.Time      `json:"due_date"`
+	AssigneeID         *uint           `json:"assignee_id"`
+	LabelIDs           []uint          `json:"label_ids"`
+	ParentTaskID       *uint           `json:"parent_task_id"` // Must be a task in the same project
+	AutoCompleteParent bool            `json:"auto_complete_parent"`
+	Recurrence         *RecurrenceRule `json:"recurrence"`
+	EstimatedMinutes   *int            `json:"estimated_minutes"`
 }
 
+// UpdateTaskRequest changes the fields that are present; omitted fields keep
+// their values
 type UpdateTaskRequest struct {
-	Title       string       `json:"title"`
-	Description string       `json:"description"`
-	Priority    TaskPriority `json:"priority"`
-	DueDate     *time.Time   `json:"due_date"`
-	AssigneeID  *uint        `json:"assignee_id"`
-	IsCompleted *bool        `json:"is_completed"`
+	Title              *string      `json:"title"`
+	Description        *string      `json:"description"` // "" clears the description
+	Priority           TaskPriority `json:"priority"`
+	DueDate            NullableTime `json:"due_date"`    // null removes the due date
+	AssigneeID         NullableID   `json:"assignee_id"` // null unassigns the task
+	IsCompleted        *bool        `json:"is_completed"`
+	ParentTaskID       *uint        `json:"parent_task_id"` // 0 detaches the task from its parent
+	AutoCompleteParent *bool        `json:"auto_complete_parent"`
+	EstimatedMinutes   *int         `json:"estimated_minutes"` // 0 clears the estimate
+}
+
+// NullableID is an optional ID that tells an omitted JSON field apart from an
+// explicit null: Set is false when the field was omitted, and ID is nil when
+// it was null.
+type NullableID struct {
+	Set bool
+	ID  *uint
+}
+
+// SetID returns a NullableID that sets the field to id, or clears it when id
+// is nil
+func SetID(id *uint) NullableID {
+	return NullableID{Set: true, ID: id}
+}
+
+func (n *NullableID) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	n.ID = nil
+	if string(data) == "null" {
+		return nil
+	}
+
+	var id uint
+	if err := json.Unmarshal(data, &id); err != nil {
+		return err
+	}
+	n.ID = &id
+	return nil
+}
+
+func (n NullableID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.ID)
+}
+
+// NullableTime is an optional time that tells an omitted JSON field apart
+// from an explicit null, the same way NullableID does for IDs
+type NullableTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// SetTime returns a NullableTime that sets the field to t, or clears it when
+// t is nil
+func SetTime(t *time.Time) NullableTime {
+	return NullableTime{Set: true, Time: t}
+}
+
+func (n *NullableTime) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	n.Time = nil
+	if string(data) == "null" {
+		return nil
+	}
+
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	n.Time = &t
+	return nil
+}
+
+func (n NullableTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.Time)
 }
 
 type MoveTaskRequest struct {
@@ -98,10 +265,63 @@ type MoveTaskRequest struct {
 	Position int  `json:"position" binding:"gte=0"`
 }
 
+// ReorderTasksRequest lists every task of a board in its new order
+type ReorderTasksRequest struct {
+	TaskIDs []uint `json:"task_ids" binding:"required"`
+}
+
+// TransferTaskRequest moves a task to a board in a different project
+type TransferTaskRequest struct {
+	BoardID  uint `json:"board_id" binding:"required"`
+	Position int  `json:"position" binding:"gte=0"`
+}
+
+// TaskFilter narrows a project task search. Zero-valued fields match every
+// task; LabelIDs matches tasks carrying all of the given labels.
+type TaskFilter struct {
+	AssigneeID  *uint        `form:"assignee_id"`
+	LabelIDs    []uint       `form:"label_ids"`
+	Priority    TaskPriority `form:"priority"`
+	IsCompleted *bool        `form:"is_completed"`
+	Completed   *bool        `form:"completed"` // Alias of is_completed
+	DueAfter    *time.Time   `form:"due_after"`
+	DueBefore   *time.Time   `form:"due_before"`
+	Query       string       `form:"q"` // Matched against title and description, ignoring case
+}
+
+// MyTasksFilter narrows the tasks assigned to the current user. Zero-valued
+// fields match every task.
+type MyTasksFilter struct {
+	IsCompleted *bool      `form:"is_completed"`
+	DueAfter    *time.Time `form:"due_after"`
+	DueBefore   *time.Time `form:"due_before"`
+}
+
+type CreateLabelRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Color string `json:"color" binding:"required"` // Hex color, e.g. #ff8800
+}
+
+type UpdateLabelRequest struct {
+	Name  string `json:"name" binding:"max=100"`
+	Color string `json:"color"`
+}
+
 type CreateCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
+type UpdateCommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// LogTimeRequest records time spent on a task. LoggedAt defaults to now.
+type LogTimeRequest struct {
+	Minutes  int        `json:"minutes" binding:"required,gt=0"`
+	Note     string     `json:"note" binding:"max=500"`
+	LoggedAt *time.Time `json:"logged_at"`
+}
+
 type CreateChecklistItemRequest struct {
 	Title    string `json:"title" binding:"required"`
 	Position int    `json:"position"`
@@ -111,3 +331,9 @@ type UpdateChecklistItemRequest struct {
 	Title       string `json:"title"`
 	IsCompleted *bool  `json:"is_completed"`
 }
+
+// ReorderChecklistRequest lists every checklist item of a task in its new
+// order
+type ReorderChecklistRequest struct {
+	ItemIDs []uint `json:"item_ids" binding:"required"`
+}
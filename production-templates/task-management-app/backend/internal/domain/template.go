@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProjectTemplate is a named set of boards and labels new projects can start
+// from. Built-in templates have no owner; saved ones belong to the user who
+// saved them.
+type ProjectTemplate struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	OwnerID     *uint          `json:"owner_id"` // nil for built-in templates
+	Boards      TemplateBoards `json:"boards" gorm:"type:jsonb;not null"`
+	Labels      TemplateLabels `json:"labels" gorm:"type:jsonb;not null"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// IsBuiltIn reports whether the template ships with the app
+func (t *ProjectTemplate) IsBuiltIn() bool {
+	return t.OwnerID == nil
+}
+
+// TemplateBoard is a board created from a template, in template order
+type TemplateBoard struct {
+	Name     string `json:"name"`
+	WIPLimit *int   `json:"wip_limit,omitempty"`
+}
+
+// TemplateLabel is a label created from a template
+type TemplateLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// TemplateBoards is stored as a JSON array
+type TemplateBoards []TemplateBoard
+
+func (b TemplateBoards) Value() (driver.Value, error) {
+	return jsonValue(b)
+}
+
+func (b *TemplateBoards) Scan(value interface{}) error {
+	return scanJSON(value, b)
+}
+
+// TemplateLabels is stored as a JSON array
+type TemplateLabels []TemplateLabel
+
+func (l TemplateLabels) Value() (driver.Value, error) {
+	return jsonValue(l)
+}
+
+func (l *TemplateLabels) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// BuiltInProjectTemplates returns the templates every user can start from.
+// They are stored at startup, matched by name.
+func BuiltInProjectTemplates() []*ProjectTemplate {
+	return []*ProjectTemplate{
+		{
+			Name:        "Kanban",
+			Description: "A simple flow from to do to done",
+			Boards:      TemplateBoards{{Name: "To Do"}, {Name: "In Progress"}, {Name: "Done"}},
+			Labels: TemplateLabels{
+				{Name: "bug", Color: "#d73a4a"},
+				{Name: "feature", Color: "#0e8a16"},
+				{Name: "improvement", Color: "#1d76db"},
+			},
+		},
+		{
+			Name:        "Scrum",
+			Description: "Backlog and sprint boards with a review step",
+			Boards: TemplateBoards{
+				{Name: "Backlog"},
+				{Name: "Sprint"},
+				{Name: "In Progress"},
+				{Name: "Review"},
+				{Name: "Done"},
+			},
+			Labels: TemplateLabels{
+				{Name: "story", Color: "#0e8a16"},
+				{Name: "bug", Color: "#d73a4a"},
+				{Name: "spike", Color: "#fbca04"},
+			},
+		},
+		{
+			Name:        "Bug Tracking",
+			Description: "Triage and fix reported issues",
+			Boards: TemplateBoards{
+				{Name: "Reported"},
+				{Name: "Triaged"},
+				{Name: "Fixing"},
+				{Name: "Verified"},
+			},
+			Labels: TemplateLabels{
+				{Name: "critical", Color: "#b60205"},
+				{Name: "major", Color: "#d93f0b"},
+				{Name: "minor", Color: "#fbca04"},
+			},
+		},
+	}
+}
+
+// CreateProjectFromTemplateRequest creates a project with the boards and
+// labels of a template
+type CreateProjectFromTemplateRequest struct {
+	TemplateID uint `json:"template_id" binding:"required"`
+	CreateProjectRequest
+}
+
+type SaveProjectTemplateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
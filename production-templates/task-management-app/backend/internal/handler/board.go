@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/middleware"
 	"task-management-app/internal/service"
 )
 
@@ -22,19 +23,19 @@ func (h *BoardHandler) Create(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	var req domain.CreateBoardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	board, err := h.boardService.Create(uint(projectID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -45,13 +46,13 @@ func (h *BoardHandler) GetByID(c *gin.Context) {
 	userID := c.GetUint("userID")
 	boardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
 		return
 	}
 
 	board, err := h.boardService.GetByID(uint(boardID), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -62,19 +63,19 @@ func (h *BoardHandler) Update(c *gin.Context) {
 	userID := c.GetUint("userID")
 	boardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
 		return
 	}
 
 	var req domain.UpdateBoardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	board, err := h.boardService.Update(uint(boardID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -85,12 +86,12 @@ func (h *BoardHandler) Delete(c *gin.Context) {
 	userID := c.GetUint("userID")
 	boardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
 		return
 	}
 
 	if err := h.boardService.Delete(uint(boardID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -101,15 +102,37 @@ func (h *BoardHandler) ListByProject(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	boards, err := h.boardService.ListByProject(uint(projectID), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, boards)
 }
+
+func (h *BoardHandler) Reorder(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.ReorderBoardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	if err := h.boardService.Reorder(uint(projectID), userID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "boards reordered successfully"})
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-management-app/internal/domain"
+)
+
+// Task export formats
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+// exportContentTypes maps each export format to its Content-Type
+var exportContentTypes = map[string]string{
+	exportCSV:  "text/csv; charset=utf-8",
+	exportJSON: "application/json; charset=utf-8",
+}
+
+// exportFlushEvery is how many rows are written between flushes
+const exportFlushEvery = 100
+
+// taskExport is one exported task
+type taskExport struct {
+	DisplayID   string     `json:"display_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Assignee    string     `json:"assignee"` // Username; empty when unassigned
+	Priority    string     `json:"priority"`
+	DueDate     *time.Time `json:"due_date"`
+	Labels      []string   `json:"labels"`
+	IsCompleted bool       `json:"is_completed"`
+}
+
+var taskExportHeader = []string{"display_id", "title", "description", "assignee", "priority", "due_date", "labels", "is_completed"}
+
+func newTaskExport(task *domain.Task) taskExport {
+	export := taskExport{
+		DisplayID:   task.DisplayID,
+		Title:       task.Title,
+		Description: task.Description,
+		Priority:    string(task.Priority),
+		DueDate:     task.DueDate,
+		Labels:      make([]string, 0, len(task.Labels)),
+		IsCompleted: task.IsCompleted,
+	}
+	if task.Assignee != nil {
+		export.Assignee = task.Assignee.Username
+	}
+	for _, label := range task.Labels {
+		export.Labels = append(export.Labels, label.Name)
+	}
+	return export
+}
+
+// writeTasksCSV writes one row per task after a header row, flushing as it
+// goes so large boards are not buffered whole. Labels are joined with "; ".
+func writeTasksCSV(w io.Writer, tasks []*domain.Task) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(taskExportHeader); err != nil {
+		return err
+	}
+
+	for i, task := range tasks {
+		export := newTaskExport(task)
+		dueDate := ""
+		if export.DueDate != nil {
+			dueDate = export.DueDate.Format(time.RFC3339)
+		}
+		row := []string{
+			csvCell(export.DisplayID),
+			csvCell(export.Title),
+			csvCell(export.Description),
+			csvCell(export.Assignee),
+			export.Priority,
+			dueDate,
+			csvCell(strings.Join(export.Labels, "; ")),
+			strconv.FormatBool(export.IsCompleted),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		if (i+1)%exportFlushEvery == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell keeps user text from being read as a formula by spreadsheet apps
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// writeTasksJSON writes the tasks as a JSON array, encoding one element at a
+// time
+func writeTasksJSON(w io.Writer, tasks []*domain.Task) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	for i, task := range tasks {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(newTaskExport(task))
+		if err != nil {
+			return fmt.Errorf("failed to encode task %d: %w", task.ID, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
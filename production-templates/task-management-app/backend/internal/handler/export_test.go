@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"task-management-app/internal/domain"
+)
+
+func exportTestTasks() []*domain.Task {
+	due := time.Date(2024, 5, 31, 17, 0, 0, 0, time.UTC)
+	return []*domain.Task{
+		{
+			DisplayID:   "APOL-1",
+			Title:       "Write spec",
+			Description: "Covers \"export\",\nover two lines",
+			Priority:    domain.PriorityHigh,
+			DueDate:     &due,
+			Assignee:    &domain.User{Username: "alice"},
+			Labels:      []domain.Label{{Name: "docs"}, {Name: "urgent"}},
+		},
+		{
+			DisplayID:   "APOL-2",
+			Title:       "=HYPERLINK(\"http://example.com\")",
+			Priority:    domain.PriorityLow,
+			IsCompleted: true,
+		},
+	}
+}
+
+func TestWriteTasksCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTasksCSV(&buf, exportTestTasks()); err != nil {
+		t.Fatalf("writeTasksCSV() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	want := [][]string{
+		taskExportHeader,
+		{"APOL-1", "Write spec", "Covers \"export\",\nover two lines", "alice", "high", "2024-05-31T17:00:00Z", "docs; urgent", "false"},
+		{"APOL-2", "'=HYPERLINK(\"http://example.com\")", "", "", "low", "", "", "true"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestWriteTasksCSV_Flushes(t *testing.T) {
+	tasks := make([]*domain.Task, exportFlushEvery*2+1)
+	for i := range tasks {
+		tasks[i] = &domain.Task{DisplayID: fmt.Sprintf("APOL-%d", i+1), Priority: domain.PriorityMedium}
+	}
+
+	var buf bytes.Buffer
+	if err := writeTasksCSV(&buf, tasks); err != nil {
+		t.Fatalf("writeTasksCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(rows) != len(tasks)+1 {
+		t.Errorf("got %d rows, want %d plus the header", len(rows), len(tasks))
+	}
+}
+
+func TestWriteTasksJSON(t *testing.T) {
+	for _, tasks := range [][]*domain.Task{exportTestTasks(), nil} {
+		var buf bytes.Buffer
+		if err := writeTasksJSON(&buf, tasks); err != nil {
+			t.Fatalf("writeTasksJSON() error = %v", err)
+		}
+
+		var exported []taskExport
+		if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+			t.Fatalf("output %q is not a JSON array: %v", buf.String(), err)
+		}
+		if len(exported) != len(tasks) {
+			t.Fatalf("exported %d tasks, want %d", len(exported), len(tasks))
+		}
+		for i, task := range tasks {
+			if want := newTaskExport(task); !reflect.DeepEqual(exported[i].Labels, want.Labels) || exported[i].Title != want.Title {
+				t.Errorf("exported[%d] = %+v, want %+v", i, exported[i], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/middleware"
+	"task-management-app/internal/service"
+)
+
+type LabelHandler struct {
+	labelService service.LabelService
+}
+
+func NewLabelHandler(labelService service.LabelService) *LabelHandler {
+	return &LabelHandler{labelService: labelService}
+}
+
+func (h *LabelHandler) Create(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	label, err := h.labelService.Create(uint(projectID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, label)
+}
+
+func (h *LabelHandler) ListByProject(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	labels, err := h.labelService.ListByProject(uint(projectID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, labels)
+}
+
+func (h *LabelHandler) GetUsage(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	usage, err := h.labelService.GetUsage(uint(projectID), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func (h *LabelHandler) Update(c *gin.Context) {
+	userID := c.GetUint("userID")
+	labelID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid label ID"))
+		return
+	}
+
+	var req domain.UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	label, err := h.labelService.Update(uint(labelID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+func (h *LabelHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("userID")
+	labelID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid label ID"))
+		return
+	}
+
+	if err := h.labelService.Delete(uint(labelID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "label deleted successfully"})
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/middleware"
 	"task-management-app/internal/service"
 )
 
@@ -23,13 +24,13 @@ func (h *ProjectHandler) Create(c *gin.Context) {
 
 	var req domain.CreateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	project, err := h.projectService.Create(userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -40,13 +41,13 @@ func (h *ProjectHandler) GetByID(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	project, err := h.projectService.GetByID(uint(projectID), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -57,19 +58,19 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	var req domain.UpdateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	project, err := h.projectService.Update(uint(projectID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -80,12 +81,12 @@ func (h *ProjectHandler) Delete(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	if err := h.projectService.Delete(uint(projectID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -96,12 +97,12 @@ func (h *ProjectHandler) Archive(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	if err := h.projectService.Archive(uint(projectID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -112,12 +113,12 @@ func (h *ProjectHandler) Unarchive(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	if err := h.projectService.Unarchive(uint(projectID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -129,51 +130,186 @@ func (h *ProjectHandler) List(c *gin.Context) {
 
 	projects, err := h.projectService.ListUserProjects(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, projects)
 }
 
+func (h *ProjectHandler) ListTemplates(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	templates, err := h.projectService.ListTemplates(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *ProjectHandler) CreateFromTemplate(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req domain.CreateProjectFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	project, err := h.projectService.CreateFromTemplate(userID, req.TemplateID, &req.CreateProjectRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) SaveAsTemplate(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.SaveProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	template, err := h.projectService.SaveAsTemplate(uint(projectID), userID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
 func (h *ProjectHandler) AddMember(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	var req domain.AddMemberRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	if err := h.projectService.AddMember(uint(projectID), userID, &req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "member added successfully"})
 }
 
+func (h *ProjectHandler) InviteMember(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	invitation, err := h.projectService.InviteMember(uint(projectID), userID, req.Email, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func (h *ProjectHandler) AcceptInvitation(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	member, err := h.projectService.AcceptInvitation(c.Param("token"), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *ProjectHandler) CreateInvite(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	invite, err := h.projectService.CreateInvite(uint(projectID), userID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+func (h *ProjectHandler) AcceptInvite(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	member, err := h.projectService.AcceptInvite(c.Param("token"), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
 func (h *ProjectHandler) RemoveMember(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	memberUserID, err := strconv.ParseUint(c.Param("memberID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid member ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid member ID"))
 		return
 	}
 
-	if err := h.projectService.RemoveMember(uint(projectID), uint(memberUserID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Optional: hand the member's tasks to another member instead of unassigning them
+	var reassignTo *uint
+	if raw := c.Query("reassign_to"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid reassign_to user ID"))
+			return
+		}
+		target := uint(id)
+		reassignTo = &target
+	}
+
+	if err := h.projectService.RemoveMember(uint(projectID), uint(memberUserID), userID, reassignTo); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -184,41 +320,63 @@ func (h *ProjectHandler) UpdateMemberRole(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	memberUserID, err := strconv.ParseUint(c.Param("memberID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid member ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid member ID"))
 		return
 	}
 
 	var req domain.UpdateMemberRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	if err := h.projectService.UpdateMemberRole(uint(projectID), uint(memberUserID), userID, &req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "member role updated successfully"})
 }
 
+func (h *ProjectHandler) TransferOwnership(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var req domain.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	if err := h.projectService.TransferOwnership(uint(projectID), req.UserID, userID); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "ownership transferred successfully"})
+}
+
 func (h *ProjectHandler) GetMembers(c *gin.Context) {
 	userID := c.GetUint("userID")
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
 	members, err := h.projectService.GetMembers(uint(projectID), userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
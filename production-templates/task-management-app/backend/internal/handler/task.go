@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/middleware"
 	"task-management-app/internal/service"
 )
 
@@ -22,19 +25,19 @@ func (h *TaskHandler) Create(c *gin.Context) {
 	userID := c.GetUint("userID")
 	boardID, err := strconv.ParseUint(c.Param("boardID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
 		return
 	}
 
 	var req domain.CreateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	task, err := h.taskService.Create(uint(boardID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -45,13 +48,13 @@ func (h *TaskHandler) GetByID(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	task, err := h.taskService.GetByID(uint(taskID), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -62,19 +65,19 @@ func (h *TaskHandler) Update(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	var req domain.UpdateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	task, err := h.taskService.Update(uint(taskID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -85,113 +88,360 @@ func (h *TaskHandler) Delete(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	if err := h.taskService.Delete(uint(taskID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "task deleted successfully"})
 }
 
+func (h *TaskHandler) AssignToMe(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	task, err := h.taskService.AssignToMe(uint(taskID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *TaskHandler) Unassign(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	task, err := h.taskService.Unassign(uint(taskID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
 func (h *TaskHandler) Move(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	var req domain.MoveTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	if err := h.taskService.Move(uint(taskID), userID, &req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "task moved successfully"})
 }
 
+func (h *TaskHandler) Reorder(c *gin.Context) {
+	userID := c.GetUint("userID")
+	boardID, err := strconv.ParseUint(c.Param("boardID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
+		return
+	}
+
+	var req domain.ReorderTasksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	if err := h.taskService.Reorder(uint(boardID), userID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tasks reordered successfully"})
+}
+
+func (h *TaskHandler) Transfer(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	var req domain.TransferTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	task, err := h.taskService.Transfer(uint(taskID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
 func (h *TaskHandler) ListByBoard(c *gin.Context) {
 	userID := c.GetUint("userID")
 	boardID, err := strconv.ParseUint(c.Param("boardID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
 		return
 	}
 
 	tasks, err := h.taskService.ListByBoard(uint(boardID), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, tasks)
 }
 
+// ExportBoard downloads the board's tasks as CSV or JSON, chosen with the
+// format query parameter
+func (h *TaskHandler) ExportBoard(c *gin.Context) {
+	userID := c.GetUint("userID")
+	boardID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid board ID"))
+		return
+	}
+
+	format := c.DefaultQuery("format", exportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "format must be csv or json"))
+		return
+	}
+
+	tasks, err := h.taskService.ListByBoard(uint(boardID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%d-tasks.%s"`, boardID, format))
+	c.Status(http.StatusOK)
+
+	write := writeTasksCSV
+	if format == exportJSON {
+		write = writeTasksJSON
+	}
+	if err := write(c.Writer, tasks); err != nil {
+		// Headers are already sent, so the download is left truncated
+		c.Error(err)
+	}
+}
+
 func (h *TaskHandler) AddComment(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	var req domain.CreateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	comment, err := h.taskService.AddComment(uint(taskID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusCreated, comment)
 }
 
+func (h *TaskHandler) LogTime(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	var req domain.LogTimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	entry, err := h.taskService.LogTime(uint(taskID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *TaskHandler) GetTimeLogs(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	logs, err := h.taskService.GetTimeLogs(uint(taskID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+func (h *TaskHandler) UpdateComment(c *gin.Context) {
+	userID := c.GetUint("userID")
+	commentID, err := strconv.ParseUint(c.Param("commentID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid comment ID"))
+		return
+	}
+
+	var req domain.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	comment, err := h.taskService.UpdateComment(uint(commentID), userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
 func (h *TaskHandler) DeleteComment(c *gin.Context) {
 	userID := c.GetUint("userID")
 	commentID, err := strconv.ParseUint(c.Param("commentID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid comment ID"))
 		return
 	}
 
 	if err := h.taskService.DeleteComment(uint(commentID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "comment deleted successfully"})
 }
 
+func (h *TaskHandler) AddAttachment(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "file is required"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "failed to read file"))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.taskService.AddAttachment(uint(taskID), userID, &domain.AttachmentUpload{
+		Filename: fileHeader.Filename,
+		Size:     fileHeader.Size,
+		MimeType: fileHeader.Header.Get("Content-Type"),
+		Content:  file,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func (h *TaskHandler) GetAttachments(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	attachments, err := h.taskService.GetAttachments(uint(taskID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+func (h *TaskHandler) DeleteAttachment(c *gin.Context) {
+	userID := c.GetUint("userID")
+	attachmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid attachment ID"))
+		return
+	}
+
+	if err := h.taskService.DeleteAttachment(uint(attachmentID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "attachment deleted successfully"})
+}
+
 func (h *TaskHandler) AddChecklistItem(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
 	var req domain.CreateChecklistItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	item, err := h.taskService.AddChecklistItem(uint(taskID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -202,19 +452,19 @@ func (h *TaskHandler) UpdateChecklistItem(c *gin.Context) {
 	userID := c.GetUint("userID")
 	itemID, err := strconv.ParseUint(c.Param("itemID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checklist item ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid checklist item ID"))
 		return
 	}
 
 	var req domain.UpdateChecklistItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	item, err := h.taskService.UpdateChecklistItem(uint(itemID), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
@@ -225,23 +475,67 @@ func (h *TaskHandler) DeleteChecklistItem(c *gin.Context) {
 	userID := c.GetUint("userID")
 	itemID, err := strconv.ParseUint(c.Param("itemID"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid checklist item ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid checklist item ID"))
 		return
 	}
 
 	if err := h.taskService.DeleteChecklistItem(uint(itemID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "checklist item deleted successfully"})
 }
 
+func (h *TaskHandler) ReorderChecklist(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	var req domain.ReorderChecklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	if err := h.taskService.ReorderChecklist(uint(taskID), userID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "checklist reordered successfully"})
+}
+
+func (h *TaskHandler) ConvertChecklistItem(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid checklist item ID"))
+		return
+	}
+
+	task, err := h.taskService.ConvertChecklistItem(uint(taskID), uint(itemID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, task)
+}
+
 func (h *TaskHandler) AssignLabels(c *gin.Context) {
 	userID := c.GetUint("userID")
 	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
 		return
 	}
 
@@ -249,14 +543,175 @@ func (h *TaskHandler) AssignLabels(c *gin.Context) {
 		LabelIDs []uint `json:"label_ids" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	if err := h.taskService.AssignLabels(uint(taskID), userID, req.LabelIDs); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "labels assigned successfully"})
 }
+
+func (h *TaskHandler) Search(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("projectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	var filter domain.TaskFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "limit must be between 1 and 100"))
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid offset"))
+		return
+	}
+
+	tasks, total, err := h.taskService.SearchProjectTasks(uint(projectID), userID, filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tasks":  tasks,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *TaskHandler) ListMyTasks(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var filter domain.MyTasksFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	tasks, err := h.taskService.ListAssignedToUser(userID, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+func (h *TaskHandler) GetOverdue(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	tasks, err := h.taskService.GetOverdueTasks(uint(projectID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+func (h *TaskHandler) GetProjectStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	stats, err := h.taskService.GetProjectStats(uint(projectID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *TaskHandler) GetUpcoming(c *gin.Context) {
+	userID := c.GetUint("userID")
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
+		return
+	}
+
+	within, err := time.ParseDuration(c.DefaultQuery("due_within", "24h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid due_within duration"))
+		return
+	}
+
+	tasks, err := h.taskService.GetUpcomingTasks(uint(projectID), userID, within)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+func (h *TaskHandler) GetActivity(c *gin.Context) {
+	userID := c.GetUint("userID")
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid task ID"))
+		return
+	}
+
+	activities, err := h.taskService.GetTaskActivity(uint(taskID), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, activities)
+}
+
+func (h *TaskHandler) GetMyActivity(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "limit must be between 1 and 100"))
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid offset"))
+		return
+	}
+
+	activities, total, err := h.taskService.GetUserActivity(userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, middleware.ErrorBody(c, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"activities": activities,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
@@ -0,0 +1,72 @@
+package jwtkeys
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultKeyID is assumed for tokens without a kid header, which were signed
+// before keys had IDs
+const DefaultKeyID = "default"
+
+// KeySet signs tokens with its current key and verifies tokens signed by any
+// key it still lists, so the signing key can be rotated without logging
+// everyone out. Tokens name the key that signed them in their kid header.
+type KeySet struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// New returns a key set signing with currentSecret under currentID and also
+// accepting tokens signed by the previous keys, given as key ID to secret.
+// Dropping a key from previous retires it: its tokens are rejected.
+func New(currentID, currentSecret string, previous map[string]string) *KeySet {
+	if currentID == "" {
+		currentID = DefaultKeyID
+	}
+
+	keys := make(map[string][]byte, len(previous)+1)
+	for id, secret := range previous {
+		keys[id] = []byte(secret)
+	}
+	keys[currentID] = []byte(currentSecret)
+
+	return &KeySet{currentID: currentID, keys: keys}
+}
+
+// CurrentID returns the ID of the key new tokens are signed with
+func (k *KeySet) CurrentID() string {
+	return k.currentID
+}
+
+// Sign signs the claims with the current key using HS256
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = k.currentID
+	return token.SignedString(k.keys[k.currentID])
+}
+
+// Keyfunc returns the secret of the key that signed the token, for use with
+// jwt.Parse. Tokens signed by unknown or retired keys are rejected.
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	id := DefaultKeyID
+	if kid, ok := token.Header["kid"]; ok {
+		s, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("invalid kid header")
+		}
+		id = s
+	}
+
+	secret, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	return secret, nil
+}
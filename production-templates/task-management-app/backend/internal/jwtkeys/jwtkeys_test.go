@@ -0,0 +1,79 @@
+package jwtkeys
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	before := New("2024-01", "old-secret", nil)
+	oldToken, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	// Rotate to a new key, keeping the old one for verification
+	after := New("2024-06", "new-secret", map[string]string{"2024-01": "old-secret"})
+
+	newToken, err := after.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	parsed, err := jwt.Parse(newToken, after.Keyfunc)
+	if err != nil {
+		t.Fatalf("Parse(new token) error = %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "2024-06" {
+		t.Errorf("kid = %v, want the current key 2024-06", kid)
+	}
+
+	if _, err := jwt.Parse(oldToken, after.Keyfunc); err != nil {
+		t.Errorf("Parse(token of a still listed key) error = %v, want it accepted", err)
+	}
+
+	// Retire the old key
+	retired := New("2024-06", "new-secret", nil)
+	if _, err := jwt.Parse(oldToken, retired.Keyfunc); err == nil {
+		t.Error("Parse(token of a retired key) succeeded, want it rejected")
+	}
+	if _, err := jwt.Parse(newToken, retired.Keyfunc); err != nil {
+		t.Errorf("Parse(token of the current key) error = %v", err)
+	}
+}
+
+func TestKeySet_Keyfunc(t *testing.T) {
+	keys := New("current", "secret", map[string]string{"previous": "old-secret"})
+
+	// Tokens from before keys had IDs are verified with the default key
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	if _, err := jwt.Parse(legacy, keys.Keyfunc); err == nil {
+		t.Error("Parse(legacy token) succeeded without a default key, want it rejected")
+	}
+	withDefault := New("current", "secret", map[string]string{DefaultKeyID: "legacy-secret"})
+	if _, err := jwt.Parse(legacy, withDefault.Keyfunc); err != nil {
+		t.Errorf("Parse(legacy token) error = %v, want it verified with the default key", err)
+	}
+
+	// A listed kid does not let a token signed with another secret through
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "previous"
+	forgedString, err := forged.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	if _, err := jwt.Parse(forgedString, keys.Keyfunc); err == nil {
+		t.Error("Parse(token with a mismatched kid) succeeded, want it rejected")
+	}
+}
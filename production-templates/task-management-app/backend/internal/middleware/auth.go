@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -9,51 +9,29 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/jwtkeys"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+func AuthMiddleware(keys *jwtkeys.KeySet) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+			c.JSON(http.StatusUnauthorized, ErrorBody(c, "authorization header required"))
 			c.Abort()
 			return
 		}
 
 		// Extract token from "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		tokenString, ok := BearerToken(authHeader)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, ErrorBody(c, "invalid authorization header format"))
 			c.Abort()
 			return
 		}
 
-		tokenString := parts[1]
-
-		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &domain.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		claims, err := ParseAccessToken(tokenString, keys)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			c.Abort()
-			return
-		}
-
-		claims, ok := token.Claims.(*domain.JWTClaims)
-		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-			c.Abort()
-			return
-		}
-
-		// Check if it's an access token (not refresh token)
-		if claims.TokenType != "access" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "not an access token"})
+			c.JSON(http.StatusUnauthorized, ErrorBody(c, err.Error()))
 			c.Abort()
 			return
 		}
@@ -66,3 +44,36 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>"
+// header value
+func BearerToken(authHeader string) (string, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// ParseAccessToken validates a signed access token and returns its claims.
+// Refresh tokens and tokens signed by keys no longer in the set are rejected.
+func ParseAccessToken(tokenString string, keys *jwtkeys.KeySet) (*domain.JWTClaims, error) {
+	// Parse and validate token
+	token, err := jwt.ParseWithClaims(tokenString, &domain.JWTClaims{}, keys.Keyfunc)
+
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(*domain.JWTClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token claims")
+	}
+
+	// Check if it's an access token (not refresh token)
+	if claims.TokenType != "access" {
+		return nil, errors.New("not an access token")
+	}
+
+	return claims, nil
+}
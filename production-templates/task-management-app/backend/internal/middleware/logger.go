@@ -1,13 +1,16 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func LoggerMiddleware() gin.HandlerFunc {
+// LoggerMiddleware writes one structured entry per request to logger. Server
+// errors are logged at error level and client errors at warn level.
+func LoggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -15,15 +18,27 @@ func LoggerMiddleware() gin.HandlerFunc {
 
 		c.Next()
 
-		duration := time.Since(start)
 		statusCode := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("path", path),
+			slog.Int("status", statusCode),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("request_id", GetRequestID(c)),
+		}
+		if userID, exists := c.Get("userID"); exists {
+			attrs = append(attrs, slog.Any("user_id", userID))
+		}
 
-		log.Printf("[%s] %s %s - %d - %v",
-			method,
-			path,
-			c.ClientIP(),
-			statusCode,
-			duration,
-		)
+		level := slog.LevelInfo
+		switch {
+		case statusCode >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case statusCode >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
 	}
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request's correlation ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits IDs accepted from clients to short, log-safe values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware tags each request with an ID, reusing the caller's
+// X-Request-ID so a request can be traced across services. The ID is stored
+// in the context and echoed in the response header.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestIDMiddleware assigned to the request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("requestID")
+}
+
+// ErrorBody builds the JSON body of an error response, tagged with the
+// request's ID so clients can quote it when reporting a problem
+func ErrorBody(c *gin.Context, message string) gin.H {
+	return gin.H{"error": message, "request_id": GetRequestID(c)}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate request ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
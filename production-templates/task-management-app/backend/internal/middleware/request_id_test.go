@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveRequest runs a request through RequestIDMiddleware and
+// LoggerMiddleware, returning the response, the ID the handler saw and the
+// decoded log entry
+func serveRequest(t *testing.T, requestID string) (*httptest.ResponseRecorder, string, map[string]interface{}) {
+	t.Helper()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), LoggerMiddleware(logger))
+
+	var seen string
+	router.GET("/tasks/:id", func(c *gin.Context) {
+		c.Set("userID", uint(42))
+		seen = GetRequestID(c)
+		c.JSON(http.StatusNotFound, ErrorBody(c, "task not found"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/7", nil)
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not a JSON entry: %v", logs.String(), err)
+	}
+	return w, seen, entry
+}
+
+func TestRequestIDMiddleware_Generated(t *testing.T) {
+	w, seen, entry := serveRequest(t, "")
+
+	header := w.Header().Get(RequestIDHeader)
+	if len(header) != 32 {
+		t.Fatalf("%s header = %q, want a generated 32-character ID", RequestIDHeader, header)
+	}
+	if seen != header {
+		t.Errorf("GetRequestID() = %q, want the echoed header %q", seen, header)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body["request_id"] != header || body["error"] != "task not found" {
+		t.Errorf("error body = %v, want the error tagged with request_id %q", body, header)
+	}
+
+	want := map[string]interface{}{
+		"level":      "WARN",
+		"method":     http.MethodGet,
+		"path":       "/tasks/7",
+		"status":     float64(http.StatusNotFound),
+		"request_id": header,
+		"user_id":    float64(42),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("log entry %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["latency"]; !ok {
+		t.Error("log entry has no latency")
+	}
+}
+
+func TestRequestIDMiddleware_Incoming(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{name: "valid ID is reused", incoming: "upstream-3f9c.1", reused: true},
+		{name: "unsafe ID is replaced", incoming: "bad id\ninjected", reused: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, seen, entry := serveRequest(t, tt.incoming)
+
+			header := w.Header().Get(RequestIDHeader)
+			if got := header == tt.incoming; got != tt.reused {
+				t.Errorf("%s header = %q, reused = %v, want %v", RequestIDHeader, header, got, tt.reused)
+			}
+			if seen != header || entry["request_id"] != header {
+				t.Errorf("context ID %q and logged ID %v, want both to be %q", seen, entry["request_id"], header)
+			}
+		})
+	}
+}
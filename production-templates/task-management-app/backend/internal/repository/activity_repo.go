@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"fmt"
+
+	"task-management-app/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ActivityRepository interface {
+	Create(activity *domain.TaskActivity) error
+	FindByUserID(userID uint, limit, offset int) ([]*domain.TaskActivity, int64, error)
+	FindByTaskID(taskID uint) ([]*domain.TaskActivity, error)
+}
+
+type activityRepository struct {
+	db *gorm.DB
+}
+
+func NewActivityRepository(db *gorm.DB) ActivityRepository {
+	return &activityRepository{db: db}
+}
+
+func (r *activityRepository) Create(activity *domain.TaskActivity) error {
+	if err := r.db.Create(activity).Error; err != nil {
+		return fmt.Errorf("failed to create activity: %w", err)
+	}
+	return nil
+}
+
+// FindByUserID returns the user's own activity, newest first, limited to
+// projects they are still a member of.
+func (r *activityRepository) FindByUserID(userID uint, limit, offset int) ([]*domain.TaskActivity, int64, error) {
+	var activities []*domain.TaskActivity
+	var total int64
+
+	query := r.db.Model(&domain.TaskActivity{}).
+		Joins("JOIN project_members ON project_members.project_id = task_activities.project_id AND project_members.user_id = task_activities.user_id").
+		Where("task_activities.user_id = ?", userID)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count activities: %w", err)
+	}
+
+	err := query.
+		Preload("Project").
+		Preload("Task").
+		Order("task_activities.created_at DESC, task_activities.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&activities).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find activities: %w", err)
+	}
+
+	return activities, total, nil
+}
+
+// FindByTaskID returns a task's activity log, oldest first
+func (r *activityRepository) FindByTaskID(taskID uint) ([]*domain.TaskActivity, error) {
+	var activities []*domain.TaskActivity
+	err := r.db.Where("task_id = ?", taskID).
+		Preload("User").
+		Order("created_at ASC, id ASC").
+		Find(&activities).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find task activity: %w", err)
+	}
+	return activities, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 
 	"task-management-app/internal/domain"
@@ -13,6 +14,7 @@ type BoardRepository interface {
 	FindByProjectID(projectID uint) ([]*domain.Board, error)
 	Update(board *domain.Board) error
 	Delete(id uint) error
+	Reorder(projectID uint, orderedIDs []uint) error
 }
 
 type boardRepository struct {
@@ -68,3 +70,46 @@ func (r *boardRepository) Delete(id uint) error {
 	}
 	return nil
 }
+
+// Reorder sets the positions of a project's boards to the order of
+// orderedIDs, which must list every board of the project exactly once.
+func (r *boardRepository) Reorder(projectID uint, orderedIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize concurrent reorders of the same project
+		if err := tx.Exec("UPDATE projects SET updated_at = updated_at WHERE id = ?", projectID).Error; err != nil {
+			return fmt.Errorf("failed to lock project %d: %w", projectID, err)
+		}
+
+		var current []uint
+		if err := tx.Model(&domain.Board{}).
+			Where("project_id = ?", projectID).
+			Pluck("id", &current).Error; err != nil {
+			return fmt.Errorf("failed to find boards by project: %w", err)
+		}
+
+		if len(uniqueIDs(orderedIDs)) != len(orderedIDs) {
+			return errors.New("board IDs must not repeat")
+		}
+		inProject := make(map[uint]bool, len(current))
+		for _, id := range current {
+			inProject[id] = true
+		}
+		for _, id := range orderedIDs {
+			if !inProject[id] {
+				return fmt.Errorf("board %d does not belong to project %d", id, projectID)
+			}
+		}
+		if len(orderedIDs) != len(current) {
+			return fmt.Errorf("expected all %d boards of the project, got %d", len(current), len(orderedIDs))
+		}
+
+		for i, id := range orderedIDs {
+			if err := tx.Model(&domain.Board{}).
+				Where("id = ? AND position != ?", id, i).
+				UpdateColumn("position", i).Error; err != nil {
+				return fmt.Errorf("failed to reorder boards: %w", err)
+			}
+		}
+		return nil
+	})
+}
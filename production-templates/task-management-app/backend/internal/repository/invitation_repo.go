@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"task-management-app/internal/domain"
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	// Create stores the invitation, replacing pending invitations of the same
+	// email to the same project
+	Create(invitation *domain.ProjectInvitation) error
+	FindByToken(token string) (*domain.ProjectInvitation, error)
+	// Accept marks the invitation accepted by member.UserID and adds the
+	// membership in one transaction. It fails if the invitation was accepted
+	// in the meantime.
+	Accept(invitation *domain.ProjectInvitation, member *domain.ProjectMember) error
+
+	CreateInvite(invite *domain.ProjectInvite) error
+	FindInviteByToken(token string) (*domain.ProjectInvite, error)
+	// AcceptInvite marks the invite used by member.UserID and adds the
+	// membership in one transaction. It fails if the invite was used in the
+	// meantime.
+	AcceptInvite(invite *domain.ProjectInvite, member *domain.ProjectMember) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(invitation *domain.ProjectInvitation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ? AND email = ? AND accepted_at IS NULL", invitation.ProjectID, invitation.Email).
+			Delete(&domain.ProjectInvitation{}).Error; err != nil {
+			return fmt.Errorf("failed to replace pending invitations: %w", err)
+		}
+
+		if err := tx.Create(invitation).Error; err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *invitationRepository) FindByToken(token string) (*domain.ProjectInvitation, error) {
+	var invitation domain.ProjectInvitation
+	err := r.db.Where("token = ?", token).Preload("Project").First(&invitation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("invitation not found")
+		}
+		return nil, fmt.Errorf("failed to find invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) Accept(invitation *domain.ProjectInvitation, member *domain.ProjectMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.ProjectInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Updates(map[string]interface{}{"accepted_at": now, "accepted_by_id": member.UserID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to accept invitation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invitation has already been accepted")
+		}
+
+		if err := tx.Create(member).Error; err != nil {
+			return fmt.Errorf("failed to add project member: %w", err)
+		}
+
+		invitation.AcceptedAt = &now
+		invitation.AcceptedByID = &member.UserID
+		return nil
+	})
+}
+
+func (r *invitationRepository) CreateInvite(invite *domain.ProjectInvite) error {
+	if err := r.db.Create(invite).Error; err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	return nil
+}
+
+func (r *invitationRepository) FindInviteByToken(token string) (*domain.ProjectInvite, error) {
+	var invite domain.ProjectInvite
+	err := r.db.Where("token = ?", token).First(&invite).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("invite not found")
+		}
+		return nil, fmt.Errorf("failed to find invite: %w", err)
+	}
+	return &invite, nil
+}
+
+func (r *invitationRepository) AcceptInvite(invite *domain.ProjectInvite, member *domain.ProjectMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.ProjectInvite{}).
+			Where("id = ? AND used_at IS NULL", invite.ID).
+			Updates(map[string]interface{}{"used_at": now, "used_by_id": member.UserID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to accept invite: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invite has already been used")
+		}
+
+		if err := tx.Create(member).Error; err != nil {
+			return fmt.Errorf("failed to add project member: %w", err)
+		}
+
+		invite.UsedAt = &now
+		invite.UsedByID = &member.UserID
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"fmt"
+
+	"task-management-app/internal/domain"
+	"gorm.io/gorm"
+)
+
+type LabelRepository interface {
+	Create(label *domain.Label) error
+	FindByID(id uint) (*domain.Label, error)
+	FindByProjectID(projectID uint) ([]*domain.Label, error)
+	// CountUsage returns the project's labels with how many tasks carry each
+	CountUsage(projectID uint) ([]*domain.LabelUsage, error)
+	Update(label *domain.Label) error
+	// Delete removes the label together with its task assignments
+	Delete(id uint) error
+}
+
+type labelRepository struct {
+	db *gorm.DB
+}
+
+func NewLabelRepository(db *gorm.DB) LabelRepository {
+	return &labelRepository{db: db}
+}
+
+func (r *labelRepository) Create(label *domain.Label) error {
+	if err := r.db.Create(label).Error; err != nil {
+		return fmt.Errorf("failed to create label: %w", err)
+	}
+	return nil
+}
+
+func (r *labelRepository) FindByID(id uint) (*domain.Label, error) {
+	var label domain.Label
+	err := r.db.First(&label, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("label not found with id %d", id)
+		}
+		return nil, fmt.Errorf("failed to find label: %w", err)
+	}
+	return &label, nil
+}
+
+func (r *labelRepository) FindByProjectID(projectID uint) ([]*domain.Label, error) {
+	var labels []*domain.Label
+	err := r.db.Where("project_id = ?", projectID).
+		Order("name ASC").
+		Find(&labels).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find labels by project: %w", err)
+	}
+	return labels, nil
+}
+
+func (r *labelRepository) CountUsage(projectID uint) ([]*domain.LabelUsage, error) {
+	var usage []*domain.LabelUsage
+	err := r.db.Model(&domain.Label{}).
+		Select("labels.*, COUNT(task_labels.task_id) AS task_count").
+		Joins("LEFT JOIN task_labels ON task_labels.label_id = labels.id").
+		Where("labels.project_id = ?", projectID).
+		Group("labels.id").
+		Order("labels.name ASC").
+		Scan(&usage).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to count label usage: %w", err)
+	}
+	return usage, nil
+}
+
+func (r *labelRepository) Update(label *domain.Label) error {
+	if err := r.db.Save(label).Error; err != nil {
+		return fmt.Errorf("failed to update label: %w", err)
+	}
+	return nil
+}
+
+func (r *labelRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The join table cascades in postgres, but clear it explicitly so the
+		// behaviour does not depend on foreign key enforcement
+		if err := tx.Exec("DELETE FROM task_labels WHERE label_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to remove label from tasks: %w", err)
+		}
+		if err := tx.Delete(&domain.Label{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete label: %w", err)
+		}
+		return nil
+	})
+}
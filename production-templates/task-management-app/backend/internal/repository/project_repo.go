@@ -2,9 +2,11 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"task-management-app/internal/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProjectRepository interface {
@@ -14,10 +16,15 @@ type ProjectRepository interface {
 	Update(project *domain.Project) error
 	Delete(id uint) error
 	AddMember(member *domain.ProjectMember) error
-	RemoveMember(projectID, userID uint) error
+	RemoveMember(projectID, userID uint, reassignTo *uint) error
 	UpdateMember(member *domain.ProjectMember) error
 	GetMember(projectID, userID uint) (*domain.ProjectMember, error)
 	GetMembers(projectID uint) ([]domain.ProjectMember, error)
+	TransferOwnership(projectID, fromUserID, toUserID uint) error
+	FindOwnershipViolations() ([]*domain.Project, error)
+	ResetOwner(projectID, ownerID uint) error
+	FindStale(cutoff time.Time) ([]*domain.Project, error)
+	NextTaskNumber(projectID uint) (int, string, error)
 }
 
 type projectRepository struct {
@@ -85,13 +92,26 @@ func (r *projectRepository) AddMember(member *domain.ProjectMember) error {
 	return nil
 }
 
-func (r *projectRepository) RemoveMember(projectID, userID uint) error {
-	err := r.db.Where("project_id = ? AND user_id = ?", projectID, userID).
-		Delete(&domain.ProjectMember{}).Error
-	if err != nil {
-		return fmt.Errorf("failed to remove project member: %w", err)
-	}
-	return nil
+// RemoveMember deletes the membership and, in the same transaction, hands the
+// member's tasks in this project to reassignTo. A nil reassignTo leaves those
+// tasks unassigned.
+func (r *projectRepository) RemoveMember(projectID, userID uint, reassignTo *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		projectBoards := tx.Model(&domain.Board{}).Select("id").Where("project_id = ?", projectID)
+
+		if err := tx.Model(&domain.Task{}).
+			Where("assignee_id = ? AND board_id IN (?)", userID, projectBoards).
+			Update("assignee_id", reassignTo).Error; err != nil {
+			return fmt.Errorf("failed to reassign member tasks: %w", err)
+		}
+
+		if err := tx.Where("project_id = ? AND user_id = ?", projectID, userID).
+			Delete(&domain.ProjectMember{}).Error; err != nil {
+			return fmt.Errorf("failed to remove project member: %w", err)
+		}
+
+		return nil
+	})
 }
 
 func (r *projectRepository) UpdateMember(member *domain.ProjectMember) error {
@@ -122,3 +142,113 @@ func (r *projectRepository) GetMembers(projectID uint) ([]domain.ProjectMember,
 	}
 	return members, nil
 }
+
+// TransferOwnership makes toUserID the sole owner of the project and demotes
+// fromUserID to admin, all in one transaction.
+func (r *projectRepository) TransferOwnership(projectID, fromUserID, toUserID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", projectID, fromUserID).
+			Update("role", domain.ProjectRoleAdmin).Error; err != nil {
+			return fmt.Errorf("failed to demote current owner: %w", err)
+		}
+
+		if err := tx.Model(&domain.ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", projectID, toUserID).
+			Update("role", domain.ProjectRoleOwner).Error; err != nil {
+			return fmt.Errorf("failed to promote new owner: %w", err)
+		}
+
+		if err := tx.Model(&domain.Project{}).
+			Where("id = ?", projectID).
+			Update("owner_id", toUserID).Error; err != nil {
+			return fmt.Errorf("failed to update project owner: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// FindOwnershipViolations returns projects that do not have exactly one owner
+// member, or whose owner member is not the project's OwnerID.
+func (r *projectRepository) FindOwnershipViolations() ([]*domain.Project, error) {
+	ownerCounts := r.db.Model(&domain.ProjectMember{}).
+		Select("project_id, COUNT(*) AS owners, MAX(user_id) AS owner_user_id").
+		Where("role = ?", domain.ProjectRoleOwner).
+		Group("project_id")
+
+	var projects []*domain.Project
+	err := r.db.Model(&domain.Project{}).
+		Joins("LEFT JOIN (?) AS oc ON oc.project_id = projects.id", ownerCounts).
+		Where("oc.owners IS NULL OR oc.owners <> 1 OR oc.owner_user_id <> projects.owner_id").
+		Find(&projects).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ownership violations: %w", err)
+	}
+	return projects, nil
+}
+
+// ResetOwner makes ownerID the only owner member of the project, demoting any
+// other owners to admin and re-adding ownerID if they are no longer a member.
+func (r *projectRepository) ResetOwner(projectID, ownerID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.ProjectMember{}).
+			Where("project_id = ? AND user_id <> ? AND role = ?", projectID, ownerID, domain.ProjectRoleOwner).
+			Update("role", domain.ProjectRoleAdmin).Error; err != nil {
+			return fmt.Errorf("failed to demote extra owners: %w", err)
+		}
+
+		var member domain.ProjectMember
+		err := tx.Where("project_id = ? AND user_id = ?", projectID, ownerID).First(&member).Error
+		if err == gorm.ErrRecordNotFound {
+			member = domain.ProjectMember{ProjectID: projectID, UserID: ownerID}
+		} else if err != nil {
+			return fmt.Errorf("failed to get owner membership: %w", err)
+		}
+
+		member.Role = domain.ProjectRoleOwner
+		if err := tx.Save(&member).Error; err != nil {
+			return fmt.Errorf("failed to restore owner: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// FindStale returns unarchived projects created before cutoff with no task
+// activity and no task updates since. Projects flagged NeverAutoArchive are
+// skipped.
+func (r *projectRepository) FindStale(cutoff time.Time) ([]*domain.Project, error) {
+	var projects []*domain.Project
+	err := r.db.
+		Where("is_archived = ? AND never_auto_archive = ? AND created_at < ?", false, false, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM task_activities WHERE task_activities.project_id = projects.id AND task_activities.created_at >= ?)", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM tasks JOIN boards ON boards.id = tasks.board_id WHERE boards.project_id = projects.id AND tasks.updated_at >= ?)", cutoff).
+		Order("id").
+		Find(&projects).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stale projects: %w", err)
+	}
+	return projects, nil
+}
+
+// NextTaskNumber atomically increments the project's task counter and returns
+// the new number along with the project key. The increment happens in a
+// single UPDATE, so concurrent callers never receive the same number.
+func (r *projectRepository) NextTaskNumber(projectID uint) (int, string, error) {
+	var project domain.Project
+	result := r.db.Model(&project).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "task_counter"}, {Name: "key"}}}).
+		Where("id = ?", projectID).
+		UpdateColumn("task_counter", gorm.Expr("task_counter + 1"))
+
+	if result.Error != nil {
+		return 0, "", fmt.Errorf("failed to allocate task number: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, "", fmt.Errorf("project not found with id %d", projectID)
+	}
+	return project.TaskCounter, project.Key, nil
+}
@@ -1,31 +1,61 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"task-management-app/internal/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TaskRepository interface {
 	Create(task *domain.Task) error
 	FindByID(id uint) (*domain.Task, error)
+	FindParentID(id uint) (*uint, error)
 	FindByBoardID(boardID uint) ([]*domain.Task, error)
+	CountActiveByBoardID(boardID uint) (int64, error)
 	FindByProjectID(projectID uint) ([]*domain.Task, error)
+	Search(projectID uint, filter *domain.TaskFilter, limit, offset int) ([]*domain.Task, int64, error)
+	FindAssignedToUser(userID uint, filter *domain.MyTasksFilter) ([]*domain.Task, error)
+	FindOverdue(projectID uint) ([]*domain.Task, error)
+	FindDueBetween(projectID uint, from, to time.Time) ([]*domain.Task, error)
+	ClaimDueReminders(now time.Time) ([]*domain.Task, error)
+	// Project aggregates
+	CountByCompletion(projectID uint) (open, completed int64, err error)
+	CountByPriority(projectID uint) (map[domain.TaskPriority]int64, error)
+	CountByAssignee(projectID uint) ([]*domain.AssigneeTaskCount, error)
+	CountOverdue(projectID uint, now time.Time) (int64, error)
+	CountCompletedSince(projectID uint, since time.Time) (int64, error)
+	AverageCompletionTime(projectID uint) (time.Duration, int64, error)
+	SumTime(projectID uint) (estimated, logged int64, err error)
+	ClaimDueSoonReminders(now, until time.Time) ([]*domain.Task, error)
 	Update(task *domain.Task) error
 	Delete(id uint) error
 	Move(taskID, boardID uint, position int) error
+	Reorder(boardID uint, orderedIDs []uint) error
+	Transfer(task *domain.Task, labelIDs []uint) error
 	AddComment(comment *domain.Comment) error
 	GetComment(commentID uint) (*domain.Comment, error)
+	UpdateComment(comment *domain.Comment) error
 	DeleteComment(commentID uint) error
 	GetComments(taskID uint) ([]*domain.Comment, error)
+	CreateCommentMentions(mentions []*domain.CommentMention) error
+	AddTimeLog(entry *domain.TimeLog) error
+	GetTimeLogs(taskID uint) ([]*domain.TimeLog, error)
 	AddAttachment(attachment *domain.Attachment) error
 	GetAttachments(taskID uint) ([]*domain.Attachment, error)
+	GetAttachment(attachmentID uint) (*domain.Attachment, error)
+	DeleteAttachment(attachmentID uint) error
 	AddChecklistItem(item *domain.ChecklistItem) error
 	GetChecklistItem(itemID uint) (*domain.ChecklistItem, error)
 	UpdateChecklistItem(item *domain.ChecklistItem) error
 	DeleteChecklistItem(id uint) error
+	ReorderChecklist(taskID uint, orderedIDs []uint) error
 	AssignLabels(taskID uint, labelIDs []uint) error
+	FindLabelsByProjectID(projectID uint) ([]*domain.Label, error)
 }
 
 type taskRepository struct {
@@ -36,11 +66,24 @@ func NewTaskRepository(db *gorm.DB) TaskRepository {
 	return &taskRepository{db: db}
 }
 
+// Create appends the task to the end of its board, ignoring task.Position
 func (r *taskRepository) Create(task *domain.Task) error {
-	if err := r.db.Create(task).Error; err != nil {
-		return fmt.Errorf("failed to create task: %w", err)
-	}
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, task.BoardID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&domain.Task{}).Where("board_id = ?", task.BoardID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count tasks: %w", err)
+		}
+		task.Position = int(count)
+
+		if err := tx.Create(task).Error; err != nil {
+			return fmt.Errorf("failed to create task: %w", err)
+		}
+		return nil
+	})
 }
 
 func (r *taskRepository) FindByID(id uint) (*domain.Task, error) {
@@ -53,6 +96,9 @@ func (r *taskRepository) FindByID(id uint) (*domain.Task, error) {
 		Preload("Comments.User").
 		Preload("Attachments.User").
 		Preload("Checklist").
+		Preload("Subtasks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
 		First(&task, id).Error
 
 	if err != nil {
@@ -64,6 +110,18 @@ func (r *taskRepository) FindByID(id uint) (*domain.Task, error) {
 	return &task, nil
 }
 
+// FindParentID returns the ID of the task's parent, or nil for a top-level task
+func (r *taskRepository) FindParentID(id uint) (*uint, error) {
+	var task domain.Task
+	if err := r.db.Select("id", "parent_task_id").First(&task, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("task not found with id %d", id)
+		}
+		return nil, fmt.Errorf("failed to find task: %w", err)
+	}
+	return task.ParentTaskID, nil
+}
+
 func (r *taskRepository) FindByBoardID(boardID uint) ([]*domain.Task, error) {
 	var tasks []*domain.Task
 	err := r.db.Where("board_id = ?", boardID).
@@ -79,55 +137,365 @@ func (r *taskRepository) FindByBoardID(boardID uint) ([]*domain.Task, error) {
 	return tasks, nil
 }
 
+// CountActiveByBoardID counts the board's tasks that are not completed
+func (r *taskRepository) CountActiveByBoardID(boardID uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&domain.Task{}).
+		Where("board_id = ? AND is_completed = ?", boardID, false).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	return count, nil
+}
+
 func (r *taskRepository) FindByProjectID(projectID uint) ([]*domain.Task, error) {
 	var tasks []*domain.Task
-	err := r.db.
+	err := r.projectTasks(projectID).Find(&tasks).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tasks by project: %w", err)
+	}
+	return tasks, nil
+}
+
+// searchOrder sorts search results by due date, undated tasks last, then by
+// board and task position
+const searchOrder = "CASE WHEN tasks.due_date IS NULL THEN 1 ELSE 0 END, tasks.due_date ASC, " +
+	"boards.position ASC, tasks.position ASC, tasks.id ASC"
+
+// Search returns a page of the project's tasks matching filter, along with
+// the total number of matches. When filter.Query is set, tasks whose title
+// matches come before those matching only in the description; ties are
+// broken by due date, undated tasks last, then board and task position.
+func (r *taskRepository) Search(projectID uint, filter *domain.TaskFilter, limit, offset int) ([]*domain.Task, int64, error) {
+	query := r.projectTasks(projectID).Model(&domain.Task{})
+
+	if filter.AssigneeID != nil {
+		query = query.Where("tasks.assignee_id = ?", *filter.AssigneeID)
+	}
+	if len(filter.LabelIDs) > 0 {
+		labelIDs := uniqueIDs(filter.LabelIDs)
+		query = query.Where(
+			"tasks.id IN (SELECT task_id FROM task_labels WHERE label_id IN ? GROUP BY task_id HAVING COUNT(DISTINCT label_id) = ?)",
+			labelIDs, len(labelIDs),
+		)
+	}
+	if filter.Priority != "" {
+		query = query.Where("tasks.priority = ?", filter.Priority)
+	}
+	if filter.IsCompleted != nil {
+		query = query.Where("tasks.is_completed = ?", *filter.IsCompleted)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("tasks.due_date >= ?", *filter.DueAfter)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("tasks.due_date <= ?", *filter.DueBefore)
+	}
+
+	// ORDER BY is built as a single expression, since gorm drops an
+	// expression once plain columns are added to the same clause
+	order := clause.Expr{SQL: searchOrder, WithoutParentheses: true}
+	if text := strings.ToLower(strings.TrimSpace(filter.Query)); text != "" {
+		pattern := "%" + escapeLike(text) + "%"
+		query = query.Where(
+			`(LOWER(tasks.title) LIKE ? ESCAPE '\' OR LOWER(tasks.description) LIKE ? ESCAPE '\')`,
+			pattern, pattern,
+		)
+		// Exact title, title prefix, title substring, description only
+		order = clause.Expr{
+			SQL: `CASE WHEN LOWER(tasks.title) = ? THEN 0 WHEN LOWER(tasks.title) LIKE ? ESCAPE '\' THEN 1 ` +
+				`WHEN LOWER(tasks.title) LIKE ? ESCAPE '\' THEN 2 ELSE 3 END, ` + searchOrder,
+			Vars:               []interface{}{text, escapeLike(text) + "%", pattern},
+			WithoutParentheses: true,
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+
+	var tasks []*domain.Task
+	err := query.
+		Clauses(clause.OrderBy{Expression: order}).
+		Limit(limit).
+		Offset(offset).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	return tasks, total, nil
+}
+
+// FindAssignedToUser returns the user's tasks in projects they are a member of,
+// soonest due first with undated tasks last. Each task carries its board and
+// the board's project.
+func (r *taskRepository) FindAssignedToUser(userID uint, filter *domain.MyTasksFilter) ([]*domain.Task, error) {
+	query := r.db.
 		Joins("JOIN boards ON tasks.board_id = boards.id").
-		Where("boards.project_id = ?", projectID).
-		Preload("Board").
-		Preload("Creator").
-		Preload("Assignee").
+		Joins("JOIN project_members ON project_members.project_id = boards.project_id AND project_members.user_id = ?", userID).
+		Where("tasks.assignee_id = ?", userID)
+
+	if filter.IsCompleted != nil {
+		query = query.Where("tasks.is_completed = ?", *filter.IsCompleted)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("tasks.due_date >= ?", *filter.DueAfter)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("tasks.due_date <= ?", *filter.DueBefore)
+	}
+
+	var tasks []*domain.Task
+	err := query.
+		Preload("Board.Project").
 		Preload("Labels").
+		Order("CASE WHEN tasks.due_date IS NULL THEN 1 ELSE 0 END").
+		Order("tasks.due_date ASC").
+		Order("tasks.id ASC").
 		Find(&tasks).Error
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to find tasks by project: %w", err)
+		return nil, fmt.Errorf("failed to find assigned tasks: %w", err)
 	}
 	return tasks, nil
 }
 
+// FindOverdue returns the project's incomplete tasks whose due date has
+// passed, most overdue first
+func (r *taskRepository) FindOverdue(projectID uint) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	err := r.projectTasks(projectID).
+		Where("tasks.is_completed = ? AND tasks.due_date < ?", false, time.Now()).
+		Order("tasks.due_date ASC").
+		Order("tasks.id ASC").
+		Find(&tasks).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find overdue tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// FindDueBetween returns the project's incomplete tasks due in [from, to),
+// soonest first
+func (r *taskRepository) FindDueBetween(projectID uint, from, to time.Time) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	err := r.projectTasks(projectID).
+		Where("tasks.is_completed = ? AND tasks.due_date >= ? AND tasks.due_date < ?", false, from, to).
+		Order("tasks.due_date ASC").
+		Order("tasks.id ASC").
+		Find(&tasks).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tasks due between %s and %s: %w", from, to, err)
+	}
+	return tasks, nil
+}
+
+// ClaimDueReminders marks every incomplete task that has reached its due date
+// and not been reminded about yet, and returns them with their board. Each
+// task is claimed with its own conditional update, so concurrent schedulers
+// never claim the same task twice.
+func (r *taskRepository) ClaimDueReminders(now time.Time) ([]*domain.Task, error) {
+	var candidates []*domain.Task
+	if err := r.db.
+		Where("is_completed = ? AND due_date <= ? AND due_notified_at IS NULL", false, now).
+		Preload("Board").
+		Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to find due tasks: %w", err)
+	}
+
+	claimed := make([]*domain.Task, 0, len(candidates))
+	for _, task := range candidates {
+		result := r.db.Model(&domain.Task{}).
+			Where("id = ? AND due_notified_at IS NULL", task.ID).
+			UpdateColumn("due_notified_at", now)
+		if result.Error != nil {
+			return claimed, fmt.Errorf("failed to mark task %d as reminded: %w", task.ID, result.Error)
+		}
+		if result.RowsAffected == 1 {
+			task.DueNotifiedAt = &now
+			claimed = append(claimed, task)
+		}
+	}
+	return claimed, nil
+}
+
+// ClaimDueSoonReminders marks every incomplete, assigned task due in
+// [now, until) that has not had a due-soon reminder yet, and returns them with
+// their board. Tasks are claimed like in ClaimDueReminders.
+func (r *taskRepository) ClaimDueSoonReminders(now, until time.Time) ([]*domain.Task, error) {
+	var candidates []*domain.Task
+	if err := r.db.
+		Where("is_completed = ? AND assignee_id IS NOT NULL AND due_date >= ? AND due_date < ? AND reminder_sent_at IS NULL", false, now, until).
+		Preload("Board").
+		Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to find tasks due soon: %w", err)
+	}
+
+	claimed := make([]*domain.Task, 0, len(candidates))
+	for _, task := range candidates {
+		result := r.db.Model(&domain.Task{}).
+			Where("id = ? AND reminder_sent_at IS NULL", task.ID).
+			UpdateColumn("reminder_sent_at", now)
+		if result.Error != nil {
+			return claimed, fmt.Errorf("failed to mark task %d as reminded: %w", task.ID, result.Error)
+		}
+		if result.RowsAffected == 1 {
+			task.ReminderSentAt = &now
+			claimed = append(claimed, task)
+		}
+	}
+	return claimed, nil
+}
+
 func (r *taskRepository) Update(task *domain.Task) error {
-	if err := r.db.Save(task).Error; err != nil {
+	// Subtasks are attached through their own parent_task_id, never from
+	// here, and logged_minutes is only ever incremented by AddTimeLog
+	if err := r.db.Omit("Subtasks", "LoggedMinutes").Save(task).Error; err != nil {
 		return fmt.Errorf("failed to update task: %w", err)
 	}
 	return nil
 }
 
 func (r *taskRepository) Delete(id uint) error {
-	if err := r.db.Delete(&domain.Task{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete task: %w", err)
-	}
-	return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var task domain.Task
+		if err := tx.Select("id", "board_id").First(&task, id).Error; err != nil {
+			return fmt.Errorf("failed to find task: %w", err)
+		}
+		if err := lockBoard(tx, task.BoardID); err != nil {
+			return err
+		}
+
+		// Subtasks outlive their parent as top-level tasks
+		if err := tx.Model(&domain.Task{}).
+			Where("parent_task_id = ?", id).
+			UpdateColumn("parent_task_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to detach subtasks: %w", err)
+		}
+
+		if err := tx.Delete(&domain.Task{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete task: %w", err)
+		}
+
+		// Close the gap left in the board
+		return placeTask(tx, task.BoardID, 0, 0)
+	})
 }
 
+// Move puts the task at position in the target board, shifting the tasks
+// from there on down, and closes the gap it leaves in the source board.
+// Positions past the end of the board append the task.
 func (r *taskRepository) Move(taskID, boardID uint, position int) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// Update task board and position
+		var task domain.Task
+		if err := tx.Select("id", "board_id").First(&task, taskID).Error; err != nil {
+			return fmt.Errorf("failed to find task: %w", err)
+		}
+		sourceBoardID := task.BoardID
+
+		if err := lockBoards(tx, sourceBoardID, boardID); err != nil {
+			return err
+		}
+
 		if err := tx.Model(&domain.Task{}).
 			Where("id = ?", taskID).
+			Update("board_id", boardID).Error; err != nil {
+			return fmt.Errorf("failed to move task: %w", err)
+		}
+
+		if err := placeTask(tx, boardID, taskID, position); err != nil {
+			return err
+		}
+		if sourceBoardID != boardID {
+			return placeTask(tx, sourceBoardID, 0, 0)
+		}
+		return nil
+	})
+}
+
+// Reorder sets the positions of a board's tasks to the order of orderedIDs,
+// which must list every task on the board exactly once.
+func (r *taskRepository) Reorder(boardID uint, orderedIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBoard(tx, boardID); err != nil {
+			return err
+		}
+
+		var current []uint
+		if err := tx.Model(&domain.Task{}).
+			Where("board_id = ?", boardID).
+			Pluck("id", &current).Error; err != nil {
+			return fmt.Errorf("failed to find tasks by board: %w", err)
+		}
+
+		if len(uniqueIDs(orderedIDs)) != len(orderedIDs) {
+			return errors.New("task IDs must not repeat")
+		}
+		onBoard := make(map[uint]bool, len(current))
+		for _, id := range current {
+			onBoard[id] = true
+		}
+		for _, id := range orderedIDs {
+			if !onBoard[id] {
+				return fmt.Errorf("task %d is not on board %d", id, boardID)
+			}
+		}
+		if len(orderedIDs) != len(current) {
+			return fmt.Errorf("expected all %d tasks of the board, got %d", len(current), len(orderedIDs))
+		}
+
+		return setPositions(tx, orderedIDs)
+	})
+}
+
+// Transfer persists a task's new board, position, number and assignee and
+// replaces its labels in one transaction.
+func (r *taskRepository) Transfer(task *domain.Task, labelIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current domain.Task
+		if err := tx.Select("id", "board_id").First(&current, task.ID).Error; err != nil {
+			return fmt.Errorf("failed to find task: %w", err)
+		}
+		if err := lockBoards(tx, current.BoardID, task.BoardID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&domain.Task{}).
+			Where("id = ?", task.ID).
 			Updates(map[string]interface{}{
-				"board_id": boardID,
-				"position": position,
+				"board_id":    task.BoardID,
+				"position":    task.Position,
+				"number":      task.Number,
+				"display_id":  task.DisplayID,
+				"assignee_id": task.AssigneeID,
 			}).Error; err != nil {
-			return fmt.Errorf("failed to move task: %w", err)
+			return fmt.Errorf("failed to transfer task: %w", err)
 		}
 
-		// Reorder other tasks in the target board
-		if err := tx.Exec(
-			"UPDATE tasks SET position = position + 1 WHERE board_id = ? AND id != ? AND position >= ?",
-			boardID, taskID, position,
-		).Error; err != nil {
-			return fmt.Errorf("failed to reorder tasks: %w", err)
+		// Slot the task into the target board and close the gap in the source
+		if err := placeTask(tx, task.BoardID, task.ID, task.Position); err != nil {
+			return err
+		}
+		if err := placeTask(tx, current.BoardID, 0, 0); err != nil {
+			return err
+		}
+
+		// Replace the labels with their counterparts in the target project
+		if err := tx.Model(&domain.Task{ID: task.ID}).Association("Labels").Clear(); err != nil {
+			return fmt.Errorf("failed to clear labels: %w", err)
+		}
+		if len(labelIDs) > 0 {
+			var targetLabels []domain.Label
+			if err := tx.Find(&targetLabels, labelIDs).Error; err != nil {
+				return fmt.Errorf("failed to find labels: %w", err)
+			}
+			if err := tx.Model(&domain.Task{ID: task.ID}).Association("Labels").Append(&targetLabels); err != nil {
+				return fmt.Errorf("failed to assign labels: %w", err)
+			}
 		}
 
 		return nil
@@ -154,6 +522,13 @@ func (r *taskRepository) GetComment(commentID uint) (*domain.Comment, error) {
 	return &comment, nil
 }
 
+func (r *taskRepository) UpdateComment(comment *domain.Comment) error {
+	if err := r.db.Omit("User").Save(comment).Error; err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
 func (r *taskRepository) DeleteComment(commentID uint) error {
 	if err := r.db.Delete(&domain.Comment{}, commentID).Error; err != nil {
 		return fmt.Errorf("failed to delete comment: %w", err)
@@ -174,11 +549,57 @@ func (r *taskRepository) GetComments(taskID uint) ([]*domain.Comment, error) {
 	return comments, nil
 }
 
+func (r *taskRepository) CreateCommentMentions(mentions []*domain.CommentMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	if err := r.db.Create(mentions).Error; err != nil {
+		return fmt.Errorf("failed to create comment mentions: %w", err)
+	}
+	return nil
+}
+
+// AddTimeLog stores the entry and adds its minutes to the task's
+// logged_minutes
+func (r *taskRepository) AddTimeLog(entry *domain.TimeLog) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("failed to add time log: %w", err)
+		}
+		if err := tx.Model(&domain.Task{}).
+			Where("id = ?", entry.TaskID).
+			UpdateColumn("logged_minutes", gorm.Expr("logged_minutes + ?", entry.Minutes)).Error; err != nil {
+			return fmt.Errorf("failed to update logged time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Reload entry with user
+	return r.db.Preload("User").First(entry, entry.ID).Error
+}
+
+// GetTimeLogs lists the task's time logs, most recent work first
+func (r *taskRepository) GetTimeLogs(taskID uint) ([]*domain.TimeLog, error) {
+	var entries []*domain.TimeLog
+	err := r.db.Where("task_id = ?", taskID).
+		Preload("User").
+		Order("logged_at DESC, id DESC").
+		Find(&entries).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time logs: %w", err)
+	}
+	return entries, nil
+}
+
 func (r *taskRepository) AddAttachment(attachment *domain.Attachment) error {
 	if err := r.db.Create(attachment).Error; err != nil {
 		return fmt.Errorf("failed to add attachment: %w", err)
 	}
-	return nil
+	// Reload attachment with user
+	return r.db.Preload("User").First(attachment, attachment.ID).Error
 }
 
 func (r *taskRepository) GetAttachments(taskID uint) ([]*domain.Attachment, error) {
@@ -194,6 +615,25 @@ func (r *taskRepository) GetAttachments(taskID uint) ([]*domain.Attachment, erro
 	return attachments, nil
 }
 
+func (r *taskRepository) GetAttachment(attachmentID uint) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	err := r.db.Preload("User").First(&attachment, attachmentID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("attachment not found with id %d", attachmentID)
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+func (r *taskRepository) DeleteAttachment(attachmentID uint) error {
+	if err := r.db.Delete(&domain.Attachment{}, attachmentID).Error; err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
 func (r *taskRepository) AddChecklistItem(item *domain.ChecklistItem) error {
 	if err := r.db.Create(item).Error; err != nil {
 		return fmt.Errorf("failed to add checklist item: %w", err)
@@ -227,6 +667,49 @@ func (r *taskRepository) DeleteChecklistItem(id uint) error {
 	return nil
 }
 
+// ReorderChecklist sets the positions of a task's checklist items to the
+// order of orderedIDs, which must list every item of the task exactly once.
+func (r *taskRepository) ReorderChecklist(taskID uint, orderedIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize concurrent reorders of the same checklist
+		if err := tx.Exec("UPDATE tasks SET updated_at = updated_at WHERE id = ?", taskID).Error; err != nil {
+			return fmt.Errorf("failed to lock task %d: %w", taskID, err)
+		}
+
+		var current []uint
+		if err := tx.Model(&domain.ChecklistItem{}).
+			Where("task_id = ?", taskID).
+			Pluck("id", &current).Error; err != nil {
+			return fmt.Errorf("failed to find checklist items: %w", err)
+		}
+
+		if len(uniqueIDs(orderedIDs)) != len(orderedIDs) {
+			return errors.New("checklist item IDs must not repeat")
+		}
+		onTask := make(map[uint]bool, len(current))
+		for _, id := range current {
+			onTask[id] = true
+		}
+		for _, id := range orderedIDs {
+			if !onTask[id] {
+				return fmt.Errorf("checklist item %d does not belong to task %d", id, taskID)
+			}
+		}
+		if len(orderedIDs) != len(current) {
+			return fmt.Errorf("expected all %d checklist items of the task, got %d", len(current), len(orderedIDs))
+		}
+
+		for i, id := range orderedIDs {
+			if err := tx.Model(&domain.ChecklistItem{}).
+				Where("id = ? AND position != ?", id, i).
+				UpdateColumn("position", i).Error; err != nil {
+				return fmt.Errorf("failed to reorder checklist: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 func (r *taskRepository) AssignLabels(taskID uint, labelIDs []uint) error {
 	var task domain.Task
 	if err := r.db.First(&task, taskID).Error; err != nil {
@@ -251,3 +734,232 @@ func (r *taskRepository) AssignLabels(taskID uint, labelIDs []uint) error {
 
 	return nil
 }
+
+func (r *taskRepository) FindLabelsByProjectID(projectID uint) ([]*domain.Label, error) {
+	var labels []*domain.Label
+	if err := r.db.Where("project_id = ?", projectID).Order("name ASC").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to find labels: %w", err)
+	}
+	return labels, nil
+}
+
+// lockBoard serializes position changes on a board by writing its row, which
+// holds a row lock in postgres and the database write lock in sqlite until the
+// transaction ends.
+func lockBoard(tx *gorm.DB, boardID uint) error {
+	if err := tx.Exec("UPDATE boards SET updated_at = updated_at WHERE id = ?", boardID).Error; err != nil {
+		return fmt.Errorf("failed to lock board %d: %w", boardID, err)
+	}
+	return nil
+}
+
+// lockBoards locks two boards in ID order so concurrent moves in opposite
+// directions cannot deadlock
+func lockBoards(tx *gorm.DB, a, b uint) error {
+	if a > b {
+		a, b = b, a
+	}
+	if err := lockBoard(tx, a); err != nil {
+		return err
+	}
+	if a == b {
+		return nil
+	}
+	return lockBoard(tx, b)
+}
+
+// placeTask renumbers a board's tasks to 0..n-1, keeping their current order
+// and putting taskID at position. With taskID 0 it only closes gaps.
+func placeTask(tx *gorm.DB, boardID, taskID uint, position int) error {
+	var ids []uint
+	if err := tx.Model(&domain.Task{}).
+		Where("board_id = ? AND id != ?", boardID, taskID).
+		Order("position ASC, id ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to find tasks by board: %w", err)
+	}
+
+	if taskID != 0 {
+		if position < 0 {
+			position = 0
+		}
+		if position > len(ids) {
+			position = len(ids)
+		}
+		ids = append(ids[:position], append([]uint{taskID}, ids[position:]...)...)
+	}
+
+	return setPositions(tx, ids)
+}
+
+// setPositions gives each task its index in ids as position
+func setPositions(tx *gorm.DB, ids []uint) error {
+	for i, id := range ids {
+		if err := tx.Model(&domain.Task{}).
+			Where("id = ? AND position != ?", id, i).
+			UpdateColumn("position", i).Error; err != nil {
+			return fmt.Errorf("failed to reorder tasks: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *taskRepository) CountByCompletion(projectID uint) (int64, int64, error) {
+	var rows []struct {
+		IsCompleted bool
+		Count       int64
+	}
+	err := r.projectTaskRows(projectID).
+		Select("tasks.is_completed, COUNT(*) AS count").
+		Group("tasks.is_completed").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count tasks by completion: %w", err)
+	}
+
+	var open, completed int64
+	for _, row := range rows {
+		if row.IsCompleted {
+			completed = row.Count
+		} else {
+			open = row.Count
+		}
+	}
+	return open, completed, nil
+}
+
+func (r *taskRepository) CountByPriority(projectID uint) (map[domain.TaskPriority]int64, error) {
+	var rows []struct {
+		Priority domain.TaskPriority
+		Count    int64
+	}
+	err := r.projectTaskRows(projectID).
+		Select("tasks.priority, COUNT(*) AS count").
+		Group("tasks.priority").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks by priority: %w", err)
+	}
+
+	counts := make(map[domain.TaskPriority]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Priority] = row.Count
+	}
+	return counts, nil
+}
+
+// CountByAssignee counts the project's tasks per assignee, most tasks first,
+// with unassigned tasks under a nil AssigneeID
+func (r *taskRepository) CountByAssignee(projectID uint) ([]*domain.AssigneeTaskCount, error) {
+	var counts []*domain.AssigneeTaskCount
+	err := r.projectTaskRows(projectID).
+		Select("tasks.assignee_id, users.username, COUNT(*) AS count").
+		Joins("LEFT JOIN users ON users.id = tasks.assignee_id").
+		Group("tasks.assignee_id, users.username").
+		Order("count DESC, tasks.assignee_id ASC").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks by assignee: %w", err)
+	}
+	return counts, nil
+}
+
+func (r *taskRepository) CountOverdue(projectID uint, now time.Time) (int64, error) {
+	var count int64
+	err := r.projectTaskRows(projectID).
+		Where("tasks.is_completed = ? AND tasks.due_date < ?", false, now).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count overdue tasks: %w", err)
+	}
+	return count, nil
+}
+
+func (r *taskRepository) CountCompletedSince(projectID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.projectTaskRows(projectID).
+		Where("tasks.is_completed = ? AND tasks.completed_at >= ?", true, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count completed tasks: %w", err)
+	}
+	return count, nil
+}
+
+// AverageCompletionTime averages CompletedAt - CreatedAt over the project's
+// completed tasks and returns it with the number of tasks averaged. Date
+// arithmetic differs between databases, so the average is taken here.
+func (r *taskRepository) AverageCompletionTime(projectID uint) (time.Duration, int64, error) {
+	var rows []struct {
+		CreatedAt   time.Time
+		CompletedAt time.Time
+	}
+	err := r.projectTaskRows(projectID).
+		Select("tasks.created_at, tasks.completed_at").
+		Where("tasks.is_completed = ? AND tasks.completed_at IS NOT NULL", true).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load completion times: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+
+	var total time.Duration
+	for _, row := range rows {
+		total += row.CompletedAt.Sub(row.CreatedAt)
+	}
+	return total / time.Duration(len(rows)), int64(len(rows)), nil
+}
+
+// SumTime totals the estimates and logged time of the project's tasks
+func (r *taskRepository) SumTime(projectID uint) (int64, int64, error) {
+	var row struct {
+		Estimated int64
+		Logged    int64
+	}
+	err := r.projectTaskRows(projectID).
+		Select("COALESCE(SUM(tasks.estimated_minutes), 0) AS estimated, COALESCE(SUM(tasks.logged_minutes), 0) AS logged").
+		Scan(&row).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum task time: %w", err)
+	}
+	return row.Estimated, row.Logged, nil
+}
+
+// projectTaskRows selects the project's task rows without loading relations,
+// for aggregates
+func (r *taskRepository) projectTaskRows(projectID uint) *gorm.DB {
+	return r.db.Model(&domain.Task{}).
+		Joins("JOIN boards ON tasks.board_id = boards.id").
+		Where("boards.project_id = ?", projectID)
+}
+
+// projectTasks scopes a query to the tasks on any board of the project
+func (r *taskRepository) projectTasks(projectID uint) *gorm.DB {
+	return r.db.
+		Joins("JOIN boards ON tasks.board_id = boards.id").
+		Where("boards.project_id = ?", projectID).
+		Preload("Board").
+		Preload("Creator").
+		Preload("Assignee").
+		Preload("Labels")
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// uniqueIDs returns ids without duplicates, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"fmt"
+
+	"task-management-app/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ProjectTemplateRepository interface {
+	Create(template *domain.ProjectTemplate) error
+	FindByID(id uint) (*domain.ProjectTemplate, error)
+	// FindAvailable returns the built-in templates followed by those saved by
+	// the user
+	FindAvailable(userID uint) ([]*domain.ProjectTemplate, error)
+	// EnsureBuiltIns stores the built-in templates, updating those already
+	// stored under the same name
+	EnsureBuiltIns(templates []*domain.ProjectTemplate) error
+}
+
+type projectTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewProjectTemplateRepository(db *gorm.DB) ProjectTemplateRepository {
+	return &projectTemplateRepository{db: db}
+}
+
+func (r *projectTemplateRepository) Create(template *domain.ProjectTemplate) error {
+	if err := r.db.Create(template).Error; err != nil {
+		return fmt.Errorf("failed to create project template: %w", err)
+	}
+	return nil
+}
+
+func (r *projectTemplateRepository) FindByID(id uint) (*domain.ProjectTemplate, error) {
+	var template domain.ProjectTemplate
+	err := r.db.First(&template, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("project template not found with id %d", id)
+		}
+		return nil, fmt.Errorf("failed to find project template: %w", err)
+	}
+	return &template, nil
+}
+
+func (r *projectTemplateRepository) FindAvailable(userID uint) ([]*domain.ProjectTemplate, error) {
+	var templates []*domain.ProjectTemplate
+	err := r.db.Where("owner_id IS NULL OR owner_id = ?", userID).
+		Order("owner_id IS NOT NULL, name ASC, id ASC").
+		Find(&templates).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project templates: %w", err)
+	}
+	return templates, nil
+}
+
+func (r *projectTemplateRepository) EnsureBuiltIns(templates []*domain.ProjectTemplate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, template := range templates {
+			var existing domain.ProjectTemplate
+			err := tx.Where("owner_id IS NULL AND name = ?", template.Name).First(&existing).Error
+			if err == gorm.ErrRecordNotFound {
+				if err := tx.Create(template).Error; err != nil {
+					return fmt.Errorf("failed to create template %q: %w", template.Name, err)
+				}
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to find template %q: %w", template.Name, err)
+			}
+
+			template.ID = existing.ID
+			template.CreatedAt = existing.CreatedAt
+			if err := tx.Save(template).Error; err != nil {
+				return fmt.Errorf("failed to update template %q: %w", template.Name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Mode selects how user content is made safe to render as HTML
+type Mode string
+
+const (
+	// ModeEscape escapes every HTML metacharacter
+	ModeEscape Mode = "escape"
+
+	// ModeMarkdown escapes raw HTML but keeps markdown syntax (emphasis,
+	// code, blockquotes, links) intact. Links with script-capable schemes
+	// are rewritten to "#".
+	ModeMarkdown Mode = "markdown"
+)
+
+// ParseMode converts a configuration value to a Mode, falling back to
+// ModeEscape for anything unrecognized.
+func ParseMode(s string) Mode {
+	switch Mode(strings.ToLower(strings.TrimSpace(s))) {
+	case ModeMarkdown:
+		return ModeMarkdown
+	default:
+		return ModeEscape
+	}
+}
+
+// Sanitizer neutralizes HTML in user-submitted content before it is stored
+type Sanitizer struct {
+	mode Mode
+}
+
+func NewSanitizer(mode Mode) *Sanitizer {
+	return &Sanitizer{mode: mode}
+}
+
+// Sanitize returns content made safe according to the sanitizer's mode
+func (s *Sanitizer) Sanitize(content string) string {
+	if s.mode == ModeMarkdown {
+		return sanitizeMarkdown(content)
+	}
+	return html.EscapeString(content)
+}
+
+var (
+	// Leading ">" markers of a blockquote line, optionally indented
+	blockquotePrefix = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)+`)
+
+	// Target of an inline markdown link or image: ](target)
+	linkTarget = regexp.MustCompile(`\]\(([^)]*)\)`)
+
+	markupEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+	unsafeSchemes = []string{"javascript:", "vbscript:", "data:"}
+)
+
+func sanitizeMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		prefix := blockquotePrefix.FindString(line)
+		lines[i] = prefix + escapeMarkup(line[len(prefix):])
+	}
+
+	return linkTarget.ReplaceAllStringFunc(strings.Join(lines, "\n"), func(match string) string {
+		target := match[2 : len(match)-1]
+		if isUnsafeURL(target) {
+			return "](#)"
+		}
+		return match
+	})
+}
+
+// escapeMarkup escapes the characters that can open a tag or an entity.
+// Quotes are left alone: without a tag there is no attribute to break out of.
+func escapeMarkup(s string) string {
+	return markupEscaper.Replace(s)
+}
+
+// isUnsafeURL reports whether target uses a scheme that can run script.
+// Entities are decoded (the target has already been escaped once) and
+// whitespace and control characters are ignored, since browsers skip them
+// when parsing the scheme.
+func isUnsafeURL(target string) bool {
+	decoded := target
+	for i := 0; i < 3; i++ {
+		next := html.UnescapeString(decoded)
+		if next == decoded {
+			break
+		}
+		decoded = next
+	}
+
+	var b strings.Builder
+	for _, r := range decoded {
+		if r <= ' ' || r == 0x7f {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	normalized := strings.ToLower(b.String())
+
+	for _, scheme := range unsafeSchemes {
+		if strings.HasPrefix(normalized, scheme) {
+			return true
+		}
+	}
+	return false
+}
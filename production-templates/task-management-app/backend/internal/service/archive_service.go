@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+	"task-management-app/internal/websocket"
+)
+
+type ArchiveService interface {
+	// ArchiveStale archives every project without task activity for the
+	// configured period and returns the IDs of the projects it archived.
+	ArchiveStale(now time.Time) ([]uint, error)
+	// Run calls ArchiveStale every interval. It never returns.
+	Run(interval time.Duration)
+}
+
+type archiveService struct {
+	projectRepo repository.ProjectRepository
+	hub         *websocket.Hub
+	staleAfter  time.Duration
+}
+
+func NewArchiveService(
+	projectRepo repository.ProjectRepository,
+	hub *websocket.Hub,
+	staleAfter time.Duration,
+) ArchiveService {
+	return &archiveService{
+		projectRepo: projectRepo,
+		hub:         hub,
+		staleAfter:  staleAfter,
+	}
+}
+
+func (s *archiveService) ArchiveStale(now time.Time) ([]uint, error) {
+	cutoff := now.Add(-s.staleAfter)
+
+	projects, err := s.projectRepo.FindStale(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := make([]uint, 0, len(projects))
+	for _, project := range projects {
+		project.IsArchived = true
+		if err := s.projectRepo.Update(project); err != nil {
+			return archived, fmt.Errorf("failed to archive project %d: %w", project.ID, err)
+		}
+		archived = append(archived, project.ID)
+
+		s.notifyArchived(project, cutoff)
+	}
+
+	return archived, nil
+}
+
+func (s *archiveService) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		archived, err := s.ArchiveStale(now)
+		if err != nil {
+			log.Printf("Failed to archive stale projects: %v", err)
+		}
+		if len(archived) > 0 {
+			log.Printf("Archived %d inactive projects: %v", len(archived), archived)
+		}
+	}
+}
+
+func (s *archiveService) notifyArchived(project *domain.Project, inactiveSince time.Time) {
+	if s.hub == nil {
+		return
+	}
+
+	notice := &domain.AutoArchiveNotice{
+		ProjectID:     project.ID,
+		InactiveSince: inactiveSince,
+		AdminIDs:      []uint{project.OwnerID},
+	}
+
+	members, err := s.projectRepo.GetMembers(project.ID)
+	if err != nil {
+		log.Printf("Failed to load members for project %d: %v", project.ID, err)
+	}
+	for _, member := range members {
+		if member.Role == domain.ProjectRoleAdmin {
+			notice.AdminIDs = append(notice.AdminIDs, member.UserID)
+		}
+	}
+
+	s.hub.Broadcast(&websocket.Message{
+		Type:      websocket.TypeProjectArchived,
+		ProjectID: project.ID,
+		Payload:   notice,
+	})
+}
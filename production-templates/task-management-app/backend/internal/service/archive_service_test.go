@@ -0,0 +1,70 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+)
+
+func TestArchiveService_ArchiveStale(t *testing.T) {
+	db := setupTestDB(t)
+	projectRepo := repository.NewProjectRepository(db)
+	archiveService := NewArchiveService(projectRepo, nil, 24*time.Hour)
+
+	owner := createTestUser(t, db, "owner")
+	longAgo := time.Now().Add(-72 * time.Hour)
+
+	// ageProject backdates a project and everything in it so that only the
+	// activity a test adds afterwards counts as recent.
+	ageProject := func(project *domain.Project) {
+		db.Model(&domain.Project{}).Where("id = ?", project.ID).UpdateColumn("created_at", longAgo)
+		db.Exec("UPDATE tasks SET updated_at = ? WHERE board_id IN (SELECT id FROM boards WHERE project_id = ?)", longAgo, project.ID)
+	}
+
+	idle := createTestProject(t, db, "Idle", owner)
+	idleBoard := createTestBoard(t, db, idle.ID, "Todo")
+	createTestTask(t, db, idleBoard.ID, owner.ID, nil)
+	ageProject(idle)
+
+	updated := createTestProject(t, db, "Updated", owner)
+	updatedBoard := createTestBoard(t, db, updated.ID, "Todo")
+	ageProject(updated)
+	createTestTask(t, db, updatedBoard.ID, owner.ID, nil)
+
+	discussed := createTestProject(t, db, "Discussed", owner)
+	discussedBoard := createTestBoard(t, db, discussed.ID, "Todo")
+	discussedTask := createTestTask(t, db, discussedBoard.ID, owner.ID, nil)
+	ageProject(discussed)
+	db.Create(&domain.TaskActivity{ProjectID: discussed.ID, TaskID: discussedTask.ID, UserID: owner.ID, Action: domain.ActivityCommentAdded})
+
+	exempt := createTestProject(t, db, "Exempt", owner)
+	db.Model(exempt).Update("never_auto_archive", true)
+	ageProject(exempt)
+
+	fresh := createTestProject(t, db, "Fresh", owner)
+
+	archived, err := archiveService.ArchiveStale(time.Now())
+	if err != nil {
+		t.Fatalf("ArchiveStale() error = %v", err)
+	}
+	if len(archived) != 1 || archived[0] != idle.ID {
+		t.Errorf("ArchiveStale() archived %v, want only project %d", archived, idle.ID)
+	}
+
+	for _, project := range []*domain.Project{idle, updated, discussed, exempt, fresh} {
+		reloaded, err := projectRepo.FindByID(project.ID)
+		if err != nil {
+			t.Fatalf("FindByID() error = %v", err)
+		}
+		if want := project.ID == idle.ID; reloaded.IsArchived != want {
+			t.Errorf("project %q IsArchived = %v, want %v", project.Name, reloaded.IsArchived, want)
+		}
+	}
+
+	// Archived projects are not picked up again
+	if again, err := archiveService.ArchiveStale(time.Now()); err != nil || len(again) != 0 {
+		t.Errorf("second ArchiveStale() = %v, %v, want nothing archived", again, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/jwtkeys"
 	"task-management-app/internal/repository"
 )
 
@@ -21,14 +22,14 @@ type AuthService interface {
 
 type authService struct {
 	userRepo      repository.UserRepository
-	jwtSecret     string
+	keys          *jwtkeys.KeySet
 	jwtExpiration time.Duration
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string, jwtExpiration time.Duration) AuthService {
+func NewAuthService(userRepo repository.UserRepository, keys *jwtkeys.KeySet, jwtExpiration time.Duration) AuthService {
 	return &authService{
 		userRepo:      userRepo,
-		jwtSecret:     jwtSecret,
+		keys:          keys,
 		jwtExpiration: jwtExpiration,
 	}
 }
@@ -133,12 +134,7 @@ func (s *authService) Login(req *domain.LoginRequest) (*domain.AuthResponse, err
 
 func (s *authService) RefreshToken(refreshToken string) (*domain.AuthResponse, error) {
 	// Parse and validate refresh token
-	token, err := jwt.ParseWithClaims(refreshToken, &domain.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(refreshToken, &domain.JWTClaims{}, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
@@ -206,8 +202,7 @@ func (s *authService) generateAccessToken(user *domain.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.keys.Sign(claims)
 }
 
 func (s *authService) generateRefreshToken(user *domain.User) (string, error) {
@@ -222,6 +217,5 @@ func (s *authService) generateRefreshToken(user *domain.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.keys.Sign(claims)
 }
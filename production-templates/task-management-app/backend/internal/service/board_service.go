@@ -15,6 +15,7 @@ type BoardService interface {
 	Update(boardID, userID uint, req *domain.UpdateBoardRequest) (*domain.Board, error)
 	Delete(boardID, userID uint) error
 	ListByProject(projectID, userID uint) ([]*domain.Board, error)
+	Reorder(projectID, userID uint, req *domain.ReorderBoardsRequest) error
 }
 
 type boardService struct {
@@ -53,9 +54,10 @@ func (s *boardService) Create(projectID, userID uint, req *domain.CreateBoardReq
 	}
 
 	board := &domain.Board{
-		ProjectID: projectID,
-		Name:      req.Name,
-		Position:  position,
+		ProjectID:   projectID,
+		Name:        req.Name,
+		Position:    position,
+		CreatedByID: &userID,
 	}
 
 	if err := s.boardRepo.Create(board); err != nil {
@@ -79,6 +81,7 @@ func (s *boardService) GetByID(boardID, userID uint) (*domain.Board, error) {
 		return nil, err
 	}
 
+	board.ComputeActiveTaskCount()
 	return board, nil
 }
 
@@ -100,10 +103,19 @@ func (s *boardService) Update(boardID, userID uint, req *domain.UpdateBoardReque
 	if req.Position != nil {
 		board.Position = *req.Position
 	}
+	if req.WIPLimit != nil {
+		if *req.WIPLimit == 0 {
+			board.WIPLimit = nil
+		} else {
+			limit := *req.WIPLimit
+			board.WIPLimit = &limit
+		}
+	}
 
 	if err := s.boardRepo.Update(board); err != nil {
 		return nil, fmt.Errorf("failed to update board: %w", err)
 	}
+	board.ComputeActiveTaskCount()
 
 	// Broadcast via WebSocket
 	s.broadcastBoardEvent(board.ProjectID, userID, "BOARD_UPDATED", board)
@@ -146,9 +158,31 @@ func (s *boardService) ListByProject(projectID, userID uint) ([]*domain.Board, e
 		return nil, fmt.Errorf("failed to list boards: %w", err)
 	}
 
+	for _, board := range boards {
+		board.ComputeActiveTaskCount()
+	}
 	return boards, nil
 }
 
+func (s *boardService) Reorder(projectID, userID uint, req *domain.ReorderBoardsRequest) error {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleMember); err != nil {
+		return err
+	}
+
+	if err := s.boardRepo.Reorder(projectID, req.BoardIDs); err != nil {
+		return fmt.Errorf("failed to reorder boards: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastBoardEvent(projectID, userID, "BOARDS_REORDERED", map[string]interface{}{
+		"project_id": projectID,
+		"board_ids":  req.BoardIDs,
+	})
+
+	return nil
+}
+
 // Helper methods
 
 func (s *boardService) checkProjectAccess(projectID, userID uint, requiredRole domain.ProjectRole) error {
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+)
+
+func TestBoardService_Create_RecordsCreator(t *testing.T) {
+	db := setupTestDB(t)
+	boardService := NewBoardService(repository.NewBoardRepository(db), repository.NewProjectRepository(db), nil)
+
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+
+	board, err := boardService.Create(project.ID, member.ID, &domain.CreateBoardRequest{Name: "Todo"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if board.CreatedByID == nil || *board.CreatedByID != member.ID {
+		t.Errorf("CreatedByID = %v, want %d", board.CreatedByID, member.ID)
+	}
+
+	reloaded, err := boardService.GetByID(board.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if reloaded.CreatedByID == nil || *reloaded.CreatedByID != member.ID {
+		t.Errorf("stored CreatedByID = %v, want %d", reloaded.CreatedByID, member.ID)
+	}
+}
+
+func TestBoardService_Reorder(t *testing.T) {
+	db := setupTestDB(t)
+	boardService := NewBoardService(repository.NewBoardRepository(db), repository.NewProjectRepository(db), nil)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+
+	var ids []uint
+	for i, name := range []string{"Todo", "Doing", "Done"} {
+		board, err := boardService.Create(project.ID, owner.ID, &domain.CreateBoardRequest{Name: name, Position: i})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids = append(ids, board.ID)
+	}
+	stranger := createTestBoard(t, db, createTestProject(t, db, "Gemini", owner).ID, "Todo")
+
+	positions := func() []uint {
+		t.Helper()
+		boards, err := boardService.ListByProject(project.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("ListByProject() error = %v", err)
+		}
+		var order []uint
+		for _, board := range boards {
+			order = append(order, board.ID)
+		}
+		return order
+	}
+
+	rejected := []struct {
+		name     string
+		userID   uint
+		boardIDs []uint
+	}{
+		{name: "viewer", userID: viewer.ID, boardIDs: []uint{ids[2], ids[1], ids[0]}},
+		{name: "missing board", userID: owner.ID, boardIDs: []uint{ids[2], ids[1]}},
+		{name: "duplicate board", userID: owner.ID, boardIDs: []uint{ids[2], ids[2], ids[1], ids[0]}},
+		{name: "board from another project", userID: owner.ID, boardIDs: []uint{ids[2], ids[1], stranger.ID}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := boardService.Reorder(project.ID, tt.userID, &domain.ReorderBoardsRequest{BoardIDs: tt.boardIDs}); err == nil {
+				t.Error("Reorder() should fail")
+			}
+			if got := positions(); fmt.Sprint(got) != fmt.Sprint(ids) {
+				t.Errorf("boards = %v after rejected reorder, want %v", got, ids)
+			}
+		})
+	}
+
+	want := []uint{ids[2], ids[0], ids[1]}
+	if err := boardService.Reorder(project.ID, owner.ID, &domain.ReorderBoardsRequest{BoardIDs: want}); err != nil {
+		t.Fatalf("Reorder() error = %v", err)
+	}
+	if got := positions(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("boards = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+	"task-management-app/internal/websocket"
+)
+
+// labelColorPattern accepts #rgb and #rrggbb hex colors
+var labelColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+type LabelService interface {
+	Create(projectID, userID uint, req *domain.CreateLabelRequest) (*domain.Label, error)
+	ListByProject(projectID, userID uint) ([]*domain.Label, error)
+	GetUsage(projectID, userID uint) ([]*domain.LabelUsage, error)
+	Update(labelID, userID uint, req *domain.UpdateLabelRequest) (*domain.Label, error)
+	Delete(labelID, userID uint) error
+}
+
+type labelService struct {
+	labelRepo   repository.LabelRepository
+	projectRepo repository.ProjectRepository
+	hub         *websocket.Hub
+}
+
+func NewLabelService(
+	labelRepo repository.LabelRepository,
+	projectRepo repository.ProjectRepository,
+	hub *websocket.Hub,
+) LabelService {
+	return &labelService{
+		labelRepo:   labelRepo,
+		projectRepo: projectRepo,
+		hub:         hub,
+	}
+}
+
+func (s *labelService) Create(projectID, userID uint, req *domain.CreateLabelRequest) (*domain.Label, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("label name is required")
+	}
+	if err := validateLabelColor(req.Color); err != nil {
+		return nil, err
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNameAvailable(projectID, 0, name); err != nil {
+		return nil, err
+	}
+
+	label := &domain.Label{
+		ProjectID:   projectID,
+		Name:        name,
+		Color:       req.Color,
+		CreatedByID: &userID,
+	}
+
+	if err := s.labelRepo.Create(label); err != nil {
+		return nil, fmt.Errorf("failed to create label: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastLabelEvent(projectID, userID, "LABEL_CREATED", label)
+
+	return label, nil
+}
+
+func (s *labelService) ListByProject(projectID, userID uint) ([]*domain.Label, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	labels, err := s.labelRepo.FindByProjectID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+func (s *labelService) GetUsage(projectID, userID uint) ([]*domain.LabelUsage, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.labelRepo.CountUsage(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (s *labelService) Update(labelID, userID uint, req *domain.UpdateLabelRequest) (*domain.Label, error) {
+	label, err := s.labelRepo.FindByID(labelID)
+	if err != nil {
+		return nil, fmt.Errorf("label not found: %w", err)
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(label.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if err := s.checkNameAvailable(label.ProjectID, label.ID, name); err != nil {
+			return nil, err
+		}
+		label.Name = name
+	}
+	if req.Color != "" {
+		if err := validateLabelColor(req.Color); err != nil {
+			return nil, err
+		}
+		label.Color = req.Color
+	}
+
+	if err := s.labelRepo.Update(label); err != nil {
+		return nil, fmt.Errorf("failed to update label: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastLabelEvent(label.ProjectID, userID, "LABEL_UPDATED", label)
+
+	return label, nil
+}
+
+func (s *labelService) Delete(labelID, userID uint) error {
+	label, err := s.labelRepo.FindByID(labelID)
+	if err != nil {
+		return fmt.Errorf("label not found: %w", err)
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(label.ProjectID, userID, domain.ProjectRoleAdmin); err != nil {
+		return err
+	}
+
+	if err := s.labelRepo.Delete(labelID); err != nil {
+		return fmt.Errorf("failed to delete label: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastLabelEvent(label.ProjectID, userID, "LABEL_DELETED", map[string]interface{}{
+		"id":         labelID,
+		"project_id": label.ProjectID,
+	})
+
+	return nil
+}
+
+// Helper methods
+
+func validateLabelColor(color string) error {
+	if !labelColorPattern.MatchString(color) {
+		return fmt.Errorf("invalid label color %q: must be a hex color such as #ff8800", color)
+	}
+	return nil
+}
+
+// checkNameAvailable rejects a name already used by another label in the
+// project, ignoring case. exceptID is the label being renamed, if any.
+func (s *labelService) checkNameAvailable(projectID, exceptID uint, name string) error {
+	labels, err := s.labelRepo.FindByProjectID(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to load labels: %w", err)
+	}
+
+	for _, label := range labels {
+		if label.ID != exceptID && strings.EqualFold(label.Name, name) {
+			return fmt.Errorf("label %q already exists in this project", label.Name)
+		}
+	}
+	return nil
+}
+
+func (s *labelService) checkProjectAccess(projectID, userID uint, requiredRole domain.ProjectRole) error {
+	member, err := s.projectRepo.GetMember(projectID, userID)
+	if err != nil {
+		return errors.New("access denied: user is not a member of this project")
+	}
+
+	// Check role hierarchy
+	roleHierarchy := map[domain.ProjectRole]int{
+		domain.ProjectRoleViewer: 1,
+		domain.ProjectRoleMember: 2,
+		domain.ProjectRoleAdmin:  3,
+		domain.ProjectRoleOwner:  4,
+	}
+
+	if roleHierarchy[member.Role] < roleHierarchy[requiredRole] {
+		return fmt.Errorf("insufficient permissions: required %s role", requiredRole)
+	}
+
+	return nil
+}
+
+func (s *labelService) broadcastLabelEvent(projectID, userID uint, eventType string, data interface{}) {
+	if s.hub != nil {
+		message := &websocket.Message{
+			Type:      websocket.MessageType(eventType),
+			ProjectID: projectID,
+			UserID:    userID,
+			Payload:   data,
+		}
+		s.hub.Broadcast(message)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+)
+
+func newTestLabelService(db *gorm.DB) LabelService {
+	return NewLabelService(repository.NewLabelRepository(db), repository.NewProjectRepository(db), nil)
+}
+
+func TestLabelService_Create(t *testing.T) {
+	db := setupTestDB(t)
+	labelService := newTestLabelService(db)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+
+	if _, err := labelService.Create(project.ID, owner.ID, &domain.CreateLabelRequest{Name: "Bug", Color: "#d73a4a"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID uint
+		req    domain.CreateLabelRequest
+	}{
+		{name: "color without hash", userID: owner.ID, req: domain.CreateLabelRequest{Name: "Feature", Color: "a2eeef"}},
+		{name: "color name", userID: owner.ID, req: domain.CreateLabelRequest{Name: "Feature", Color: "blue"}},
+		{name: "color with bad digits", userID: owner.ID, req: domain.CreateLabelRequest{Name: "Feature", Color: "#12345g"}},
+		{name: "duplicate name", userID: owner.ID, req: domain.CreateLabelRequest{Name: "bug", Color: "#fff"}},
+		{name: "viewer", userID: viewer.ID, req: domain.CreateLabelRequest{Name: "Feature", Color: "#a2eeef"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := labelService.Create(project.ID, tt.userID, &tt.req); err == nil {
+				t.Error("Create() should fail")
+			}
+		})
+	}
+
+	labels, err := labelService.ListByProject(project.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("ListByProject() error = %v", err)
+	}
+	if len(labels) != 1 || labels[0].Name != "Bug" {
+		t.Errorf("ListByProject() = %+v, want only Bug", labels)
+	}
+}
+
+func TestLabelService_Delete(t *testing.T) {
+	db := setupTestDB(t)
+	labelService := newTestLabelService(db)
+
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Gemini", owner)
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+
+	label, err := labelService.Create(project.ID, member.ID, &domain.CreateLabelRequest{Name: "Bug", Color: "#d73a4a"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if label.CreatedByID == nil || *label.CreatedByID != member.ID {
+		t.Errorf("CreatedByID = %v, want %d", label.CreatedByID, member.ID)
+	}
+	kept, err := labelService.Create(project.ID, member.ID, &domain.CreateLabelRequest{Name: "Docs", Color: "#0075ca"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	board := createTestBoard(t, db, project.ID, "Todo")
+	task := createTestTask(t, db, board.ID, owner.ID, nil)
+	if err := db.Model(task).Association("Labels").Append(&domain.Label{ID: label.ID}, &domain.Label{ID: kept.ID}); err != nil {
+		t.Fatalf("failed to assign labels: %v", err)
+	}
+
+	if _, err := labelService.ListByProject(project.ID, outsider.ID); err == nil {
+		t.Error("ListByProject() by a non-member should fail")
+	}
+	if err := labelService.Delete(label.ID, member.ID); err == nil {
+		t.Error("Delete() by a member should fail")
+	}
+
+	if err := labelService.Delete(label.ID, owner.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	var reloaded domain.Task
+	db.Preload("Labels").First(&reloaded, task.ID)
+	if len(reloaded.Labels) != 1 || reloaded.Labels[0].ID != kept.ID {
+		t.Errorf("task labels = %+v, want only label %d", reloaded.Labels, kept.ID)
+	}
+
+	var assignments int64
+	db.Table("task_labels").Where("label_id = ?", label.ID).Count(&assignments)
+	if assignments != 0 {
+		t.Errorf("task_labels rows for deleted label = %d, want 0", assignments)
+	}
+}
+
+func TestLabelService_GetUsage(t *testing.T) {
+	db := setupTestDB(t)
+	labelService := newTestLabelService(db)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	other := createTestProject(t, db, "Gemini", owner)
+
+	newLabel := func(projectID uint, name string) *domain.Label {
+		label, err := labelService.Create(projectID, owner.ID, &domain.CreateLabelRequest{Name: name, Color: "#d73a4a"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return label
+	}
+	bug := newLabel(project.ID, "Bug")
+	docs := newLabel(project.ID, "Docs")
+	unused := newLabel(project.ID, "Unused")
+	foreign := newLabel(other.ID, "Bug")
+
+	board := createTestBoard(t, db, project.ID, "Todo")
+	otherBoard := createTestBoard(t, db, other.ID, "Todo")
+	assign := func(task *domain.Task, labels ...*domain.Label) {
+		for _, label := range labels {
+			if err := db.Model(task).Association("Labels").Append(&domain.Label{ID: label.ID}); err != nil {
+				t.Fatalf("failed to assign label: %v", err)
+			}
+		}
+	}
+	assign(createTestTask(t, db, board.ID, owner.ID, nil), bug, docs)
+	assign(createTestTask(t, db, board.ID, owner.ID, nil), bug)
+	assign(createTestTask(t, db, board.ID, owner.ID, nil), bug)
+	assign(createTestTask(t, db, otherBoard.ID, owner.ID, nil), foreign)
+
+	usage, err := labelService.GetUsage(project.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetUsage() error = %v", err)
+	}
+
+	want := map[uint]int64{bug.ID: 3, docs.ID: 1, unused.ID: 0}
+	if len(usage) != len(want) {
+		t.Fatalf("GetUsage() returned %d labels, want %d", len(usage), len(want))
+	}
+	for _, u := range usage {
+		var actual int64
+		db.Table("task_labels").Where("label_id = ?", u.ID).Count(&actual)
+		if u.TaskCount != want[u.ID] || u.TaskCount != actual {
+			t.Errorf("label %q task_count = %d, want %d (associations: %d)", u.Name, u.TaskCount, want[u.ID], actual)
+		}
+	}
+
+	if _, err := labelService.GetUsage(project.ID, outsider.ID); err == nil {
+		t.Error("GetUsage() by a non-member should fail")
+	}
+}
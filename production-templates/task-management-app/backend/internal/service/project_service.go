@@ -1,10 +1,17 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"task-management-app/internal/domain"
+	"task-management-app/internal/mailer"
 	"task-management-app/internal/repository"
 )
 
@@ -18,43 +25,64 @@ type ProjectService interface {
 	ListUserProjects(userID uint) ([]*domain.Project, error)
 
 	AddMember(projectID, userID uint, req *domain.AddMemberRequest) error
-	RemoveMember(projectID, memberUserID, requestUserID uint) error
+	InviteMember(projectID, inviterID uint, email string, role domain.ProjectRole) (*domain.ProjectInvitation, error)
+	AcceptInvitation(token string, userID uint) (*domain.ProjectMember, error)
+	CreateInvite(projectID, userID uint, role domain.ProjectRole) (*domain.ProjectInvite, error)
+	AcceptInvite(token string, userID uint) (*domain.ProjectMember, error)
+	RemoveMember(projectID, memberUserID, requestUserID uint, reassignTo *uint) error
 	UpdateMemberRole(projectID, memberUserID, requestUserID uint, req *domain.UpdateMemberRoleRequest) error
 	GetMembers(projectID, userID uint) ([]domain.ProjectMember, error)
+	TransferOwnership(projectID, newOwnerID, requestUserID uint) error
+	RepairOwnership() ([]uint, error)
+
+	ListTemplates(userID uint) ([]*domain.ProjectTemplate, error)
+	CreateFromTemplate(userID, templateID uint, req *domain.CreateProjectRequest) (*domain.Project, error)
+	SaveAsTemplate(projectID, userID uint, name string) (*domain.ProjectTemplate, error)
 
 	CheckAccess(projectID, userID uint, requiredRole domain.ProjectRole) (bool, error)
 	GetUserRole(projectID, userID uint) (domain.ProjectRole, error)
 }
 
+// ProjectOptions configures member invitations
+type ProjectOptions struct {
+	InvitationTTL       time.Duration // How long an invitation can be accepted
+	InvitationAcceptURL string        // Link sent to invitees, followed by the token
+}
+
 type projectService struct {
-	projectRepo repository.ProjectRepository
-	userRepo    repository.UserRepository
+	projectRepo    repository.ProjectRepository
+	userRepo       repository.UserRepository
+	labelRepo      repository.LabelRepository
+	templateRepo   repository.ProjectTemplateRepository
+	invitationRepo repository.InvitationRepository
+	mailer         mailer.Mailer
+	options        ProjectOptions
 }
 
-func NewProjectService(projectRepo repository.ProjectRepository, userRepo repository.UserRepository) ProjectService {
+func NewProjectService(
+	projectRepo repository.ProjectRepository,
+	userRepo repository.UserRepository,
+	labelRepo repository.LabelRepository,
+	templateRepo repository.ProjectTemplateRepository,
+	invitationRepo repository.InvitationRepository,
+	mailer mailer.Mailer,
+	options ProjectOptions,
+) ProjectService {
 	return &projectService{
-		projectRepo: projectRepo,
-		userRepo:    userRepo,
+		projectRepo:    projectRepo,
+		userRepo:       userRepo,
+		labelRepo:      labelRepo,
+		templateRepo:   templateRepo,
+		invitationRepo: invitationRepo,
+		mailer:         mailer,
+		options:        options,
 	}
 }
 
 func (s *projectService) Create(userID uint, req *domain.CreateProjectRequest) (*domain.Project, error) {
-	if req.Name == "" {
-		return nil, errors.New("project name is required")
-	}
-
-	// Verify user exists
-	_, err := s.userRepo.FindByID(userID)
+	project, err := s.newProject(userID, req)
 	if err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
-	}
-
-	project := &domain.Project{
-		Name:        req.Name,
-		Description: req.Description,
-		Icon:        req.Icon,
-		Color:       req.Color,
-		OwnerID:     userID,
+		return nil, err
 	}
 
 	if err := s.projectRepo.Create(project); err != nil {
@@ -75,6 +103,36 @@ func (s *projectService) Create(userID uint, req *domain.CreateProjectRequest) (
 	return s.projectRepo.FindByID(project.ID)
 }
 
+// newProject validates req and builds the project userID is creating
+func (s *projectService) newProject(userID uint, req *domain.CreateProjectRequest) (*domain.Project, error) {
+	if req.Name == "" {
+		return nil, errors.New("project name is required")
+	}
+
+	// Verify user exists
+	_, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	key := req.Key
+	if key == "" {
+		key = deriveProjectKey(req.Name)
+	}
+	if !projectKeyPattern.MatchString(key) {
+		return nil, errInvalidProjectKey
+	}
+
+	return &domain.Project{
+		Name:        req.Name,
+		Key:         key,
+		Description: req.Description,
+		Icon:        req.Icon,
+		Color:       req.Color,
+		OwnerID:     userID,
+	}, nil
+}
+
 func (s *projectService) GetByID(projectID, userID uint) (*domain.Project, error) {
 	// Check if user has access to this project
 	hasAccess, err := s.CheckAccess(projectID, userID, domain.ProjectRoleViewer)
@@ -112,6 +170,12 @@ func (s *projectService) Update(projectID, userID uint, req *domain.UpdateProjec
 	if req.Name != "" {
 		project.Name = req.Name
 	}
+	if req.Key != "" {
+		if !projectKeyPattern.MatchString(req.Key) {
+			return nil, errInvalidProjectKey
+		}
+		project.Key = req.Key
+	}
 	if req.Description != "" {
 		project.Description = req.Description
 	}
@@ -121,6 +185,9 @@ func (s *projectService) Update(projectID, userID uint, req *domain.UpdateProjec
 	if req.Color != "" {
 		project.Color = req.Color
 	}
+	if req.NeverAutoArchive != nil {
+		project.NeverAutoArchive = *req.NeverAutoArchive
+	}
 
 	if err := s.projectRepo.Update(project); err != nil {
 		return nil, fmt.Errorf("failed to update project: %w", err)
@@ -210,6 +277,10 @@ func (s *projectService) AddMember(projectID, userID uint, req *domain.AddMember
 		return errors.New("insufficient permissions to add members")
 	}
 
+	if req.Role == domain.ProjectRoleOwner {
+		return errors.New("a project can only have one owner")
+	}
+
 	// Verify the user to be added exists
 	_, err = s.userRepo.FindByID(req.UserID)
 	if err != nil {
@@ -235,7 +306,191 @@ func (s *projectService) AddMember(projectID, userID uint, req *domain.AddMember
 	return nil
 }
 
-func (s *projectService) RemoveMember(projectID, memberUserID, requestUserID uint) error {
+// InviteMember invites email to the project with the given role and mails
+// them the token to accept with. The invitee doesn't need an account yet.
+// Inviting the same email again replaces the pending invitation.
+func (s *projectService) InviteMember(projectID, inviterID uint, email string, role domain.ProjectRole) (*domain.ProjectInvitation, error) {
+	// Only admin and owner can invite members
+	hasAccess, err := s.CheckAccess(projectID, inviterID, domain.ProjectRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAccess {
+		return nil, errors.New("insufficient permissions to invite members")
+	}
+
+	if err := checkInviteRole(role); err != nil {
+		return nil, err
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	if user, err := s.userRepo.FindByEmail(email); err == nil {
+		if member, _ := s.projectRepo.GetMember(projectID, user.ID); member != nil {
+			return nil, errors.New("user is already a member of this project")
+		}
+	}
+
+	project, err := s.projectRepo.FindByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invitation := &domain.ProjectInvitation{
+		ProjectID:   projectID,
+		Email:       email,
+		Role:        role,
+		Token:       token,
+		InvitedByID: inviterID,
+		ExpiresAt:   time.Now().Add(s.options.InvitationTTL),
+	}
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	subject := fmt.Sprintf("You're invited to join %s", project.Name)
+	body := fmt.Sprintf("You have been invited to join the project %q as %s.\n\nAccept the invitation: %s%s\n\nThe invitation expires on %s.",
+		project.Name, role, s.options.InvitationAcceptURL, token, invitation.ExpiresAt.UTC().Format(time.RFC1123))
+	if err := s.mailer.Send(email, subject, body); err != nil {
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation adds userID to the invitation's project with the invited
+// role. The user's email must be the one the invitation was sent to.
+func (s *projectService) AcceptInvitation(token string, userID uint) (*domain.ProjectMember, error) {
+	invitation, err := s.invitationRepo.FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.AcceptedAt != nil {
+		return nil, errors.New("invitation has already been accepted")
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, errors.New("invitation has expired")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, errors.New("invitation was sent to a different email address")
+	}
+
+	if member, _ := s.projectRepo.GetMember(invitation.ProjectID, userID); member != nil {
+		return nil, errors.New("user is already a member of this project")
+	}
+
+	member := &domain.ProjectMember{
+		ProjectID: invitation.ProjectID,
+		UserID:    userID,
+		Role:      invitation.Role,
+	}
+	if err := s.invitationRepo.Accept(invitation, member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// CreateInvite creates a single-use invite link to the project for the given
+// role, valid for the invitation TTL. Anyone holding the token can accept it.
+func (s *projectService) CreateInvite(projectID, userID uint, role domain.ProjectRole) (*domain.ProjectInvite, error) {
+	// Only admin and owner can invite members
+	hasAccess, err := s.CheckAccess(projectID, userID, domain.ProjectRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAccess {
+		return nil, errors.New("insufficient permissions to invite members")
+	}
+
+	if err := checkInviteRole(role); err != nil {
+		return nil, err
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	invite := &domain.ProjectInvite{
+		ProjectID:   projectID,
+		Role:        role,
+		Token:       token,
+		CreatedByID: userID,
+		ExpiresAt:   time.Now().Add(s.options.InvitationTTL),
+	}
+	if err := s.invitationRepo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// AcceptInvite adds userID to the invite's project with the invited role and
+// uses up the invite
+func (s *projectService) AcceptInvite(token string, userID uint) (*domain.ProjectMember, error) {
+	invite, err := s.invitationRepo.FindInviteByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if invite.UsedAt != nil {
+		return nil, errors.New("invite has already been used")
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, errors.New("invite has expired")
+	}
+
+	if member, _ := s.projectRepo.GetMember(invite.ProjectID, userID); member != nil {
+		return nil, errors.New("user is already a member of this project")
+	}
+
+	member := &domain.ProjectMember{
+		ProjectID: invite.ProjectID,
+		UserID:    userID,
+		Role:      invite.Role,
+	}
+	if err := s.invitationRepo.AcceptInvite(invite, member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// checkInviteRole rejects roles members can't be invited with
+func checkInviteRole(role domain.ProjectRole) error {
+	switch role {
+	case domain.ProjectRoleAdmin, domain.ProjectRoleMember, domain.ProjectRoleViewer:
+		return nil
+	case domain.ProjectRoleOwner:
+		return errors.New("a project can only have one owner")
+	default:
+		return fmt.Errorf("invalid role %q", role)
+	}
+}
+
+// newInvitationToken returns a random hex token for accepting an invitation
+func newInvitationToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// RemoveMember removes a member from the project. Tasks assigned to them are
+// transferred to reassignTo, which must itself be a member, or unassigned when
+// reassignTo is nil.
+func (s *projectService) RemoveMember(projectID, memberUserID, requestUserID uint, reassignTo *uint) error {
 	// Get the role of the user making the request
 	requestUserRole, err := s.GetUserRole(projectID, requestUserID)
 	if err != nil {
@@ -261,7 +516,16 @@ func (s *projectService) RemoveMember(projectID, memberUserID, requestUserID uin
 		}
 	}
 
-	if err := s.projectRepo.RemoveMember(projectID, memberUserID); err != nil {
+	if reassignTo != nil {
+		if *reassignTo == memberUserID {
+			return errors.New("cannot reassign tasks to the member being removed")
+		}
+		if _, err := s.projectRepo.GetMember(projectID, *reassignTo); err != nil {
+			return errors.New("reassign target is not a member of this project")
+		}
+	}
+
+	if err := s.projectRepo.RemoveMember(projectID, memberUserID, reassignTo); err != nil {
 		return fmt.Errorf("failed to remove member: %w", err)
 	}
 
@@ -287,6 +551,11 @@ func (s *projectService) UpdateMemberRole(projectID, memberUserID, requestUserID
 		return errors.New("cannot change project owner's role")
 	}
 
+	// A second owner would break Delete and transfer; use TransferOwnership
+	if req.Role == domain.ProjectRoleOwner {
+		return errors.New("a project can only have one owner; transfer ownership instead")
+	}
+
 	member, err := s.projectRepo.GetMember(projectID, memberUserID)
 	if err != nil {
 		return fmt.Errorf("failed to get member: %w", err)
@@ -300,6 +569,156 @@ func (s *projectService) UpdateMemberRole(projectID, memberUserID, requestUserID
 	return nil
 }
 
+// TransferOwnership hands the project to another member. The current owner
+// stays on as an admin.
+func (s *projectService) TransferOwnership(projectID, newOwnerID, requestUserID uint) error {
+	requestUserRole, err := s.GetUserRole(projectID, requestUserID)
+	if err != nil {
+		return err
+	}
+	if requestUserRole != domain.ProjectRoleOwner {
+		return errors.New("only project owner can transfer ownership")
+	}
+
+	if newOwnerID == requestUserID {
+		return errors.New("user already owns this project")
+	}
+	if _, err := s.projectRepo.GetMember(projectID, newOwnerID); err != nil {
+		return errors.New("new owner must be a member of this project")
+	}
+
+	if err := s.projectRepo.TransferOwnership(projectID, requestUserID, newOwnerID); err != nil {
+		return fmt.Errorf("failed to transfer ownership: %w", err)
+	}
+
+	return nil
+}
+
+// RepairOwnership restores the one-owner invariant on every project that has
+// no owner member or several: the project's creator (OwnerID) becomes the sole
+// owner and any other owners are demoted to admin. It returns the IDs of the
+// projects it fixed.
+func (s *projectService) RepairOwnership() ([]uint, error) {
+	projects, err := s.projectRepo.FindOwnershipViolations()
+	if err != nil {
+		return nil, err
+	}
+
+	repaired := make([]uint, 0, len(projects))
+	for _, project := range projects {
+		if err := s.projectRepo.ResetOwner(project.ID, project.OwnerID); err != nil {
+			return repaired, fmt.Errorf("failed to repair project %d: %w", project.ID, err)
+		}
+		repaired = append(repaired, project.ID)
+	}
+
+	return repaired, nil
+}
+
+func (s *projectService) ListTemplates(userID uint) ([]*domain.ProjectTemplate, error) {
+	templates, err := s.templateRepo.FindAvailable(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project templates: %w", err)
+	}
+	return templates, nil
+}
+
+// CreateFromTemplate creates a project with the boards and labels of a
+// built-in template or one the user saved. The project, its owner membership,
+// boards and labels are created together.
+func (s *projectService) CreateFromTemplate(userID, templateID uint, req *domain.CreateProjectRequest) (*domain.Project, error) {
+	template, err := s.templateRepo.FindByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("template not found: %w", err)
+	}
+	if !template.IsBuiltIn() && *template.OwnerID != userID {
+		return nil, errors.New("access denied to this template")
+	}
+
+	project, err := s.newProject(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Members = []domain.ProjectMember{{UserID: userID, Role: domain.ProjectRoleOwner}}
+	for i, board := range template.Boards {
+		project.Boards = append(project.Boards, domain.Board{
+			Name:        board.Name,
+			Position:    i,
+			WIPLimit:    board.WIPLimit,
+			CreatedByID: &userID,
+		})
+	}
+	for _, label := range template.Labels {
+		project.Labels = append(project.Labels, domain.Label{
+			Name:        label.Name,
+			Color:       label.Color,
+			CreatedByID: &userID,
+		})
+	}
+
+	if err := s.projectRepo.Create(project); err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	// Reload project with members
+	return s.projectRepo.FindByID(project.ID)
+}
+
+// SaveAsTemplate saves the project's boards, in board order, and labels as a
+// template of the user's. Only the project owner can save it.
+func (s *projectService) SaveAsTemplate(projectID, userID uint, name string) (*domain.ProjectTemplate, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("template name is required")
+	}
+
+	role, err := s.GetUserRole(projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role != domain.ProjectRoleOwner {
+		return nil, errors.New("only project owner can save the project as a template")
+	}
+
+	project, err := s.projectRepo.FindByID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	labels, err := s.labelRepo.FindByProjectID(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project labels: %w", err)
+	}
+
+	boards := project.Boards
+	sort.SliceStable(boards, func(i, j int) bool {
+		if boards[i].Position != boards[j].Position {
+			return boards[i].Position < boards[j].Position
+		}
+		return boards[i].ID < boards[j].ID
+	})
+
+	template := &domain.ProjectTemplate{
+		Name:        name,
+		Description: project.Description,
+		OwnerID:     &userID,
+		Boards:      domain.TemplateBoards{},
+		Labels:      domain.TemplateLabels{},
+	}
+	for _, board := range boards {
+		template.Boards = append(template.Boards, domain.TemplateBoard{Name: board.Name, WIPLimit: board.WIPLimit})
+	}
+	for _, label := range labels {
+		template.Labels = append(template.Labels, domain.TemplateLabel{Name: label.Name, Color: label.Color})
+	}
+
+	if err := s.templateRepo.Create(template); err != nil {
+		return nil, fmt.Errorf("failed to save project template: %w", err)
+	}
+
+	return template, nil
+}
+
 func (s *projectService) GetMembers(projectID, userID uint) ([]domain.ProjectMember, error) {
 	// Check if user has access to this project
 	hasAccess, err := s.CheckAccess(projectID, userID, domain.ProjectRoleViewer)
@@ -347,3 +766,28 @@ func (s *projectService) hasPermission(userRole, requiredRole domain.ProjectRole
 
 	return roleHierarchy[userRole] >= roleHierarchy[requiredRole]
 }
+
+// projectKeyPattern is what a project key may look like: 2-10 uppercase
+// letters or digits, starting with a letter.
+var projectKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+
+var errInvalidProjectKey = errors.New("project key must be 2-10 uppercase letters or digits, starting with a letter")
+
+// deriveProjectKey builds a key from the first four letters and digits of a
+// project name, e.g. "Apollo 11" becomes APOL. Names that do not yield a
+// valid key get PROJ.
+func deriveProjectKey(name string) string {
+	var key []rune
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			key = append(key, r)
+		}
+		if len(key) == 4 {
+			break
+		}
+	}
+	if !projectKeyPattern.MatchString(string(key)) {
+		return "PROJ"
+	}
+	return string(key)
+}
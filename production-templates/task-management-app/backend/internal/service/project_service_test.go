@@ -0,0 +1,658 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+)
+
+// setupTestDB opens a file-backed database; repository transactions run on
+// their own connection, which an in-memory database would not share.
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	if err := db.AutoMigrate(
+		&domain.User{},
+		&domain.Project{},
+		&domain.ProjectMember{},
+		&domain.Board{},
+		&domain.Task{},
+		&domain.Label{},
+		&domain.Comment{},
+		&domain.CommentMention{},
+		&domain.TimeLog{},
+		&domain.Attachment{},
+		&domain.ChecklistItem{},
+		&domain.TaskActivity{},
+		&domain.ProjectTemplate{},
+		&domain.ProjectInvitation{},
+		&domain.ProjectInvite{},
+	); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	return db
+}
+
+func newTestProjectService(db *gorm.DB, projectRepo repository.ProjectRepository) ProjectService {
+	return newTestProjectServiceWithMailer(db, projectRepo, &fakeMailer{})
+}
+
+func newTestProjectServiceWithMailer(db *gorm.DB, projectRepo repository.ProjectRepository, mailer *fakeMailer) ProjectService {
+	return NewProjectService(
+		projectRepo,
+		repository.NewUserRepository(db),
+		repository.NewLabelRepository(db),
+		repository.NewProjectTemplateRepository(db),
+		repository.NewInvitationRepository(db),
+		mailer,
+		ProjectOptions{InvitationTTL: 24 * time.Hour, InvitationAcceptURL: "https://tasks.example.com/invitations/"},
+	)
+}
+
+// fakeMailer records the emails it is asked to send
+type fakeMailer struct {
+	sent []sentEmail
+}
+
+type sentEmail struct {
+	to, subject, body string
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	m.sent = append(m.sent, sentEmail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, username string) *domain.User {
+	t.Helper()
+
+	user := &domain.User{
+		Email:        fmt.Sprintf("%s@example.com", username),
+		PasswordHash: "hashed_password",
+		Username:     username,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+func addTestMember(t *testing.T, db *gorm.DB, projectID, userID uint, role domain.ProjectRole) {
+	t.Helper()
+
+	member := &domain.ProjectMember{ProjectID: projectID, UserID: userID, Role: role}
+	if err := db.Create(member).Error; err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+}
+
+func createTestBoard(t *testing.T, db *gorm.DB, projectID uint, name string) *domain.Board {
+	t.Helper()
+
+	board := &domain.Board{ProjectID: projectID, Name: name}
+	if err := db.Create(board).Error; err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	return board
+}
+
+func createTestTask(t *testing.T, db *gorm.DB, boardID, creatorID uint, assigneeID *uint) *domain.Task {
+	t.Helper()
+
+	task := &domain.Task{
+		BoardID:    boardID,
+		Title:      "task",
+		Priority:   domain.PriorityMedium,
+		CreatorID:  creatorID,
+		AssigneeID: assigneeID,
+	}
+	if err := db.Create(task).Error; err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	return task
+}
+
+func TestProjectService_RemoveMember_Reassign(t *testing.T) {
+	db := setupTestDB(t)
+	projectRepo := repository.NewProjectRepository(db)
+	projectService := newTestProjectService(db, projectRepo)
+
+	owner := createTestUser(t, db, "owner")
+	leaving := createTestUser(t, db, "leaving")
+	heir := createTestUser(t, db, "heir")
+	outsider := createTestUser(t, db, "outsider")
+
+	project, err := projectService.Create(owner.ID, &domain.CreateProjectRequest{Name: "Apollo"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	addTestMember(t, db, project.ID, leaving.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, project.ID, heir.ID, domain.ProjectRoleMember)
+
+	board := createTestBoard(t, db, project.ID, "Todo")
+	tasks := []*domain.Task{
+		createTestTask(t, db, board.ID, owner.ID, &leaving.ID),
+		createTestTask(t, db, board.ID, owner.ID, &leaving.ID),
+	}
+	untouched := createTestTask(t, db, board.ID, owner.ID, &owner.ID)
+
+	tests := []struct {
+		name       string
+		reassignTo *uint
+		wantErr    bool
+	}{
+		{name: "target is not a member", reassignTo: &outsider.ID, wantErr: true},
+		{name: "target is the removed member", reassignTo: &leaving.ID, wantErr: true},
+		{name: "target is a member", reassignTo: &heir.ID, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := projectService.RemoveMember(project.ID, leaving.ID, owner.ID, tt.reassignTo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemoveMember() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			wantAssignee := leaving.ID
+			if !tt.wantErr {
+				wantAssignee = *tt.reassignTo
+			}
+
+			for _, task := range tasks {
+				var reloaded domain.Task
+				db.First(&reloaded, task.ID)
+				if reloaded.AssigneeID == nil || *reloaded.AssigneeID != wantAssignee {
+					t.Errorf("task %d assignee = %v, want %d", task.ID, reloaded.AssigneeID, wantAssignee)
+				}
+			}
+
+			_, memberErr := projectRepo.GetMember(project.ID, leaving.ID)
+			if tt.wantErr && memberErr != nil {
+				t.Error("member should not be removed when reassignment is rejected")
+			}
+			if !tt.wantErr && memberErr == nil {
+				t.Error("member should be removed")
+			}
+		})
+	}
+
+	var reloaded domain.Task
+	db.First(&reloaded, untouched.ID)
+	if reloaded.AssigneeID == nil || *reloaded.AssigneeID != owner.ID {
+		t.Errorf("unrelated task assignee = %v, want %d", reloaded.AssigneeID, owner.ID)
+	}
+}
+
+func TestProjectService_RemoveMember_Unassigns(t *testing.T) {
+	db := setupTestDB(t)
+	projectService := newTestProjectService(db, repository.NewProjectRepository(db))
+
+	owner := createTestUser(t, db, "owner")
+	leaving := createTestUser(t, db, "leaving")
+
+	project, err := projectService.Create(owner.ID, &domain.CreateProjectRequest{Name: "Gemini"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	addTestMember(t, db, project.ID, leaving.ID, domain.ProjectRoleMember)
+
+	board := createTestBoard(t, db, project.ID, "Todo")
+	task := createTestTask(t, db, board.ID, owner.ID, &leaving.ID)
+
+	if err := projectService.RemoveMember(project.ID, leaving.ID, owner.ID, nil); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+
+	var reloaded domain.Task
+	db.First(&reloaded, task.ID)
+	if reloaded.AssigneeID != nil {
+		t.Errorf("assignee = %d, want nil", *reloaded.AssigneeID)
+	}
+}
+
+func TestProjectService_SingleOwner(t *testing.T) {
+	db := setupTestDB(t)
+	projectRepo := repository.NewProjectRepository(db)
+	projectService := newTestProjectService(db, projectRepo)
+
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	newcomer := createTestUser(t, db, "newcomer")
+
+	project, err := projectService.Create(owner.ID, &domain.CreateProjectRequest{Name: "Artemis"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+
+	err = projectService.UpdateMemberRole(project.ID, member.ID, owner.ID, &domain.UpdateMemberRoleRequest{Role: domain.ProjectRoleOwner})
+	if err == nil {
+		t.Error("UpdateMemberRole() should reject promoting a second owner")
+	}
+
+	err = projectService.AddMember(project.ID, owner.ID, &domain.AddMemberRequest{UserID: newcomer.ID, Role: domain.ProjectRoleOwner})
+	if err == nil {
+		t.Error("AddMember() should reject adding a second owner")
+	}
+
+	if err := projectService.TransferOwnership(project.ID, member.ID, owner.ID); err != nil {
+		t.Fatalf("TransferOwnership() error = %v", err)
+	}
+
+	if role, _ := projectService.GetUserRole(project.ID, member.ID); role != domain.ProjectRoleOwner {
+		t.Errorf("new owner role = %s, want owner", role)
+	}
+	if role, _ := projectService.GetUserRole(project.ID, owner.ID); role != domain.ProjectRoleAdmin {
+		t.Errorf("previous owner role = %s, want admin", role)
+	}
+	reloaded, _ := projectRepo.FindByID(project.ID)
+	if reloaded.OwnerID != member.ID {
+		t.Errorf("OwnerID = %d, want %d", reloaded.OwnerID, member.ID)
+	}
+
+	if err := projectService.TransferOwnership(project.ID, newcomer.ID, member.ID); err == nil {
+		t.Error("TransferOwnership() to a non-member should fail")
+	}
+}
+
+func TestProjectService_RepairOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	projectService := newTestProjectService(db, repository.NewProjectRepository(db))
+
+	creator := createTestUser(t, db, "creator")
+	usurper := createTestUser(t, db, "usurper")
+
+	healthy := createTestProject(t, db, "Healthy", creator)
+
+	multiple := createTestProject(t, db, "Multiple", creator)
+	addTestMember(t, db, multiple.ID, usurper.ID, domain.ProjectRoleOwner)
+
+	orphaned := &domain.Project{Name: "Orphaned", OwnerID: creator.ID}
+	if err := db.Create(orphaned).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	addTestMember(t, db, orphaned.ID, usurper.ID, domain.ProjectRoleAdmin)
+
+	repaired, err := projectService.RepairOwnership()
+	if err != nil {
+		t.Fatalf("RepairOwnership() error = %v", err)
+	}
+	if len(repaired) != 2 {
+		t.Errorf("RepairOwnership() repaired %v, want projects %d and %d", repaired, multiple.ID, orphaned.ID)
+	}
+
+	for _, project := range []*domain.Project{healthy, multiple, orphaned} {
+		var owners []domain.ProjectMember
+		db.Where("project_id = ? AND role = ?", project.ID, domain.ProjectRoleOwner).Find(&owners)
+		if len(owners) != 1 || owners[0].UserID != creator.ID {
+			t.Errorf("project %q owners = %+v, want only the creator", project.Name, owners)
+		}
+	}
+
+	if role, _ := projectService.GetUserRole(multiple.ID, usurper.ID); role != domain.ProjectRoleAdmin {
+		t.Errorf("extra owner role = %s, want admin", role)
+	}
+
+	// A second run finds nothing left to fix
+	if again, err := projectService.RepairOwnership(); err != nil || len(again) != 0 {
+		t.Errorf("second RepairOwnership() = %v, %v, want nothing repaired", again, err)
+	}
+}
+
+func TestProjectService_Invitations(t *testing.T) {
+	db := setupTestDB(t)
+	mailer := &fakeMailer{}
+	projectService := newTestProjectServiceWithMailer(db, repository.NewProjectRepository(db), mailer)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+
+	// invite invites email and returns the token mailed to it
+	invite := func(email string, role domain.ProjectRole) string {
+		t.Helper()
+		invitation, err := projectService.InviteMember(project.ID, owner.ID, email, role)
+		if err != nil {
+			t.Fatalf("InviteMember(%q) error = %v", email, err)
+		}
+		last := mailer.sent[len(mailer.sent)-1]
+		if last.to != invitation.Email {
+			t.Fatalf("invitation mailed to %q, want %q", last.to, invitation.Email)
+		}
+		if !strings.Contains(last.body, "https://tasks.example.com/invitations/"+invitation.Token) {
+			t.Fatalf("invitation email %q does not link to the token", last.body)
+		}
+		return invitation.Token
+	}
+
+	rejected := []struct {
+		name      string
+		inviterID uint
+		email     string
+		role      domain.ProjectRole
+	}{
+		{name: "viewer inviting", inviterID: viewer.ID, email: "new@example.com", role: domain.ProjectRoleMember},
+		{name: "owner role", inviterID: owner.ID, email: "new@example.com", role: domain.ProjectRoleOwner},
+		{name: "unknown role", inviterID: owner.ID, email: "new@example.com", role: "boss"},
+		{name: "existing member", inviterID: owner.ID, email: viewer.Email, role: domain.ProjectRoleMember},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := projectService.InviteMember(project.ID, tt.inviterID, tt.email, tt.role); err == nil {
+				t.Error("InviteMember() should fail")
+			}
+		})
+	}
+	if len(mailer.sent) != 0 {
+		t.Errorf("rejected invitations sent %d emails", len(mailer.sent))
+	}
+
+	t.Run("accept after signing up", func(t *testing.T) {
+		// No account exists for the email when the invitation is sent
+		token := invite(" Newcomer@Example.com ", domain.ProjectRoleMember)
+		newcomer := createTestUser(t, db, "newcomer")
+
+		member, err := projectService.AcceptInvitation(token, newcomer.ID)
+		if err != nil {
+			t.Fatalf("AcceptInvitation() error = %v", err)
+		}
+		if member.ProjectID != project.ID || member.Role != domain.ProjectRoleMember {
+			t.Errorf("member = %+v, want member of project %d", member, project.ID)
+		}
+		if role, _ := projectService.GetUserRole(project.ID, newcomer.ID); role != domain.ProjectRoleMember {
+			t.Errorf("role = %q, want member", role)
+		}
+
+		if _, err := projectService.AcceptInvitation(token, newcomer.ID); err == nil {
+			t.Error("accepting an invitation twice should fail")
+		}
+	})
+
+	t.Run("wrong email", func(t *testing.T) {
+		token := invite("alice@example.com", domain.ProjectRoleViewer)
+		mallory := createTestUser(t, db, "mallory")
+
+		if _, err := projectService.AcceptInvitation(token, mallory.ID); err == nil {
+			t.Error("AcceptInvitation() by a user with another email should fail")
+		}
+		if _, err := projectService.GetUserRole(project.ID, mallory.ID); err == nil {
+			t.Error("user with another email was added to the project")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token := invite("bob@example.com", domain.ProjectRoleMember)
+		db.Model(&domain.ProjectInvitation{}).Where("token = ?", token).Update("expires_at", time.Now().Add(-time.Minute))
+		bob := createTestUser(t, db, "bob")
+
+		if _, err := projectService.AcceptInvitation(token, bob.ID); err == nil {
+			t.Error("AcceptInvitation() after expiry should fail")
+		}
+		if _, err := projectService.GetUserRole(project.ID, bob.ID); err == nil {
+			t.Error("expired invitation added the user to the project")
+		}
+	})
+
+	t.Run("reinvite replaces pending invitation", func(t *testing.T) {
+		first := invite("carol@example.com", domain.ProjectRoleViewer)
+		second := invite("carol@example.com", domain.ProjectRoleAdmin)
+		carol := createTestUser(t, db, "carol")
+
+		if _, err := projectService.AcceptInvitation(first, carol.ID); err == nil {
+			t.Error("AcceptInvitation() with a replaced token should fail")
+		}
+		if _, err := projectService.AcceptInvitation(second, carol.ID); err != nil {
+			t.Fatalf("AcceptInvitation() error = %v", err)
+		}
+		if role, _ := projectService.GetUserRole(project.ID, carol.ID); role != domain.ProjectRoleAdmin {
+			t.Errorf("role = %q, want admin", role)
+		}
+	})
+
+	if _, err := projectService.AcceptInvitation("unknown", owner.ID); err == nil {
+		t.Error("AcceptInvitation() with an unknown token should fail")
+	}
+}
+
+func TestProjectService_InviteLinks(t *testing.T) {
+	db := setupTestDB(t)
+	projectService := newTestProjectService(db, repository.NewProjectRepository(db))
+
+	owner := createTestUser(t, db, "owner")
+	admin := createTestUser(t, db, "admin")
+	member := createTestUser(t, db, "member")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, admin.ID, domain.ProjectRoleAdmin)
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+
+	rejected := []struct {
+		name   string
+		userID uint
+		role   domain.ProjectRole
+	}{
+		{name: "member creating", userID: member.ID, role: domain.ProjectRoleViewer},
+		{name: "owner role", userID: owner.ID, role: domain.ProjectRoleOwner},
+		{name: "unknown role", userID: owner.ID, role: "boss"},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := projectService.CreateInvite(project.ID, tt.userID, tt.role); err == nil {
+				t.Error("CreateInvite() should fail")
+			}
+		})
+	}
+
+	t.Run("single use", func(t *testing.T) {
+		invite, err := projectService.CreateInvite(project.ID, admin.ID, domain.ProjectRoleViewer)
+		if err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		if invite.Token == "" || invite.CreatedByID != admin.ID {
+			t.Errorf("invite = %+v, want a token created by the admin", invite)
+		}
+
+		first := createTestUser(t, db, "first")
+		joined, err := projectService.AcceptInvite(invite.Token, first.ID)
+		if err != nil {
+			t.Fatalf("AcceptInvite() error = %v", err)
+		}
+		if joined.ProjectID != project.ID || joined.Role != domain.ProjectRoleViewer {
+			t.Errorf("member = %+v, want viewer of project %d", joined, project.ID)
+		}
+
+		second := createTestUser(t, db, "second")
+		_, err = projectService.AcceptInvite(invite.Token, second.ID)
+		if err == nil || err.Error() != "invite has already been used" {
+			t.Errorf("AcceptInvite() of a used invite error = %v, want it reported as used", err)
+		}
+		if _, err := projectService.GetUserRole(project.ID, second.ID); err == nil {
+			t.Error("used invite added another user to the project")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		invite, err := projectService.CreateInvite(project.ID, owner.ID, domain.ProjectRoleMember)
+		if err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		db.Model(&domain.ProjectInvite{}).Where("id = ?", invite.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+		late := createTestUser(t, db, "late")
+		_, err = projectService.AcceptInvite(invite.Token, late.ID)
+		if err == nil || err.Error() != "invite has expired" {
+			t.Errorf("AcceptInvite() after expiry error = %v, want it reported as expired", err)
+		}
+		if _, err := projectService.GetUserRole(project.ID, late.ID); err == nil {
+			t.Error("expired invite added the user to the project")
+		}
+	})
+
+	t.Run("existing member", func(t *testing.T) {
+		invite, err := projectService.CreateInvite(project.ID, owner.ID, domain.ProjectRoleAdmin)
+		if err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		if _, err := projectService.AcceptInvite(invite.Token, member.ID); err == nil {
+			t.Error("AcceptInvite() by an existing member should fail")
+		}
+		if role, _ := projectService.GetUserRole(project.ID, member.ID); role != domain.ProjectRoleMember {
+			t.Errorf("role = %q, want member to be unchanged", role)
+		}
+	})
+
+	if _, err := projectService.AcceptInvite("unknown", member.ID); err == nil {
+		t.Error("AcceptInvite() with an unknown token should fail")
+	}
+}
+
+func TestProjectService_Templates(t *testing.T) {
+	db := setupTestDB(t)
+	templateRepo := repository.NewProjectTemplateRepository(db)
+	projectService := newTestProjectService(db, repository.NewProjectRepository(db))
+
+	owner := createTestUser(t, db, "owner")
+	admin := createTestUser(t, db, "admin")
+	other := createTestUser(t, db, "other")
+
+	// Storing the built-ins twice keeps one copy of each
+	for i := 0; i < 2; i++ {
+		if err := templateRepo.EnsureBuiltIns(domain.BuiltInProjectTemplates()); err != nil {
+			t.Fatalf("EnsureBuiltIns() error = %v", err)
+		}
+	}
+	builtIns, err := projectService.ListTemplates(owner.ID)
+	if err != nil {
+		t.Fatalf("ListTemplates() error = %v", err)
+	}
+	if len(builtIns) != len(domain.BuiltInProjectTemplates()) {
+		t.Fatalf("ListTemplates() returned %d templates, want the %d built-ins", len(builtIns), len(domain.BuiltInProjectTemplates()))
+	}
+	var kanban *domain.ProjectTemplate
+	for _, template := range builtIns {
+		if template.Name == "Kanban" {
+			kanban = template
+		}
+	}
+	if kanban == nil {
+		t.Fatal("Kanban template not listed")
+	}
+
+	project, err := projectService.CreateFromTemplate(owner.ID, kanban.ID, &domain.CreateProjectRequest{Name: "Apollo"})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error = %v", err)
+	}
+	if role, _ := projectService.GetUserRole(project.ID, owner.ID); role != domain.ProjectRoleOwner {
+		t.Errorf("creator role = %s, want owner", role)
+	}
+	if got := fmt.Sprint(templateBoardNames(t, db, project.ID)); got != "[To Do In Progress Done]" {
+		t.Errorf("boards = %s, want [To Do In Progress Done]", got)
+	}
+	labels, err := repository.NewLabelRepository(db).FindByProjectID(project.ID)
+	if err != nil {
+		t.Fatalf("FindByProjectID() error = %v", err)
+	}
+	if len(labels) != len(kanban.Labels) {
+		t.Errorf("project has %d labels, want %d", len(labels), len(kanban.Labels))
+	}
+
+	// Customize the project and save it
+	addTestMember(t, db, project.ID, admin.ID, domain.ProjectRoleAdmin)
+	limit := 3
+	db.Model(&domain.Board{}).Where("project_id = ? AND name = ?", project.ID, "In Progress").Update("WIPLimit", limit)
+	db.Create(&domain.Board{ProjectID: project.ID, Name: "Archive", Position: 3})
+	db.Create(&domain.Label{ProjectID: project.ID, Name: "docs", Color: "#cccccc"})
+
+	if _, err := projectService.SaveAsTemplate(project.ID, admin.ID, "Team flow"); err == nil {
+		t.Error("SaveAsTemplate() by admin should fail")
+	}
+	if _, err := projectService.SaveAsTemplate(project.ID, owner.ID, "  "); err == nil {
+		t.Error("SaveAsTemplate() without a name should fail")
+	}
+	saved, err := projectService.SaveAsTemplate(project.ID, owner.ID, "Team flow")
+	if err != nil {
+		t.Fatalf("SaveAsTemplate() error = %v", err)
+	}
+	if saved.OwnerID == nil || *saved.OwnerID != owner.ID {
+		t.Errorf("saved template owner = %v, want %d", saved.OwnerID, owner.ID)
+	}
+	if len(saved.Labels) != len(kanban.Labels)+1 {
+		t.Errorf("saved template has %d labels, want %d", len(saved.Labels), len(kanban.Labels)+1)
+	}
+
+	// Only the user who saved a template sees and uses it
+	if templates, _ := projectService.ListTemplates(owner.ID); len(templates) != len(builtIns)+1 {
+		t.Errorf("owner sees %d templates, want %d", len(templates), len(builtIns)+1)
+	}
+	if templates, _ := projectService.ListTemplates(other.ID); len(templates) != len(builtIns) {
+		t.Errorf("other user sees %d templates, want %d", len(templates), len(builtIns))
+	}
+	if _, err := projectService.CreateFromTemplate(other.ID, saved.ID, &domain.CreateProjectRequest{Name: "Copy"}); err == nil {
+		t.Error("CreateFromTemplate() with another user's template should fail")
+	}
+
+	copied, err := projectService.CreateFromTemplate(owner.ID, saved.ID, &domain.CreateProjectRequest{Name: "Gemini"})
+	if err != nil {
+		t.Fatalf("CreateFromTemplate() error = %v", err)
+	}
+	if got := fmt.Sprint(templateBoardNames(t, db, copied.ID)); got != "[To Do In Progress Done Archive]" {
+		t.Errorf("boards = %s, want [To Do In Progress Done Archive]", got)
+	}
+	var inProgress domain.Board
+	db.Where("project_id = ? AND name = ?", copied.ID, "In Progress").First(&inProgress)
+	if inProgress.WIPLimit == nil || *inProgress.WIPLimit != limit {
+		t.Errorf("In Progress WIP limit = %v, want %d", inProgress.WIPLimit, limit)
+	}
+}
+
+// templateBoardNames lists a project's boards in board order
+func templateBoardNames(t *testing.T, db *gorm.DB, projectID uint) []string {
+	t.Helper()
+
+	var names []string
+	if err := db.Model(&domain.Board{}).
+		Where("project_id = ?", projectID).
+		Order("position ASC").
+		Pluck("name", &names).Error; err != nil {
+		t.Fatalf("failed to list boards: %v", err)
+	}
+	return names
+}
+
+func TestDeriveProjectKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Apollo", want: "APOL"},
+		{name: "Go 2", want: "GO2"},
+		{name: "web-app", want: "WEBA"},
+		{name: "42 things", want: "PROJ"},
+		{name: "x", want: "PROJ"},
+		{name: "프로젝트", want: "PROJ"},
+	}
+
+	for _, tt := range tests {
+		if got := deriveProjectKey(tt.name); got != tt.want {
+			t.Errorf("deriveProjectKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"task-management-app/internal/repository"
+	"task-management-app/internal/websocket"
+)
+
+type ReminderScheduler interface {
+	// SendDueReminders broadcasts TASK_DUE for every incomplete task that has
+	// reached its due date since the last run and returns their IDs. A task
+	// is reminded about once per due date.
+	SendDueReminders(now time.Time) ([]uint, error)
+	// SendDueSoonReminders sends TASK_DUE_SOON to the assignee of every
+	// incomplete, assigned task falling due within the due-soon window and
+	// returns their IDs. A task is reminded about once per due date.
+	SendDueSoonReminders(now time.Time) ([]uint, error)
+	// Run calls SendDueReminders and SendDueSoonReminders every interval. It
+	// never returns.
+	Run(interval time.Duration)
+}
+
+type reminderScheduler struct {
+	taskRepo      repository.TaskRepository
+	hub           *websocket.Hub
+	dueSoonWindow time.Duration
+}
+
+// NewReminderScheduler creates a scheduler that warns assignees dueSoonWindow
+// ahead of a task's due date. A zero window disables those reminders.
+func NewReminderScheduler(taskRepo repository.TaskRepository, hub *websocket.Hub, dueSoonWindow time.Duration) ReminderScheduler {
+	return &reminderScheduler{
+		taskRepo:      taskRepo,
+		hub:           hub,
+		dueSoonWindow: dueSoonWindow,
+	}
+}
+
+func (s *reminderScheduler) SendDueReminders(now time.Time) ([]uint, error) {
+	tasks, err := s.taskRepo.ClaimDueReminders(now)
+
+	reminded := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		reminded = append(reminded, task.ID)
+
+		if s.hub != nil && task.Board != nil {
+			s.hub.Broadcast(&websocket.Message{
+				Type:      websocket.TypeTaskDue,
+				ProjectID: task.Board.ProjectID,
+				Payload:   task,
+			})
+		}
+	}
+
+	return reminded, err
+}
+
+func (s *reminderScheduler) SendDueSoonReminders(now time.Time) ([]uint, error) {
+	if s.dueSoonWindow <= 0 {
+		return nil, nil
+	}
+
+	tasks, err := s.taskRepo.ClaimDueSoonReminders(now, now.Add(s.dueSoonWindow))
+
+	reminded := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		reminded = append(reminded, task.ID)
+
+		// Only the assignee is reminded
+		if s.hub != nil && task.Board != nil {
+			s.hub.SendToUsers(&websocket.Message{
+				Type:      websocket.TypeTaskDueSoon,
+				ProjectID: task.Board.ProjectID,
+				Payload:   task,
+			}, []uint{*task.AssigneeID})
+		}
+	}
+
+	return reminded, err
+}
+
+func (s *reminderScheduler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		reminded, err := s.SendDueReminders(now)
+		if err != nil {
+			log.Printf("Failed to send due date reminders: %v", err)
+		}
+		if len(reminded) > 0 {
+			log.Printf("Sent due date reminders for %d tasks: %v", len(reminded), reminded)
+		}
+
+		dueSoon, err := s.SendDueSoonReminders(now)
+		if err != nil {
+			log.Printf("Failed to send due soon reminders: %v", err)
+		}
+		if len(dueSoon) > 0 {
+			log.Printf("Sent due soon reminders for %d tasks: %v", len(dueSoon), dueSoon)
+		}
+	}
+}
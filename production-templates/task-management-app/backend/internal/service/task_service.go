@@ -1,12 +1,25 @@
 package service
 
 import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"task-management-app/internal/domain"
 	"task-management-app/internal/repository"
+	"task-management-app/internal/sanitize"
+	"task-management-app/internal/storage"
 	"task-management-app/internal/websocket"
 )
 
@@ -15,37 +28,78 @@ type TaskService interface {
 	GetByID(taskID, userID uint) (*domain.Task, error)
 	Update(taskID, userID uint, req *domain.UpdateTaskRequest) (*domain.Task, error)
 	Delete(taskID, userID uint) error
+	AssignToMe(taskID, userID uint) (*domain.Task, error)
+	Unassign(taskID, userID uint) (*domain.Task, error)
 	Move(taskID, userID uint, req *domain.MoveTaskRequest) error
+	Reorder(boardID, userID uint, req *domain.ReorderTasksRequest) error
+	Transfer(taskID, userID uint, req *domain.TransferTaskRequest) (*domain.Task, error)
 	ListByBoard(boardID, userID uint) ([]*domain.Task, error)
+	SearchProjectTasks(projectID, userID uint, filter domain.TaskFilter, limit, offset int) ([]*domain.Task, int64, error)
+	ListAssignedToUser(userID uint, filter domain.MyTasksFilter) ([]*domain.Task, error)
+	GetOverdueTasks(projectID, userID uint) ([]*domain.Task, error)
+	GetUpcomingTasks(projectID, userID uint, within time.Duration) ([]*domain.Task, error)
+	GetProjectStats(projectID, userID uint) (*domain.ProjectStats, error)
 
 	AddComment(taskID, userID uint, req *domain.CreateCommentRequest) (*domain.Comment, error)
+	UpdateComment(commentID, userID uint, req *domain.UpdateCommentRequest) (*domain.Comment, error)
 	DeleteComment(commentID, userID uint) error
 
+	LogTime(taskID, userID uint, req *domain.LogTimeRequest) (*domain.TimeLog, error)
+	GetTimeLogs(taskID, userID uint) (*domain.TaskTimeLogs, error)
+
 	AddChecklistItem(taskID, userID uint, req *domain.CreateChecklistItemRequest) (*domain.ChecklistItem, error)
 	UpdateChecklistItem(itemID, userID uint, req *domain.UpdateChecklistItemRequest) (*domain.ChecklistItem, error)
 	DeleteChecklistItem(itemID, userID uint) error
+	ReorderChecklist(taskID, userID uint, req *domain.ReorderChecklistRequest) error
+	ConvertChecklistItem(taskID, itemID, userID uint) (*domain.Task, error)
+
+	AddAttachment(taskID, userID uint, file *domain.AttachmentUpload) (*domain.Attachment, error)
+	GetAttachments(taskID, userID uint) ([]*domain.Attachment, error)
+	DeleteAttachment(attachmentID, userID uint) error
 
 	AssignLabels(taskID, userID uint, labelIDs []uint) error
+
+	GetUserActivity(userID uint, limit, offset int) ([]*domain.TaskActivity, int64, error)
+	GetTaskActivity(taskID, userID uint) ([]*domain.TaskActivity, error)
+}
+
+// TaskOptions holds optional task validation rules. The zero value enables
+// none of them.
+type TaskOptions struct {
+	RejectPastDueDates bool
+	MaxAttachmentSize  int64 // In bytes; 0 allows any size
 }
 
 type taskService struct {
-	taskRepo    repository.TaskRepository
-	boardRepo   repository.BoardRepository
-	projectRepo repository.ProjectRepository
-	hub         *websocket.Hub
+	taskRepo     repository.TaskRepository
+	boardRepo    repository.BoardRepository
+	projectRepo  repository.ProjectRepository
+	activityRepo repository.ActivityRepository
+	sanitizer    *sanitize.Sanitizer
+	storage      storage.Storage
+	hub          *websocket.Hub
+	options      TaskOptions
 }
 
 func NewTaskService(
 	taskRepo repository.TaskRepository,
 	boardRepo repository.BoardRepository,
 	projectRepo repository.ProjectRepository,
+	activityRepo repository.ActivityRepository,
+	sanitizer *sanitize.Sanitizer,
+	fileStorage storage.Storage,
 	hub *websocket.Hub,
+	options TaskOptions,
 ) TaskService {
 	return &taskService{
-		taskRepo:    taskRepo,
-		boardRepo:   boardRepo,
-		projectRepo: projectRepo,
-		hub:         hub,
+		taskRepo:     taskRepo,
+		boardRepo:    boardRepo,
+		projectRepo:  projectRepo,
+		activityRepo: activityRepo,
+		sanitizer:    sanitizer,
+		storage:      fileStorage,
+		hub:          hub,
+		options:      options,
 	}
 }
 
@@ -65,9 +119,34 @@ func (s *taskService) Create(boardID, userID uint, req *domain.CreateTaskRequest
 		return nil, err
 	}
 
-	// Get next position for the task
-	tasks, _ := s.taskRepo.FindByBoardID(boardID)
-	position := len(tasks)
+	if err := s.checkAssignee(board.ProjectID, req.AssigneeID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDueDate(req.DueDate); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkWIPLimit(board); err != nil {
+		return nil, err
+	}
+
+	if req.ParentTaskID != nil {
+		if err := s.checkParent(board.ProjectID, 0, *req.ParentTaskID); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Recurrence != nil {
+		if err := req.Recurrence.Normalize(); err != nil {
+			return nil, err
+		}
+	}
+
+	estimate, err := checkEstimate(req.EstimatedMinutes)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set default priority
 	priority := req.Priority
@@ -75,16 +154,28 @@ func (s *taskService) Create(boardID, userID uint, req *domain.CreateTaskRequest
 		priority = domain.PriorityMedium
 	}
 
+	// Allocate the task's number within its project
+	number, key, err := s.projectRepo.NextTaskNumber(board.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+
 	task := &domain.Task{
 		BoardID:     boardID,
+		Number:      number,
+		DisplayID:   domain.TaskDisplayID(key, number),
 		Title:       req.Title,
 		Description: req.Description,
 		Priority:    priority,
 		DueDate:     req.DueDate,
 		AssigneeID:  req.AssigneeID,
 		CreatorID:   userID,
-		Position:    position,
 		IsCompleted: false,
+
+		ParentTaskID:       req.ParentTaskID,
+		AutoCompleteParent: req.AutoCompleteParent,
+		Recurrence:         req.Recurrence,
+		EstimatedMinutes:   estimate,
 	}
 
 	if err := s.taskRepo.Create(task); err != nil {
@@ -104,6 +195,8 @@ func (s *taskService) Create(boardID, userID uint, req *domain.CreateTaskRequest
 		return nil, fmt.Errorf("failed to reload task: %w", err)
 	}
 
+	s.recordActivity(board.ProjectID, task.ID, userID, domain.ActivityTaskCreated, nil)
+
 	// Broadcast via WebSocket
 	s.broadcastTaskEvent(board.ProjectID, userID, "TASK_CREATED", task)
 
@@ -127,6 +220,8 @@ func (s *taskService) GetByID(taskID, userID uint) (*domain.Task, error) {
 		return nil, err
 	}
 
+	task.ComputeSubtaskProgress()
+
 	return task, nil
 }
 
@@ -147,23 +242,63 @@ func (s *taskService) Update(taskID, userID uint, req *domain.UpdateTaskRequest)
 		return nil, err
 	}
 
+	before := taskFields(task)
+
 	// Update fields if provided
-	if req.Title != "" {
-		task.Title = req.Title
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, errors.New("task title is required")
+		}
+		task.Title = *req.Title
 	}
-	if req.Description != "" {
-		task.Description = req.Description
+	if req.Description != nil {
+		task.Description = *req.Description
 	}
 	if req.Priority != "" {
 		task.Priority = req.Priority
 	}
-	if req.DueDate != nil {
-		task.DueDate = req.DueDate
+	if req.DueDate.Set {
+		dueDate := req.DueDate.Time
+		if err := s.checkDueDate(dueDate); err != nil {
+			return nil, err
+		}
+		if dueDate == nil || task.DueDate == nil || !task.DueDate.Equal(*dueDate) {
+			// Remind again for the new date
+			task.DueNotifiedAt = nil
+			task.ReminderSentAt = nil
+		}
+		task.DueDate = dueDate
+	}
+	if req.AssigneeID.Set {
+		if err := s.checkAssignee(board.ProjectID, req.AssigneeID.ID); err != nil {
+			return nil, err
+		}
+		task.AssigneeID = req.AssigneeID.ID
+		task.Assignee = nil // Otherwise saving the loaded assignee restores the old ID
+	}
+	if req.ParentTaskID != nil {
+		if *req.ParentTaskID == 0 {
+			task.ParentTaskID = nil
+		} else {
+			if err := s.checkParent(board.ProjectID, task.ID, *req.ParentTaskID); err != nil {
+				return nil, err
+			}
+			task.ParentTaskID = req.ParentTaskID
+		}
+	}
+	if req.AutoCompleteParent != nil {
+		task.AutoCompleteParent = *req.AutoCompleteParent
 	}
-	if req.AssigneeID != nil {
-		task.AssigneeID = req.AssigneeID
+	if req.EstimatedMinutes != nil {
+		estimate, err := checkEstimate(req.EstimatedMinutes)
+		if err != nil {
+			return nil, err
+		}
+		task.EstimatedMinutes = estimate
 	}
+	completed := false
 	if req.IsCompleted != nil {
+		completed = *req.IsCompleted && !task.IsCompleted
 		task.IsCompleted = *req.IsCompleted
 		if *req.IsCompleted {
 			now := time.Now()
@@ -173,15 +308,40 @@ func (s *taskService) Update(taskID, userID uint, req *domain.UpdateTaskRequest)
 		}
 	}
 
+	// Completing a recurring task hands the rule over to its next
+	// occurrence, so reopening and completing it again doesn't spawn another
+	recurrence := task.Recurrence
+	if completed && recurrence != nil {
+		task.Recurrence = nil
+	}
+
+	changes := diffFields(before, taskFields(task))
+
 	if err := s.taskRepo.Update(task); err != nil {
 		return nil, fmt.Errorf("failed to update task: %w", err)
 	}
 
+	if len(changes) > 0 {
+		action := domain.ActivityTaskUpdated
+		if _, assigned := changes["assignee_id"]; assigned && len(changes) == 1 {
+			action = domain.ActivityTaskAssigned
+		}
+		s.recordActivity(board.ProjectID, taskID, userID, action, changes)
+	}
+
+	if completed && task.ParentTaskID != nil {
+		s.completeParent(board.ProjectID, *task.ParentTaskID, userID)
+	}
+	if completed && recurrence != nil {
+		s.createNextOccurrence(board.ProjectID, task, *recurrence, userID)
+	}
+
 	// Reload task with all relations
 	task, err = s.taskRepo.FindByID(taskID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reload task: %w", err)
 	}
+	task.ComputeSubtaskProgress()
 
 	// Broadcast via WebSocket
 	s.broadcastTaskEvent(board.ProjectID, userID, "TASK_UPDATED", task)
@@ -219,6 +379,67 @@ func (s *taskService) Delete(taskID, userID uint) error {
 	return nil
 }
 
+func (s *taskService) AssignToMe(taskID, userID uint) (*domain.Task, error) {
+	return s.setAssignee(taskID, userID, &userID)
+}
+
+func (s *taskService) Unassign(taskID, userID uint) (*domain.Task, error) {
+	return s.setAssignee(taskID, userID, nil)
+}
+
+// setAssignee changes only the task's assignee, recording and broadcasting the
+// assignment. Setting the current assignee again is a no-op.
+func (s *taskService) setAssignee(taskID, userID uint, assigneeID *uint) (*domain.Task, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	// Get board to check access
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkAssignee(board.ProjectID, assigneeID); err != nil {
+		return nil, err
+	}
+
+	changes := diffFields(
+		map[string]interface{}{"assignee_id": uintValue(task.AssigneeID)},
+		map[string]interface{}{"assignee_id": uintValue(assigneeID)},
+	)
+	if len(changes) == 0 {
+		task.ComputeSubtaskProgress()
+		return task, nil
+	}
+
+	task.AssigneeID = assigneeID
+	task.Assignee = nil // Otherwise saving the loaded assignee restores the old ID
+	if err := s.taskRepo.Update(task); err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	s.recordActivity(board.ProjectID, taskID, userID, domain.ActivityTaskAssigned, changes)
+
+	// Reload task with all relations
+	task, err = s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload task: %w", err)
+	}
+	task.ComputeSubtaskProgress()
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "TASK_ASSIGNED", task)
+
+	return task, nil
+}
+
 func (s *taskService) Move(taskID, userID uint, req *domain.MoveTaskRequest) error {
 	task, err := s.taskRepo.FindByID(taskID)
 	if err != nil {
@@ -247,212 +468,841 @@ func (s *taskService) Move(taskID, userID uint, req *domain.MoveTaskRequest) err
 		return err
 	}
 
+	// Reordering within a board or moving a completed task leaves the
+	// target's active count unchanged
+	if targetBoard.ID != sourceBoard.ID && !task.IsCompleted {
+		if err := s.checkWIPLimit(targetBoard); err != nil {
+			return err
+		}
+	}
+
 	if err := s.taskRepo.Move(taskID, req.BoardID, req.Position); err != nil {
 		return fmt.Errorf("failed to move task: %w", err)
 	}
 
+	before := placementFields(task)
+
 	// Reload task with all relations
 	task, err = s.taskRepo.FindByID(taskID)
 	if err != nil {
 		return fmt.Errorf("failed to reload task: %w", err)
 	}
 
+	s.recordActivity(sourceBoard.ProjectID, taskID, userID, domain.ActivityTaskMoved, diffFields(before, placementFields(task)))
+
 	// Broadcast via WebSocket
 	s.broadcastTaskEvent(sourceBoard.ProjectID, userID, "TASK_MOVED", task)
 
 	return nil
 }
 
-func (s *taskService) ListByBoard(boardID, userID uint) ([]*domain.Task, error) {
-	// Get board to check access
+func (s *taskService) Reorder(boardID, userID uint, req *domain.ReorderTasksRequest) error {
 	board, err := s.boardRepo.FindByID(boardID)
 	if err != nil {
-		return nil, fmt.Errorf("board not found: %w", err)
+		return fmt.Errorf("board not found: %w", err)
 	}
 
 	// Check if user has access to the project
-	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleViewer); err != nil {
-		return nil, err
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return err
 	}
 
-	tasks, err := s.taskRepo.FindByBoardID(boardID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	if err := s.taskRepo.Reorder(boardID, req.TaskIDs); err != nil {
+		return fmt.Errorf("failed to reorder tasks: %w", err)
 	}
 
-	return tasks, nil
-}
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "TASKS_REORDERED", map[string]interface{}{
+		"board_id": boardID,
+		"task_ids": req.TaskIDs,
+	})
 
-func (s *taskService) AddComment(taskID, userID uint, req *domain.CreateCommentRequest) (*domain.Comment, error) {
-	if req.Content == "" {
-		return nil, errors.New("comment content is required")
-	}
+	return nil
+}
 
+// Transfer moves a task to a board in another project. The task is renumbered
+// in the target project, keeps only the labels whose names exist there and is
+// unassigned when its assignee is not a member of the target project.
+func (s *taskService) Transfer(taskID, userID uint, req *domain.TransferTaskRequest) (*domain.Task, error) {
 	task, err := s.taskRepo.FindByID(taskID)
 	if err != nil {
 		return nil, fmt.Errorf("task not found: %w", err)
 	}
 
-	// Get board to check access
-	board, err := s.boardRepo.FindByID(task.BoardID)
+	sourceBoard, err := s.boardRepo.FindByID(task.BoardID)
 	if err != nil {
-		return nil, fmt.Errorf("board not found: %w", err)
+		return nil, fmt.Errorf("source board not found: %w", err)
 	}
 
-	// Check if user has access to the project
-	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
-		return nil, err
+	targetBoard, err := s.boardRepo.FindByID(req.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("target board not found: %w", err)
 	}
 
-	comment := &domain.Comment{
-		TaskID:  taskID,
-		UserID:  userID,
-		Content: req.Content,
+	if sourceBoard.ProjectID == targetBoard.ProjectID {
+		return nil, errors.New("target board is in the same project, move the task instead")
 	}
 
-	if err := s.taskRepo.AddComment(comment); err != nil {
-		return nil, fmt.Errorf("failed to add comment: %w", err)
+	// A subtask must stay in its parent's project
+	if task.ParentTaskID != nil || len(task.Subtasks) > 0 {
+		return nil, errors.New("tasks with a parent or subtasks cannot be transferred to another project")
 	}
 
-	// Broadcast via WebSocket
-	s.broadcastTaskEvent(board.ProjectID, userID, "COMMENT_ADDED", comment)
-
-	return comment, nil
-}
+	// The user must be able to edit tasks in both projects
+	if err := s.checkProjectAccess(sourceBoard.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+	if err := s.checkProjectAccess(targetBoard.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
 
-func (s *taskService) DeleteComment(commentID, userID uint) error {
-	comment, err := s.taskRepo.GetComment(commentID)
-	if err != nil {
-		return fmt.Errorf("comment not found: %w", err)
+	if !task.IsCompleted {
+		if err := s.checkWIPLimit(targetBoard); err != nil {
+			return nil, err
+		}
 	}
 
-	// Only the comment author can delete it
-	if comment.UserID != userID {
-		return errors.New("only comment author can delete the comment")
+	if err := s.checkAssignee(targetBoard.ProjectID, task.AssigneeID); err != nil {
+		task.AssigneeID = nil
 	}
 
-	task, err := s.taskRepo.FindByID(comment.TaskID)
+	labelIDs, err := s.mapLabels(task.Labels, targetBoard.ProjectID)
 	if err != nil {
-		return fmt.Errorf("task not found: %w", err)
+		return nil, err
 	}
 
-	board, err := s.boardRepo.FindByID(task.BoardID)
+	number, key, err := s.projectRepo.NextTaskNumber(targetBoard.ProjectID)
 	if err != nil {
-		return fmt.Errorf("board not found: %w", err)
+		return nil, err
 	}
 
-	if err := s.taskRepo.DeleteComment(commentID); err != nil {
-		return fmt.Errorf("failed to delete comment: %w", err)
+	sourceBoardID := task.BoardID
+	before := placementFields(task)
+	task.BoardID = targetBoard.ID
+	task.Position = req.Position
+	task.Number = number
+	task.DisplayID = domain.TaskDisplayID(key, number)
+
+	if err := s.taskRepo.Transfer(task, labelIDs); err != nil {
+		return nil, fmt.Errorf("failed to transfer task: %w", err)
 	}
 
-	// Broadcast via WebSocket
-	s.broadcastTaskEvent(board.ProjectID, userID, "COMMENT_DELETED", map[string]interface{}{
-		"id":      commentID,
-		"task_id": task.ID,
+	// Reload task with all relations
+	task, err = s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload task: %w", err)
+	}
+
+	s.recordActivity(targetBoard.ProjectID, taskID, userID, domain.ActivityTaskTransferred, diffFields(before, placementFields(task)))
+
+	// To the source project the task is gone, to the target it is new
+	s.broadcastTaskEvent(sourceBoard.ProjectID, userID, "TASK_DELETED", map[string]interface{}{
+		"id":       taskID,
+		"board_id": sourceBoardID,
 	})
+	s.broadcastTaskEvent(targetBoard.ProjectID, userID, "TASK_CREATED", task)
 
-	return nil
+	return task, nil
 }
 
-func (s *taskService) AddChecklistItem(taskID, userID uint, req *domain.CreateChecklistItemRequest) (*domain.ChecklistItem, error) {
-	if req.Title == "" {
-		return nil, errors.New("checklist item title is required")
+func (s *taskService) ListByBoard(boardID, userID uint) ([]*domain.Task, error) {
+	// Get board to check access
+	board, err := s.boardRepo.FindByID(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
 	}
 
-	task, err := s.taskRepo.FindByID(taskID)
-	if err != nil {
-		return nil, fmt.Errorf("task not found: %w", err)
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
 	}
 
-	board, err := s.boardRepo.FindByID(task.BoardID)
+	tasks, err := s.taskRepo.FindByBoardID(boardID)
 	if err != nil {
-		return nil, fmt.Errorf("board not found: %w", err)
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
 	}
 
-	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
-		return nil, err
-	}
+	return tasks, nil
+}
 
-	item := &domain.ChecklistItem{
-		TaskID:   taskID,
-		Title:    req.Title,
-		Position: req.Position,
+func (s *taskService) SearchProjectTasks(projectID, userID uint, filter domain.TaskFilter, limit, offset int) ([]*domain.Task, int64, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, 0, err
 	}
 
-	if err := s.taskRepo.AddChecklistItem(item); err != nil {
-		return nil, fmt.Errorf("failed to add checklist item: %w", err)
+	switch filter.Priority {
+	case "", domain.PriorityLow, domain.PriorityMedium, domain.PriorityHigh, domain.PriorityUrgent:
+	default:
+		return nil, 0, fmt.Errorf("invalid priority %q", filter.Priority)
+	}
+	if filter.DueAfter != nil && filter.DueBefore != nil && filter.DueBefore.Before(*filter.DueAfter) {
+		return nil, 0, errors.New("due_before must not be earlier than due_after")
+	}
+	if filter.Completed != nil {
+		if filter.IsCompleted != nil && *filter.IsCompleted != *filter.Completed {
+			return nil, 0, errors.New("completed and is_completed disagree")
+		}
+		filter.IsCompleted = filter.Completed
 	}
 
-	// Broadcast via WebSocket
-	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_ADDED", item)
+	tasks, total, err := s.taskRepo.Search(projectID, &filter, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search tasks: %w", err)
+	}
 
-	return item, nil
+	return tasks, total, nil
 }
 
-func (s *taskService) UpdateChecklistItem(itemID, userID uint, req *domain.UpdateChecklistItemRequest) (*domain.ChecklistItem, error) {
-	item, err := s.taskRepo.GetChecklistItem(itemID)
-	if err != nil {
-		return nil, fmt.Errorf("checklist item not found: %w", err)
+func (s *taskService) ListAssignedToUser(userID uint, filter domain.MyTasksFilter) ([]*domain.Task, error) {
+	if filter.DueAfter != nil && filter.DueBefore != nil && filter.DueBefore.Before(*filter.DueAfter) {
+		return nil, errors.New("due_before must not be earlier than due_after")
 	}
 
-	task, err := s.taskRepo.FindByID(item.TaskID)
+	tasks, err := s.taskRepo.FindAssignedToUser(userID, &filter)
 	if err != nil {
-		return nil, fmt.Errorf("task not found: %w", err)
+		return nil, fmt.Errorf("failed to list assigned tasks: %w", err)
 	}
 
-	board, err := s.boardRepo.FindByID(task.BoardID)
-	if err != nil {
-		return nil, fmt.Errorf("board not found: %w", err)
-	}
+	return tasks, nil
+}
 
-	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+func (s *taskService) GetOverdueTasks(projectID, userID uint) ([]*domain.Task, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
 		return nil, err
 	}
 
-	if req.Title != "" {
-		item.Title = req.Title
-	}
-	if req.IsCompleted != nil {
-		item.IsCompleted = *req.IsCompleted
-	}
-
-	if err := s.taskRepo.UpdateChecklistItem(item); err != nil {
-		return nil, fmt.Errorf("failed to update checklist item: %w", err)
+	tasks, err := s.taskRepo.FindOverdue(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overdue tasks: %w", err)
 	}
 
-	// Broadcast via WebSocket
-	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_UPDATED", item)
-
-	return item, nil
+	return tasks, nil
 }
 
-func (s *taskService) DeleteChecklistItem(itemID, userID uint) error {
-	item, err := s.taskRepo.GetChecklistItem(itemID)
-	if err != nil {
-		return fmt.Errorf("checklist item not found: %w", err)
+func (s *taskService) GetProjectStats(projectID, userID uint) (*domain.ProjectStats, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
 	}
 
-	task, err := s.taskRepo.FindByID(item.TaskID)
+	now := time.Now()
+	stats := &domain.ProjectStats{ProjectID: projectID}
+
+	open, completed, err := s.taskRepo.CountByCompletion(projectID)
 	if err != nil {
-		return fmt.Errorf("task not found: %w", err)
+		return nil, err
 	}
+	stats.Total = open + completed
+	stats.ByStatus = map[string]int64{"open": open, "completed": completed}
 
-	board, err := s.boardRepo.FindByID(task.BoardID)
+	byPriority, err := s.taskRepo.CountByPriority(projectID)
 	if err != nil {
-		return fmt.Errorf("board not found: %w", err)
+		return nil, err
 	}
-
-	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
-		return err
+	stats.ByPriority = map[domain.TaskPriority]int64{
+		domain.PriorityLow:    byPriority[domain.PriorityLow],
+		domain.PriorityMedium: byPriority[domain.PriorityMedium],
+		domain.PriorityHigh:   byPriority[domain.PriorityHigh],
+		domain.PriorityUrgent: byPriority[domain.PriorityUrgent],
+	}
+
+	if stats.ByAssignee, err = s.taskRepo.CountByAssignee(projectID); err != nil {
+		return nil, err
+	}
+	if stats.Overdue, err = s.taskRepo.CountOverdue(projectID, now); err != nil {
+		return nil, err
+	}
+	if stats.CompletedLast7Days, err = s.taskRepo.CountCompletedSince(projectID, now.AddDate(0, 0, -7)); err != nil {
+		return nil, err
+	}
+
+	average, averaged, err := s.taskRepo.AverageCompletionTime(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if averaged > 0 {
+		hours := average.Hours()
+		stats.AverageCompletionHours = &hours
+	}
+
+	if stats.EstimatedMinutes, stats.LoggedMinutes, err = s.taskRepo.SumTime(projectID); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetUpcomingTasks lists the project's incomplete tasks due from now until
+// within from now. Overdue tasks are not included.
+func (s *taskService) GetUpcomingTasks(projectID, userID uint, within time.Duration) ([]*domain.Task, error) {
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(projectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	if within <= 0 {
+		return nil, errors.New("due_within must be positive")
+	}
+
+	now := time.Now()
+	tasks, err := s.taskRepo.FindDueBetween(projectID, now, now.Add(within))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upcoming tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+func (s *taskService) AddComment(taskID, userID uint, req *domain.CreateCommentRequest) (*domain.Comment, error) {
+	if req.Content == "" {
+		return nil, errors.New("comment content is required")
+	}
+
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	// Get board to check access
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	comment := &domain.Comment{
+		TaskID:  taskID,
+		UserID:  userID,
+		Content: s.sanitizer.Sanitize(req.Content),
+	}
+
+	if err := s.taskRepo.AddComment(comment); err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+	comment.MentionedUserIDs = s.recordMentions(board.ProjectID, comment, req.Content)
+
+	s.recordActivity(board.ProjectID, taskID, userID, domain.ActivityCommentAdded, domain.ActivityChanges{
+		"comment_id": {From: nil, To: comment.ID},
+	})
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "COMMENT_ADDED", comment)
+	if len(comment.MentionedUserIDs) > 0 && s.hub != nil {
+		s.hub.SendToUsers(&websocket.Message{
+			Type:      websocket.TypeCommentMention,
+			ProjectID: board.ProjectID,
+			UserID:    userID,
+			Payload:   comment,
+		}, comment.MentionedUserIDs)
+	}
+
+	return comment, nil
+}
+
+func (s *taskService) UpdateComment(commentID, userID uint, req *domain.UpdateCommentRequest) (*domain.Comment, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, errors.New("comment content is required")
+	}
+
+	comment, err := s.taskRepo.GetComment(commentID)
+	if err != nil {
+		return nil, fmt.Errorf("comment not found: %w", err)
+	}
+
+	// Only the comment author can edit it
+	if comment.UserID != userID {
+		return nil, errors.New("only comment author can edit the comment")
+	}
+
+	task, err := s.taskRepo.FindByID(comment.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	// Authors who have since left the project or become viewers can't edit
+	if err := s.checkProjectAccess(task.Board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	comment.Content = s.sanitizer.Sanitize(req.Content)
+	comment.IsEdited = true
+	comment.EditedAt = &now
+
+	if err := s.taskRepo.UpdateComment(comment); err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(task.Board.ProjectID, userID, "COMMENT_UPDATED", comment)
+
+	return comment, nil
+}
+
+func (s *taskService) DeleteComment(commentID, userID uint) error {
+	comment, err := s.taskRepo.GetComment(commentID)
+	if err != nil {
+		return fmt.Errorf("comment not found: %w", err)
+	}
+
+	// Only the comment author can delete it
+	if comment.UserID != userID {
+		return errors.New("only comment author can delete the comment")
+	}
+
+	task, err := s.taskRepo.FindByID(comment.TaskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.taskRepo.DeleteComment(commentID); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "COMMENT_DELETED", map[string]interface{}{
+		"id":      commentID,
+		"task_id": task.ID,
+	})
+
+	return nil
+}
+
+// mentionPattern matches @username at the start of the content or after a
+// character that cannot be part of a username, so emails are not mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@-])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// parseMentions returns the distinct usernames mentioned in content, in order
+// of first appearance. Trailing punctuation such as "@bob." is not part of the
+// username.
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// recordMentions persists a mention for every project member mentioned in
+// content, other than the author, and returns their user IDs. Usernames of
+// non-members are ignored. Failures are logged rather than returned because
+// the comment itself has already been added.
+func (s *taskService) recordMentions(projectID uint, comment *domain.Comment, content string) []uint {
+	usernames := parseMentions(content)
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	members, err := s.projectRepo.GetMembers(projectID)
+	if err != nil {
+		log.Printf("Failed to resolve mentions in comment %d: %v", comment.ID, err)
+		return nil
+	}
+	memberIDs := make(map[string]uint, len(members))
+	for _, member := range members {
+		if member.User != nil {
+			memberIDs[member.User.Username] = member.UserID
+		}
+	}
+
+	var mentions []*domain.CommentMention
+	var userIDs []uint
+	for _, username := range usernames {
+		userID, ok := memberIDs[username]
+		if !ok || userID == comment.UserID {
+			continue
+		}
+		mentions = append(mentions, &domain.CommentMention{
+			CommentID: comment.ID,
+			TaskID:    comment.TaskID,
+			UserID:    userID,
+			CreatedAt: comment.CreatedAt,
+		})
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := s.taskRepo.CreateCommentMentions(mentions); err != nil {
+		log.Printf("Failed to record mentions for comment %d: %v", comment.ID, err)
+		return nil
+	}
+	return userIDs
+}
+
+// maxTimeLogMinutes caps a single time log at one day
+const maxTimeLogMinutes = 24 * 60
+
+func (s *taskService) LogTime(taskID, userID uint, req *domain.LogTimeRequest) (*domain.TimeLog, error) {
+	if req.Minutes <= 0 || req.Minutes > maxTimeLogMinutes {
+		return nil, fmt.Errorf("minutes must be between 1 and %d", maxTimeLogMinutes)
+	}
+
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	// Check if user has access to the project
+	if err := s.checkProjectAccess(task.Board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	loggedAt := time.Now()
+	if req.LoggedAt != nil {
+		loggedAt = *req.LoggedAt
+	}
+
+	entry := &domain.TimeLog{
+		TaskID:   taskID,
+		UserID:   userID,
+		Minutes:  req.Minutes,
+		Note:     s.sanitizer.Sanitize(req.Note),
+		LoggedAt: loggedAt,
+	}
+
+	if err := s.taskRepo.AddTimeLog(entry); err != nil {
+		return nil, fmt.Errorf("failed to log time: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(task.Board.ProjectID, userID, "TIME_LOGGED", entry)
+
+	return entry, nil
+}
+
+func (s *taskService) GetTimeLogs(taskID, userID uint) (*domain.TaskTimeLogs, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(task.Board.ProjectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.taskRepo.GetTimeLogs(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := &domain.TaskTimeLogs{Entries: entries}
+	for _, entry := range entries {
+		logs.TotalMinutes += entry.Minutes
+	}
+	return logs, nil
+}
+
+func (s *taskService) AddChecklistItem(taskID, userID uint, req *domain.CreateChecklistItemRequest) (*domain.ChecklistItem, error) {
+	if req.Title == "" {
+		return nil, errors.New("checklist item title is required")
+	}
+
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	item := &domain.ChecklistItem{
+		TaskID:   taskID,
+		Title:    req.Title,
+		Position: req.Position,
+	}
+
+	if err := s.taskRepo.AddChecklistItem(item); err != nil {
+		return nil, fmt.Errorf("failed to add checklist item: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_ADDED", item)
+
+	return item, nil
+}
+
+func (s *taskService) UpdateChecklistItem(itemID, userID uint, req *domain.UpdateChecklistItemRequest) (*domain.ChecklistItem, error) {
+	item, err := s.taskRepo.GetChecklistItem(itemID)
+	if err != nil {
+		return nil, fmt.Errorf("checklist item not found: %w", err)
+	}
+
+	task, err := s.taskRepo.FindByID(item.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	if req.Title != "" {
+		item.Title = req.Title
+	}
+	if req.IsCompleted != nil {
+		item.IsCompleted = *req.IsCompleted
+	}
+
+	if err := s.taskRepo.UpdateChecklistItem(item); err != nil {
+		return nil, fmt.Errorf("failed to update checklist item: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_UPDATED", item)
+
+	return item, nil
+}
+
+func (s *taskService) DeleteChecklistItem(itemID, userID uint) error {
+	item, err := s.taskRepo.GetChecklistItem(itemID)
+	if err != nil {
+		return fmt.Errorf("checklist item not found: %w", err)
+	}
+
+	task, err := s.taskRepo.FindByID(item.TaskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return err
+	}
+
+	if err := s.taskRepo.DeleteChecklistItem(itemID); err != nil {
+		return fmt.Errorf("failed to delete checklist item: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_DELETED", map[string]interface{}{
+		"id":      itemID,
+		"task_id": task.ID,
+	})
+
+	return nil
+}
+
+func (s *taskService) ReorderChecklist(taskID, userID uint, req *domain.ReorderChecklistRequest) error {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return err
+	}
+
+	if err := s.taskRepo.ReorderChecklist(taskID, req.ItemIDs); err != nil {
+		return fmt.Errorf("failed to reorder checklist: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_REORDERED", map[string]interface{}{
+		"task_id":  taskID,
+		"item_ids": req.ItemIDs,
+	})
+
+	return nil
+}
+
+// ConvertChecklistItem promotes a checklist item to a task of its own on the
+// same board, titled after the item, and removes the item from the checklist.
+func (s *taskService) ConvertChecklistItem(taskID, itemID, userID uint) (*domain.Task, error) {
+	item, err := s.taskRepo.GetChecklistItem(itemID)
+	if err != nil {
+		return nil, fmt.Errorf("checklist item not found: %w", err)
+	}
+	if item.TaskID != taskID {
+		return nil, fmt.Errorf("checklist item %d does not belong to task %d", itemID, taskID)
+	}
+
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	// Create checks access to the board, and broadcasts TASK_CREATED
+	created, err := s.Create(task.BoardID, userID, &domain.CreateTaskRequest{Title: item.Title})
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.taskRepo.DeleteChecklistItem(itemID); err != nil {
-		return fmt.Errorf("failed to delete checklist item: %w", err)
+		return nil, fmt.Errorf("failed to delete checklist item: %w", err)
 	}
 
 	// Broadcast via WebSocket
-	s.broadcastTaskEvent(board.ProjectID, userID, "CHECKLIST_ITEM_DELETED", map[string]interface{}{
-		"id":      itemID,
+	s.broadcastTaskEvent(task.Board.ProjectID, userID, "CHECKLIST_ITEM_CONVERTED", map[string]interface{}{
+		"id":          itemID,
+		"task_id":     taskID,
+		"new_task_id": created.ID,
+	})
+
+	return created, nil
+}
+
+// AddAttachment streams an uploaded file to storage and attaches it to the
+// task. Files larger than the configured maximum are rejected, whatever size
+// the client declared.
+func (s *taskService) AddAttachment(taskID, userID uint, file *domain.AttachmentUpload) (*domain.Attachment, error) {
+	if s.storage == nil {
+		return nil, errors.New("attachment storage is not configured")
+	}
+
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleMember); err != nil {
+		return nil, err
+	}
+
+	filename := path.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+	if filename == "" || filename == "." || filename == "/" {
+		return nil, errors.New("file name is required")
+	}
+
+	maxSize := s.options.MaxAttachmentSize
+	if maxSize > 0 && file.Size > maxSize {
+		return nil, fmt.Errorf("file exceeds the maximum attachment size of %d bytes", maxSize)
+	}
+
+	content := bufio.NewReader(file.Content)
+	mimeType := file.MimeType
+	if mimeType == "" {
+		head, _ := content.Peek(512)
+		mimeType = http.DetectContentType(head)
+	}
+
+	key, err := attachmentKey(taskID, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &countingReader{r: content, limit: maxSize}
+	fileURL, err := s.storage.Save(key, counter)
+	if err != nil {
+		if errors.Is(err, errAttachmentTooLarge) {
+			return nil, fmt.Errorf("file exceeds the maximum attachment size of %d bytes", maxSize)
+		}
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	attachment := &domain.Attachment{
+		TaskID:     taskID,
+		UserID:     userID,
+		Filename:   filename,
+		FileURL:    fileURL,
+		StorageKey: key,
+		FileSize:   counter.n,
+		MimeType:   mimeType,
+	}
+
+	if err := s.taskRepo.AddAttachment(attachment); err != nil {
+		s.deleteStoredFile(key)
+		return nil, fmt.Errorf("failed to add attachment: %w", err)
+	}
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "ATTACHMENT_ADDED", attachment)
+
+	return attachment, nil
+}
+
+func (s *taskService) GetAttachments(taskID, userID uint) ([]*domain.Attachment, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.taskRepo.GetAttachments(taskID)
+}
+
+// DeleteAttachment removes an attachment and its file. The uploader and
+// project admins may delete it.
+func (s *taskService) DeleteAttachment(attachmentID, userID uint) error {
+	attachment, err := s.taskRepo.GetAttachment(attachmentID)
+	if err != nil {
+		return fmt.Errorf("attachment not found: %w", err)
+	}
+
+	task, err := s.taskRepo.FindByID(attachment.TaskID)
+	if err != nil {
+		return fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return fmt.Errorf("board not found: %w", err)
+	}
+
+	requiredRole := domain.ProjectRoleAdmin
+	if attachment.UserID == userID {
+		requiredRole = domain.ProjectRoleMember
+	}
+	if err := s.checkProjectAccess(board.ProjectID, userID, requiredRole); err != nil {
+		return err
+	}
+
+	if err := s.taskRepo.DeleteAttachment(attachmentID); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+
+	s.deleteStoredFile(attachment.StorageKey)
+
+	// Broadcast via WebSocket
+	s.broadcastTaskEvent(board.ProjectID, userID, "ATTACHMENT_DELETED", map[string]interface{}{
+		"id":      attachmentID,
 		"task_id": task.ID,
 	})
 
@@ -478,18 +1328,56 @@ func (s *taskService) AssignLabels(taskID, userID uint, labelIDs []uint) error {
 		return fmt.Errorf("failed to assign labels: %w", err)
 	}
 
+	before := labelFields(task)
+
 	// Reload task to get updated labels
 	task, err = s.taskRepo.FindByID(taskID)
 	if err != nil {
 		return fmt.Errorf("failed to reload task: %w", err)
 	}
 
+	if changes := diffFields(before, labelFields(task)); len(changes) > 0 {
+		s.recordActivity(board.ProjectID, taskID, userID, domain.ActivityTaskUpdated, changes)
+	}
+
 	// Broadcast via WebSocket
 	s.broadcastTaskEvent(board.ProjectID, userID, "TASK_LABELS_UPDATED", task)
 
 	return nil
 }
 
+func (s *taskService) GetUserActivity(userID uint, limit, offset int) ([]*domain.TaskActivity, int64, error) {
+	activities, total, err := s.activityRepo.FindByUserID(userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get user activity: %w", err)
+	}
+	return activities, total, nil
+}
+
+// GetTaskActivity returns the task's activity log in chronological order, so
+// users can catch up on changes made while they were away
+func (s *taskService) GetTaskActivity(taskID, userID uint) ([]*domain.TaskActivity, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("task not found: %w", err)
+	}
+
+	board, err := s.boardRepo.FindByID(task.BoardID)
+	if err != nil {
+		return nil, fmt.Errorf("board not found: %w", err)
+	}
+
+	if err := s.checkProjectAccess(board.ProjectID, userID, domain.ProjectRoleViewer); err != nil {
+		return nil, err
+	}
+
+	activities, err := s.activityRepo.FindByTaskID(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task activity: %w", err)
+	}
+	return activities, nil
+}
+
 // Helper methods
 
 func (s *taskService) checkProjectAccess(projectID, userID uint, requiredRole domain.ProjectRole) error {
@@ -513,6 +1401,346 @@ func (s *taskService) checkProjectAccess(projectID, userID uint, requiredRole do
 	return nil
 }
 
+// checkAssignee verifies that a task assignee belongs to the project. A nil
+// assignee is always allowed.
+func (s *taskService) checkAssignee(projectID uint, assigneeID *uint) error {
+	if assigneeID == nil {
+		return nil
+	}
+	if _, err := s.projectRepo.GetMember(projectID, *assigneeID); err != nil {
+		return errors.New("assignee is not a member of this project")
+	}
+	return nil
+}
+
+// checkDueDate rejects due dates before the start of today, in the due
+// date's own time zone, when past due dates are disallowed. A nil due date
+// is always allowed.
+func (s *taskService) checkDueDate(dueDate *time.Time) error {
+	if !s.options.RejectPastDueDates || dueDate == nil {
+		return nil
+	}
+
+	year, month, day := time.Now().In(dueDate.Location()).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, dueDate.Location())
+	if dueDate.Before(today) {
+		return fmt.Errorf("due date %s is in the past", dueDate.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// checkEstimate validates an estimate in minutes, mapping 0 to no estimate
+func checkEstimate(minutes *int) (*int, error) {
+	if minutes == nil || *minutes == 0 {
+		return nil, nil
+	}
+	if *minutes < 0 {
+		return nil, errors.New("estimated minutes cannot be negative")
+	}
+	return minutes, nil
+}
+
+// maxTaskDepth bounds the ancestor walk in checkParent
+const maxTaskDepth = 100
+
+// checkParent verifies that parentID can become the parent of taskID: the
+// parent must belong to the same project and taskID must not be among its
+// ancestors. A taskID of 0 stands for a task that doesn't exist yet.
+func (s *taskService) checkParent(projectID, taskID, parentID uint) error {
+	parent, err := s.taskRepo.FindByID(parentID)
+	if err != nil {
+		return fmt.Errorf("parent task not found: %w", err)
+	}
+	if parent.Board == nil || parent.Board.ProjectID != projectID {
+		return errors.New("parent task must belong to the same project")
+	}
+	if taskID == 0 {
+		return nil
+	}
+
+	ancestorID := &parentID
+	for depth := 0; ancestorID != nil; depth++ {
+		if *ancestorID == taskID {
+			return errors.New("a task cannot be its own ancestor")
+		}
+		if depth >= maxTaskDepth {
+			return errors.New("subtasks are nested too deeply")
+		}
+		if ancestorID, err = s.taskRepo.FindParentID(*ancestorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeParent completes a parent task that opted into auto-completion
+// once all of its subtasks are done, and continues up the hierarchy. Failures
+// are only logged; the subtask update itself already succeeded.
+func (s *taskService) completeParent(projectID, parentID, userID uint) {
+	for depth := 0; depth < maxTaskDepth; depth++ {
+		parent, err := s.taskRepo.FindByID(parentID)
+		if err != nil {
+			log.Printf("Failed to load parent task %d: %v", parentID, err)
+			return
+		}
+		if !parent.AutoCompleteParent || parent.IsCompleted {
+			return
+		}
+		for _, subtask := range parent.Subtasks {
+			if !subtask.IsCompleted {
+				return
+			}
+		}
+
+		now := time.Now()
+		parent.IsCompleted = true
+		parent.CompletedAt = &now
+		if err := s.taskRepo.Update(parent); err != nil {
+			log.Printf("Failed to auto-complete parent task %d: %v", parentID, err)
+			return
+		}
+
+		s.recordActivity(projectID, parent.ID, userID, domain.ActivityTaskUpdated, domain.ActivityChanges{
+			"is_completed": {From: false, To: true},
+		})
+
+		parent.ComputeSubtaskProgress()
+		s.broadcastTaskEvent(projectID, userID, "TASK_UPDATED", parent)
+
+		if parent.ParentTaskID == nil {
+			return
+		}
+		parentID = *parent.ParentTaskID
+	}
+}
+
+// createNextOccurrence creates the task following a completed recurring one,
+// on the same board with the same details and labels. It is due one
+// recurrence period after the completed task was, or after now if it had no
+// due date.
+func (s *taskService) createNextOccurrence(projectID uint, completed *domain.Task, rule domain.RecurrenceRule, userID uint) {
+	base := time.Now()
+	if completed.DueDate != nil {
+		base = *completed.DueDate
+	}
+	dueDate := rule.Next(base)
+
+	number, key, err := s.projectRepo.NextTaskNumber(projectID)
+	if err != nil {
+		log.Printf("Failed to number next occurrence of task %d: %v", completed.ID, err)
+		return
+	}
+
+	next := &domain.Task{
+		BoardID:     completed.BoardID,
+		Number:      number,
+		DisplayID:   domain.TaskDisplayID(key, number),
+		Title:       completed.Title,
+		Description: completed.Description,
+		Priority:    completed.Priority,
+		DueDate:     &dueDate,
+		AssigneeID:  completed.AssigneeID,
+		CreatorID:   userID,
+		Recurrence:  &rule,
+
+		EstimatedMinutes: completed.EstimatedMinutes,
+	}
+	if err := s.taskRepo.Create(next); err != nil {
+		log.Printf("Failed to create next occurrence of task %d: %v", completed.ID, err)
+		return
+	}
+
+	if len(completed.Labels) > 0 {
+		labelIDs := make([]uint, len(completed.Labels))
+		for i, label := range completed.Labels {
+			labelIDs[i] = label.ID
+		}
+		if err := s.taskRepo.AssignLabels(next.ID, labelIDs); err != nil {
+			log.Printf("Failed to copy labels to task %d: %v", next.ID, err)
+		}
+	}
+
+	next, err = s.taskRepo.FindByID(next.ID)
+	if err != nil {
+		log.Printf("Failed to reload task: %v", err)
+		return
+	}
+
+	s.recordActivity(projectID, next.ID, userID, domain.ActivityTaskCreated, nil)
+	s.broadcastTaskEvent(projectID, userID, "TASK_CREATED", next)
+}
+
+// checkWIPLimit rejects adding an active task to a board that already holds
+// as many active tasks as its WIP limit allows. Completed tasks don't count.
+func (s *taskService) checkWIPLimit(board *domain.Board) error {
+	if board.WIPLimit == nil {
+		return nil
+	}
+
+	active, err := s.taskRepo.CountActiveByBoardID(board.ID)
+	if err != nil {
+		return err
+	}
+	if active >= int64(*board.WIPLimit) {
+		return fmt.Errorf("board %q has reached its WIP limit of %d active tasks", board.Name, *board.WIPLimit)
+	}
+	return nil
+}
+
+// mapLabels returns the IDs of the target project's labels that share a name
+// with the given labels. Labels without a counterpart are dropped.
+func (s *taskService) mapLabels(labels []domain.Label, projectID uint) ([]uint, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	targetLabels, err := s.taskRepo.FindLabelsByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]uint, len(targetLabels))
+	for _, label := range targetLabels {
+		byName[strings.ToLower(label.Name)] = label.ID
+	}
+
+	var labelIDs []uint
+	for _, label := range labels {
+		if id, ok := byName[strings.ToLower(label.Name)]; ok {
+			labelIDs = append(labelIDs, id)
+		}
+	}
+	return labelIDs, nil
+}
+
+// errAttachmentTooLarge aborts an upload once it exceeds the maximum size
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+// countingReader counts the bytes read through it and fails with
+// errAttachmentTooLarge once more than limit bytes were read. A limit of 0
+// means no limit.
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.limit > 0 && c.n > c.limit {
+		return n, errAttachmentTooLarge
+	}
+	return n, err
+}
+
+// attachmentKey returns a unique storage key for a task's file. The random
+// segment keeps uploads with the same name apart and makes URLs unguessable.
+func attachmentKey(taskID uint, filename string) (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+	return fmt.Sprintf("tasks/%d/%s/%s", taskID, hex.EncodeToString(token), filename), nil
+}
+
+// deleteStoredFile removes a file from storage. The attachment record is
+// already gone, so a leftover file is only logged.
+func (s *taskService) deleteStoredFile(key string) {
+	if key == "" || s.storage == nil {
+		return
+	}
+	if err := s.storage.Delete(key); err != nil {
+		log.Printf("Failed to delete stored file %s: %v", key, err)
+	}
+}
+
+// recordActivity persists an activity entry and broadcasts it as
+// TASK_ACTIVITY. Failing to record it must not fail the action itself, so
+// errors are only logged.
+func (s *taskService) recordActivity(projectID, taskID, userID uint, action domain.ActivityAction, changes domain.ActivityChanges) {
+	activity := &domain.TaskActivity{
+		ProjectID: projectID,
+		TaskID:    taskID,
+		UserID:    userID,
+		Action:    action,
+		Changes:   changes,
+	}
+	if err := s.activityRepo.Create(activity); err != nil {
+		log.Printf("Failed to record %s activity for task %d: %v", action, taskID, err)
+		return
+	}
+
+	s.broadcastTaskEvent(projectID, userID, "TASK_ACTIVITY", activity)
+}
+
+// taskFields snapshots the task fields an update can change, for diffFields.
+// Pointers are dereferenced so that the snapshot is unaffected by later
+// changes to the task.
+func taskFields(task *domain.Task) map[string]interface{} {
+	return map[string]interface{}{
+		"title":                task.Title,
+		"description":          task.Description,
+		"priority":             task.Priority,
+		"due_date":             timeValue(task.DueDate),
+		"assignee_id":          uintValue(task.AssigneeID),
+		"is_completed":         task.IsCompleted,
+		"parent_task_id":       uintValue(task.ParentTaskID),
+		"auto_complete_parent": task.AutoCompleteParent,
+		"estimated_minutes":    intValue(task.EstimatedMinutes),
+	}
+}
+
+// placementFields snapshots where a task sits, for moves and transfers
+func placementFields(task *domain.Task) map[string]interface{} {
+	return map[string]interface{}{
+		"board_id": task.BoardID,
+		"position": task.Position,
+	}
+}
+
+// labelFields snapshots the task's label IDs in ascending order
+func labelFields(task *domain.Task) map[string]interface{} {
+	ids := make([]uint, len(task.Labels))
+	for i, label := range task.Labels {
+		ids[i] = label.ID
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return map[string]interface{}{"label_ids": ids}
+}
+
+// diffFields returns the fields whose value differs between two snapshots
+func diffFields(before, after map[string]interface{}) domain.ActivityChanges {
+	changes := domain.ActivityChanges{}
+	for field, from := range before {
+		if to := after[field]; !reflect.DeepEqual(from, to) {
+			changes[field] = domain.FieldChange{From: from, To: to}
+		}
+	}
+	return changes
+}
+
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func uintValue(u *uint) interface{} {
+	if u == nil {
+		return nil
+	}
+	return *u
+}
+
+func intValue(i *int) interface{} {
+	if i == nil {
+		return nil
+	}
+	return *i
+}
+
 func (s *taskService) broadcastTaskEvent(projectID, userID uint, eventType string, data interface{}) {
 	if s.hub != nil {
 		message := &websocket.Message{
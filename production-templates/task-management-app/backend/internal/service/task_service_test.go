@@ -0,0 +1,2010 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/repository"
+	"task-management-app/internal/sanitize"
+)
+
+func newTestTaskService(db *gorm.DB) TaskService {
+	return newTestTaskServiceWithMode(db, sanitize.ModeEscape)
+}
+
+func newTestTaskServiceWithMode(db *gorm.DB, mode sanitize.Mode) TaskService {
+	return newTestTaskServiceWithOptions(db, mode, TaskOptions{})
+}
+
+func newTestTaskServiceWithOptions(db *gorm.DB, mode sanitize.Mode, options TaskOptions) TaskService {
+	return NewTaskService(
+		repository.NewTaskRepository(db),
+		repository.NewBoardRepository(db),
+		repository.NewProjectRepository(db),
+		repository.NewActivityRepository(db),
+		sanitize.NewSanitizer(mode),
+		nil,
+		nil,
+		options,
+	)
+}
+
+func createTestProject(t *testing.T, db *gorm.DB, name string, owner *domain.User) *domain.Project {
+	t.Helper()
+
+	project := &domain.Project{Name: name, OwnerID: owner.ID}
+	if err := db.Create(project).Error; err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	addTestMember(t, db, project.ID, owner.ID, domain.ProjectRoleOwner)
+	return project
+}
+
+func TestTaskService_AssigneeMustBeMember(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	outsider := createTestUser(t, db, "outsider")
+
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	tests := []struct {
+		name       string
+		assigneeID *uint
+		wantErr    bool
+	}{
+		{name: "unassigned", assigneeID: nil, wantErr: false},
+		{name: "project member", assigneeID: &member.ID, wantErr: false},
+		{name: "non-member", assigneeID: &outsider.ID, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run("create "+tt.name, func(t *testing.T) {
+			_, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{
+				Title:      "Write spec",
+				AssigneeID: tt.assigneeID,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	task, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "Review spec"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for _, tt := range tests[1:] {
+		t.Run("update "+tt.name, func(t *testing.T) {
+			_, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{AssigneeID: domain.SetID(tt.assigneeID)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	reloaded, err := taskService.GetByID(task.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if reloaded.AssigneeID == nil || *reloaded.AssigneeID != member.ID {
+		t.Errorf("assignee = %v, want %d", reloaded.AssigneeID, member.ID)
+	}
+}
+
+func TestTaskService_GetUserActivity(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+	projectRepo := repository.NewProjectRepository(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+
+	kept := createTestProject(t, db, "Kept", owner)
+	left := createTestProject(t, db, "Left", owner)
+	addTestMember(t, db, kept.ID, dev.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, left.ID, dev.ID, domain.ProjectRoleMember)
+
+	todo := createTestBoard(t, db, kept.ID, "Todo")
+	done := createTestBoard(t, db, kept.ID, "Done")
+	other := createTestBoard(t, db, left.ID, "Todo")
+
+	task, err := taskService.Create(todo.ID, dev.ID, &domain.CreateTaskRequest{Title: "Ship it"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := taskService.AddComment(task.ID, dev.ID, &domain.CreateCommentRequest{Content: "on it"}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	if err := taskService.Move(task.ID, dev.ID, &domain.MoveTaskRequest{BoardID: done.ID}); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if _, err := taskService.Create(other.ID, dev.ID, &domain.CreateTaskRequest{Title: "Old work"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// Someone else's action must not show up in dev's timeline
+	if _, err := taskService.Create(todo.ID, owner.ID, &domain.CreateTaskRequest{Title: "Owner task"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if err := projectRepo.RemoveMember(left.ID, dev.ID, nil); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+
+	activities, total, err := taskService.GetUserActivity(dev.ID, 20, 0)
+	if err != nil {
+		t.Fatalf("GetUserActivity() error = %v", err)
+	}
+
+	want := []domain.ActivityAction{
+		domain.ActivityTaskMoved,
+		domain.ActivityCommentAdded,
+		domain.ActivityTaskCreated,
+	}
+	if total != int64(len(want)) || len(activities) != len(want) {
+		t.Fatalf("GetUserActivity() returned %d of %d, want %d", len(activities), total, len(want))
+	}
+	for i, activity := range activities {
+		if activity.Action != want[i] {
+			t.Errorf("activities[%d].Action = %s, want %s", i, activity.Action, want[i])
+		}
+		if activity.ProjectID != kept.ID {
+			t.Errorf("activities[%d].ProjectID = %d, want %d", i, activity.ProjectID, kept.ID)
+		}
+	}
+
+	page, _, err := taskService.GetUserActivity(dev.ID, 1, 1)
+	if err != nil {
+		t.Fatalf("GetUserActivity() error = %v", err)
+	}
+	if len(page) != 1 || page[0].Action != domain.ActivityCommentAdded {
+		t.Errorf("second page = %+v, want the comment activity", page)
+	}
+}
+
+func TestTaskService_GetTaskActivity(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+	viewer := createTestUser(t, db, "viewer")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, dev.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	todo := createTestBoard(t, db, project.ID, "Todo")
+	done := createTestBoard(t, db, project.ID, "Done")
+
+	task, err := taskService.Create(todo.ID, owner.ID, &domain.CreateTaskRequest{Title: "Ship it"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	newTitle := "Ship it today"
+	if _, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{Title: &newTitle, Priority: domain.PriorityHigh}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// An update that changes nothing is not recorded
+	if _, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{Title: &newTitle}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{AssigneeID: domain.SetID(&dev.ID)}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := taskService.Move(task.ID, dev.ID, &domain.MoveTaskRequest{BoardID: done.ID}); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+	if _, err := taskService.AddComment(task.ID, dev.ID, &domain.CreateCommentRequest{Content: "done"}); err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+
+	if _, err := taskService.GetTaskActivity(task.ID, outsider.ID); err == nil {
+		t.Error("GetTaskActivity() by a non-member should fail")
+	}
+
+	activities, err := taskService.GetTaskActivity(task.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetTaskActivity() error = %v", err)
+	}
+
+	want := []struct {
+		action domain.ActivityAction
+		userID uint
+		fields string
+	}{
+		{action: domain.ActivityTaskCreated, userID: owner.ID, fields: ""},
+		{action: domain.ActivityTaskUpdated, userID: owner.ID, fields: "priority title"},
+		{action: domain.ActivityTaskAssigned, userID: owner.ID, fields: "assignee_id"},
+		{action: domain.ActivityTaskMoved, userID: dev.ID, fields: "board_id"},
+		{action: domain.ActivityCommentAdded, userID: dev.ID, fields: "comment_id"},
+	}
+	if len(activities) != len(want) {
+		t.Fatalf("GetTaskActivity() returned %d activities, want %d", len(activities), len(want))
+	}
+	for i, activity := range activities {
+		fields := make([]string, 0, len(activity.Changes))
+		for field := range activity.Changes {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		if activity.Action != want[i].action || activity.UserID != want[i].userID || strings.Join(fields, " ") != want[i].fields {
+			t.Errorf("activities[%d] = %s by %d changing %v, want %s by %d changing %q",
+				i, activity.Action, activity.UserID, fields, want[i].action, want[i].userID, want[i].fields)
+		}
+	}
+
+	title := activities[1].Changes["title"]
+	if title.From != "Ship it" || title.To != "Ship it today" {
+		t.Errorf("title change = %+v, want from %q to %q", title, "Ship it", "Ship it today")
+	}
+	if board := activities[3].Changes["board_id"]; fmt.Sprint(board.From, board.To) != fmt.Sprint(todo.ID, done.ID) {
+		t.Errorf("board change = %+v, want from %d to %d", board, todo.ID, done.ID)
+	}
+}
+
+func TestTaskService_AddComment_Sanitizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    sanitize.Mode
+		content string
+		want    string
+	}{
+		{
+			name:    "escape neutralizes script",
+			mode:    sanitize.ModeEscape,
+			content: "<script>alert(1)</script> **done**",
+			want:    "&lt;script&gt;alert(1)&lt;/script&gt; **done**",
+		},
+		{
+			name:    "markdown keeps formatting",
+			mode:    sanitize.ModeMarkdown,
+			content: "> see `fix` in [PR](https://example.com/pr/1)\n<img src=x onerror=alert(1)>",
+			want:    "> see `fix` in [PR](https://example.com/pr/1)\n&lt;img src=x onerror=alert(1)&gt;",
+		},
+		{
+			name:    "markdown drops javascript links",
+			mode:    sanitize.ModeMarkdown,
+			content: "[open](javascript:alert(document.cookie))",
+			want:    "[open](#))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			taskService := newTestTaskServiceWithMode(db, tt.mode)
+
+			owner := createTestUser(t, db, "owner")
+			project := createTestProject(t, db, "Apollo", owner)
+			board := createTestBoard(t, db, project.ID, "Todo")
+			task := createTestTask(t, db, board.ID, owner.ID, nil)
+
+			comment, err := taskService.AddComment(task.ID, owner.ID, &domain.CreateCommentRequest{Content: tt.content})
+			if err != nil {
+				t.Fatalf("AddComment() error = %v", err)
+			}
+			if comment.Content != tt.want {
+				t.Errorf("Content = %q, want %q", comment.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskService_Create_ConcurrentNumbers(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	db.Model(project).Update("key", "APL")
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	// A second project keeps its own sequence
+	other := createTestProject(t, db, "Other", owner)
+	otherBoard := createTestBoard(t, db, other.ID, "Todo")
+
+	const creators = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, creators)
+	start := make(chan struct{})
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: fmt.Sprintf("task %d", i)})
+			errs <- err
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	var tasks []domain.Task
+	db.Where("board_id = ?", board.ID).Order("number").Find(&tasks)
+	if len(tasks) != creators {
+		t.Fatalf("tasks created = %d, want %d", len(tasks), creators)
+	}
+	for i, task := range tasks {
+		if want := i + 1; task.Number != want || task.DisplayID != fmt.Sprintf("APL-%d", want) {
+			t.Errorf("task %d = %d (%s), want %d (APL-%d)", i, task.Number, task.DisplayID, want, want)
+		}
+	}
+
+	first, err := taskService.Create(otherBoard.ID, owner.ID, &domain.CreateTaskRequest{Title: "first"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if first.Number != 1 {
+		t.Errorf("other project's first task number = %d, want 1", first.Number)
+	}
+}
+
+func TestTaskService_Transfer(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	shared := createTestUser(t, db, "shared")
+	local := createTestUser(t, db, "local")
+	outsider := createTestUser(t, db, "outsider")
+
+	source := createTestProject(t, db, "Source", owner)
+	target := createTestProject(t, db, "Target", owner)
+	db.Model(target).Update("key", "TGT")
+	addTestMember(t, db, source.ID, shared.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, target.ID, shared.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, source.ID, local.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, source.ID, outsider.ID, domain.ProjectRoleMember)
+
+	sourceBoard := createTestBoard(t, db, source.ID, "Todo")
+	otherSourceBoard := createTestBoard(t, db, source.ID, "Done")
+	targetBoard := createTestBoard(t, db, target.ID, "Backlog")
+
+	bug := &domain.Label{ProjectID: source.ID, Name: "bug", Color: "#f00"}
+	chore := &domain.Label{ProjectID: source.ID, Name: "chore", Color: "#999"}
+	targetBug := &domain.Label{ProjectID: target.ID, Name: "Bug", Color: "#e00"}
+	for _, label := range []*domain.Label{bug, chore, targetBug} {
+		if err := db.Create(label).Error; err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		assigneeID   *uint
+		wantAssignee *uint
+	}{
+		{name: "assignee is a member of both projects", assigneeID: &shared.ID, wantAssignee: &shared.ID},
+		{name: "assignee is not a member of the target", assigneeID: &local.ID, wantAssignee: nil},
+		{name: "unassigned", assigneeID: nil, wantAssignee: nil},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task, err := taskService.Create(sourceBoard.ID, owner.ID, &domain.CreateTaskRequest{
+				Title:      "transfer me",
+				AssigneeID: tt.assigneeID,
+				LabelIDs:   []uint{bug.ID, chore.ID},
+			})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			moved, err := taskService.Transfer(task.ID, owner.ID, &domain.TransferTaskRequest{BoardID: targetBoard.ID})
+			if err != nil {
+				t.Fatalf("Transfer() error = %v", err)
+			}
+
+			if moved.BoardID != targetBoard.ID {
+				t.Errorf("BoardID = %d, want %d", moved.BoardID, targetBoard.ID)
+			}
+			if want := fmt.Sprintf("TGT-%d", i+1); moved.DisplayID != want {
+				t.Errorf("DisplayID = %s, want %s", moved.DisplayID, want)
+			}
+
+			switch {
+			case tt.wantAssignee == nil && moved.AssigneeID != nil:
+				t.Errorf("AssigneeID = %d, want nil", *moved.AssigneeID)
+			case tt.wantAssignee != nil && (moved.AssigneeID == nil || *moved.AssigneeID != *tt.wantAssignee):
+				t.Errorf("AssigneeID = %v, want %d", moved.AssigneeID, *tt.wantAssignee)
+			}
+
+			// "bug" maps onto the target's "Bug", "chore" has no counterpart
+			if len(moved.Labels) != 1 || moved.Labels[0].ID != targetBug.ID {
+				t.Errorf("Labels = %+v, want only label %d", moved.Labels, targetBug.ID)
+			}
+		})
+	}
+
+	task := createTestTask(t, db, sourceBoard.ID, owner.ID, nil)
+
+	if _, err := taskService.Transfer(task.ID, owner.ID, &domain.TransferTaskRequest{BoardID: otherSourceBoard.ID}); err == nil {
+		t.Error("Transfer() within the same project should fail")
+	}
+	if _, err := taskService.Transfer(task.ID, outsider.ID, &domain.TransferTaskRequest{BoardID: targetBoard.ID}); err == nil {
+		t.Error("Transfer() by a non-member of the target project should fail")
+	}
+
+	var reloaded domain.Task
+	db.First(&reloaded, task.ID)
+	if reloaded.BoardID != sourceBoard.ID {
+		t.Errorf("rejected transfer moved the task to board %d", reloaded.BoardID)
+	}
+}
+
+func TestTaskService_WIPLimit(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+	boardService := NewBoardService(repository.NewBoardRepository(db), repository.NewProjectRepository(db), nil)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	backlog := createTestBoard(t, db, project.ID, "Backlog")
+	doing := createTestBoard(t, db, project.ID, "Doing")
+
+	limit := 2
+	if _, err := boardService.Update(doing.ID, owner.ID, &domain.UpdateBoardRequest{WIPLimit: &limit}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	for i := 0; i < limit; i++ {
+		if _, err := taskService.Create(doing.ID, owner.ID, &domain.CreateTaskRequest{Title: fmt.Sprintf("task %d", i)}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	if _, err := taskService.Create(doing.ID, owner.ID, &domain.CreateTaskRequest{Title: "one too many"}); err == nil {
+		t.Error("Create() on a full board should fail")
+	}
+
+	waiting := createTestTask(t, db, backlog.ID, owner.ID, nil)
+	if err := taskService.Move(waiting.ID, owner.ID, &domain.MoveTaskRequest{BoardID: doing.ID}); err == nil {
+		t.Error("Move() onto a full board should fail")
+	}
+
+	// Completed tasks don't count towards the limit
+	done := createTestTask(t, db, backlog.ID, owner.ID, nil)
+	db.Model(done).Update("is_completed", true)
+	if err := taskService.Move(done.ID, owner.ID, &domain.MoveTaskRequest{BoardID: doing.ID}); err != nil {
+		t.Errorf("Move() of a completed task error = %v", err)
+	}
+
+	db.Model(&domain.Task{}).Where("board_id = ? AND title = ?", doing.ID, "task 0").Update("is_completed", true)
+	if err := taskService.Move(waiting.ID, owner.ID, &domain.MoveTaskRequest{BoardID: doing.ID}); err != nil {
+		t.Errorf("Move() after completing a task error = %v", err)
+	}
+
+	full, err := boardService.GetByID(doing.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if full.ActiveTaskCount != limit || full.WIPLimit == nil || *full.WIPLimit != limit {
+		t.Errorf("board count/limit = %d/%v, want %d/%d", full.ActiveTaskCount, full.WIPLimit, limit, limit)
+	}
+
+	// Removing the limit lifts the cap
+	unlimited := 0
+	board, err := boardService.Update(doing.ID, owner.ID, &domain.UpdateBoardRequest{WIPLimit: &unlimited})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if board.WIPLimit != nil {
+		t.Errorf("WIPLimit = %d, want nil", *board.WIPLimit)
+	}
+	if _, err := taskService.Create(doing.ID, owner.ID, &domain.CreateTaskRequest{Title: "no limit"}); err != nil {
+		t.Errorf("Create() without a limit error = %v", err)
+	}
+}
+
+func TestTaskService_PastDueDates(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	startOfToday := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		reject  bool
+		dueDate time.Time
+		wantErr bool
+	}{
+		{name: "past date rejected when enabled", reject: true, dueDate: yesterday, wantErr: true},
+		{name: "today allowed when enabled", reject: true, dueDate: startOfToday, wantErr: false},
+		{name: "future date allowed when enabled", reject: true, dueDate: tomorrow, wantErr: false},
+		{name: "past date allowed when disabled", reject: false, dueDate: yesterday, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			taskService := newTestTaskServiceWithOptions(db, sanitize.ModeEscape, TaskOptions{RejectPastDueDates: tt.reject})
+
+			owner := createTestUser(t, db, "owner")
+			project := createTestProject(t, db, "Apollo", owner)
+			board := createTestBoard(t, db, project.ID, "Todo")
+
+			_, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "create", DueDate: &tt.dueDate})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			task := createTestTask(t, db, board.ID, owner.ID, nil)
+			_, err = taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{DueDate: domain.SetTime(&tt.dueDate)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTaskService_ClearDueDate(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskServiceWithOptions(db, sanitize.ModeEscape, TaskOptions{RejectPastDueDates: true})
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	// An overdue task can still be edited and have its due date cleared
+	task := createTestTask(t, db, board.ID, owner.ID, nil)
+	db.Model(task).Update("due_date", time.Now().AddDate(0, 0, -7))
+
+	title := "renamed"
+	updated, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{Title: &title})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.DueDate == nil {
+		t.Fatal("Update() without due date fields cleared the due date")
+	}
+
+	// An explicit null clears it
+	var req domain.UpdateTaskRequest
+	if err := json.Unmarshal([]byte(`{"due_date": null}`), &req); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	updated, err = taskService.Update(task.ID, owner.ID, &req)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.DueDate != nil {
+		t.Errorf("DueDate = %v, want nil", updated.DueDate)
+	}
+}
+
+func TestTaskService_Search(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, dev.ID, domain.ProjectRoleMember)
+	other := createTestProject(t, db, "Gemini", owner)
+
+	todo := createTestBoard(t, db, project.ID, "Todo")
+	done := createTestBoard(t, db, project.ID, "Done")
+
+	bug := &domain.Label{ProjectID: project.ID, Name: "Bug", Color: "#d73a4a"}
+	ui := &domain.Label{ProjectID: project.ID, Name: "UI", Color: "#a2eeef"}
+	db.Create(bug)
+	db.Create(ui)
+
+	dueSoon := time.Date(2030, 5, 10, 12, 0, 0, 0, time.UTC)
+	dueLater := time.Date(2030, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	login := &domain.Task{BoardID: todo.ID, Title: "Fix login button", Description: "Button is misaligned",
+		Priority: domain.PriorityHigh, CreatorID: owner.ID, AssigneeID: &dev.ID, DueDate: &dueSoon,
+		Labels: []domain.Label{*bug, *ui}}
+	crash := &domain.Task{BoardID: todo.ID, Title: "Crash on save", Description: "100% reproducible",
+		Priority: domain.PriorityUrgent, CreatorID: owner.ID, DueDate: &dueLater, Labels: []domain.Label{*bug}}
+	docs := &domain.Task{BoardID: done.ID, Title: "Write docs", Priority: domain.PriorityLow,
+		CreatorID: owner.ID, AssigneeID: &dev.ID, IsCompleted: true}
+	for _, task := range []*domain.Task{login, crash, docs} {
+		if err := db.Create(task).Error; err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+	createTestTask(t, db, createTestBoard(t, db, other.ID, "Todo").ID, owner.ID, nil)
+
+	completed := true
+	after := time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2030, 5, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter domain.TaskFilter
+		want   []uint
+	}{
+		{name: "no filter", filter: domain.TaskFilter{}, want: []uint{login.ID, crash.ID, docs.ID}},
+		{name: "assignee", filter: domain.TaskFilter{AssigneeID: &dev.ID}, want: []uint{login.ID, docs.ID}},
+		{name: "one label", filter: domain.TaskFilter{LabelIDs: []uint{bug.ID}}, want: []uint{login.ID, crash.ID}},
+		{name: "all labels", filter: domain.TaskFilter{LabelIDs: []uint{bug.ID, ui.ID, bug.ID}}, want: []uint{login.ID}},
+		{name: "priority", filter: domain.TaskFilter{Priority: domain.PriorityUrgent}, want: []uint{crash.ID}},
+		{name: "completed", filter: domain.TaskFilter{IsCompleted: &completed}, want: []uint{docs.ID}},
+		{name: "due range", filter: domain.TaskFilter{DueAfter: &after, DueBefore: &before}, want: []uint{login.ID}},
+		{name: "title query", filter: domain.TaskFilter{Query: "LOGIN"}, want: []uint{login.ID}},
+		{name: "description query", filter: domain.TaskFilter{Query: "misaligned"}, want: []uint{login.ID}},
+		{name: "wildcard is literal", filter: domain.TaskFilter{Query: "100%"}, want: []uint{crash.ID}},
+		{name: "combined", filter: domain.TaskFilter{AssigneeID: &dev.ID, LabelIDs: []uint{bug.ID}, Query: "button"}, want: []uint{login.ID}},
+		{name: "completed alias", filter: domain.TaskFilter{Completed: &completed}, want: []uint{docs.ID}},
+		{name: "combined with completion", filter: domain.TaskFilter{AssigneeID: &dev.ID, Priority: domain.PriorityLow, Completed: &completed}, want: []uint{docs.ID}},
+		{name: "combined without match", filter: domain.TaskFilter{AssigneeID: &dev.ID, Priority: domain.PriorityUrgent}, want: []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, total, err := taskService.SearchProjectTasks(project.ID, dev.ID, tt.filter, 20, 0)
+			if err != nil {
+				t.Fatalf("SearchProjectTasks() error = %v", err)
+			}
+			if total != int64(len(tt.want)) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+
+			got := make([]uint, len(tasks))
+			for i, task := range tasks {
+				got[i] = task.ID
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("SearchProjectTasks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := taskService.SearchProjectTasks(project.ID, outsider.ID, domain.TaskFilter{}, 20, 0); err == nil {
+		t.Error("SearchProjectTasks() by a non-member should fail")
+	}
+	if _, _, err := taskService.SearchProjectTasks(project.ID, dev.ID, domain.TaskFilter{Priority: "critical"}, 20, 0); err == nil {
+		t.Error("SearchProjectTasks() with an unknown priority should fail")
+	}
+	notCompleted := false
+	if _, _, err := taskService.SearchProjectTasks(project.ID, dev.ID, domain.TaskFilter{IsCompleted: &notCompleted, Completed: &completed}, 20, 0); err == nil {
+		t.Error("SearchProjectTasks() with conflicting completion filters should fail")
+	}
+}
+
+func TestTaskService_SearchProjectTasks_Relevance(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	due := time.Date(2030, 5, 10, 12, 0, 0, 0, time.UTC)
+	dueEarlier := time.Date(2030, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	newTask := func(title, description string, dueDate *time.Time) *domain.Task {
+		task := &domain.Task{BoardID: board.ID, Title: title, Description: description,
+			Priority: domain.PriorityMedium, CreatorID: owner.ID, DueDate: dueDate}
+		if err := db.Create(task).Error; err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+		return task
+	}
+	inDescription := newTask("Update styles", "Deploy after review", &dueEarlier)
+	inTitle := newTask("Staging deploy", "", &due)
+	prefixUndated := newTask("Deploy pipeline", "", nil)
+	prefixDue := newTask("Deploy scripts", "", &due)
+	exact := newTask("deploy", "", nil)
+	newTask("Unrelated", "", &dueEarlier)
+
+	want := []uint{exact.ID, prefixDue.ID, prefixUndated.ID, inTitle.ID, inDescription.ID}
+
+	tasks, total, err := taskService.SearchProjectTasks(project.ID, owner.ID, domain.TaskFilter{Query: "Deploy"}, 20, 0)
+	if err != nil {
+		t.Fatalf("SearchProjectTasks() error = %v", err)
+	}
+	if total != int64(len(want)) {
+		t.Errorf("total = %d, want %d", total, len(want))
+	}
+	if got := taskIDs(tasks); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("SearchProjectTasks() = %v, want %v", got, want)
+	}
+
+	// Pages follow the same order and report the full total
+	page, total, err := taskService.SearchProjectTasks(project.ID, owner.ID, domain.TaskFilter{Query: "deploy"}, 2, 2)
+	if err != nil {
+		t.Fatalf("SearchProjectTasks() error = %v", err)
+	}
+	if total != int64(len(want)) {
+		t.Errorf("paged total = %d, want %d", total, len(want))
+	}
+	if got := taskIDs(page); fmt.Sprint(got) != fmt.Sprint(want[2:4]) {
+		t.Errorf("second page = %v, want %v", got, want[2:4])
+	}
+}
+
+// boardPositions returns the board's task IDs by position and fails unless the
+// positions are exactly 0..n-1
+func boardPositions(t *testing.T, db *gorm.DB, boardID uint) []uint {
+	t.Helper()
+
+	var tasks []domain.Task
+	db.Where("board_id = ?", boardID).Order("position ASC").Find(&tasks)
+
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		if task.Position != i {
+			t.Fatalf("board %d positions are not contiguous: task %d at %d, want %d", boardID, task.ID, task.Position, i)
+		}
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestTaskService_MoveKeepsPositionsContiguous(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	todo := createTestBoard(t, db, project.ID, "Todo")
+	doing := createTestBoard(t, db, project.ID, "Doing")
+
+	tasks := make([]*domain.Task, 5)
+	for i := range tasks {
+		task, err := taskService.Create(todo.ID, owner.ID, &domain.CreateTaskRequest{Title: fmt.Sprintf("task %d", i)})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		tasks[i] = task
+	}
+	a, b, c, d, e := tasks[0].ID, tasks[1].ID, tasks[2].ID, tasks[3].ID, tasks[4].ID
+
+	moves := []struct {
+		task      uint
+		board     uint
+		position  int
+		wantTodo  []uint
+		wantDoing []uint
+	}{
+		{task: b, board: doing.ID, position: 0, wantTodo: []uint{a, c, d, e}, wantDoing: []uint{b}},
+		{task: d, board: doing.ID, position: 0, wantTodo: []uint{a, c, e}, wantDoing: []uint{d, b}},
+		{task: e, board: todo.ID, position: 0, wantTodo: []uint{e, a, c}, wantDoing: []uint{d, b}},
+		{task: a, board: doing.ID, position: 99, wantTodo: []uint{e, c}, wantDoing: []uint{d, b, a}},
+		{task: d, board: doing.ID, position: 2, wantTodo: []uint{e, c}, wantDoing: []uint{b, a, d}},
+		{task: b, board: todo.ID, position: 1, wantTodo: []uint{e, b, c}, wantDoing: []uint{a, d}},
+	}
+
+	for i, move := range moves {
+		if err := taskService.Move(move.task, owner.ID, &domain.MoveTaskRequest{BoardID: move.board, Position: move.position}); err != nil {
+			t.Fatalf("move %d: Move() error = %v", i, err)
+		}
+		if got := boardPositions(t, db, todo.ID); fmt.Sprint(got) != fmt.Sprint(move.wantTodo) {
+			t.Errorf("move %d: todo = %v, want %v", i, got, move.wantTodo)
+		}
+		if got := boardPositions(t, db, doing.ID); fmt.Sprint(got) != fmt.Sprint(move.wantDoing) {
+			t.Errorf("move %d: doing = %v, want %v", i, got, move.wantDoing)
+		}
+	}
+
+	// Deleting closes the gap and new tasks append after the last position
+	if err := taskService.Delete(b, owner.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	created, err := taskService.Create(todo.ID, owner.ID, &domain.CreateTaskRequest{Title: "late"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got, want := boardPositions(t, db, todo.ID), []uint{e, c, created.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("todo after delete and create = %v, want %v", got, want)
+	}
+}
+
+func TestTaskService_Reorder(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	other := createTestBoard(t, db, project.ID, "Done")
+
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		task, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: fmt.Sprintf("task %d", i)})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+	stranger := createTestTask(t, db, other.ID, owner.ID, nil)
+
+	rejected := []struct {
+		name    string
+		userID  uint
+		taskIDs []uint
+	}{
+		{name: "viewer", userID: viewer.ID, taskIDs: []uint{ids[2], ids[1], ids[0]}},
+		{name: "missing task", userID: owner.ID, taskIDs: []uint{ids[2], ids[1]}},
+		{name: "duplicate task", userID: owner.ID, taskIDs: []uint{ids[2], ids[2], ids[1], ids[0]}},
+		{name: "task from another board", userID: owner.ID, taskIDs: []uint{ids[2], ids[1], stranger.ID}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := taskService.Reorder(board.ID, tt.userID, &domain.ReorderTasksRequest{TaskIDs: tt.taskIDs}); err == nil {
+				t.Error("Reorder() should fail")
+			}
+			if got := boardPositions(t, db, board.ID); fmt.Sprint(got) != fmt.Sprint(ids) {
+				t.Errorf("positions changed to %v after rejected reorder", got)
+			}
+		})
+	}
+
+	want := []uint{ids[2], ids[0], ids[1]}
+	if err := taskService.Reorder(board.ID, owner.ID, &domain.ReorderTasksRequest{TaskIDs: want}); err != nil {
+		t.Fatalf("Reorder() error = %v", err)
+	}
+	if got := boardPositions(t, db, board.ID); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("positions = %v, want %v", got, want)
+	}
+}
+
+func TestTaskService_ListAssignedToUser(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+
+	apollo := createTestProject(t, db, "Apollo", owner)
+	gemini := createTestProject(t, db, "Gemini", owner)
+	left := createTestProject(t, db, "Mercury", owner)
+	addTestMember(t, db, apollo.ID, dev.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, gemini.ID, dev.ID, domain.ProjectRoleMember)
+
+	apolloBoard := createTestBoard(t, db, apollo.ID, "Todo")
+	geminiBoard := createTestBoard(t, db, gemini.ID, "Doing")
+	leftBoard := createTestBoard(t, db, left.ID, "Todo")
+
+	soon := time.Date(2030, 1, 5, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2030, 2, 5, 0, 0, 0, 0, time.UTC)
+
+	undated := createTestTask(t, db, apolloBoard.ID, owner.ID, &dev.ID)
+	dueLater := createTestTask(t, db, geminiBoard.ID, owner.ID, &dev.ID)
+	dueSoon := createTestTask(t, db, apolloBoard.ID, owner.ID, &dev.ID)
+	done := createTestTask(t, db, geminiBoard.ID, owner.ID, &dev.ID)
+	db.Model(dueLater).Update("due_date", later)
+	db.Model(dueSoon).Update("due_date", soon)
+	db.Model(done).Updates(map[string]interface{}{"due_date": soon, "is_completed": true})
+
+	createTestTask(t, db, apolloBoard.ID, owner.ID, &owner.ID)
+	// Assigned in a project the user no longer belongs to
+	createTestTask(t, db, leftBoard.ID, owner.ID, &dev.ID)
+
+	incomplete := false
+	cutoff := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter domain.MyTasksFilter
+		want   []uint
+	}{
+		{name: "all", filter: domain.MyTasksFilter{}, want: []uint{dueSoon.ID, done.ID, dueLater.ID, undated.ID}},
+		{name: "incomplete", filter: domain.MyTasksFilter{IsCompleted: &incomplete}, want: []uint{dueSoon.ID, dueLater.ID, undated.ID}},
+		{name: "due before", filter: domain.MyTasksFilter{DueBefore: &cutoff}, want: []uint{dueSoon.ID, done.ID}},
+		{name: "due after", filter: domain.MyTasksFilter{DueAfter: &cutoff}, want: []uint{dueLater.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := taskService.ListAssignedToUser(dev.ID, tt.filter)
+			if err != nil {
+				t.Fatalf("ListAssignedToUser() error = %v", err)
+			}
+
+			got := make([]uint, len(tasks))
+			for i, task := range tasks {
+				got[i] = task.ID
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ListAssignedToUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	tasks, _ := taskService.ListAssignedToUser(dev.ID, domain.MyTasksFilter{})
+	for _, task := range tasks {
+		if task.Board == nil || task.Board.Project == nil {
+			t.Fatalf("task %d is missing its board or project", task.ID)
+		}
+	}
+	if tasks[2].Board.Name != "Doing" || tasks[2].Board.Project.Name != "Gemini" {
+		t.Errorf("task %d grouped under %s/%s, want Gemini/Doing", tasks[2].ID, tasks[2].Board.Project.Name, tasks[2].Board.Name)
+	}
+}
+
+func TestTaskService_DueDateQueries(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+	taskRepo := repository.NewTaskRepository(db)
+
+	owner := createTestUser(t, db, "owner")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	otherBoard := createTestBoard(t, db, createTestProject(t, db, "Gemini", owner).ID, "Todo")
+
+	base := time.Date(2030, 3, 1, 12, 0, 0, 0, time.UTC)
+	dueAt := func(boardID uint, due time.Time, completed bool) *domain.Task {
+		task := createTestTask(t, db, boardID, owner.ID, nil)
+		db.Model(task).Updates(map[string]interface{}{"due_date": due, "is_completed": completed})
+		return task
+	}
+
+	atFrom := dueAt(board.ID, base, false)
+	inside := dueAt(board.ID, base.Add(time.Hour), false)
+	atTo := dueAt(board.ID, base.Add(2*time.Hour), false)
+	dueAt(board.ID, base.Add(time.Hour), true)
+	dueAt(otherBoard.ID, base.Add(time.Hour), false)
+
+	tasks, err := taskRepo.FindDueBetween(project.ID, base, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("FindDueBetween() error = %v", err)
+	}
+	if got, want := taskIDs(tasks), []uint{atFrom.ID, inside.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("FindDueBetween() = %v, want %v (from inclusive, to exclusive)", got, want)
+	}
+	if tasks, _ := taskRepo.FindDueBetween(project.ID, base.Add(2*time.Hour), base.Add(3*time.Hour)); len(tasks) != 1 || tasks[0].ID != atTo.ID {
+		t.Errorf("FindDueBetween() of the next window = %v, want [%d]", taskIDs(tasks), atTo.ID)
+	}
+
+	now := time.Now()
+	overdue := dueAt(board.ID, now.Add(-time.Minute), false)
+	upcoming := dueAt(board.ID, now.Add(time.Hour), false)
+	dueAt(board.ID, now.Add(48*time.Hour), false)
+
+	// Tasks due in 2030 are neither overdue nor due within a day
+	tasks, err = taskService.GetOverdueTasks(project.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetOverdueTasks() error = %v", err)
+	}
+	if got, want := taskIDs(tasks), []uint{overdue.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetOverdueTasks() = %v, want %v", got, want)
+	}
+
+	tasks, err = taskService.GetUpcomingTasks(project.ID, owner.ID, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetUpcomingTasks() error = %v", err)
+	}
+	if got, want := taskIDs(tasks), []uint{upcoming.ID}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetUpcomingTasks() = %v, want %v", got, want)
+	}
+
+	if _, err := taskService.GetOverdueTasks(project.ID, outsider.ID); err == nil {
+		t.Error("GetOverdueTasks() by a non-member should fail")
+	}
+	if _, err := taskService.GetUpcomingTasks(project.ID, outsider.ID, time.Hour); err == nil {
+		t.Error("GetUpcomingTasks() by a non-member should fail")
+	}
+}
+
+func TestReminderScheduler_SendDueReminders(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+	scheduler := NewReminderScheduler(repository.NewTaskRepository(db), nil, 0)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	now := time.Now()
+	due := createTestTask(t, db, board.ID, owner.ID, nil)
+	db.Model(due).Update("due_date", now.Add(-time.Minute))
+	notYet := createTestTask(t, db, board.ID, owner.ID, nil)
+	db.Model(notYet).Update("due_date", now.Add(time.Hour))
+	done := createTestTask(t, db, board.ID, owner.ID, nil)
+	db.Model(done).Updates(map[string]interface{}{"due_date": now.Add(-time.Minute), "is_completed": true})
+
+	reminded, err := scheduler.SendDueReminders(now)
+	if err != nil {
+		t.Fatalf("SendDueReminders() error = %v", err)
+	}
+	if len(reminded) != 1 || reminded[0] != due.ID {
+		t.Fatalf("SendDueReminders() = %v, want [%d]", reminded, due.ID)
+	}
+
+	// The same task is not reminded about again
+	if again, _ := scheduler.SendDueReminders(now.Add(time.Minute)); len(again) != 0 {
+		t.Errorf("second SendDueReminders() = %v, want none", again)
+	}
+
+	// The other task fires once its time comes
+	if later, _ := scheduler.SendDueReminders(now.Add(2 * time.Hour)); len(later) != 1 || later[0] != notYet.ID {
+		t.Errorf("SendDueReminders() after the second due date = %v, want [%d]", later, notYet.ID)
+	}
+
+	// Moving the due date re-arms the reminder
+	newDue := now.Add(3 * time.Hour)
+	if _, err := taskService.Update(due.ID, owner.ID, &domain.UpdateTaskRequest{DueDate: domain.SetTime(&newDue)}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if early, _ := scheduler.SendDueReminders(now.Add(2 * time.Hour)); len(early) != 0 {
+		t.Errorf("SendDueReminders() before the new due date = %v, want none", early)
+	}
+	if rearmed, _ := scheduler.SendDueReminders(now.Add(4 * time.Hour)); len(rearmed) != 1 || rearmed[0] != due.ID {
+		t.Errorf("SendDueReminders() after the new due date = %v, want [%d]", rearmed, due.ID)
+	}
+}
+
+func TestReminderScheduler_SendDueSoonReminders(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+	scheduler := NewReminderScheduler(repository.NewTaskRepository(db), nil, 24*time.Hour)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	now := time.Now()
+	withDue := func(assigneeID *uint, due time.Time) *domain.Task {
+		task := createTestTask(t, db, board.ID, owner.ID, assigneeID)
+		db.Model(task).Update("due_date", due)
+		return task
+	}
+	soon := withDue(&owner.ID, now.Add(2*time.Hour))
+	withDue(nil, now.Add(2*time.Hour))                 // Nobody to remind
+	later := withDue(&owner.ID, now.Add(48*time.Hour)) // Outside the window
+	withDue(&owner.ID, now.Add(-time.Hour))            // Already overdue
+	done := withDue(&owner.ID, now.Add(time.Hour))
+	db.Model(done).Update("is_completed", true)
+
+	reminded, err := scheduler.SendDueSoonReminders(now)
+	if err != nil {
+		t.Fatalf("SendDueSoonReminders() error = %v", err)
+	}
+	if len(reminded) != 1 || reminded[0] != soon.ID {
+		t.Fatalf("SendDueSoonReminders() = %v, want [%d]", reminded, soon.ID)
+	}
+
+	// The same task is not reminded about again within its window
+	if again, _ := scheduler.SendDueSoonReminders(now.Add(time.Hour)); len(again) != 0 {
+		t.Errorf("second SendDueSoonReminders() = %v, want none", again)
+	}
+
+	// The later task fires once it enters the window
+	if next, _ := scheduler.SendDueSoonReminders(now.Add(25 * time.Hour)); len(next) != 1 || next[0] != later.ID {
+		t.Errorf("SendDueSoonReminders() a day later = %v, want [%d]", next, later.ID)
+	}
+
+	// Moving the due date re-arms the reminder
+	newDue := now.Add(72 * time.Hour)
+	if _, err := taskService.Update(soon.ID, owner.ID, &domain.UpdateTaskRequest{DueDate: domain.SetTime(&newDue)}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if rearmed, _ := scheduler.SendDueSoonReminders(now.Add(60 * time.Hour)); len(rearmed) != 1 || rearmed[0] != soon.ID {
+		t.Errorf("SendDueSoonReminders() before the new due date = %v, want [%d]", rearmed, soon.ID)
+	}
+
+	disabled := NewReminderScheduler(repository.NewTaskRepository(db), nil, 0)
+	if got, err := disabled.SendDueSoonReminders(now); err != nil || len(got) != 0 {
+		t.Errorf("SendDueSoonReminders() without a window = %v, %v, want none", got, err)
+	}
+}
+
+func TestTaskService_Subtasks(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	otherProject := createTestProject(t, db, "Gemini", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	otherBoard := createTestBoard(t, db, otherProject.ID, "Todo")
+
+	parent, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "parent", AutoCompleteParent: true})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	var subtasks []*domain.Task
+	for i := 0; i < 2; i++ {
+		subtask, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{
+			Title:        fmt.Sprintf("subtask %d", i),
+			ParentTaskID: &parent.ID,
+		})
+		if err != nil {
+			t.Fatalf("Create() subtask error = %v", err)
+		}
+		subtasks = append(subtasks, subtask)
+	}
+
+	foreign := createTestTask(t, db, otherBoard.ID, owner.ID, nil)
+	if _, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "stray", ParentTaskID: &foreign.ID}); err == nil {
+		t.Error("Create() with a parent from another project should fail")
+	}
+
+	// The parent can't become a child of its own subtask, nor of itself
+	if _, err := taskService.Update(parent.ID, owner.ID, &domain.UpdateTaskRequest{ParentTaskID: &subtasks[0].ID}); err == nil {
+		t.Error("Update() making a task its own ancestor should fail")
+	}
+	if _, err := taskService.Update(parent.ID, owner.ID, &domain.UpdateTaskRequest{ParentTaskID: &parent.ID}); err == nil {
+		t.Error("Update() making a task its own parent should fail")
+	}
+
+	if _, err := taskService.Transfer(subtasks[0].ID, owner.ID, &domain.TransferTaskRequest{BoardID: otherBoard.ID}); err == nil {
+		t.Error("Transfer() of a subtask to another project should fail")
+	}
+
+	got, err := taskService.GetByID(parent.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if len(got.Subtasks) != 2 || got.SubtaskProgress == nil || got.SubtaskProgress.Total != 2 || got.SubtaskProgress.Completed != 0 {
+		t.Fatalf("subtasks = %d, progress = %+v, want 2 with 0/2 completed", len(got.Subtasks), got.SubtaskProgress)
+	}
+
+	done := true
+	if _, err := taskService.Update(subtasks[0].ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &done}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, _ = taskService.GetByID(parent.ID, owner.ID)
+	if got.IsCompleted {
+		t.Error("parent completed while a subtask is still open")
+	}
+	if got.SubtaskProgress.Completed != 1 {
+		t.Errorf("progress = %+v, want 1/2 completed", got.SubtaskProgress)
+	}
+
+	if _, err := taskService.Update(subtasks[1].ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &done}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, _ = taskService.GetByID(parent.ID, owner.ID)
+	if !got.IsCompleted || got.CompletedAt == nil {
+		t.Error("parent should be completed with its last subtask")
+	}
+
+	// Without the flag the parent stays open
+	manual, _ := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "manual"})
+	child, _ := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "child", ParentTaskID: &manual.ID})
+	if _, err := taskService.Update(child.ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &done}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, _ := taskService.GetByID(manual.ID, owner.ID); got.IsCompleted {
+		t.Error("parent without auto_complete_parent should stay open")
+	}
+
+	// Detaching leaves a top-level task
+	detach := uint(0)
+	updated, err := taskService.Update(child.ID, owner.ID, &domain.UpdateTaskRequest{ParentTaskID: &detach})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if updated.ParentTaskID != nil {
+		t.Errorf("ParentTaskID = %d, want nil", *updated.ParentTaskID)
+	}
+
+	// Deleting a parent keeps its subtasks
+	if err := taskService.Delete(parent.ID, owner.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	orphan, err := taskService.GetByID(subtasks[0].ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() of a subtask after deleting its parent error = %v", err)
+	}
+	if orphan.ParentTaskID != nil {
+		t.Errorf("ParentTaskID = %d, want nil after the parent was deleted", *orphan.ParentTaskID)
+	}
+}
+
+func TestTaskService_AssignShortcuts(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, dev.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	task := createTestTask(t, db, board.ID, owner.ID, &owner.ID)
+
+	// Taking over a task assigned to someone else
+	assigned, err := taskService.AssignToMe(task.ID, dev.ID)
+	if err != nil {
+		t.Fatalf("AssignToMe() error = %v", err)
+	}
+	if assigned.AssigneeID == nil || *assigned.AssigneeID != dev.ID {
+		t.Errorf("AssigneeID = %v, want %d", assigned.AssigneeID, dev.ID)
+	}
+	if assigned.Assignee == nil || assigned.Assignee.ID != dev.ID {
+		t.Errorf("Assignee = %+v, want user %d", assigned.Assignee, dev.ID)
+	}
+
+	if _, err := taskService.AssignToMe(task.ID, viewer.ID); err == nil {
+		t.Error("AssignToMe() by a viewer should fail")
+	}
+	if _, err := taskService.Unassign(task.ID, viewer.ID); err == nil {
+		t.Error("Unassign() by a viewer should fail")
+	}
+
+	unassigned, err := taskService.Unassign(task.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("Unassign() error = %v", err)
+	}
+	if unassigned.AssigneeID != nil || unassigned.Assignee != nil {
+		t.Errorf("AssigneeID = %v, want nil", unassigned.AssigneeID)
+	}
+
+	var reloaded domain.Task
+	db.First(&reloaded, task.ID)
+	if reloaded.AssigneeID != nil {
+		t.Errorf("stored AssigneeID = %d, want nil", *reloaded.AssigneeID)
+	}
+
+	// Unassigning again changes nothing and records nothing
+	if _, err := taskService.Unassign(task.ID, owner.ID); err != nil {
+		t.Fatalf("Unassign() of an unassigned task error = %v", err)
+	}
+
+	activities, err := taskService.GetTaskActivity(task.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetTaskActivity() error = %v", err)
+	}
+	want := []domain.ActivityChanges{
+		{"assignee_id": {From: float64(owner.ID), To: float64(dev.ID)}},
+		{"assignee_id": {From: float64(dev.ID), To: nil}},
+	}
+	if len(activities) != len(want) {
+		t.Fatalf("GetTaskActivity() returned %d entries, want %d", len(activities), len(want))
+	}
+	for i, activity := range activities {
+		if activity.Action != domain.ActivityTaskAssigned || fmt.Sprint(activity.Changes) != fmt.Sprint(want[i]) {
+			t.Errorf("activity %d = %s %v, want %s %v", i, activity.Action, activity.Changes, domain.ActivityTaskAssigned, want[i])
+		}
+	}
+}
+
+func TestTaskService_Recurrence(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	project := createTestProject(t, db, "Apollo", owner)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	label := &domain.Label{ProjectID: project.ID, Name: "Ops", Color: "#0075ca"}
+	db.Create(label)
+
+	invalid := []domain.RecurrenceRule{
+		{Frequency: "yearly"},
+		{Frequency: domain.RecurrenceWeekly, Interval: -1},
+	}
+	for _, rule := range invalid {
+		rule := rule
+		if _, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "bad", Recurrence: &rule}); err == nil {
+			t.Errorf("Create() with recurrence %+v should fail", rule)
+		}
+	}
+
+	due := time.Date(2030, 5, 10, 9, 0, 0, 0, time.UTC)
+	weekly, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{
+		Title:      "Rotate keys",
+		Priority:   domain.PriorityHigh,
+		DueDate:    &due,
+		AssigneeID: &owner.ID,
+		LabelIDs:   []uint{label.ID},
+		Recurrence: &domain.RecurrenceRule{Frequency: domain.RecurrenceWeekly},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if weekly.Recurrence == nil || weekly.Recurrence.Interval != 1 {
+		t.Fatalf("Recurrence = %+v, want weekly with interval 1", weekly.Recurrence)
+	}
+
+	completed := true
+	done, err := taskService.Update(weekly.ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &completed})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if done.Recurrence != nil {
+		t.Errorf("completed task Recurrence = %+v, want nil", done.Recurrence)
+	}
+
+	tasks, err := taskService.ListByBoard(board.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("ListByBoard() error = %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("board has %d tasks, want the completed task and its next occurrence", len(tasks))
+	}
+	var next *domain.Task
+	for _, task := range tasks {
+		if task.ID != weekly.ID {
+			next = task
+		}
+	}
+
+	if want := due.AddDate(0, 0, 7); next.DueDate == nil || !next.DueDate.Equal(want) {
+		t.Errorf("next DueDate = %v, want %v", next.DueDate, want)
+	}
+	if next.IsCompleted || next.Title != weekly.Title || next.Priority != domain.PriorityHigh {
+		t.Errorf("next occurrence = %+v, want an open copy of the completed task", next)
+	}
+	if next.AssigneeID == nil || *next.AssigneeID != owner.ID {
+		t.Errorf("next AssigneeID = %v, want %d", next.AssigneeID, owner.ID)
+	}
+	if next.Recurrence == nil || next.Recurrence.Frequency != domain.RecurrenceWeekly {
+		t.Errorf("next Recurrence = %+v, want weekly", next.Recurrence)
+	}
+	if next.DisplayID == weekly.DisplayID {
+		t.Errorf("next DisplayID = %s, want a new number", next.DisplayID)
+	}
+	reloaded, err := taskService.GetByID(next.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if len(reloaded.Labels) != 1 || reloaded.Labels[0].ID != label.ID {
+		t.Errorf("next labels = %+v, want label %d", reloaded.Labels, label.ID)
+	}
+
+	// Reopening and completing again doesn't create another occurrence
+	reopened := false
+	if _, err := taskService.Update(weekly.ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &reopened}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := taskService.Update(weekly.ID, owner.ID, &domain.UpdateTaskRequest{IsCompleted: &completed}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	var count int64
+	db.Model(&domain.Task{}).Where("board_id = ?", board.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("board has %d tasks after completing again, want 2", count)
+	}
+}
+
+func TestRecurrenceRule_Next(t *testing.T) {
+	tests := []struct {
+		rule domain.RecurrenceRule
+		from time.Time
+		want time.Time
+	}{
+		{domain.RecurrenceRule{Frequency: domain.RecurrenceDaily, Interval: 3},
+			time.Date(2030, 2, 27, 0, 0, 0, 0, time.UTC), time.Date(2030, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{domain.RecurrenceRule{Frequency: domain.RecurrenceWeekly, Interval: 2},
+			time.Date(2030, 5, 10, 0, 0, 0, 0, time.UTC), time.Date(2030, 5, 24, 0, 0, 0, 0, time.UTC)},
+		{domain.RecurrenceRule{Frequency: domain.RecurrenceMonthly, Interval: 1},
+			time.Date(2030, 5, 15, 0, 0, 0, 0, time.UTC), time.Date(2030, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{domain.RecurrenceRule{Frequency: domain.RecurrenceMonthly, Interval: 1},
+			time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2030, 2, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%+v.Next(%s) = %s, want %s", tt.rule, tt.from.Format("2006-01-02"), got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestTaskService_GetProjectStats(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, alice.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	now := time.Now()
+	seed := func(assigneeID *uint, priority domain.TaskPriority, createdAt time.Time, completedAfter time.Duration, dueDate *time.Time) {
+		t.Helper()
+		task := createTestTask(t, db, board.ID, owner.ID, assigneeID)
+		updates := map[string]interface{}{"priority": priority, "created_at": createdAt, "due_date": dueDate}
+		if completedAfter > 0 {
+			updates["is_completed"] = true
+			updates["completed_at"] = createdAt.Add(completedAfter)
+		}
+		if err := db.Model(task).Updates(updates).Error; err != nil {
+			t.Fatalf("failed to seed task: %v", err)
+		}
+	}
+
+	yesterday := now.AddDate(0, 0, -1)
+	tomorrow := now.AddDate(0, 0, 1)
+	// Completed before the last 7 days, after 4 hours
+	seed(&alice.ID, domain.PriorityHigh, now.AddDate(0, 0, -10), 4*time.Hour, nil)
+	// Completed within the last 7 days, after 8 hours, past its due date
+	seed(&alice.ID, domain.PriorityUrgent, now.AddDate(0, 0, -3), 8*time.Hour, &yesterday)
+	seed(&owner.ID, domain.PriorityMedium, now.AddDate(0, 0, -2), 0, &yesterday)
+	seed(nil, domain.PriorityMedium, now.AddDate(0, 0, -1), 0, &tomorrow)
+
+	// Tasks of other projects are not counted
+	other := createTestProject(t, db, "Gemini", owner)
+	createTestTask(t, db, createTestBoard(t, db, other.ID, "Todo").ID, owner.ID, &owner.ID)
+
+	stats, err := taskService.GetProjectStats(project.ID, alice.ID)
+	if err != nil {
+		t.Fatalf("GetProjectStats() error = %v", err)
+	}
+
+	if stats.Total != 4 {
+		t.Errorf("Total = %d, want 4", stats.Total)
+	}
+	if stats.ByStatus["open"] != 2 || stats.ByStatus["completed"] != 2 {
+		t.Errorf("ByStatus = %v, want 2 open and 2 completed", stats.ByStatus)
+	}
+	wantPriority := map[domain.TaskPriority]int64{
+		domain.PriorityLow:    0,
+		domain.PriorityMedium: 2,
+		domain.PriorityHigh:   1,
+		domain.PriorityUrgent: 1,
+	}
+	if !reflect.DeepEqual(stats.ByPriority, wantPriority) {
+		t.Errorf("ByPriority = %v, want %v", stats.ByPriority, wantPriority)
+	}
+
+	byAssignee := make(map[uint]int64)
+	for _, count := range stats.ByAssignee {
+		var id uint
+		if count.AssigneeID != nil {
+			id = *count.AssigneeID
+		}
+		byAssignee[id] = count.Count
+	}
+	wantAssignee := map[uint]int64{alice.ID: 2, owner.ID: 1, 0: 1}
+	if !reflect.DeepEqual(byAssignee, wantAssignee) {
+		t.Errorf("ByAssignee = %v, want %v (0 is unassigned)", byAssignee, wantAssignee)
+	}
+	if len(stats.ByAssignee) > 0 && (stats.ByAssignee[0].Username != "alice" || stats.ByAssignee[0].Count != 2) {
+		t.Errorf("ByAssignee[0] = %+v, want alice with 2 tasks first", stats.ByAssignee[0])
+	}
+
+	// The completed task past its due date is not overdue
+	if stats.Overdue != 1 {
+		t.Errorf("Overdue = %d, want 1", stats.Overdue)
+	}
+	if stats.CompletedLast7Days != 1 {
+		t.Errorf("CompletedLast7Days = %d, want 1", stats.CompletedLast7Days)
+	}
+	if stats.AverageCompletionHours == nil || math.Abs(*stats.AverageCompletionHours-6) > 0.01 {
+		t.Errorf("AverageCompletionHours = %v, want 6", stats.AverageCompletionHours)
+	}
+
+	// Without completed tasks there is no average
+	empty := createTestProject(t, db, "Mercury", owner)
+	stats, err = taskService.GetProjectStats(empty.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetProjectStats() error = %v", err)
+	}
+	if stats.Total != 0 || stats.AverageCompletionHours != nil {
+		t.Errorf("empty project stats = %+v, want no tasks and no average", stats)
+	}
+
+	if _, err := taskService.GetProjectStats(project.ID, outsider.ID); err == nil {
+		t.Error("GetProjectStats() by non-member succeeded, want error")
+	}
+}
+
+func TestTaskService_Update_PresentFieldsOnly(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	dev := createTestUser(t, db, "dev")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, dev.ID, domain.ProjectRoleMember)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	task, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{
+		Title:       "Write spec",
+		Description: "Cover the API",
+		AssigneeID:  &owner.ID,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Requests are decoded from JSON as the handler does
+	update := func(body string) (*domain.Task, error) {
+		t.Helper()
+		var req domain.UpdateTaskRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("json.Unmarshal(%s) error = %v", body, err)
+		}
+		return taskService.Update(task.ID, owner.ID, &req)
+	}
+	check := func(got *domain.Task, title, description string, assigneeID *uint) {
+		t.Helper()
+		if got.Title != title || got.Description != description {
+			t.Errorf("title, description = %q, %q, want %q, %q", got.Title, got.Description, title, description)
+		}
+		if derefID(got.AssigneeID) != derefID(assigneeID) {
+			t.Errorf("assignee = %v, want %v", derefID(got.AssigneeID), derefID(assigneeID))
+		}
+	}
+
+	updated, err := update(`{"title": "Write the spec"}`)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	check(updated, "Write the spec", "Cover the API", &owner.ID)
+
+	// Reassigning replaces the loaded assignee
+	if updated, err = update(fmt.Sprintf(`{"assignee_id": %d}`, dev.ID)); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	check(updated, "Write the spec", "Cover the API", &dev.ID)
+	if updated.Assignee == nil || updated.Assignee.ID != dev.ID {
+		t.Errorf("loaded assignee = %+v, want user %d", updated.Assignee, dev.ID)
+	}
+
+	if updated, err = update(`{"description": ""}`); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	check(updated, "Write the spec", "", &dev.ID)
+
+	if updated, err = update(`{"assignee_id": null}`); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	check(updated, "Write the spec", "", nil)
+
+	if _, err := update(`{"title": ""}`); err == nil {
+		t.Error("Update() with an empty title should fail")
+	}
+
+	reloaded, err := taskService.GetByID(task.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	check(reloaded, "Write the spec", "", nil)
+}
+
+// derefID renders an optional ID for comparison, "none" when nil
+func derefID(id *uint) string {
+	if id == nil {
+		return "none"
+	}
+	return fmt.Sprint(*id)
+}
+
+func TestTaskService_ChecklistReorderAndConvert(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	task, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "Release"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	other, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "Announce"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	var ids []uint
+	for i, title := range []string{"Tag", "Build", "Publish"} {
+		item, err := taskService.AddChecklistItem(task.ID, owner.ID, &domain.CreateChecklistItemRequest{Title: title, Position: i})
+		if err != nil {
+			t.Fatalf("AddChecklistItem() error = %v", err)
+		}
+		ids = append(ids, item.ID)
+	}
+	stranger, err := taskService.AddChecklistItem(other.ID, owner.ID, &domain.CreateChecklistItemRequest{Title: "Tweet"})
+	if err != nil {
+		t.Fatalf("AddChecklistItem() error = %v", err)
+	}
+
+	checklist := func() []uint {
+		t.Helper()
+		var order []uint
+		db.Model(&domain.ChecklistItem{}).Where("task_id = ?", task.ID).Order("position ASC").Pluck("id", &order)
+		return order
+	}
+
+	rejected := []struct {
+		name    string
+		userID  uint
+		itemIDs []uint
+	}{
+		{name: "viewer", userID: viewer.ID, itemIDs: []uint{ids[2], ids[1], ids[0]}},
+		{name: "missing item", userID: owner.ID, itemIDs: []uint{ids[2], ids[1]}},
+		{name: "duplicate item", userID: owner.ID, itemIDs: []uint{ids[2], ids[2], ids[1], ids[0]}},
+		{name: "item of another task", userID: owner.ID, itemIDs: []uint{ids[2], ids[1], stranger.ID}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := taskService.ReorderChecklist(task.ID, tt.userID, &domain.ReorderChecklistRequest{ItemIDs: tt.itemIDs}); err == nil {
+				t.Error("ReorderChecklist() should fail")
+			}
+			if got := checklist(); fmt.Sprint(got) != fmt.Sprint(ids) {
+				t.Errorf("checklist = %v after rejected reorder, want %v", got, ids)
+			}
+		})
+	}
+
+	want := []uint{ids[2], ids[0], ids[1]}
+	if err := taskService.ReorderChecklist(task.ID, owner.ID, &domain.ReorderChecklistRequest{ItemIDs: want}); err != nil {
+		t.Fatalf("ReorderChecklist() error = %v", err)
+	}
+	if got := checklist(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("checklist = %v, want %v", got, want)
+	}
+
+	if _, err := taskService.ConvertChecklistItem(task.ID, ids[1], viewer.ID); err == nil {
+		t.Error("ConvertChecklistItem() by viewer should fail")
+	}
+	if _, err := taskService.ConvertChecklistItem(task.ID, stranger.ID, owner.ID); err == nil {
+		t.Error("ConvertChecklistItem() with an item of another task should fail")
+	}
+	if got := checklist(); len(got) != 3 {
+		t.Fatalf("checklist = %v after rejected conversions, want 3 items", got)
+	}
+
+	converted, err := taskService.ConvertChecklistItem(task.ID, ids[1], owner.ID)
+	if err != nil {
+		t.Fatalf("ConvertChecklistItem() error = %v", err)
+	}
+	if converted.Title != "Build" || converted.BoardID != board.ID || converted.DisplayID == "" {
+		t.Errorf("converted task = %q on board %d (%q), want Build on board %d", converted.Title, converted.BoardID, converted.DisplayID, board.ID)
+	}
+	if got := checklist(); fmt.Sprint(got) != fmt.Sprint([]uint{ids[2], ids[0]}) {
+		t.Errorf("checklist = %v after conversion, want %v", got, []uint{ids[2], ids[0]})
+	}
+}
+
+func TestTaskService_TimeTracking(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	viewer := createTestUser(t, db, "viewer")
+	outsider := createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+
+	estimate := 120
+	task, err := taskService.Create(board.ID, owner.ID, &domain.CreateTaskRequest{Title: "Write report", EstimatedMinutes: &estimate})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if _, err := taskService.LogTime(task.ID, viewer.ID, &domain.LogTimeRequest{Minutes: 30}); err == nil {
+		t.Error("LogTime() by a viewer succeeded, want an access error")
+	}
+	for _, minutes := range []int{0, -5, 24*60 + 1} {
+		if _, err := taskService.LogTime(task.ID, owner.ID, &domain.LogTimeRequest{Minutes: minutes}); err == nil {
+			t.Errorf("LogTime(%d minutes) succeeded, want an error", minutes)
+		}
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	older, err := taskService.LogTime(task.ID, owner.ID, &domain.LogTimeRequest{Minutes: 30, Note: "outline", LoggedAt: &yesterday})
+	if err != nil {
+		t.Fatalf("LogTime() error = %v", err)
+	}
+	if older.User == nil || older.User.ID != owner.ID {
+		t.Errorf("LogTime() user = %+v, want the owner loaded", older.User)
+	}
+	latest, err := taskService.LogTime(task.ID, owner.ID, &domain.LogTimeRequest{Minutes: 45})
+	if err != nil {
+		t.Fatalf("LogTime() error = %v", err)
+	}
+
+	// Saving the task must not overwrite the logged total
+	newTitle := "Write final report"
+	if _, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{Title: &newTitle}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	logs, err := taskService.GetTimeLogs(task.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetTimeLogs() error = %v", err)
+	}
+	if logs.TotalMinutes != 75 {
+		t.Errorf("TotalMinutes = %d, want 75", logs.TotalMinutes)
+	}
+	if len(logs.Entries) != 2 || logs.Entries[0].ID != latest.ID || logs.Entries[1].ID != older.ID {
+		t.Errorf("GetTimeLogs() returned %d entries, want the latest then the older one", len(logs.Entries))
+	}
+	if _, err := taskService.GetTimeLogs(task.ID, outsider.ID); err == nil {
+		t.Error("GetTimeLogs() by an outsider succeeded, want an access error")
+	}
+
+	got, err := taskService.GetByID(task.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got.LoggedMinutes != 75 || got.EstimatedMinutes == nil || *got.EstimatedMinutes != 120 {
+		t.Errorf("task time = %d logged, %v estimated, want 75 and 120", got.LoggedMinutes, got.EstimatedMinutes)
+	}
+
+	// Tasks without an estimate add nothing to the estimated total
+	createTestTask(t, db, board.ID, owner.ID, nil)
+
+	stats, err := taskService.GetProjectStats(project.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetProjectStats() error = %v", err)
+	}
+	if stats.EstimatedMinutes != 120 || stats.LoggedMinutes != 75 {
+		t.Errorf("stats time = %d estimated, %d logged, want 120 and 75", stats.EstimatedMinutes, stats.LoggedMinutes)
+	}
+
+	zero := 0
+	cleared, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{EstimatedMinutes: &zero})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if cleared.EstimatedMinutes != nil {
+		t.Errorf("EstimatedMinutes = %d after clearing, want nil", *cleared.EstimatedMinutes)
+	}
+	negative := -10
+	if _, err := taskService.Update(task.ID, owner.ID, &domain.UpdateTaskRequest{EstimatedMinutes: &negative}); err == nil {
+		t.Error("Update() with a negative estimate succeeded, want an error")
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{content: "@alice can you look?", want: []string{"alice"}},
+		{content: "thanks @bob. cc @alice, @bob", want: []string{"bob", "alice"}},
+		{content: "mail alice@example.com", want: nil},
+		{content: "@ nobody", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseMentions(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestTaskService_AddComment_Mentions(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	createTestUser(t, db, "outsider")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, alice.ID, domain.ProjectRoleViewer)
+	addTestMember(t, db, project.ID, bob.ID, domain.ProjectRoleMember)
+	task := createTestTask(t, db, createTestBoard(t, db, project.ID, "Todo").ID, owner.ID, nil)
+
+	// Non-members, unknown users and the author are not mentioned
+	comment, err := taskService.AddComment(task.ID, owner.ID, &domain.CreateCommentRequest{
+		Content: "@bob @alice please review, cc @outsider @ghost @owner (bob@example.com)",
+	})
+	if err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	if want := []uint{bob.ID, alice.ID}; !reflect.DeepEqual(comment.MentionedUserIDs, want) {
+		t.Errorf("MentionedUserIDs = %v, want %v", comment.MentionedUserIDs, want)
+	}
+
+	var mentions []domain.CommentMention
+	if err := db.Order("user_id ASC").Find(&mentions).Error; err != nil {
+		t.Fatalf("failed to load mentions: %v", err)
+	}
+	if len(mentions) != 2 || mentions[0].UserID != alice.ID || mentions[1].UserID != bob.ID {
+		t.Fatalf("stored mentions = %+v, want alice and bob", mentions)
+	}
+	if mentions[0].CommentID != comment.ID || mentions[0].TaskID != task.ID {
+		t.Errorf("mention = %+v, want comment %d on task %d", mentions[0], comment.ID, task.ID)
+	}
+
+	plain, err := taskService.AddComment(task.ID, bob.ID, &domain.CreateCommentRequest{Content: "no mentions here"})
+	if err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	if plain.MentionedUserIDs != nil {
+		t.Errorf("MentionedUserIDs = %v, want none", plain.MentionedUserIDs)
+	}
+}
+
+func TestTaskService_UpdateComment(t *testing.T) {
+	db := setupTestDB(t)
+	taskService := newTestTaskService(db)
+
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, alice.ID, domain.ProjectRoleMember)
+	task := createTestTask(t, db, createTestBoard(t, db, project.ID, "Todo").ID, owner.ID, nil)
+
+	comment, err := taskService.AddComment(task.ID, alice.ID, &domain.CreateCommentRequest{Content: "first draft"})
+	if err != nil {
+		t.Fatalf("AddComment() error = %v", err)
+	}
+	if comment.IsEdited || comment.EditedAt != nil {
+		t.Errorf("new comment is_edited = %v, edited_at = %v, want unedited", comment.IsEdited, comment.EditedAt)
+	}
+
+	if _, err := taskService.UpdateComment(comment.ID, owner.ID, &domain.UpdateCommentRequest{Content: "hijacked"}); err == nil {
+		t.Error("UpdateComment() by another user succeeded, want an error")
+	}
+	if _, err := taskService.UpdateComment(comment.ID, alice.ID, &domain.UpdateCommentRequest{Content: " \n\t "}); err == nil {
+		t.Error("UpdateComment() with blank content succeeded, want an error")
+	}
+
+	updated, err := taskService.UpdateComment(comment.ID, alice.ID, &domain.UpdateCommentRequest{Content: "<b>final</b>"})
+	if err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+	if updated.Content != "&lt;b&gt;final&lt;/b&gt;" {
+		t.Errorf("Content = %q, want the sanitized edit", updated.Content)
+	}
+
+	var stored domain.Comment
+	if err := db.First(&stored, comment.ID).Error; err != nil {
+		t.Fatalf("failed to reload comment: %v", err)
+	}
+	if stored.Content != updated.Content || !stored.IsEdited || stored.EditedAt == nil {
+		t.Errorf("stored comment = %q, is_edited = %v, edited_at = %v, want the edit marked", stored.Content, stored.IsEdited, stored.EditedAt)
+	}
+
+	// Authors demoted to viewers can no longer edit
+	if err := db.Model(&domain.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", project.ID, alice.ID).
+		Update("role", domain.ProjectRoleViewer).Error; err != nil {
+		t.Fatalf("failed to demote member: %v", err)
+	}
+	if _, err := taskService.UpdateComment(comment.ID, alice.ID, &domain.UpdateCommentRequest{Content: "again"}); err == nil {
+		t.Error("UpdateComment() by a viewer succeeded, want an access error")
+	}
+}
+
+// memoryStorage is an in-memory storage.Storage
+type memoryStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: make(map[string][]byte)}
+}
+
+func (m *memoryStorage) Save(key string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[key] = data
+	return "https://files.example.com/" + key, nil
+}
+
+func (m *memoryStorage) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, key)
+	return nil
+}
+
+func TestTaskService_Attachments(t *testing.T) {
+	db := setupTestDB(t)
+	files := newMemoryStorage()
+	taskService := NewTaskService(
+		repository.NewTaskRepository(db),
+		repository.NewBoardRepository(db),
+		repository.NewProjectRepository(db),
+		repository.NewActivityRepository(db),
+		sanitize.NewSanitizer(sanitize.ModeEscape),
+		files,
+		nil,
+		TaskOptions{MaxAttachmentSize: 16},
+	)
+
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	viewer := createTestUser(t, db, "viewer")
+	project := createTestProject(t, db, "Apollo", owner)
+	addTestMember(t, db, project.ID, member.ID, domain.ProjectRoleMember)
+	addTestMember(t, db, project.ID, viewer.ID, domain.ProjectRoleViewer)
+	board := createTestBoard(t, db, project.ID, "Todo")
+	task := createTestTask(t, db, board.ID, owner.ID, nil)
+
+	upload := func(filename, content string, declaredSize int64) *domain.AttachmentUpload {
+		return &domain.AttachmentUpload{Filename: filename, Size: declaredSize, Content: strings.NewReader(content)}
+	}
+
+	rejected := []struct {
+		name   string
+		userID uint
+		file   *domain.AttachmentUpload
+	}{
+		{name: "viewer", userID: viewer.ID, file: upload("notes.txt", "hello", 5)},
+		{name: "declared too large", userID: member.ID, file: upload("big.txt", "hello", 17)},
+		{name: "actually too large", userID: member.ID, file: upload("big.txt", strings.Repeat("x", 17), 5)},
+		{name: "no file name", userID: member.ID, file: upload("", "hello", 5)},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := taskService.AddAttachment(task.ID, tt.userID, tt.file); err == nil {
+				t.Error("AddAttachment() should fail")
+			}
+		})
+	}
+	if len(files.files) != 0 {
+		t.Fatalf("rejected uploads left %d stored files", len(files.files))
+	}
+
+	attachment, err := taskService.AddAttachment(task.ID, member.ID, upload("../../notes.txt", "hello, world", 12))
+	if err != nil {
+		t.Fatalf("AddAttachment() error = %v", err)
+	}
+	if attachment.Filename != "notes.txt" || attachment.FileSize != 12 || !strings.HasPrefix(attachment.MimeType, "text/plain") {
+		t.Errorf("attachment = %q, %d bytes, %q; want notes.txt, 12 bytes, text/plain", attachment.Filename, attachment.FileSize, attachment.MimeType)
+	}
+	stored, ok := files.files[attachment.StorageKey]
+	if !ok || !bytes.Equal(stored, []byte("hello, world")) {
+		t.Fatalf("stored file = %q, want the uploaded content", stored)
+	}
+	if attachment.FileURL != "https://files.example.com/"+attachment.StorageKey {
+		t.Errorf("FileURL = %q", attachment.FileURL)
+	}
+
+	listed, err := taskService.GetAttachments(task.ID, viewer.ID)
+	if err != nil {
+		t.Fatalf("GetAttachments() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != attachment.ID {
+		t.Errorf("GetAttachments() = %+v, want attachment %d", listed, attachment.ID)
+	}
+
+	// Other members may not delete someone else's attachment, admins may
+	other := createTestUser(t, db, "other")
+	addTestMember(t, db, project.ID, other.ID, domain.ProjectRoleMember)
+	if err := taskService.DeleteAttachment(attachment.ID, other.ID); err == nil {
+		t.Error("DeleteAttachment() by another member should fail")
+	}
+	if err := taskService.DeleteAttachment(attachment.ID, owner.ID); err != nil {
+		t.Fatalf("DeleteAttachment() error = %v", err)
+	}
+	if _, ok := files.files[attachment.StorageKey]; ok {
+		t.Error("DeleteAttachment() left the stored file behind")
+	}
+	if listed, _ := taskService.GetAttachments(task.ID, owner.ID); len(listed) != 0 {
+		t.Errorf("GetAttachments() after delete = %d attachments, want 0", len(listed))
+	}
+}
+
+func taskIDs(tasks []*domain.Task) []uint {
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
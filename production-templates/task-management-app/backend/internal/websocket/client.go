@@ -18,6 +18,7 @@ type Client struct {
 	hub       *Hub
 	conn      *websocket.Conn
 	send      chan []byte
+	done      chan struct{} // Closed when WritePump returns
 	ProjectID uint
 	UserID    uint
 }
@@ -27,6 +28,7 @@ func NewClient(hub *Hub, conn *websocket.Conn, projectID, userID uint) *Client {
 		hub:       hub,
 		conn:      conn,
 		send:      make(chan []byte, 256),
+		done:      make(chan struct{}),
 		ProjectID: projectID,
 		UserID:    userID,
 	}
@@ -34,7 +36,7 @@ func NewClient(hub *Hub, conn *websocket.Conn, projectID, userID uint) *Client {
 
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.Unregister(c)
 		if err := c.conn.Close(); err != nil {
 			log.Printf("Error closing connection: %v", err)
 		}
@@ -67,6 +69,7 @@ func (c *Client) WritePump() {
 		if err := c.conn.Close(); err != nil {
 			log.Printf("Error closing connection: %v", err)
 		}
+		close(c.done)
 	}()
 
 	for {
@@ -79,7 +82,11 @@ func (c *Client) WritePump() {
 
 			if !ok {
 				// Hub closed the channel
-				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+				closeMessage := []byte{}
+				if c.hub.shuttingDown() {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				}
+				if err := c.conn.WriteMessage(websocket.CloseMessage, closeMessage); err != nil {
 					log.Printf("Error writing close message: %v", err)
 				}
 				return
@@ -1,12 +1,17 @@
 package websocket
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/middleware"
 )
 
 var upgrader = websocket.Upgrader{
@@ -17,13 +22,36 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// Close codes sent when a connection is refused after the upgrade
+const (
+	// CloseUnauthorized is sent when the connection carries no valid access
+	// token
+	CloseUnauthorized = 4001
+	// CloseAccessDenied is sent to users who aren't members of the project
+	// they tried to subscribe to
+	CloseAccessDenied = websocket.ClosePolicyViolation
+)
+
+// TokenParser validates an access token and returns its claims
+type TokenParser func(token string) (*domain.JWTClaims, error)
+
+// AccessChecker reports whether a user holds at least the given role in a
+// project; service.ProjectService satisfies it
+type AccessChecker interface {
+	CheckAccess(projectID, userID uint, requiredRole domain.ProjectRole) (bool, error)
+}
+
 type WebSocketHandler struct {
-	hub *Hub
+	hub        *Hub
+	access     AccessChecker
+	parseToken TokenParser
 }
 
-func NewWebSocketHandler(hub *Hub) *WebSocketHandler {
+func NewWebSocketHandler(hub *Hub, access AccessChecker, parseToken TokenParser) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub: hub,
+		hub:        hub,
+		access:     access,
+		parseToken: parseToken,
 	}
 }
 
@@ -31,15 +59,22 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 	projectIDStr := c.Param("projectId")
 	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
-	// Get user ID from auth middleware context
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
+	// Browsers can't set headers on WebSocket connections, so the token may
+	// come as a query parameter instead
+	var userID uint
+	claims, authErr := h.authenticate(c)
+	hasAccess := false
+	if authErr == nil {
+		userID = claims.UserID
+		hasAccess, err = h.access.CheckAccess(uint(projectID), userID, domain.ProjectRoleViewer)
+		if err != nil {
+			log.Printf("Failed to check project access: %v", err)
+			hasAccess = false
+		}
 	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -48,18 +83,56 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 		return
 	}
 
-	client := NewClient(h.hub, conn, uint(projectID), userID.(uint))
-	h.hub.register <- client
+	if authErr != nil {
+		rejectConnection(conn, CloseUnauthorized, authErr.Error())
+		return
+	}
+	if !hasAccess {
+		rejectConnection(conn, CloseAccessDenied, "access denied")
+		return
+	}
+
+	client := NewClient(h.hub, conn, uint(projectID), userID)
+	h.hub.Register(client)
 
 	go client.WritePump()
 	go client.ReadPump()
 }
 
+// authenticate validates the access token from the Authorization header or,
+// failing that, the token query parameter
+func (h *WebSocketHandler) authenticate(c *gin.Context) (*domain.JWTClaims, error) {
+	token := c.Query("token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		bearer, ok := middleware.BearerToken(header)
+		if !ok {
+			return nil, errors.New("invalid authorization header format")
+		}
+		token = bearer
+	}
+	if token == "" {
+		return nil, errors.New("access token required")
+	}
+	return h.parseToken(token)
+}
+
+// rejectConnection closes an upgraded connection with a close frame, so
+// browser clients can see why, unlike with a plain HTTP error
+func rejectConnection(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait)); err != nil {
+		log.Printf("Error writing close message: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		log.Printf("Error closing connection: %v", err)
+	}
+}
+
 func (h *WebSocketHandler) GetOnlineUsers(c *gin.Context) {
 	projectIDStr := c.Param("projectId")
 	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		c.JSON(http.StatusBadRequest, middleware.ErrorBody(c, "invalid project ID"))
 		return
 	}
 
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+
+	"task-management-app/internal/domain"
+	"task-management-app/internal/jwtkeys"
+	"task-management-app/internal/middleware"
+)
+
+const testJWTSecret = "test-secret"
+
+// memberAccess grants viewer access to the listed users of project 1
+type memberAccess map[uint]bool
+
+func (m memberAccess) CheckAccess(projectID, userID uint, requiredRole domain.ProjectRole) (bool, error) {
+	return projectID == 1 && m[userID], nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *Hub) {
+	t.Helper()
+
+	hub := NewHub()
+	go hub.Run()
+	handler := NewWebSocketHandler(hub, memberAccess{1: true}, func(token string) (*domain.JWTClaims, error) {
+		return middleware.ParseAccessToken(token, jwtkeys.New(jwtkeys.DefaultKeyID, testJWTSecret, nil))
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws/:projectId", handler.HandleConnection)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+// signToken issues a token like the auth service does
+func signToken(t *testing.T, userID uint, tokenType, secret string) string {
+	t.Helper()
+
+	claims := &domain.JWTClaims{
+		UserID:    userID,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// dialProject connects to a project's socket with the given query parameters
+// and request headers
+func dialProject(t *testing.T, server *httptest.Server, projectID string, query url.Values, header http.Header) *websocket.Conn {
+	t.Helper()
+
+	target := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/" + projectID
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func waitForOnline(t *testing.T, hub *Hub, userID uint) {
+	t.Helper()
+
+	// Registration happens on the hub's goroutine after the handshake
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hub.GetOnlineUsers(1)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("member was not registered with the hub")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if users := hub.GetOnlineUsers(1); len(users) != 1 || users[0] != userID {
+		t.Errorf("GetOnlineUsers() = %v, want [%d]", users, userID)
+	}
+}
+
+func TestHandleConnection_Member(t *testing.T) {
+	t.Run("query token", func(t *testing.T) {
+		server, hub := newTestServer(t)
+		dialProject(t, server, "1", url.Values{"token": {signToken(t, 1, "access", testJWTSecret)}}, nil)
+		waitForOnline(t, hub, 1)
+	})
+
+	t.Run("authorization header", func(t *testing.T) {
+		server, hub := newTestServer(t)
+		header := http.Header{"Authorization": {"Bearer " + signToken(t, 1, "access", testJWTSecret)}}
+		dialProject(t, server, "1", nil, header)
+		waitForOnline(t, hub, 1)
+	})
+}
+
+func TestHandleConnection_Rejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		projectID string
+		query     url.Values
+		header    http.Header
+		wantCode  int
+	}{
+		{name: "no token", projectID: "1", wantCode: CloseUnauthorized},
+		{name: "malformed token", projectID: "1", query: url.Values{"token": {"not-a-jwt"}}, wantCode: CloseUnauthorized},
+		{name: "wrong signature", projectID: "1", query: url.Values{"token": {signToken(t, 1, "access", "other-secret")}}, wantCode: CloseUnauthorized},
+		{name: "refresh token", projectID: "1", query: url.Values{"token": {signToken(t, 1, "refresh", testJWTSecret)}}, wantCode: CloseUnauthorized},
+		{name: "malformed header", projectID: "1", header: http.Header{"Authorization": {"Token abc"}}, wantCode: CloseUnauthorized},
+		{name: "not a member", projectID: "1", query: url.Values{"token": {signToken(t, 2, "access", testJWTSecret)}}, wantCode: CloseAccessDenied},
+		{name: "member of another project", projectID: "2", query: url.Values{"token": {signToken(t, 1, "access", testJWTSecret)}}, wantCode: CloseAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hub := newTestServer(t)
+			conn := dialProject(t, server, tt.projectID, tt.query, tt.header)
+
+			_, _, err := conn.ReadMessage()
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+			}
+			if closeErr.Code != tt.wantCode {
+				t.Errorf("close code = %d, want %d", closeErr.Code, tt.wantCode)
+			}
+			if users := hub.GetOnlineUsers(1); len(users) != 0 {
+				t.Errorf("GetOnlineUsers() = %v, want none", users)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"sync"
@@ -14,8 +15,12 @@ const (
 	TypeTaskDeleted MessageType = "TASK_DELETED"
 	TypeTaskMoved   MessageType = "TASK_MOVED"
 	TypeCommentAdded MessageType = "COMMENT_ADDED"
+	TypeCommentMention MessageType = "COMMENT_MENTION"
 	TypeUserJoined  MessageType = "USER_JOINED"
 	TypeUserLeft    MessageType = "USER_LEFT"
+	TypeProjectArchived MessageType = "PROJECT_ARCHIVED"
+	TypeTaskDue     MessageType = "TASK_DUE"
+	TypeTaskDueSoon MessageType = "TASK_DUE_SOON"
 )
 
 type Message struct {
@@ -23,6 +28,10 @@ type Message struct {
 	Payload   interface{} `json:"payload"`
 	ProjectID uint        `json:"project_id"`
 	UserID    uint        `json:"user_id"`
+
+	// recipients limits delivery to these users of the project; nil sends
+	// to everyone connected to it
+	recipients map[uint]bool
 }
 
 type Hub struct {
@@ -32,6 +41,13 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// quit asks Run to stop; stopped is closed once it has, after which
+	// closed lists the clients it disconnected
+	quit     chan struct{}
+	quitOnce sync.Once
+	stopped  chan struct{}
+	closed   []*Client
 }
 
 func NewHub() *Hub {
@@ -40,6 +56,8 @@ func NewHub() *Hub {
 		broadcast:  make(chan *Message, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		quit:       make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 }
 
@@ -54,8 +72,82 @@ func (h *Hub) Run() {
 
 		case message := <-h.broadcast:
 			h.broadcastMessage(message)
+
+		case <-h.quit:
+			h.closed = h.closeAll()
+			close(h.stopped)
+			return
+		}
+	}
+}
+
+// Shutdown stops Run and disconnects every client with a going-away close
+// frame, then waits for their write pumps to finish. It returns ctx.Err() if
+// ctx expires first. Run must have been started.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.quitOnce.Do(func() { close(h.quit) })
+
+	select {
+	case <-h.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, client := range h.closed {
+		select {
+		case <-client.done:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
+	return nil
+}
+
+// closeAll closes the send channel of every registered client, which makes
+// its write pump send a close frame, and returns the clients
+func (h *Hub) closeAll() []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var clients []*Client
+	for projectID, projectClients := range h.projects {
+		for client := range projectClients {
+			close(client.send)
+			clients = append(clients, client)
+		}
+		delete(h.projects, projectID)
+	}
+
+	log.Printf("Hub stopped, disconnected %d client(s)", len(clients))
+	return clients
+}
+
+// shuttingDown reports whether Shutdown has been called
+func (h *Hub) shuttingDown() bool {
+	select {
+	case <-h.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// Register adds a client to the hub. Once the hub has stopped the client is
+// disconnected instead.
+func (h *Hub) Register(client *Client) {
+	select {
+	case h.register <- client:
+	case <-h.stopped:
+		close(client.send)
+	}
+}
+
+// Unregister removes a client from the hub
+func (h *Hub) Unregister(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.stopped:
+	}
 }
 
 func (h *Hub) registerClient(client *Client) {
@@ -130,6 +222,9 @@ func (h *Hub) broadcastMessage(message *Message) {
 		if client.UserID == message.UserID {
 			continue
 		}
+		if message.recipients != nil && !message.recipients[client.UserID] {
+			continue
+		}
 
 		select {
 		case client.send <- data:
@@ -141,8 +236,23 @@ func (h *Hub) broadcastMessage(message *Message) {
 	}
 }
 
+// Broadcast queues a message for the project's clients. Messages sent after
+// the hub has stopped are dropped.
 func (h *Hub) Broadcast(message *Message) {
-	h.broadcast <- message
+	select {
+	case h.broadcast <- message:
+	case <-h.stopped:
+	}
+}
+
+// SendToUsers delivers a message only to the given users' connections to
+// the message's project
+func (h *Hub) SendToUsers(message *Message, userIDs []uint) {
+	message.recipients = make(map[uint]bool, len(userIDs))
+	for _, userID := range userIDs {
+		message.recipients[userID] = true
+	}
+	h.Broadcast(message)
 }
 
 func (h *Hub) GetOnlineUsers(projectID uint) []uint {
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// receivedTypes drains the client's queued messages and returns their types
+func receivedTypes(t *testing.T, client *Client) []MessageType {
+	t.Helper()
+
+	var types []MessageType
+	for {
+		select {
+		case data := <-client.send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("failed to decode message: %v", err)
+			}
+			types = append(types, message.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestHub_SendToUsers(t *testing.T) {
+	hub := NewHub()
+
+	author := NewClient(hub, nil, 1, 1)
+	mentioned := NewClient(hub, nil, 1, 2)
+	mentionedElsewhere := NewClient(hub, nil, 2, 2)
+	bystander := NewClient(hub, nil, 1, 3)
+	for _, client := range []*Client{author, mentioned, mentionedElsewhere, bystander} {
+		hub.registerClient(client)
+	}
+
+	message := &Message{Type: TypeCommentMention, ProjectID: 1, UserID: 1}
+	message.recipients = map[uint]bool{1: true, 2: true}
+	hub.broadcastMessage(message)
+
+	if got := receivedTypes(t, mentioned); len(got) != 1 || got[0] != TypeCommentMention {
+		t.Errorf("mentioned user received %v, want one %s", got, TypeCommentMention)
+	}
+	for name, client := range map[string]*Client{
+		"author":                       author,
+		"mentioned in another project": mentionedElsewhere,
+		"bystander":                    bystander,
+	} {
+		if got := receivedTypes(t, client); len(got) != 0 {
+			t.Errorf("%s received %v, want nothing", name, got)
+		}
+	}
+}
+
+func TestHub_Shutdown(t *testing.T) {
+	server, hub := newTestServer(t)
+	query := url.Values{"token": {signToken(t, 1, "access", testJWTSecret)}}
+	conns := []*websocket.Conn{
+		dialProject(t, server, "1", query, nil),
+		dialProject(t, server, "1", query, nil),
+	}
+
+	// Registration happens on the hub's goroutine after the handshake
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hub.mu.RLock()
+		registered := len(hub.projects[1])
+		hub.mu.RUnlock()
+		if registered == len(conns) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d clients registered", registered, len(conns))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if len(hub.closed) != len(conns) {
+		t.Fatalf("Shutdown() disconnected %d clients, want %d", len(hub.closed), len(conns))
+	}
+	for _, client := range hub.closed {
+		if _, ok := <-client.send; ok {
+			t.Error("client send channel is still open")
+		}
+	}
+	if len(hub.projects) != 0 {
+		t.Errorf("hub still tracks %d project(s)", len(hub.projects))
+	}
+
+	for _, conn := range conns {
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("ReadMessage() error = %v, want a close frame", err)
+		}
+		if closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseGoingAway)
+		}
+	}
+
+	// A stopped hub neither blocks senders nor accepts new clients
+	hub.Broadcast(&Message{Type: TypeTaskUpdated, ProjectID: 1})
+	late := NewClient(hub, nil, 1, 1)
+	hub.Register(late)
+	if _, ok := <-late.send; ok {
+		t.Error("client registered after Shutdown was not disconnected")
+	}
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown() error = %v", err)
+	}
+}
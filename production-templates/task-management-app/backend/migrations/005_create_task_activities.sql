@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS task_activities (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_task_activities_project ON task_activities(project_id);
+CREATE INDEX idx_task_activities_task ON task_activities(task_id);
+CREATE INDEX idx_task_activities_user ON task_activities(user_id);
+CREATE INDEX idx_task_activities_created_at ON task_activities(created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS task_activities;
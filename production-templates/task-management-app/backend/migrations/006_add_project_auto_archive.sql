@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS never_auto_archive BOOLEAN NOT NULL DEFAULT false;
+
+-- +migrate Down
+ALTER TABLE projects DROP COLUMN IF EXISTS never_auto_archive;
@@ -0,0 +1,44 @@
+-- +migrate Up
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS key VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS task_counter INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS number INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS display_id VARCHAR(32) NOT NULL DEFAULT '';
+
+-- Derive keys for existing projects the same way new projects get them
+UPDATE projects
+SET key = CASE
+    WHEN LENGTH(REGEXP_REPLACE(UPPER(name), '[^A-Z0-9]', '', 'g')) >= 2
+        AND REGEXP_REPLACE(UPPER(name), '[^A-Z0-9]', '', 'g') ~ '^[A-Z]'
+    THEN LEFT(REGEXP_REPLACE(UPPER(name), '[^A-Z0-9]', '', 'g'), 4)
+    ELSE 'PROJ'
+END
+WHERE key = '';
+
+-- Number existing tasks per project in creation order
+UPDATE tasks
+SET number = numbered.number,
+    display_id = numbered.key || '-' || numbered.number
+FROM (
+    SELECT tasks.id, projects.key,
+           ROW_NUMBER() OVER (PARTITION BY boards.project_id ORDER BY tasks.created_at, tasks.id) AS number
+    FROM tasks
+    JOIN boards ON boards.id = tasks.board_id
+    JOIN projects ON projects.id = boards.project_id
+) AS numbered
+WHERE tasks.id = numbered.id AND tasks.number = 0;
+
+UPDATE projects
+SET task_counter = counts.total
+FROM (
+    SELECT boards.project_id, MAX(tasks.number) AS total
+    FROM tasks
+    JOIN boards ON boards.id = tasks.board_id
+    GROUP BY boards.project_id
+) AS counts
+WHERE projects.id = counts.project_id;
+
+-- +migrate Down
+ALTER TABLE tasks DROP COLUMN IF EXISTS display_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS number;
+ALTER TABLE projects DROP COLUMN IF EXISTS task_counter;
+ALTER TABLE projects DROP COLUMN IF EXISTS key;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- Maximum number of non-completed tasks on a board; NULL means unlimited
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS wip_limit INTEGER CHECK (wip_limit > 0);
+
+-- +migrate Down
+ALTER TABLE boards DROP COLUMN IF EXISTS wip_limit;
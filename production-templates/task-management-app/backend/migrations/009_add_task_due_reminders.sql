@@ -0,0 +1,9 @@
+-- +migrate Up
+-- When the TASK_DUE reminder was broadcast; NULL until the task falls due
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_notified_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_due_pending ON tasks(due_date) WHERE due_notified_at IS NULL AND is_completed = false;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_tasks_due_pending;
+ALTER TABLE tasks DROP COLUMN IF EXISTS due_notified_at;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Who created each board and label; NULL for rows that predate the column
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS created_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE labels ADD COLUMN IF NOT EXISTS created_by_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- +migrate Down
+ALTER TABLE labels DROP COLUMN IF EXISTS created_by_id;
+ALTER TABLE boards DROP COLUMN IF EXISTS created_by_id;
@@ -0,0 +1,11 @@
+-- +migrate Up
+-- Subtasks outlive their parent as top-level tasks
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_task_id INTEGER REFERENCES tasks(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS auto_complete_parent BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_parent_task_id ON tasks(parent_task_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_tasks_parent_task_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS auto_complete_parent;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parent_task_id;
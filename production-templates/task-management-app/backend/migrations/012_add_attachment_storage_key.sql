@@ -0,0 +1,6 @@
+-- +migrate Up
+-- Key of the attachment's file in the storage backend, used to delete it
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS storage_key VARCHAR(1000) NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE attachments DROP COLUMN IF EXISTS storage_key;
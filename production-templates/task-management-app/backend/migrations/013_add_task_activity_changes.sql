@@ -0,0 +1,6 @@
+-- +migrate Up
+-- Fields an action changed, as {"field": {"from": old, "to": new}}
+ALTER TABLE task_activities ADD COLUMN IF NOT EXISTS changes JSONB;
+
+-- +migrate Down
+ALTER TABLE task_activities DROP COLUMN IF EXISTS changes;
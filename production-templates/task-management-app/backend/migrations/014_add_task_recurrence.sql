@@ -0,0 +1,7 @@
+-- +migrate Up
+-- Recurrence rule, as {"frequency": "weekly", "interval": 1}; completing the
+-- task creates its next occurrence
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence JSONB;
+
+-- +migrate Down
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence;
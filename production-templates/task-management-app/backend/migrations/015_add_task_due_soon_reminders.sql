@@ -0,0 +1,9 @@
+-- +migrate Up
+-- When the TASK_DUE_SOON reminder was broadcast; NULL until the task nears its due date
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_due_soon_pending ON tasks(due_date) WHERE reminder_sent_at IS NULL AND is_completed = false;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_tasks_due_soon_pending;
+ALTER TABLE tasks DROP COLUMN IF EXISTS reminder_sent_at;
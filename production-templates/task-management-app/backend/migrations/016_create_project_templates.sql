@@ -0,0 +1,17 @@
+-- +migrate Up
+-- Built-in templates (owner_id NULL) are stored by the server at startup
+CREATE TABLE IF NOT EXISTS project_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    boards JSONB NOT NULL DEFAULT '[]',
+    labels JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_project_templates_owner ON project_templates(owner_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS project_templates;
@@ -0,0 +1,21 @@
+-- +migrate Up
+-- Estimate in minutes, and the running total of time logged against the task
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS estimated_minutes INTEGER;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS logged_minutes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS time_logs (
+    id SERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    minutes INTEGER NOT NULL CHECK (minutes > 0),
+    note TEXT NOT NULL DEFAULT '',
+    logged_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_time_logs_task_id ON time_logs(task_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS time_logs;
+ALTER TABLE tasks DROP COLUMN IF EXISTS logged_minutes;
+ALTER TABLE tasks DROP COLUMN IF EXISTS estimated_minutes;
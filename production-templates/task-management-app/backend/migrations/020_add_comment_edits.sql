@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS is_edited BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE comments DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS is_edited;